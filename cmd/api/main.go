@@ -19,9 +19,17 @@ import (
 	"github.com/faisalhardin/medilink/internal/config"
 	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
 	anamnesarepo "github.com/faisalhardin/medilink/internal/repo/anamnesa"
+	attachmentrepo "github.com/faisalhardin/medilink/internal/repo/attachment"
+	consentrepo "github.com/faisalhardin/medilink/internal/repo/consent"
 	inmemory "github.com/faisalhardin/medilink/internal/repo/cache/inmemory"
 	diagnosisrepo "github.com/faisalhardin/medilink/internal/repo/diagnosis"
 	procedurerepo "github.com/faisalhardin/medilink/internal/repo/procedure"
+	purchasingrepo "github.com/faisalhardin/medilink/internal/repo/purchasing"
+	stocktakerepo "github.com/faisalhardin/medilink/internal/repo/stocktake"
+	pricelistrepo "github.com/faisalhardin/medilink/internal/repo/pricelist"
+	discountrepo "github.com/faisalhardin/medilink/internal/repo/discount"
+	doctorfeerepo "github.com/faisalhardin/medilink/internal/repo/doctorfee"
+	billingrepo "github.com/faisalhardin/medilink/internal/repo/billing"
 	icd10repo "github.com/faisalhardin/medilink/internal/repo/icd10"
 	institutionrepo "github.com/faisalhardin/medilink/internal/repo/institution"
 	journeyrepo "github.com/faisalhardin/medilink/internal/repo/journey"
@@ -37,7 +45,15 @@ import (
 	staffuc "github.com/faisalhardin/medilink/internal/usecase/staff"
 
 	anamnesauc "github.com/faisalhardin/medilink/internal/usecase/anamnesa"
+	attachmentuc "github.com/faisalhardin/medilink/internal/usecase/attachment"
+	consentuc "github.com/faisalhardin/medilink/internal/usecase/consent"
 	procedureuc "github.com/faisalhardin/medilink/internal/usecase/procedure"
+	purchasinguc "github.com/faisalhardin/medilink/internal/usecase/purchasing"
+	stocktakeuc "github.com/faisalhardin/medilink/internal/usecase/stocktake"
+	pricelistuc "github.com/faisalhardin/medilink/internal/usecase/pricelist"
+	discountuc "github.com/faisalhardin/medilink/internal/usecase/discount"
+	doctorfeeuc "github.com/faisalhardin/medilink/internal/usecase/doctorfee"
+	billinguc "github.com/faisalhardin/medilink/internal/usecase/billing"
 	authCleanup "github.com/faisalhardin/medilink/internal/usecase/auth"
 	authUC "github.com/faisalhardin/medilink/internal/usecase/auth"
 	diagnosisuc "github.com/faisalhardin/medilink/internal/usecase/diagnosis"
@@ -52,7 +68,15 @@ import (
 	visituc "github.com/faisalhardin/medilink/internal/usecase/visit"
 
 	anamnesahandler "github.com/faisalhardin/medilink/internal/http/anamnesa"
+	attachmenthandler "github.com/faisalhardin/medilink/internal/http/attachment"
+	consenthandler "github.com/faisalhardin/medilink/internal/http/consent"
 	procedurehandler "github.com/faisalhardin/medilink/internal/http/procedure"
+	purchasinghandler "github.com/faisalhardin/medilink/internal/http/purchasing"
+	stocktakehandler "github.com/faisalhardin/medilink/internal/http/stocktake"
+	pricelisthandler "github.com/faisalhardin/medilink/internal/http/pricelist"
+	discounthandler "github.com/faisalhardin/medilink/internal/http/discount"
+	doctorfeehandler "github.com/faisalhardin/medilink/internal/http/doctorfee"
+	billinghandler "github.com/faisalhardin/medilink/internal/http/billing"
 	authHandler "github.com/faisalhardin/medilink/internal/http/auth"
 	diagnosishandler "github.com/faisalhardin/medilink/internal/http/diagnosis"
 	icd10handler "github.com/faisalhardin/medilink/internal/http/icd10"
@@ -65,7 +89,10 @@ import (
 	recallhandler "github.com/faisalhardin/medilink/internal/http/recall"
 	staffhandler "github.com/faisalhardin/medilink/internal/http/staff"
 
+	"github.com/faisalhardin/medilink/internal/library/eligibility"
 	"github.com/faisalhardin/medilink/internal/library/idempotency"
+	"github.com/faisalhardin/medilink/internal/library/storage"
+	"github.com/faisalhardin/medilink/internal/library/util/signedurl"
 	mwmodule "github.com/faisalhardin/medilink/internal/library/middlewares/auth"
 	"github.com/faisalhardin/medilink/internal/server"
 	"github.com/gorilla/sessions"
@@ -183,6 +210,29 @@ func main() {
 
 	procedureDB := procedurerepo.NewProcedureDB(db)
 	icd9cmDB := procedurerepo.NewICD9CMDB(db)
+	purchasingDB := purchasingrepo.NewPurchasingDB(db)
+	stockTakeDB := stocktakerepo.NewStockTakeDB(db)
+	priceListDB := pricelistrepo.NewPriceListDB(db)
+	discountDB := discountrepo.NewDiscountDB(db)
+	billingDB := billingrepo.NewBillingDB(db)
+	doctorFeeDB := doctorfeerepo.NewDoctorFeeDB(db)
+	attachmentDB := attachmentrepo.NewAttachmentDB(db)
+	consentDB := consentrepo.NewConsentDB(db)
+
+	attachmentStorage, err := storage.New(cfg.AttachmentConfig.Storage, cfg.AttachmentConfig.LocalPath)
+	if err != nil {
+		log.Fatalf("failed to init the attachment storage: %v", err)
+	}
+	eligibilityChecker, err := eligibility.New(cfg.EligibilityConfig.Driver)
+	if err != nil {
+		log.Fatalf("failed to init the eligibility checker: %v", err)
+	}
+	var attachmentSigner *signedurl.Signer
+	if cfg.Vault.Attachment.SigningKey == "" {
+		log.Warn("attachment_credential.signing_key is not set; attachment download links are disabled")
+	} else {
+		attachmentSigner = signedurl.New(cfg.Vault.Attachment.SigningKey)
+	}
 
 	_ = satusehatQueueDB
 	// repo block end
@@ -190,11 +240,19 @@ func main() {
 	// usecase block start
 	institutionUC := institutionUC.NewInstitutionUC(&institutionUC.InstitutionUC{
 		InstitutionRepo: institutionDB,
+		PriceListDB:     priceListDB,
+		JourneyRepo:     journeyDB,
 		Transaction:     transaction,
 	})
 
 	patientUC := patientUC.NewPatientUC(&patientUC.PatientUC{
-		PatientDB:   patientDB,
+		PatientDB:    patientDB,
+		Transaction:  transaction,
+		AttachmentDB: attachmentDB,
+		ConsentDB:    consentDB,
+		BillingDB:    billingDB,
+		Storage:      attachmentStorage,
+		Eligibility:  eligibilityChecker,
 		Idempotency: idempotency.New(inMemoryCaching,
 			idempotency.WithTTL(cfg.IdempotencyConfig.TTLInSeconds),
 			idempotency.WithPoll(
@@ -204,6 +262,13 @@ func main() {
 		),
 	})
 
+	discountUC := discountuc.NewDiscountUC(&discountuc.DiscountUC{
+		DiscountDB:      discountDB,
+		PatientDB:       patientDB,
+		InstitutionRepo: institutionDB,
+		Transaction:     transaction,
+	})
+
 	visitUC := visituc.NewVisitUC(&visituc.VisitUC{
 		PatientDB:       patientDB,
 		InstitutionRepo: institutionDB,
@@ -212,6 +277,10 @@ func main() {
 		AnamnesaDB:      anamnesaDB,
 		DiagnosisDB:     diagnosisDB,
 		ProcedureDB:     procedureDB,
+		PriceListDB:     priceListDB,
+		InstitutionUC:   institutionUC,
+		DiscountUC:      discountUC,
+		PatientUC:       patientUC,
 	})
 
 	// Create session repository
@@ -282,9 +351,59 @@ func main() {
 		InstitutionRepo: institutionDB,
 		PatientDB:       patientDB,
 		PractitionerDB:  practitionerDB,
+		InstitutionUC:   institutionUC,
+		ConsentDB:       consentDB,
+		Transaction:     transaction,
+	})
+
+	purchasingUC := purchasinguc.NewPurchasingUC(&purchasinguc.PurchasingUC{
+		PurchasingDB:    purchasingDB,
+		InstitutionRepo: institutionDB,
+		Transaction:     transaction,
+	})
+
+	stockTakeUC := stocktakeuc.NewStockTakeUC(&stocktakeuc.StockTakeUC{
+		StockTakeDB:     stockTakeDB,
+		InstitutionRepo: institutionDB,
+		Transaction:     transaction,
+	})
+
+	priceListUC := pricelistuc.NewPriceListUC(&pricelistuc.PriceListUC{
+		PriceListDB:     priceListDB,
+		InstitutionRepo: institutionDB,
+		Transaction:     transaction,
+	})
+
+	billingUC := billinguc.NewBillingUC(&billinguc.BillingUC{
+		BillingDB:       billingDB,
+		PatientDB:       patientDB,
+		InstitutionRepo: institutionDB,
+		Transaction:     transaction,
+	})
+
+	doctorFeeUC := doctorfeeuc.NewDoctorFeeUC(&doctorfeeuc.DoctorFeeUC{
+		DoctorFeeDB:     doctorFeeDB,
+		InstitutionRepo: institutionDB,
+		PractitionerDB:  practitionerDB,
 		Transaction:     transaction,
 	})
 
+	attachmentUC := attachmentuc.NewAttachmentUC(&attachmentuc.AttachmentUC{
+		AttachmentDB: attachmentDB,
+		PatientDB:    patientDB,
+		Storage:      attachmentStorage,
+		Signer:       attachmentSigner,
+		BaseURL:      cfg.Server.BaseURL,
+		URLTTL:       time.Duration(cfg.AttachmentConfig.URLTTLInMinutes) * time.Minute,
+	})
+
+	consentUC := consentuc.NewConsentUC(&consentuc.ConsentUC{
+		ConsentDB:   consentDB,
+		PatientDB:   patientDB,
+		ProcedureDB: procedureDB,
+		Transaction: transaction,
+	})
+
 	// usecase block end
 
 	// httphandler block start
@@ -346,6 +465,39 @@ func main() {
 	procedureHandler := procedurehandler.New(&procedurehandler.ProcedureHandler{
 		ProcedureUC: procedureUC,
 	})
+
+	purchasingHandler := purchasinghandler.New(&purchasinghandler.PurchasingHandler{
+		PurchasingUC: purchasingUC,
+	})
+
+	stockTakeHandler := stocktakehandler.New(&stocktakehandler.StockTakeHandler{
+		StockTakeUC: stockTakeUC,
+	})
+
+	priceListHandler := pricelisthandler.New(&pricelisthandler.PriceListHandler{
+		PriceListUC: priceListUC,
+	})
+
+	discountHandler := discounthandler.New(&discounthandler.DiscountHandler{
+		DiscountUC: discountUC,
+	})
+
+	billingHandler := billinghandler.New(&billinghandler.BillingHandler{
+		BillingUC: billingUC,
+	})
+
+	doctorFeeHandler := doctorfeehandler.New(&doctorfeehandler.DoctorFeeHandler{
+		DoctorFeeUC: doctorFeeUC,
+	})
+
+	attachmentHandler := attachmenthandler.New(&attachmenthandler.AttachmentHandler{
+		AttachmentUC: attachmentUC,
+		MaxFileSize:  int64(cfg.AttachmentConfig.MaxFileSizeInMB) << 20,
+	})
+
+	consentHandler := consenthandler.New(&consenthandler.ConsentHandler{
+		ConsentUC: consentUC,
+	})
 	// httphandler block end
 
 	// module block start
@@ -370,6 +522,14 @@ func main() {
 		AnamnesaHandler:     anamnesaHandler,
 		StaffHandler:        staffHandler,
 		ProcedureHandler:    procedureHandler,
+		PurchasingHandler:   purchasingHandler,
+		StockTakeHandler:    stockTakeHandler,
+		PriceListHandler:    priceListHandler,
+		DiscountHandler:     discountHandler,
+		BillingHandler:      billingHandler,
+		DoctorFeeHandler:    doctorFeeHandler,
+		AttachmentHandler:   attachmentHandler,
+		ConsentHandler:      consentHandler,
 		},
 		middlewareModule,
 	)
@@ -380,6 +540,12 @@ func main() {
 	cleanupUC := authCleanup.NewCleanupUC(sessionRepo)
 	go cleanupUC.RunCleanupJob(ctx)
 
+	// Start product statistics rollup job
+	go institutionUC.RunProductStatisticsRollupJob(ctx)
+
+	// Start patient import job
+	go patientUC.RunPatientImportJob(ctx)
+
 	// Handle graceful shutdown
 	shutdownChan := make(chan os.Signal, 1)
 	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM)
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/faisalhardin/medilink/internal/config"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	patientrepo "github.com/faisalhardin/medilink/internal/repo/patient"
+	patientUC "github.com/faisalhardin/medilink/internal/usecase/patient"
+	_ "github.com/lib/pq"
+)
+
+const (
+	repoName = "medilink"
+)
+
+// mrn gives every patient registered before medical record numbers existed its
+// number, in the format each institution has configured.
+func main() {
+	var (
+		institutionID = flag.Int64("institution", 0, "Only number the patients of this institution; 0 numbers every institution")
+		batchSize     = flag.Int("batch", 500, "Patients numbered per transaction")
+		help          = flag.Bool("help", false, "Show help")
+	)
+	flag.Parse()
+
+	if *help {
+		flag.Usage()
+		return
+	}
+
+	// Initialize config
+	cfg, err := config.New(repoName)
+	if err != nil {
+		log.Fatalf("Failed to init config: %v", err)
+	}
+
+	vault, err := config.NewVault()
+	if err != nil {
+		log.Fatalf("Failed to init vault: %v", err)
+	}
+	cfg.Vault = vault.Data
+
+	// Initialize database
+	db, err := xormlib.NewDBConnection(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init db: %v", err)
+	}
+	defer db.CloseDBConnection()
+
+	patientUC := patientUC.NewPatientUC(&patientUC.PatientUC{
+		PatientDB:   patientrepo.NewPatientDB(&patientrepo.Conn{DB: db}),
+		Transaction: xormlib.NewTransaction(db),
+	})
+
+	log.Println("Numbering patients without a medical record number...")
+	numbered, err := patientUC.BackfillMedicalRecordNumbers(context.Background(), *institutionID, *batchSize)
+	if err != nil {
+		log.Fatalf("Backfill failed after numbering %d patients: %v", numbered, err)
+	}
+	log.Printf("Backfill completed: %d patients numbered", numbered)
+}
@@ -19,6 +19,8 @@ type Config struct {
 	WebConfig          WebConfig          `yaml:"web_config"`
 	SatuSehatConfig    SatuSehatConfig    `yaml:"satusehat_config"`
 	IdempotencyConfig  IdempotencyConfig  `yaml:"idempotency_config"`
+	AttachmentConfig   AttachmentConfig   `yaml:"attachment_config"`
+	EligibilityConfig  EligibilityConfig  `yaml:"eligibility_config"`
 }
 
 type WebConfig struct {
@@ -56,12 +58,13 @@ type VaultData struct {
 }
 
 type Vault struct {
-	GoogleAuth    GoogleAuth       `json:"google_auth"`
-	DBMaster      DBConfig         `json:"db_master"`
-	DBSlave       DBConfig         `json:"db_slave"`
-	JWTCredential JWTCredential    `json:"jwt_credential"`
-	Redis         RedisCredentials `json:"redis_credentials"`
-	SatuSehatAuth SatuSehatAuth    `json:"satusehat_auth"`
+	GoogleAuth    GoogleAuth           `json:"google_auth"`
+	DBMaster      DBConfig             `json:"db_master"`
+	DBSlave       DBConfig             `json:"db_slave"`
+	JWTCredential JWTCredential        `json:"jwt_credential"`
+	Redis         RedisCredentials     `json:"redis_credentials"`
+	SatuSehatAuth SatuSehatAuth        `json:"satusehat_auth"`
+	Attachment    AttachmentCredential `json:"attachment_credential"`
 }
 
 type RedisCredentials struct {
@@ -114,6 +117,21 @@ type IdempotencyConfig struct {
 	PollMaxInMs  int `yaml:"poll_max_in_ms"`
 }
 
+type AttachmentConfig struct {
+	Storage         string `yaml:"storage"`            // storage driver, "local" by default
+	LocalPath       string `yaml:"local_path"`         // directory of the local driver
+	MaxFileSizeInMB int    `yaml:"max_file_size_in_mb"`
+	URLTTLInMinutes int    `yaml:"url_ttl_in_minutes"` // how long download links stay valid
+}
+
+type EligibilityConfig struct {
+	Driver string `yaml:"driver"` // eligibility checker, "stub" by default
+}
+
+type AttachmentCredential struct {
+	SigningKey string `json:"signing_key"` // signs attachment download links
+}
+
 type SatuSehatAuth struct {
 	ClientID     string `json:"client_id"`     // Client ID from Kementerian Kesehatan
 	ClientSecret string `json:"client_secret"` // Client Secret from Kementerian Kesehatan
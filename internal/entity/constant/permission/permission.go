@@ -39,6 +39,36 @@ const (
 	ProductStatistics = "product.statistics"
 )
 
+// Purchasing permissions
+const (
+	PurchasingRead    = "purchasing.read"
+	PurchasingCreate  = "purchasing.create"
+	PurchasingUpdate  = "purchasing.update"
+	PurchasingReceive = "purchasing.receive"
+)
+
+// Stock-take permissions
+const (
+	StockTakeRead     = "stock_take.read"
+	StockTakeCreate   = "stock_take.create"
+	StockTakeCount    = "stock_take.count"
+	StockTakeFinalize = "stock_take.finalize"
+)
+
+// Tax configuration permissions
+const (
+	TaxConfigRead   = "tax_config.read"
+	TaxConfigUpdate = "tax_config.update"
+)
+
+// Discount permissions
+const (
+	DiscountPolicyRead   = "discount.policy.read"
+	DiscountPolicyUpdate = "discount.policy.update"
+	DiscountApprove      = "discount.approve"
+	DiscountAuditRead    = "discount.audit.read"
+)
+
 // Journey permissions
 const (
 	JourneyRead   = "journey.read"
@@ -76,3 +106,68 @@ const (
 	StaffDelete     = "staff.delete"
 	StaffRoleAssign = "staff.role.assign"
 )
+
+// Billing permissions
+const (
+	PaymentCreate = "payment.create"
+	RefundCreate  = "refund.create"
+)
+
+// Patient account permissions
+const (
+	PatientAccountRead    = "patient_account.read"
+	PatientAccountDeposit = "patient_account.deposit"
+)
+
+// Doctor fee permissions
+const (
+	DoctorFeeRead   = "doctor_fee.read"
+	DoctorFeeUpdate = "doctor_fee.update"
+)
+
+// Stock location permissions
+const (
+	StockLocationRead     = "stock_location.read"
+	StockLocationUpdate   = "stock_location.update"
+	StockLocationTransfer = "stock_location.transfer"
+)
+
+// Patient merge permissions
+const (
+	PatientMerge = "patient.merge"
+)
+
+// Patient import permissions
+const (
+	PatientImport = "patient.import"
+)
+
+// Patient personal data permissions
+const (
+	PatientDataExport = "patient.data_export"
+	PatientAnonymise  = "patient.anonymise"
+)
+
+// Patient payer permissions
+const (
+	PatientPayerUpdate = "patient_payer.update"
+)
+
+// Medical record number configuration permissions
+const (
+	MRNConfigRead   = "mrn_config.read"
+	MRNConfigUpdate = "mrn_config.update"
+)
+
+// Patient attachment permissions
+const (
+	AttachmentRead   = "attachment.read"
+	AttachmentCreate = "attachment.create"
+	AttachmentDelete = "attachment.delete"
+)
+
+// Informed consent permissions
+const (
+	ConsentTemplateUpdate = "consent_template.update"
+	ConsentCreate         = "consent.create"
+)
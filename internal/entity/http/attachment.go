@@ -0,0 +1,11 @@
+package http
+
+import "net/http"
+
+type AttachmentHandler interface {
+	UploadAttachment(w http.ResponseWriter, r *http.Request)
+	ListAttachments(w http.ResponseWriter, r *http.Request)
+	GetAttachment(w http.ResponseWriter, r *http.Request)
+	DeleteAttachment(w http.ResponseWriter, r *http.Request)
+	DownloadAttachment(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,16 @@
+package http
+
+import "net/http"
+
+type BillingHandler interface {
+	GetVisitBilling(w http.ResponseWriter, r *http.Request)
+	RecordPayment(w http.ResponseWriter, r *http.Request)
+	CreateRefund(w http.ResponseWriter, r *http.Request)
+	ListRefunds(w http.ResponseWriter, r *http.Request)
+	ApplyDeposit(w http.ResponseWriter, r *http.Request)
+	GetPatientAccount(w http.ResponseWriter, r *http.Request)
+	RecordDeposit(w http.ResponseWriter, r *http.Request)
+	RefundPatientCredit(w http.ResponseWriter, r *http.Request)
+	GetPatientStatement(w http.ResponseWriter, r *http.Request)
+	DownloadPatientStatement(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,13 @@
+package http
+
+import "net/http"
+
+type ConsentHandler interface {
+	ListTemplates(w http.ResponseWriter, r *http.Request)
+	SaveTemplate(w http.ResponseWriter, r *http.Request)
+	RetireTemplate(w http.ResponseWriter, r *http.Request)
+	PreviewConsent(w http.ResponseWriter, r *http.Request)
+	CreateConsent(w http.ResponseWriter, r *http.Request)
+	ListVisitConsents(w http.ResponseWriter, r *http.Request)
+	GetSignature(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,13 @@
+package http
+
+import "net/http"
+
+type DiscountHandler interface {
+	ListPolicies(w http.ResponseWriter, r *http.Request)
+	SavePolicies(w http.ResponseWriter, r *http.Request)
+	RequestApproval(w http.ResponseWriter, r *http.Request)
+	ListApprovals(w http.ResponseWriter, r *http.Request)
+	ApproveRequest(w http.ResponseWriter, r *http.Request)
+	RejectRequest(w http.ResponseWriter, r *http.Request)
+	ListPriceAudit(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,10 @@
+package http
+
+import "net/http"
+
+type DoctorFeeHandler interface {
+	ListRules(w http.ResponseWriter, r *http.Request)
+	SaveRules(w http.ResponseWriter, r *http.Request)
+	GetReport(w http.ResponseWriter, r *http.Request)
+	DownloadReport(w http.ResponseWriter, r *http.Request)
+}
@@ -6,10 +6,30 @@ type InstitutionHandler interface {
 	InsertNewInstitution(w http.ResponseWriter, r *http.Request)
 	FindInstitutions(w http.ResponseWriter, r *http.Request)
 	GetUserInstitution(w http.ResponseWriter, r *http.Request)
+	GetTaxConfig(w http.ResponseWriter, r *http.Request)
+	UpdateTaxConfig(w http.ResponseWriter, r *http.Request)
+	GetMRNConfig(w http.ResponseWriter, r *http.Request)
+	UpdateMRNConfig(w http.ResponseWriter, r *http.Request)
 
 	FindInstitutionProducts(w http.ResponseWriter, r *http.Request)
 	InsertInstitutionProduct(w http.ResponseWriter, r *http.Request)
 	UpdateInstitutionProduct(w http.ResponseWriter, r *http.Request)
 	UpdateInstitutionProductStock(w http.ResponseWriter, r *http.Request)
+	GetProductUnits(w http.ResponseWriter, r *http.Request)
+	SaveProductUnits(w http.ResponseWriter, r *http.Request)
+	GetTreatmentMaterials(w http.ResponseWriter, r *http.Request)
+	SaveTreatmentMaterials(w http.ResponseWriter, r *http.Request)
 	GetProductStatistics(w http.ResponseWriter, r *http.Request)
+	ImportProducts(w http.ResponseWriter, r *http.Request)
+	ExportProducts(w http.ResponseWriter, r *http.Request)
+
+	ListStockLocations(w http.ResponseWriter, r *http.Request)
+	CreateStockLocation(w http.ResponseWriter, r *http.Request)
+	UpdateStockLocation(w http.ResponseWriter, r *http.Request)
+	ListLocationStock(w http.ResponseWriter, r *http.Request)
+	GetLowStockReport(w http.ResponseWriter, r *http.Request)
+	SaveReorderLevels(w http.ResponseWriter, r *http.Request)
+	CreateStockTransfer(w http.ResponseWriter, r *http.Request)
+	ListStockTransfers(w http.ResponseWriter, r *http.Request)
+	GetStockTransfer(w http.ResponseWriter, r *http.Request)
 }
@@ -14,4 +14,12 @@ type Handlers struct {
 	AnamnesaHandler     AnamnesaHandler
 	StaffHandler        StaffHandler
 	ProcedureHandler    ProcedureHandler
+	PurchasingHandler   PurchasingHandler
+	StockTakeHandler    StockTakeHandler
+	PriceListHandler    PriceListHandler
+	DiscountHandler     DiscountHandler
+	BillingHandler      BillingHandler
+	DoctorFeeHandler    DoctorFeeHandler
+	AttachmentHandler   AttachmentHandler
+	ConsentHandler      ConsentHandler
 }
@@ -7,6 +7,24 @@ type PatientHandler interface {
 	ListPatient(w http.ResponseWriter, r *http.Request)
 	GetPatient(w http.ResponseWriter, r *http.Request)
 	UpdatePatient(w http.ResponseWriter, r *http.Request)
+	ListPatientDuplicates(w http.ResponseWriter, r *http.Request)
+	MergePatients(w http.ResponseWriter, r *http.Request)
+	ListPatientMerges(w http.ResponseWriter, r *http.Request)
+	UndoPatientMerge(w http.ResponseWriter, r *http.Request)
+	GetPatientTimeline(w http.ResponseWriter, r *http.Request)
+	ListPatientRelations(w http.ResponseWriter, r *http.Request)
+	SavePatientRelation(w http.ResponseWriter, r *http.Request)
+	DeletePatientRelation(w http.ResponseWriter, r *http.Request)
+	ImportPatients(w http.ResponseWriter, r *http.Request)
+	ListPatientImports(w http.ResponseWriter, r *http.Request)
+	GetPatientImport(w http.ResponseWriter, r *http.Request)
+	DownloadPatientImportReport(w http.ResponseWriter, r *http.Request)
+	ExportPatientData(w http.ResponseWriter, r *http.Request)
+	AnonymisePatient(w http.ResponseWriter, r *http.Request)
+	ListPatientPayers(w http.ResponseWriter, r *http.Request)
+	SavePatientPayer(w http.ResponseWriter, r *http.Request)
+	DeletePatientPayer(w http.ResponseWriter, r *http.Request)
+	CheckPayerEligibility(w http.ResponseWriter, r *http.Request)
 	InsertNewVisit(w http.ResponseWriter, r *http.Request)
 	ListVisitTouchpoints(w http.ResponseWriter, r *http.Request)
 	GetPatientVisits(w http.ResponseWriter, r *http.Request)
@@ -21,4 +39,6 @@ type PatientHandler interface {
 	InsertVisitProduct(w http.ResponseWriter, r *http.Request)
 	UpdateVisitProduct(w http.ResponseWriter, r *http.Request)
 	ListVisitProducts(w http.ResponseWriter, r *http.Request)
+	GetVisitInvoice(w http.ResponseWriter, r *http.Request)
+	SelectVisitPayer(w http.ResponseWriter, r *http.Request)
 }
@@ -0,0 +1,12 @@
+package http
+
+import "net/http"
+
+type PriceListHandler interface {
+	CreatePriceList(w http.ResponseWriter, r *http.Request)
+	ListPriceLists(w http.ResponseWriter, r *http.Request)
+	GetPriceList(w http.ResponseWriter, r *http.Request)
+	UpdatePriceListItems(w http.ResponseWriter, r *http.Request)
+	DeletePriceList(w http.ResponseWriter, r *http.Request)
+	GetProductPriceHistory(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,17 @@
+package http
+
+import "net/http"
+
+type PurchasingHandler interface {
+	CreateSupplier(w http.ResponseWriter, r *http.Request)
+	UpdateSupplier(w http.ResponseWriter, r *http.Request)
+	ListSuppliers(w http.ResponseWriter, r *http.Request)
+
+	CreatePurchaseOrder(w http.ResponseWriter, r *http.Request)
+	UpdatePurchaseOrder(w http.ResponseWriter, r *http.Request)
+	GetPurchaseOrder(w http.ResponseWriter, r *http.Request)
+	ListPurchaseOrders(w http.ResponseWriter, r *http.Request)
+	OrderPurchaseOrder(w http.ResponseWriter, r *http.Request)
+	CancelPurchaseOrder(w http.ResponseWriter, r *http.Request)
+	ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request)
+}
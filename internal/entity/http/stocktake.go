@@ -0,0 +1,13 @@
+package http
+
+import "net/http"
+
+type StockTakeHandler interface {
+	CreateStockTake(w http.ResponseWriter, r *http.Request)
+	ListStockTakes(w http.ResponseWriter, r *http.Request)
+	GetStockTake(w http.ResponseWriter, r *http.Request)
+	SaveCounts(w http.ResponseWriter, r *http.Request)
+	FinalizeStockTake(w http.ResponseWriter, r *http.Request)
+	CancelStockTake(w http.ResponseWriter, r *http.Request)
+	DownloadVarianceReport(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,223 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	TrxVisitPaymentTableName    = "mdl_trx_visit_payment"
+	TrxVisitRefundTableName     = "mdl_trx_visit_refund"
+	DtlVisitRefundLineTableName = "mdl_dtl_visit_refund_line"
+)
+
+// Payment methods accepted for visit payments and refunds.
+const (
+	PaymentMethodCash         = "cash"
+	PaymentMethodDebitCard    = "debit_card"
+	PaymentMethodCreditCard   = "credit_card"
+	PaymentMethodBankTransfer = "bank_transfer"
+	PaymentMethodQRIS         = "qris"
+	PaymentMethodEWallet      = "e_wallet"
+)
+
+func IsValidPaymentMethod(method string) bool {
+	switch method {
+	case PaymentMethodCash, PaymentMethodDebitCard, PaymentMethodCreditCard,
+		PaymentMethodBankTransfer, PaymentMethodQRIS, PaymentMethodEWallet:
+		return true
+	}
+	return false
+}
+
+// Refund document types. A refund pays the patient back; a credit note keeps
+// the amount as credit for the patient.
+const (
+	RefundDocumentTypeRefund     = "refund"
+	RefundDocumentTypeCreditNote = "credit_note"
+)
+
+// Stock movement type and reference of items restocked by a refund document.
+const (
+	StockMovementTypeRefundRestock    = "refund_restock"
+	StockMovementReferenceVisitRefund = "visit_refund"
+)
+
+type TrxVisitPayment struct {
+	ID                int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution  int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxPatientVisit int64       `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	PaymentMethod     string      `xorm:"'payment_method'" json:"payment_method"`
+	Amount            money.Money `xorm:"'amount'" json:"amount"`
+	Reference         string      `xorm:"'reference'" json:"reference"`
+	ReceivedBy        string      `xorm:"'received_by'" json:"received_by"`
+	CreateTime        time.Time   `xorm:"'create_time' created" json:"create_time"`
+}
+
+type RecordVisitPaymentRequest struct {
+	PaymentMethod string      `json:"payment_method" validate:"required"`
+	Amount        money.Money `json:"amount"`
+	Reference     string      `json:"reference"`
+}
+
+type TrxVisitRefund struct {
+	ID                int64                `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution  int64                `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxPatientVisit int64                `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	DocumentType      string               `xorm:"'document_type'" json:"document_type"`
+	RefundMethod      string               `xorm:"'refund_method'" json:"refund_method,omitempty"`
+	Reason            string               `xorm:"'reason'" json:"reason"`
+	Restock           bool                 `xorm:"'restock'" json:"restock"`
+	TotalAmount       money.Money          `xorm:"'total_amount'" json:"total_amount"`
+	TaxAmount         money.Money          `xorm:"'tax_amount'" json:"tax_amount"`
+	ServiceCharge     money.Money          `xorm:"'service_charge'" json:"service_charge"`
+	CreatedBy         string               `xorm:"'created_by'" json:"created_by"`
+	CreateTime        time.Time            `xorm:"'create_time' created" json:"create_time"`
+	Lines             []DtlVisitRefundLine `xorm:"-" json:"lines"`
+}
+
+// DtlVisitRefundLine returns Quantity units of one visit line, in the unit the
+// line was billed in.
+type DtlVisitRefundLine struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxVisitRefund        int64       `xorm:"'id_trx_visit_refund'" json:"-"`
+	IDMstInstitution        int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxPatientVisit       int64       `xorm:"'id_trx_patient_visit'" json:"-"`
+	IDTrxVisitProduct       int64       `xorm:"'id_trx_visit_product'" json:"visit_product_id"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	Name                    string      `xorm:"'name'" json:"name"`
+	Quantity                int         `xorm:"'quantity'" json:"quantity"`
+	UnitType                string      `xorm:"'unit_type'" json:"unit_type"`
+	ConversionFactor        int64       `xorm:"'conversion_factor'" json:"conversion_factor"`
+	UnitCost                money.Money `xorm:"'unit_cost'" json:"-"`
+	Revenue                 money.Money `xorm:"'revenue'" json:"revenue"`
+	TaxAmount               money.Money `xorm:"'tax_amount'" json:"tax_amount"`
+	ServiceCharge           money.Money `xorm:"'service_charge'" json:"service_charge"`
+	Amount                  money.Money `xorm:"'amount'" json:"amount"`
+	Restocked               bool        `xorm:"'restocked'" json:"restocked"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"create_time"`
+}
+
+// BaseQuantity is the returned quantity in the product's base (stock) unit.
+func (l DtlVisitRefundLine) BaseQuantity() int64 {
+	factor := l.ConversionFactor
+	if factor <= 0 {
+		factor = 1
+	}
+	return int64(l.Quantity) * factor
+}
+
+type CreateVisitRefundRequest struct {
+	DocumentType string                   `json:"document_type" validate:"required,oneof=refund credit_note"`
+	RefundMethod string                   `json:"refund_method"`
+	Reason       string                   `json:"reason" validate:"required"`
+	Restock      bool                     `json:"restock"`
+	Lines        []VisitRefundLineRequest `json:"lines" validate:"required,min=1,dive"`
+}
+
+type VisitRefundLineRequest struct {
+	IDTrxVisitProduct int64 `json:"visit_product_id" validate:"required"`
+	Quantity          int   `json:"quantity" validate:"gt=0"`
+}
+
+// RefundedLineTotals sums what earlier documents returned of one visit line.
+type RefundedLineTotals struct {
+	IDTrxVisitProduct int64       `xorm:"'id_trx_visit_product'"`
+	Quantity          int         `xorm:"'quantity'"`
+	Revenue           money.Money `xorm:"'revenue'"`
+	TaxAmount         money.Money `xorm:"'tax_amount'"`
+	ServiceCharge     money.Money `xorm:"'service_charge'"`
+	Amount            money.Money `xorm:"'amount'"`
+}
+
+// NewRefundLine takes the share of quantity units of line. The last units of a
+// line take whatever earlier documents left of it, so a fully returned line
+// adds up to the line exactly.
+func NewRefundLine(line TrxVisitProduct, refunded RefundedLineTotals, quantity int) DtlVisitRefundLine {
+	refundLine := DtlVisitRefundLine{
+		IDMstInstitution:        line.IDMstInstitution,
+		IDTrxPatientVisit:       line.IDTrxPatientVisit,
+		IDTrxVisitProduct:       line.ID,
+		IDTrxInstitutionProduct: line.IDTrxInstitutionProduct,
+		Name:                    line.Name,
+		Quantity:                quantity,
+		UnitType:                line.UnitType,
+		ConversionFactor:        line.ConversionFactor,
+		UnitCost:                line.UnitCost,
+	}
+
+	if refunded.Quantity+quantity >= line.Quantity {
+		refundLine.Revenue = line.TotalPrice.Sub(refunded.Revenue)
+		refundLine.TaxAmount = line.TaxAmount.Sub(refunded.TaxAmount)
+		refundLine.ServiceCharge = line.ServiceCharge.Sub(refunded.ServiceCharge)
+		refundLine.Amount = line.GrandTotal().Sub(refunded.Amount)
+		return refundLine
+	}
+
+	share := func(m money.Money) money.Money {
+		return m.MulInt(int64(quantity)).Div(int64(line.Quantity)).Round()
+	}
+	refundLine.Revenue = share(line.TotalPrice)
+	refundLine.TaxAmount = share(line.TaxAmount)
+	refundLine.ServiceCharge = share(line.ServiceCharge)
+	refundLine.Amount = share(line.GrandTotal())
+	return refundLine
+}
+
+// VisitPaymentSummary compares what was paid and returned with the invoice.
+// The patient owes PatientShare; PayerShare is billed to the visit's payer.
+type VisitPaymentSummary struct {
+	InvoiceTotal money.Money `json:"invoice_total"`
+	PayerShare   money.Money `json:"payer_share"`
+	PatientShare money.Money `json:"patient_share"`
+	TotalPaid    money.Money `json:"total_paid"`
+	Outstanding  money.Money `json:"outstanding"`
+	TotalRefund  money.Money `json:"total_refund"`
+	TotalCredit  money.Money `json:"total_credit"`
+	IsPaid       bool        `json:"is_paid"`
+}
+
+// NewVisitPaymentSummary adds up payments and refund documents of a visit. A
+// visit with a positive invoice is paid once its payments cover the invoice.
+func NewVisitPaymentSummary(totals VisitTotals, payments []TrxVisitPayment, refunds []TrxVisitRefund) VisitPaymentSummary {
+	summary := VisitPaymentSummary{
+		InvoiceTotal: totals.GrandTotal,
+		TotalPaid:    money.Zero,
+		TotalRefund:  money.Zero,
+		TotalCredit:  money.Zero,
+	}
+	for _, payment := range payments {
+		summary.TotalPaid = summary.TotalPaid.Add(payment.Amount)
+	}
+	for _, refund := range refunds {
+		if refund.DocumentType == RefundDocumentTypeCreditNote {
+			summary.TotalCredit = summary.TotalCredit.Add(refund.TotalAmount)
+			continue
+		}
+		summary.TotalRefund = summary.TotalRefund.Add(refund.TotalAmount)
+	}
+	return summary.WithInvoiceSplit(VisitInvoiceSplit{
+		PayerShare:   money.Zero,
+		PatientShare: summary.InvoiceTotal,
+	})
+}
+
+// WithInvoiceSplit leaves the patient owing only their share of the invoice. A
+// visit with a positive invoice is paid once the patient's payments cover
+// their share, which for a visit fully covered by its payer is at once.
+func (s VisitPaymentSummary) WithInvoiceSplit(split VisitInvoiceSplit) VisitPaymentSummary {
+	s.PayerShare = split.PayerShare
+	s.PatientShare = split.PatientShare
+	s.Outstanding = money.Max(s.PatientShare.Sub(s.TotalPaid), money.Zero)
+	s.IsPaid = s.InvoiceTotal.IsPositive() && !s.Outstanding.IsPositive()
+	return s
+}
+
+// VisitBillingResponse is the payment state of a visit with its documents.
+type VisitBillingResponse struct {
+	IDTrxPatientVisit int64               `json:"visit_id"`
+	Summary           VisitPaymentSummary `json:"summary"`
+	Payments          []TrxVisitPayment   `json:"payments"`
+	Refunds           []TrxVisitRefund    `json:"refunds"`
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestNewRefundLine(t *testing.T) {
+	t.Parallel()
+
+	line := TrxVisitProduct{
+		ID:               1,
+		Quantity:         3,
+		ConversionFactor: 10,
+		TotalPrice:       money.New(10000),
+		TaxBase:          money.New(10000),
+		TaxAmount:        money.New(1100),
+		ServiceCharge:    money.New(500),
+	}
+
+	first := NewRefundLine(line, RefundedLineTotals{}, 1)
+	if first.Revenue.String() != "3333.33" || first.TaxAmount.String() != "366.67" || first.Amount.String() != "3866.67" {
+		t.Fatalf("unexpected share of one unit: revenue %s tax %s amount %s", first.Revenue, first.TaxAmount, first.Amount)
+	}
+	if first.BaseQuantity() != 10 {
+		t.Fatalf("expected base quantity 10, got %d", first.BaseQuantity())
+	}
+
+	refunded := RefundedLineTotals{
+		IDTrxVisitProduct: line.ID,
+		Quantity:          first.Quantity,
+		Revenue:           first.Revenue,
+		TaxAmount:         first.TaxAmount,
+		ServiceCharge:     first.ServiceCharge,
+		Amount:            first.Amount,
+	}
+	rest := NewRefundLine(line, refunded, 2)
+	if total := first.Revenue.Add(rest.Revenue); !total.Equal(line.TotalPrice) {
+		t.Fatalf("expected revenue shares to add up to %s, got %s", line.TotalPrice, total)
+	}
+	if total := first.Amount.Add(rest.Amount); !total.Equal(line.GrandTotal()) {
+		t.Fatalf("expected amount shares to add up to %s, got %s", line.GrandTotal(), total)
+	}
+}
+
+func TestNewVisitPaymentSummary(t *testing.T) {
+	t.Parallel()
+
+	totals := VisitTotals{GrandTotal: money.New(150000)}
+
+	partial := NewVisitPaymentSummary(totals, []TrxVisitPayment{{Amount: money.New(100000)}}, nil)
+	if partial.IsPaid || partial.Outstanding.String() != "50000" {
+		t.Fatalf("expected 50000 outstanding, got %s paid %v", partial.Outstanding, partial.IsPaid)
+	}
+
+	paid := NewVisitPaymentSummary(totals,
+		[]TrxVisitPayment{{Amount: money.New(100000)}, {Amount: money.New(50000)}},
+		[]TrxVisitRefund{
+			{DocumentType: RefundDocumentTypeRefund, TotalAmount: money.New(20000)},
+			{DocumentType: RefundDocumentTypeCreditNote, TotalAmount: money.New(5000)},
+		})
+	if !paid.IsPaid || !paid.Outstanding.IsZero() {
+		t.Fatalf("expected visit to be paid, outstanding %s", paid.Outstanding)
+	}
+	if paid.TotalRefund.String() != "20000" || paid.TotalCredit.String() != "5000" {
+		t.Fatalf("unexpected refund %s credit %s", paid.TotalRefund, paid.TotalCredit)
+	}
+
+	if NewVisitPaymentSummary(VisitTotals{GrandTotal: money.Zero}, nil, nil).IsPaid {
+		t.Fatalf("expected an empty invoice not to count as paid")
+	}
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	MstConsentTemplateTableName = "mdl_mst_consent_template"
+	TrxConsentTableName         = "mdl_trx_consent"
+
+	ConsentSignerPatient  = "patient"
+	ConsentSignerGuardian = "guardian"
+
+	// ConsentSignatureMaxSize bounds a decoded signature image.
+	ConsentSignatureMaxSize = 512 << 10
+)
+
+// ProcedureCategoryDisplay names the SNOMED CT procedure categories a
+// procedure can be filed under.
+var ProcedureCategoryDisplay = map[string]string{
+	"103693007": "Diagnostic procedure",
+	"24642003":  "Psychiatry procedure or service",
+	"277132007": "Therapeutic procedure",
+	"387713003": "Surgical procedure",
+	"409063005": "Counselling",
+	"409073007": "Education",
+	"410606002": "Social service procedure",
+	"46947000":  "Chiropractic manipulation",
+}
+
+// Placeholders a consent template body can use; they are replaced when the
+// consent is rendered for a visit.
+const (
+	ConsentPlaceholderPatientName         = "{{patient_name}}"
+	ConsentPlaceholderMedicalRecordNumber = "{{medical_record_number}}"
+	ConsentPlaceholderNIK                 = "{{nik}}"
+	ConsentPlaceholderDateOfBirth         = "{{date_of_birth}}"
+	ConsentPlaceholderProcedureCategory   = "{{procedure_category}}"
+	ConsentPlaceholderProcedureName       = "{{procedure_name}}"
+	ConsentPlaceholderDoctorName          = "{{doctor_name}}"
+	ConsentPlaceholderSignerName          = "{{signer_name}}"
+	ConsentPlaceholderDate                = "{{date}}"
+)
+
+// ConsentDateLayout is how dates are written in the rendered consent text.
+const ConsentDateLayout = "02-01-2006"
+
+// MstConsentTemplate is the consent text of an institution for a procedure
+// category. Saving a template retires the active version and adds the next
+// one, so signed consents keep pointing at the text they were rendered from.
+// Procedures of a category whose template IsRequired cannot be saved without
+// a signed consent.
+type MstConsentTemplate struct {
+	ID                int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution  int64      `xorm:"'id_mst_institution'" json:"-"`
+	ProcedureCategory string     `xorm:"'procedure_category'" json:"procedure_category"`
+	Title             string     `xorm:"'title'" json:"title"`
+	Body              string     `xorm:"'body'" json:"body"`
+	IsRequired        bool       `xorm:"'is_required'" json:"is_required"`
+	Version           int        `xorm:"'version'" json:"version"`
+	UpdatedBy         string     `xorm:"'updated_by'" json:"updated_by"`
+	CreateTime        time.Time  `xorm:"'create_time' created" json:"create_time"`
+	DeleteTime        *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+func (MstConsentTemplate) TableName() string {
+	return MstConsentTemplateTableName
+}
+
+// TrxConsent is a signed informed consent for a visit, and for one of its
+// procedures when ProcedureID is set; otherwise it covers the visit's
+// procedures of its category. ContentHash fingerprints the rendered text and
+// the signature so later changes to either can be detected.
+type TrxConsent struct {
+	ID                   int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution     int64       `xorm:"'id_mst_institution'" json:"-"`
+	VisitID              int64       `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	ProcedureID          null.Int64  `xorm:"'id_trx_visit_procedure'" json:"procedure_id"`
+	TemplateID           int64       `xorm:"'id_mst_consent_template'" json:"template_id"`
+	TemplateVersion      int         `xorm:"'template_version'" json:"template_version"`
+	ProcedureCategory    string      `xorm:"'procedure_category'" json:"procedure_category"`
+	Title                string      `xorm:"'title'" json:"title"`
+	RenderedText         string      `xorm:"'rendered_text'" json:"rendered_text"`
+	SignerType           string      `xorm:"'signer_type'" json:"signer_type"`
+	SignerName           string      `xorm:"'signer_name'" json:"signer_name"`
+	SignerRelationship   null.String `xorm:"'signer_relationship'" json:"signer_relationship"`
+	SignatureImage       []byte      `xorm:"'signature_image'" json:"-"`
+	SignatureContentType string      `xorm:"'signature_content_type'" json:"signature_content_type"`
+	WitnessName          string      `xorm:"'witness_name'" json:"witness_name"`
+	ContentHash          string      `xorm:"'content_hash'" json:"content_hash"`
+	SignedAt             time.Time   `xorm:"'signed_at'" json:"signed_at"`
+	RecordedBy           string      `xorm:"'recorded_by'" json:"recorded_by"`
+	CreateTime           time.Time   `xorm:"'create_time' created" json:"-"`
+}
+
+func (TrxConsent) TableName() string {
+	return TrxConsentTableName
+}
+
+// Covers reports whether the consent allows a procedure of category, with
+// procedureID 0 for one not saved yet.
+func (c TrxConsent) Covers(category string, procedureID int64) bool {
+	if c.ProcedureCategory != category {
+		return false
+	}
+	return !c.ProcedureID.Valid || c.ProcedureID.Int64 == procedureID
+}
+
+// ConsentContentHash is the SHA-256 of the rendered text followed by the
+// signature image.
+func ConsentContentHash(renderedText string, signature []byte) string {
+	h := sha256.New()
+	h.Write([]byte(renderedText))
+	h.Write(signature)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RenderConsent fills the placeholders of body from values, keyed by
+// placeholder. Placeholders without a value are left as written.
+func RenderConsent(body string, values map[string]string) string {
+	pairs := make([]string, 0, len(values)*2)
+	for placeholder, value := range values {
+		pairs = append(pairs, placeholder, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(body)
+}
+
+type SaveConsentTemplateRequest struct {
+	ProcedureCategory string `json:"procedure_category" validate:"required,oneof=103693007 24642003 277132007 387713003 409063005 409073007 410606002 46947000"`
+	Title             string `json:"title" validate:"required,max=200"`
+	Body              string `json:"body" validate:"required,max=20000"`
+	IsRequired        bool   `json:"is_required"`
+}
+
+// ConsentPreviewParams pick the template to render for a visit before it is
+// signed.
+type ConsentPreviewParams struct {
+	VisitID     int64  `schema:"-"`
+	TemplateID  int64  `schema:"template_id" validate:"required"`
+	ProcedureID int64  `schema:"procedure_id"`
+	SignerName  string `schema:"signer_name"`
+}
+
+type ConsentPreview struct {
+	TemplateID        int64  `json:"template_id"`
+	TemplateVersion   int    `json:"template_version"`
+	ProcedureCategory string `json:"procedure_category"`
+	Title             string `json:"title"`
+	RenderedText      string `json:"rendered_text"`
+}
+
+// CreateConsentRequest records a signed consent. SignatureImage is a base64
+// PNG or JPEG, optionally as a data URL.
+type CreateConsentRequest struct {
+	VisitID            int64      `json:"-"`
+	TemplateID         int64      `json:"template_id" validate:"required"`
+	ProcedureID        null.Int64 `json:"procedure_id"`
+	SignerType         string     `json:"signer_type" validate:"required,oneof=patient guardian"`
+	SignerName         string     `json:"signer_name" validate:"required,max=255"`
+	SignerRelationship string     `json:"signer_relationship" validate:"required_if=SignerType guardian,max=50"`
+	SignatureImage     string     `json:"signature_image" validate:"required"`
+	WitnessName        string     `json:"witness_name" validate:"required,max=255"`
+}
+
+// ConsentSignature is the signature image of a consent.
+type ConsentSignature struct {
+	ContentType string `xorm:"'signature_content_type'"`
+	Image       []byte `xorm:"'signature_image'"`
+}
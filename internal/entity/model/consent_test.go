@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/volatiletech/null/v8"
+)
+
+func TestRenderConsent(t *testing.T) {
+	t.Parallel()
+
+	body := "Saya, {{signer_name}}, menyetujui {{procedure_name}} untuk {{patient_name}} ({{medical_record_number}}) oleh {{doctor_name}}. {{unknown}}"
+	got := RenderConsent(body, map[string]string{
+		ConsentPlaceholderSignerName:          "Siti",
+		ConsentPlaceholderProcedureName:       "Pencabutan gigi",
+		ConsentPlaceholderPatientName:         "Budi {{doctor_name}}",
+		ConsentPlaceholderMedicalRecordNumber: "RM-2026-000001",
+		ConsentPlaceholderDoctorName:          "drg. Ani",
+	})
+	want := "Saya, Siti, menyetujui Pencabutan gigi untuk Budi {{doctor_name}} (RM-2026-000001) oleh drg. Ani. {{unknown}}"
+	if got != want {
+		t.Fatalf("RenderConsent() = %q, want %q", got, want)
+	}
+}
+
+func TestTrxConsentCovers(t *testing.T) {
+	t.Parallel()
+
+	visitWide := TrxConsent{ProcedureCategory: "387713003"}
+	forProcedure := TrxConsent{ProcedureCategory: "387713003", ProcedureID: null.Int64From(7)}
+
+	tests := []struct {
+		name        string
+		consent     TrxConsent
+		category    string
+		procedureID int64
+		want        bool
+	}{
+		{"visit-wide covers a new procedure", visitWide, "387713003", 0, true},
+		{"visit-wide covers a saved procedure", visitWide, "387713003", 9, true},
+		{"other category", visitWide, "103693007", 0, false},
+		{"its own procedure", forProcedure, "387713003", 7, true},
+		{"another procedure", forProcedure, "387713003", 9, false},
+		{"a new procedure", forProcedure, "387713003", 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.consent.Covers(tt.category, tt.procedureID); got != tt.want {
+			t.Fatalf("%s: Covers() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConsentContentHash(t *testing.T) {
+	t.Parallel()
+
+	hash := ConsentContentHash("text", []byte{1, 2, 3})
+	if len(hash) != 64 {
+		t.Fatalf("ConsentContentHash() = %q, want 64 hex characters", hash)
+	}
+	if hash == ConsentContentHash("text", []byte{1, 2, 4}) {
+		t.Fatalf("ConsentContentHash() ignores the signature")
+	}
+	if hash == ConsentContentHash("text.", []byte{1, 2, 3}) {
+		t.Fatalf("ConsentContentHash() ignores the text")
+	}
+}
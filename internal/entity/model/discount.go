@@ -0,0 +1,184 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	MstDiscountPolicyTableName     = "mdl_mst_discount_policy"
+	TrxDiscountApprovalTableName   = "mdl_trx_discount_approval"
+	TrxPriceOverrideAuditTableName = "mdl_trx_price_override_audit"
+)
+
+// Discount approval statuses. A request is pending until a supervisor decides it.
+const (
+	DiscountApprovalStatusPending  = "pending"
+	DiscountApprovalStatusApproved = "approved"
+	DiscountApprovalStatusRejected = "rejected"
+)
+
+// discountRateTolerance absorbs the rounding of discounts to the cent, so a 10%
+// cap still allows the 10% discount of an amount that does not divide evenly.
+const discountRateTolerance = 0.00005
+
+// MstDiscountPolicy caps the discount a role may give without approval, as a
+// fraction of a line's gross amount.
+type MstDiscountPolicy struct {
+	ID               int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64      `xorm:"'id_mst_institution'" json:"-"`
+	RoleName         string     `xorm:"'role_name'" json:"role_name"`
+	MaxDiscountRate  float64    `xorm:"'max_discount_rate'" json:"max_discount_rate"`
+	UpdatedBy        string     `xorm:"'updated_by'" json:"updated_by"`
+	CreateTime       time.Time  `xorm:"'create_time' created" json:"-"`
+	UpdateTime       time.Time  `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime       *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+type DiscountPolicyRequest struct {
+	RoleName        string  `json:"role_name" validate:"required"`
+	MaxDiscountRate float64 `json:"max_discount_rate" validate:"gte=0,lte=1"`
+}
+
+// SaveDiscountPoliciesRequest replaces every discount policy of the institution.
+type SaveDiscountPoliciesRequest struct {
+	Policies []DiscountPolicyRequest `json:"policies" validate:"dive"`
+}
+
+// DiscountLimit is the largest discount rate a user may give without approval.
+// Unlimited users are never asked for approval.
+type DiscountLimit struct {
+	MaxDiscountRate float64
+	Unlimited       bool
+}
+
+// Allows reports whether a discount of rate stays within the limit.
+func (l DiscountLimit) Allows(rate float64) bool {
+	return l.Unlimited || rate <= l.MaxDiscountRate+discountRateTolerance
+}
+
+// DiscountLimitForRoles returns the most generous cap among the policies of
+// roles. An institution without policies does not limit discounts; otherwise
+// roles without a policy may not discount at all.
+func DiscountLimitForRoles(policies []MstDiscountPolicy, roles []string) DiscountLimit {
+	if len(policies) == 0 {
+		return DiscountLimit{Unlimited: true}
+	}
+
+	rateByRole := make(map[string]float64, len(policies))
+	for _, policy := range policies {
+		rateByRole[policy.RoleName] = policy.MaxDiscountRate
+	}
+
+	limit := DiscountLimit{}
+	for _, role := range roles {
+		if rate, ok := rateByRole[role]; ok && rate > limit.MaxDiscountRate {
+			limit.MaxDiscountRate = rate
+		}
+	}
+	return limit
+}
+
+// EffectiveDiscountRate is the share of gross that a line no longer charges,
+// whichever discount field produced it.
+func EffectiveDiscountRate(gross, charged money.Money) float64 {
+	if !gross.IsPositive() || !gross.GreaterThan(charged) {
+		return 0
+	}
+	return gross.Sub(charged).DivMoney(gross).Float64()
+}
+
+type TrxDiscountApproval struct {
+	ID                      int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution        int64      `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxPatientVisit       int64      `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	IDTrxInstitutionProduct int64      `xorm:"'id_trx_institution_product'" json:"product_id"`
+	DiscountRate            float64    `xorm:"'discount_rate'" json:"discount_rate"`
+	Reason                  string     `xorm:"'reason'" json:"reason"`
+	Status                  string     `xorm:"'status'" json:"status"`
+	RequestedBy             string     `xorm:"'requested_by'" json:"requested_by"`
+	DecidedBy               string     `xorm:"'decided_by'" json:"decided_by,omitempty"`
+	DecidedAt               *time.Time `xorm:"'decided_at'" json:"decided_at,omitempty"`
+	DecisionNote            string     `xorm:"'decision_note'" json:"decision_note,omitempty"`
+	CreateTime              time.Time  `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime              time.Time  `xorm:"'update_time' updated" json:"update_time"`
+}
+
+// Covers reports whether the approval allows a discount of rate on the given
+// visit line.
+func (a TrxDiscountApproval) Covers(visitID, productID int64, rate float64) bool {
+	return a.Status == DiscountApprovalStatusApproved &&
+		a.IDTrxPatientVisit == visitID &&
+		a.IDTrxInstitutionProduct == productID &&
+		rate <= a.DiscountRate+discountRateTolerance
+}
+
+// CreateDiscountApprovalRequest asks a supervisor to allow a discount of up to
+// DiscountRate on one product of a visit.
+type CreateDiscountApprovalRequest struct {
+	IDTrxInstitutionProduct int64   `json:"product_id" validate:"required"`
+	DiscountRate            float64 `json:"discount_rate" validate:"gt=0,lte=1"`
+	Reason                  string  `json:"reason" validate:"required"`
+}
+
+type DecideDiscountApprovalRequest struct {
+	Note string `json:"note"`
+}
+
+type ListDiscountApprovalParams struct {
+	Status            string `schema:"status" validate:"omitempty,oneof=pending approved rejected"`
+	IDTrxPatientVisit int64  `schema:"visit_id"`
+	IDMstInstitution  int64  `schema:"-"`
+	CommonRequestPayload
+}
+
+// TrxPriceOverrideAudit records one manual change of a visit line's discount
+// or adjusted price.
+type TrxPriceOverrideAudit struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution        int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxPatientVisit       int64       `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	IDTrxVisitProduct       int64       `xorm:"'id_trx_visit_product'" json:"visit_product_id"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	GrossAmount             money.Money `xorm:"'gross_amount'" json:"gross_amount"`
+	OldDiscountRate         float64     `xorm:"'old_discount_rate'" json:"old_discount_rate"`
+	NewDiscountRate         float64     `xorm:"'new_discount_rate'" json:"new_discount_rate"`
+	OldDiscountPrice        money.Money `xorm:"'old_discount_price'" json:"old_discount_price"`
+	NewDiscountPrice        money.Money `xorm:"'new_discount_price'" json:"new_discount_price"`
+	OldAdjustedPrice        money.Money `xorm:"'old_adjusted_price'" json:"old_adjusted_price"`
+	NewAdjustedPrice        money.Money `xorm:"'new_adjusted_price'" json:"new_adjusted_price"`
+	EffectiveDiscountRate   float64     `xorm:"'effective_discount_rate'" json:"effective_discount_rate"`
+	Reason                  string      `xorm:"'reason'" json:"reason"`
+	IDTrxDiscountApproval   null.Int64  `xorm:"'id_trx_discount_approval'" json:"discount_approval_id"`
+	ChangedBy               string      `xorm:"'changed_by'" json:"changed_by"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"create_time"`
+}
+
+type ListPriceOverrideAuditParams struct {
+	IDTrxPatientVisit int64 `schema:"-"`
+	IDMstInstitution  int64 `schema:"-"`
+}
+
+// VisitLinePriceChange is a visit line before and after a change, with what the
+// user sent to justify it. Before is the zero line for a new line.
+type VisitLinePriceChange struct {
+	Before             TrxVisitProduct
+	After              TrxVisitProduct
+	Reason             string
+	DiscountApprovalID null.Int64
+}
+
+// GrossAmount is the line amount before any discount.
+func (c VisitLinePriceChange) GrossAmount() money.Money {
+	return c.After.Price.MulInt(int64(c.After.Quantity)).Round()
+}
+
+// IsManualChange reports whether the user changed the line's discount or
+// adjusted price. A new line counts when it carries any of them.
+func (c VisitLinePriceChange) IsManualChange() bool {
+	return c.Before.DiscountRate != c.After.DiscountRate ||
+		!c.Before.DiscountPrice.Equal(c.After.DiscountPrice) ||
+		!c.Before.AdjustedPrice.Equal(c.After.AdjustedPrice)
+}
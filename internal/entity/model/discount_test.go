@@ -0,0 +1,84 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestDiscountLimitForRoles(t *testing.T) {
+	t.Parallel()
+
+	policies := []MstDiscountPolicy{
+		{RoleName: "clerk", MaxDiscountRate: 0.05},
+		{RoleName: "doctor", MaxDiscountRate: 0.2},
+	}
+
+	cases := []struct {
+		name      string
+		policies  []MstDiscountPolicy
+		roles     []string
+		rate      float64
+		wantAllow bool
+	}{
+		{"no policies allows everything", nil, []string{"clerk"}, 1, true},
+		{"within the cap", policies, []string{"clerk"}, 0.05, true},
+		{"above the cap", policies, []string{"clerk"}, 0.06, false},
+		{"most generous role wins", policies, []string{"clerk", "doctor"}, 0.2, true},
+		{"role without policy may not discount", policies, []string{"nurse"}, 0.01, false},
+		{"role without policy may charge full price", policies, []string{"nurse"}, 0, true},
+	}
+	for _, c := range cases {
+		limit := DiscountLimitForRoles(c.policies, c.roles)
+		if got := limit.Allows(c.rate); got != c.wantAllow {
+			t.Fatalf("%s: expected allow %v, got %v", c.name, c.wantAllow, got)
+		}
+	}
+}
+
+func TestEffectiveDiscountRate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name           string
+		gross, charged money.Money
+		want           float64
+	}{
+		{"no discount", money.New(100000), money.New(100000), 0},
+		{"quarter off", money.New(100000), money.New(75000), 0.25},
+		{"free", money.New(100000), money.Zero, 1},
+		{"zero gross", money.Zero, money.Zero, 0},
+		{"charged above gross", money.New(100000), money.New(110000), 0},
+	}
+	for _, c := range cases {
+		if got := EffectiveDiscountRate(c.gross, c.charged); math.Abs(got-c.want) > 1e-9 {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestDiscountApprovalCovers(t *testing.T) {
+	t.Parallel()
+
+	approval := TrxDiscountApproval{
+		IDTrxPatientVisit:       10,
+		IDTrxInstitutionProduct: 20,
+		DiscountRate:            0.3,
+		Status:                  DiscountApprovalStatusApproved,
+	}
+	if !approval.Covers(10, 20, 0.3) {
+		t.Fatalf("expected approval to cover its own rate")
+	}
+	if approval.Covers(10, 20, 0.31) {
+		t.Fatalf("expected approval not to cover a larger rate")
+	}
+	if approval.Covers(11, 20, 0.1) || approval.Covers(10, 21, 0.1) {
+		t.Fatalf("expected approval to cover only its visit and product")
+	}
+
+	approval.Status = DiscountApprovalStatusPending
+	if approval.Covers(10, 20, 0.1) {
+		t.Fatalf("expected pending approval not to cover any discount")
+	}
+}
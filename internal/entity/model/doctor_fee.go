@@ -0,0 +1,275 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	customtime "github.com/faisalhardin/medilink/pkg/type/time"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	MstDoctorFeeRuleTableName = "mdl_mst_doctor_fee_rule"
+)
+
+// Doctor fee types. A percentage fee is a share of the revenue of the
+// procedure's product; a fixed fee is paid per procedure.
+const (
+	DoctorFeeTypePercentage = "percentage"
+	DoctorFeeTypeFixed      = "fixed"
+)
+
+// MstDoctorFeeRule sets the commission of procedures. A rule without a doctor
+// applies to every doctor; a rule names at most one of a product or a
+// procedure category, and one naming neither is the default of its doctor.
+type MstDoctorFeeRule struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution        int64       `xorm:"'id_mst_institution'" json:"-"`
+	DoctorID                null.String `xorm:"'doctor_id'" json:"doctor_id"`
+	IDTrxInstitutionProduct null.Int64  `xorm:"'id_trx_institution_product'" json:"product_id"`
+	ProcedureCategory       null.String `xorm:"'procedure_category'" json:"procedure_category"`
+	FeeType                 string      `xorm:"'fee_type'" json:"fee_type"`
+	FeeRate                 float64     `xorm:"'fee_rate'" json:"fee_rate"`
+	FeeAmount               money.Money `xorm:"'fee_amount'" json:"fee_amount"`
+	UpdatedBy               string      `xorm:"'updated_by'" json:"updated_by"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"-"`
+	UpdateTime              time.Time   `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime              *time.Time  `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// specificity ranks how closely a rule fits a procedure: a product beats a
+// category, which beats the default, and a doctor's own rule beats the
+// institution-wide rule of the same scope.
+func (r MstDoctorFeeRule) specificity() int {
+	score := 0
+	if r.IDTrxInstitutionProduct.Valid {
+		score += 4
+	}
+	if r.ProcedureCategory.Valid {
+		score += 2
+	}
+	if r.DoctorID.Valid {
+		score++
+	}
+	return score
+}
+
+func (r MstDoctorFeeRule) matches(doctorID string, productID null.Int64, category string) bool {
+	if r.DoctorID.Valid && r.DoctorID.String != doctorID {
+		return false
+	}
+	if r.IDTrxInstitutionProduct.Valid && (!productID.Valid || productID.Int64 != r.IDTrxInstitutionProduct.Int64) {
+		return false
+	}
+	if r.ProcedureCategory.Valid && r.ProcedureCategory.String != category {
+		return false
+	}
+	return true
+}
+
+// Fee returns the commission of a procedure whose share of revenue is base.
+func (r MstDoctorFeeRule) Fee(base money.Money) money.Money {
+	if r.FeeType == DoctorFeeTypeFixed {
+		return r.FeeAmount
+	}
+	if !base.IsPositive() {
+		return money.Zero
+	}
+	return base.MulRate(r.FeeRate).Round()
+}
+
+// MatchDoctorFeeRule returns the most specific rule of rules that applies to
+// a procedure, or false when none does.
+func MatchDoctorFeeRule(rules []MstDoctorFeeRule, doctorID string, productID null.Int64, category string) (MstDoctorFeeRule, bool) {
+	var (
+		best  MstDoctorFeeRule
+		found bool
+	)
+	for _, rule := range rules {
+		if !rule.matches(doctorID, productID, category) {
+			continue
+		}
+		if !found || rule.specificity() > best.specificity() {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+type DoctorFeeRuleRequest struct {
+	DoctorID          null.String `json:"doctor_id"`
+	ProductID         null.Int64  `json:"product_id"`
+	ProcedureCategory null.String `json:"procedure_category" validate:"omitempty,oneof=103693007 24642003 277132007 387713003 409063005 409073007 410606002 46947000"`
+	FeeType           string      `json:"fee_type" validate:"required,oneof=percentage fixed"`
+	FeeRate           float64     `json:"fee_rate"`
+	FeeAmount         money.Money `json:"fee_amount"`
+}
+
+// SaveDoctorFeeRulesRequest replaces every doctor fee rule of the institution.
+type SaveDoctorFeeRulesRequest struct {
+	Rules []DoctorFeeRuleRequest `json:"rules" validate:"dive"`
+}
+
+type DoctorFeeReportParams struct {
+	StartTime customtime.Time `schema:"start_time" validate:"required"`
+	EndTime   customtime.Time `schema:"end_time" validate:"required"`
+	DoctorID  string          `schema:"doctor_id"`
+}
+
+// DoctorFeeReportQuery is the validated, institution-scoped query passed to the repo.
+type DoctorFeeReportQuery struct {
+	IDMstInstitution int64
+	StartTime        time.Time
+	EndTime          time.Time
+	DoctorID         string
+}
+
+// DoctorFeeVisitTotals is the invoice total of a visit created in the report
+// period, what the patient paid on it and the payer coverage it was opened with.
+type DoctorFeeVisitTotals struct {
+	IDTrxPatientVisit  int64           `xorm:"'id_trx_patient_visit'"`
+	IDMstPatientPayer  null.Int64      `xorm:"'id_mst_patient_payer'"`
+	PayerType          string          `xorm:"'payer_type'"`
+	PayerCoverageRate  float64         `xorm:"'payer_coverage_rate'"`
+	PayerCoverageLimit money.NullMoney `xorm:"'payer_coverage_limit'"`
+	InvoiceTotal       money.Money     `xorm:"'invoice_total'"`
+	TotalPaid          money.Money     `xorm:"'total_paid'"`
+}
+
+// IsPaid reports whether the patient paid their share of the invoice, split
+// with the payer as the visit's bill is; a visit the payer covers in full is
+// paid without a payment.
+func (t DoctorFeeVisitTotals) IsPaid() bool {
+	split := NewVisitInvoiceSplit(TrxPatientVisit{
+		IDMstPatientPayer:  t.IDMstPatientPayer,
+		PayerType:          t.PayerType,
+		PayerCoverageRate:  t.PayerCoverageRate,
+		PayerCoverageLimit: t.PayerCoverageLimit,
+	}, t.InvoiceTotal)
+	return t.InvoiceTotal.IsPositive() && !t.TotalPaid.LessThan(split.PatientShare)
+}
+
+// DoctorFeeProcedureRow is a procedure of a paid visit with the revenue of the
+// visit lines of its product: their tax base, before PPN and service charge.
+// Procedures sharing those lines split it.
+type DoctorFeeProcedureRow struct {
+	IDTrxVisitProcedure int64       `xorm:"'id_trx_visit_procedure'"`
+	IDTrxPatientVisit   int64       `xorm:"'id_trx_patient_visit'"`
+	VisitTime           time.Time   `xorm:"'visit_time'"`
+	DoctorID            string      `xorm:"'doctor_id'"`
+	DoctorName          string      `xorm:"'doctor_name'"`
+	ProductID           null.Int64  `xorm:"'product_id'"`
+	ProductName         string      `xorm:"'product_name'"`
+	ProcedureCategory   string      `xorm:"'procedure_category'"`
+	LineRevenue         money.Money `xorm:"'line_revenue'"`
+	RefundedRevenue     money.Money `xorm:"'refunded_revenue'"`
+	ProcedureCount      int64       `xorm:"'procedure_count'"`
+	ProcedureRank       int64       `xorm:"'procedure_rank'"`
+}
+
+// DoctorFeeLine is the commission of one procedure.
+type DoctorFeeLine struct {
+	IDTrxVisitProcedure int64       `json:"procedure_id"`
+	IDTrxPatientVisit   int64       `json:"visit_id"`
+	VisitTime           time.Time   `json:"visit_time"`
+	ProductID           null.Int64  `json:"product_id"`
+	ProductName         string      `json:"product_name"`
+	ProcedureCategory   string      `json:"procedure_category"`
+	Revenue             money.Money `json:"revenue"`
+	IDMstDoctorFeeRule  null.Int64  `json:"rule_id"`
+	FeeType             string      `json:"fee_type,omitempty"`
+	FeeRate             float64     `json:"fee_rate"`
+	Fee                 money.Money `json:"fee"`
+}
+
+type DoctorFeeSummary struct {
+	DoctorID       string          `json:"doctor_id"`
+	DoctorName     string          `json:"doctor_name"`
+	ProcedureCount int             `json:"procedure_count"`
+	TotalRevenue   money.Money     `json:"total_revenue"`
+	TotalFee       money.Money     `json:"total_fee"`
+	Lines          []DoctorFeeLine `json:"lines"`
+}
+
+type DoctorFeeReport struct {
+	StartTime    time.Time          `json:"start_time"`
+	EndTime      time.Time          `json:"end_time"`
+	TotalRevenue money.Money        `json:"total_revenue"`
+	TotalFee     money.Money        `json:"total_fee"`
+	Doctors      []DoctorFeeSummary `json:"doctors"`
+}
+
+// NewDoctorFeeReport prices every procedure of rows with the rule that fits it
+// best and groups the lines by doctor, in the order the doctors first appear.
+// The net revenue of a product's visit lines is split evenly between the
+// procedures sharing it, the remainder going to the last ones so the shares
+// add up exactly. Procedures without a matching rule, or whose product lines
+// were refunded in full, earn nothing.
+func NewDoctorFeeReport(rows []DoctorFeeProcedureRow, rules []MstDoctorFeeRule) DoctorFeeReport {
+	report := DoctorFeeReport{
+		TotalRevenue: money.Zero,
+		TotalFee:     money.Zero,
+		Doctors:      []DoctorFeeSummary{},
+	}
+	doctorIndex := map[string]int{}
+
+	for _, row := range rows {
+		line := DoctorFeeLine{
+			IDTrxVisitProcedure: row.IDTrxVisitProcedure,
+			IDTrxPatientVisit:   row.IDTrxPatientVisit,
+			VisitTime:           row.VisitTime,
+			ProductID:           row.ProductID,
+			ProductName:         row.ProductName,
+			ProcedureCategory:   row.ProcedureCategory,
+			Revenue:             row.revenueShare(),
+			Fee:                 money.Zero,
+		}
+		if rule, ok := MatchDoctorFeeRule(rules, row.DoctorID, row.ProductID, row.ProcedureCategory); ok {
+			line.IDMstDoctorFeeRule = null.Int64From(rule.ID)
+			line.FeeType = rule.FeeType
+			line.FeeRate = rule.FeeRate
+			line.Fee = rule.Fee(line.Revenue)
+			if row.fullyRefunded() {
+				line.Fee = money.Zero
+			}
+		}
+
+		i, ok := doctorIndex[row.DoctorID]
+		if !ok {
+			i = len(report.Doctors)
+			doctorIndex[row.DoctorID] = i
+			report.Doctors = append(report.Doctors, DoctorFeeSummary{
+				DoctorID:     row.DoctorID,
+				DoctorName:   row.DoctorName,
+				TotalRevenue: money.Zero,
+				TotalFee:     money.Zero,
+				Lines:        []DoctorFeeLine{},
+			})
+		}
+		doctor := &report.Doctors[i]
+		doctor.ProcedureCount++
+		doctor.TotalRevenue = doctor.TotalRevenue.Add(line.Revenue)
+		doctor.TotalFee = doctor.TotalFee.Add(line.Fee)
+		doctor.Lines = append(doctor.Lines, line)
+
+		report.TotalRevenue = report.TotalRevenue.Add(line.Revenue)
+		report.TotalFee = report.TotalFee.Add(line.Fee)
+	}
+	return report
+}
+
+// revenueShare is the procedure's part of the net revenue of its product lines.
+func (r DoctorFeeProcedureRow) revenueShare() money.Money {
+	net := r.LineRevenue.Sub(r.RefundedRevenue)
+	if !r.ProductID.Valid || !net.IsPositive() || r.ProcedureCount <= 0 {
+		return money.Zero
+	}
+	upTo := func(rank int64) money.Money {
+		return net.MulInt(rank).Div(r.ProcedureCount).Round()
+	}
+	return upTo(r.ProcedureRank).Sub(upTo(r.ProcedureRank - 1))
+}
+
+func (r DoctorFeeProcedureRow) fullyRefunded() bool {
+	return r.LineRevenue.IsPositive() && !r.LineRevenue.GreaterThan(r.RefundedRevenue)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/volatiletech/null/v8"
+)
+
+func TestMatchDoctorFeeRule(t *testing.T) {
+	t.Parallel()
+
+	rules := []MstDoctorFeeRule{
+		{ID: 1, FeeType: DoctorFeeTypePercentage, FeeRate: 0.1},
+		{ID: 2, DoctorID: null.StringFrom("dr-a"), FeeType: DoctorFeeTypePercentage, FeeRate: 0.2},
+		{ID: 3, ProcedureCategory: null.StringFrom("387713003"), FeeType: DoctorFeeTypePercentage, FeeRate: 0.25},
+		{ID: 4, IDTrxInstitutionProduct: null.Int64From(7), FeeType: DoctorFeeTypeFixed, FeeAmount: money.New(50000)},
+		{ID: 5, DoctorID: null.StringFrom("dr-a"), IDTrxInstitutionProduct: null.Int64From(7), FeeType: DoctorFeeTypePercentage, FeeRate: 0.4},
+	}
+
+	cases := []struct {
+		doctorID  string
+		productID null.Int64
+		category  string
+		want      int64
+	}{
+		{"dr-b", null.Int64{}, "", 1},
+		{"dr-a", null.Int64{}, "", 2},
+		{"dr-a", null.Int64From(8), "387713003", 3},
+		{"dr-b", null.Int64From(7), "387713003", 4},
+		{"dr-a", null.Int64From(7), "", 5},
+	}
+	for _, c := range cases {
+		rule, ok := MatchDoctorFeeRule(rules, c.doctorID, c.productID, c.category)
+		if !ok || rule.ID != c.want {
+			t.Fatalf("doctor %s product %v category %q: expected rule %d, got %d (found %v)", c.doctorID, c.productID.Int64, c.category, c.want, rule.ID, ok)
+		}
+	}
+
+	if _, ok := MatchDoctorFeeRule(rules[1:2], "dr-b", null.Int64{}, ""); ok {
+		t.Fatalf("expected another doctor's rule not to match")
+	}
+}
+
+func TestNewDoctorFeeReport(t *testing.T) {
+	t.Parallel()
+
+	rules := []MstDoctorFeeRule{
+		{ID: 1, FeeType: DoctorFeeTypePercentage, FeeRate: 0.3},
+		{ID: 2, DoctorID: null.StringFrom("dr-b"), FeeType: DoctorFeeTypeFixed, FeeAmount: money.New(25000)},
+	}
+	// three procedures share 100000 of revenue, less 10000 refunded
+	shared := DoctorFeeProcedureRow{
+		IDTrxPatientVisit: 1,
+		ProductID:         null.Int64From(7),
+		LineRevenue:       money.New(100000),
+		RefundedRevenue:   money.New(10000),
+		ProcedureCount:    3,
+	}
+	rows := []DoctorFeeProcedureRow{}
+	for rank, doctorID := range []string{"dr-a", "dr-a", "dr-b"} {
+		row := shared
+		row.IDTrxVisitProcedure = int64(rank + 1)
+		row.ProcedureRank = int64(rank + 1)
+		row.DoctorID = doctorID
+		rows = append(rows, row)
+	}
+	rows = append(rows, DoctorFeeProcedureRow{
+		IDTrxVisitProcedure: 4,
+		IDTrxPatientVisit:   2,
+		DoctorID:            "dr-b",
+		ProductID:           null.Int64From(7),
+		LineRevenue:         money.New(40000),
+		RefundedRevenue:     money.New(40000),
+		ProcedureCount:      1,
+		ProcedureRank:       1,
+	})
+
+	report := NewDoctorFeeReport(rows, rules)
+	if len(report.Doctors) != 2 {
+		t.Fatalf("expected 2 doctors, got %d", len(report.Doctors))
+	}
+	if report.TotalRevenue.String() != "90000" {
+		t.Fatalf("expected revenue shares to add up to 90000, got %s", report.TotalRevenue)
+	}
+
+	drA := report.Doctors[0]
+	if drA.TotalRevenue.String() != "60000" || drA.TotalFee.String() != "18000" {
+		t.Fatalf("unexpected dr-a revenue %s fee %s", drA.TotalRevenue, drA.TotalFee)
+	}
+
+	drB := report.Doctors[1]
+	if drB.ProcedureCount != 2 || drB.TotalFee.String() != "25000" {
+		t.Fatalf("expected one fixed fee for dr-b and none for the refunded procedure, got %d procedures fee %s", drB.ProcedureCount, drB.TotalFee)
+	}
+	if !drB.Lines[1].Fee.IsZero() {
+		t.Fatalf("expected no fee on a fully refunded procedure, got %s", drB.Lines[1].Fee)
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MstInstitutionMRNConfigTableName = "mdl_mst_institution_mrn_config"
+	TrxMRNCounterTableName           = "mdl_trx_mrn_counter"
+)
+
+// MstInstitutionMRNConfig is the format of an institution's medical record
+// numbers (No. RM): Prefix, then the registration year when IncludeYear, then
+// a sequence zero-padded to SequenceDigits, joined by Separator.
+type MstInstitutionMRNConfig struct {
+	ID               int64     `xorm:"'id' pk autoincr" json:"-"`
+	IDMstInstitution int64     `xorm:"'id_mst_institution'" json:"-"`
+	Prefix           string    `xorm:"'prefix'" json:"prefix"`
+	Separator        string    `xorm:"'separator'" json:"separator"`
+	IncludeYear      bool      `xorm:"'include_year'" json:"include_year"`
+	SequenceDigits   int       `xorm:"'sequence_digits'" json:"sequence_digits"`
+	UpdatedBy        string    `xorm:"'updated_by'" json:"updated_by"`
+	CreateTime       time.Time `xorm:"'create_time' created" json:"-"`
+	UpdateTime       time.Time `xorm:"'update_time' updated" json:"update_time"`
+}
+
+// DefaultMRNConfig is the format of an institution that never configured one:
+// RM-2026-000123.
+func DefaultMRNConfig(institutionID int64) MstInstitutionMRNConfig {
+	return MstInstitutionMRNConfig{
+		IDMstInstitution: institutionID,
+		Prefix:           "RM",
+		Separator:        "-",
+		IncludeYear:      true,
+		SequenceDigits:   6,
+	}
+}
+
+// Period is the counter a number registered at t is drawn from: the year when
+// the format carries one, so the sequence restarts yearly, otherwise 0.
+func (c MstInstitutionMRNConfig) Period(t time.Time) int {
+	if c.IncludeYear {
+		return t.Year()
+	}
+	return 0
+}
+
+// Format builds the medical record number for sequence in period. A sequence
+// longer than SequenceDigits is written in full rather than truncated.
+func (c MstInstitutionMRNConfig) Format(period int, sequence int64) string {
+	parts := make([]string, 0, 3)
+	if c.Prefix != "" {
+		parts = append(parts, c.Prefix)
+	}
+	if c.IncludeYear {
+		parts = append(parts, strconv.Itoa(period))
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", c.SequenceDigits, sequence))
+	return strings.Join(parts, c.Separator)
+}
+
+type UpdateMRNConfigRequest struct {
+	Prefix         string `json:"prefix" validate:"omitempty,max=10,alphanum"`
+	Separator      string `json:"separator" validate:"omitempty,oneof=- / ."`
+	IncludeYear    bool   `json:"include_year"`
+	SequenceDigits int    `json:"sequence_digits" validate:"min=3,max=10"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMRNConfigFormat(t *testing.T) {
+	t.Parallel()
+
+	registered := time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		config   MstInstitutionMRNConfig
+		sequence int64
+		want     string
+	}{
+		{config: DefaultMRNConfig(1), sequence: 123, want: "RM-2026-000123"},
+		{config: MstInstitutionMRNConfig{Prefix: "KLN", Separator: "/", SequenceDigits: 4}, sequence: 7, want: "KLN/0007"},
+		{config: MstInstitutionMRNConfig{IncludeYear: true, SequenceDigits: 3}, sequence: 42, want: "2026042"},
+		{config: MstInstitutionMRNConfig{Prefix: "RM", Separator: ".", SequenceDigits: 3}, sequence: 12345, want: "RM.12345"},
+	}
+	for _, c := range cases {
+		if got := c.config.Format(c.config.Period(registered), c.sequence); got != c.want {
+			t.Fatalf("Format(%+v, %d) = %q, want %q", c.config, c.sequence, got, c.want)
+		}
+	}
+
+	if period := (MstInstitutionMRNConfig{}).Period(registered); period != 0 {
+		t.Fatalf("a format without the year should draw from period 0, got %d", period)
+	}
+}
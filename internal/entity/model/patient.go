@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/volatiletech/null/v8"
 )
 
 const (
@@ -12,21 +14,30 @@ const (
 )
 
 type MstPatientInstitution struct {
-	ID            int64      `json:"-" xorm:"'id' pk autoincr"`
-	UUID          string     `json:"uuid" xorm:"'uuid' <-"`
-	NIK           string     `json:"nik" xorm:"'nik'"`
-	Name          string     `json:"name" xorm:"'name'"`
-	Sex           string     `json:"sex" xorm:"'sex'"`
-	PlaceOfBirth  string     `json:"place_of_birth" xorm:"'place_of_birth'"`
-	DateOfBirth   time.Time  `json:"date_of_birth" xorm:"'date_of_birth'"`
-	Address       string     `json:"address" xorm:"'address'"`
-	Religion      string     `json:"religion" xorm:"'religion'"`
-	PhoneNumber   string     `json:"phone_number" xorm:"phone_number"`
-	Occupation    string     `json:"occupation" xorm:"'occupation'"`
-	InstitutionID int64      `json:"institution_id" xorm:"'id_mst_institution'"`
-	CreateTime    time.Time  `json:"-" xorm:"'create_time' created"`
-	UpdateTime    time.Time  `json:"-" xorm:"'update_time' updated"`
-	DeleteTime    *time.Time `json:"-" xorm:"'delete_time' deleted"`
+	ID                  int64     `json:"-" xorm:"'id' pk autoincr"`
+	UUID                string    `json:"uuid" xorm:"'uuid' <-"`
+	MedicalRecordNumber string    `json:"medical_record_number" xorm:"'medical_record_number'"`
+	NIK                 string    `json:"nik" xorm:"'nik'"`
+	Name                string    `json:"name" xorm:"'name'"`
+	Sex                 string    `json:"sex" xorm:"'sex'"`
+	PlaceOfBirth        string    `json:"place_of_birth" xorm:"'place_of_birth'"`
+	DateOfBirth         time.Time `json:"date_of_birth" xorm:"'date_of_birth'"`
+	Address             string    `json:"address" xorm:"'address'"`
+	Religion            string    `json:"religion" xorm:"'religion'"`
+	PhoneNumber         string    `json:"phone_number" xorm:"phone_number"`
+	Occupation          string    `json:"occupation" xorm:"'occupation'"`
+	// PatientCategory selects the price list of the patient's visits.
+	PatientCategory string `json:"patient_category" xorm:"'patient_category'"`
+	// LegacyRecordNumber and LegacyLastVisitDate keep, for an imported
+	// patient, their number and last visit in the clinic's previous system.
+	// AnonymiseTime is set once the patient's identifying data was scrubbed.
+	LegacyRecordNumber  string     `json:"legacy_record_number" xorm:"'legacy_record_number'"`
+	LegacyLastVisitDate null.Time  `json:"legacy_last_visit_date" xorm:"'legacy_last_visit_date'"`
+	AnonymiseTime       null.Time  `json:"anonymise_time" xorm:"'anonymise_time'"`
+	InstitutionID       int64      `json:"institution_id" xorm:"'id_mst_institution'"`
+	CreateTime          time.Time  `json:"-" xorm:"'create_time' created"`
+	UpdateTime          time.Time  `json:"-" xorm:"'update_time' updated"`
+	DeleteTime          *time.Time `json:"-" xorm:"'delete_time' deleted"`
 }
 
 // type MstPatientVisit struct {
@@ -51,6 +62,8 @@ type RegisterNewPatientRequest struct {
 	PhoneNumber  string `json:"phone_number"`
 	Religion     string `json:"religion"`
 	Occupation   string `json:"occupation"`
+	// PatientCategory defaults to general.
+	PatientCategory string `json:"patient_category" validate:"omitempty,oneof=general bpjs insurance corporate staff"`
 }
 
 type GetPatientParams struct {
@@ -60,31 +73,45 @@ type GetPatientParams struct {
 	InstitutionID int64    `schema:"institution_id"`
 	NIK           string   `schema:"nik"`
 	PhoneNumber   string   `schema:"phone_number"`
+	// MedicalRecordNumber matches any part of a No. RM.
+	MedicalRecordNumber string `schema:"medical_record_number"`
+	// Query searches name, NIK, phone number and No. RM at once, tolerating
+	// typos in the name, and ranks the results by match and latest visit.
+	Query string `schema:"q"`
 	CommonRequestPayload
 }
 
 type GetPatientResponse struct {
-	UUID         string    `json:"uuid" xorm:"'uuid' <-"`
-	NIK          string    `json:"nik" xorm:"'nik'"`
-	Name         string    `json:"name" xorm:"'name'"`
-	PlaceOfBirth string    `json:"place_of_birth" xorm:"'place_of_birth'"`
-	DateOfBirth  time.Time `json:"date_of_birth" xorm:"'date_of_birth'"`
-	Address      string    `json:"address" xorm:"'address'"`
-	Religion     string    `json:"religion" xorm:"'religion'"`
-	PhoneNumber  string    `json:"phone_number"`
-	Sex          string    `json:"sex" xorm:"'sex'"`
-	Occupation   string    `json:"occupation" xorm:"occupation"`
+	UUID                string    `json:"uuid" xorm:"'uuid' <-"`
+	MedicalRecordNumber string    `json:"medical_record_number" xorm:"'medical_record_number'"`
+	NIK                 string    `json:"nik" xorm:"'nik'"`
+	Name                string    `json:"name" xorm:"'name'"`
+	PlaceOfBirth        string    `json:"place_of_birth" xorm:"'place_of_birth'"`
+	DateOfBirth         time.Time `json:"date_of_birth" xorm:"'date_of_birth'"`
+	Address             string    `json:"address" xorm:"'address'"`
+	Religion            string    `json:"religion" xorm:"'religion'"`
+	PhoneNumber         string    `json:"phone_number"`
+	Sex                 string    `json:"sex" xorm:"'sex'"`
+	Occupation          string    `json:"occupation" xorm:"occupation"`
+	PatientCategory     string    `json:"patient_category" xorm:"patient_category"`
+	LegacyRecordNumber  string    `json:"legacy_record_number,omitempty" xorm:"'legacy_record_number'"`
+	LegacyLastVisitDate null.Time `json:"legacy_last_visit_date" xorm:"'legacy_last_visit_date'"`
+	AnonymiseTime       null.Time `json:"anonymise_time" xorm:"'anonymise_time'"`
+	// PossibleDuplicates warns, on registration, of existing patients that
+	// look like the same person.
+	PossibleDuplicates []PatientDuplicateCandidate `json:"possible_duplicates,omitempty" xorm:"-"`
 }
 
 type UpdatePatientRequest struct {
-	UUID         string `json:"uuid" xorm:"'uuid' <-"`
-	NIK          string `json:"nik" xorm:"'nik'"`
-	Name         string `json:"name" xorm:"'name'"`
-	Sex          string `json:"sex" xorm:"'sex'"`
-	PlaceOfBirth string `json:"place_of_birth" xorm:"'place_of_birth'"`
-	DateOfBirth  Time   `json:"date_of_birth" xorm:"'date_of_birth'"`
-	Address      string `json:"address" xorm:"'address'"`
-	Religion     string `json:"religion" xorm:"'religion'"`
-	PhoneNumber  string `json:"phone_number" xorm:"'phone_number'"`
-	Occupation   string `json:"occupation" xorm:"'occupation'"`
+	UUID            string `json:"uuid" xorm:"'uuid' <-"`
+	NIK             string `json:"nik" xorm:"'nik'"`
+	Name            string `json:"name" xorm:"'name'"`
+	Sex             string `json:"sex" xorm:"'sex'"`
+	PlaceOfBirth    string `json:"place_of_birth" xorm:"'place_of_birth'"`
+	DateOfBirth     Time   `json:"date_of_birth" xorm:"'date_of_birth'"`
+	Address         string `json:"address" xorm:"'address'"`
+	Religion        string `json:"religion" xorm:"'religion'"`
+	PhoneNumber     string `json:"phone_number" xorm:"'phone_number'"`
+	Occupation      string `json:"occupation" xorm:"'occupation'"`
+	PatientCategory string `json:"patient_category" xorm:"'patient_category'" validate:"omitempty,oneof=general bpjs insurance corporate staff"`
 }
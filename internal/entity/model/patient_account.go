@@ -0,0 +1,163 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	TrxPatientLedgerEntryTableName = "mdl_trx_patient_ledger_entry"
+)
+
+// Patient ledger entry types. Amounts are signed from the patient's side: a
+// positive balance is credit the patient can spend, a negative one is owed.
+const (
+	// PatientLedgerEntryDeposit is money paid in advance, not tied to a visit (+).
+	PatientLedgerEntryDeposit = "deposit"
+	// PatientLedgerEntryCharge posts a visit invoice to the account (-). Charges
+	// follow the invoice, so a changed invoice posts the difference.
+	PatientLedgerEntryCharge = "charge"
+	// PatientLedgerEntryPayment is money paid against a visit invoice (+).
+	PatientLedgerEntryPayment = "payment"
+	// PatientLedgerEntryReturn credits the value of items returned by a refund
+	// or credit note (+).
+	PatientLedgerEntryReturn = "return"
+	// PatientLedgerEntryRefund is money paid back to the patient (-).
+	PatientLedgerEntryRefund = "refund"
+)
+
+// Patient ledger reference types, pointing at the document behind an entry.
+const (
+	PatientLedgerReferenceVisit        = "visit"
+	PatientLedgerReferenceVisitPayment = "visit_payment"
+	PatientLedgerReferenceVisitRefund  = "visit_refund"
+)
+
+// PaymentMethodDeposit pays a visit from the patient's account credit. It is
+// only written by applying a deposit, never accepted as a payment method.
+const PaymentMethodDeposit = "deposit"
+
+type TrxPatientLedgerEntry struct {
+	ID                int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution  int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDMstPatient      int64       `xorm:"'id_mst_patient'" json:"-"`
+	EntryType         string      `xorm:"'entry_type'" json:"entry_type"`
+	Amount            money.Money `xorm:"'amount'" json:"amount"`
+	IDTrxPatientVisit null.Int64  `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	ReferenceType     string      `xorm:"'reference_type'" json:"reference_type,omitempty"`
+	ReferenceID       int64       `xorm:"'reference_id'" json:"reference_id,omitempty"`
+	PaymentMethod     string      `xorm:"'payment_method'" json:"payment_method,omitempty"`
+	Notes             string      `xorm:"'notes'" json:"notes,omitempty"`
+	CreatedBy         string      `xorm:"'created_by'" json:"created_by"`
+	CreateTime        time.Time   `xorm:"'create_time' created" json:"create_time"`
+	// Balance is the running balance after the entry, filled in for statements.
+	Balance money.Money `xorm:"-" json:"balance"`
+}
+
+// PatientLedgerTypeTotal sums the entries of one type.
+type PatientLedgerTypeTotal struct {
+	EntryType string      `xorm:"'entry_type'"`
+	Amount    money.Money `xorm:"'amount'"`
+}
+
+type PatientAccountDepositRequest struct {
+	PaymentMethod string      `json:"payment_method" validate:"required"`
+	Amount        money.Money `json:"amount"`
+	Reference     string      `json:"reference"`
+	Notes         string      `json:"notes"`
+}
+
+// PatientAccountRefundRequest pays unused credit back to the patient.
+type PatientAccountRefundRequest struct {
+	RefundMethod string      `json:"refund_method" validate:"required"`
+	Amount       money.Money `json:"amount"`
+	Reason       string      `json:"reason" validate:"required"`
+}
+
+// ApplyDepositRequest pays a visit from the patient's credit. A zero amount
+// applies as much as the credit and the outstanding invoice allow.
+type ApplyDepositRequest struct {
+	Amount money.Money `json:"amount"`
+}
+
+type PatientAccountStatementParams struct {
+	IDMstInstitution int64 `schema:"-"`
+	IDMstPatient     int64 `schema:"-"`
+	CommonRequestPayload
+}
+
+// PatientAccountSummary is the balance of a patient account with the totals
+// of each entry type, all as positive amounts.
+type PatientAccountSummary struct {
+	PatientUUID     string      `json:"patient_uuid"`
+	Balance         money.Money `json:"balance"`
+	AvailableCredit money.Money `json:"available_credit"`
+	AmountDue       money.Money `json:"amount_due"`
+	TotalDeposit    money.Money `json:"total_deposit"`
+	TotalCharge     money.Money `json:"total_charge"`
+	TotalPayment    money.Money `json:"total_payment"`
+	TotalReturn     money.Money `json:"total_return"`
+	TotalRefund     money.Money `json:"total_refund"`
+}
+
+func NewPatientAccountSummary(patientUUID string, totals []PatientLedgerTypeTotal) PatientAccountSummary {
+	summary := PatientAccountSummary{
+		PatientUUID:  patientUUID,
+		Balance:      money.Zero,
+		TotalDeposit: money.Zero,
+		TotalCharge:  money.Zero,
+		TotalPayment: money.Zero,
+		TotalReturn:  money.Zero,
+		TotalRefund:  money.Zero,
+	}
+	for _, total := range totals {
+		summary.Balance = summary.Balance.Add(total.Amount)
+		switch total.EntryType {
+		case PatientLedgerEntryDeposit:
+			summary.TotalDeposit = summary.TotalDeposit.Add(total.Amount)
+		case PatientLedgerEntryCharge:
+			summary.TotalCharge = summary.TotalCharge.Sub(total.Amount)
+		case PatientLedgerEntryPayment:
+			summary.TotalPayment = summary.TotalPayment.Add(total.Amount)
+		case PatientLedgerEntryReturn:
+			summary.TotalReturn = summary.TotalReturn.Add(total.Amount)
+		case PatientLedgerEntryRefund:
+			summary.TotalRefund = summary.TotalRefund.Sub(total.Amount)
+		}
+	}
+	summary.AvailableCredit = money.Max(summary.Balance, money.Zero)
+	summary.AmountDue = money.Max(summary.Balance.Neg(), money.Zero)
+	return summary
+}
+
+// PatientAccountStatement lists the entries of a period between the balance
+// before it and the balance after it.
+type PatientAccountStatement struct {
+	PatientUUID    string                  `json:"patient_uuid"`
+	PatientName    string                  `json:"patient_name"`
+	FromTime       *time.Time              `json:"from_time,omitempty"`
+	ToTime         *time.Time              `json:"to_time,omitempty"`
+	OpeningBalance money.Money             `json:"opening_balance"`
+	ClosingBalance money.Money             `json:"closing_balance"`
+	Entries        []TrxPatientLedgerEntry `json:"entries"`
+}
+
+// NewPatientAccountStatement fills in the running balance of entries, which
+// must be in posting order, starting from opening.
+func NewPatientAccountStatement(opening money.Money, entries []TrxPatientLedgerEntry) PatientAccountStatement {
+	balance := opening
+	for i := range entries {
+		balance = balance.Add(entries[i].Amount)
+		entries[i].Balance = balance
+	}
+	if entries == nil {
+		entries = []TrxPatientLedgerEntry{}
+	}
+	return PatientAccountStatement{
+		OpeningBalance: opening,
+		ClosingBalance: balance,
+		Entries:        entries,
+	}
+}
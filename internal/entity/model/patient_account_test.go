@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestNewPatientAccountSummary(t *testing.T) {
+	t.Parallel()
+
+	summary := NewPatientAccountSummary("patient-uuid", []PatientLedgerTypeTotal{
+		{EntryType: PatientLedgerEntryDeposit, Amount: money.New(500000)},
+		{EntryType: PatientLedgerEntryCharge, Amount: money.New(-350000)},
+		{EntryType: PatientLedgerEntryPayment, Amount: money.New(100000)},
+		{EntryType: PatientLedgerEntryReturn, Amount: money.New(20000)},
+		{EntryType: PatientLedgerEntryRefund, Amount: money.New(-70000)},
+	})
+	if summary.Balance.String() != "200000" || summary.AvailableCredit.String() != "200000" || !summary.AmountDue.IsZero() {
+		t.Fatalf("unexpected balance %s credit %s due %s", summary.Balance, summary.AvailableCredit, summary.AmountDue)
+	}
+	if summary.TotalCharge.String() != "350000" || summary.TotalRefund.String() != "70000" {
+		t.Fatalf("expected positive totals, got charge %s refund %s", summary.TotalCharge, summary.TotalRefund)
+	}
+
+	owing := NewPatientAccountSummary("patient-uuid", []PatientLedgerTypeTotal{
+		{EntryType: PatientLedgerEntryCharge, Amount: money.New(-150000)},
+		{EntryType: PatientLedgerEntryPayment, Amount: money.New(100000)},
+	})
+	if owing.AmountDue.String() != "50000" || !owing.AvailableCredit.IsZero() {
+		t.Fatalf("expected 50000 due and no credit, got due %s credit %s", owing.AmountDue, owing.AvailableCredit)
+	}
+}
+
+func TestNewPatientAccountStatement(t *testing.T) {
+	t.Parallel()
+
+	statement := NewPatientAccountStatement(money.New(100000), []TrxPatientLedgerEntry{
+		{EntryType: PatientLedgerEntryCharge, Amount: money.New(-150000)},
+		{EntryType: PatientLedgerEntryPayment, Amount: money.New(30000)},
+	})
+	if statement.Entries[0].Balance.String() != "-50000" || statement.Entries[1].Balance.String() != "-20000" {
+		t.Fatalf("unexpected running balances %s, %s", statement.Entries[0].Balance, statement.Entries[1].Balance)
+	}
+	if statement.ClosingBalance.String() != "-20000" {
+		t.Fatalf("expected closing balance -20000, got %s", statement.ClosingBalance)
+	}
+
+	empty := NewPatientAccountStatement(money.New(100000), nil)
+	if empty.Entries == nil || !empty.ClosingBalance.Equal(empty.OpeningBalance) {
+		t.Fatalf("expected an empty statement to keep the opening balance")
+	}
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	TrxPatientAttachmentTableName = "mdl_trx_patient_attachment"
+)
+
+// Categories of patient attachments.
+const (
+	AttachmentCategoryXRay           = "xray"
+	AttachmentCategoryIntraoralPhoto = "intraoral_photo"
+	AttachmentCategoryReferralLetter = "referral_letter"
+	AttachmentCategoryLabResult      = "lab_result"
+	AttachmentCategoryOther          = "other"
+)
+
+// Variants of an attachment that can be downloaded.
+const (
+	AttachmentVariantOriginal  = "original"
+	AttachmentVariantThumbnail = "thumbnail"
+)
+
+const (
+	// DefaultAttachmentMaxFileSize applies when the configuration sets no limit.
+	DefaultAttachmentMaxFileSize = 20 << 20
+	DefaultAttachmentURLTTL      = 15 * time.Minute
+	AttachmentThumbnailMaxSide   = 256
+)
+
+// AttachmentContentTypes are the file types accepted for upload, as sniffed
+// from their content, with the extension they are stored under.
+var AttachmentContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// HasAttachmentThumbnail reports whether a thumbnail is made for uploads of
+// contentType.
+func HasAttachmentThumbnail(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// TrxPatientAttachment is a file kept for a patient, e.g. an X-ray or a lab
+// PDF, optionally tied to a visit and a tooth.
+type TrxPatientAttachment struct {
+	ID                int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution  int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDMstPatient      int64       `xorm:"'id_mst_patient'" json:"-"`
+	IDTrxPatientVisit null.Int64  `xorm:"'id_trx_patient_visit'" json:"visit_id"`
+	ToothID           null.String `xorm:"'tooth_id'" json:"tooth_id"`
+	Category          string      `xorm:"'category'" json:"category"`
+	Description       string      `xorm:"'description'" json:"description"`
+	FileName          string      `xorm:"'file_name'" json:"file_name"`
+	ContentType       string      `xorm:"'content_type'" json:"content_type"`
+	SizeBytes         int64       `xorm:"'size_bytes'" json:"size_bytes"`
+	Checksum          string      `xorm:"'checksum'" json:"checksum"`
+	StorageKey        string      `xorm:"'storage_key'" json:"-"`
+	ThumbnailKey      null.String `xorm:"'thumbnail_key'" json:"-"`
+	UploadedBy        string      `xorm:"'uploaded_by'" json:"uploaded_by"`
+	CreateTime        time.Time   `xorm:"'create_time' created" json:"create_time"`
+	DeleteTime        *time.Time  `xorm:"'delete_time' deleted" json:"-"`
+}
+
+func (TrxPatientAttachment) TableName() string {
+	return TrxPatientAttachmentTableName
+}
+
+// AttachmentStorageKey is where an attachment is stored, grouped by
+// institution and patient; name is unique per upload.
+func AttachmentStorageKey(institutionID int64, patientUUID, name, ext string) string {
+	return fmt.Sprintf("institution/%d/patient/%s/%s%s", institutionID, patientUUID, name, ext)
+}
+
+// AttachmentThumbnailKey is where the thumbnail of the attachment stored under
+// key is kept.
+func AttachmentThumbnailKey(key string) string {
+	return key + ".thumb.jpg"
+}
+
+// AttachmentResource names an attachment variant in download signatures.
+func AttachmentResource(institutionID, attachmentID int64, variant string) string {
+	return fmt.Sprintf("attachment/%d/%d/%s", institutionID, attachmentID, variant)
+}
+
+// AttachmentResponse is an attachment with links to download it. The links
+// need no session and stop working at URLExpiresAt.
+type AttachmentResponse struct {
+	TrxPatientAttachment
+	PatientUUID  string    `json:"patient_uuid"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// UploadAttachmentRequest is the fields of an upload form; the handler fills
+// in FileName and Content from its file.
+type UploadAttachmentRequest struct {
+	PatientUUID string `schema:"-"`
+	VisitID     int64  `schema:"visit_id" validate:"min=0"`
+	ToothID     string `schema:"tooth_id"`
+	Category    string `schema:"category" validate:"required,oneof=xray intraoral_photo referral_letter lab_result other"`
+	Description string `schema:"description" validate:"max=500"`
+	FileName    string `schema:"-"`
+	Content     []byte `schema:"-"`
+}
+
+type ListAttachmentParams struct {
+	PatientUUID string `schema:"-"`
+	VisitID     int64  `schema:"visit_id"`
+	ToothID     string `schema:"tooth_id"`
+	Category    string `schema:"category"`
+}
+
+// DownloadAttachmentParams come from a signed download URL.
+type DownloadAttachmentParams struct {
+	ID            int64  `schema:"-"`
+	InstitutionID int64  `schema:"institution_id"`
+	Variant       string `schema:"variant"`
+	Expires       int64  `schema:"expires"`
+	Signature     string `schema:"signature"`
+}
+
+// AttachmentContent is a downloaded file. The caller closes Body.
+type AttachmentContent struct {
+	Body        io.ReadCloser
+	ContentType string
+	FileName    string
+}
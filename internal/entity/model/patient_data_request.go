@@ -0,0 +1,187 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	TrxPatientDataRequestTableName = "mdl_trx_patient_data_request"
+)
+
+// Requests a patient can make about their personal data under UU PDP.
+const (
+	PatientDataRequestTypeExport    = "export"
+	PatientDataRequestTypeAnonymise = "anonymise"
+)
+
+const (
+	// AnonymisedPatientName replaces the name of an anonymised patient.
+	AnonymisedPatientName = "Anonymised patient"
+	// AnonymisedText replaces what identifies a patient in text kept for
+	// retention, such as the rendered text of their consents.
+	AnonymisedText = "[anonymised]"
+	// PatientDataExportTimelinePageSize is how many clinical records an export
+	// reads at a time.
+	PatientDataExportTimelinePageSize = 500
+)
+
+// TrxPatientDataRequest records an export or anonymisation of a patient's
+// data, who handled it and why.
+type TrxPatientDataRequest struct {
+	ID               int64     `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64     `xorm:"'id_mst_institution'" json:"-"`
+	IDMstPatient     int64     `xorm:"'id_mst_patient'" json:"-"`
+	RequestType      string    `xorm:"'request_type'" json:"request_type"`
+	Reason           string    `xorm:"'reason'" json:"reason"`
+	RequestedBy      string    `xorm:"'requested_by'" json:"requested_by"`
+	CreateTime       time.Time `xorm:"'create_time' created" json:"create_time"`
+}
+
+func (TrxPatientDataRequest) TableName() string {
+	return TrxPatientDataRequestTableName
+}
+
+// ExportPatientDataParams asks for everything held about a patient.
+type ExportPatientDataParams struct {
+	PatientUUID string `schema:"-"`
+	Reason      string `schema:"reason" validate:"max=500"`
+}
+
+// AnonymisePatientRequest asks to scrub what identifies a patient.
+type AnonymisePatientRequest struct {
+	PatientUUID string `json:"-"`
+	Reason      string `json:"reason" validate:"required,max=500"`
+}
+
+// PatientDataExport is everything held about a patient, written to the files
+// of an export archive. Signatures and attachment files are read from storage
+// as the archive is written.
+type PatientDataExport struct {
+	Manifest     PatientDataExportManifest
+	Patient      GetPatientResponse
+	Relations    []PatientRelationResponse
+	Payers       []MstPatientPayer
+	Timeline     []TimelineEntry
+	Consents     []TrxConsent
+	Attachments  []TrxPatientAttachment
+	Ledger       []TrxPatientLedgerEntry
+	Merges       []PatientMergeRow
+	DataRequests []TrxPatientDataRequest
+}
+
+// PatientDataExportManifest describes an export archive.
+type PatientDataExportManifest struct {
+	PatientUUID string    `json:"patient_uuid"`
+	RequestID   int64     `json:"request_id"`
+	ExportedBy  string    `json:"exported_by"`
+	ExportedAt  time.Time `json:"exported_at"`
+	Files       []string  `json:"files"`
+	// MissingFiles are attachments whose file was not found in storage.
+	MissingFiles []string `json:"missing_files,omitempty"`
+}
+
+// Files of a patient data export archive.
+const (
+	PatientDataExportManifestFile    = "manifest.json"
+	PatientDataExportPatientFile     = "patient.json"
+	PatientDataExportTimelineFile    = "clinical_records.json"
+	PatientDataExportConsentFile     = "consents.json"
+	PatientDataExportAttachmentFile  = "attachments.json"
+	PatientDataExportBillingFile     = "billing.json"
+	PatientDataExportAuditFile       = "audit.json"
+	patientDataExportConsentDir      = "consents/"
+	patientDataExportAttachmentDir   = "attachments/"
+	patientDataExportMaxFileNameSize = 100
+)
+
+// PatientDataExportAudit is the audit file of an export: the patient's merges
+// and the requests about their data.
+type PatientDataExportAudit struct {
+	Merges       []PatientMergeRow       `json:"merges"`
+	DataRequests []TrxPatientDataRequest `json:"data_requests"`
+}
+
+// PatientDataExportPatient is the patient file of an export.
+type PatientDataExportPatient struct {
+	Patient   GetPatientResponse        `json:"patient"`
+	Relations []PatientRelationResponse `json:"relations"`
+	Payers    []MstPatientPayer         `json:"payers"`
+}
+
+// PatientDataExportSignaturePath is where the signature of consent is kept in
+// an export archive.
+func PatientDataExportSignaturePath(consent TrxConsent) string {
+	return fmt.Sprintf("%s%d-signature%s", patientDataExportConsentDir, consent.ID, AttachmentContentTypes[consent.SignatureContentType])
+}
+
+// PatientDataExportAttachmentPath is where the file of attachment is kept in an
+// export archive, named after the uploaded file.
+func PatientDataExportAttachmentPath(attachment TrxPatientAttachment) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, path.Base(strings.ReplaceAll(attachment.FileName, "\\", "/")))
+	name = strings.TrimLeft(name, ".")
+	if runes := []rune(name); len(runes) > patientDataExportMaxFileNameSize {
+		name = string(runes[len(runes)-patientDataExportMaxFileNameSize:])
+	}
+	if name == "" {
+		name = "file" + AttachmentContentTypes[attachment.ContentType]
+	}
+	return fmt.Sprintf("%s%d-%s", patientDataExportAttachmentDir, attachment.ID, name)
+}
+
+// AnonymisedPatient is patient without what identifies them: the name is
+// replaced, the date of birth kept to its year, and NIK, contact details and
+// other demographics cleared. The medical record number, sex and patient
+// category stay so retained clinical and billing records still add up.
+func AnonymisedPatient(patient MstPatientInstitution, now time.Time) MstPatientInstitution {
+	patient.Name = AnonymisedPatientName
+	patient.NIK = ""
+	patient.PlaceOfBirth = ""
+	patient.DateOfBirth = time.Date(patient.DateOfBirth.Year(), time.January, 1, 0, 0, 0, 0, patient.DateOfBirth.Location())
+	patient.Address = ""
+	patient.Religion = ""
+	patient.PhoneNumber = ""
+	patient.Occupation = ""
+	patient.LegacyRecordNumber = ""
+	patient.AnonymiseTime.SetValid(now)
+	return patient
+}
+
+// PatientIdentifiers are the values of patients that may have been copied
+// into text, such as consents rendered with their name, NIK and date of birth
+// written in dateLayout. They come without repeats, longest first, so one
+// containing another is replaced whole.
+func PatientIdentifiers(patients []MstPatientInstitution, dateLayout string) []string {
+	seen := map[string]bool{}
+	identifiers := []string{}
+	for _, patient := range patients {
+		for _, value := range []string{
+			patient.Name,
+			patient.NIK,
+			patient.DateOfBirth.Format(dateLayout),
+			patient.PhoneNumber,
+			patient.Address,
+			patient.LegacyRecordNumber,
+		} {
+			value = strings.TrimSpace(value)
+			if value == "" || seen[value] {
+				continue
+			}
+			seen[value] = true
+			identifiers = append(identifiers, value)
+		}
+	}
+	sort.SliceStable(identifiers, func(i, j int) bool {
+		return len(identifiers[i]) > len(identifiers[j])
+	})
+	return identifiers
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatientDataExportAttachmentPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attachment TrxPatientAttachment
+		want       string
+	}{
+		{TrxPatientAttachment{ID: 7, FileName: "panoramic 2026.jpg"}, "attachments/7-panoramic_2026.jpg"},
+		{TrxPatientAttachment{ID: 8, FileName: "../../etc/passwd"}, "attachments/8-passwd"},
+		{TrxPatientAttachment{ID: 9, FileName: `C:\scans\rujukan.pdf`}, "attachments/9-rujukan.pdf"},
+		{TrxPatientAttachment{ID: 10, FileName: "..", ContentType: "image/png"}, "attachments/10-file.png"},
+		{TrxPatientAttachment{ID: 11, FileName: strings.Repeat("a", 120) + ".jpg"}, "attachments/11-" + strings.Repeat("a", 96) + ".jpg"},
+	}
+	for _, tt := range tests {
+		if got := PatientDataExportAttachmentPath(tt.attachment); got != tt.want {
+			t.Fatalf("PatientDataExportAttachmentPath(%q) = %q, want %q", tt.attachment.FileName, got, tt.want)
+		}
+	}
+
+	if got := PatientDataExportSignaturePath(TrxConsent{ID: 3, SignatureContentType: "image/png"}); got != "consents/3-signature.png" {
+		t.Fatalf("PatientDataExportSignaturePath() = %q", got)
+	}
+}
+
+func TestAnonymisedPatient(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	patient := MstPatientInstitution{
+		ID:                  1,
+		MedicalRecordNumber: "RM-2026-000001",
+		NIK:                 "3171012345678901",
+		Name:                "Budi Santoso",
+		Sex:                 "male",
+		PlaceOfBirth:        "Jakarta",
+		DateOfBirth:         time.Date(1990, time.March, 5, 0, 0, 0, 0, time.UTC),
+		Address:             "Jl. Melati 1",
+		Religion:            "Islam",
+		PhoneNumber:         "081234567890",
+		Occupation:          "Guru",
+		PatientCategory:     "general",
+		LegacyRecordNumber:  "A-001",
+		InstitutionID:       2,
+	}
+
+	got := AnonymisedPatient(patient, now)
+	want := MstPatientInstitution{
+		ID:                  1,
+		MedicalRecordNumber: "RM-2026-000001",
+		Name:                AnonymisedPatientName,
+		Sex:                 "male",
+		DateOfBirth:         time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC),
+		PatientCategory:     "general",
+		InstitutionID:       2,
+	}
+	want.AnonymiseTime.SetValid(now)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("AnonymisedPatient() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPatientIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	patients := []MstPatientInstitution{
+		{
+			Name:        "Budi",
+			NIK:         "3171012345678901",
+			DateOfBirth: time.Date(1990, time.March, 5, 0, 0, 0, 0, time.UTC),
+			Address:     " ",
+		},
+		{
+			Name:        "Budi Santoso",
+			NIK:         "3171012345678901",
+			DateOfBirth: time.Date(1990, time.March, 5, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	got := PatientIdentifiers(patients, ConsentDateLayout)
+	want := []string{"3171012345678901", "Budi Santoso", "05-03-1990", "Budi"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PatientIdentifiers() = %q, want %q", got, want)
+	}
+}
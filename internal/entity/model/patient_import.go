@@ -0,0 +1,558 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	TrxPatientImportTableName = "mdl_trx_patient_import"
+
+	// PatientImportMaxRows bounds the rows of an import file, header included.
+	PatientImportMaxRows = 20001
+	// PatientImportBatchSize is the number of lines imported per transaction;
+	// progress is saved after every batch.
+	PatientImportBatchSize = 100
+	// PatientImportNIKLength is the length of a NIK, the national identity number.
+	PatientImportNIKLength = 16
+
+	LegacyRecordNumberMaxLength = 50
+)
+
+// Statuses of a patient import.
+const (
+	PatientImportStatusQueued     = "queued"
+	PatientImportStatusProcessing = "processing"
+	PatientImportStatusCompleted  = "completed"
+	PatientImportStatusFailed     = "failed"
+)
+
+// Fields the columns of a patient import file are mapped to.
+const (
+	PatientImportFieldName               = "name"
+	PatientImportFieldNIK                = "nik"
+	PatientImportFieldSex                = "sex"
+	PatientImportFieldDateOfBirth        = "date_of_birth"
+	PatientImportFieldPlaceOfBirth       = "place_of_birth"
+	PatientImportFieldAddress            = "address"
+	PatientImportFieldReligion           = "religion"
+	PatientImportFieldPhoneNumber        = "phone_number"
+	PatientImportFieldOccupation         = "occupation"
+	PatientImportFieldPatientCategory    = "patient_category"
+	PatientImportFieldLegacyRecordNumber = "legacy_record_number"
+	PatientImportFieldLastVisitDate      = "last_visit_date"
+)
+
+var PatientImportFields = []string{
+	PatientImportFieldName,
+	PatientImportFieldNIK,
+	PatientImportFieldSex,
+	PatientImportFieldDateOfBirth,
+	PatientImportFieldPlaceOfBirth,
+	PatientImportFieldAddress,
+	PatientImportFieldReligion,
+	PatientImportFieldPhoneNumber,
+	PatientImportFieldOccupation,
+	PatientImportFieldPatientCategory,
+	PatientImportFieldLegacyRecordNumber,
+	PatientImportFieldLastVisitDate,
+}
+
+var patientImportRequiredFields = []string{PatientImportFieldName, PatientImportFieldSex, PatientImportFieldDateOfBirth}
+
+// Orders of day, month and year in the dates of an import file. Dates written
+// with a four-digit year first are read as year-month-day whatever the order.
+const (
+	DateOrderDMY = "dmy"
+	DateOrderMDY = "mdy"
+	DateOrderYMD = "ymd"
+)
+
+// importMonths reads month names and their abbreviations in Indonesian or
+// English.
+var importMonths = map[string]time.Month{
+	"jan": time.January, "januari": time.January, "january": time.January,
+	"feb": time.February, "februari": time.February, "february": time.February, "pebruari": time.February,
+	"mar": time.March, "maret": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"mei": time.May, "may": time.May,
+	"jun": time.June, "juni": time.June, "june": time.June,
+	"jul": time.July, "juli": time.July, "july": time.July,
+	"agu": time.August, "agt": time.August, "agustus": time.August, "aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"okt": time.October, "oktober": time.October, "oct": time.October, "october": time.October,
+	"nov": time.November, "nopember": time.November, "november": time.November,
+	"des": time.December, "desember": time.December, "dec": time.December, "december": time.December,
+}
+
+// excelEpoch is day 0 of the serial numbers spreadsheets store dates as.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// PatientImportOptions says how to read an import file. Mapping maps headers
+// of the file, in any case, to PatientImportFields; headers it leaves out are
+// matched to the field of the same name, if any, and otherwise ignored.
+// Patients that merely look like an existing patient are skipped unless
+// ImportPossibleDuplicates; a known NIK or legacy record number always is.
+type PatientImportOptions struct {
+	Mapping                  map[string]string `json:"mapping"`
+	DateOrder                string            `json:"date_order"`
+	ImportPossibleDuplicates bool              `json:"import_possible_duplicates"`
+}
+
+// PatientImportRequest carries the rows of an uploaded file, header first.
+type PatientImportRequest struct {
+	FileName string
+	Rows     [][]string
+	Options  PatientImportOptions
+}
+
+// TrxPatientImport is an uploaded patient file and the progress of its import.
+// Rows holds the file, header first; ProcessedRows counts the lines after the
+// header done so far. Errors lists a PatientImportLineError per line that was
+// not imported.
+type TrxPatientImport struct {
+	ID               int64           `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64           `xorm:"'id_mst_institution'" json:"-"`
+	FileName         string          `xorm:"'file_name'" json:"file_name"`
+	Status           string          `xorm:"'status'" json:"status"`
+	Options          json.RawMessage `xorm:"'options' jsonb notnull" json:"options"`
+	Rows             json.RawMessage `xorm:"'rows' jsonb notnull" json:"-"`
+	TotalRows        int             `xorm:"'total_rows'" json:"total_rows"`
+	ProcessedRows    int             `xorm:"'processed_rows'" json:"processed_rows"`
+	ImportedRows     int             `xorm:"'imported_rows'" json:"imported_rows"`
+	DuplicateRows    int             `xorm:"'duplicate_rows'" json:"duplicate_rows"`
+	FailedRows       int             `xorm:"'failed_rows'" json:"failed_rows"`
+	Errors           json.RawMessage `xorm:"'errors' jsonb notnull" json:"-"`
+	FailureReason    string          `xorm:"'failure_reason'" json:"failure_reason"`
+	CreatedBy        string          `xorm:"'created_by'" json:"created_by"`
+	StartTime        null.Time       `xorm:"'start_time'" json:"start_time"`
+	FinishTime       null.Time       `xorm:"'finish_time'" json:"finish_time"`
+	CreateTime       time.Time       `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime       time.Time       `xorm:"'update_time' updated" json:"update_time"`
+}
+
+func (TrxPatientImport) TableName() string {
+	return TrxPatientImportTableName
+}
+
+// Progress is the percentage of the lines of the import done so far.
+func (i TrxPatientImport) Progress() int {
+	if i.TotalRows == 0 {
+		if i.Status == PatientImportStatusCompleted {
+			return 100
+		}
+		return 0
+	}
+	return i.ProcessedRows * 100 / i.TotalRows
+}
+
+// PatientImportResponse is the status of an import.
+type PatientImportResponse struct {
+	TrxPatientImport
+	Progress int `json:"progress"`
+}
+
+type ListPatientImportParams struct {
+	IDMstInstitution int64 `schema:"-"`
+	CommonRequestPayload
+}
+
+// PatientImportLineError is why a line of an import file was not imported.
+// DuplicateOf is the UUID of the existing patient a duplicate line matched.
+type PatientImportLineError struct {
+	Line        int    `json:"line"`
+	Column      string `json:"column,omitempty"`
+	Message     string `json:"message"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+func (e PatientImportLineError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d, %s: %s", e.Line, e.Column, e.Message)
+}
+
+// PatientImportColumns locates the fields of an import file among its
+// columns.
+type PatientImportColumns struct {
+	index  map[string]int
+	header []string
+}
+
+// Cell returns the trimmed value of field in record, empty when the file has
+// no column for it.
+func (c PatientImportColumns) Cell(record []string, field string) string {
+	index, ok := c.index[field]
+	if !ok || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+// Header returns the header of the column of field as written in the file.
+func (c PatientImportColumns) Header(field string) string {
+	if index, ok := c.index[field]; ok {
+		return c.header[index]
+	}
+	return field
+}
+
+// ResolvePatientImportColumns maps the header of an import file to fields, by
+// mapping first and then by name. Headers are compared ignoring case, and
+// spaces or dashes in them read as underscores when matched by name.
+func ResolvePatientImportColumns(header []string, mapping map[string]string) (columns PatientImportColumns, lineErrors []PatientImportLineError) {
+	columns = PatientImportColumns{index: map[string]int{}, header: make([]string, len(header))}
+	fail := func(column, message string) {
+		lineErrors = append(lineErrors, PatientImportLineError{Line: 1, Column: column, Message: message})
+	}
+
+	positions := map[string]int{}
+	for i, name := range header {
+		columns.header[i] = strings.TrimSpace(name)
+		key := strings.ToLower(columns.header[i])
+		if key == "" {
+			continue
+		}
+		if _, ok := positions[key]; ok {
+			fail(columns.header[i], "column appears more than once")
+			continue
+		}
+		positions[key] = i
+	}
+
+	known := map[string]bool{}
+	for _, field := range PatientImportFields {
+		known[field] = true
+	}
+
+	mapped := map[int]bool{}
+	for name, field := range mapping {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !known[field] {
+			fail(name, fmt.Sprintf("is mapped to %q, which is not one of %s", field, strings.Join(PatientImportFields, ", ")))
+			continue
+		}
+		position, ok := positions[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			fail(name, "is mapped but the file has no such column")
+			continue
+		}
+		if _, ok := columns.index[field]; ok {
+			fail(name, fmt.Sprintf("is mapped to %s like another column", field))
+			continue
+		}
+		columns.index[field] = position
+		mapped[position] = true
+	}
+
+	for key, position := range positions {
+		field := strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+		if mapped[position] || !known[field] {
+			continue
+		}
+		if _, ok := columns.index[field]; ok {
+			continue
+		}
+		columns.index[field] = position
+	}
+
+	for _, field := range patientImportRequiredFields {
+		if _, ok := columns.index[field]; !ok {
+			fail(field, "column is missing; map a column of the file to it")
+		}
+	}
+	if len(lineErrors) > 0 {
+		return PatientImportColumns{}, lineErrors
+	}
+	return columns, nil
+}
+
+// PatientImportRow is one validated line of an import file.
+type PatientImportRow struct {
+	Line               int
+	NIK                string
+	Name               string
+	Sex                string
+	PlaceOfBirth       string
+	DateOfBirth        time.Time
+	Address            string
+	Religion           string
+	PhoneNumber        string
+	Occupation         string
+	PatientCategory    string
+	LegacyRecordNumber string
+	LastVisitDate      null.Time
+}
+
+// Patient is the patient the row registers in the institution.
+func (r PatientImportRow) Patient(institutionID int64) MstPatientInstitution {
+	return MstPatientInstitution{
+		NIK:                 r.NIK,
+		Name:                r.Name,
+		Sex:                 r.Sex,
+		PlaceOfBirth:        r.PlaceOfBirth,
+		DateOfBirth:         r.DateOfBirth,
+		Address:             r.Address,
+		Religion:            r.Religion,
+		PhoneNumber:         r.PhoneNumber,
+		Occupation:          r.Occupation,
+		PatientCategory:     NormalisePatientCategory(r.PatientCategory),
+		LegacyRecordNumber:  r.LegacyRecordNumber,
+		LegacyLastVisitDate: r.LastVisitDate,
+		InstitutionID:       institutionID,
+	}
+}
+
+// ParsePatientImportRow validates line of an import file, reading its dates in
+// dateOrder; today bounds the dates of birth and visits. Errors name the
+// column as headed in the file.
+func ParsePatientImportRow(line int, record []string, columns PatientImportColumns, dateOrder string, today time.Time) (row PatientImportRow, errs []PatientImportLineError) {
+	cell := func(field string) string {
+		return columns.Cell(record, field)
+	}
+	fail := func(field, message string) {
+		errs = append(errs, PatientImportLineError{Line: line, Column: columns.Header(field), Message: message})
+	}
+
+	row = PatientImportRow{
+		Line:               line,
+		Name:               cell(PatientImportFieldName),
+		PlaceOfBirth:       cell(PatientImportFieldPlaceOfBirth),
+		Address:            cell(PatientImportFieldAddress),
+		Religion:           cell(PatientImportFieldReligion),
+		PhoneNumber:        cell(PatientImportFieldPhoneNumber),
+		Occupation:         cell(PatientImportFieldOccupation),
+		PatientCategory:    strings.ToLower(cell(PatientImportFieldPatientCategory)),
+		LegacyRecordNumber: cell(PatientImportFieldLegacyRecordNumber),
+	}
+
+	if row.Name == "" {
+		fail(PatientImportFieldName, "is required")
+	}
+
+	var ok bool
+	if row.Sex, ok = ParseImportSex(cell(PatientImportFieldSex)); !ok {
+		fail(PatientImportFieldSex, "must be L or P, or male or female")
+	}
+
+	if nik := cell(PatientImportFieldNIK); nik != "" {
+		switch {
+		case strings.ContainsAny(nik, "eE"):
+			fail(PatientImportFieldNIK, "was saved as a number and lost its last digits; format the column as text and export again")
+		case len(NormaliseNIK(nik)) != PatientImportNIKLength:
+			fail(PatientImportFieldNIK, fmt.Sprintf("must be %d digits", PatientImportNIKLength))
+		default:
+			row.NIK = NormaliseNIK(nik)
+		}
+	}
+
+	earliest := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	dateOfBirth, ok := ParseImportDate(cell(PatientImportFieldDateOfBirth), dateOrder)
+	switch {
+	case cell(PatientImportFieldDateOfBirth) == "":
+		fail(PatientImportFieldDateOfBirth, "is required")
+	case !ok:
+		fail(PatientImportFieldDateOfBirth, fmt.Sprintf("is not a date in %s order", dateOrder))
+	case dateOfBirth.Before(earliest) || dateOfBirth.After(today):
+		fail(PatientImportFieldDateOfBirth, "must be between 1900 and today")
+	default:
+		row.DateOfBirth = dateOfBirth
+	}
+
+	if value := cell(PatientImportFieldLastVisitDate); value != "" {
+		lastVisit, ok := ParseImportDate(value, dateOrder)
+		switch {
+		case !ok:
+			fail(PatientImportFieldLastVisitDate, fmt.Sprintf("is not a date in %s order", dateOrder))
+		case lastVisit.After(today):
+			fail(PatientImportFieldLastVisitDate, "cannot be in the future")
+		case !row.DateOfBirth.IsZero() && lastVisit.Before(row.DateOfBirth):
+			fail(PatientImportFieldLastVisitDate, "is before the date of birth")
+		default:
+			row.LastVisitDate = null.TimeFrom(lastVisit)
+		}
+	}
+
+	if len(row.LegacyRecordNumber) > LegacyRecordNumberMaxLength {
+		fail(PatientImportFieldLegacyRecordNumber, fmt.Sprintf("must be at most %d characters", LegacyRecordNumberMaxLength))
+	}
+
+	// spreadsheets drop the leading 0 of phone numbers kept as numbers
+	if strings.HasPrefix(row.PhoneNumber, "8") && digitsOnly(row.PhoneNumber) == row.PhoneNumber {
+		row.PhoneNumber = "0" + row.PhoneNumber
+	}
+	return row, errs
+}
+
+// ParseImportSex reads the sex of a patient as written in Indonesian or
+// English.
+func ParseImportSex(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "l", "lk", "laki-laki", "laki laki", "lakilaki", "laki", "pria", "m", "male":
+		return "male", true
+	case "p", "pr", "perempuan", "wanita", "w", "f", "female":
+		return "female", true
+	default:
+		return "", false
+	}
+}
+
+// ParseImportDate reads a date as exported by spreadsheets and clinic systems:
+// day, month and year in order separated by /, -, . or spaces, the month
+// possibly by name; a four-digit year first; a date with a time after it; or
+// a spreadsheet serial number. Two-digit years are taken as the latest year
+// not after the current one.
+func ParseImportDate(value, order string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if strings.Trim(value, "0123456789.") == "" && strings.Count(value, ".") <= 1 {
+		serial, err := strconv.ParseFloat(value, 64)
+		// a year alone is not a date; other numbers are serials from 1900 on
+		if err != nil || serial < 1 || serial > 100000 || (len(value) == 4 && serial >= 1900) {
+			return time.Time{}, false
+		}
+		return excelEpoch.AddDate(0, 0, int(math.Floor(serial))), true
+	}
+
+	// drop the time of day of a timestamp
+	if i := strings.IndexAny(value, "T "); i == 10 && (value[4] == '-' || value[4] == '/') {
+		value = value[:i]
+	}
+
+	parts := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == ' ' || r == ','
+	})
+	if len(parts) == 4 && strings.Contains(parts[3], ":") {
+		parts = parts[:3]
+	}
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	if len(parts[0]) == 4 {
+		order = DateOrderYMD
+	}
+
+	var day, month, year string
+	switch order {
+	case DateOrderMDY:
+		month, day, year = parts[0], parts[1], parts[2]
+	case DateOrderYMD:
+		year, month, day = parts[0], parts[1], parts[2]
+	default:
+		day, month, year = parts[0], parts[1], parts[2]
+	}
+	// a month name settles which part is the month
+	for i, part := range parts {
+		if _, ok := importMonths[part]; ok && order != DateOrderYMD {
+			others := append(append([]string{}, parts[:i]...), parts[i+1:]...)
+			month, day, year = part, others[0], others[1]
+			break
+		}
+	}
+
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	m, ok := importMonths[month]
+	if !ok {
+		n, err := strconv.Atoi(month)
+		if err != nil {
+			return time.Time{}, false
+		}
+		m = time.Month(n)
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil || (len(year) != 2 && len(year) != 4) {
+		return time.Time{}, false
+	}
+	if len(year) == 2 {
+		century := time.Now().Year() / 100 * 100
+		y += century
+		if y > time.Now().Year() {
+			y -= 100
+		}
+	}
+
+	date := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	if date.Year() != y || date.Month() != m || date.Day() != d {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// IsValidDateOrder reports whether order is one of the date orders of
+// imports.
+func IsValidDateOrder(order string) bool {
+	switch order {
+	case DateOrderDMY, DateOrderMDY, DateOrderYMD:
+		return true
+	}
+	return false
+}
+
+// IsBlankImportRecord reports whether a line of an import file is empty.
+func IsBlankImportRecord(record []string) bool {
+	return isBlankRecord(record)
+}
+
+// PatientImportReport lists the lines of an import file that were not
+// imported, each with its problems and its cells as in the file, so the file
+// can be fixed and imported again.
+type PatientImportReport struct {
+	Header []string
+	Lines  []PatientImportReportLine
+}
+
+type PatientImportReportLine struct {
+	Line     int
+	Problems string
+	Record   []string
+}
+
+// BuildPatientImportReport groups the errors of an import by line, in line
+// order. rows is the import file, header first.
+func BuildPatientImportReport(rows [][]string, lineErrors []PatientImportLineError) (report PatientImportReport) {
+	report.Lines = []PatientImportReportLine{}
+	if len(rows) > 0 {
+		report.Header = rows[0]
+	}
+
+	problems := map[int][]string{}
+	lines := []int{}
+	for _, lineErr := range lineErrors {
+		if _, ok := problems[lineErr.Line]; !ok {
+			lines = append(lines, lineErr.Line)
+		}
+		problem := lineErr.Message
+		if lineErr.Column != "" {
+			problem = lineErr.Column + ": " + problem
+		}
+		problems[lineErr.Line] = append(problems[lineErr.Line], problem)
+	}
+	sort.Ints(lines)
+
+	for _, line := range lines {
+		reportLine := PatientImportReportLine{
+			Line:     line,
+			Problems: strings.Join(problems[line], "; "),
+		}
+		if line >= 2 && line <= len(rows) {
+			reportLine.Record = rows[line-1]
+		}
+		report.Lines = append(report.Lines, reportLine)
+	}
+	return report
+}
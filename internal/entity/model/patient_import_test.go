@@ -0,0 +1,160 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseImportDate(t *testing.T) {
+	t.Parallel()
+
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		value  string
+		order  string
+		want   time.Time
+		wantOK bool
+	}{
+		{"05/03/1990", DateOrderDMY, date(1990, time.March, 5), true},
+		{"05-03-1990", DateOrderMDY, date(1990, time.May, 3), true},
+		{"1990-03-05", DateOrderDMY, date(1990, time.March, 5), true},
+		{"1990-03-05 00:00:00", DateOrderDMY, date(1990, time.March, 5), true},
+		{"05/03/1990 08:30", DateOrderDMY, date(1990, time.March, 5), true},
+		{"5 Agustus 1990", DateOrderDMY, date(1990, time.August, 5), true},
+		{"Aug 5, 1990", DateOrderDMY, date(1990, time.August, 5), true},
+		{"05.03.90", DateOrderDMY, date(1990, time.March, 5), true},
+		{"32874", DateOrderDMY, date(1990, time.January, 1), true},
+		{"32874.5", DateOrderDMY, date(1990, time.January, 1), true},
+		{"1990", DateOrderDMY, time.Time{}, false},
+		{"31/02/1990", DateOrderDMY, time.Time{}, false},
+		{"13/13/1990", DateOrderDMY, time.Time{}, false},
+		{"05/03", DateOrderDMY, time.Time{}, false},
+		{"unknown", DateOrderDMY, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseImportDate(tt.value, tt.order)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Fatalf("ParseImportDate(%q, %s) = %v, %v, want %v, %v", tt.value, tt.order, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseImportSex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{"L", "male", true},
+		{"Laki-laki", "male", true},
+		{"male", "male", true},
+		{"P", "female", true},
+		{"Perempuan", "female", true},
+		{"F", "female", true},
+		{"", "", false},
+		{"x", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseImportSex(tt.value); got != tt.want || ok != tt.wantOK {
+			t.Fatalf("ParseImportSex(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestResolvePatientImportColumns(t *testing.T) {
+	t.Parallel()
+
+	header := []string{"No RM", "Nama Pasien", "JK", "Tgl Lahir", "Phone Number", "Catatan"}
+	mapping := map[string]string{
+		"no rm":       PatientImportFieldLegacyRecordNumber,
+		"Nama Pasien": PatientImportFieldName,
+		"JK":          PatientImportFieldSex,
+		"Tgl Lahir":   PatientImportFieldDateOfBirth,
+	}
+
+	columns, lineErrors := ResolvePatientImportColumns(header, mapping)
+	if len(lineErrors) > 0 {
+		t.Fatalf("ResolvePatientImportColumns() errors = %v", lineErrors)
+	}
+	record := []string{"A-001", " Budi ", "L", "05/03/1990", "0812000001", "alergi"}
+	for field, want := range map[string]string{
+		PatientImportFieldLegacyRecordNumber: "A-001",
+		PatientImportFieldName:               "Budi",
+		PatientImportFieldPhoneNumber:        "0812000001",
+		PatientImportFieldAddress:            "",
+	} {
+		if got := columns.Cell(record, field); got != want {
+			t.Fatalf("Cell(%s) = %q, want %q", field, got, want)
+		}
+	}
+	if got := columns.Header(PatientImportFieldSex); got != "JK" {
+		t.Fatalf("Header(sex) = %q, want JK", got)
+	}
+
+	_, lineErrors = ResolvePatientImportColumns(header, map[string]string{"Nama Pasien": "full_name", "Alamat": PatientImportFieldAddress})
+	// an unknown field, a missing column, and name, sex and date of birth unmapped
+	if len(lineErrors) != 5 {
+		t.Fatalf("ResolvePatientImportColumns() errors = %v, want 5", lineErrors)
+	}
+}
+
+func TestParsePatientImportRow(t *testing.T) {
+	t.Parallel()
+
+	columns, lineErrors := ResolvePatientImportColumns(
+		[]string{"name", "nik", "sex", "date of birth", "phone_number", "last_visit_date"}, nil)
+	if len(lineErrors) > 0 {
+		t.Fatalf("ResolvePatientImportColumns() errors = %v", lineErrors)
+	}
+	today := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	row, errs := ParsePatientImportRow(2, []string{"Budi", "3171 0123 4567 8901", "L", "05/03/1990", "81234567890", "01/10/2026"}, columns, DateOrderDMY, today)
+	if len(errs) > 0 {
+		t.Fatalf("ParsePatientImportRow() errors = %v", errs)
+	}
+	if row.NIK != "3171012345678901" || row.Sex != "male" || row.PhoneNumber != "081234567890" {
+		t.Fatalf("ParsePatientImportRow() = %+v", row)
+	}
+	if !row.LastVisitDate.Valid || !row.LastVisitDate.Time.Equal(time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ParsePatientImportRow() last visit = %v", row.LastVisitDate)
+	}
+
+	_, errs = ParsePatientImportRow(3, []string{"", "3.17101E+15", "X", "20/10/2026", "", "01/01/1980"}, columns, DateOrderDMY, today)
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Column)
+	}
+	want := []string{"name", "sex", "nik", "date of birth"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParsePatientImportRow() error columns = %v, want %v", got, want)
+	}
+}
+
+func TestBuildPatientImportReport(t *testing.T) {
+	t.Parallel()
+
+	rows := [][]string{
+		{"name", "sex"},
+		{"Budi", "L"},
+		{"", "X"},
+		{"Siti", "P"},
+	}
+	report := BuildPatientImportReport(rows, []PatientImportLineError{
+		{Line: 4, Message: "looks like patient RM-2026-000001, Siti"},
+		{Line: 3, Column: "name", Message: "is required"},
+		{Line: 3, Column: "sex", Message: "must be L or P, or male or female"},
+	})
+
+	want := []PatientImportReportLine{
+		{Line: 3, Problems: "name: is required; sex: must be L or P, or male or female", Record: []string{"", "X"}},
+		{Line: 4, Problems: "looks like patient RM-2026-000001, Siti", Record: []string{"Siti", "P"}},
+	}
+	if !reflect.DeepEqual(report.Header, rows[0]) || !reflect.DeepEqual(report.Lines, want) {
+		t.Fatalf("BuildPatientImportReport() = %+v, want %+v", report, want)
+	}
+}
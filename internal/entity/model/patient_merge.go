@@ -0,0 +1,255 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	TrxPatientMergeTableName = "mdl_trx_patient_merge"
+
+	// PatientDuplicateMinScore is the score from which a patient is reported as
+	// a possible duplicate of another.
+	PatientDuplicateMinScore = 0.5
+	// PatientDuplicateLimit caps the candidates returned for one patient.
+	PatientDuplicateLimit = 10
+)
+
+// Reasons a patient was scored as a possible duplicate.
+const (
+	DuplicateReasonNIK                = "nik"
+	DuplicateReasonSimilarNIK         = "similar_nik"
+	DuplicateReasonName               = "name"
+	DuplicateReasonDateOfBirth        = "date_of_birth"
+	DuplicateReasonSwappedDateOfBirth = "swapped_date_of_birth"
+	DuplicateReasonPhoneNumber        = "phone_number"
+)
+
+// Weights of the signals that add up to a duplicate score. A matching NIK
+// scores 1 on its own.
+const (
+	duplicateWeightName               = 0.45
+	duplicateWeightDateOfBirth        = 0.35
+	duplicateWeightSwappedDateOfBirth = 0.2
+	duplicateWeightPhoneNumber        = 0.2
+	duplicateWeightSimilarNIK         = 0.2
+
+	// duplicateMinNameSimilarity is the name similarity below which names are
+	// treated as unrelated.
+	duplicateMinNameSimilarity = 0.3
+	// duplicateMinPhoneDigits keeps short or placeholder numbers from matching.
+	duplicateMinPhoneDigits = 8
+)
+
+// PatientDuplicateCandidate is a patient that may be the same person as the
+// one being looked at, with the score and the signals behind it.
+type PatientDuplicateCandidate struct {
+	GetPatientResponse
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// ScorePatientDuplicate scores how likely two patient records are the same
+// person, from 0 to 1, and names the signals that matched.
+func ScorePatientDuplicate(a, b MstPatientInstitution) (score float64, reasons []string) {
+	nikA, nikB := NormaliseNIK(a.NIK), NormaliseNIK(b.NIK)
+	if nikA != "" && nikA == nikB {
+		return 1, []string{DuplicateReasonNIK}
+	}
+	if len(nikA) >= 10 && len(nikB) >= 10 && editDistance(nikA, nikB) <= 2 {
+		score += duplicateWeightSimilarNIK
+		reasons = append(reasons, DuplicateReasonSimilarNIK)
+	}
+
+	if similarity := NameSimilarity(a.Name, b.Name); similarity >= duplicateMinNameSimilarity {
+		score += duplicateWeightName * similarity
+		reasons = append(reasons, DuplicateReasonName)
+	}
+
+	if !a.DateOfBirth.IsZero() && !b.DateOfBirth.IsZero() {
+		yearA, monthA, dayA := a.DateOfBirth.Date()
+		yearB, monthB, dayB := b.DateOfBirth.Date()
+		switch {
+		case yearA == yearB && monthA == monthB && dayA == dayB:
+			score += duplicateWeightDateOfBirth
+			reasons = append(reasons, DuplicateReasonDateOfBirth)
+		case yearA == yearB && int(monthA) == dayB && dayA == int(monthB):
+			score += duplicateWeightSwappedDateOfBirth
+			reasons = append(reasons, DuplicateReasonSwappedDateOfBirth)
+		}
+	}
+
+	phoneA, phoneB := NormalisePhoneNumber(a.PhoneNumber), NormalisePhoneNumber(b.PhoneNumber)
+	if len(phoneA) >= duplicateMinPhoneDigits && phoneA == phoneB {
+		score += duplicateWeightPhoneNumber
+		reasons = append(reasons, DuplicateReasonPhoneNumber)
+	}
+
+	return math.Min(1, math.Round(score*100)/100), reasons
+}
+
+// NormaliseNIK keeps only the digits of a NIK.
+func NormaliseNIK(nik string) string {
+	return digitsOnly(nik)
+}
+
+// NormalisePhoneNumber reduces a phone number to its digits in the local 08…
+// form, so +62 812…, 62812… and 0812… compare equal.
+func NormalisePhoneNumber(phone string) string {
+	digits := digitsOnly(phone)
+	switch {
+	case strings.HasPrefix(digits, "62"):
+		return "0" + digits[2:]
+	case strings.HasPrefix(digits, "8"):
+		return "0" + digits
+	}
+	return digits
+}
+
+// NameSimilarity compares two names by their word trigrams the way pg_trgm's
+// similarity() does: shared trigrams over all distinct trigrams.
+func NameSimilarity(a, b string) float64 {
+	trigramsA, trigramsB := nameTrigrams(a), nameTrigrams(b)
+	if len(trigramsA) == 0 || len(trigramsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range trigramsA {
+		if _, ok := trigramsB[trigram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(trigramsA)+len(trigramsB)-shared)
+}
+
+// NameSearchTokens returns the lower-cased words of a name worth searching
+// for: those of at least three letters.
+func NameSearchTokens(name string) []string {
+	var tokens []string
+	for _, word := range nameWords(name) {
+		if len([]rune(word)) >= 3 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func nameTrigrams(name string) map[string]struct{} {
+	trigrams := make(map[string]struct{})
+	for _, word := range nameWords(name) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigrams[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return trigrams
+}
+
+func digitsOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// editDistance is the Levenshtein distance between two ASCII strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, v := range rest {
+		if v < first {
+			first = v
+		}
+	}
+	return first
+}
+
+// PatientMergeUndo records what a merge re-pointed from the merged patient to
+// the survivor, so undoing it moves exactly those rows back.
+type PatientMergeUndo struct {
+	VisitIDs         []int64                     `json:"visit_ids"`
+	RecallIDs        []int64                     `json:"recall_ids"`
+	LedgerEntryIDs   []int64                     `json:"ledger_entry_ids"`
+	AttachmentIDs    []int64                     `json:"attachment_ids"`
+	OdontogramEvents []PatientMergeOdontogramRow `json:"odontogram_events"`
+	// RelationIDs are the merged patient's relations; RelatedRelationIDs are
+	// the relations of other patients to the merged patient.
+	RelationIDs        []int64 `json:"relation_ids"`
+	RelatedRelationIDs []int64 `json:"related_relation_ids"`
+	PayerIDs           []int64 `json:"payer_ids"`
+}
+
+// PatientMergeOdontogramRow is an odontogram event moved by a merge, with the
+// sequence number it had under the merged patient.
+type PatientMergeOdontogramRow struct {
+	EventID        string `xorm:"'event_id'" json:"event_id"`
+	SequenceNumber int64  `xorm:"'sequence_number'" json:"sequence_number"`
+}
+
+// TrxPatientMerge is the audit record of a merge of one patient into another.
+type TrxPatientMerge struct {
+	ID                   int64           `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution     int64           `xorm:"'id_mst_institution'" json:"-"`
+	SurvivorIDMstPatient int64           `xorm:"'survivor_id_mst_patient'" json:"-"`
+	MergedIDMstPatient   int64           `xorm:"'merged_id_mst_patient'" json:"-"`
+	Reason               string          `xorm:"'reason'" json:"reason"`
+	UndoData             json.RawMessage `xorm:"'undo_data' jsonb notnull" json:"undo_data"`
+	MergedBy             string          `xorm:"'merged_by'" json:"merged_by"`
+	CreateTime           time.Time       `xorm:"'create_time' created" json:"create_time"`
+	UndoneBy             *string         `xorm:"'undone_by'" json:"undone_by"`
+	UndoTime             *time.Time      `xorm:"'undo_time'" json:"undo_time"`
+}
+
+// PatientMergeRow is a merge with both patients' UUIDs and names.
+type PatientMergeRow struct {
+	TrxPatientMerge `xorm:"extends"`
+	SurvivorUUID    string `xorm:"'survivor_uuid'" json:"survivor_uuid"`
+	SurvivorName    string `xorm:"'survivor_name'" json:"survivor_name"`
+	MergedUUID      string `xorm:"'merged_uuid'" json:"merged_uuid"`
+	MergedName      string `xorm:"'merged_name'" json:"merged_name"`
+}
+
+// MergePatientsRequest folds MergedUUID into SurvivorUUID: the merged patient's
+// visits, recalls, ledger and odontogram move to the survivor and the merged
+// patient is removed.
+type MergePatientsRequest struct {
+	SurvivorUUID string `json:"survivor_uuid" validate:"required"`
+	MergedUUID   string `json:"merged_uuid" validate:"required,nefield=SurvivorUUID"`
+	Reason       string `json:"reason" validate:"max=500"`
+}
+
+type ListPatientMergeParams struct {
+	PatientUUID      string `schema:"patient_uuid"`
+	IDMstInstitution int64  `schema:"-"`
+	CommonRequestPayload
+}
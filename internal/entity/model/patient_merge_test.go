@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScorePatientDuplicate(t *testing.T) {
+	t.Parallel()
+
+	dob := time.Date(1990, time.March, 7, 0, 0, 0, 0, time.UTC)
+	base := MstPatientInstitution{
+		NIK:         "3174010703900001",
+		Name:        "Budi Santoso",
+		DateOfBirth: dob,
+		PhoneNumber: "0812-3456-7890",
+	}
+
+	cases := []struct {
+		name      string
+		other     MstPatientInstitution
+		duplicate bool
+		reason    string
+	}{
+		{
+			name:      "same nik",
+			other:     MstPatientInstitution{NIK: "3174 0107 0390 0001", Name: "B. Santoso"},
+			duplicate: true,
+			reason:    DuplicateReasonNIK,
+		},
+		{
+			name:      "blank nik, same name and date of birth",
+			other:     MstPatientInstitution{Name: "budi santoso", DateOfBirth: dob},
+			duplicate: true,
+			reason:    DuplicateReasonDateOfBirth,
+		},
+		{
+			name:      "mistyped nik and similar name",
+			other:     MstPatientInstitution{NIK: "3174010703900010", Name: "Budi Santosa"},
+			duplicate: true,
+			reason:    DuplicateReasonSimilarNIK,
+		},
+		{
+			name:      "day and month swapped, same phone",
+			other:     MstPatientInstitution{Name: "Budi", DateOfBirth: time.Date(1990, time.July, 3, 0, 0, 0, 0, time.UTC), PhoneNumber: "+62 812 3456 7890"},
+			duplicate: true,
+			reason:    DuplicateReasonPhoneNumber,
+		},
+		{
+			name:  "same name only",
+			other: MstPatientInstitution{Name: "Budi Santoso", DateOfBirth: dob.AddDate(5, 0, 0)},
+		},
+		{
+			name:  "unrelated",
+			other: MstPatientInstitution{NIK: "3273015505850002", Name: "Siti Aminah", DateOfBirth: dob.AddDate(-5, 0, 0)},
+		},
+	}
+	for _, c := range cases {
+		score, reasons := ScorePatientDuplicate(base, c.other)
+		if got := score >= PatientDuplicateMinScore; got != c.duplicate {
+			t.Fatalf("%s: score %v (reasons %v), want duplicate %v", c.name, score, reasons, c.duplicate)
+		}
+		if c.reason == "" {
+			continue
+		}
+		found := false
+		for _, reason := range reasons {
+			found = found || reason == c.reason
+		}
+		if !found {
+			t.Fatalf("%s: reasons %v, want %q among them", c.name, reasons, c.reason)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	t.Parallel()
+
+	if got := NameSimilarity("Budi Santoso", "SANTOSO, budi"); got != 1 {
+		t.Fatalf("reordered name similarity = %v, want 1", got)
+	}
+	if got := NameSimilarity("Budi", ""); got != 0 {
+		t.Fatalf("similarity to a blank name = %v, want 0", got)
+	}
+	if near, far := NameSimilarity("Muhammad Rizki", "Muhamad Rizky"), NameSimilarity("Muhammad Rizki", "Dewi Lestari"); near <= far {
+		t.Fatalf("near spelling scored %v, not above unrelated name %v", near, far)
+	}
+}
+
+func TestNormalisePhoneNumber(t *testing.T) {
+	t.Parallel()
+
+	for _, phone := range []string{"0812-3456-7890", "+62 812 3456 7890", "6281234567890", "812 3456 7890"} {
+		if got := NormalisePhoneNumber(phone); got != "081234567890" {
+			t.Fatalf("NormalisePhoneNumber(%q) = %q, want 081234567890", phone, got)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	customtime "github.com/faisalhardin/medilink/pkg/type/time"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	MstPatientPayerTableName = "mdl_mst_patient_payer"
+)
+
+// Payers a visit can be billed to besides the patient.
+const (
+	PayerTypeBPJS      = "bpjs"
+	PayerTypeInsurance = "insurance"
+	PayerTypeCorporate = "corporate"
+)
+
+// Results of the last eligibility check of a payer.
+const (
+	EligibilityStatusUnchecked  = "unchecked"
+	EligibilityStatusEligible   = "eligible"
+	EligibilityStatusIneligible = "ineligible"
+)
+
+// MstPatientPayer is a BPJS Kesehatan membership, insurance policy or
+// corporate arrangement of a patient. CoverageRate is the share of a visit's
+// invoice the payer takes, up to CoverageLimit per visit when set. The
+// patient's default payer is proposed for their new visits.
+type MstPatientPayer struct {
+	ID                   int64           `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution     int64           `xorm:"'id_mst_institution'" json:"-"`
+	IDMstPatient         int64           `xorm:"'id_mst_patient'" json:"-"`
+	PayerType            string          `xorm:"'payer_type'" json:"payer_type"`
+	MemberNumber         string          `xorm:"'member_number'" json:"member_number"`
+	BPJSClass            null.String     `xorm:"'bpjs_class'" json:"bpjs_class"`
+	FKTPCode             string          `xorm:"'fktp_code'" json:"fktp_code"`
+	FKTPName             string          `xorm:"'fktp_name'" json:"fktp_name"`
+	InsurerName          string          `xorm:"'insurer_name'" json:"insurer_name"`
+	PolicyNumber         string          `xorm:"'policy_number'" json:"policy_number"`
+	PolicyHolder         string          `xorm:"'policy_holder'" json:"policy_holder"`
+	CoverageRate         float64         `xorm:"'coverage_rate'" json:"coverage_rate"`
+	CoverageLimit        money.NullMoney `xorm:"'coverage_limit'" json:"coverage_limit"`
+	ValidFrom            *time.Time      `xorm:"'valid_from'" json:"valid_from"`
+	ValidTo              *time.Time      `xorm:"'valid_to'" json:"valid_to"`
+	IsDefault            bool            `xorm:"'is_default'" json:"is_default"`
+	EligibilityStatus    string          `xorm:"'eligibility_status'" json:"eligibility_status"`
+	EligibilityMessage   string          `xorm:"'eligibility_message'" json:"eligibility_message"`
+	EligibilityCheckTime null.Time       `xorm:"'eligibility_check_time'" json:"eligibility_check_time"`
+	Notes                string          `xorm:"'notes'" json:"notes"`
+	UpdatedBy            string          `xorm:"'updated_by'" json:"-"`
+	CreateTime           time.Time       `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime           time.Time       `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime           *time.Time      `xorm:"'delete_time' deleted" json:"-"`
+}
+
+func (MstPatientPayer) TableName() string {
+	return MstPatientPayerTableName
+}
+
+// PatientCategory is the category the payer's visits are priced with.
+func (p MstPatientPayer) PatientCategory() string {
+	switch p.PayerType {
+	case PayerTypeBPJS:
+		return PatientCategoryBPJS
+	case PayerTypeCorporate:
+		return PatientCategoryCorporate
+	default:
+		return PatientCategoryInsurance
+	}
+}
+
+// SavePatientPayerRequest adds or replaces a payer of a patient. BPJSClass
+// and the FKTP, the primary care facility the member is registered with, only
+// apply to BPJS; InsurerName is required for insurance. CoverageRate defaults
+// to the whole invoice.
+type SavePatientPayerRequest struct {
+	PatientUUID   string           `json:"-"`
+	ID            int64            `json:"-"`
+	PayerType     string           `json:"payer_type" validate:"required,oneof=bpjs insurance corporate"`
+	MemberNumber  string           `json:"member_number" validate:"required,max=50"`
+	BPJSClass     string           `json:"bpjs_class" validate:"omitempty,oneof=1 2 3"`
+	FKTPCode      string           `json:"fktp_code" validate:"max=20"`
+	FKTPName      string           `json:"fktp_name" validate:"max=255"`
+	InsurerName   string           `json:"insurer_name" validate:"max=255"`
+	PolicyNumber  string           `json:"policy_number" validate:"max=50"`
+	PolicyHolder  string           `json:"policy_holder" validate:"max=255"`
+	CoverageRate  *float64         `json:"coverage_rate" validate:"omitempty,gte=0,lte=1"`
+	CoverageLimit money.NullMoney  `json:"coverage_limit"`
+	ValidFrom     *customtime.Time `json:"valid_from"`
+	ValidTo       *customtime.Time `json:"valid_to"`
+	IsDefault     bool             `json:"is_default"`
+	Notes         string           `json:"notes"`
+}
+
+// CheckPatientPayerEligibilityRequest asks whether a payer covers the patient
+// on ServiceDate, today by default.
+type CheckPatientPayerEligibilityRequest struct {
+	PatientUUID string          `json:"-"`
+	ID          int64           `json:"-"`
+	ServiceDate customtime.Time `json:"service_date"`
+}
+
+// SelectVisitPayerRequest bills a visit to one of the patient's payers, or to
+// the patient alone when PayerID is zero.
+type SelectVisitPayerRequest struct {
+	IDTrxPatientVisit int64 `json:"-"`
+	PayerID           int64 `json:"payer_id"`
+}
+
+// SelectPayer bills visit to payer: the payer's category prices the visit and
+// its coverage is copied onto it, so later changes to the payer do not
+// resplit the visit.
+func (visit *TrxPatientVisit) SelectPayer(payer MstPatientPayer) {
+	visit.IDMstPatientPayer = null.Int64From(payer.ID)
+	visit.PayerType = payer.PayerType
+	visit.PayerCoverageRate = payer.CoverageRate
+	visit.PayerCoverageLimit = payer.CoverageLimit
+	visit.PatientCategory = payer.PatientCategory()
+}
+
+// ClearPayer bills visit to the patient alone, priced with category.
+func (visit *TrxPatientVisit) ClearPayer(category string) {
+	visit.IDMstPatientPayer = null.Int64{}
+	visit.PayerType = ""
+	visit.PayerCoverageRate = 0
+	visit.PayerCoverageLimit = money.NullMoney{}
+	visit.PatientCategory = NormalisePatientCategory(category)
+}
+
+// VisitInvoiceSplit divides the invoice of a visit between its payer and the
+// patient.
+type VisitInvoiceSplit struct {
+	PayerType    string      `json:"payer_type"`
+	PayerShare   money.Money `json:"payer_share"`
+	PatientShare money.Money `json:"patient_share"`
+}
+
+// NewVisitInvoiceSplit charges the payer of visit its coverage rate of total,
+// up to its coverage limit, and the patient the rest. A visit without a payer
+// is the patient's to pay.
+func NewVisitInvoiceSplit(visit TrxPatientVisit, total money.Money) VisitInvoiceSplit {
+	split := VisitInvoiceSplit{
+		PayerType:    visit.PayerType,
+		PayerShare:   money.Zero,
+		PatientShare: total,
+	}
+	if !visit.IDMstPatientPayer.Valid || !total.IsPositive() {
+		return split
+	}
+
+	payerShare := total.MulRate(visit.PayerCoverageRate).Round()
+	if visit.PayerCoverageLimit.Valid {
+		payerShare = money.Min(payerShare, visit.PayerCoverageLimit.Money)
+	}
+	split.PayerShare = money.Min(payerShare, total)
+	split.PatientShare = total.Sub(split.PayerShare)
+	return split
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestNewVisitInvoiceSplit(t *testing.T) {
+	t.Parallel()
+
+	visitWith := func(payer MstPatientPayer) TrxPatientVisit {
+		visit := TrxPatientVisit{}
+		visit.SelectPayer(payer)
+		return visit
+	}
+	tests := []struct {
+		name         string
+		visit        TrxPatientVisit
+		total        money.Money
+		payerShare   string
+		patientShare string
+	}{
+		{"no payer", TrxPatientVisit{}, money.New(150000), "0", "150000"},
+		{"bpjs covers all", visitWith(MstPatientPayer{ID: 1, PayerType: PayerTypeBPJS, CoverageRate: 1}), money.New(150000), "150000", "0"},
+		{"insurance co-pay", visitWith(MstPatientPayer{ID: 2, PayerType: PayerTypeInsurance, CoverageRate: 0.8}), money.New(150000), "120000", "30000"},
+		{"capped by limit", visitWith(MstPatientPayer{ID: 3, PayerType: PayerTypeCorporate, CoverageRate: 1, CoverageLimit: money.NullMoneyFrom(money.New(100000))}), money.New(150000), "100000", "50000"},
+		{"empty invoice", visitWith(MstPatientPayer{ID: 4, PayerType: PayerTypeBPJS, CoverageRate: 1}), money.Zero, "0", "0"},
+	}
+	for _, tt := range tests {
+		got := NewVisitInvoiceSplit(tt.visit, tt.total)
+		if got.PayerShare.String() != tt.payerShare || got.PatientShare.String() != tt.patientShare {
+			t.Fatalf("%s: NewVisitInvoiceSplit() = payer %s patient %s, want %s and %s",
+				tt.name, got.PayerShare, got.PatientShare, tt.payerShare, tt.patientShare)
+		}
+	}
+}
+
+func TestTrxPatientVisitSelectPayer(t *testing.T) {
+	t.Parallel()
+
+	for payerType, want := range map[string]string{
+		PayerTypeBPJS:      PatientCategoryBPJS,
+		PayerTypeInsurance: PatientCategoryInsurance,
+		PayerTypeCorporate: PatientCategoryCorporate,
+	} {
+		visit := TrxPatientVisit{PatientCategory: PatientCategoryGeneral}
+		visit.SelectPayer(MstPatientPayer{ID: 7, PayerType: payerType, CoverageRate: 1})
+		if visit.PatientCategory != want || visit.IDMstPatientPayer.Int64 != 7 || visit.PayerType != payerType {
+			t.Fatalf("SelectPayer(%s) = %+v, want category %s", payerType, visit, want)
+		}
+	}
+
+	visit := TrxPatientVisit{}
+	visit.SelectPayer(MstPatientPayer{ID: 7, PayerType: PayerTypeBPJS, CoverageRate: 1})
+	visit.ClearPayer("")
+	if visit.IDMstPatientPayer.Valid || visit.PayerType != "" || visit.PatientCategory != PatientCategoryGeneral {
+		t.Fatalf("ClearPayer() = %+v", visit)
+	}
+}
+
+func TestVisitPaymentSummaryWithInvoiceSplit(t *testing.T) {
+	t.Parallel()
+
+	totals := VisitTotals{GrandTotal: money.New(150000)}
+	split := VisitInvoiceSplit{PayerShare: money.New(120000), PatientShare: money.New(30000)}
+
+	unpaid := NewVisitPaymentSummary(totals, nil, nil).WithInvoiceSplit(split)
+	if unpaid.IsPaid || unpaid.Outstanding.String() != "30000" {
+		t.Fatalf("expected the patient share outstanding, got %s paid %v", unpaid.Outstanding, unpaid.IsPaid)
+	}
+
+	paid := NewVisitPaymentSummary(totals, []TrxVisitPayment{{Amount: money.New(30000)}}, nil).WithInvoiceSplit(split)
+	if !paid.IsPaid || !paid.Outstanding.IsZero() {
+		t.Fatalf("expected the co-pay to settle the visit, outstanding %s", paid.Outstanding)
+	}
+
+	covered := NewVisitPaymentSummary(totals, nil, nil).
+		WithInvoiceSplit(VisitInvoiceSplit{PayerShare: money.New(150000), PatientShare: money.Zero})
+	if !covered.IsPaid {
+		t.Fatalf("expected a fully covered visit to count as paid")
+	}
+}
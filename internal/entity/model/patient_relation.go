@@ -0,0 +1,109 @@
+package model
+
+import (
+	"time"
+
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	MstPatientRelationTableName = "mdl_mst_patient_relation"
+
+	PatientRelationshipParent           = "parent"
+	PatientRelationshipSpouse           = "spouse"
+	PatientRelationshipGuardian         = "guardian"
+	PatientRelationshipEmergencyContact = "emergency_contact"
+
+	// RecallContactSelf is the relationship of a recall contact that is the
+	// patient themself.
+	RecallContactSelf = "self"
+)
+
+// MstPatientRelation relates a patient to a person responsible for or close
+// to them: either another patient of the institution, RelatedPatientID, or a
+// person who is not a patient, described by the name and contact columns.
+// The relation with IsRecallContact set is who recalls go to.
+type MstPatientRelation struct {
+	ID               int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64      `xorm:"'id_mst_institution'" json:"-"`
+	IDMstPatient     int64      `xorm:"'id_mst_patient'" json:"-"`
+	RelatedPatientID null.Int64 `xorm:"'id_mst_patient_related'" json:"-"`
+	Relationship     string     `xorm:"'relationship'" json:"relationship"`
+	Name             string     `xorm:"'name'" json:"name"`
+	NIK              string     `xorm:"'nik'" json:"nik"`
+	Sex              string     `xorm:"'sex'" json:"sex"`
+	PhoneNumber      string     `xorm:"'phone_number'" json:"phone_number"`
+	Address          string     `xorm:"'address'" json:"address"`
+	IsRecallContact  bool       `xorm:"'is_recall_contact'" json:"is_recall_contact"`
+	Notes            string     `xorm:"'notes'" json:"notes"`
+	UpdatedBy        string     `xorm:"'updated_by'" json:"-"`
+	CreateTime       time.Time  `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime       time.Time  `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime       *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+func (MstPatientRelation) TableName() string {
+	return MstPatientRelationTableName
+}
+
+// PatientRelationResponse is a relation with the person's details taken from
+// the related patient when there is one.
+type PatientRelationResponse struct {
+	ID                 int64       `xorm:"'id'" json:"id"`
+	IDMstPatient       int64       `xorm:"'id_mst_patient'" json:"-"`
+	Relationship       string      `xorm:"'relationship'" json:"relationship"`
+	RelatedPatientUUID null.String `xorm:"'related_patient_uuid'" json:"related_patient_uuid"`
+	Name               string      `xorm:"'name'" json:"name"`
+	NIK                string      `xorm:"'nik'" json:"nik"`
+	Sex                string      `xorm:"'sex'" json:"sex"`
+	DateOfBirth        null.Time   `xorm:"'date_of_birth'" json:"date_of_birth"`
+	PhoneNumber        string      `xorm:"'phone_number'" json:"phone_number"`
+	Address            string      `xorm:"'address'" json:"address"`
+	IsRecallContact    bool        `xorm:"'is_recall_contact'" json:"is_recall_contact"`
+	Notes              string      `xorm:"'notes'" json:"notes"`
+	UpdateTime         time.Time   `xorm:"'update_time'" json:"update_time"`
+}
+
+// SavePatientRelationRequest adds or replaces a relation of a patient. Give
+// RelatedPatientUUID for a person registered as a patient; the person's
+// details then follow their patient record. Otherwise Name is required.
+type SavePatientRelationRequest struct {
+	PatientUUID        string `json:"-"`
+	ID                 int64  `json:"-"`
+	Relationship       string `json:"relationship" validate:"required,oneof=parent spouse guardian emergency_contact"`
+	RelatedPatientUUID string `json:"related_patient_uuid"`
+	Name               string `json:"name" validate:"required_without=RelatedPatientUUID,max=255"`
+	NIK                string `json:"nik"`
+	Sex                string `json:"sex" validate:"omitempty,oneof=male female"`
+	PhoneNumber        string `json:"phone_number" validate:"max=30"`
+	Address            string `json:"address"`
+	IsRecallContact    bool   `json:"is_recall_contact"`
+	Notes              string `json:"notes"`
+}
+
+// RecallContact is who to reach about a recall: the relation marked as the
+// patient's recall contact, or else the patient.
+type RecallContact struct {
+	Name         string `json:"name"`
+	PhoneNumber  string `json:"phone_number"`
+	Relationship string `json:"relationship"`
+}
+
+// RecallContactOf picks the recall contact of a patient from their relations.
+// A contact without a phone number is passed over for the patient's own.
+func RecallContactOf(patient MstPatientInstitution, relations []PatientRelationResponse) RecallContact {
+	for _, relation := range relations {
+		if relation.IsRecallContact && relation.PhoneNumber != "" {
+			return RecallContact{
+				Name:         relation.Name,
+				PhoneNumber:  relation.PhoneNumber,
+				Relationship: relation.Relationship,
+			}
+		}
+	}
+	return RecallContact{
+		Name:         patient.Name,
+		PhoneNumber:  patient.PhoneNumber,
+		Relationship: RecallContactSelf,
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import "testing"
+
+func TestRecallContactOf(t *testing.T) {
+	t.Parallel()
+
+	patient := MstPatientInstitution{Name: "Budi", PhoneNumber: "0812000001"}
+	guardian := PatientRelationResponse{Relationship: PatientRelationshipGuardian, Name: "Siti", PhoneNumber: "0812000002", IsRecallContact: true}
+	spouse := PatientRelationResponse{Relationship: PatientRelationshipSpouse, Name: "Ani", PhoneNumber: "0812000003"}
+	noPhone := PatientRelationResponse{Relationship: PatientRelationshipParent, Name: "Joko", IsRecallContact: true}
+
+	tests := []struct {
+		name      string
+		relations []PatientRelationResponse
+		want      RecallContact
+	}{
+		{"no relations", nil, RecallContact{"Budi", "0812000001", RecallContactSelf}},
+		{"no recall contact", []PatientRelationResponse{spouse}, RecallContact{"Budi", "0812000001", RecallContactSelf}},
+		{"recall contact", []PatientRelationResponse{spouse, guardian}, RecallContact{"Siti", "0812000002", PatientRelationshipGuardian}},
+		{"recall contact without a phone", []PatientRelationResponse{noPhone}, RecallContact{"Budi", "0812000001", RecallContactSelf}},
+	}
+	for _, tt := range tests {
+		if got := RecallContactOf(patient, tt.relations); got != tt.want {
+			t.Fatalf("%s: RecallContactOf() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
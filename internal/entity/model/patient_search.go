@@ -0,0 +1,60 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// PatientSearchMinLength is the shortest query searched: pg_trgm takes no
+	// trigrams from a shorter one, so it could not use the search indexes.
+	PatientSearchMinLength = 3
+
+	// patientSearchMinDigits keeps a short number in a query from matching
+	// every NIK and phone number that happens to contain it.
+	patientSearchMinDigits = 4
+)
+
+// PatientSearchTerms is a patient search query split into what each field is
+// matched against.
+type PatientSearchTerms struct {
+	// Text is the trimmed query, matched against the name and the No. RM.
+	Text string
+	// Digits are the query's digits when it is a number, matched against NIKs.
+	Digits string
+	// PhoneDigits are Digits without the 0 or 62 trunk prefix, matched against
+	// phone numbers however they were written.
+	PhoneDigits string
+}
+
+// TooShort reports whether the query is shorter than PatientSearchMinLength.
+func (t PatientSearchTerms) TooShort() bool {
+	return utf8.RuneCountInString(t.Text) < PatientSearchMinLength
+}
+
+// NewPatientSearchTerms reads a search query. A query with letters in it is a
+// name or No. RM only; one of at least four digits, with any spaces, dashes or
+// plus sign, is also looked up as a NIK and a phone number.
+func NewPatientSearchTerms(query string) PatientSearchTerms {
+	terms := PatientSearchTerms{Text: strings.Join(strings.Fields(query), " ")}
+	if strings.IndexFunc(terms.Text, unicode.IsLetter) >= 0 {
+		return terms
+	}
+
+	digits := digitsOnly(terms.Text)
+	if len(digits) < patientSearchMinDigits {
+		return terms
+	}
+	terms.Digits = digits
+
+	switch {
+	case strings.HasPrefix(digits, "62"):
+		terms.PhoneDigits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		terms.PhoneDigits = digits[1:]
+	default:
+		terms.PhoneDigits = digits
+	}
+	return terms
+}
@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestNewPatientSearchTerms(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		query string
+		want  PatientSearchTerms
+	}{
+		{query: "  Muhamad   Rizki ", want: PatientSearchTerms{Text: "Muhamad Rizki"}},
+		{query: "RM-2026-000123", want: PatientSearchTerms{Text: "RM-2026-000123"}},
+		{query: "3174", want: PatientSearchTerms{Text: "3174", Digits: "3174", PhoneDigits: "3174"}},
+		{query: "+62 812-3456", want: PatientSearchTerms{Text: "+62 812-3456", Digits: "628123456", PhoneDigits: "8123456"}},
+		{query: "0812 3456", want: PatientSearchTerms{Text: "0812 3456", Digits: "08123456", PhoneDigits: "8123456"}},
+		{query: "123", want: PatientSearchTerms{Text: "123"}},
+	}
+	for _, c := range cases {
+		if got := NewPatientSearchTerms(c.query); got != c.want {
+			t.Fatalf("NewPatientSearchTerms(%q) = %+v, want %+v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestPatientSearchTermsTooShort(t *testing.T) {
+	t.Parallel()
+
+	for query, want := range map[string]bool{"Al": true, " a  b ": false, "Ali": false, "Çé": true, "12": true, "123": false} {
+		if got := NewPatientSearchTerms(query).TooShort(); got != want {
+			t.Fatalf("NewPatientSearchTerms(%q).TooShort() = %v, want %v", query, got, want)
+		}
+	}
+}
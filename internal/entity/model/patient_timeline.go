@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	customtime "github.com/faisalhardin/medilink/pkg/type/time"
+	"github.com/volatiletech/null/v8"
+)
+
+// Kinds of entries on a patient's clinical timeline.
+const (
+	TimelineKindVisit      = "visit"
+	TimelineKindTouchpoint = "touchpoint"
+	TimelineKindDiagnosis  = "diagnosis"
+	TimelineKindAnamnesa   = "anamnesa"
+	TimelineKindProcedure  = "procedure"
+	TimelineKindOdontogram = "odontogram"
+	TimelineKindProduct    = "product"
+	TimelineKindRecall     = "recall"
+
+	DefaultTimelineLimit = 50
+	MaxTimelineLimit     = 200
+)
+
+// TimelineKinds lists every timeline entry kind.
+var TimelineKinds = []string{
+	TimelineKindVisit,
+	TimelineKindTouchpoint,
+	TimelineKindDiagnosis,
+	TimelineKindAnamnesa,
+	TimelineKindProcedure,
+	TimelineKindOdontogram,
+	TimelineKindProduct,
+	TimelineKindRecall,
+}
+
+// IsValidTimelineKind reports whether kind is a known timeline entry kind.
+func IsValidTimelineKind(kind string) bool {
+	for _, k := range TimelineKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// TimelineEntry is one thing that happened to a patient. Detail holds the
+// kind-specific fields, e.g. the vital signs of an anamnesa.
+type TimelineEntry struct {
+	Kind       string          `xorm:"'kind'" json:"kind"`
+	ID         string          `xorm:"'entry_id'" json:"id"`
+	OccurredAt time.Time       `xorm:"'occurred_at'" json:"occurred_at"`
+	VisitID    null.Int64      `xorm:"'visit_id'" json:"visit_id"`
+	Title      string          `xorm:"'title'" json:"title"`
+	Detail     json.RawMessage `xorm:"'detail' jsonb" json:"detail"`
+}
+
+// GetPatientTimelineParams pages through a patient's timeline, newest first.
+// Cursor is the next_cursor of the previous page; Kinds and the time range
+// narrow the entries.
+type GetPatientTimelineParams struct {
+	PatientUUID string          `schema:"-"`
+	Kinds       []string        `schema:"kind"`
+	FromTime    customtime.Time `schema:"from_time"`
+	ToTime      customtime.Time `schema:"to_time"`
+	Cursor      string          `schema:"cursor"`
+	Limit       int             `schema:"limit" validate:"omitempty,min=0"`
+}
+
+// PatientTimeline is one page of a patient's timeline.
+type PatientTimeline struct {
+	Entries    []TimelineEntry `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// TimelineCursor is the position after the last entry of a page: entries are
+// ordered by time, then kind, then ID, all descending.
+type TimelineCursor struct {
+	OccurredAt time.Time
+	Kind       string
+	ID         string
+}
+
+var ErrInvalidTimelineCursor = errors.New("invalid timeline cursor")
+
+// NewTimelineCursor is the cursor continuing after entry.
+func NewTimelineCursor(entry TimelineEntry) TimelineCursor {
+	return TimelineCursor{OccurredAt: entry.OccurredAt, Kind: entry.Kind, ID: entry.ID}
+}
+
+// Encode renders the cursor as an opaque URL-safe token.
+func (c TimelineCursor) Encode() string {
+	raw := strings.Join([]string{c.OccurredAt.UTC().Format(time.RFC3339Nano), c.Kind, c.ID}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeTimelineCursor reads a token made by TimelineCursor.Encode.
+func DecodeTimelineCursor(token string) (cursor TimelineCursor, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, ErrInvalidTimelineCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || !IsValidTimelineKind(parts[1]) || parts[2] == "" {
+		return cursor, ErrInvalidTimelineCursor
+	}
+	occurredAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return cursor, ErrInvalidTimelineCursor
+	}
+	return TimelineCursor{OccurredAt: occurredAt, Kind: parts[1], ID: parts[2]}, nil
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimelineCursorRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	entry := TimelineEntry{
+		Kind:       TimelineKindOdontogram,
+		ID:         "6f1c2b1e-3c1a-4d5e-9f00-1a2b3c4d5e6f",
+		OccurredAt: time.Date(2026, time.October, 19, 9, 30, 15, 123456000, time.FixedZone("WIB", 7*3600)),
+	}
+
+	cursor, err := DecodeTimelineCursor(NewTimelineCursor(entry).Encode())
+	if err != nil {
+		t.Fatalf("decoding an encoded cursor: %v", err)
+	}
+	if !cursor.OccurredAt.Equal(entry.OccurredAt) || cursor.Kind != entry.Kind || cursor.ID != entry.ID {
+		t.Fatalf("round trip gave %+v, want the position of %+v", cursor, entry)
+	}
+
+	for _, token := range []string{"", "not base64!", TimelineCursor{OccurredAt: entry.OccurredAt, Kind: "invoice", ID: "1"}.Encode()} {
+		if _, err := DecodeTimelineCursor(token); err != ErrInvalidTimelineCursor {
+			t.Fatalf("DecodeTimelineCursor(%q) error = %v, want ErrInvalidTimelineCursor", token, err)
+		}
+	}
+}
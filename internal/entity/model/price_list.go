@@ -0,0 +1,171 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	customtime "github.com/faisalhardin/medilink/pkg/type/time"
+)
+
+const (
+	MstPriceListTableName           = "mdl_mst_price_list"
+	DtlPriceListItemTableName       = "mdl_dtl_price_list_item"
+	TrxProductPriceHistoryTableName = "mdl_trx_product_price_history"
+)
+
+// Patient categories select which price list applies to a visit.
+const (
+	PatientCategoryGeneral   = "general"
+	PatientCategoryBPJS      = "bpjs"
+	PatientCategoryInsurance = "insurance"
+	PatientCategoryCorporate = "corporate"
+	PatientCategoryStaff     = "staff"
+)
+
+// NormalisePatientCategory returns category, or general when it is empty.
+func NormalisePatientCategory(category string) string {
+	if category == "" {
+		return PatientCategoryGeneral
+	}
+	return category
+}
+
+// MstPriceList is an effective-dated price list of one patient category.
+// EffectiveTo is nil while the list is the latest one of its category.
+type MstPriceList struct {
+	ID               int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64      `xorm:"'id_mst_institution'" json:"-"`
+	Name             string     `xorm:"'name'" json:"name"`
+	PatientCategory  string     `xorm:"'patient_category'" json:"patient_category"`
+	EffectiveFrom    time.Time  `xorm:"'effective_from'" json:"effective_from"`
+	EffectiveTo      *time.Time `xorm:"'effective_to'" json:"effective_to"`
+	Notes            string     `xorm:"'notes'" json:"notes"`
+	CreatedBy        string     `xorm:"'created_by'" json:"created_by"`
+	CreateTime       time.Time  `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime       time.Time  `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime       *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// IsEffectiveAt reports whether the list prices sales made at t.
+func (p MstPriceList) IsEffectiveAt(t time.Time) bool {
+	if t.Before(p.EffectiveFrom) {
+		return false
+	}
+	return p.EffectiveTo == nil || t.Before(*p.EffectiveTo)
+}
+
+type DtlPriceListItem struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"-"`
+	IDMstPriceList          int64       `xorm:"'id_mst_price_list'" json:"-"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	Price                   money.Money `xorm:"'price'" json:"price"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"-"`
+	UpdateTime              time.Time   `xorm:"'update_time' updated" json:"-"`
+	DeleteTime              *time.Time  `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// PriceListItemRow is an item joined with its product name.
+type PriceListItemRow struct {
+	DtlPriceListItem `xorm:"extends"`
+	Name             string `xorm:"'name'" json:"name"`
+}
+
+// TrxProductPriceHistory records one change of a product's base price.
+type TrxProductPriceHistory struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution        int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	OldPrice                money.Money `xorm:"'old_price'" json:"old_price"`
+	NewPrice                money.Money `xorm:"'new_price'" json:"new_price"`
+	ChangedBy               string      `xorm:"'changed_by'" json:"changed_by"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"create_time"`
+}
+
+// ApplicablePrice is the price of a product on the price list effective at a point in time.
+type ApplicablePrice struct {
+	IDMstPriceList          int64       `xorm:"'id_mst_price_list'"`
+	PatientCategory         string      `xorm:"'patient_category'"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'"`
+	Price                   money.Money `xorm:"'price'"`
+}
+
+type PriceListItemRequest struct {
+	IDTrxInstitutionProduct int64       `json:"product_id" validate:"required"`
+	Price                   money.Money `json:"price" validate:"gte=0"`
+}
+
+// CreatePriceListRequest starts a new price list for a category. EffectiveFrom
+// defaults to now and must be later than every existing list of the category,
+// whose latest list is closed at EffectiveFrom.
+type CreatePriceListRequest struct {
+	Name            string                 `json:"name" validate:"required"`
+	PatientCategory string                 `json:"patient_category" validate:"required,oneof=general bpjs insurance corporate staff"`
+	EffectiveFrom   customtime.Time        `json:"effective_from"`
+	Notes           string                 `json:"notes"`
+	Items           []PriceListItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+// UpdatePriceListItemsRequest replaces the items of a list that is not yet effective.
+type UpdatePriceListItemsRequest struct {
+	Items []PriceListItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type ListPriceListParams struct {
+	PatientCategory  string          `schema:"patient_category" validate:"omitempty,oneof=general bpjs insurance corporate staff"`
+	EffectiveAt      customtime.Time `schema:"effective_at"`
+	IDMstInstitution int64           `schema:"-"`
+	CommonRequestPayload
+}
+
+type PriceListResponse struct {
+	MstPriceList
+	Items []PriceListItemRow `json:"items"`
+}
+
+type ProductPriceHistoryParams struct {
+	IDTrxInstitutionProduct int64 `schema:"product_id" validate:"required"`
+}
+
+// ProductPriceListEntry is the price of a product on one price list.
+type ProductPriceListEntry struct {
+	IDMstPriceList  int64       `xorm:"'id_mst_price_list'" json:"price_list_id"`
+	Name            string      `xorm:"'name'" json:"name"`
+	PatientCategory string      `xorm:"'patient_category'" json:"patient_category"`
+	EffectiveFrom   time.Time   `xorm:"'effective_from'" json:"effective_from"`
+	EffectiveTo     *time.Time  `xorm:"'effective_to'" json:"effective_to"`
+	Price           money.Money `xorm:"'price'" json:"price"`
+}
+
+type ProductPriceHistoryResponse struct {
+	IDTrxInstitutionProduct int64                    `json:"product_id"`
+	Price                   money.Money              `json:"price"`
+	BasePriceChanges        []TrxProductPriceHistory `json:"base_price_changes"`
+	PriceLists              []ProductPriceListEntry  `json:"price_lists"`
+}
+
+// SelectApplicablePrices picks, per product, the price of the given category's
+// list, falling back to the general list. Products on neither are absent.
+func SelectApplicablePrices(patientCategory string, prices []ApplicablePrice) map[int64]ApplicablePrice {
+	patientCategory = NormalisePatientCategory(patientCategory)
+	selected := make(map[int64]ApplicablePrice, len(prices))
+	for _, price := range prices {
+		current, ok := selected[price.IDTrxInstitutionProduct]
+		if ok && current.PatientCategory == patientCategory {
+			continue
+		}
+		if price.PatientCategory == patientCategory || price.PatientCategory == PatientCategoryGeneral {
+			selected[price.IDTrxInstitutionProduct] = price
+		}
+	}
+	return selected
+}
+
+// ApplicablePriceCategories returns the categories whose lists may price a
+// visit of the given category, in order of preference.
+func ApplicablePriceCategories(patientCategory string) []string {
+	patientCategory = NormalisePatientCategory(patientCategory)
+	if patientCategory == PatientCategoryGeneral {
+		return []string{PatientCategoryGeneral}
+	}
+	return []string{patientCategory, PatientCategoryGeneral}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestSelectApplicablePrices(t *testing.T) {
+	t.Parallel()
+
+	prices := []ApplicablePrice{
+		{IDMstPriceList: 1, PatientCategory: PatientCategoryGeneral, IDTrxInstitutionProduct: 10, Price: money.New(1000)},
+		{IDMstPriceList: 2, PatientCategory: PatientCategoryInsurance, IDTrxInstitutionProduct: 10, Price: money.New(1200)},
+		{IDMstPriceList: 1, PatientCategory: PatientCategoryGeneral, IDTrxInstitutionProduct: 11, Price: money.New(500)},
+		{IDMstPriceList: 3, PatientCategory: PatientCategoryStaff, IDTrxInstitutionProduct: 12, Price: money.New(100)},
+	}
+
+	got := SelectApplicablePrices(PatientCategoryInsurance, prices)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 products priced, got %d", len(got))
+	}
+	if got[10].IDMstPriceList != 2 || got[10].Price.String() != "1200" {
+		t.Fatalf("insurance list should win for product 10, got %+v", got[10])
+	}
+	if got[11].IDMstPriceList != 1 {
+		t.Fatalf("general list should price product 11, got %+v", got[11])
+	}
+	if _, ok := got[12]; ok {
+		t.Fatalf("staff list must not price an insurance visit")
+	}
+
+	got = SelectApplicablePrices("", prices)
+	if got[10].IDMstPriceList != 1 {
+		t.Fatalf("empty category should use the general list, got %+v", got[10])
+	}
+}
+
+func TestMstPriceListIsEffectiveAt(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 6, 0)
+	list := MstPriceList{EffectiveFrom: from, EffectiveTo: &to}
+
+	if list.IsEffectiveAt(from.Add(-time.Second)) {
+		t.Fatalf("list must not apply before effective_from")
+	}
+	if !list.IsEffectiveAt(from) {
+		t.Fatalf("list must apply at effective_from")
+	}
+	if list.IsEffectiveAt(to) {
+		t.Fatalf("list must not apply at effective_to")
+	}
+	list.EffectiveTo = nil
+	if !list.IsEffectiveAt(to.AddDate(5, 0, 0)) {
+		t.Fatalf("open list must apply indefinitely")
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	customtime "github.com/faisalhardin/medilink/pkg/type/time"
 	"github.com/volatiletech/null/v8"
 )
@@ -18,16 +19,27 @@ type MstProduct struct {
 }
 
 type TrxInstitutionProduct struct {
-	ID               int64      `xorm:"'id' pk autoincr" json:"id"`
-	Name             string     `xorm:"'name'" json:"name"`
-	IDMstProduct     null.Int64 `xorm:"id_mst_product" json:"id_mst_product"`
-	IDMstInstitution int64      `xorm:"id_mst_institution" json:"id_mst_institution"`
-	Price            float64    `xorm:"'price'" json:"price"`
-	IsItem           bool       `xorm:"'is_item'" json:"is_item"`
-	IsTreatment      bool       `xorm:"'is_treatment'" json:"is_treatment"`
-	CreateTime       time.Time  `json:"-" xorm:"'create_time' created"`
-	UpdateTime       time.Time  `json:"-" xorm:"'update_time' updated"`
-	DeleteTime       *time.Time `json:"-" xorm:"'delete_time' deleted"`
+	ID               int64       `xorm:"'id' pk autoincr" json:"id"`
+	Name             string      `xorm:"'name'" json:"name"`
+	SKU              null.String `xorm:"'sku'" json:"sku"`
+	IDMstProduct     null.Int64  `xorm:"id_mst_product" json:"id_mst_product"`
+	IDMstInstitution int64       `xorm:"id_mst_institution" json:"id_mst_institution"`
+	Price            money.Money `xorm:"'price'" json:"price"`
+	IsItem           bool        `xorm:"'is_item'" json:"is_item"`
+	IsTreatment      bool        `xorm:"'is_treatment'" json:"is_treatment"`
+	TaxCategory      string      `xorm:"'tax_category'" json:"tax_category"`
+	CreateTime       time.Time   `json:"-" xorm:"'create_time' created"`
+	UpdateTime       time.Time   `json:"-" xorm:"'update_time' updated"`
+	DeleteTime       *time.Time  `json:"-" xorm:"'delete_time' deleted"`
+}
+
+// BaseQuantity is the quantity in the product's base (stock) unit.
+// Rows written before unit conversions existed carry no factor and count as base units.
+func (p TrxVisitProduct) BaseQuantity() int64 {
+	if p.ConversionFactor <= 0 {
+		return int64(p.Quantity)
+	}
+	return int64(p.Quantity) * p.ConversionFactor
 }
 
 type FindTrxInstitutionProductParams struct {
@@ -42,37 +54,49 @@ type FindTrxInstitutionProductParams struct {
 }
 
 type TrxVisitProduct struct {
-	ID                      int64      `xorm:"'id' pk autoincr" json:"id"`
-	IDTrxInstitutionProduct int64      `xorm:"'id_trx_institution_product'" json:"id_trx_institution_product"`
-	IDMstInstitution        int64      `xorm:"'id_mst_institution'" json:"-"`
-	IDTrxPatientVisit       int64      `xorm:"'id_trx_patient_visit'" json:"id_trx_patient_visit"`
-	IDDtlPatientVisit       int64      `xorm:"'id_dtl_patient_visit'" json:"id_dtl_patient_visit"`
-	Name                    string     `xorm:"name" json:"name"`
-	Quantity                int        `xorm:"'quantity'" json:"quantity"`
-	UnitType                string     `xorm:"'unit_type'" json:"unit_type"`
-	Price                   float64    `xorm:"'price'" json:"price"`
-	DiscountRate            float64    `xorm:"'discount_rate'" json:"discount_rate"`
-	DiscountPrice           float64    `xorm:"'discount_price'" json:"discount_price"`
-	TotalPrice              float64    `xorm:"'total_price'" json:"total_price"`
-	AdjustedPrice           float64    `xorm:"adjusted_price" json:"adjusted_price"`
-	CreateTime              time.Time  `json:"-" xorm:"'create_time' created"`
-	UpdateTime              time.Time  `json:"-" xorm:"'update_time' updated"`
-	DeleteTime              *time.Time `json:"-" xorm:"'delete_time' deleted"`
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"id_trx_institution_product"`
+	IDMstInstitution        int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxPatientVisit       int64       `xorm:"'id_trx_patient_visit'" json:"id_trx_patient_visit"`
+	IDDtlPatientVisit       int64       `xorm:"'id_dtl_patient_visit'" json:"id_dtl_patient_visit"`
+	Name                    string      `xorm:"name" json:"name"`
+	Quantity                int         `xorm:"'quantity'" json:"quantity"`
+	UnitType                string      `xorm:"'unit_type'" json:"unit_type"`
+	Price                   money.Money `xorm:"'price'" json:"price"`
+	DiscountRate            float64     `xorm:"'discount_rate'" json:"discount_rate"`
+	DiscountPrice           money.Money `xorm:"'discount_price'" json:"discount_price"`
+	TotalPrice              money.Money `xorm:"'total_price'" json:"total_price"`
+	AdjustedPrice           money.Money `xorm:"adjusted_price" json:"adjusted_price"`
+	UnitCost                money.Money `xorm:"'unit_cost'" json:"-"`
+	ConversionFactor        int64       `xorm:"'conversion_factor'" json:"conversion_factor"`
+	IDMstPriceList          null.Int64  `xorm:"'id_mst_price_list'" json:"price_list_id"`
+	IDMstStockLocation      null.Int64  `xorm:"'id_mst_stock_location'" json:"stock_location_id"`
+	TaxCategory             string      `xorm:"'tax_category'" json:"tax_category"`
+	TaxRate                 float64     `xorm:"'tax_rate'" json:"tax_rate"`
+	TaxInclusive            bool        `xorm:"'tax_inclusive'" json:"tax_inclusive"`
+	TaxBase                 money.Money `xorm:"'tax_base'" json:"tax_base"`
+	TaxAmount               money.Money `xorm:"'tax_amount'" json:"tax_amount"`
+	ServiceCharge           money.Money `xorm:"'service_charge'" json:"service_charge"`
+	CreateTime              time.Time   `json:"-" xorm:"'create_time' created"`
+	UpdateTime              time.Time   `json:"-" xorm:"'update_time' updated"`
+	DeleteTime              *time.Time  `json:"-" xorm:"'delete_time' deleted"`
 }
 
 type DtlInstitutionProductStock struct {
-	ID                      int64      `xorm:"'id' pk autoincr" json:"id"`
-	Quantity                int64      `xorm:"'quantity'" json:"quantity"`
-	UnitType                string     `xorm:"'unit_type'" json:"unit_type"`
-	IDTrxInstitutionProduct int64      `xorm:"id_trx_institution_product" json:"id_trx_institution_product"`
-	CreateTime              time.Time  `json:"-" xorm:"'create_time' created"`
-	UpdateTime              time.Time  `json:"-" xorm:"'update_time' updated"`
-	DeleteTime              *time.Time `json:"-" xorm:"'delete_time' deleted"`
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	Quantity                int64       `xorm:"'quantity'" json:"quantity"`
+	UnitType                string      `xorm:"'unit_type'" json:"unit_type"`
+	AvgCost                 money.Money `xorm:"'avg_cost'" json:"avg_cost"`
+	IDTrxInstitutionProduct int64       `xorm:"id_trx_institution_product" json:"id_trx_institution_product"`
+	CreateTime              time.Time   `json:"-" xorm:"'create_time' created"`
+	UpdateTime              time.Time   `json:"-" xorm:"'update_time' updated"`
+	DeleteTime              *time.Time  `json:"-" xorm:"'delete_time' deleted"`
 }
 
 type ProductStockResupplyItem struct {
-	IDTrxInstitutionProduct int64 `json:"product_id" validate:"required"`
-	Quantity                int64 `json:"quantity" validate:"required,ne=0"` // not equal 0
+	IDTrxInstitutionProduct int64  `json:"product_id" validate:"required"`
+	Quantity                int64  `json:"quantity" validate:"required,ne=0"` // not equal 0
+	UnitType                string `json:"unit_type"`                         // empty means the base unit
 }
 
 type ProductStockResupplyRequest struct {
@@ -85,35 +109,44 @@ type TrxInstitutionProductJoinStock struct {
 }
 
 type InsertInstitutionProductRequest struct {
-	Name         string     `json:"name" validate:"required"`
-	IDMstProduct null.Int64 `json:"id_mst_product"`
-	Price        float64    `json:"price"`
-	IsItem       bool       `json:"is_item"`
-	IsTreatment  bool       `json:"is_treatment"`
-	Quantity     int64      `json:"quantity" validate:"gte=0"`
-	UnitType     string     `json:"unit_type" validate:"required"`
+	Name         string      `json:"name" validate:"required"`
+	SKU          string      `json:"sku" validate:"max=64"`
+	IDMstProduct null.Int64  `json:"id_mst_product"`
+	Price        money.Money `json:"price"`
+	IsItem       bool        `json:"is_item"`
+	IsTreatment  bool        `json:"is_treatment"`
+	TaxCategory  string      `json:"tax_category" validate:"omitempty,oneof=exempt ppn"`
+	Quantity     int64       `json:"quantity" validate:"gte=0"`
+	UnitType     string      `json:"unit_type" validate:"required"`
 }
 
+// UpdateInstitutionProductRequest changes the fields it sets. An empty SKU
+// removes the product's SKU.
 type UpdateInstitutionProductRequest struct {
 	ID           int64       `json:"id"`
 	Name         string      `json:"name"`
+	SKU          null.String `json:"sku"`
 	IDMstProduct null.Int64  `json:"id_mst_product"`
-	Price        float64     `json:"price"`
+	Price        money.Money `json:"price"`
 	IsItem       null.Bool   `json:"is_item"`
 	IsTreatment  null.Bool   `json:"is_treatment"`
+	TaxCategory  null.String `json:"tax_category"`
 	Quantity     null.Int64  `json:"quantity"`
 	UnitType     null.String `json:"unit_type"`
 }
 
 type GetInstitutionProductResponse struct {
-	ID           int64      `xorm:"'id'" json:"id"`
-	Name         string     `xorm:"'name'" json:"name"`
-	IDMstProduct null.Int64 `xorm:"id_mst_product" json:"id_mst_product,omitempty"`
-	Price        float64    `xorm:"'price'" json:"price,omitempty"`
-	IsItem       bool       `xorm:"'is_item'" json:"is_item,omitempty"`
-	IsTreatment  bool       `xorm:"'is_treatment'" json:"is_treatment"`
-	Quantity     int64      `xorm:"'quantity'" json:"quantity"`
-	UnitType     string     `xorm:"'unit_type'" json:"unit_type,omitempty"`
+	ID           int64       `xorm:"'id'" json:"id"`
+	Name         string      `xorm:"'name'" json:"name"`
+	SKU          null.String `xorm:"'sku'" json:"sku"`
+	IDMstProduct null.Int64  `xorm:"id_mst_product" json:"id_mst_product,omitempty"`
+	Price        money.Money `xorm:"'price'" json:"price,omitempty"`
+	IsItem       bool        `xorm:"'is_item'" json:"is_item,omitempty"`
+	IsTreatment  bool        `xorm:"'is_treatment'" json:"is_treatment"`
+	TaxCategory  string      `xorm:"'tax_category'" json:"tax_category"`
+	Quantity     int64       `xorm:"'quantity'" json:"quantity"`
+	UnitType     string      `xorm:"'unit_type'" json:"unit_type,omitempty"`
+	AvgCost      money.Money `xorm:"'avg_cost'" json:"avg_cost"`
 }
 
 type InsertTrxVisitProductRequest struct {
@@ -135,28 +168,35 @@ type GetVisitProductRequest struct {
 	VisitIDs       []int64
 }
 
+// PurchasedProduct is one requested visit line. UnitType selects any unit
+// configured for the product (empty means the base unit); Quantity and Price
+// are expressed in that unit. A discount above the user's limit needs the ID of
+// an approved discount request, and every manual discount or adjusted price is
+// audited with PriceChangeReason.
 type PurchasedProduct struct {
-	IDTrxInstitutionProduct int64   `json:"id"`
-	Quantity                int     `json:"quantity"`
-	Name                    string  `json:"name,omitempty"`
-	Price                   int32   `json:"price"`
-	TotalPrice              int32   `json:"total_price,omitempty"`
-	UnitType                string  `json:"unit_type,omitempty"`
-	DiscountRate            float64 `json:"discount_rate,omitempty"`
-	DiscountPrice           float64 `json:"discount_price,omitempty"`
-	AdjustedPrice           float64 `json:"adjusted_price,omitempty"`
+	IDTrxInstitutionProduct int64       `json:"id"`
+	Quantity                int         `json:"quantity"`
+	Name                    string      `json:"name,omitempty"`
+	Price                   money.Money `json:"price"`
+	TotalPrice              money.Money `json:"total_price,omitempty"`
+	UnitType                string      `json:"unit_type,omitempty"`
+	DiscountRate            float64     `json:"discount_rate,omitempty"`
+	DiscountPrice           money.Money `json:"discount_price,omitempty"`
+	AdjustedPrice           money.Money `json:"adjusted_price,omitempty"`
+	PriceChangeReason       string      `json:"price_change_reason,omitempty"`
+	DiscountApprovalID      null.Int64  `json:"discount_approval_id"`
 }
 
 type UpdateTrxVisitProductRequest struct {
-	ID                      int64   `json:"id"`
-	IDTrxInstitutionProduct int64   `json:"id_trx_institution_product"`
-	IDTrxPatientVisit       int64   `json:"id_trx_patient_visit"`
-	Quantity                int     `json:"quantity"`
-	Price                   float64 `json:"price"`
-	DiscountAmount          float64 `json:"discount_amount"`
-	DiscountPrice           float64 `json:"discount_price"`
-	FinalPrice              float64 `json:"final_price"`
-	AdjustedPrice           float64 `json:"adjusted_price"`
+	ID                      int64       `json:"id"`
+	IDTrxInstitutionProduct int64       `json:"id_trx_institution_product"`
+	IDTrxPatientVisit       int64       `json:"id_trx_patient_visit"`
+	Quantity                int         `json:"quantity"`
+	Price                   money.Money `json:"price"`
+	DiscountAmount          money.Money `json:"discount_amount"`
+	DiscountPrice           money.Money `json:"discount_price"`
+	FinalPrice              money.Money `json:"final_price"`
+	AdjustedPrice           money.Money `json:"adjusted_price"`
 }
 
 type DeleteTrxVisitProductRequest struct {
@@ -167,8 +207,37 @@ const (
 	ProductStatisticsGranularityHour = "hour"
 	ProductStatisticsGranularityDay  = "day"
 	ProductStatisticsGranularityWeek = "week"
+	// Month and year buckets are read from the daily rollups, so they cover
+	// whole local days and may span any range.
+	ProductStatisticsGranularityMonth = "month"
+	ProductStatisticsGranularityYear  = "year"
+)
+
+const (
+	AggProductDailyStatsTableName = "mdl_agg_product_daily_stats"
+	AggRollupStateTableName       = "mdl_agg_rollup_state"
+
+	// ProductStatisticsRollupName names the daily product statistics in the rollup state.
+	ProductStatisticsRollupName = "product_daily_stats"
 )
 
+// ProductStatisticsRollupOffsets are the UTC offsets, in seconds, whose local
+// days are rolled up: WIB, WITA and WIT.
+var ProductStatisticsRollupOffsets = []int{7 * 3600, 8 * 3600, 9 * 3600}
+
+// ProductStatisticsRollupDay is one local day of an institution whose rollup
+// must be rebuilt.
+type ProductStatisticsRollupDay struct {
+	IDMstInstitution int64     `xorm:"'id_mst_institution'"`
+	StatDate         time.Time `xorm:"'stat_date'"`
+}
+
+// AggRollupState records how far a rollup has been refreshed.
+type AggRollupState struct {
+	Name           string    `xorm:"'name' pk"`
+	RefreshedUntil time.Time `xorm:"'refreshed_until'"`
+}
+
 // ProductStatisticsParams is bound from GET query parameters.
 type ProductStatisticsParams struct {
 	StartTime   customtime.Time `schema:"start_time" validate:"required"`
@@ -184,16 +253,21 @@ type ProductStatisticsQuery struct {
 	EndTime                 time.Time
 	Granularity             string
 	IDTrxInstitutionProduct int64
+	// UTCOffsetSeconds selects the rollups of month and year queries.
+	UTCOffsetSeconds int
 }
 
 // ProductStatisticsRow is one grouped row returned from the database.
 type ProductStatisticsRow struct {
-	PeriodStart             time.Time `xorm:"period_start"`
-	IDTrxInstitutionProduct int64     `xorm:"id_trx_institution_product"`
-	Name                    string    `xorm:"name"`
-	TotalQuantity           int64     `xorm:"total_quantity"`
-	TotalRevenue            float64   `xorm:"total_revenue"`
-	AvgUnitPrice            float64   `xorm:"avg_unit_price"`
+	PeriodStart             time.Time   `xorm:"period_start"`
+	IDTrxInstitutionProduct int64       `xorm:"id_trx_institution_product"`
+	Name                    string      `xorm:"name"`
+	TotalQuantity           int64       `xorm:"total_quantity"`
+	TotalRevenue            money.Money `xorm:"total_revenue"`
+	TotalCost               money.Money `xorm:"total_cost"`
+	TotalTax                money.Money `xorm:"total_tax"`
+	TotalServiceCharge      money.Money `xorm:"total_service_charge"`
+	AvgUnitPrice            money.Money `xorm:"avg_unit_price"`
 }
 
 type ProductStatisticsPeriod struct {
@@ -202,31 +276,55 @@ type ProductStatisticsPeriod struct {
 }
 
 type ProductStatisticsProductItem struct {
-	ProductID     int64   `json:"product_id"`
-	Name          string  `json:"name"`
-	UnitPrice     float64 `json:"unit_price"`
-	TotalQuantity int64   `json:"total_quantity"`
-	TotalRevenue  float64 `json:"total_revenue"`
+	ProductID          int64       `json:"product_id"`
+	Name               string      `json:"name"`
+	UnitPrice          money.Money `json:"unit_price"`
+	TotalQuantity      int64       `json:"total_quantity"`
+	TotalRevenue       money.Money `json:"total_revenue"`
+	TotalCost          money.Money `json:"total_cost"`
+	TotalMargin        money.Money `json:"total_margin"`
+	TotalTax           money.Money `json:"total_tax"`
+	TotalServiceCharge money.Money `json:"total_service_charge"`
+}
+
+// ProductStatisticsLine is one product of one bucket, as streamed to exports.
+// Its period is in the location of the query's start_time.
+type ProductStatisticsLine struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	ProductStatisticsProductItem
 }
 
 type ProductStatisticsBucket struct {
-	PeriodStart   string                         `json:"period_start"`
-	PeriodEnd     string                         `json:"period_end"`
-	TotalRevenue  float64                        `json:"total_revenue"`
-	TotalQuantity int64                          `json:"total_quantity"`
-	Products      []ProductStatisticsProductItem `json:"products"`
+	PeriodStart        string                         `json:"period_start"`
+	PeriodEnd          string                         `json:"period_end"`
+	TotalRevenue       money.Money                    `json:"total_revenue"`
+	TotalCost          money.Money                    `json:"total_cost"`
+	TotalMargin        money.Money                    `json:"total_margin"`
+	TotalTax           money.Money                    `json:"total_tax"`
+	TotalServiceCharge money.Money                    `json:"total_service_charge"`
+	TotalQuantity      int64                          `json:"total_quantity"`
+	Products           []ProductStatisticsProductItem `json:"products"`
 }
 
 type ProductStatisticsSummaryItem struct {
-	ProductID     int64   `json:"product_id"`
-	Name          string  `json:"name"`
-	UnitPrice     float64 `json:"unit_price"`
-	TotalQuantity int64   `json:"total_quantity"`
-	TotalRevenue  float64 `json:"total_revenue"`
+	ProductID          int64       `json:"product_id"`
+	Name               string      `json:"name"`
+	UnitPrice          money.Money `json:"unit_price"`
+	TotalQuantity      int64       `json:"total_quantity"`
+	TotalRevenue       money.Money `json:"total_revenue"`
+	TotalCost          money.Money `json:"total_cost"`
+	TotalMargin        money.Money `json:"total_margin"`
+	TotalTax           money.Money `json:"total_tax"`
+	TotalServiceCharge money.Money `json:"total_service_charge"`
 }
 
 type ProductStatisticsSummary struct {
-	TotalRevenue          float64                        `json:"total_revenue"`
+	TotalRevenue          money.Money                    `json:"total_revenue"`
+	TotalCost             money.Money                    `json:"total_cost"`
+	TotalMargin           money.Money                    `json:"total_margin"`
+	TotalTax              money.Money                    `json:"total_tax"`
+	TotalServiceCharge    money.Money                    `json:"total_service_charge"`
 	TotalQuantity         int64                          `json:"total_quantity"`
 	TopProductsByRevenue  []ProductStatisticsSummaryItem `json:"top_products_by_revenue"`
 	TopProductsByQuantity []ProductStatisticsSummaryItem `json:"top_products_by_quantity"`
@@ -0,0 +1,388 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	// ProductImportMaxRows bounds the rows of an import file, header included.
+	ProductImportMaxRows = 5001
+
+	ProductSKUMaxLength = 64
+
+	// StockMovementTypeCatalogImport is the initial stock of a product created by a catalog import.
+	StockMovementTypeCatalogImport = "catalog_import"
+)
+
+// Actions of the rows of a catalog import.
+const (
+	ProductImportActionCreate    = "create"
+	ProductImportActionUpdate    = "update"
+	ProductImportActionUnchanged = "unchanged"
+)
+
+// Columns of catalog files. Exports write ProductCatalogColumns; imports also
+// read the initial stock of new products from quantity and unit_cost.
+const (
+	ProductColumnSKU         = "sku"
+	ProductColumnName        = "name"
+	ProductColumnPrice       = "price"
+	ProductColumnIsItem      = "is_item"
+	ProductColumnIsTreatment = "is_treatment"
+	ProductColumnTaxCategory = "tax_category"
+	ProductColumnUnitType    = "unit_type"
+	ProductColumnQuantity    = "quantity"
+	ProductColumnUnitCost    = "unit_cost"
+)
+
+var ProductCatalogColumns = []string{
+	ProductColumnSKU,
+	ProductColumnName,
+	ProductColumnPrice,
+	ProductColumnIsItem,
+	ProductColumnIsTreatment,
+	ProductColumnTaxCategory,
+	ProductColumnUnitType,
+}
+
+var productImportRequiredColumns = []string{ProductColumnName, ProductColumnPrice, ProductColumnUnitType}
+
+// ProductImportRequest carries the rows of an uploaded catalog, header first.
+// A dry run validates and previews the import without writing anything.
+type ProductImportRequest struct {
+	Rows   [][]string
+	DryRun bool
+}
+
+// ProductImportRow is one validated line of a catalog file.
+type ProductImportRow struct {
+	Line        int
+	SKU         string
+	Name        string
+	Price       money.Money
+	IsItem      bool
+	IsTreatment bool
+	TaxCategory string
+	UnitType    string
+	HasQuantity bool
+	Quantity    int64
+	UnitCost    money.Money
+}
+
+type ProductImportLineError struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ProductImportLineError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d, %s: %s", e.Line, e.Column, e.Message)
+}
+
+// ProductImportItem is a row of the file with what the import does with it.
+// Current is the product the row matched, nil when the row creates one.
+type ProductImportItem struct {
+	Row       ProductImportRow
+	Action    string
+	Current   *GetInstitutionProductResponse
+	ProductID int64
+}
+
+type ProductImportPlan struct {
+	Items  []ProductImportItem
+	Errors []ProductImportLineError
+}
+
+type ProductImportRowResult struct {
+	Line      int    `json:"line"`
+	Action    string `json:"action"`
+	ProductID int64  `json:"product_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"name"`
+	Quantity  int64  `json:"quantity,omitempty"`
+}
+
+type ProductImportResult struct {
+	DryRun    bool                     `json:"dry_run"`
+	Created   int                      `json:"created"`
+	Updated   int                      `json:"updated"`
+	Unchanged int                      `json:"unchanged"`
+	Rows      []ProductImportRowResult `json:"rows"`
+	Errors    []ProductImportLineError `json:"errors"`
+}
+
+// Result summarises the plan; product IDs are those known so far.
+func (p ProductImportPlan) Result(dryRun bool) ProductImportResult {
+	result := ProductImportResult{
+		DryRun: dryRun,
+		Rows:   make([]ProductImportRowResult, 0, len(p.Items)),
+		Errors: p.Errors,
+	}
+	if result.Errors == nil {
+		result.Errors = []ProductImportLineError{}
+	}
+
+	for _, item := range p.Items {
+		switch item.Action {
+		case ProductImportActionCreate:
+			result.Created++
+		case ProductImportActionUpdate:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+		result.Rows = append(result.Rows, ProductImportRowResult{
+			Line:      item.Row.Line,
+			Action:    item.Action,
+			ProductID: item.ProductID,
+			SKU:       item.Row.SKU,
+			Name:      item.Row.Name,
+			Quantity:  item.Row.Quantity,
+		})
+	}
+	return result
+}
+
+// ParseProductImportRows validates the rows of a catalog file, whose first row
+// names the columns in any order and case. Blank lines are skipped; every
+// other line either becomes a row or reports its errors.
+func ParseProductImportRows(rows [][]string) (parsed []ProductImportRow, lineErrors []ProductImportLineError) {
+	if len(rows) == 0 {
+		return nil, []ProductImportLineError{{Line: 1, Message: "file is empty"}}
+	}
+
+	columns := map[string]int{}
+	for i, header := range rows[0] {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header == "" {
+			continue
+		}
+		if _, ok := columns[header]; ok {
+			lineErrors = append(lineErrors, ProductImportLineError{Line: 1, Column: header, Message: "column appears more than once"})
+			continue
+		}
+		columns[header] = i
+	}
+	for _, column := range productImportRequiredColumns {
+		if _, ok := columns[column]; !ok {
+			lineErrors = append(lineErrors, ProductImportLineError{Line: 1, Column: column, Message: "column is missing"})
+		}
+	}
+	if len(lineErrors) > 0 {
+		return nil, lineErrors
+	}
+
+	for i, record := range rows[1:] {
+		line := i + 2
+		cell := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+
+		row, errs := parseProductImportRow(line, cell)
+		if len(errs) > 0 {
+			lineErrors = append(lineErrors, errs...)
+			continue
+		}
+		parsed = append(parsed, row)
+	}
+	return parsed, lineErrors
+}
+
+func parseProductImportRow(line int, cell func(column string) string) (row ProductImportRow, errs []ProductImportLineError) {
+	fail := func(column, message string) {
+		errs = append(errs, ProductImportLineError{Line: line, Column: column, Message: message})
+	}
+
+	row = ProductImportRow{
+		Line:        line,
+		SKU:         cell(ProductColumnSKU),
+		Name:        cell(ProductColumnName),
+		TaxCategory: strings.ToLower(cell(ProductColumnTaxCategory)),
+		UnitType:    cell(ProductColumnUnitType),
+		UnitCost:    money.Zero,
+	}
+
+	if len(row.SKU) > ProductSKUMaxLength {
+		fail(ProductColumnSKU, fmt.Sprintf("must be at most %d characters", ProductSKUMaxLength))
+	}
+	if row.Name == "" {
+		fail(ProductColumnName, "is required")
+	}
+	if row.UnitType == "" {
+		fail(ProductColumnUnitType, "is required")
+	}
+	if row.TaxCategory == "" {
+		row.TaxCategory = TaxCategoryExempt
+	} else if !IsValidTaxCategory(row.TaxCategory) {
+		fail(ProductColumnTaxCategory, "must be exempt or ppn")
+	}
+
+	if price, ok := parseImportMoney(cell(ProductColumnPrice)); !ok {
+		fail(ProductColumnPrice, "must be a non-negative amount, such as 12500 or 12500.50")
+	} else {
+		row.Price = price
+	}
+
+	var ok bool
+	if row.IsItem, ok = parseImportBool(cell(ProductColumnIsItem)); !ok {
+		fail(ProductColumnIsItem, "must be true or false")
+	}
+	if row.IsTreatment, ok = parseImportBool(cell(ProductColumnIsTreatment)); !ok {
+		fail(ProductColumnIsTreatment, "must be true or false")
+	}
+
+	if quantity := cell(ProductColumnQuantity); quantity != "" {
+		n, err := strconv.ParseInt(quantity, 10, 64)
+		if err != nil || n < 0 {
+			fail(ProductColumnQuantity, "must be a whole number of base units, zero or more")
+		}
+		row.HasQuantity, row.Quantity = true, n
+	}
+	if unitCost := cell(ProductColumnUnitCost); unitCost != "" {
+		if row.UnitCost, ok = parseImportMoney(unitCost); !ok {
+			fail(ProductColumnUnitCost, "must be a non-negative amount")
+		}
+	}
+	return row, errs
+}
+
+// PlanProductImport matches every row to the catalog: by SKU when the row has
+// one, else by name, both ignoring case. A row with a new SKU may still claim
+// a product of its name that has no SKU yet. Rows matching nothing create a
+// product; the others update it, or leave it unchanged. A row without SKU
+// keeps the SKU of its product.
+func PlanProductImport(rows []ProductImportRow, catalog []GetInstitutionProductResponse) (plan ProductImportPlan) {
+	bySKU := map[string]*GetInstitutionProductResponse{}
+	byName := map[string][]*GetInstitutionProductResponse{}
+	for i := range catalog {
+		product := &catalog[i]
+		if product.SKU.Valid && product.SKU.String != "" {
+			bySKU[strings.ToLower(product.SKU.String)] = product
+		}
+		name := strings.ToLower(product.Name)
+		byName[name] = append(byName[name], product)
+	}
+
+	seenSKU := map[string]int{}
+	seenName := map[string]int{}
+	claimed := map[int64]int{}
+	for _, row := range rows {
+		fail := func(column, message string) {
+			plan.Errors = append(plan.Errors, ProductImportLineError{Line: row.Line, Column: column, Message: message})
+		}
+
+		sku, name := strings.ToLower(row.SKU), strings.ToLower(row.Name)
+		if sku != "" {
+			if line, ok := seenSKU[sku]; ok {
+				fail(ProductColumnSKU, fmt.Sprintf("repeats the sku of line %d", line))
+				continue
+			}
+			seenSKU[sku] = row.Line
+		}
+		if line, ok := seenName[name]; ok && sku == "" {
+			fail(ProductColumnName, fmt.Sprintf("repeats the name of line %d; add a sku to tell them apart", line))
+			continue
+		}
+		seenName[name] = row.Line
+
+		current := bySKU[sku]
+		if current == nil {
+			candidates := byName[name]
+			if len(candidates) > 1 {
+				fail(ProductColumnName, fmt.Sprintf("matches %d products; add the sku of the one to update", len(candidates)))
+				continue
+			}
+			if len(candidates) == 1 {
+				candidate := candidates[0]
+				if sku != "" && candidate.SKU.Valid && candidate.SKU.String != "" {
+					// the name belongs to a product with another SKU; a new product
+					// of the same name would make name matching ambiguous
+					fail(ProductColumnName, fmt.Sprintf("belongs to the product with sku %s", candidate.SKU.String))
+					continue
+				}
+				current = candidate
+			}
+		}
+
+		if current == nil {
+			plan.Items = append(plan.Items, ProductImportItem{Row: row, Action: ProductImportActionCreate})
+			continue
+		}
+
+		if line, ok := claimed[current.ID]; ok {
+			fail("", fmt.Sprintf("updates the same product as line %d", line))
+			continue
+		}
+		claimed[current.ID] = row.Line
+		if row.HasQuantity {
+			fail(ProductColumnQuantity, "sets the stock of new products only; change the stock of existing products with a resupply or stock take")
+			continue
+		}
+		if row.Price.IsZero() && !current.Price.IsZero() {
+			// product updates read a zero price as keeping the current one
+			fail(ProductColumnPrice, "cannot change the price of an existing product to 0")
+			continue
+		}
+
+		action := ProductImportActionUnchanged
+		if row.changes(*current) {
+			action = ProductImportActionUpdate
+		}
+		plan.Items = append(plan.Items, ProductImportItem{Row: row, Action: action, Current: current, ProductID: current.ID})
+	}
+	return plan
+}
+
+func (r ProductImportRow) changes(product GetInstitutionProductResponse) bool {
+	return r.Name != product.Name ||
+		(r.SKU != "" && !strings.EqualFold(r.SKU, product.SKU.String)) ||
+		!r.Price.Equal(product.Price) ||
+		r.IsItem != product.IsItem ||
+		r.IsTreatment != product.IsTreatment ||
+		r.TaxCategory != product.TaxCategory ||
+		r.UnitType != product.UnitType
+}
+
+func parseImportMoney(value string) (money.Money, bool) {
+	amount, err := money.NewFromString(value)
+	if err != nil || amount.IsNegative() {
+		return money.Zero, false
+	}
+	return amount.Round(), true
+}
+
+// parseImportBool reads the booleans of spreadsheets in English or Indonesian;
+// an empty cell is false.
+func parseImportBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "", "false", "0", "no", "n", "tidak":
+		return false, true
+	case "true", "1", "yes", "y", "ya":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/volatiletech/null/v8"
+)
+
+func TestParseProductImportRows(t *testing.T) {
+	t.Parallel()
+
+	rows := [][]string{
+		{"Name", "SKU", "Price", "Unit_Type", "is_item", "quantity", "unit_cost"},
+		{"Kasa steril", "KS-01", "12500", "pcs", "ya", "40", "9000.5"},
+		{"", "", "", "", "", "", ""},
+		{"Scaling", "", "350000.005", "session", "false", "", ""},
+		{"", "X", "-1", "", "maybe", "1.5", ""},
+	}
+
+	parsed, errs := ParseProductImportRows(rows)
+	if len(parsed) != 2 {
+		t.Fatalf("parsed %d rows, want 2", len(parsed))
+	}
+	kasa := parsed[0]
+	if kasa.Line != 2 || kasa.SKU != "KS-01" || !kasa.IsItem || !kasa.HasQuantity || kasa.Quantity != 40 ||
+		!kasa.UnitCost.Equal(money.NewFromFloat(9000.5)) || kasa.TaxCategory != TaxCategoryExempt {
+		t.Fatalf("unexpected first row %+v", kasa)
+	}
+	if scaling := parsed[1]; scaling.Line != 4 || scaling.HasQuantity || !scaling.Price.Equal(money.NewFromFloat(350000.01)) {
+		t.Fatalf("unexpected second row %+v", scaling)
+	}
+
+	wantColumns := []string{ProductColumnName, ProductColumnUnitType, ProductColumnPrice, ProductColumnIsItem, ProductColumnQuantity}
+	if len(errs) != len(wantColumns) {
+		t.Fatalf("got errors %v, want one per column %v", errs, wantColumns)
+	}
+	for i, column := range wantColumns {
+		if errs[i].Line != 5 || errs[i].Column != column {
+			t.Fatalf("error %d = %v, want line 5 column %s", i, errs[i], column)
+		}
+	}
+}
+
+func TestParseProductImportRowsMissingColumns(t *testing.T) {
+	t.Parallel()
+
+	_, errs := ParseProductImportRows([][]string{{"sku", "name", "SKU"}})
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Column)
+	}
+	if strings.Join(got, ",") != "sku,price,unit_type" {
+		t.Fatalf("got error columns %v", got)
+	}
+}
+
+func TestPlanProductImport(t *testing.T) {
+	t.Parallel()
+
+	catalog := []GetInstitutionProductResponse{
+		{ID: 1, Name: "Kasa Steril", SKU: null.StringFrom("KS-01"), Price: money.New(12500), IsItem: true, TaxCategory: TaxCategoryExempt, UnitType: "pcs"},
+		{ID: 2, Name: "Scaling", Price: money.New(300000), IsTreatment: true, TaxCategory: TaxCategoryExempt, UnitType: "session"},
+		{ID: 3, Name: "Salep", SKU: null.StringFrom("SL-01"), Price: money.New(7500), IsItem: true, TaxCategory: TaxCategoryExempt, UnitType: "tube"},
+		{ID: 4, Name: "Salep", SKU: null.StringFrom("SL-02"), Price: money.New(9500), IsItem: true, TaxCategory: TaxCategoryExempt, UnitType: "tube"},
+	}
+	row := func(line int, sku, name string, price int64, unitType string) ProductImportRow {
+		return ProductImportRow{Line: line, SKU: sku, Name: name, Price: money.New(price), TaxCategory: TaxCategoryExempt, UnitType: unitType}
+	}
+
+	unchanged := row(2, "ks-01", "Kasa Steril", 12500, "pcs")
+	unchanged.IsItem = true
+	claimsSKU := row(3, "SC-01", "scaling", 350000, "session")
+	claimsSKU.IsTreatment = true
+	created := row(4, "", "Masker", 2000, "box")
+	created.IsItem, created.HasQuantity, created.Quantity = true, true, 10
+	ambiguous := row(5, "", "Salep", 7500, "tube")
+	takenName := row(6, "KS-02", "Kasa steril", 12500, "pcs")
+	repeatedSKU := row(7, "MS-01", "Masker biru", 2500, "box")
+	repeatedSKU2 := row(8, "ms-01", "Masker hijau", 2500, "box")
+	stockOnExisting := row(9, "SL-01", "Salep", 7500, "tube")
+	stockOnExisting.HasQuantity = true
+
+	plan := PlanProductImport([]ProductImportRow{
+		unchanged, claimsSKU, created, ambiguous, takenName, repeatedSKU, repeatedSKU2, stockOnExisting,
+	}, catalog)
+
+	wantItems := []struct {
+		line      int
+		action    string
+		productID int64
+	}{
+		{2, ProductImportActionUnchanged, 1},
+		{3, ProductImportActionUpdate, 2},
+		{4, ProductImportActionCreate, 0},
+		{7, ProductImportActionCreate, 0},
+	}
+	if len(plan.Items) != len(wantItems) {
+		t.Fatalf("got %d items, want %d: %+v", len(plan.Items), len(wantItems), plan.Items)
+	}
+	for i, want := range wantItems {
+		item := plan.Items[i]
+		if item.Row.Line != want.line || item.Action != want.action || item.ProductID != want.productID {
+			t.Fatalf("item %d = line %d %s product %d, want %+v", i, item.Row.Line, item.Action, item.ProductID, want)
+		}
+	}
+
+	wantErrors := map[int]string{5: ProductColumnName, 6: ProductColumnName, 8: ProductColumnSKU, 9: ProductColumnQuantity}
+	if len(plan.Errors) != len(wantErrors) {
+		t.Fatalf("got errors %v", plan.Errors)
+	}
+	for _, err := range plan.Errors {
+		if wantErrors[err.Line] != err.Column {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	result := plan.Result(true)
+	if !result.DryRun || result.Created != 2 || result.Updated != 1 || result.Unchanged != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	DtlInstitutionProductUnitTableName = "mdl_dtl_institution_product_unit"
+)
+
+// DtlInstitutionProductUnit declares an alternative unit for a product and how
+// many base (stock) units it holds. Price, when set, overrides the selling
+// price of one such unit.
+type DtlInstitutionProductUnit struct {
+	ID                      int64           `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxInstitutionProduct int64           `xorm:"'id_trx_institution_product'" json:"product_id"`
+	UnitType                string          `xorm:"'unit_type'" json:"unit_type"`
+	ConversionFactor        int64           `xorm:"'conversion_factor'" json:"conversion_factor"`
+	Price                   money.NullMoney `xorm:"'price'" json:"price"`
+	CreateTime              time.Time       `xorm:"'create_time' created" json:"-"`
+	UpdateTime              time.Time       `xorm:"'update_time' updated" json:"-"`
+	DeleteTime              *time.Time      `xorm:"'delete_time' deleted" json:"-"`
+}
+
+type ProductUnitRequest struct {
+	UnitType         string          `json:"unit_type" validate:"required"`
+	ConversionFactor int64           `json:"conversion_factor" validate:"required,gt=0"`
+	Price            money.NullMoney `json:"price" validate:"gte=0"`
+}
+
+// SaveProductUnitsRequest replaces the alternative units of a product.
+type SaveProductUnitsRequest struct {
+	IDTrxInstitutionProduct int64                `json:"product_id" validate:"required"`
+	Units                   []ProductUnitRequest `json:"units" validate:"dive"`
+}
+
+type ListProductUnitsParams struct {
+	IDTrxInstitutionProduct int64 `schema:"product_id" validate:"required"`
+}
+
+type ProductUnitsResponse struct {
+	IDTrxInstitutionProduct int64                       `json:"product_id"`
+	BaseUnitType            string                      `json:"base_unit_type"`
+	Units                   []DtlInstitutionProductUnit `json:"units"`
+}
+
+// ProductUnitConversion resolves the units of one product to its base unit.
+type ProductUnitConversion struct {
+	BaseUnitType string
+	BasePrice    money.Money
+	units        map[string]DtlInstitutionProductUnit
+}
+
+// NewProductUnitConversion builds the conversion for a product whose stock is
+// kept in baseUnitType and sold at basePrice per base unit.
+func NewProductUnitConversion(baseUnitType string, basePrice money.Money, units []DtlInstitutionProductUnit) ProductUnitConversion {
+	conversion := ProductUnitConversion{
+		BaseUnitType: baseUnitType,
+		BasePrice:    basePrice,
+		units:        make(map[string]DtlInstitutionProductUnit, len(units)),
+	}
+	for _, unit := range units {
+		conversion.units[normaliseUnitType(unit.UnitType)] = unit
+	}
+	return conversion
+}
+
+// Factor returns how many base units one unitType holds. An empty unitType or
+// the base unit itself resolves to 1; unknown units return ok=false.
+func (c ProductUnitConversion) Factor(unitType string) (factor int64, ok bool) {
+	key := normaliseUnitType(unitType)
+	if key == "" || key == normaliseUnitType(c.BaseUnitType) {
+		return 1, true
+	}
+	unit, ok := c.units[key]
+	if !ok {
+		return 0, false
+	}
+	return unit.ConversionFactor, true
+}
+
+// UnitType returns the display unit for unitType, falling back to the base unit.
+func (c ProductUnitConversion) UnitType(unitType string) string {
+	if unit, ok := c.units[normaliseUnitType(unitType)]; ok {
+		return unit.UnitType
+	}
+	return c.BaseUnitType
+}
+
+// Price returns the selling price of one unitType: the unit's own price when
+// configured, otherwise the base price times the conversion factor.
+func (c ProductUnitConversion) Price(unitType string) (price money.Money, ok bool) {
+	factor, ok := c.Factor(unitType)
+	if !ok {
+		return money.Zero, false
+	}
+	if unit, found := c.units[normaliseUnitType(unitType)]; found && unit.Price.Valid {
+		return unit.Price.Money, true
+	}
+	return c.BasePrice.MulInt(factor), true
+}
+
+// WithListPrice returns the conversion priced from a price list: every unit
+// costs price per base unit times its factor and per-unit price overrides,
+// which belong to the base price, no longer apply.
+func (c ProductUnitConversion) WithListPrice(price money.Money) ProductUnitConversion {
+	units := make(map[string]DtlInstitutionProductUnit, len(c.units))
+	for key, unit := range c.units {
+		unit.Price = money.NullMoney{}
+		units[key] = unit
+	}
+	return ProductUnitConversion{
+		BaseUnitType: c.BaseUnitType,
+		BasePrice:    price,
+		units:        units,
+	}
+}
+
+func normaliseUnitType(unitType string) string {
+	return strings.ToLower(strings.TrimSpace(unitType))
+}
+
+// NewProductUnitConversions builds a conversion per product, keyed by product ID,
+// from products joined with their stock row and the products' configured units.
+func NewProductUnitConversions(products []GetInstitutionProductResponse, units []DtlInstitutionProductUnit) map[int64]ProductUnitConversion {
+	unitsByProduct := make(map[int64][]DtlInstitutionProductUnit)
+	for _, unit := range units {
+		unitsByProduct[unit.IDTrxInstitutionProduct] = append(unitsByProduct[unit.IDTrxInstitutionProduct], unit)
+	}
+
+	conversions := make(map[int64]ProductUnitConversion, len(products))
+	for _, product := range products {
+		conversions[product.ID] = NewProductUnitConversion(product.UnitType, product.Price, unitsByProduct[product.ID])
+	}
+	return conversions
+}
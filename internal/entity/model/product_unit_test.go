@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestProductUnitConversion(t *testing.T) {
+	t.Parallel()
+
+	conversion := NewProductUnitConversion("tablet", money.New(1000), []DtlInstitutionProductUnit{
+		{UnitType: "Strip", ConversionFactor: 10},
+		{UnitType: "box", ConversionFactor: 100, Price: money.NullMoneyFrom(money.New(90000))},
+	})
+
+	if factor, ok := conversion.Factor(""); !ok || factor != 1 {
+		t.Fatalf("empty unit: got %d, %v", factor, ok)
+	}
+	if factor, ok := conversion.Factor("Tablet"); !ok || factor != 1 {
+		t.Fatalf("base unit: got %d, %v", factor, ok)
+	}
+	if factor, ok := conversion.Factor(" strip "); !ok || factor != 10 {
+		t.Fatalf("strip: got %d, %v", factor, ok)
+	}
+	if _, ok := conversion.Factor("bottle"); ok {
+		t.Fatalf("expected unknown unit to be rejected")
+	}
+
+	if price, _ := conversion.Price("strip"); !price.Equal(money.New(10000)) {
+		t.Fatalf("expected derived strip price 10000, got %v", price)
+	}
+	if price, _ := conversion.Price("box"); !price.Equal(money.New(90000)) {
+		t.Fatalf("expected configured box price 90000, got %v", price)
+	}
+	if unitType := conversion.UnitType("STRIP"); unitType != "Strip" {
+		t.Fatalf("expected configured display unit, got %s", unitType)
+	}
+	if unitType := conversion.UnitType(""); unitType != "tablet" {
+		t.Fatalf("expected base unit fallback, got %s", unitType)
+	}
+
+	listed := conversion.WithListPrice(money.New(800))
+	if price, _ := listed.Price("box"); !price.Equal(money.New(80000)) {
+		t.Fatalf("expected list price to replace the box override, got %v", price)
+	}
+	if price, _ := conversion.Price("box"); !price.Equal(money.New(90000)) {
+		t.Fatalf("expected the original conversion to keep its override, got %v", price)
+	}
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	MstSupplierTableName          = "mdl_mst_supplier"
+	TrxPurchaseOrderTableName     = "mdl_trx_purchase_order"
+	DtlPurchaseOrderItemTableName = "mdl_dtl_purchase_order_item"
+)
+
+// Purchase order statuses. Orders move draft -> ordered -> partially_received -> received;
+// draft and ordered orders may be cancelled.
+const (
+	PurchaseOrderStatusDraft             = "draft"
+	PurchaseOrderStatusOrdered           = "ordered"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+	PurchaseOrderStatusCancelled         = "cancelled"
+)
+
+type MstSupplier struct {
+	ID               int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64      `xorm:"'id_mst_institution'" json:"-"`
+	Name             string     `xorm:"'name'" json:"name"`
+	ContactPerson    string     `xorm:"'contact_person'" json:"contact_person"`
+	PhoneNumber      string     `xorm:"'phone_number'" json:"phone_number"`
+	Email            string     `xorm:"'email'" json:"email"`
+	Address          string     `xorm:"'address'" json:"address"`
+	Notes            string     `xorm:"'notes'" json:"notes"`
+	CreateTime       time.Time  `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime       time.Time  `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime       *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+type TrxPurchaseOrder struct {
+	ID               int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDMstSupplier    int64       `xorm:"'id_mst_supplier'" json:"supplier_id"`
+	Status           string      `xorm:"'status'" json:"status"`
+	Notes            string      `xorm:"'notes'" json:"notes"`
+	TotalAmount      money.Money `xorm:"'total_amount'" json:"total_amount"`
+	CreatedBy        string      `xorm:"'created_by'" json:"created_by"`
+	OrderedAt        *time.Time  `xorm:"'ordered_at'" json:"ordered_at,omitempty"`
+	ReceivedAt       *time.Time  `xorm:"'received_at'" json:"received_at,omitempty"`
+	CreateTime       time.Time   `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime       time.Time   `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime       *time.Time  `xorm:"'delete_time' deleted" json:"-"`
+}
+
+type DtlPurchaseOrderItem struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxPurchaseOrder      int64       `xorm:"'id_trx_purchase_order'" json:"-"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	Name                    string      `xorm:"'name'" json:"name"`
+	UnitType                string      `xorm:"'unit_type'" json:"unit_type"`
+	ConversionFactor        int64       `xorm:"'conversion_factor'" json:"conversion_factor"`
+	OrderedQuantity         int64       `xorm:"'ordered_quantity'" json:"ordered_quantity"`
+	ReceivedQuantity        int64       `xorm:"'received_quantity'" json:"received_quantity"`
+	UnitCost                money.Money `xorm:"'unit_cost'" json:"unit_cost"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"-"`
+	UpdateTime              time.Time   `xorm:"'update_time' updated" json:"-"`
+	DeleteTime              *time.Time  `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// OutstandingQuantity is the quantity still expected from the supplier, in the line's unit.
+func (i DtlPurchaseOrderItem) OutstandingQuantity() int64 {
+	return i.OrderedQuantity - i.ReceivedQuantity
+}
+
+// TrxPurchaseOrderJoinSupplier is the list read-model for purchase orders.
+type TrxPurchaseOrderJoinSupplier struct {
+	TrxPurchaseOrder `xorm:"extends"`
+	SupplierName     string `xorm:"'supplier_name'" json:"supplier_name"`
+}
+
+type CreateSupplierRequest struct {
+	Name          string `json:"name" validate:"required"`
+	ContactPerson string `json:"contact_person"`
+	PhoneNumber   string `json:"phone_number"`
+	Email         string `json:"email" validate:"omitempty,email"`
+	Address       string `json:"address"`
+	Notes         string `json:"notes"`
+}
+
+// UpdateSupplierRequest patches only the fields that are present.
+type UpdateSupplierRequest struct {
+	ID            int64   `json:"id" validate:"required"`
+	Name          *string `json:"name,omitempty"`
+	ContactPerson *string `json:"contact_person,omitempty"`
+	PhoneNumber   *string `json:"phone_number,omitempty"`
+	Email         *string `json:"email,omitempty" validate:"omitempty,email"`
+	Address       *string `json:"address,omitempty"`
+	Notes         *string `json:"notes,omitempty"`
+}
+
+type ListSupplierParams struct {
+	Name             string `schema:"name"`
+	IDMstInstitution int64  `schema:"-"`
+	CommonRequestPayload
+}
+
+// PurchaseOrderItemRequest orders Quantity of UnitType at UnitCost per that
+// unit. An empty UnitType means the product's base unit.
+type PurchaseOrderItemRequest struct {
+	IDTrxInstitutionProduct int64       `json:"product_id" validate:"required"`
+	UnitType                string      `json:"unit_type"`
+	Quantity                int64       `json:"quantity" validate:"required,gt=0"`
+	UnitCost                money.Money `json:"unit_cost" validate:"gte=0"`
+}
+
+type CreatePurchaseOrderRequest struct {
+	IDMstSupplier int64                      `json:"supplier_id" validate:"required"`
+	Notes         string                     `json:"notes"`
+	Items         []PurchaseOrderItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+// UpdatePurchaseOrderRequest replaces the notes and lines of a draft purchase order.
+type UpdatePurchaseOrderRequest struct {
+	IDMstSupplier int64                      `json:"supplier_id" validate:"required"`
+	Notes         string                     `json:"notes"`
+	Items         []PurchaseOrderItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type ReceivePurchaseOrderItemRequest struct {
+	IDDtlPurchaseOrderItem int64 `json:"item_id" validate:"required"`
+	Quantity               int64 `json:"quantity" validate:"required,gt=0"`
+	// UnitCost overrides the ordered unit cost when the invoice differs.
+	UnitCost *money.Money `json:"unit_cost,omitempty" validate:"omitempty,gte=0"`
+}
+
+// ReceivePurchaseOrderRequest books the received goods at the location they
+// arrive at; without one they go to the main location.
+type ReceivePurchaseOrderRequest struct {
+	IDMstStockLocation int64                             `json:"location_id"`
+	Notes              string                            `json:"notes"`
+	Items              []ReceivePurchaseOrderItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type ListPurchaseOrderParams struct {
+	Status           string `schema:"status" validate:"omitempty,oneof=draft ordered partially_received received cancelled"`
+	IDMstSupplier    int64  `schema:"supplier_id"`
+	IDMstInstitution int64  `schema:"-"`
+	CommonRequestPayload
+}
+
+type PurchaseOrderResponse struct {
+	TrxPurchaseOrder
+	SupplierName string                 `json:"supplier_name"`
+	Items        []DtlPurchaseOrderItem `json:"items"`
+}
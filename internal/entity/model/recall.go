@@ -76,6 +76,9 @@ type RecallResponse struct {
 	Notes             string    `json:"notes"`
 	IDTrxPatientVisit int64     `json:"id_trx_patient_visit,omitempty"`
 	CreateTime        time.Time `json:"create_time"`
+	// Contact is who to reach about the recall, the patient's recall contact
+	// if they have one.
+	Contact *RecallContact `json:"contact,omitempty"`
 }
 
 // NextRecallResponse is the next upcoming recall for a patient (for doctor reminder)
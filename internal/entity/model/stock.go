@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	TrxStockMovementTableName = "mdl_trx_stock_movement"
+)
+
+// Stock movement types recorded in mdl_trx_stock_movement.movement_type.
+const (
+	StockMovementTypePurchaseReceipt = "purchase_receipt"
+	StockMovementTypeResupply        = "resupply"
+	StockMovementTypeStockTake       = "stock_take_adjustment"
+)
+
+// Stock movement reference types, pointing at the document that caused the movement.
+const (
+	StockMovementReferencePurchaseOrder = "purchase_order"
+	StockMovementReferenceStockTake     = "stock_take"
+)
+
+// TrxStockMovement is one append-only row of the stock ledger.
+// Quantity is signed: positive adds stock, negative removes it, at the location
+// IDMstStockLocation; a movement inserted without one books to the main location.
+type TrxStockMovement struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution        int64       `xorm:"'id_mst_institution'" json:"-"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	IDMstStockLocation      int64       `xorm:"'id_mst_stock_location'" json:"location_id"`
+	MovementType            string      `xorm:"'movement_type'" json:"movement_type"`
+	Quantity                int64       `xorm:"'quantity'" json:"quantity"`
+	UnitCost                money.Money `xorm:"'unit_cost'" json:"unit_cost"`
+	ReferenceType           string      `xorm:"'reference_type'" json:"reference_type,omitempty"`
+	ReferenceID             int64       `xorm:"'reference_id'" json:"reference_id,omitempty"`
+	Notes                   string      `xorm:"'notes'" json:"notes,omitempty"`
+	CreatedBy               string      `xorm:"'created_by'" json:"created_by"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"create_time"`
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	MstStockLocationTableName     = "mdl_mst_stock_location"
+	DtlLocationStockTableName     = "mdl_dtl_location_stock"
+	TrxStockTransferTableName     = "mdl_trx_stock_transfer"
+	DtlStockTransferItemTableName = "mdl_dtl_stock_transfer_item"
+
+	// MainStockLocationName names the main location created for an institution.
+	MainStockLocationName = "Gudang Utama"
+)
+
+// Stock movements posted by transfers between locations.
+const (
+	StockMovementTypeTransferOut = "transfer_out"
+	StockMovementTypeTransferIn  = "transfer_in"
+
+	StockMovementReferenceStockTransfer = "stock_transfer"
+)
+
+// MstStockLocation is a place stock is kept: the institution's main warehouse,
+// or a cabinet that supplies one service point.
+type MstStockLocation struct {
+	ID                int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution  int64      `xorm:"'id_mst_institution'" json:"-"`
+	Name              string     `xorm:"'name'" json:"name"`
+	IsMain            bool       `xorm:"'is_main'" json:"is_main"`
+	IDMstServicePoint null.Int64 `xorm:"'id_mst_service_point'" json:"service_point_id"`
+	CreateTime        time.Time  `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime        time.Time  `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime        *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// LocationStockRow is the stock of one item product at one location, with the
+// product's name and base unit.
+type LocationStockRow struct {
+	IDMstStockLocation      int64       `xorm:"'id_mst_stock_location'" json:"location_id"`
+	LocationName            string      `xorm:"'location_name'" json:"location_name"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	Name                    string      `xorm:"'name'" json:"name"`
+	SKU                     null.String `xorm:"'sku'" json:"sku"`
+	UnitType                string      `xorm:"'unit_type'" json:"unit_type"`
+	Quantity                int64       `xorm:"'quantity'" json:"quantity"`
+	ReorderLevel            int64       `xorm:"'reorder_level'" json:"reorder_level"`
+}
+
+// IsLow reports whether the stock has fallen to its reorder level. A reorder
+// level of zero turns the check off.
+func (row LocationStockRow) IsLow() bool {
+	return row.ReorderLevel > 0 && row.Quantity <= row.ReorderLevel
+}
+
+type CreateStockLocationRequest struct {
+	Name              string     `json:"name" validate:"required,max=100"`
+	IDMstServicePoint null.Int64 `json:"service_point_id"`
+}
+
+// UpdateStockLocationRequest renames a location or changes the service point it
+// supplies; a service point of 0 unlinks it.
+type UpdateStockLocationRequest struct {
+	ID                int64       `json:"id" validate:"required"`
+	Name              null.String `json:"name" validate:"omitempty,max=100"`
+	IDMstServicePoint null.Int64  `json:"service_point_id"`
+}
+
+// ListLocationStockParams selects stock rows; without a location every location
+// is listed.
+type ListLocationStockParams struct {
+	IDMstStockLocation int64   `schema:"location_id"`
+	ProductIDs         []int64 `schema:"product_id"`
+	LowStockOnly       bool    `schema:"low_stock"`
+	IDMstInstitution   int64   `schema:"-"`
+}
+
+type ReorderLevelRequest struct {
+	IDTrxInstitutionProduct int64 `json:"product_id" validate:"required"`
+	ReorderLevel            int64 `json:"reorder_level" validate:"min=0"`
+}
+
+// SaveReorderLevelsRequest sets the reorder level of products at one location.
+type SaveReorderLevelsRequest struct {
+	IDMstStockLocation int64                 `json:"-"`
+	Items              []ReorderLevelRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+// TrxStockTransfer is a document moving item stock from one location to another.
+type TrxStockTransfer struct {
+	ID                     int64                  `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution       int64                  `xorm:"'id_mst_institution'" json:"-"`
+	FromIDMstStockLocation int64                  `xorm:"'from_id_mst_stock_location'" json:"from_location_id"`
+	ToIDMstStockLocation   int64                  `xorm:"'to_id_mst_stock_location'" json:"to_location_id"`
+	Notes                  string                 `xorm:"'notes'" json:"notes"`
+	CreatedBy              string                 `xorm:"'created_by'" json:"created_by"`
+	CreateTime             time.Time              `xorm:"'create_time' created" json:"create_time"`
+	Items                  []StockTransferItemRow `xorm:"-" json:"items,omitempty"`
+}
+
+type DtlStockTransferItem struct {
+	ID                      int64 `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxStockTransfer      int64 `xorm:"'id_trx_stock_transfer'" json:"-"`
+	IDTrxInstitutionProduct int64 `xorm:"'id_trx_institution_product'" json:"product_id"`
+	Quantity                int64 `xorm:"'quantity'" json:"quantity"`
+}
+
+// StockTransferItemRow is a transfer line with the product's name and base unit.
+type StockTransferItemRow struct {
+	DtlStockTransferItem `xorm:"extends"`
+	Name                 string `xorm:"'name'" json:"name"`
+	UnitType             string `xorm:"'unit_type'" json:"unit_type"`
+}
+
+// StockTransferItemRequest moves Quantity of a product, in its base unit.
+type StockTransferItemRequest struct {
+	IDTrxInstitutionProduct int64 `json:"product_id" validate:"required"`
+	Quantity                int64 `json:"quantity" validate:"required,gt=0"`
+}
+
+type CreateStockTransferRequest struct {
+	FromIDMstStockLocation int64                      `json:"from_location_id" validate:"required"`
+	ToIDMstStockLocation   int64                      `json:"to_location_id" validate:"required"`
+	Notes                  string                     `json:"notes" validate:"max=500"`
+	Items                  []StockTransferItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type ListStockTransferParams struct {
+	IDMstStockLocation int64 `schema:"location_id"`
+	IDMstInstitution   int64 `schema:"-"`
+	CommonRequestPayload
+}
+
+// StockTransferItemError explains why line Index of a transfer request cannot be moved.
+type StockTransferItemError struct {
+	Index   int
+	Field   string
+	Message string
+}
+
+// CheckStockTransferItems validates the lines of a transfer against the stock at
+// its source: onHand holds the quantity there of every item product of the
+// institution, so a product missing from it is not a stock-keeping item.
+func CheckStockTransferItems(items []StockTransferItemRequest, onHand map[int64]int64) []StockTransferItemError {
+	var errs []StockTransferItemError
+	seen := make(map[int64]struct{}, len(items))
+	for i, item := range items {
+		available, isItem := onHand[item.IDTrxInstitutionProduct]
+		if !isItem {
+			errs = append(errs, StockTransferItemError{i, "product_id", "product must be an item product of this institution"})
+			continue
+		}
+		if _, dup := seen[item.IDTrxInstitutionProduct]; dup {
+			errs = append(errs, StockTransferItemError{i, "product_id", "product is already listed"})
+			continue
+		}
+		seen[item.IDTrxInstitutionProduct] = struct{}{}
+
+		if item.Quantity > available {
+			errs = append(errs, StockTransferItemError{i, "quantity", fmt.Sprintf("only %d on hand at the source location", available)})
+		}
+	}
+	return errs
+}
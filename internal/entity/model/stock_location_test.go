@@ -0,0 +1,54 @@
+package model
+
+import "testing"
+
+func TestCheckStockTransferItems(t *testing.T) {
+	t.Parallel()
+
+	onHand := map[int64]int64{1: 10, 2: 0}
+	items := []StockTransferItemRequest{
+		{IDTrxInstitutionProduct: 1, Quantity: 10},
+		{IDTrxInstitutionProduct: 2, Quantity: 1},
+		{IDTrxInstitutionProduct: 3, Quantity: 1},
+		{IDTrxInstitutionProduct: 1, Quantity: 1},
+	}
+
+	errs := CheckStockTransferItems(items, onHand)
+	want := []StockTransferItemError{
+		{Index: 1, Field: "quantity"},
+		{Index: 2, Field: "product_id"},
+		{Index: 3, Field: "product_id"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got errors %+v, want %+v", errs, want)
+	}
+	for i := range want {
+		if errs[i].Index != want[i].Index || errs[i].Field != want[i].Field {
+			t.Fatalf("error %d = %+v, want %+v", i, errs[i], want[i])
+		}
+	}
+
+	if errs := CheckStockTransferItems(items[:1], onHand); len(errs) != 0 {
+		t.Fatalf("moving all stock on hand should pass, got %+v", errs)
+	}
+}
+
+func TestLocationStockRowIsLow(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		quantity, reorderLevel int64
+		want                   bool
+	}{
+		{quantity: 0, reorderLevel: 0, want: false},
+		{quantity: 5, reorderLevel: 5, want: true},
+		{quantity: 6, reorderLevel: 5, want: false},
+		{quantity: -2, reorderLevel: 1, want: true},
+	}
+	for _, c := range cases {
+		row := LocationStockRow{Quantity: c.quantity, ReorderLevel: c.reorderLevel}
+		if got := row.IsLow(); got != c.want {
+			t.Fatalf("IsLow() with quantity %d and reorder level %d = %v, want %v", c.quantity, c.reorderLevel, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	TrxStockTakeTableName      = "mdl_trx_stock_take"
+	DtlStockTakeItemTableName  = "mdl_dtl_stock_take_item"
+	DtlStockTakeCountTableName = "mdl_dtl_stock_take_count"
+)
+
+// Stock-take statuses. A session is open until it is finalized or cancelled.
+const (
+	StockTakeStatusOpen      = "open"
+	StockTakeStatusFinalized = "finalized"
+	StockTakeStatusCancelled = "cancelled"
+)
+
+// TrxStockTake is a count of the stock held at one location.
+type TrxStockTake struct {
+	ID                 int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDMstInstitution   int64      `xorm:"'id_mst_institution'" json:"-"`
+	IDMstStockLocation int64      `xorm:"'id_mst_stock_location'" json:"location_id"`
+	Status             string     `xorm:"'status'" json:"status"`
+	Notes              string     `xorm:"'notes'" json:"notes"`
+	FinalizeReason     string     `xorm:"'finalize_reason'" json:"finalize_reason,omitempty"`
+	CreatedBy          string     `xorm:"'created_by'" json:"created_by"`
+	FinalizedBy        string     `xorm:"'finalized_by'" json:"finalized_by,omitempty"`
+	FinalizedAt        *time.Time `xorm:"'finalized_at'" json:"finalized_at,omitempty"`
+	CreateTime         time.Time  `xorm:"'create_time' created" json:"create_time"`
+	UpdateTime         time.Time  `xorm:"'update_time' updated" json:"update_time"`
+	DeleteTime         *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// DtlStockTakeItem is the snapshot of one product at the session's location,
+// taken when the session opened.
+type DtlStockTakeItem struct {
+	ID                      int64       `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxStockTake          int64       `xorm:"'id_trx_stock_take'" json:"-"`
+	IDTrxInstitutionProduct int64       `xorm:"'id_trx_institution_product'" json:"product_id"`
+	Name                    string      `xorm:"'name'" json:"name"`
+	UnitType                string      `xorm:"'unit_type'" json:"unit_type"`
+	SystemQuantity          int64       `xorm:"'system_quantity'" json:"system_quantity"`
+	UnitCost                money.Money `xorm:"'unit_cost'" json:"unit_cost"`
+	AdjustmentReason        string      `xorm:"'adjustment_reason'" json:"adjustment_reason,omitempty"`
+	CreateTime              time.Time   `xorm:"'create_time' created" json:"-"`
+	UpdateTime              time.Time   `xorm:"'update_time' updated" json:"-"`
+}
+
+// DtlStockTakeCount is one counter's entry for an item.
+type DtlStockTakeCount struct {
+	ID                 int64     `xorm:"'id' pk autoincr" json:"-"`
+	IDDtlStockTakeItem int64     `xorm:"'id_dtl_stock_take_item'" json:"item_id"`
+	CountedBy          string    `xorm:"'counted_by'" json:"counted_by"`
+	CountedQuantity    int64     `xorm:"'counted_quantity'" json:"counted_quantity"`
+	CreateTime         time.Time `xorm:"'create_time' created" json:"-"`
+	UpdateTime         time.Time `xorm:"'update_time' updated" json:"update_time"`
+}
+
+// StockTakeItemRow is an item joined with the sum of its counts.
+// CountedQuantity is nil when nobody has counted the item yet.
+type StockTakeItemRow struct {
+	DtlStockTakeItem `xorm:"extends"`
+	CountedQuantity  *int64 `xorm:"'counted_quantity'"`
+	CounterTotal     int    `xorm:"'counter_total'"`
+}
+
+// CreateStockTakeRequest opens a session counting one location; without a
+// location the main location is counted.
+type CreateStockTakeRequest struct {
+	IDMstStockLocation int64  `json:"location_id"`
+	Notes              string `json:"notes"`
+}
+
+type ListStockTakeParams struct {
+	Status             string `schema:"status" validate:"omitempty,oneof=open finalized cancelled"`
+	IDMstStockLocation int64  `schema:"location_id"`
+	IDMstInstitution   int64  `schema:"-"`
+	CommonRequestPayload
+}
+
+type StockTakeCountItemRequest struct {
+	IDDtlStockTakeItem int64 `json:"item_id" validate:"required"`
+	CountedQuantity    int64 `json:"counted_quantity" validate:"gte=0"`
+}
+
+// SaveStockTakeCountRequest records the calling user's counts. Submitting an
+// item again replaces that user's previous count for it.
+type SaveStockTakeCountRequest struct {
+	Items []StockTakeCountItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type StockTakeItemReasonRequest struct {
+	IDDtlStockTakeItem int64  `json:"item_id" validate:"required"`
+	Reason             string `json:"reason" validate:"required"`
+}
+
+// FinalizeStockTakeRequest carries the session-wide adjustment reason and
+// optional per-item reasons that override it on the posted movements.
+type FinalizeStockTakeRequest struct {
+	Reason      string                       `json:"reason" validate:"required"`
+	ItemReasons []StockTakeItemReasonRequest `json:"item_reasons" validate:"omitempty,dive"`
+}
+
+type StockTakeItemResponse struct {
+	DtlStockTakeItem
+	CountedQuantity *int64              `json:"counted_quantity"`
+	Variance        *int64              `json:"variance"`
+	VarianceValue   money.Money         `json:"variance_value"`
+	Counts          []DtlStockTakeCount `json:"counts"`
+}
+
+type StockTakeSummary struct {
+	TotalItems       int         `json:"total_items"`
+	CountedItems     int         `json:"counted_items"`
+	VarianceItems    int         `json:"variance_items"`
+	SurplusQuantity  int64       `json:"surplus_quantity"`
+	ShortageQuantity int64       `json:"shortage_quantity"`
+	VarianceValue    money.Money `json:"variance_value"`
+}
+
+type StockTakeResponse struct {
+	TrxStockTake
+	Summary StockTakeSummary        `json:"summary"`
+	Items   []StockTakeItemResponse `json:"items"`
+}
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	MstInstitutionTaxConfigTableName = "mdl_mst_institution_tax_config"
+)
+
+// Tax categories of institution products. Medical services and medicines are
+// exempt from PPN; non-medical goods sold by a PKP institution are taxed.
+const (
+	TaxCategoryExempt = "exempt"
+	TaxCategoryPPN    = "ppn"
+)
+
+// IsValidTaxCategory reports whether category is a known tax category.
+func IsValidTaxCategory(category string) bool {
+	return category == TaxCategoryExempt || category == TaxCategoryPPN
+}
+
+// MstInstitutionTaxConfig is an institution's tax setup. An institution without
+// a row is not PKP and charges neither PPN nor a service charge.
+type MstInstitutionTaxConfig struct {
+	ID                int64     `xorm:"'id' pk autoincr" json:"-"`
+	IDMstInstitution  int64     `xorm:"'id_mst_institution'" json:"-"`
+	IsPKP             bool      `xorm:"'is_pkp'" json:"is_pkp"`
+	PPNRate           float64   `xorm:"'ppn_rate'" json:"ppn_rate"`
+	PriceIncludesTax  bool      `xorm:"'price_includes_tax'" json:"price_includes_tax"`
+	ServiceChargeRate float64   `xorm:"'service_charge_rate'" json:"service_charge_rate"`
+	UpdatedBy         string    `xorm:"'updated_by'" json:"updated_by"`
+	CreateTime        time.Time `xorm:"'create_time' created" json:"-"`
+	UpdateTime        time.Time `xorm:"'update_time' updated" json:"update_time"`
+}
+
+type UpdateTaxConfigRequest struct {
+	IsPKP             bool    `json:"is_pkp"`
+	PPNRate           float64 `json:"ppn_rate" validate:"gte=0,lt=1"`
+	PriceIncludesTax  bool    `json:"price_includes_tax"`
+	ServiceChargeRate float64 `json:"service_charge_rate" validate:"gte=0,lt=1"`
+}
+
+// TaxRateFor is the PPN rate the institution charges on products of category.
+func (c MstInstitutionTaxConfig) TaxRateFor(category string) float64 {
+	if !c.IsPKP || category != TaxCategoryPPN {
+		return 0
+	}
+	return c.PPNRate
+}
+
+// LineTax is the tax treatment of one visit line.
+type LineTax struct {
+	TaxCategory   string
+	TaxRate       float64
+	TaxInclusive  bool
+	TaxBase       money.Money
+	TaxAmount     money.Money
+	ServiceCharge money.Money
+}
+
+// ComputeLineTax splits the amount charged for a line into its tax base, PPN
+// and service charge. With tax-inclusive pricing the PPN is carved out of
+// amount; otherwise it is added on top. The service charge is a share of the
+// tax base and is not itself taxed. Every part is rounded to the cent, and an
+// inclusive line's base and tax always add back up to amount.
+func ComputeLineTax(amount money.Money, category string, config MstInstitutionTaxConfig) LineTax {
+	if !IsValidTaxCategory(category) {
+		category = TaxCategoryExempt
+	}
+	amount = amount.Round()
+	rate := config.TaxRateFor(category)
+
+	line := LineTax{
+		TaxCategory: category,
+		TaxRate:     rate,
+		TaxBase:     amount,
+		TaxAmount:   money.Zero,
+	}
+	if rate > 0 {
+		line.TaxInclusive = config.PriceIncludesTax
+		if line.TaxInclusive {
+			line.TaxBase = amount.DivRate(1 + rate).Round()
+			line.TaxAmount = amount.Sub(line.TaxBase)
+		} else {
+			line.TaxAmount = amount.MulRate(rate).Round()
+		}
+	}
+	line.ServiceCharge = line.TaxBase.MulRate(config.ServiceChargeRate).Round()
+	return line
+}
+
+// ApplyTax stores the tax treatment of the line's charged amount on the line.
+func (p *TrxVisitProduct) ApplyTax(category string, config MstInstitutionTaxConfig) {
+	line := ComputeLineTax(p.ChargedAmount(), category, config)
+	p.TaxCategory = line.TaxCategory
+	p.TaxRate = line.TaxRate
+	p.TaxInclusive = line.TaxInclusive
+	p.TaxBase = line.TaxBase
+	p.TaxAmount = line.TaxAmount
+	p.ServiceCharge = line.ServiceCharge
+}
+
+// ChargedAmount is what the line bills before tax handling: the adjusted price
+// when one was agreed, otherwise the discounted total.
+func (p TrxVisitProduct) ChargedAmount() money.Money {
+	if p.AdjustedPrice.IsPositive() {
+		return p.AdjustedPrice
+	}
+	return p.TotalPrice
+}
+
+// GrandTotal is what the patient pays for the line.
+func (p TrxVisitProduct) GrandTotal() money.Money {
+	return p.TaxBase.Add(p.TaxAmount).Add(p.ServiceCharge)
+}
+
+// VisitTaxBreakdownItem sums the lines of one tax category and rate.
+type VisitTaxBreakdownItem struct {
+	TaxCategory   string      `json:"tax_category"`
+	TaxRate       float64     `json:"tax_rate"`
+	TaxBase       money.Money `json:"tax_base"`
+	TaxAmount     money.Money `json:"tax_amount"`
+	ServiceCharge money.Money `json:"service_charge"`
+}
+
+// VisitTotals is the bill of a visit, broken down by tax category.
+type VisitTotals struct {
+	Subtotal      money.Money             `json:"subtotal"`
+	TaxAmount     money.Money             `json:"tax_amount"`
+	ServiceCharge money.Money             `json:"service_charge"`
+	GrandTotal    money.Money             `json:"grand_total"`
+	TaxBreakdown  []VisitTaxBreakdownItem `json:"tax_breakdown"`
+}
+
+// NewVisitTotals adds up the stored tax treatment of the lines. Breakdown items
+// follow the order in which their category and rate first appear.
+func NewVisitTotals(lines []TrxVisitProduct) VisitTotals {
+	totals := VisitTotals{
+		Subtotal:      money.Zero,
+		TaxAmount:     money.Zero,
+		ServiceCharge: money.Zero,
+		GrandTotal:    money.Zero,
+		TaxBreakdown:  []VisitTaxBreakdownItem{},
+	}
+
+	type breakdownKey struct {
+		category string
+		rate     float64
+	}
+	index := map[breakdownKey]int{}
+	for _, line := range lines {
+		totals.Subtotal = totals.Subtotal.Add(line.TaxBase)
+		totals.TaxAmount = totals.TaxAmount.Add(line.TaxAmount)
+		totals.ServiceCharge = totals.ServiceCharge.Add(line.ServiceCharge)
+
+		category := line.TaxCategory
+		if category == "" {
+			category = TaxCategoryExempt
+		}
+		key := breakdownKey{category: category, rate: line.TaxRate}
+		i, ok := index[key]
+		if !ok {
+			totals.TaxBreakdown = append(totals.TaxBreakdown, VisitTaxBreakdownItem{
+				TaxCategory:   category,
+				TaxRate:       line.TaxRate,
+				TaxBase:       money.Zero,
+				TaxAmount:     money.Zero,
+				ServiceCharge: money.Zero,
+			})
+			i = len(totals.TaxBreakdown) - 1
+			index[key] = i
+		}
+		item := &totals.TaxBreakdown[i]
+		item.TaxBase = item.TaxBase.Add(line.TaxBase)
+		item.TaxAmount = item.TaxAmount.Add(line.TaxAmount)
+		item.ServiceCharge = item.ServiceCharge.Add(line.ServiceCharge)
+	}
+	totals.GrandTotal = totals.Subtotal.Add(totals.TaxAmount).Add(totals.ServiceCharge)
+	return totals
+}
+
+// VisitInvoiceResponse is the priced bill of a visit.
+type VisitInvoiceResponse struct {
+	IDTrxPatientVisit int64                   `json:"visit_id"`
+	PatientCategory   string                  `json:"patient_category"`
+	TaxConfig         MstInstitutionTaxConfig `json:"tax_config"`
+	Lines             []TrxVisitProduct       `json:"lines"`
+	Totals            VisitTotals             `json:"totals"`
+	Split             VisitInvoiceSplit       `json:"split"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestComputeLineTax(t *testing.T) {
+	t.Parallel()
+
+	pkp := MstInstitutionTaxConfig{IsPKP: true, PPNRate: 0.11, ServiceChargeRate: 0.05}
+	inclusive := pkp
+	inclusive.PriceIncludesTax = true
+
+	cases := []struct {
+		name               string
+		amount             money.Money
+		category           string
+		config             MstInstitutionTaxConfig
+		base, tax, service string
+		rate               float64
+		wantInclusive      bool
+	}{
+		{"exclusive ppn", money.New(100000), TaxCategoryPPN, pkp, "100000", "11000", "5000", 0.11, false},
+		{"inclusive ppn", money.New(111000), TaxCategoryPPN, inclusive, "100000", "11000", "5000", 0.11, true},
+		{"inclusive ppn rounds to the cent", money.New(10000), TaxCategoryPPN, inclusive, "9009.01", "990.99", "450.45", 0.11, true},
+		{"exempt on pkp", money.New(100000), TaxCategoryExempt, pkp, "100000", "0", "5000", 0, false},
+		{"ppn on non-pkp", money.New(100000), TaxCategoryPPN, MstInstitutionTaxConfig{PPNRate: 0.11}, "100000", "0", "0", 0, false},
+		{"unknown category is exempt", money.New(100000), "", pkp, "100000", "0", "5000", 0, false},
+	}
+	for _, c := range cases {
+		got := ComputeLineTax(c.amount, c.category, c.config)
+		if got.TaxBase.String() != c.base || got.TaxAmount.String() != c.tax || got.ServiceCharge.String() != c.service {
+			t.Fatalf("%s: expected base %s tax %s service %s, got %s %s %s",
+				c.name, c.base, c.tax, c.service, got.TaxBase, got.TaxAmount, got.ServiceCharge)
+		}
+		if got.TaxRate != c.rate || got.TaxInclusive != c.wantInclusive {
+			t.Fatalf("%s: unexpected rate %v inclusive %v", c.name, got.TaxRate, got.TaxInclusive)
+		}
+		if c.wantInclusive && !got.TaxBase.Add(got.TaxAmount).Equal(c.amount) {
+			t.Fatalf("%s: inclusive parts do not add up to %s", c.name, c.amount)
+		}
+	}
+}
+
+func TestNewVisitTotals(t *testing.T) {
+	t.Parallel()
+
+	config := MstInstitutionTaxConfig{IsPKP: true, PPNRate: 0.11, ServiceChargeRate: 0.1}
+	lines := []TrxVisitProduct{
+		{TotalPrice: money.New(50000)},
+		{TotalPrice: money.New(20000), AdjustedPrice: money.New(10000)},
+		{TotalPrice: money.New(30000)},
+	}
+	lines[0].ApplyTax(TaxCategoryExempt, config)
+	lines[1].ApplyTax(TaxCategoryPPN, config)
+	lines[2].ApplyTax(TaxCategoryExempt, config)
+
+	totals := NewVisitTotals(lines)
+	if totals.Subtotal.String() != "90000" || totals.TaxAmount.String() != "1100" ||
+		totals.ServiceCharge.String() != "9000" || totals.GrandTotal.String() != "100100" {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+	if len(totals.TaxBreakdown) != 2 {
+		t.Fatalf("expected 2 breakdown items, got %+v", totals.TaxBreakdown)
+	}
+	exempt := totals.TaxBreakdown[0]
+	if exempt.TaxCategory != TaxCategoryExempt || exempt.TaxBase.String() != "80000" || !exempt.TaxAmount.IsZero() {
+		t.Fatalf("unexpected exempt breakdown: %+v", exempt)
+	}
+	ppn := totals.TaxBreakdown[1]
+	if ppn.TaxCategory != TaxCategoryPPN || ppn.TaxBase.String() != "10000" || ppn.TaxAmount.String() != "1100" {
+		t.Fatalf("unexpected ppn breakdown: %+v", ppn)
+	}
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	DtlTreatmentMaterialTableName      = "mdl_dtl_treatment_material"
+	DtlVisitTreatmentMaterialTableName = "mdl_dtl_visit_treatment_material"
+)
+
+// Stock movements posted when treatments consume their materials.
+const (
+	StockMovementTypeTreatmentConsumption     = "treatment_consumption"
+	StockMovementTypeTreatmentConsumptionVoid = "treatment_consumption_void"
+
+	StockMovementReferenceVisit = "visit"
+)
+
+// DtlTreatmentMaterial is one line of a treatment's bill of materials: the item
+// product consumed by one unit of the treatment, in the material's base unit.
+type DtlTreatmentMaterial struct {
+	ID                      int64      `xorm:"'id' pk autoincr" json:"id"`
+	IDTrxInstitutionProduct int64      `xorm:"'id_trx_institution_product'" json:"product_id"`
+	IDMaterialProduct       int64      `xorm:"'id_material_product'" json:"material_product_id"`
+	Quantity                int64      `xorm:"'quantity'" json:"quantity"`
+	CreateTime              time.Time  `xorm:"'create_time' created" json:"-"`
+	UpdateTime              time.Time  `xorm:"'update_time' updated" json:"-"`
+	DeleteTime              *time.Time `xorm:"'delete_time' deleted" json:"-"`
+}
+
+// TreatmentMaterialRow is a material line joined with the material's name,
+// base unit and average cost.
+type TreatmentMaterialRow struct {
+	DtlTreatmentMaterial `xorm:"extends"`
+	Name                 string      `xorm:"'name'" json:"name"`
+	UnitType             string      `xorm:"'unit_type'" json:"unit_type"`
+	AvgCost              money.Money `xorm:"'avg_cost'" json:"-"`
+}
+
+// DtlVisitTreatmentMaterial is one line of the bill of materials a treatment
+// had when a visit first consumed it. A treatment without materials is
+// recorded by a line with no material product.
+type DtlVisitTreatmentMaterial struct {
+	ID                      int64     `xorm:"'id' pk autoincr"`
+	IDTrxPatientVisit       int64     `xorm:"'id_trx_patient_visit'"`
+	IDTrxInstitutionProduct int64     `xorm:"'id_trx_institution_product'"`
+	IDMaterialProduct       int64     `xorm:"'id_material_product'"`
+	Quantity                int64     `xorm:"'quantity'"`
+	CreateTime              time.Time `xorm:"'create_time' created"`
+}
+
+type TreatmentMaterialRequest struct {
+	IDMaterialProduct int64 `json:"material_product_id" validate:"required"`
+	Quantity          int64 `json:"quantity" validate:"required,gt=0"`
+}
+
+// SaveTreatmentMaterialsRequest replaces the bill of materials of a treatment product.
+type SaveTreatmentMaterialsRequest struct {
+	IDTrxInstitutionProduct int64                      `json:"product_id" validate:"required"`
+	Materials               []TreatmentMaterialRequest `json:"materials" validate:"dive"`
+}
+
+type ListTreatmentMaterialsParams struct {
+	IDTrxInstitutionProduct int64 `schema:"product_id" validate:"required"`
+}
+
+type TreatmentMaterialsResponse struct {
+	IDTrxInstitutionProduct int64                  `json:"product_id"`
+	Materials               []TreatmentMaterialRow `json:"materials"`
+}
+
+// TreatmentUsage is how many units of a treatment product a visit performed.
+type TreatmentUsage struct {
+	IDTrxInstitutionProduct int64 `xorm:"'id_trx_institution_product'"`
+	Quantity                int64 `xorm:"'quantity'"`
+}
+
+// MaterialStockBalance is the net quantity a document has moved for one product
+// at one stock location.
+type MaterialStockBalance struct {
+	IDTrxInstitutionProduct int64 `xorm:"'id_trx_institution_product'"`
+	IDMstStockLocation      int64 `xorm:"'id_mst_stock_location'"`
+	Quantity                int64 `xorm:"'quantity'"`
+}
+
+// TreatmentMaterialDeltas returns, per material product, the signed stock
+// quantity still to post so that the ledger matches the consumption of usage:
+// each treatment consumes its materials once per unit, and posted holds the net
+// quantity already moved (negative for consumed). Products already in balance
+// are absent.
+func TreatmentMaterialDeltas(usage []TreatmentUsage, materials []TreatmentMaterialRow, posted []MaterialStockBalance) map[int64]int64 {
+	treatmentQuantity := make(map[int64]int64, len(usage))
+	for _, u := range usage {
+		treatmentQuantity[u.IDTrxInstitutionProduct] += u.Quantity
+	}
+
+	deltas := make(map[int64]int64)
+	for _, material := range materials {
+		quantity := treatmentQuantity[material.IDTrxInstitutionProduct]
+		if quantity <= 0 {
+			continue
+		}
+		deltas[material.IDMaterialProduct] -= material.Quantity * quantity
+	}
+	for _, balance := range posted {
+		deltas[balance.IDTrxInstitutionProduct] -= balance.Quantity
+	}
+	for productID, delta := range deltas {
+		if delta == 0 {
+			delete(deltas, productID)
+		}
+	}
+	return deltas
+}
+
+// RestoreMaterialStock splits quantity, a positive quantity of product to put
+// back, over the locations posted consumed it at, each getting back at most
+// what it gave. Whatever no location gave is left under location 0.
+func RestoreMaterialStock(productID, quantity int64, posted []MaterialStockBalance) map[int64]int64 {
+	restored := make(map[int64]int64)
+	for _, balance := range posted {
+		if quantity <= 0 {
+			break
+		}
+		if balance.IDTrxInstitutionProduct != productID || balance.Quantity >= 0 {
+			continue
+		}
+		back := -balance.Quantity
+		if back > quantity {
+			back = quantity
+		}
+		restored[balance.IDMstStockLocation] += back
+		quantity -= back
+	}
+	if quantity > 0 {
+		restored[0] += quantity
+	}
+	return restored
+}
@@ -0,0 +1,66 @@
+package model
+
+import "testing"
+
+func TestTreatmentMaterialDeltas(t *testing.T) {
+	t.Parallel()
+
+	materials := []TreatmentMaterialRow{
+		{DtlTreatmentMaterial: DtlTreatmentMaterial{IDTrxInstitutionProduct: 1, IDMaterialProduct: 10, Quantity: 2}},
+		{DtlTreatmentMaterial: DtlTreatmentMaterial{IDTrxInstitutionProduct: 1, IDMaterialProduct: 11, Quantity: 1}},
+		{DtlTreatmentMaterial: DtlTreatmentMaterial{IDTrxInstitutionProduct: 2, IDMaterialProduct: 10, Quantity: 3}},
+	}
+
+	// nothing posted yet: both treatments consume, sharing material 10
+	deltas := TreatmentMaterialDeltas(
+		[]TreatmentUsage{{IDTrxInstitutionProduct: 1, Quantity: 2}, {IDTrxInstitutionProduct: 2, Quantity: 1}},
+		materials, nil)
+	if deltas[10] != -7 || deltas[11] != -2 || len(deltas) != 2 {
+		t.Fatalf("unexpected first consumption: %v", deltas)
+	}
+
+	// treatment 1 reduced to one unit after the first posting
+	posted := []MaterialStockBalance{{IDTrxInstitutionProduct: 10, Quantity: -7}, {IDTrxInstitutionProduct: 11, Quantity: -2}}
+	deltas = TreatmentMaterialDeltas(
+		[]TreatmentUsage{{IDTrxInstitutionProduct: 1, Quantity: 1}, {IDTrxInstitutionProduct: 2, Quantity: 1}},
+		materials, posted)
+	if deltas[10] != 2 || deltas[11] != 1 || len(deltas) != 2 {
+		t.Fatalf("unexpected partial reversal: %v", deltas)
+	}
+
+	// everything voided: the ledger is restored in full
+	deltas = TreatmentMaterialDeltas(nil, materials, posted)
+	if deltas[10] != 7 || deltas[11] != 2 {
+		t.Fatalf("unexpected full reversal: %v", deltas)
+	}
+
+	// already in balance
+	deltas = TreatmentMaterialDeltas(
+		[]TreatmentUsage{{IDTrxInstitutionProduct: 1, Quantity: 2}, {IDTrxInstitutionProduct: 2, Quantity: 1}},
+		materials, posted)
+	if len(deltas) != 0 {
+		t.Fatalf("expected no deltas, got %v", deltas)
+	}
+}
+
+func TestRestoreMaterialStock(t *testing.T) {
+	t.Parallel()
+
+	// material 10 was consumed at two locations and partly returned at one
+	posted := []MaterialStockBalance{
+		{IDTrxInstitutionProduct: 10, IDMstStockLocation: 1, Quantity: -4},
+		{IDTrxInstitutionProduct: 11, IDMstStockLocation: 1, Quantity: -5},
+		{IDTrxInstitutionProduct: 10, IDMstStockLocation: 2, Quantity: -3},
+		{IDTrxInstitutionProduct: 10, IDMstStockLocation: 3, Quantity: 0},
+	}
+
+	restored := RestoreMaterialStock(10, 5, posted)
+	if restored[1] != 4 || restored[2] != 1 || len(restored) != 2 {
+		t.Fatalf("unexpected partial restore: %v", restored)
+	}
+
+	restored = RestoreMaterialStock(10, 9, posted)
+	if restored[1] != 4 || restored[2] != 3 || restored[0] != 2 || len(restored) != 3 {
+		t.Fatalf("unexpected restore beyond what was consumed: %v", restored)
+	}
+}
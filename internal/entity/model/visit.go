@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	"github.com/volatiletech/null/v8"
 )
 
@@ -23,6 +24,12 @@ type TrxPatientVisit struct {
 	DeleteTime                  *time.Time      `json:"-" xorm:"'delete_time' deleted"`
 	UpdateTimeMstJourneyPointID int64           `json:"column_update_time" xorm:"'mst_journey_point_id_update_unix_time' created"`
 	ProductCart                 json.RawMessage `xorm:"'product_cart'" json:"product_cart"`
+	PatientCategory             string          `xorm:"'patient_category'" json:"patient_category"`
+	// the payer the visit is billed to, with its coverage when selected
+	IDMstPatientPayer  null.Int64      `xorm:"'id_mst_patient_payer'" json:"payer_id"`
+	PayerType          string          `xorm:"'payer_type'" json:"payer_type"`
+	PayerCoverageRate  float64         `xorm:"'payer_coverage_rate'" json:"payer_coverage_rate"`
+	PayerCoverageLimit money.NullMoney `xorm:"'payer_coverage_limit'" json:"payer_coverage_limit"`
 }
 
 func (tbl *TrxPatientVisit) BeforeUpdate() {
@@ -96,6 +103,12 @@ type InsertNewVisitRequest struct {
 	PatientUUID         string          `json:"patient_uuid"`
 	JourneyPointShortID string          `json:"journey_point_id"`
 	Notes               json.RawMessage `json:"notes"`
+	// PatientCategory overrides the patient's category for this visit only.
+	PatientCategory string `json:"patient_category" validate:"omitempty,oneof=general bpjs insurance corporate staff"`
+	// PayerID bills the visit to one of the patient's payers, whose category
+	// then prices it. The patient's default payer is used when it is zero and
+	// no PatientCategory is given.
+	PayerID int64 `json:"payer_id"`
 }
 
 type UpdatePatientVisitRequest struct {
@@ -170,6 +183,7 @@ type GetPatientVisitDetailResponse struct {
 	Anamnesa        null.JSON                    `json:"anamnesa"`
 	Diagnoses       []DiagnosisResponse          `json:"diagnoses"`
 	Procedures      []ProcedureEntry             `json:"procedures"`
+	Totals          VisitTotals                  `json:"totals"`
 }
 
 // AnamnesaDetailedToNullJSON encodes an optional anamnesa payload for APIs that
@@ -0,0 +1,18 @@
+package attachment
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// AttachmentDB is the data-access contract for patient attachment records.
+// The files themselves are kept in storage.
+type AttachmentDB interface {
+	InsertAttachment(ctx context.Context, attachment *model.TrxPatientAttachment) error
+	GetAttachment(ctx context.Context, institutionID, attachmentID int64) (model.TrxPatientAttachment, bool, error)
+	// ListAttachments returns the patient's attachments matching params, newest first.
+	ListAttachments(ctx context.Context, institutionID, patientID int64, params model.ListAttachmentParams) ([]model.TrxPatientAttachment, error)
+	// DeleteAttachment soft-deletes the attachment, returning constant.ErrorNoAffectedRow when there is none.
+	DeleteAttachment(ctx context.Context, institutionID, attachmentID int64) error
+}
@@ -0,0 +1,37 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+type BillingDB interface {
+	// LockPatientVisit locks the visit row so payments and refund documents of
+	// one visit are written one at a time. It must run inside a transaction.
+	LockPatientVisit(ctx context.Context, institutionID, visitID int64) (found bool, err error)
+
+	InsertVisitPayment(ctx context.Context, payment *model.TrxVisitPayment) error
+	ListVisitPayments(ctx context.Context, institutionID, visitID int64) ([]model.TrxVisitPayment, error)
+
+	// InsertVisitRefund writes the document and its lines.
+	InsertVisitRefund(ctx context.Context, refund *model.TrxVisitRefund) error
+	// ListVisitRefunds returns the documents of a visit with their lines.
+	ListVisitRefunds(ctx context.Context, institutionID, visitID int64) ([]model.TrxVisitRefund, error)
+	// SumRefundedVisitLines sums what earlier documents returned of each line of a visit.
+	SumRefundedVisitLines(ctx context.Context, institutionID, visitID int64) ([]model.RefundedLineTotals, error)
+
+	// LockPatient locks the patient row so postings to one account are written
+	// one at a time. Lock the patient before any of their visits.
+	LockPatient(ctx context.Context, institutionID, patientID int64) (found bool, err error)
+	InsertLedgerEntries(ctx context.Context, entries []model.TrxPatientLedgerEntry) error
+	SumLedgerByType(ctx context.Context, institutionID, patientID int64) ([]model.PatientLedgerTypeTotal, error)
+	// SumVisitCharges is what has been charged for a visit so far, as a positive amount.
+	SumVisitCharges(ctx context.Context, institutionID, visitID int64) (money.Money, error)
+	// ListLedgerEntries returns the entries of a patient in posting order.
+	ListLedgerEntries(ctx context.Context, params model.PatientAccountStatementParams) ([]model.TrxPatientLedgerEntry, error)
+	// GetLedgerBalanceBefore is the balance of a patient account before a moment.
+	GetLedgerBalanceBefore(ctx context.Context, institutionID, patientID int64, before time.Time) (money.Money, error)
+}
@@ -0,0 +1,28 @@
+package consent
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// ConsentDB is the data-access contract for consent templates and signed
+// consents. Mutating methods honour an active xorm session from the request
+// context.
+type ConsentDB interface {
+	// ListConsentTemplates returns the active template of each category.
+	ListConsentTemplates(ctx context.Context, institutionID int64) ([]model.MstConsentTemplate, error)
+	GetConsentTemplate(ctx context.Context, institutionID, templateID int64) (model.MstConsentTemplate, bool, error)
+	// GetActiveConsentTemplate returns the active template of category, locking it inside a transaction.
+	GetActiveConsentTemplate(ctx context.Context, institutionID int64, category string) (model.MstConsentTemplate, bool, error)
+	InsertConsentTemplate(ctx context.Context, template *model.MstConsentTemplate) error
+	// RetireConsentTemplate soft-deletes the template, returning constant.ErrorNoAffectedRow when it is not active.
+	RetireConsentTemplate(ctx context.Context, institutionID, templateID int64) error
+	// ListRequiredConsentCategories returns the categories whose active template is required.
+	ListRequiredConsentCategories(ctx context.Context, institutionID int64) ([]string, error)
+
+	InsertConsent(ctx context.Context, consent *model.TrxConsent) error
+	// ListVisitConsents returns the consents of the visit without their signature images, oldest first.
+	ListVisitConsents(ctx context.Context, institutionID, visitID int64) ([]model.TrxConsent, error)
+	GetConsentSignature(ctx context.Context, institutionID, consentID int64) (model.ConsentSignature, bool, error)
+}
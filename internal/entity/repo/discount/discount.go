@@ -0,0 +1,28 @@
+package discount
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// DiscountDB is the data-access contract for discount policies, approvals and
+// the price override audit. Mutating methods honour an active xorm session
+// from the request context.
+type DiscountDB interface {
+	ListDiscountPolicies(ctx context.Context, institutionID int64) ([]model.MstDiscountPolicy, error)
+	// ReplaceDiscountPolicies soft-deletes the institution's policies and inserts the given set.
+	ReplaceDiscountPolicies(ctx context.Context, institutionID int64, policies []model.MstDiscountPolicy) error
+
+	InsertDiscountApproval(ctx context.Context, approval *model.TrxDiscountApproval) error
+	// GetDiscountApprovalByID returns nil when the request does not exist in the institution.
+	GetDiscountApprovalByID(ctx context.Context, institutionID, approvalID int64) (*model.TrxDiscountApproval, error)
+	// LockDiscountApproval reads the request with SELECT ... FOR UPDATE on the session from ctx.
+	LockDiscountApproval(ctx context.Context, institutionID, approvalID int64) (*model.TrxDiscountApproval, error)
+	// UpdateDiscountApproval overwrites status and the decision fields.
+	UpdateDiscountApproval(ctx context.Context, approval *model.TrxDiscountApproval) error
+	ListDiscountApprovals(ctx context.Context, params model.ListDiscountApprovalParams) ([]model.TrxDiscountApproval, error)
+
+	InsertPriceOverrideAudits(ctx context.Context, audits []model.TrxPriceOverrideAudit) error
+	ListPriceOverrideAudits(ctx context.Context, params model.ListPriceOverrideAuditParams) ([]model.TrxPriceOverrideAudit, error)
+}
@@ -0,0 +1,23 @@
+package doctorfee
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// DoctorFeeDB is the data-access contract for doctor fee rules and the
+// procedures they are paid on. Mutating methods honour an active xorm session
+// from the request context.
+type DoctorFeeDB interface {
+	ListDoctorFeeRules(ctx context.Context, institutionID int64) ([]model.MstDoctorFeeRule, error)
+	// ReplaceDoctorFeeRules soft-deletes the institution's rules and inserts the given set.
+	ReplaceDoctorFeeRules(ctx context.Context, institutionID int64, rules []model.MstDoctorFeeRule) error
+
+	// ListVisitTotals returns the invoice and paid totals of the visits with
+	// lines created in the period.
+	ListVisitTotals(ctx context.Context, query model.DoctorFeeReportQuery) ([]model.DoctorFeeVisitTotals, error)
+	// ListPaidVisitProcedures returns the procedures of the given paid visits,
+	// ordered by doctor and visit.
+	ListPaidVisitProcedures(ctx context.Context, query model.DoctorFeeReportQuery, visitIDs []int64) ([]model.DoctorFeeProcedureRow, error)
+}
@@ -2,8 +2,10 @@ package institution
 
 import (
 	"context"
+	"time"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/pkg/type/money"
 )
 
 type InstitutionDB interface {
@@ -16,8 +18,44 @@ type InstitutionDB interface {
 	InsertInstitutionProductStock(ctx context.Context, product *model.DtlInstitutionProductStock) (err error)
 	FindTrxInstitutionProductStockByParams(ctx context.Context, request model.DtlInstitutionProductStock) (stock []model.DtlInstitutionProductStock, err error)
 	FindTrxInstitutionProductJoinStockByParams(ctx context.Context, request model.FindTrxInstitutionProductParams) (products []model.GetInstitutionProductResponse, err error)
+	FindProductCatalog(ctx context.Context, institutionID int64) (products []model.GetInstitutionProductResponse, err error)
 	UpdateDtlInstitutionProductStock(ctx context.Context, request *model.DtlInstitutionProductStock) (err error)
 	UpdateDtlInstitutionProduct(ctx context.Context, request *model.DtlInstitutionProductStock) (err error)
 	RestockDtlInstitutionProductStock(ctx context.Context, request *model.DtlInstitutionProductStock) (err error)
+	ReceiveDtlInstitutionProductStock(ctx context.Context, productID, quantity int64, unitCost money.Money) (err error)
+	AdjustDtlInstitutionProductStock(ctx context.Context, productID, delta int64) (err error)
+	InsertStockMovements(ctx context.Context, movements []model.TrxStockMovement) (err error)
+	InsertStockLocation(ctx context.Context, location *model.MstStockLocation) (err error)
+	FindStockLocations(ctx context.Context, institutionID int64) (locations []model.MstStockLocation, err error)
+	UpdateStockLocation(ctx context.Context, location *model.MstStockLocation) (err error)
+	EnsureMainStockLocation(ctx context.Context, institutionID int64) (location model.MstStockLocation, err error)
+	FindVisitStockLocation(ctx context.Context, institutionID, visitID int64) (location model.MstStockLocation, found bool, err error)
+	FindLocationStocks(ctx context.Context, params model.ListLocationStockParams) (rows []model.LocationStockRow, err error)
+	AdjustLocationStock(ctx context.Context, locationID, productID, delta int64) (err error)
+	SaveLocationReorderLevels(ctx context.Context, locationID int64, levels []model.ReorderLevelRequest) (err error)
+	InsertStockTransfer(ctx context.Context, transfer *model.TrxStockTransfer, items []model.DtlStockTransferItem) (err error)
+	FindStockTransfers(ctx context.Context, params model.ListStockTransferParams) (transfers []model.TrxStockTransfer, err error)
+	GetStockTransfer(ctx context.Context, institutionID, transferID int64) (transfer *model.TrxStockTransfer, err error)
+	FindProductUnitsByProductIDs(ctx context.Context, productIDs []int64) (units []model.DtlInstitutionProductUnit, err error)
+	ReplaceProductUnits(ctx context.Context, productID int64, units []model.DtlInstitutionProductUnit) (err error)
+	FindTreatmentMaterialsByProductIDs(ctx context.Context, productIDs []int64) (materials []model.TreatmentMaterialRow, err error)
+	ReplaceTreatmentMaterials(ctx context.Context, productID int64, materials []model.DtlTreatmentMaterial) (err error)
+	FindVisitTreatmentUsage(ctx context.Context, institutionID, visitID int64) (usage []model.TreatmentUsage, err error)
+	SumStockMovementsByReference(ctx context.Context, institutionID int64, referenceType string, referenceID int64, movementTypes []string) (balances []model.MaterialStockBalance, err error)
+	FindVisitTreatmentMaterials(ctx context.Context, visitID int64) (materials []model.TreatmentMaterialRow, err error)
+	InsertVisitTreatmentMaterials(ctx context.Context, materials []model.DtlVisitTreatmentMaterial) (err error)
+	GetInstitutionTaxConfig(ctx context.Context, institutionID int64) (config model.MstInstitutionTaxConfig, err error)
+	UpsertInstitutionTaxConfig(ctx context.Context, config *model.MstInstitutionTaxConfig) (err error)
+	GetInstitutionMRNConfig(ctx context.Context, institutionID int64) (config model.MstInstitutionMRNConfig, err error)
+	UpsertInstitutionMRNConfig(ctx context.Context, config *model.MstInstitutionMRNConfig) (err error)
 	GetProductStatistics(ctx context.Context, query model.ProductStatisticsQuery) (rows []model.ProductStatisticsRow, err error)
+	GetProductStatisticsFromRollups(ctx context.Context, query model.ProductStatisticsQuery) (rows []model.ProductStatisticsRow, err error)
+	IterateProductStatistics(ctx context.Context, query model.ProductStatisticsQuery, fn func(model.ProductStatisticsRow) error) (err error)
+	IterateProductStatisticsFromRollups(ctx context.Context, query model.ProductStatisticsQuery, fn func(model.ProductStatisticsRow) error) (err error)
+	FindProductStatisticsDirtyDays(ctx context.Context, since, until time.Time, utcOffsetSeconds int) (days []model.ProductStatisticsRollupDay, err error)
+	RebuildProductStatisticsRollups(ctx context.Context, utcOffsetSeconds int, days []model.ProductStatisticsRollupDay) (err error)
+	TryLockRollup(ctx context.Context, name string) (unlock func(), locked bool, err error)
+	GetProductStatisticsSourceStart(ctx context.Context) (start time.Time, err error)
+	GetRollupRefreshedUntil(ctx context.Context, name string) (refreshedUntil time.Time, found bool, err error)
+	SetRollupRefreshedUntil(ctx context.Context, name string, refreshedUntil time.Time) (err error)
 }
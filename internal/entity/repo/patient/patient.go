@@ -2,6 +2,7 @@ package patient
 
 import (
 	"context"
+	"time"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
 )
@@ -14,6 +15,7 @@ type PatientDB interface {
 	GetPatientByID(ctx context.Context, patientID int64) (patient model.MstPatientInstitution, err error)
 	GetPatientByParams(ctx context.Context, patientParam model.MstPatientInstitution) (patient model.MstPatientInstitution, err error)
 	GetPatients(ctx context.Context, params model.GetPatientParams) (patients []model.MstPatientInstitution, err error)
+	SearchPatients(ctx context.Context, params model.GetPatientParams) (patients []model.MstPatientInstitution, err error)
 	UpdatePatient(ctx context.Context, request *model.UpdatePatientRequest) (err error)
 	GetPatientVisits(ctx context.Context, params model.GetPatientVisitParams) (trxPatientVisit []model.GetPatientVisitResponse, err error)
 	UpdatePatientVisit(ctx context.Context, updateRequest model.UpdatePatientVisitRequest) (trxVisit model.TrxPatientVisit, err error)
@@ -28,4 +30,45 @@ type PatientDB interface {
 	DeleteTrxVisitProduct(ctx context.Context, request *model.TrxVisitProduct) (err error)
 	GetTrxVisitProduct(ctx context.Context, params model.GetVisitProductRequest) (trxVisitProduct []model.TrxVisitProduct, err error)
 	ListDtlPatientVisitWithOdontogram(ctx context.Context, limit, offset int) (dtlPatientVisit []model.DtlPatientVisit, err error)
+	FindPatientDuplicateCandidates(ctx context.Context, patient model.MstPatientInstitution) (candidates []model.MstPatientInstitution, err error)
+	MovePatientRecords(ctx context.Context, institutionID, fromID, toID int64) (undo model.PatientMergeUndo, err error)
+	RestorePatientRecords(ctx context.Context, institutionID, fromID, toID int64, undo model.PatientMergeUndo) (err error)
+	SetPatientDeleted(ctx context.Context, institutionID, patientID int64, deleted bool) (err error)
+	InsertPatientMerge(ctx context.Context, merge *model.TrxPatientMerge) (err error)
+	GetPatientMerge(ctx context.Context, institutionID, mergeID int64) (merge model.PatientMergeRow, found bool, err error)
+	ListPatientMerges(ctx context.Context, params model.ListPatientMergeParams) (merges []model.PatientMergeRow, err error)
+	MarkPatientMergeUndone(ctx context.Context, institutionID, mergeID int64, undoneBy string) (err error)
+	NextMedicalRecordNumber(ctx context.Context, institutionID int64, registeredAt time.Time) (number string, err error)
+	ReserveMedicalRecordNumbers(ctx context.Context, institutionID int64, registeredAt time.Time, count int) (numbers []string, err error)
+	ListPatientsWithoutMedicalRecordNumber(ctx context.Context, institutionID int64, limit int) (patients []model.MstPatientInstitution, err error)
+	SetMedicalRecordNumbers(ctx context.Context, patientIDs []int64, numbers []string) (err error)
+	ListPatientTimeline(ctx context.Context, institutionID, patientID int64, params model.GetPatientTimelineParams, cursor *model.TimelineCursor, limit int) (entries []model.TimelineEntry, err error)
+	ListPatientRelations(ctx context.Context, institutionID int64, patientIDs []int64) (relations []model.PatientRelationResponse, err error)
+	GetPatientRelation(ctx context.Context, institutionID, patientID, relationID int64) (relation model.MstPatientRelation, found bool, err error)
+	InsertPatientRelation(ctx context.Context, relation *model.MstPatientRelation) (err error)
+	UpdatePatientRelation(ctx context.Context, relation *model.MstPatientRelation) (err error)
+	DeletePatientRelation(ctx context.Context, institutionID, patientID, relationID int64) (err error)
+	ClearRecallContact(ctx context.Context, institutionID, patientID int64) (err error)
+	GetPatientByLegacyRecordNumber(ctx context.Context, institutionID int64, number string) (patient model.MstPatientInstitution, found bool, err error)
+	InsertPatientImport(ctx context.Context, patientImport *model.TrxPatientImport) (err error)
+	GetPatientImport(ctx context.Context, institutionID, importID int64) (patientImport model.TrxPatientImport, found bool, err error)
+	GetPatientImportWithRows(ctx context.Context, institutionID, importID int64) (patientImport model.TrxPatientImport, found bool, err error)
+	ListPatientImports(ctx context.Context, params model.ListPatientImportParams) (patientImports []model.TrxPatientImport, err error)
+	ClaimPatientImport(ctx context.Context, staleBefore time.Time) (patientImport model.TrxPatientImport, found bool, err error)
+	SavePatientImportProgress(ctx context.Context, patientImport model.TrxPatientImport, lineErrors []model.PatientImportLineError) (err error)
+	FinishPatientImport(ctx context.Context, importID int64, status, failureReason string) (err error)
+	InsertPatientDataRequest(ctx context.Context, request *model.TrxPatientDataRequest) (err error)
+	ListPatientDataRequests(ctx context.Context, institutionID, patientID int64) (requests []model.TrxPatientDataRequest, err error)
+	ListMergedPatients(ctx context.Context, institutionID, survivorID int64) (patients []model.MstPatientInstitution, err error)
+	AnonymisePatient(ctx context.Context, patient model.MstPatientInstitution) (err error)
+	AnonymisePatientRecords(ctx context.Context, institutionID int64, patientIDs []int64, identifiers []string) (err error)
+	ListPatientPayers(ctx context.Context, institutionID, patientID int64) (payers []model.MstPatientPayer, err error)
+	GetPatientPayer(ctx context.Context, institutionID, patientID, payerID int64) (payer model.MstPatientPayer, found bool, err error)
+	FindPatientPayersByMemberNumber(ctx context.Context, institutionID int64, payerType, memberNumber string) (payers []model.MstPatientPayer, err error)
+	InsertPatientPayer(ctx context.Context, payer *model.MstPatientPayer) (err error)
+	UpdatePatientPayer(ctx context.Context, payer *model.MstPatientPayer) (err error)
+	DeletePatientPayer(ctx context.Context, institutionID, patientID, payerID int64) (err error)
+	ClearDefaultPatientPayer(ctx context.Context, institutionID, patientID int64) (err error)
+	UpdatePatientPayerEligibility(ctx context.Context, payer model.MstPatientPayer) (err error)
+	UpdateVisitPayer(ctx context.Context, visit model.TrxPatientVisit) (err error)
 }
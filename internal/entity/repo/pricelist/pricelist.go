@@ -0,0 +1,38 @@
+package pricelist
+
+import (
+	"context"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// PriceListDB is the data-access contract for price lists and product price
+// history. Mutating methods honour an active xorm session from the request context.
+type PriceListDB interface {
+	InsertPriceList(ctx context.Context, priceList *model.MstPriceList) error
+	// GetPriceListByID returns nil when the list does not exist in the institution.
+	GetPriceListByID(ctx context.Context, institutionID, priceListID int64) (*model.MstPriceList, error)
+	ListPriceLists(ctx context.Context, params model.ListPriceListParams) ([]model.MstPriceList, error)
+	// GetLatestPriceList returns the list of the category with the greatest
+	// effective_from, locking it on the session from ctx, or nil when there is none.
+	GetLatestPriceList(ctx context.Context, institutionID int64, patientCategory string) (*model.MstPriceList, error)
+	// SetPriceListEffectiveTo closes (or with nil reopens) a list.
+	SetPriceListEffectiveTo(ctx context.Context, priceListID int64, effectiveTo *time.Time) error
+	// ReopenPriceListEndingAt clears effective_to of the category's list closed at effectiveTo.
+	ReopenPriceListEndingAt(ctx context.Context, institutionID int64, patientCategory string, effectiveTo time.Time) error
+	DeletePriceList(ctx context.Context, priceListID int64) error
+
+	GetPriceListItems(ctx context.Context, priceListID int64) ([]model.PriceListItemRow, error)
+	// ReplacePriceListItems soft-deletes the list's items and inserts the given set.
+	ReplacePriceListItems(ctx context.Context, priceListID int64, items []model.DtlPriceListItem) error
+
+	// FindApplicablePrices returns, for the given products, the prices on the
+	// lists of the given categories that are effective at the given time.
+	FindApplicablePrices(ctx context.Context, institutionID int64, patientCategories []string, at time.Time, productIDs []int64) ([]model.ApplicablePrice, error)
+	// FindProductPriceListEntries returns every price list price of a product, newest first.
+	FindProductPriceListEntries(ctx context.Context, institutionID, productID int64) ([]model.ProductPriceListEntry, error)
+
+	InsertProductPriceHistory(ctx context.Context, history *model.TrxProductPriceHistory) error
+	FindProductPriceHistory(ctx context.Context, institutionID, productID int64) ([]model.TrxProductPriceHistory, error)
+}
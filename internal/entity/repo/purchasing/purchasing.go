@@ -0,0 +1,38 @@
+package purchasing
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// PurchasingDB is the data-access contract for suppliers and purchase orders.
+// Mutating methods honour an active xorm session from the request context
+// (see internal/library/db/xorm.SetDBSession) so receiving goods, the stock
+// update and the stock movement land in one transaction.
+type PurchasingDB interface {
+	InsertSupplier(ctx context.Context, supplier *model.MstSupplier) error
+	// UpdateSupplier patches the non-nil fields. Returns found=false when the
+	// supplier does not exist in the institution.
+	UpdateSupplier(ctx context.Context, institutionID int64, req model.UpdateSupplierRequest) (found bool, err error)
+	GetSupplierByID(ctx context.Context, institutionID, supplierID int64) (*model.MstSupplier, error)
+	ListSuppliers(ctx context.Context, params model.ListSupplierParams) ([]model.MstSupplier, error)
+
+	InsertPurchaseOrder(ctx context.Context, order *model.TrxPurchaseOrder) error
+	// UpdatePurchaseOrder overwrites supplier, status, notes, total and the
+	// ordered/received timestamps.
+	UpdatePurchaseOrder(ctx context.Context, order *model.TrxPurchaseOrder) error
+	// GetPurchaseOrderByID returns nil when the order does not exist in the institution.
+	GetPurchaseOrderByID(ctx context.Context, institutionID, orderID int64) (*model.TrxPurchaseOrderJoinSupplier, error)
+	// LockPurchaseOrder reads the order with SELECT ... FOR UPDATE on the session
+	// from ctx, serialising concurrent status changes and receipts.
+	LockPurchaseOrder(ctx context.Context, institutionID, orderID int64) (*model.TrxPurchaseOrder, error)
+	ListPurchaseOrders(ctx context.Context, params model.ListPurchaseOrderParams) ([]model.TrxPurchaseOrderJoinSupplier, error)
+
+	InsertPurchaseOrderItems(ctx context.Context, items []model.DtlPurchaseOrderItem) error
+	// SoftDeletePurchaseOrderItems removes every active line of the order.
+	SoftDeletePurchaseOrderItems(ctx context.Context, orderID int64) error
+	GetPurchaseOrderItems(ctx context.Context, orderID int64) ([]model.DtlPurchaseOrderItem, error)
+	// AddReceivedQuantity increments received_quantity on a line.
+	AddReceivedQuantity(ctx context.Context, itemID, quantity int64) error
+}
@@ -0,0 +1,31 @@
+package stocktake
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// StockTakeDB is the data-access contract for stock-take sessions. Mutating
+// methods honour an active xorm session from the request context.
+type StockTakeDB interface {
+	InsertStockTake(ctx context.Context, stockTake *model.TrxStockTake) error
+	// SnapshotStockTakeItems copies the quantity held at the location and the
+	// average cost of every active is_item product of the institution into the
+	// session.
+	SnapshotStockTakeItems(ctx context.Context, institutionID, locationID, stockTakeID int64) (int64, error)
+	// UpdateStockTake overwrites status and the finalize fields.
+	UpdateStockTake(ctx context.Context, stockTake *model.TrxStockTake) error
+	// GetStockTakeByID returns nil when the session does not exist in the institution.
+	GetStockTakeByID(ctx context.Context, institutionID, stockTakeID int64) (*model.TrxStockTake, error)
+	// LockStockTake reads the session with SELECT ... FOR UPDATE on the session from ctx.
+	LockStockTake(ctx context.Context, institutionID, stockTakeID int64) (*model.TrxStockTake, error)
+	ListStockTakes(ctx context.Context, params model.ListStockTakeParams) ([]model.TrxStockTake, error)
+
+	// GetStockTakeItems returns items with their summed counted quantity.
+	GetStockTakeItems(ctx context.Context, stockTakeID int64) ([]model.StockTakeItemRow, error)
+	GetStockTakeCounts(ctx context.Context, stockTakeID int64) ([]model.DtlStockTakeCount, error)
+	// UpsertStockTakeCounts inserts counts or replaces the counter's earlier entry for the item.
+	UpsertStockTakeCounts(ctx context.Context, counts []model.DtlStockTakeCount) error
+	UpdateStockTakeItemReason(ctx context.Context, itemID int64, reason string) error
+}
@@ -0,0 +1,18 @@
+package attachment
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// AttachmentUC keeps files for patients and hands out time-limited links to
+// download them.
+type AttachmentUC interface {
+	UploadAttachment(ctx context.Context, req model.UploadAttachmentRequest) (model.AttachmentResponse, error)
+	ListAttachments(ctx context.Context, params model.ListAttachmentParams) ([]model.AttachmentResponse, error)
+	GetAttachment(ctx context.Context, attachmentID int64) (model.AttachmentResponse, error)
+	DeleteAttachment(ctx context.Context, attachmentID int64) error
+	// OpenAttachment checks a signed download link and opens the file it grants.
+	OpenAttachment(ctx context.Context, params model.DownloadAttachmentParams) (model.AttachmentContent, error)
+}
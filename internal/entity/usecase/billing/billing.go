@@ -0,0 +1,27 @@
+package billing
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// BillingUC records what patients pay for their visits and what is returned
+// to them afterwards.
+type BillingUC interface {
+	GetVisitBilling(ctx context.Context, visitID int64) (model.VisitBillingResponse, error)
+	// RecordPayment adds a payment of the visit invoice, up to what is outstanding.
+	RecordPayment(ctx context.Context, visitID int64, req model.RecordVisitPaymentRequest) (model.TrxVisitPayment, error)
+	// CreateRefund issues a refund or credit note for lines of a paid visit.
+	CreateRefund(ctx context.Context, visitID int64, req model.CreateVisitRefundRequest) (model.TrxVisitRefund, error)
+	ListRefunds(ctx context.Context, visitID int64) ([]model.TrxVisitRefund, error)
+
+	GetPatientAccount(ctx context.Context, patientUUID string) (model.PatientAccountSummary, error)
+	// RecordDeposit credits money paid in advance to the patient's account.
+	RecordDeposit(ctx context.Context, patientUUID string, req model.PatientAccountDepositRequest) (model.TrxPatientLedgerEntry, error)
+	// RefundPatientCredit pays unused credit back to the patient.
+	RefundPatientCredit(ctx context.Context, patientUUID string, req model.PatientAccountRefundRequest) (model.TrxPatientLedgerEntry, error)
+	// ApplyDeposit pays the visit invoice from the patient's credit.
+	ApplyDeposit(ctx context.Context, visitID int64, req model.ApplyDepositRequest) (model.TrxVisitPayment, error)
+	GetPatientStatement(ctx context.Context, patientUUID string, params model.PatientAccountStatementParams) (model.PatientAccountStatement, error)
+}
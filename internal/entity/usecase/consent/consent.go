@@ -0,0 +1,21 @@
+package consent
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// ConsentUC keeps the consent templates of an institution and records signed
+// informed consents for visits.
+type ConsentUC interface {
+	ListTemplates(ctx context.Context) ([]model.MstConsentTemplate, error)
+	// SaveTemplate retires the active template of the category and adds the next version.
+	SaveTemplate(ctx context.Context, req model.SaveConsentTemplateRequest) (model.MstConsentTemplate, error)
+	RetireTemplate(ctx context.Context, templateID int64) error
+	// PreviewConsent renders a template for a visit without recording anything.
+	PreviewConsent(ctx context.Context, params model.ConsentPreviewParams) (model.ConsentPreview, error)
+	CreateConsent(ctx context.Context, req model.CreateConsentRequest) (model.TrxConsent, error)
+	ListVisitConsents(ctx context.Context, visitID int64) ([]model.TrxConsent, error)
+	GetSignature(ctx context.Context, consentID int64) (model.ConsentSignature, error)
+}
@@ -0,0 +1,28 @@
+package discount
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// DiscountUC governs manual discounts on visit lines: the per-role limits, the
+// approvals that lift them, and the audit of every manual price change.
+type DiscountUC interface {
+	ListPolicies(ctx context.Context) ([]model.MstDiscountPolicy, error)
+	// SavePolicies replaces every discount policy of the institution.
+	SavePolicies(ctx context.Context, req model.SaveDiscountPoliciesRequest) ([]model.MstDiscountPolicy, error)
+
+	// RequestApproval asks a supervisor to allow a discount on one product of a visit.
+	RequestApproval(ctx context.Context, visitID int64, req model.CreateDiscountApprovalRequest) (model.TrxDiscountApproval, error)
+	ListApprovals(ctx context.Context, params model.ListDiscountApprovalParams) ([]model.TrxDiscountApproval, error)
+	// ApproveRequest and RejectRequest decide a pending request; nobody decides their own.
+	ApproveRequest(ctx context.Context, approvalID int64, req model.DecideDiscountApprovalRequest) (model.TrxDiscountApproval, error)
+	RejectRequest(ctx context.Context, approvalID int64, req model.DecideDiscountApprovalRequest) (model.TrxDiscountApproval, error)
+
+	// AuthorizePriceChanges checks every manual discount against the user's
+	// limit or the approval sent with it and audits the changes. It must run
+	// inside the caller's transaction, after the lines were written.
+	AuthorizePriceChanges(ctx context.Context, changes []model.VisitLinePriceChange) error
+	ListPriceAudit(ctx context.Context, visitID int64) ([]model.TrxPriceOverrideAudit, error)
+}
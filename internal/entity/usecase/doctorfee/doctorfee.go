@@ -0,0 +1,17 @@
+package doctorfee
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// DoctorFeeUC configures the commission doctors earn on their procedures and
+// reports what they earned.
+type DoctorFeeUC interface {
+	ListRules(ctx context.Context) ([]model.MstDoctorFeeRule, error)
+	// SaveRules replaces every doctor fee rule of the institution.
+	SaveRules(ctx context.Context, req model.SaveDoctorFeeRulesRequest) ([]model.MstDoctorFeeRule, error)
+	// GetReport computes each doctor's fee on the procedures of paid visits in the period.
+	GetReport(ctx context.Context, params model.DoctorFeeReportParams) (model.DoctorFeeReport, error)
+}
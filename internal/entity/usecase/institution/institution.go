@@ -15,5 +15,30 @@ type InstitutionUC interface {
 	FindInstitutionProductByParams(ctx context.Context, params model.FindTrxInstitutionProductParams) (products []model.GetInstitutionProductResponse, err error)
 	UpdateInstitutionProduct(ctx context.Context, request model.UpdateInstitutionProductRequest) (err error)
 	UpdateInstitutionProductStock(ctx context.Context, request model.ProductStockResupplyRequest) (err error)
+	GetProductUnits(ctx context.Context, params model.ListProductUnitsParams) (resp model.ProductUnitsResponse, err error)
+	SaveProductUnits(ctx context.Context, request model.SaveProductUnitsRequest) (resp model.ProductUnitsResponse, err error)
+	GetTreatmentMaterials(ctx context.Context, params model.ListTreatmentMaterialsParams) (resp model.TreatmentMaterialsResponse, err error)
+	SaveTreatmentMaterials(ctx context.Context, request model.SaveTreatmentMaterialsRequest) (resp model.TreatmentMaterialsResponse, err error)
+	// ConsumeTreatmentMaterials reconciles the visit's material stock movements
+	// with its treatments; it must run inside the caller's transaction.
+	ConsumeTreatmentMaterials(ctx context.Context, visitID int64) (err error)
+	GetTaxConfig(ctx context.Context) (config model.MstInstitutionTaxConfig, err error)
+	UpdateTaxConfig(ctx context.Context, request model.UpdateTaxConfigRequest) (config model.MstInstitutionTaxConfig, err error)
+	GetMRNConfig(ctx context.Context) (config model.MstInstitutionMRNConfig, err error)
+	UpdateMRNConfig(ctx context.Context, request model.UpdateMRNConfigRequest) (config model.MstInstitutionMRNConfig, err error)
+	ImportProducts(ctx context.Context, request model.ProductImportRequest) (result model.ProductImportResult, err error)
+	ListProductCatalog(ctx context.Context) (products []model.GetInstitutionProductResponse, err error)
 	GetProductStatistics(ctx context.Context, params model.ProductStatisticsParams) (result model.ProductStatisticsResponse, err error)
+	BuildProductStatisticsQuery(ctx context.Context, params model.ProductStatisticsParams) (query model.ProductStatisticsQuery, err error)
+	StreamProductStatistics(ctx context.Context, query model.ProductStatisticsQuery, fn func(model.ProductStatisticsLine) error) (err error)
+	ListStockLocations(ctx context.Context) (locations []model.MstStockLocation, err error)
+	CreateStockLocation(ctx context.Context, request model.CreateStockLocationRequest) (location model.MstStockLocation, err error)
+	UpdateStockLocation(ctx context.Context, request model.UpdateStockLocationRequest) (location model.MstStockLocation, err error)
+	ListLocationStock(ctx context.Context, params model.ListLocationStockParams) (rows []model.LocationStockRow, err error)
+	SaveReorderLevels(ctx context.Context, request model.SaveReorderLevelsRequest) (rows []model.LocationStockRow, err error)
+	// GetVisitStockLocation returns the location sales on the visit draw from.
+	GetVisitStockLocation(ctx context.Context, visitID int64) (location model.MstStockLocation, err error)
+	CreateStockTransfer(ctx context.Context, request model.CreateStockTransferRequest) (transfer *model.TrxStockTransfer, err error)
+	ListStockTransfers(ctx context.Context, params model.ListStockTransferParams) (transfers []model.TrxStockTransfer, err error)
+	GetStockTransfer(ctx context.Context, transferID int64) (transfer *model.TrxStockTransfer, err error)
 }
@@ -2,6 +2,7 @@ package patient
 
 import (
 	"context"
+	"io"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
 )
@@ -11,4 +12,23 @@ type PatientUC interface {
 	GetPatients(ctx context.Context, patientUUID string) (patient model.GetPatientResponse, err error)
 	ListPatients(ctx context.Context, req model.GetPatientParams) (patients []model.GetPatientResponse, err error)
 	UpdatePatient(ctx context.Context, req model.UpdatePatientRequest) (err error)
+	ListPatientDuplicates(ctx context.Context, patientUUID string) (candidates []model.PatientDuplicateCandidate, err error)
+	MergePatients(ctx context.Context, req model.MergePatientsRequest) (merge model.PatientMergeRow, err error)
+	UndoPatientMerge(ctx context.Context, mergeID int64) (merge model.PatientMergeRow, err error)
+	ListPatientMerges(ctx context.Context, params model.ListPatientMergeParams) (merges []model.PatientMergeRow, err error)
+	GetPatientTimeline(ctx context.Context, params model.GetPatientTimelineParams) (timeline model.PatientTimeline, err error)
+	ListPatientRelations(ctx context.Context, patientUUID string) (relations []model.PatientRelationResponse, err error)
+	SavePatientRelation(ctx context.Context, req model.SavePatientRelationRequest) (relation model.PatientRelationResponse, err error)
+	DeletePatientRelation(ctx context.Context, patientUUID string, relationID int64) (err error)
+	ImportPatients(ctx context.Context, req model.PatientImportRequest) (response model.PatientImportResponse, err error)
+	GetPatientImport(ctx context.Context, importID int64) (response model.PatientImportResponse, err error)
+	ListPatientImports(ctx context.Context, params model.ListPatientImportParams) (responses []model.PatientImportResponse, err error)
+	GetPatientImportReport(ctx context.Context, importID int64) (report model.PatientImportReport, err error)
+	ExportPatientData(ctx context.Context, params model.ExportPatientDataParams) (export model.PatientDataExport, err error)
+	WritePatientDataExport(ctx context.Context, export model.PatientDataExport, w io.Writer) (err error)
+	AnonymisePatient(ctx context.Context, req model.AnonymisePatientRequest) (response model.GetPatientResponse, err error)
+	ListPatientPayers(ctx context.Context, patientUUID string) (payers []model.MstPatientPayer, err error)
+	SavePatientPayer(ctx context.Context, req model.SavePatientPayerRequest) (payer model.MstPatientPayer, err error)
+	DeletePatientPayer(ctx context.Context, patientUUID string, payerID int64) (err error)
+	CheckPayerEligibility(ctx context.Context, req model.CheckPatientPayerEligibilityRequest) (payer model.MstPatientPayer, err error)
 }
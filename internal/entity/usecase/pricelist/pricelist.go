@@ -0,0 +1,23 @@
+package pricelist
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// PriceListUC manages effective-dated price lists per patient category.
+type PriceListUC interface {
+	// CreatePriceList adds a list that takes effect at EffectiveFrom and closes
+	// the category's current list at the same instant.
+	CreatePriceList(ctx context.Context, req model.CreatePriceListRequest) (model.PriceListResponse, error)
+	ListPriceLists(ctx context.Context, params model.ListPriceListParams) ([]model.MstPriceList, error)
+	GetPriceList(ctx context.Context, priceListID int64) (model.PriceListResponse, error)
+	// UpdatePriceListItems replaces the items of a list that is not yet effective.
+	UpdatePriceListItems(ctx context.Context, priceListID int64, req model.UpdatePriceListItemsRequest) (model.PriceListResponse, error)
+	// DeletePriceList removes a list that is not yet effective and reopens its predecessor.
+	DeletePriceList(ctx context.Context, priceListID int64) error
+	// GetProductPriceHistory returns the base price changes of a product and its
+	// price on every list, past and future.
+	GetProductPriceHistory(ctx context.Context, params model.ProductPriceHistoryParams) (model.ProductPriceHistoryResponse, error)
+}
@@ -0,0 +1,30 @@
+package purchasing
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// PurchasingUC is the supplier and purchase order orchestration contract.
+type PurchasingUC interface {
+	CreateSupplier(ctx context.Context, req model.CreateSupplierRequest) (model.MstSupplier, error)
+	UpdateSupplier(ctx context.Context, req model.UpdateSupplierRequest) error
+	ListSuppliers(ctx context.Context, params model.ListSupplierParams) ([]model.MstSupplier, error)
+
+	// CreatePurchaseOrder stores a new draft order with its lines.
+	CreatePurchaseOrder(ctx context.Context, req model.CreatePurchaseOrderRequest) (model.PurchaseOrderResponse, error)
+	// UpdatePurchaseOrder replaces supplier, notes and lines of a draft order.
+	UpdatePurchaseOrder(ctx context.Context, orderID int64, req model.UpdatePurchaseOrderRequest) (model.PurchaseOrderResponse, error)
+	GetPurchaseOrder(ctx context.Context, orderID int64) (model.PurchaseOrderResponse, error)
+	ListPurchaseOrders(ctx context.Context, params model.ListPurchaseOrderParams) ([]model.TrxPurchaseOrderJoinSupplier, error)
+
+	// OrderPurchaseOrder moves a draft order to ordered.
+	OrderPurchaseOrder(ctx context.Context, orderID int64) (model.PurchaseOrderResponse, error)
+	// CancelPurchaseOrder cancels a draft or ordered order.
+	CancelPurchaseOrder(ctx context.Context, orderID int64) (model.PurchaseOrderResponse, error)
+	// ReceivePurchaseOrder books received goods into stock, records purchase_receipt
+	// stock movements, folds the unit cost into the product's average cost and
+	// advances the order to partially_received or received.
+	ReceivePurchaseOrder(ctx context.Context, orderID int64, req model.ReceivePurchaseOrderRequest) (model.PurchaseOrderResponse, error)
+}
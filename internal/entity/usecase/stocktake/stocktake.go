@@ -0,0 +1,23 @@
+package stocktake
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+// StockTakeUC is the stock-take (stock opname) orchestration contract.
+type StockTakeUC interface {
+	// CreateStockTake opens a session and snapshots the system quantity of every
+	// is_item product. Only one session may be open per institution.
+	CreateStockTake(ctx context.Context, req model.CreateStockTakeRequest) (model.StockTakeResponse, error)
+	ListStockTakes(ctx context.Context, params model.ListStockTakeParams) ([]model.TrxStockTake, error)
+	// GetStockTake returns the session with per-item counts and variances.
+	GetStockTake(ctx context.Context, stockTakeID int64) (model.StockTakeResponse, error)
+	// SaveCounts records the calling user's counted quantities on an open session.
+	SaveCounts(ctx context.Context, stockTakeID int64, req model.SaveStockTakeCountRequest) (model.StockTakeResponse, error)
+	// FinalizeStockTake posts a stock_take_adjustment movement for every counted
+	// item whose count differs from the snapshot and closes the session.
+	FinalizeStockTake(ctx context.Context, stockTakeID int64, req model.FinalizeStockTakeRequest) (model.StockTakeResponse, error)
+	CancelStockTake(ctx context.Context, stockTakeID int64) (model.StockTakeResponse, error)
+}
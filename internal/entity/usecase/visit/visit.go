@@ -22,5 +22,7 @@ type VisitUC interface {
 	UpdateVisitProduct(ctx context.Context, req model.InsertTrxVisitProductRequest) (err error)
 	UpsertVisitProduct(ctx context.Context, req model.UpsertTrxVisitProductRequest) (err error)
 	ListVisitProducts(ctx context.Context, params model.GetVisitProductRequest) (products []model.TrxVisitProduct, err error)
+	GetVisitInvoice(ctx context.Context, visitID int64) (invoice model.VisitInvoiceResponse, err error)
+	SelectVisitPayer(ctx context.Context, req model.SelectVisitPayerRequest) (visit model.TrxPatientVisit, err error)
 	ArchivePatientVisit(ctx context.Context, req model.ArchivePatientVisitRequest) (err error)
 }
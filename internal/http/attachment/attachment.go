@@ -0,0 +1,171 @@
+package attachment
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	attachmentuc "github.com/faisalhardin/medilink/internal/entity/usecase/attachment"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+type AttachmentHandler struct {
+	AttachmentUC attachmentuc.AttachmentUC
+	// MaxFileSize bounds uploads, in bytes.
+	MaxFileSize int64
+}
+
+func New(h *AttachmentHandler) *AttachmentHandler {
+	if h.MaxFileSize <= 0 {
+		h.MaxFileSize = model.DefaultAttachmentMaxFileSize
+	}
+	return h
+}
+
+// UploadAttachment handles POST /v1/patient/{uuid}/attachment, a multipart
+// form with the file as file and category, description, visit_id and tooth_id.
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxFileSize+1<<20)
+	if err := r.ParseMultipartForm(h.MaxFileSize); err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file",
+			fmt.Sprintf("upload the attachment as the file field of a multipart form, up to %d MB", h.MaxFileSize>>20)))
+		return
+	}
+	request := model.UploadAttachmentRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", "file is required"))
+		return
+	}
+	defer file.Close()
+	if header.Size > h.MaxFileSize {
+		commonwriter.SetError(ctx, w, commonerr.SetNewUnprocessableEntityError("file", fmt.Sprintf("file is larger than %d MB", h.MaxFileSize>>20)))
+		return
+	}
+
+	request.Content, err = io.ReadAll(file)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", "file could not be read"))
+		return
+	}
+	request.FileName = header.Filename
+	request.PatientUUID = chi.URLParam(r, "uuid")
+
+	attachment, err := h.AttachmentUC.UploadAttachment(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, attachment)
+}
+
+// ListAttachments handles GET /v1/patient/{uuid}/attachment
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ListAttachmentParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	params.PatientUUID = chi.URLParam(r, "uuid")
+
+	attachments, err := h.AttachmentUC.ListAttachments(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, attachments)
+}
+
+// GetAttachment handles GET /v1/attachment/{id}
+func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid attachment ID"))
+		return
+	}
+
+	attachment, err := h.AttachmentUC.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, attachment)
+}
+
+// DeleteAttachment handles DELETE /v1/attachment/{id}
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid attachment ID"))
+		return
+	}
+
+	err = h.AttachmentUC.DeleteAttachment(ctx, attachmentID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, "ok")
+}
+
+// DownloadAttachment handles GET /v1/attachment/{id}/content, the signed link
+// of an attachment. It needs no session.
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.DownloadAttachmentParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	params.ID, err = strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid attachment ID"))
+		return
+	}
+
+	content, err := h.AttachmentUC.OpenAttachment(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	defer content.Body.Close()
+
+	w.Header().Set("Content-Type", content.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": content.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, content.Body); err != nil {
+		liblog.Errorf("download attachment %d: %v", params.ID, err)
+	}
+}
@@ -0,0 +1,261 @@
+package billing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	billinguc "github.com/faisalhardin/medilink/internal/entity/usecase/billing"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+type BillingHandler struct {
+	BillingUC billinguc.BillingUC
+}
+
+func New(h *BillingHandler) *BillingHandler {
+	return h
+}
+
+// GetVisitBilling handles GET /v1/visit/:id/billing
+func (h *BillingHandler) GetVisitBilling(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.BillingUC.GetVisitBilling(ctx, visitID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// RecordPayment handles POST /v1/visit/:id/payment
+func (h *BillingHandler) RecordPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.RecordVisitPaymentRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	payment, err := h.BillingUC.RecordPayment(ctx, visitID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, payment)
+}
+
+// CreateRefund handles POST /v1/visit/:id/refund
+func (h *BillingHandler) CreateRefund(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.CreateVisitRefundRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	refund, err := h.BillingUC.CreateRefund(ctx, visitID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, refund)
+}
+
+// ListRefunds handles GET /v1/visit/:id/refund
+func (h *BillingHandler) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	refunds, err := h.BillingUC.ListRefunds(ctx, visitID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, refunds)
+}
+
+// ApplyDeposit handles POST /v1/visit/:id/apply-deposit
+func (h *BillingHandler) ApplyDeposit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.ApplyDepositRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	payment, err := h.BillingUC.ApplyDeposit(ctx, visitID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, payment)
+}
+
+// GetPatientAccount handles GET /v1/patient/:uuid/account
+func (h *BillingHandler) GetPatientAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	summary, err := h.BillingUC.GetPatientAccount(ctx, chi.URLParam(r, "uuid"))
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, summary)
+}
+
+// RecordDeposit handles POST /v1/patient/:uuid/account/deposit
+func (h *BillingHandler) RecordDeposit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.PatientAccountDepositRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	entry, err := h.BillingUC.RecordDeposit(ctx, chi.URLParam(r, "uuid"), req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, entry)
+}
+
+// RefundPatientCredit handles POST /v1/patient/:uuid/account/refund
+func (h *BillingHandler) RefundPatientCredit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.PatientAccountRefundRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	entry, err := h.BillingUC.RefundPatientCredit(ctx, chi.URLParam(r, "uuid"), req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, entry)
+}
+
+// GetPatientStatement handles GET /v1/patient/:uuid/account/statement
+func (h *BillingHandler) GetPatientStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.PatientAccountStatementParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	statement, err := h.BillingUC.GetPatientStatement(ctx, chi.URLParam(r, "uuid"), params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, statement)
+}
+
+var patientStatementHeader = []string{
+	"date", "entry_type", "visit_id", "payment_method", "notes", "amount", "balance",
+}
+
+// DownloadPatientStatement handles GET /v1/patient/:uuid/account/statement/export
+func (h *BillingHandler) DownloadPatientStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.PatientAccountStatementParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	statement, err := h.BillingUC.GetPatientStatement(ctx, chi.URLParam(r, "uuid"), params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="patient-%s-statement.csv"`, statement.PatientUUID))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write(patientStatementHeader)
+	_ = writer.Write([]string{"", "opening_balance", "", "", "", "", statement.OpeningBalance.StringFixed(money.Scale)})
+	for _, entry := range statement.Entries {
+		_ = writer.Write(patientStatementRecord(entry))
+	}
+	_ = writer.Write([]string{"", "closing_balance", "", "", "", "", statement.ClosingBalance.StringFixed(money.Scale)})
+	writer.Flush()
+}
+
+func patientStatementRecord(entry model.TrxPatientLedgerEntry) []string {
+	visitID := ""
+	if entry.IDTrxPatientVisit.Valid {
+		visitID = strconv.FormatInt(entry.IDTrxPatientVisit.Int64, 10)
+	}
+
+	return []string{
+		entry.CreateTime.Format(time.RFC3339),
+		entry.EntryType,
+		visitID,
+		entry.PaymentMethod,
+		entry.Notes,
+		entry.Amount.StringFixed(money.Scale),
+		entry.Balance.StringFixed(money.Scale),
+	}
+}
+
+func parseInt64Param(r *http.Request, key string) (int64, error) {
+	raw := chi.URLParam(r, key)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, commonerr.SetNewBadRequest("invalid_parameter", key+" must be an integer")
+	}
+	return v, nil
+}
@@ -0,0 +1,171 @@
+package consent
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	consentuc "github.com/faisalhardin/medilink/internal/entity/usecase/consent"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+type ConsentHandler struct {
+	ConsentUC consentuc.ConsentUC
+}
+
+func New(h *ConsentHandler) *ConsentHandler {
+	return h
+}
+
+// ListTemplates handles GET /v1/institution/consent-template
+func (h *ConsentHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	templates, err := h.ConsentUC.ListTemplates(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, templates)
+}
+
+// SaveTemplate handles PUT /v1/institution/consent-template
+func (h *ConsentHandler) SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.SaveConsentTemplateRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	template, err := h.ConsentUC.SaveTemplate(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, template)
+}
+
+// RetireTemplate handles DELETE /v1/institution/consent-template/{id}
+func (h *ConsentHandler) RetireTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	templateID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid consent template ID"))
+		return
+	}
+
+	err = h.ConsentUC.RetireTemplate(ctx, templateID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, "ok")
+}
+
+// PreviewConsent handles GET /v1/visit/{id}/consent/preview
+func (h *ConsentHandler) PreviewConsent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ConsentPreviewParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	params.VisitID, err = strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid visit ID"))
+		return
+	}
+
+	preview, err := h.ConsentUC.PreviewConsent(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, preview)
+}
+
+// CreateConsent handles POST /v1/visit/{id}/consent
+func (h *ConsentHandler) CreateConsent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.CreateConsentRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.VisitID, err = strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid visit ID"))
+		return
+	}
+
+	consent, err := h.ConsentUC.CreateConsent(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, consent)
+}
+
+// ListVisitConsents handles GET /v1/visit/{id}/consent
+func (h *ConsentHandler) ListVisitConsents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid visit ID"))
+		return
+	}
+
+	consents, err := h.ConsentUC.ListVisitConsents(ctx, visitID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, consents)
+}
+
+// GetSignature handles GET /v1/consent/{id}/signature, the signature image of
+// a consent.
+func (h *ConsentHandler) GetSignature(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	consentID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid consent ID"))
+		return
+	}
+
+	signature, err := h.ConsentUC.GetSignature(ctx, consentID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", signature.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(signature.Image); err != nil {
+		liblog.Errorf("write consent %d signature: %v", consentID, err)
+	}
+}
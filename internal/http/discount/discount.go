@@ -0,0 +1,170 @@
+package discount
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	discountuc "github.com/faisalhardin/medilink/internal/entity/usecase/discount"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+type DiscountHandler struct {
+	DiscountUC discountuc.DiscountUC
+}
+
+func New(h *DiscountHandler) *DiscountHandler {
+	return h
+}
+
+// ListPolicies handles GET /v1/institution/discount-policy
+func (h *DiscountHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	policies, err := h.DiscountUC.ListPolicies(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, policies)
+}
+
+// SavePolicies handles PUT /v1/institution/discount-policy
+func (h *DiscountHandler) SavePolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.SaveDiscountPoliciesRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	policies, err := h.DiscountUC.SavePolicies(ctx, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, policies)
+}
+
+// RequestApproval handles POST /v1/visit/:id/discount-approval
+func (h *DiscountHandler) RequestApproval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.CreateDiscountApprovalRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	approval, err := h.DiscountUC.RequestApproval(ctx, visitID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, approval)
+}
+
+// ListApprovals handles GET /v1/institution/discount-approval
+func (h *DiscountHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.ListDiscountApprovalParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	approvals, err := h.DiscountUC.ListApprovals(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, approvals)
+}
+
+// ApproveRequest handles POST /v1/institution/discount-approval/:id/approve
+func (h *DiscountHandler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	approvalID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.DecideDiscountApprovalRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	approval, err := h.DiscountUC.ApproveRequest(ctx, approvalID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, approval)
+}
+
+// RejectRequest handles POST /v1/institution/discount-approval/:id/reject
+func (h *DiscountHandler) RejectRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	approvalID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.DecideDiscountApprovalRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	approval, err := h.DiscountUC.RejectRequest(ctx, approvalID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, approval)
+}
+
+// ListPriceAudit handles GET /v1/visit/:id/price-audit
+func (h *DiscountHandler) ListPriceAudit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	audits, err := h.DiscountUC.ListPriceAudit(ctx, visitID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, audits)
+}
+
+func parseInt64Param(r *http.Request, key string) (int64, error) {
+	raw := chi.URLParam(r, key)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, commonerr.SetNewBadRequest("invalid_parameter", key+" must be an integer")
+	}
+	return v, nil
+}
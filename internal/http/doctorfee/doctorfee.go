@@ -0,0 +1,158 @@
+package doctorfee
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	doctorfeeuc "github.com/faisalhardin/medilink/internal/entity/usecase/doctorfee"
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+)
+
+var bindingBind = binding.Bind
+
+var doctorFeeReportColumns = []export.Column{
+	{Header: "doctor_id", Kind: export.Text},
+	{Header: "doctor_name", Kind: export.Text},
+	{Header: "visit_id", Kind: export.Number},
+	{Header: "visit_time", Kind: export.DateTime},
+	{Header: "procedure_id", Kind: export.Number},
+	{Header: "product_id", Kind: export.Number},
+	{Header: "product_name", Kind: export.Text},
+	{Header: "procedure_category", Kind: export.Text},
+	{Header: "revenue", Kind: export.Rupiah},
+	{Header: "rule_id", Kind: export.Number},
+	{Header: "fee_type", Kind: export.Text},
+	{Header: "fee_rate", Kind: export.Number},
+	{Header: "fee", Kind: export.Rupiah},
+}
+
+type DoctorFeeHandler struct {
+	DoctorFeeUC doctorfeeuc.DoctorFeeUC
+}
+
+func New(h *DoctorFeeHandler) *DoctorFeeHandler {
+	return h
+}
+
+// ListRules handles GET /v1/institution/doctor-fee-rule
+func (h *DoctorFeeHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rules, err := h.DoctorFeeUC.ListRules(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, rules)
+}
+
+// SaveRules handles PUT /v1/institution/doctor-fee-rule
+func (h *DoctorFeeHandler) SaveRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.SaveDoctorFeeRulesRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	rules, err := h.DoctorFeeUC.SaveRules(ctx, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, rules)
+}
+
+// GetReport handles GET /v1/institution/report/doctor-fees
+func (h *DoctorFeeHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.DoctorFeeReportParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	report, err := h.DoctorFeeUC.GetReport(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, report)
+}
+
+// DownloadReport handles GET /v1/institution/report/doctor-fees/export, as CSV
+// unless ?export=xlsx or the Accept header asks for XLSX.
+func (h *DoctorFeeHandler) DownloadReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.DoctorFeeReportParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	format, err := export.Format(r)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	report, err := h.DoctorFeeUC.GetReport(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	loc := params.StartTime.Location()
+	filename := fmt.Sprintf("doctor-fees-%s-%s", report.StartTime.In(loc).Format("20060102"), report.EndTime.In(loc).Format("20060102"))
+	table, err := export.NewWriter(w, format, filename, doctorFeeReportColumns, loc)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	for _, doctor := range report.Doctors {
+		for _, line := range doctor.Lines {
+			if err = table.WriteRow(doctorFeeReportRecord(doctor, line)...); err != nil {
+				liblog.Errorf("export doctor fee report: %v", err)
+				return
+			}
+		}
+	}
+	if err = table.Close(); err != nil {
+		liblog.Errorf("export doctor fee report: %v", err)
+	}
+}
+
+func doctorFeeReportRecord(doctor model.DoctorFeeSummary, line model.DoctorFeeLine) []interface{} {
+	var productID, ruleID interface{}
+	if line.ProductID.Valid {
+		productID = line.ProductID.Int64
+	}
+	if line.IDMstDoctorFeeRule.Valid {
+		ruleID = line.IDMstDoctorFeeRule.Int64
+	}
+
+	return []interface{}{
+		doctor.DoctorID,
+		doctor.DoctorName,
+		line.IDTrxPatientVisit,
+		line.VisitTime,
+		line.IDTrxVisitProcedure,
+		productID,
+		line.ProductName,
+		line.ProcedureCategory,
+		line.Revenue,
+		ruleID,
+		line.FeeType,
+		line.FeeRate,
+		line.Fee,
+	}
+}
@@ -77,3 +77,65 @@ func (h *InstitutionHandler) FindInstitutions(w http.ResponseWriter, r *http.Req
 
 	commonwriter.SetOKWithData(ctx, w, institutions)
 }
+
+func (h *InstitutionHandler) GetTaxConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	result, err := h.InstitutionUC.GetTaxConfig(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+func (h *InstitutionHandler) UpdateTaxConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.UpdateTaxConfigRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	result, err := h.InstitutionUC.UpdateTaxConfig(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+func (h *InstitutionHandler) GetMRNConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	result, err := h.InstitutionUC.GetMRNConfig(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+func (h *InstitutionHandler) UpdateMRNConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.UpdateMRNConfigRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	result, err := h.InstitutionUC.UpdateMRNConfig(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
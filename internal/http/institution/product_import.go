@@ -0,0 +1,110 @@
+package institution
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	"github.com/faisalhardin/medilink/internal/library/common/spreadsheet"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+)
+
+// productImportMaxFileSize bounds catalog uploads.
+const productImportMaxFileSize = 10 << 20
+
+var productCatalogExportColumns = []export.Column{
+	{Header: model.ProductColumnSKU, Kind: export.Text},
+	{Header: model.ProductColumnName, Kind: export.Text},
+	{Header: model.ProductColumnPrice, Kind: export.Rupiah},
+	{Header: model.ProductColumnIsItem, Kind: export.Text},
+	{Header: model.ProductColumnIsTreatment, Kind: export.Text},
+	{Header: model.ProductColumnTaxCategory, Kind: export.Text},
+	{Header: model.ProductColumnUnitType, Kind: export.Text},
+}
+
+// ImportProducts handles POST /v1/institution/product/import, a multipart form
+// with the catalog as file, in CSV or XLSX. With dry_run=true it only returns
+// the preview of what the import would do.
+func (h *InstitutionHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, productImportMaxFileSize+1<<20)
+	if err := r.ParseMultipartForm(productImportMaxFileSize); err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", "upload the catalog as the file field of a multipart form, up to 10 MB"))
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", "file is required"))
+		return
+	}
+	defer file.Close()
+
+	format, err := spreadsheet.FormatOf(header.Filename)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", err.Error()))
+		return
+	}
+	rows, err := spreadsheet.ReadRows(file, header.Size, format, model.ProductImportMaxRows)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", err.Error()))
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+	result, err := h.InstitutionUC.ImportProducts(ctx, model.ProductImportRequest{
+		Rows:   rows,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+// ExportProducts handles GET /v1/institution/product/export, as CSV unless
+// ?export=xlsx or the Accept header asks for XLSX. The file imports back as is.
+func (h *InstitutionHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, err := export.Format(r)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	products, err := h.InstitutionUC.ListProductCatalog(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("product-catalog-%s", time.Now().Format("20060102"))
+	table, err := export.NewWriter(w, format, filename, productCatalogExportColumns, time.Local)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	for _, product := range products {
+		err = table.WriteRow(product.SKU.String, product.Name, product.Price,
+			strconv.FormatBool(product.IsItem), strconv.FormatBool(product.IsTreatment),
+			product.TaxCategory, product.UnitType)
+		if err != nil {
+			liblog.Errorf("export product catalog: %v", err)
+			return
+		}
+	}
+	if err = table.Close(); err != nil {
+		liblog.Errorf("export product catalog: %v", err)
+	}
+}
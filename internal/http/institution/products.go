@@ -1,12 +1,30 @@
 package institution
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
 	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/pkg/type/money"
 )
 
+var productStatisticsExportColumns = []export.Column{
+	{Header: "period_start", Kind: export.DateTime},
+	{Header: "period_end", Kind: export.DateTime},
+	{Header: "product_id", Kind: export.Number},
+	{Header: "name", Kind: export.Text},
+	{Header: "quantity", Kind: export.Number},
+	{Header: "unit_price", Kind: export.Rupiah},
+	{Header: "revenue", Kind: export.Rupiah},
+	{Header: "cost", Kind: export.Rupiah},
+	{Header: "margin", Kind: export.Rupiah},
+	{Header: "tax", Kind: export.Rupiah},
+	{Header: "service_charge", Kind: export.Rupiah},
+}
+
 func (h *InstitutionHandler) FindInstitutionProducts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -83,6 +101,85 @@ func (h *InstitutionHandler) UpdateInstitutionProductStock(w http.ResponseWriter
 	commonwriter.SetOKWithData(ctx, w, "ok")
 }
 
+func (h *InstitutionHandler) GetProductUnits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.ListProductUnitsParams{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	result, err := h.InstitutionUC.GetProductUnits(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+func (h *InstitutionHandler) SaveProductUnits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.SaveProductUnitsRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	result, err := h.InstitutionUC.SaveProductUnits(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+func (h *InstitutionHandler) GetTreatmentMaterials(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.ListTreatmentMaterialsParams{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	result, err := h.InstitutionUC.GetTreatmentMaterials(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+func (h *InstitutionHandler) SaveTreatmentMaterials(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.SaveTreatmentMaterialsRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	result, err := h.InstitutionUC.SaveTreatmentMaterials(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, result)
+}
+
+// GetProductStatistics handles GET /v1/institution/product/statistics. With
+// ?export=csv|xlsx, or an Accept header asking for either, the statistics are
+// streamed as a file instead of JSON.
 func (h *InstitutionHandler) GetProductStatistics(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -93,6 +190,16 @@ func (h *InstitutionHandler) GetProductStatistics(w http.ResponseWriter, r *http
 		return
 	}
 
+	format, err := export.Format(r)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	if format != "" {
+		h.exportProductStatistics(w, r, request, format)
+		return
+	}
+
 	result, err := h.InstitutionUC.GetProductStatistics(ctx, request)
 	if err != nil {
 		commonwriter.SetError(ctx, w, err)
@@ -101,3 +208,49 @@ func (h *InstitutionHandler) GetProductStatistics(w http.ResponseWriter, r *http
 
 	commonwriter.SetOKWithData(ctx, w, result)
 }
+
+// exportProductStatistics streams the statistics row by row, ending with a
+// total row. Once the file has started an error can only cut it short.
+func (h *InstitutionHandler) exportProductStatistics(w http.ResponseWriter, r *http.Request, request model.ProductStatisticsParams, format string) {
+	ctx := r.Context()
+
+	query, err := h.InstitutionUC.BuildProductStatisticsQuery(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("product-statistics-%s-%s-%s", query.Granularity,
+		request.StartTime.Format("20060102"), request.EndTime.In(query.StartTime.Location()).Format("20060102"))
+	table, err := export.NewWriter(w, format, filename, productStatisticsExportColumns, query.StartTime.Location())
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var quantity int64
+	revenue, cost, margin, tax, serviceCharge := money.Zero, money.Zero, money.Zero, money.Zero, money.Zero
+	err = h.InstitutionUC.StreamProductStatistics(ctx, query, func(line model.ProductStatisticsLine) error {
+		quantity += line.TotalQuantity
+		revenue = revenue.Add(line.TotalRevenue)
+		cost = cost.Add(line.TotalCost)
+		margin = margin.Add(line.TotalMargin)
+		tax = tax.Add(line.TotalTax)
+		serviceCharge = serviceCharge.Add(line.TotalServiceCharge)
+
+		return table.WriteRow(line.PeriodStart, line.PeriodEnd, line.ProductID, line.Name, line.TotalQuantity,
+			line.UnitPrice, line.TotalRevenue, line.TotalCost, line.TotalMargin, line.TotalTax, line.TotalServiceCharge)
+	})
+	if err != nil {
+		liblog.Errorf("export product statistics: %v", err)
+		return
+	}
+
+	if err = table.WriteRow(nil, nil, nil, "Total", quantity, nil, revenue, cost, margin, tax, serviceCharge); err != nil {
+		liblog.Errorf("export product statistics: %v", err)
+		return
+	}
+	if err = table.Close(); err != nil {
+		liblog.Errorf("export product statistics: %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package institution
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/go-chi/chi/v5"
+)
+
+var lowStockExportColumns = []export.Column{
+	{Header: "location", Kind: export.Text},
+	{Header: model.ProductColumnSKU, Kind: export.Text},
+	{Header: model.ProductColumnName, Kind: export.Text},
+	{Header: model.ProductColumnUnitType, Kind: export.Text},
+	{Header: "quantity", Kind: export.Number},
+	{Header: "reorder_level", Kind: export.Number},
+}
+
+// ListStockLocations handles GET /v1/institution/stock-location
+func (h *InstitutionHandler) ListStockLocations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	locations, err := h.InstitutionUC.ListStockLocations(ctx)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, locations)
+}
+
+// CreateStockLocation handles POST /v1/institution/stock-location
+func (h *InstitutionHandler) CreateStockLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.CreateStockLocationRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	location, err := h.InstitutionUC.CreateStockLocation(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, location)
+}
+
+// UpdateStockLocation handles PATCH /v1/institution/stock-location
+func (h *InstitutionHandler) UpdateStockLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.UpdateStockLocationRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	location, err := h.InstitutionUC.UpdateStockLocation(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, location)
+}
+
+// ListLocationStock handles GET /v1/institution/stock-location/stock
+func (h *InstitutionHandler) ListLocationStock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ListLocationStockParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	rows, err := h.InstitutionUC.ListLocationStock(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, rows)
+}
+
+// GetLowStockReport handles GET /v1/institution/stock-location/low-stock: the
+// products at or below their reorder level, per location. With
+// ?export=csv|xlsx, or an Accept header asking for either, it is a file.
+func (h *InstitutionHandler) GetLowStockReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ListLocationStockParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	params.LowStockOnly = true
+
+	format, err := export.Format(r)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	rows, err := h.InstitutionUC.ListLocationStock(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	if format == "" {
+		commonwriter.SetOKWithData(ctx, w, rows)
+		return
+	}
+
+	filename := fmt.Sprintf("low-stock-%s", time.Now().Format("20060102"))
+	table, err := export.NewWriter(w, format, filename, lowStockExportColumns, time.Local)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	for _, row := range rows {
+		err = table.WriteRow(row.LocationName, row.SKU.String, row.Name, row.UnitType, row.Quantity, row.ReorderLevel)
+		if err != nil {
+			liblog.Errorf("export low stock report: %v", err)
+			return
+		}
+	}
+	if err = table.Close(); err != nil {
+		liblog.Errorf("export low stock report: %v", err)
+	}
+}
+
+// SaveReorderLevels handles PUT /v1/institution/stock-location/{id}/reorder-level
+func (h *InstitutionHandler) SaveReorderLevels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	locationID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	request := model.SaveReorderLevelsRequest{}
+	err = bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.IDMstStockLocation = locationID
+
+	rows, err := h.InstitutionUC.SaveReorderLevels(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, rows)
+}
+
+// CreateStockTransfer handles POST /v1/institution/stock-transfer
+func (h *InstitutionHandler) CreateStockTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.CreateStockTransferRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	transfer, err := h.InstitutionUC.CreateStockTransfer(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, transfer)
+}
+
+// ListStockTransfers handles GET /v1/institution/stock-transfer
+func (h *InstitutionHandler) ListStockTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ListStockTransferParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	transfers, err := h.InstitutionUC.ListStockTransfers(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, transfers)
+}
+
+// GetStockTransfer handles GET /v1/institution/stock-transfer/{id}
+func (h *InstitutionHandler) GetStockTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	transferID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	transfer, err := h.InstitutionUC.GetStockTransfer(ctx, transferID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, transfer)
+}
+
+func parseInt64Param(r *http.Request, key string) (int64, error) {
+	raw := chi.URLParam(r, key)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, commonerr.SetNewBadRequest("invalid_parameter", key+" must be an integer")
+	}
+	return v, nil
+}
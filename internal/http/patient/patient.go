@@ -100,3 +100,24 @@ func (h *PatientHandler) UpdatePatient(w http.ResponseWriter, r *http.Request) {
 
 	commonwriter.SetOKWithData(ctx, w, "ok")
 }
+
+// GetPatientTimeline handles GET /v1/patient/{uuid}/timeline
+func (h *PatientHandler) GetPatientTimeline(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.GetPatientTimelineParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	params.PatientUUID = chi.URLParam(r, "uuid")
+
+	timeline, err := h.PatientUC.GetPatientTimeline(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, timeline)
+}
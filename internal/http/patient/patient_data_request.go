@@ -0,0 +1,59 @@
+package patient
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/go-chi/chi/v5"
+)
+
+// ExportPatientData handles GET /v1/patient/{uuid}/data-export, a zip archive
+// of everything held about the patient. ?reason= is kept with the record of
+// the export.
+func (h *PatientHandler) ExportPatientData(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ExportPatientDataParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	params.PatientUUID = chi.URLParam(r, "uuid")
+
+	export, err := h.PatientUC.ExportPatientData(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="patient-%s-data.zip"`, export.Manifest.PatientUUID))
+	if err = h.PatientUC.WritePatientDataExport(ctx, export, w); err != nil {
+		liblog.Errorf("export patient data: %v", err)
+	}
+}
+
+// AnonymisePatient handles POST /v1/patient/{uuid}/anonymise
+func (h *PatientHandler) AnonymisePatient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.AnonymisePatientRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.PatientUUID = chi.URLParam(r, "uuid")
+
+	patient, err := h.PatientUC.AnonymisePatient(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, patient)
+}
@@ -0,0 +1,172 @@
+package patient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+	liblog "github.com/faisalhardin/medilink/internal/library/common/log"
+	"github.com/faisalhardin/medilink/internal/library/common/spreadsheet"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/go-chi/chi/v5"
+)
+
+// patientImportMaxFileSize bounds patient uploads.
+const patientImportMaxFileSize = 20 << 20
+
+// ImportPatients handles POST /v1/patient/import, a multipart form with the
+// patients as file, in CSV or XLSX. mapping is a JSON object from headers of
+// the file to patient fields, date_order one of dmy (the default), mdy or ymd,
+// and import_possible_duplicates=true imports patients that only look like an
+// existing one. The import runs in the background; poll its status.
+func (h *PatientHandler) ImportPatients(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, patientImportMaxFileSize+1<<20)
+	if err := r.ParseMultipartForm(patientImportMaxFileSize); err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", "upload the patients as the file field of a multipart form, up to 20 MB"))
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", "file is required"))
+		return
+	}
+	defer file.Close()
+
+	format, err := spreadsheet.FormatOf(header.Filename)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", err.Error()))
+		return
+	}
+	rows, err := spreadsheet.ReadRows(file, header.Size, format, model.PatientImportMaxRows)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid file", err.Error()))
+		return
+	}
+
+	options := model.PatientImportOptions{DateOrder: r.FormValue("date_order")}
+	if mapping := r.FormValue("mapping"); mapping != "" {
+		if err = json.Unmarshal([]byte(mapping), &options.Mapping); err != nil {
+			commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid mapping", "mapping must be a JSON object from column headers to patient fields"))
+			return
+		}
+	}
+	options.ImportPossibleDuplicates, _ = strconv.ParseBool(r.FormValue("import_possible_duplicates"))
+
+	patientImport, err := h.PatientUC.ImportPatients(ctx, model.PatientImportRequest{
+		FileName: header.Filename,
+		Rows:     rows,
+		Options:  options,
+	})
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, patientImport)
+}
+
+// ListPatientImports handles GET /v1/patient/import
+func (h *PatientHandler) ListPatientImports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ListPatientImportParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	patientImports, err := h.PatientUC.ListPatientImports(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, patientImports)
+}
+
+// GetPatientImport handles GET /v1/patient/import/{id}
+func (h *PatientHandler) GetPatientImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	importID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid Import ID"))
+		return
+	}
+
+	patientImport, err := h.PatientUC.GetPatientImport(ctx, importID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, patientImport)
+}
+
+// DownloadPatientImportReport handles GET /v1/patient/import/{id}/report, the
+// lines not imported with their problems followed by their cells, as CSV
+// unless ?export=xlsx or the Accept header asks for XLSX. Fixed lines import
+// again as they are.
+func (h *PatientHandler) DownloadPatientImportReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	importID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid Import ID"))
+		return
+	}
+	format, err := export.Format(r)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	report, err := h.PatientUC.GetPatientImportReport(ctx, importID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	columns := []export.Column{
+		{Header: "line", Kind: export.Text},
+		{Header: "problems", Kind: export.Text},
+	}
+	for _, header := range report.Header {
+		columns = append(columns, export.Column{Header: header, Kind: export.Text})
+	}
+
+	filename := fmt.Sprintf("patient-import-%d-report", importID)
+	table, err := export.NewWriter(w, format, filename, columns, time.Local)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	for _, line := range report.Lines {
+		values := make([]interface{}, len(columns))
+		values[0], values[1] = strconv.Itoa(line.Line), line.Problems
+		for i := range report.Header {
+			values[i+2] = ""
+			if i < len(line.Record) {
+				values[i+2] = line.Record[i]
+			}
+		}
+		if err = table.WriteRow(values...); err != nil {
+			liblog.Errorf("export patient import report: %v", err)
+			return
+		}
+	}
+	if err = table.Close(); err != nil {
+		liblog.Errorf("export patient import report: %v", err)
+	}
+}
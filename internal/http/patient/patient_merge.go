@@ -0,0 +1,83 @@
+package patient
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListPatientDuplicates handles GET /v1/patient/{uuid}/duplicates
+func (h *PatientHandler) ListPatientDuplicates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	candidates, err := h.PatientUC.ListPatientDuplicates(ctx, chi.URLParam(r, "uuid"))
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, candidates)
+}
+
+// MergePatients handles POST /v1/patient/merge
+func (h *PatientHandler) MergePatients(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.MergePatientsRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	merge, err := h.PatientUC.MergePatients(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, merge)
+}
+
+// ListPatientMerges handles GET /v1/patient/merge
+func (h *PatientHandler) ListPatientMerges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	params := model.ListPatientMergeParams{}
+	err := bindingBind(r, &params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	merges, err := h.PatientUC.ListPatientMerges(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, merges)
+}
+
+// UndoPatientMerge handles POST /v1/patient/merge/{id}/undo
+func (h *PatientHandler) UndoPatientMerge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mergeID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid Merge ID"))
+		return
+	}
+
+	merge, err := h.PatientUC.UndoPatientMerge(ctx, mergeID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, merge)
+}
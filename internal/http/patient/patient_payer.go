@@ -0,0 +1,98 @@
+package patient
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListPatientPayers handles GET /v1/patient/{uuid}/payer
+func (h *PatientHandler) ListPatientPayers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	payers, err := h.PatientUC.ListPatientPayers(ctx, chi.URLParam(r, "uuid"))
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, payers)
+}
+
+// SavePatientPayer handles POST /v1/patient/{uuid}/payer and
+// PUT /v1/patient/{uuid}/payer/{id}
+func (h *PatientHandler) SavePatientPayer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.SavePatientPayerRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.PatientUUID = chi.URLParam(r, "uuid")
+	if rawID := chi.URLParam(r, "id"); rawID != "" {
+		request.ID, err = strconv.ParseInt(rawID, 10, 64)
+		if err != nil || request.ID <= 0 {
+			commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid payer ID"))
+			return
+		}
+	}
+
+	payer, err := h.PatientUC.SavePatientPayer(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, payer)
+}
+
+// DeletePatientPayer handles DELETE /v1/patient/{uuid}/payer/{id}
+func (h *PatientHandler) DeletePatientPayer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	payerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid payer ID"))
+		return
+	}
+
+	err = h.PatientUC.DeletePatientPayer(ctx, chi.URLParam(r, "uuid"), payerID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, "ok")
+}
+
+// CheckPayerEligibility handles POST /v1/patient/{uuid}/payer/{id}/eligibility
+func (h *PatientHandler) CheckPayerEligibility(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.CheckPatientPayerEligibilityRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.PatientUUID = chi.URLParam(r, "uuid")
+	request.ID, err = strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || request.ID <= 0 {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid payer ID"))
+		return
+	}
+
+	payer, err := h.PatientUC.CheckPayerEligibility(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, payer)
+}
@@ -0,0 +1,72 @@
+package patient
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListPatientRelations handles GET /v1/patient/{uuid}/relation
+func (h *PatientHandler) ListPatientRelations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	relations, err := h.PatientUC.ListPatientRelations(ctx, chi.URLParam(r, "uuid"))
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, relations)
+}
+
+// SavePatientRelation handles POST /v1/patient/{uuid}/relation and
+// PUT /v1/patient/{uuid}/relation/{id}
+func (h *PatientHandler) SavePatientRelation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.SavePatientRelationRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.PatientUUID = chi.URLParam(r, "uuid")
+	if rawID := chi.URLParam(r, "id"); rawID != "" {
+		request.ID, err = strconv.ParseInt(rawID, 10, 64)
+		if err != nil || request.ID <= 0 {
+			commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid relation ID"))
+			return
+		}
+	}
+
+	relation, err := h.PatientUC.SavePatientRelation(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, relation)
+}
+
+// DeletePatientRelation handles DELETE /v1/patient/{uuid}/relation/{id}
+func (h *PatientHandler) DeletePatientRelation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	relationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid relation ID"))
+		return
+	}
+
+	err = h.PatientUC.DeletePatientRelation(ctx, chi.URLParam(r, "uuid"), relationID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, "ok")
+}
@@ -99,6 +99,51 @@ func (h *PatientHandler) GetPatientVisits(w http.ResponseWriter, r *http.Request
 	commonwriter.SetOKWithData(ctx, w, visits)
 }
 
+func (h *PatientHandler) GetVisitInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	visitID := chi.URLParam(r, "id")
+	parsedVisitID, err := strconv.ParseInt(visitID, 10, 64)
+	if err != nil {
+		errMsg := commonerr.SetNewBadRequest("invalid", "Invalid Visit ID")
+		commonwriter.SetError(ctx, w, errMsg)
+		return
+	}
+
+	invoice, err := h.VisitUC.GetVisitInvoice(ctx, parsedVisitID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, invoice)
+}
+
+// SelectVisitPayer handles PUT /v1/visit/{id}/payer
+func (h *PatientHandler) SelectVisitPayer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := model.SelectVisitPayerRequest{}
+	err := bindingBind(r, &request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	request.IDTrxPatientVisit, err = strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		commonwriter.SetError(ctx, w, commonerr.SetNewBadRequest("invalid", "Invalid Visit ID"))
+		return
+	}
+
+	visit, err := h.VisitUC.SelectVisitPayer(ctx, request)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	commonwriter.SetOKWithData(ctx, w, visit)
+}
+
 func (h *PatientHandler) ListPatientVisitsDetailed(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -0,0 +1,145 @@
+package pricelist
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	pricelistuc "github.com/faisalhardin/medilink/internal/entity/usecase/pricelist"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+type PriceListHandler struct {
+	PriceListUC pricelistuc.PriceListUC
+}
+
+func New(h *PriceListHandler) *PriceListHandler {
+	return h
+}
+
+// CreatePriceList handles POST /v1/institution/price-list
+func (h *PriceListHandler) CreatePriceList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.CreatePriceListRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.PriceListUC.CreatePriceList(ctx, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// ListPriceLists handles GET /v1/institution/price-list
+func (h *PriceListHandler) ListPriceLists(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.ListPriceListParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	priceLists, err := h.PriceListUC.ListPriceLists(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, priceLists)
+}
+
+// GetPriceList handles GET /v1/institution/price-list/:id
+func (h *PriceListHandler) GetPriceList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	priceListID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.PriceListUC.GetPriceList(ctx, priceListID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// UpdatePriceListItems handles PUT /v1/institution/price-list/:id/items
+func (h *PriceListHandler) UpdatePriceListItems(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	priceListID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.UpdatePriceListItemsRequest
+	if err = bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.PriceListUC.UpdatePriceListItems(ctx, priceListID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// DeletePriceList handles DELETE /v1/institution/price-list/:id
+func (h *PriceListHandler) DeletePriceList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	priceListID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	if err = h.PriceListUC.DeletePriceList(ctx, priceListID); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, "ok")
+}
+
+// GetProductPriceHistory handles GET /v1/institution/product/price-history
+func (h *PriceListHandler) GetProductPriceHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.ProductPriceHistoryParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.PriceListUC.GetProductPriceHistory(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+func parseInt64Param(r *http.Request, key string) (int64, error) {
+	raw := chi.URLParam(r, key)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, commonerr.SetNewBadRequest("invalid_parameter", key+" must be an integer")
+	}
+	return v, nil
+}
@@ -0,0 +1,223 @@
+package purchasing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	purchasinguc "github.com/faisalhardin/medilink/internal/entity/usecase/purchasing"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+type PurchasingHandler struct {
+	PurchasingUC purchasinguc.PurchasingUC
+}
+
+func New(h *PurchasingHandler) *PurchasingHandler {
+	return h
+}
+
+// CreateSupplier handles POST /v1/institution/supplier
+func (h *PurchasingHandler) CreateSupplier(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.CreateSupplierRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	supplier, err := h.PurchasingUC.CreateSupplier(ctx, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, supplier)
+}
+
+// UpdateSupplier handles PATCH /v1/institution/supplier
+func (h *PurchasingHandler) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.UpdateSupplierRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	if err := h.PurchasingUC.UpdateSupplier(ctx, req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, "ok")
+}
+
+// ListSuppliers handles GET /v1/institution/supplier
+func (h *PurchasingHandler) ListSuppliers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.ListSupplierParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	suppliers, err := h.PurchasingUC.ListSuppliers(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, suppliers)
+}
+
+// CreatePurchaseOrder handles POST /v1/institution/purchase-order
+func (h *PurchasingHandler) CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.CreatePurchaseOrderRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	order, err := h.PurchasingUC.CreatePurchaseOrder(ctx, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, order)
+}
+
+// UpdatePurchaseOrder handles PATCH /v1/institution/purchase-order/:id
+func (h *PurchasingHandler) UpdatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	orderID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.UpdatePurchaseOrderRequest
+	if err = bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	order, err := h.PurchasingUC.UpdatePurchaseOrder(ctx, orderID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, order)
+}
+
+// GetPurchaseOrder handles GET /v1/institution/purchase-order/:id
+func (h *PurchasingHandler) GetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	orderID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	order, err := h.PurchasingUC.GetPurchaseOrder(ctx, orderID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, order)
+}
+
+// ListPurchaseOrders handles GET /v1/institution/purchase-order
+func (h *PurchasingHandler) ListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.ListPurchaseOrderParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	orders, err := h.PurchasingUC.ListPurchaseOrders(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, orders)
+}
+
+// OrderPurchaseOrder handles POST /v1/institution/purchase-order/:id/order
+func (h *PurchasingHandler) OrderPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	orderID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	order, err := h.PurchasingUC.OrderPurchaseOrder(ctx, orderID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, order)
+}
+
+// CancelPurchaseOrder handles POST /v1/institution/purchase-order/:id/cancel
+func (h *PurchasingHandler) CancelPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	orderID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	order, err := h.PurchasingUC.CancelPurchaseOrder(ctx, orderID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, order)
+}
+
+// ReceivePurchaseOrder handles POST /v1/institution/purchase-order/:id/receive
+func (h *PurchasingHandler) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	orderID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.ReceivePurchaseOrderRequest
+	if err = bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	order, err := h.PurchasingUC.ReceivePurchaseOrder(ctx, orderID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, order)
+}
+
+func parseInt64Param(r *http.Request, key string) (int64, error) {
+	raw := chi.URLParam(r, key)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, commonerr.SetNewBadRequest("invalid_parameter", key+" must be an integer")
+	}
+	return v, nil
+}
@@ -0,0 +1,218 @@
+package stocktake
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	stocktakeuc "github.com/faisalhardin/medilink/internal/entity/usecase/stocktake"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	commonwriter "github.com/faisalhardin/medilink/internal/library/common/writer"
+	"github.com/faisalhardin/medilink/internal/library/util/common/binding"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/go-chi/chi/v5"
+)
+
+var bindingBind = binding.Bind
+
+var varianceReportHeader = []string{
+	"product_id", "name", "unit_type", "system_quantity", "counted_quantity",
+	"variance", "unit_cost", "variance_value", "counters", "adjustment_reason",
+}
+
+type StockTakeHandler struct {
+	StockTakeUC stocktakeuc.StockTakeUC
+}
+
+func New(h *StockTakeHandler) *StockTakeHandler {
+	return h
+}
+
+// CreateStockTake handles POST /v1/institution/stock-take
+func (h *StockTakeHandler) CreateStockTake(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.CreateStockTakeRequest
+	if err := bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.StockTakeUC.CreateStockTake(ctx, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// ListStockTakes handles GET /v1/institution/stock-take
+func (h *StockTakeHandler) ListStockTakes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params model.ListStockTakeParams
+	if err := bindingBind(r, &params); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	stockTakes, err := h.StockTakeUC.ListStockTakes(ctx, params)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, stockTakes)
+}
+
+// GetStockTake handles GET /v1/institution/stock-take/:id
+func (h *StockTakeHandler) GetStockTake(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stockTakeID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.StockTakeUC.GetStockTake(ctx, stockTakeID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// SaveCounts handles POST /v1/institution/stock-take/:id/count
+func (h *StockTakeHandler) SaveCounts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stockTakeID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.SaveStockTakeCountRequest
+	if err = bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.StockTakeUC.SaveCounts(ctx, stockTakeID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// FinalizeStockTake handles POST /v1/institution/stock-take/:id/finalize
+func (h *StockTakeHandler) FinalizeStockTake(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stockTakeID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	var req model.FinalizeStockTakeRequest
+	if err = bindingBind(r, &req); err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.StockTakeUC.FinalizeStockTake(ctx, stockTakeID, req)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// CancelStockTake handles POST /v1/institution/stock-take/:id/cancel
+func (h *StockTakeHandler) CancelStockTake(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stockTakeID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.StockTakeUC.CancelStockTake(ctx, stockTakeID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+	commonwriter.SetOKWithData(ctx, w, resp)
+}
+
+// DownloadVarianceReport handles GET /v1/institution/stock-take/:id/variance-report
+// and streams the per-item variances as CSV.
+func (h *StockTakeHandler) DownloadVarianceReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stockTakeID, err := parseInt64Param(r, "id")
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	resp, err := h.StockTakeUC.GetStockTake(ctx, stockTakeID)
+	if err != nil {
+		commonwriter.SetError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="stock-take-%d-variance.csv"`, resp.ID))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write(varianceReportHeader)
+	for _, item := range resp.Items {
+		_ = writer.Write(varianceReportRecord(item))
+	}
+	writer.Flush()
+}
+
+func varianceReportRecord(item model.StockTakeItemResponse) []string {
+	counted, variance := "", ""
+	if item.CountedQuantity != nil {
+		counted = strconv.FormatInt(*item.CountedQuantity, 10)
+	}
+	if item.Variance != nil {
+		variance = strconv.FormatInt(*item.Variance, 10)
+	}
+
+	counters := make([]string, 0, len(item.Counts))
+	for _, count := range item.Counts {
+		counters = append(counters, fmt.Sprintf("%s=%d", count.CountedBy, count.CountedQuantity))
+	}
+
+	return []string{
+		strconv.FormatInt(item.IDTrxInstitutionProduct, 10),
+		item.Name,
+		item.UnitType,
+		strconv.FormatInt(item.SystemQuantity, 10),
+		counted,
+		variance,
+		item.UnitCost.StringFixed(money.Scale),
+		item.VarianceValue.StringFixed(money.Scale),
+		strings.Join(counters, "; "),
+		item.AdjustmentReason,
+	}
+}
+
+func parseInt64Param(r *http.Request, key string) (int64, error) {
+	raw := chi.URLParam(r, key)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, commonerr.SetNewBadRequest("invalid_parameter", key+" must be an integer")
+	}
+	return v, nil
+}
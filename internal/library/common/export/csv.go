@@ -0,0 +1,107 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+// csvFlushRows is how many rows are buffered before they are sent to the client.
+const csvFlushRows = 500
+
+type csvWriter struct {
+	w       *csv.Writer
+	flusher http.Flusher
+	columns []Column
+	loc     *time.Location
+	rows    int
+}
+
+func newCSVWriter(w http.ResponseWriter, columns []Column, loc *time.Location) (*csvWriter, error) {
+	cw := &csvWriter{
+		w:       csv.NewWriter(w),
+		columns: columns,
+		loc:     loc,
+	}
+	cw.flusher, _ = w.(http.Flusher)
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	if err := cw.w.Write(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(cw.columns))
+	for i, column := range cw.columns {
+		if i < len(values) {
+			record[i] = cw.value(column.Kind, values[i])
+		}
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+
+	cw.rows++
+	if cw.rows%csvFlushRows == 0 {
+		return cw.flush()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	return cw.flush()
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return err
+	}
+	if cw.flusher != nil {
+		cw.flusher.Flush()
+	}
+	return nil
+}
+
+func (cw *csvWriter) value(kind Kind, value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	switch kind {
+	case Rupiah:
+		if m, ok := moneyValue(value); ok {
+			return m.StringFixed(money.Scale)
+		}
+	case DateTime:
+		if t, ok := value.(time.Time); ok {
+			if t.IsZero() {
+				return ""
+			}
+			return t.In(cw.loc).Format(csvTimeLayout)
+		}
+	case Number:
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v)
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
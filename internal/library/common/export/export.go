@@ -0,0 +1,119 @@
+// Package export streams tables as CSV or XLSX file downloads. Rows are
+// written as they are produced, so a report never has to be held in memory.
+package export
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	ContentTypeCSV  = "text/csv"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	// QueryParam selects the file format of an endpoint that also answers JSON.
+	QueryParam = "export"
+
+	csvTimeLayout = "2006-01-02 15:04:05"
+)
+
+// Kind tells how the values of a column are written.
+type Kind int
+
+const (
+	// Text columns take strings; other values are written with fmt.
+	Text Kind = iota
+	// Number columns take int, int64 or float64.
+	Number
+	// Rupiah columns take money.Money.
+	Rupiah
+	// DateTime columns take time.Time, written as wall clock time of the
+	// writer's location.
+	DateTime
+)
+
+type Column struct {
+	Header string
+	Kind   Kind
+}
+
+// Writer writes the rows of a table. A nil value, or a zero time, leaves its
+// cell empty. Close must be called once the last row is written.
+type Writer interface {
+	WriteRow(values ...interface{}) error
+	Close() error
+}
+
+// Format returns the format a request asks for, from its export query
+// parameter or else its Accept header, or "" when it wants JSON.
+func Format(r *http.Request) (string, error) {
+	if format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get(QueryParam))); format != "" {
+		if format != FormatCSV && format != FormatXLSX {
+			return "", commonerr.SetNewBadRequest("invalid export", "export must be csv or xlsx")
+		}
+		return format, nil
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accept, ";", 2)[0])
+		switch mediaType {
+		case ContentTypeCSV:
+			return FormatCSV, nil
+		case ContentTypeXLSX:
+			return FormatXLSX, nil
+		}
+	}
+	return "", nil
+}
+
+// NewWriter starts a download of filename, without extension, in format and
+// writes the header row of columns. Times are written in loc.
+func NewWriter(w http.ResponseWriter, format, filename string, columns []Column, loc *time.Location) (Writer, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch format {
+	case FormatCSV:
+		setDownloadHeaders(w, ContentTypeCSV+"; charset=utf-8", filename+".csv")
+		return newCSVWriter(w, columns, loc)
+	case FormatXLSX:
+		setDownloadHeaders(w, ContentTypeXLSX, filename+".xlsx")
+		return newXLSXWriter(w, columns, loc)
+	default:
+		return nil, commonerr.SetNewBadRequest("invalid export", "export must be csv or xlsx")
+	}
+}
+
+func setDownloadHeaders(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+}
+
+// wallClock returns t in loc as the same wall clock time in UTC, which is how
+// spreadsheets store times.
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func moneyValue(value interface{}) (money.Money, bool) {
+	switch v := value.(type) {
+	case money.Money:
+		return v, true
+	case *money.Money:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return money.Money{}, false
+}
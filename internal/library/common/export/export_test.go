@@ -0,0 +1,139 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+var testColumns = []Column{
+	{Header: "period_start", Kind: DateTime},
+	{Header: "name", Kind: Text},
+	{Header: "quantity", Kind: Number},
+	{Header: "revenue", Kind: Rupiah},
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		target  string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{name: "json by default", target: "/stats", accept: "application/json", want: ""},
+		{name: "query parameter", target: "/stats?export=XLSX", want: FormatXLSX},
+		{name: "query parameter beats accept", target: "/stats?export=csv", accept: ContentTypeXLSX, want: FormatCSV},
+		{name: "accept header", target: "/stats", accept: "application/json;q=0.5, text/csv", want: FormatCSV},
+		{name: "unknown format", target: "/stats?export=pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		got, err := Format(r)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriterLocalisesTimes(t *testing.T) {
+	t.Parallel()
+
+	wib := time.FixedZone("WIB", 7*3600)
+	rec := httptest.NewRecorder()
+	w, err := NewWriter(rec, FormatCSV, "stats", testColumns, wib)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	_ = w.WriteRow(time.Date(2026, 1, 31, 18, 30, 0, 0, time.UTC), "Paracetamol", int64(3), money.New(15000))
+	_ = w.WriteRow(nil, "Total", int64(3), money.New(15000))
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := "period_start,name,quantity,revenue\n" +
+		"2026-02-01 01:30:00,Paracetamol,3,15000.00\n" +
+		",Total,3,15000.00\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="stats.csv"` {
+		t.Fatalf("Content-Disposition = %q", got)
+	}
+}
+
+func TestXLSXWriterWritesWorkbook(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	w, err := NewWriter(rec, FormatXLSX, "stats", testColumns, time.FixedZone("WIB", 7*3600))
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	_ = w.WriteRow(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), "Salep <kulit> & gel", int64(2), money.NewFromFloat(12500.5))
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	body := rec.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("response is not a zip archive: %v", err)
+	}
+
+	parts := map[string]string{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(content)
+	}
+	for _, part := range xlsxStaticParts {
+		if _, ok := parts[part.path]; !ok {
+			t.Fatalf("workbook misses %s", part.path)
+		}
+	}
+
+	sheet := parts[xlsxSheetPath]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">period_start</t></is></c>`,
+		// 2026-01-01 07:00 WIB
+		`<c r="A2" s="3"><v>46023.291666666664</v></c>`,
+		`<t xml:space="preserve">Salep &lt;kulit&gt; &amp; gel</t>`,
+		`<c r="C2" s="0"><v>2</v></c>`,
+		`<c r="D2" s="2"><v>12500.50</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("sheet misses %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	t.Parallel()
+
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Fatalf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
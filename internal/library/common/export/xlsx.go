@@ -0,0 +1,219 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+// Cell styles, as indexes into the cellXfs of xlsxStyles.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleRupiah
+	xlsxStyleDateTime
+)
+
+const xlsxSheetPath = "xl/worksheets/sheet1.xml"
+
+// xlsxEpoch is day zero of spreadsheet date serials.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+var xlsxStaticParts = []struct {
+	path    string
+	content string
+}{
+	{
+		path: "[Content_Types].xml",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`,
+	},
+	{
+		path: "_rels/.rels",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		path: "xl/workbook.xml",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`,
+	},
+	{
+		path: "xl/_rels/workbook.xml.rels",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		// number format 164 shows Rupiah, 165 a date and time
+		path: "xl/styles.xml",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts count="2">` +
+			`<numFmt numFmtId="164" formatCode="&quot;Rp &quot;#,##0.00;-&quot;Rp &quot;#,##0.00"/>` +
+			`<numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss"/>` +
+			`</numFmts>` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="4">` +
+			`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`</cellXfs>` +
+			`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+			`</styleSheet>`,
+	},
+}
+
+// xlsxWriter writes a single sheet workbook. The sheet is the last part of the
+// archive, so its rows go to the client as they are written.
+type xlsxWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	columns []Column
+	loc     *time.Location
+	row     int
+}
+
+func newXLSXWriter(w io.Writer, columns []Column, loc *time.Location) (*xlsxWriter, error) {
+	xw := &xlsxWriter{
+		zip:     zip.NewWriter(w),
+		columns: columns,
+		loc:     loc,
+	}
+
+	for _, part := range xlsxStaticParts {
+		f, err := xw.zip.Create(part.path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := xw.zip.Create(xlsxSheetPath)
+	if err != nil {
+		return nil, err
+	}
+	xw.sheet = bufio.NewWriter(f)
+	_, _ = xw.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	xw.writeRow(header, true)
+	return xw, nil
+}
+
+func (xw *xlsxWriter) WriteRow(values ...interface{}) error {
+	xw.writeRow(values, false)
+	return nil
+}
+
+func (xw *xlsxWriter) Close() error {
+	if _, err := xw.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zip.Close()
+}
+
+// writeRow buffers the row; write errors surface on Close, when the sheet is flushed.
+func (xw *xlsxWriter) writeRow(values []interface{}, header bool) {
+	xw.row++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.row)
+	for i, column := range xw.columns {
+		if i >= len(values) || values[i] == nil {
+			continue
+		}
+		ref := columnName(i) + strconv.Itoa(xw.row)
+		if header {
+			xw.writeText(ref, xlsxStyleHeader, fmt.Sprint(values[i]))
+			continue
+		}
+		xw.writeCell(ref, column.Kind, values[i])
+	}
+	_, _ = xw.sheet.WriteString(`</row>`)
+}
+
+func (xw *xlsxWriter) writeCell(ref string, kind Kind, value interface{}) {
+	switch kind {
+	case Rupiah:
+		if m, ok := moneyValue(value); ok {
+			xw.writeNumber(ref, xlsxStyleRupiah, m.StringFixed(money.Scale))
+			return
+		}
+	case DateTime:
+		if t, ok := value.(time.Time); ok {
+			if t.IsZero() {
+				return
+			}
+			serial := wallClock(t, xw.loc).Sub(xlsxEpoch).Seconds() / (24 * 60 * 60)
+			xw.writeNumber(ref, xlsxStyleDateTime, strconv.FormatFloat(serial, 'f', -1, 64))
+			return
+		}
+	case Number:
+		switch v := value.(type) {
+		case int:
+			xw.writeNumber(ref, xlsxStyleDefault, strconv.Itoa(v))
+			return
+		case int64:
+			xw.writeNumber(ref, xlsxStyleDefault, strconv.FormatInt(v, 10))
+			return
+		case float64:
+			xw.writeNumber(ref, xlsxStyleDefault, strconv.FormatFloat(v, 'f', -1, 64))
+			return
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		xw.writeText(ref, xlsxStyleDefault, s)
+		return
+	}
+	xw.writeText(ref, xlsxStyleDefault, fmt.Sprint(value))
+}
+
+func (xw *xlsxWriter) writeNumber(ref string, style int, value string) {
+	fmt.Fprintf(xw.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, value)
+}
+
+func (xw *xlsxWriter) writeText(ref string, style int, value string) {
+	fmt.Fprintf(xw.sheet, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+	_ = xml.EscapeText(xw.sheet, []byte(value))
+	_, _ = xw.sheet.WriteString(`</t></is></c>`)
+}
+
+// columnName returns the spreadsheet name of the zero-based column i: A, B, ..., Z, AA, AB, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,95 @@
+// Package imaging makes thumbnails of uploaded JPEG, PNG and GIF images with
+// the standard library alone.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// decoders for image.Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// MaxPixels refuses images that would take too much memory to decode,
+	// e.g. a small file claiming huge dimensions.
+	MaxPixels = 50_000_000
+
+	thumbnailQuality = 80
+)
+
+var ErrTooLarge = errors.New("imaging: image dimensions are too large")
+
+// Thumbnail decodes data and returns it as a JPEG whose longer side is at most
+// maxSide pixels. Smaller images keep their size.
+func Thumbnail(data []byte, maxSide int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, scaleDown(src, maxSide), &jpeg.Options{Quality: thumbnailQuality})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown resizes src to fit maxSide by averaging the source pixels under
+// each target pixel, over white so transparent areas do not turn black.
+func scaleDown(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w >= h && w > maxSide {
+		tw, th = maxSide, maxInt(1, h*maxSide/w)
+	} else if h > w && h > maxSide {
+		tw, th = maxInt(1, w*maxSide/h), maxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+maxInt((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+maxInt((x+1)*w/tw, x*w/tw+1)
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					// composite the premultiplied colour over white
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		w, h          int
+		c             color.Color
+		wantW, wantH  int
+		wantGrayAbove uint8
+	}{
+		{name: "landscape", w: 800, h: 400, c: color.NRGBA{R: 200, G: 10, B: 10, A: 255}, wantW: 256, wantH: 128},
+		{name: "portrait", w: 300, h: 600, c: color.NRGBA{R: 10, G: 10, B: 200, A: 255}, wantW: 128, wantH: 256},
+		{name: "small keeps its size", w: 40, h: 30, c: color.NRGBA{R: 10, G: 200, B: 10, A: 255}, wantW: 40, wantH: 30},
+		{name: "transparent turns white", w: 20, h: 20, c: color.NRGBA{}, wantW: 20, wantH: 20, wantGrayAbove: 240},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			thumb, err := Thumbnail(encodePNG(t, tt.w, tt.h, tt.c), 256)
+			if err != nil {
+				t.Fatalf("Thumbnail() = %v", err)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatalf("thumbnail is not a JPEG: %v", err)
+			}
+			if got := img.Bounds(); got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+				t.Fatalf("thumbnail is %dx%d, want %dx%d", got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+			}
+			if tt.wantGrayAbove > 0 {
+				r, g, b, _ := img.At(tt.wantW/2, tt.wantH/2).RGBA()
+				if uint8(r>>8) < tt.wantGrayAbove || uint8(g>>8) < tt.wantGrayAbove || uint8(b>>8) < tt.wantGrayAbove {
+					t.Fatalf("centre pixel = (%d, %d, %d), want white", r>>8, g>>8, b>>8)
+				}
+			}
+		})
+	}
+}
+
+func TestThumbnailRejectsNonImages(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Thumbnail([]byte("%PDF-1.7\n"), 256); err == nil {
+		t.Fatalf("Thumbnail(pdf) = nil error, want an error")
+	}
+}
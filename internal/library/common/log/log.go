@@ -55,3 +55,19 @@ func Infof(format string, v ...interface{}) {
 func InfoWithFields(msg string, fields logger.KV) {
 	infoLogger.InfoWithFields(msg, fields)
 }
+
+func Warn(args ...interface{}) {
+	warnLogger.Warn(args...)
+}
+
+func Warnln(args ...interface{}) {
+	warnLogger.Warnln(args...)
+}
+
+func Warnf(format string, v ...interface{}) {
+	warnLogger.Warnf(format, v...)
+}
+
+func WarnWithFields(msg string, fields logger.KV) {
+	warnLogger.WarnWithFields(msg, fields)
+}
@@ -0,0 +1,92 @@
+// Package spreadsheet reads uploaded CSV and XLSX files into rows of text,
+// the counterpart of the export package.
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("file must be .csv or .xlsx")
+	ErrTooManyRows       = errors.New("file has too many rows")
+)
+
+// FormatOf returns the format of an uploaded file from its name.
+func FormatOf(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return export.FormatCSV, nil
+	case ".xlsx":
+		return export.FormatXLSX, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// ReadRows reads the rows of a CSV file or of the first sheet of an XLSX
+// file, failing with ErrTooManyRows past maxRows. Cells are trimmed and
+// trailing empty rows are dropped.
+func ReadRows(r io.ReaderAt, size int64, format string, maxRows int) (rows [][]string, err error) {
+	switch format {
+	case export.FormatCSV:
+		rows, err = readCSV(io.NewSectionReader(r, 0, size), maxRows)
+	case export.FormatXLSX:
+		rows, err = readXLSX(r, size, maxRows)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		for j := range rows[i] {
+			rows[i][j] = strings.TrimSpace(rows[i][j])
+		}
+	}
+	for len(rows) > 0 && isEmptyRow(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+func readCSV(r io.Reader, maxRows int) (rows [][]string, err error) {
+	// spreadsheet programs often save CSV files with a byte order mark
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+		rows = append(rows, record)
+	}
+}
+
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/library/common/export"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestReadRowsCSV(t *testing.T) {
+	t.Parallel()
+
+	content := "\xef\xbb\xbfsku,name,price\n A-1 ,Kasa steril,12500\nB-2,\"Salep, 5g\"\n,,\n"
+	rows, err := ReadRows(strings.NewReader(content), int64(len(content)), export.FormatCSV, 10)
+	if err != nil {
+		t.Fatalf("ReadRows: %v", err)
+	}
+
+	want := [][]string{
+		{"sku", "name", "price"},
+		{"A-1", "Kasa steril", "12500"},
+		{"B-2", "Salep, 5g"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %q, want %q", rows, want)
+	}
+
+	if _, err = ReadRows(strings.NewReader(content), int64(len(content)), export.FormatCSV, 2); err != ErrTooManyRows {
+		t.Fatalf("err = %v, want ErrTooManyRows", err)
+	}
+}
+
+func TestReadRowsXLSXWrittenByExport(t *testing.T) {
+	t.Parallel()
+
+	columns := []export.Column{
+		{Header: "name", Kind: export.Text},
+		{Header: "quantity", Kind: export.Number},
+		{Header: "price", Kind: export.Rupiah},
+	}
+	rec := httptest.NewRecorder()
+	w, err := export.NewWriter(rec, export.FormatXLSX, "catalog", columns, time.UTC)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	_ = w.WriteRow("Kasa <steril>", int64(3), money.New(12500))
+	_ = w.WriteRow("Salep", nil, money.NewFromFloat(7500.5))
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	body := rec.Body.Bytes()
+	rows, err := ReadRows(bytes.NewReader(body), int64(len(body)), export.FormatXLSX, 10)
+	if err != nil {
+		t.Fatalf("ReadRows: %v", err)
+	}
+
+	want := [][]string{
+		{"name", "quantity", "price"},
+		{"Kasa <steril>", "3", "12500.00"},
+		{"Salep", "", "7500.50"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %q, want %q", rows, want)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]string{"catalog.CSV": export.FormatCSV, "katalog cabang.xlsx": export.FormatXLSX} {
+		if got, err := FormatOf(name); err != nil || got != want {
+			t.Fatalf("FormatOf(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := FormatOf("catalog.xls"); err != ErrUnsupportedFormat {
+		t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+	}
+}
@@ -0,0 +1,248 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// xlsxMaxPartSize bounds how much of a part is inflated, so a small upload
+// cannot expand into an unbounded amount of XML.
+const xlsxMaxPartSize = 64 << 20
+
+var ErrInvalidXLSX = errors.New("file is not a valid xlsx workbook")
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a shared or inline string, either plain or split into rich text runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+func readXLSX(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidXLSX
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	sheet, ok := files[sheetPath]
+	if !ok {
+		return nil, ErrInvalidXLSX
+	}
+
+	var sharedStrings []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err = decodePart(f, &sst); err != nil {
+			return nil, err
+		}
+		sharedStrings = make([]string, len(sst.Items))
+		for i, item := range sst.Items {
+			sharedStrings[i] = item.String()
+		}
+	}
+
+	return readSheet(sheet, sharedStrings, maxRows)
+}
+
+// firstSheetPath follows the workbook's relationships to its first sheet.
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	workbookFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", ErrInvalidXLSX
+	}
+	var workbook xlsxWorkbook
+	if err := decodePart(workbookFile, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", ErrInvalidXLSX
+	}
+
+	relsFile, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok {
+		return "", ErrInvalidXLSX
+	}
+	var rels xlsxRelationships
+	if err := decodePart(relsFile, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", ErrInvalidXLSX
+}
+
+func decodePart(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return ErrInvalidXLSX
+	}
+	defer rc.Close()
+
+	if err = xml.NewDecoder(io.LimitReader(rc, xlsxMaxPartSize)).Decode(v); err != nil {
+		return ErrInvalidXLSX
+	}
+	return nil
+}
+
+// readSheet decodes the sheet a row at a time. Rows and cells the file skips
+// are filled with empty ones, so row i of the result is line i+1 of the sheet.
+func readSheet(f *zip.File, sharedStrings []string, maxRows int) (rows [][]string, err error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, ErrInvalidXLSX
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(rc, xlsxMaxPartSize))
+	var row []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidXLSX
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "row":
+				number := len(rows) + 1
+				if ref := attr(element, "r"); ref != "" {
+					if number, err = strconv.Atoi(ref); err != nil || number <= len(rows) {
+						return nil, ErrInvalidXLSX
+					}
+				}
+				if number > maxRows {
+					return nil, ErrTooManyRows
+				}
+				for len(rows) < number-1 {
+					rows = append(rows, nil)
+				}
+				row = []string{}
+			case "c":
+				var cell xlsxCell
+				if err = decoder.DecodeElement(&cell, &element); err != nil {
+					return nil, ErrInvalidXLSX
+				}
+				column := len(row)
+				if cell.Ref != "" {
+					if column, err = columnIndex(cell.Ref); err != nil {
+						return nil, err
+					}
+				}
+				for len(row) <= column {
+					row = append(row, "")
+				}
+				if row[column], err = cellValue(cell, sharedStrings); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Local == "row" {
+				rows = append(rows, row)
+			}
+		}
+	}
+}
+
+func cellValue(cell xlsxCell, sharedStrings []string) (string, error) {
+	switch cell.Type {
+	case "s":
+		i, err := strconv.Atoi(cell.Value)
+		if err != nil || i < 0 || i >= len(sharedStrings) {
+			return "", ErrInvalidXLSX
+		}
+		return sharedStrings[i], nil
+	case "inlineStr":
+		return cell.Inline.String(), nil
+	case "b":
+		if cell.Value == "1" {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		return cell.Value, nil
+	}
+}
+
+// columnIndex returns the zero-based column of a cell reference such as AB12.
+func columnIndex(ref string) (int, error) {
+	index := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A') + 1
+		letters++
+	}
+	if letters == 0 || letters > 3 {
+		return 0, ErrInvalidXLSX
+	}
+	return index - 1, nil
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
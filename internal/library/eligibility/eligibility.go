@@ -0,0 +1,66 @@
+// Package eligibility asks a payer whether a patient is covered on a service
+// date. Stub answers from what is on file; a bridge to BPJS VClaim or an
+// insurer's API plugs in by implementing Checker.
+package eligibility
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DriverStub = "stub"
+)
+
+// Payer types, as stored on patient payers.
+const (
+	PayerTypeBPJS      = "bpjs"
+	PayerTypeInsurance = "insurance"
+	PayerTypeCorporate = "corporate"
+)
+
+// Statuses of a check.
+const (
+	StatusEligible   = "eligible"
+	StatusIneligible = "ineligible"
+)
+
+// Request describes the membership to check. ValidFrom and ValidTo are the
+// dates on file, nil when open.
+type Request struct {
+	PayerType    string
+	MemberNumber string
+	FKTPCode     string
+	ValidFrom    *time.Time
+	ValidTo      *time.Time
+	ServiceDate  time.Time
+}
+
+// Result is the payer's answer. Message says why a member is not eligible.
+type Result struct {
+	Status    string
+	Message   string
+	CheckedAt time.Time
+}
+
+// Eligible reports whether the payer covers the member.
+func (r Result) Eligible() bool {
+	return r.Status == StatusEligible
+}
+
+type Checker interface {
+	// Check asks the payer about the membership. An error means the payer
+	// could not answer, not that the member is ineligible.
+	Check(ctx context.Context, req Request) (Result, error)
+}
+
+// New returns the checker named by driver.
+func New(driver string) (Checker, error) {
+	switch driver {
+	case "", DriverStub:
+		return NewStub(), nil
+	default:
+		return nil, fmt.Errorf("eligibility: unknown driver %q", driver)
+	}
+}
@@ -0,0 +1,60 @@
+package eligibility
+
+import (
+	"context"
+	"time"
+	"unicode"
+)
+
+// bpjsMemberNumberSize is the number of digits of a BPJS Kesehatan card.
+const bpjsMemberNumberSize = 13
+
+// Stub checks a membership against what is on file, without asking the
+// payer: the member number must be well formed and the service date within
+// the validity dates. It stands in until the clinic is connected to VClaim.
+type Stub struct {
+	now func() time.Time
+}
+
+func NewStub() *Stub {
+	return &Stub{now: time.Now}
+}
+
+func (s *Stub) Check(ctx context.Context, req Request) (Result, error) {
+	result := Result{Status: StatusIneligible, CheckedAt: s.now()}
+
+	serviceDate := truncateDay(req.ServiceDate)
+	switch {
+	case req.MemberNumber == "":
+		result.Message = "member number is missing"
+	case req.PayerType == PayerTypeBPJS && !isBPJSMemberNumber(req.MemberNumber):
+		result.Message = "BPJS member number must be 13 digits"
+	case req.PayerType != PayerTypeBPJS && req.PayerType != PayerTypeInsurance && req.PayerType != PayerTypeCorporate:
+		result.Message = "payer type is not supported"
+	case req.ValidFrom != nil && serviceDate.Before(truncateDay(*req.ValidFrom)):
+		result.Message = "membership is not valid yet on " + serviceDate.Format("2006-01-02")
+	case req.ValidTo != nil && serviceDate.After(truncateDay(*req.ValidTo)):
+		result.Message = "membership expired on " + req.ValidTo.Format("2006-01-02")
+	default:
+		result.Status = StatusEligible
+	}
+	return result, nil
+}
+
+func isBPJSMemberNumber(number string) bool {
+	if len(number) != bpjsMemberNumberSize {
+		return false
+	}
+	for _, r := range number {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// truncateDay drops the time of t, keeping its location, so dates compare by
+// calendar day.
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,47 @@
+package eligibility
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStubCheck(t *testing.T) {
+	t.Parallel()
+
+	date := func(y int, m time.Month, d int) *time.Time {
+		t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	serviceDate := time.Date(2026, time.October, 19, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"bpjs member", Request{PayerType: PayerTypeBPJS, MemberNumber: "0001234567890"}, StatusEligible},
+		{"bpjs short number", Request{PayerType: PayerTypeBPJS, MemberNumber: "000123456789"}, StatusIneligible},
+		{"bpjs letters", Request{PayerType: PayerTypeBPJS, MemberNumber: "000123456789A"}, StatusIneligible},
+		{"insurance policy", Request{PayerType: PayerTypeInsurance, MemberNumber: "POL-778"}, StatusEligible},
+		{"missing number", Request{PayerType: PayerTypeCorporate}, StatusIneligible},
+		{"unknown payer", Request{PayerType: "cash", MemberNumber: "1"}, StatusIneligible},
+		{"valid on the last day", Request{PayerType: PayerTypeInsurance, MemberNumber: "1", ValidFrom: date(2026, time.January, 1), ValidTo: date(2026, time.October, 19)}, StatusEligible},
+		{"expired", Request{PayerType: PayerTypeInsurance, MemberNumber: "1", ValidTo: date(2026, time.October, 18)}, StatusIneligible},
+		{"not valid yet", Request{PayerType: PayerTypeInsurance, MemberNumber: "1", ValidFrom: date(2026, time.October, 20)}, StatusIneligible},
+	}
+	stub := NewStub()
+	for _, tt := range tests {
+		tt.req.ServiceDate = serviceDate
+		got, err := stub.Check(context.Background(), tt.req)
+		if err != nil {
+			t.Fatalf("%s: Check() = %v", tt.name, err)
+		}
+		if got.Status != tt.want {
+			t.Fatalf("%s: Check() = %+v, want %s", tt.name, got, tt.want)
+		}
+		if !got.Eligible() && got.Message == "" {
+			t.Fatalf("%s: Check() gives no reason for %s", tt.name, got.Status)
+		}
+	}
+}
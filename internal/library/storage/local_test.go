@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() = %v", err)
+	}
+
+	if err = store.Put(ctx, "institution/1/a.pdf", strings.NewReader("first")); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if err = store.Put(ctx, "institution/1/a.pdf", strings.NewReader("second")); err != nil {
+		t.Fatalf("Put(replace) = %v", err)
+	}
+
+	f, err := store.Open(ctx, "institution/1/a.pdf")
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if string(content) != "second" {
+		t.Fatalf("Open() content = %q, want %q", content, "second")
+	}
+
+	if err = store.Delete(ctx, "institution/1/a.pdf"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if err = store.Delete(ctx, "institution/1/a.pdf"); err != nil {
+		t.Fatalf("Delete(missing) = %v", err)
+	}
+	if _, err = store.Open(ctx, "institution/1/a.pdf"); err != ErrNotFound {
+		t.Fatalf("Open(deleted) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalRejectsEscapingKeys(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() = %v", err)
+	}
+
+	for _, key := range []string{"", "../outside", "a/../../outside", "/absolute", "a//b", "a/"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x")); err == nil {
+			t.Fatalf("Put(%q) = nil error, want an error", key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package storage keeps uploaded files under slash-separated keys chosen by the
+// caller. Local serves a directory on disk; object storage plugs in by
+// implementing Storage, and Presigner when it can hand out download URLs itself.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	DriverLocal = "local"
+)
+
+var ErrNotFound = errors.New("storage: file not found")
+
+type Storage interface {
+	// Put stores the content of r under key, replacing what was there.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the content stored under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by stores clients can download from directly, such
+// as object storage with presigned GET URLs. Files of a store without it are
+// streamed through the API instead.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, expires time.Time) (string, error)
+}
+
+// New returns the store named by driver.
+func New(driver, localPath string) (Storage, error) {
+	switch driver {
+	case "", DriverLocal:
+		return NewLocal(localPath)
+	default:
+		return nil, fmt.Errorf("storage: unknown driver %q", driver)
+	}
+}
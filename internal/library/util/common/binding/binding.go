@@ -10,6 +10,7 @@ import (
 
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	"github.com/faisalhardin/medilink/internal/library/util/validation"
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	customtime "github.com/faisalhardin/medilink/pkg/type/time"
 	"github.com/go-playground/locales/en"
 
@@ -36,6 +37,7 @@ const (
 func init() {
 
 	schemaDecoder.RegisterConverter(customtime.Time{}, customtime.TimeConverter)
+	schemaDecoder.RegisterConverter(money.Money{}, money.MoneyConverter)
 
 	validatorJSON = validation.NewValidation()
 	validatorURL = validation.NewValidation()
@@ -0,0 +1,34 @@
+package signedurl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignerVerify(t *testing.T) {
+	t.Parallel()
+
+	signer := New("secret")
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	expires := now.Add(15 * time.Minute)
+	signature := signer.Sign("attachment/1/original", expires)
+
+	if err := signer.Verify("attachment/1/original", expires, signature, now); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+	if err := signer.Verify("attachment/2/original", expires, signature, now); err != ErrInvalidSignature {
+		t.Fatalf("Verify(other resource) = %v, want ErrInvalidSignature", err)
+	}
+	if err := signer.Verify("attachment/1/original", expires.Add(time.Hour), signature, now); err != ErrInvalidSignature {
+		t.Fatalf("Verify(extended expiry) = %v, want ErrInvalidSignature", err)
+	}
+	if err := New("other").Verify("attachment/1/original", expires, signature, now); err != ErrInvalidSignature {
+		t.Fatalf("Verify(other key) = %v, want ErrInvalidSignature", err)
+	}
+	if err := signer.Verify("attachment/1/original", expires, "not-hex", now); err != ErrInvalidSignature {
+		t.Fatalf("Verify(malformed) = %v, want ErrInvalidSignature", err)
+	}
+	if err := signer.Verify("attachment/1/original", expires, signature, expires.Add(time.Second)); err != ErrExpired {
+		t.Fatalf("Verify(after expiry) = %v, want ErrExpired", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	"github.com/go-playground/locales"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
@@ -19,6 +20,7 @@ type Validator struct {
 func NewValidation(options ...validator.Option) *Validator {
 	validate := validator.New(options...)
 	registerNullV8Types(validate)
+	registerMoneyTypes(validate)
 	return &Validator{Validate: validate}
 }
 
@@ -64,6 +66,25 @@ func registerNullV8Types(v *validator.Validate) {
 	v.RegisterCustomTypeFunc(fn, null.Bool{})
 }
 
+// registerMoneyTypes maps money amounts to float64 so tags like gte=0 apply.
+func registerMoneyTypes(v *validator.Validate) {
+	fn := func(field reflect.Value) interface{} {
+		switch val := field.Interface().(type) {
+		case money.Money:
+			return val.Float64()
+		case money.NullMoney:
+			if !val.Valid {
+				return float64(0)
+			}
+			return val.Money.Float64()
+		default:
+			return nil
+		}
+	}
+	v.RegisterCustomTypeFunc(fn, money.Money{})
+	v.RegisterCustomTypeFunc(fn, money.NullMoney{})
+}
+
 func (v *Validator) SetTranslator(translator ut.Translator) {
 	v.Translator = translator
 	entranslations.RegisterDefaultTranslations(v.Validate, v.Translator)
@@ -0,0 +1,103 @@
+package attachment
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	attachmentrepo "github.com/faisalhardin/medilink/internal/entity/repo/attachment"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix        = "AttachmentDB."
+	WrapMsgInsertAttachment = WrapErrMsgPrefix + "InsertAttachment"
+	WrapMsgGetAttachment    = WrapErrMsgPrefix + "GetAttachment"
+	WrapMsgListAttachments  = WrapErrMsgPrefix + "ListAttachments"
+	WrapMsgDeleteAttachment = WrapErrMsgPrefix + "DeleteAttachment"
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewAttachmentDB returns an AttachmentDB implementation bound to the xorm connection.
+func NewAttachmentDB(db *xormlib.DBConnect) attachmentrepo.AttachmentDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) InsertAttachment(ctx context.Context, attachment *model.TrxPatientAttachment) error {
+	_, err := c.writeSession(ctx).Insert(attachment)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertAttachment)
+	}
+	return nil
+}
+
+func (c *Conn) GetAttachment(ctx context.Context, institutionID, attachmentID int64) (model.TrxPatientAttachment, bool, error) {
+	attachment := model.TrxPatientAttachment{}
+	found, err := c.readSession(ctx).
+		Where("id = ?", attachmentID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&attachment)
+	if err != nil {
+		return attachment, false, errors.Wrap(err, WrapMsgGetAttachment)
+	}
+	return attachment, found, nil
+}
+
+func (c *Conn) ListAttachments(ctx context.Context, institutionID, patientID int64, params model.ListAttachmentParams) ([]model.TrxPatientAttachment, error) {
+	session := c.readSession(ctx).
+		Where("id_mst_institution = ?", institutionID).
+		And("id_mst_patient = ?", patientID)
+	if params.VisitID > 0 {
+		session.And("id_trx_patient_visit = ?", params.VisitID)
+	}
+	if params.ToothID != "" {
+		session.And("tooth_id = ?", params.ToothID)
+	}
+	if params.Category != "" {
+		session.And("category = ?", params.Category)
+	}
+
+	attachments := []model.TrxPatientAttachment{}
+	err := session.Desc("create_time", "id").Find(&attachments)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListAttachments)
+	}
+	return attachments, nil
+}
+
+func (c *Conn) DeleteAttachment(ctx context.Context, institutionID, attachmentID int64) error {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", attachmentID).
+		And("id_mst_institution = ?", institutionID).
+		Delete(&model.TrxPatientAttachment{})
+	if err != nil {
+		return errors.Wrap(err, WrapMsgDeleteAttachment)
+	}
+	if affected == 0 {
+		return errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgDeleteAttachment)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package billing
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	billingrepo "github.com/faisalhardin/medilink/internal/entity/repo/billing"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix             = "BillingDB."
+	WrapMsgLockPatientVisit      = WrapErrMsgPrefix + "LockPatientVisit"
+	WrapMsgInsertVisitPayment    = WrapErrMsgPrefix + "InsertVisitPayment"
+	WrapMsgListVisitPayments     = WrapErrMsgPrefix + "ListVisitPayments"
+	WrapMsgInsertVisitRefund     = WrapErrMsgPrefix + "InsertVisitRefund"
+	WrapMsgListVisitRefunds      = WrapErrMsgPrefix + "ListVisitRefunds"
+	WrapMsgSumRefundedVisitLines = WrapErrMsgPrefix + "SumRefundedVisitLines"
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewBillingDB returns a BillingDB implementation bound to the xorm connection.
+func NewBillingDB(db *xormlib.DBConnect) billingrepo.BillingDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) LockPatientVisit(ctx context.Context, institutionID, visitID int64) (bool, error) {
+	const sql = `
+		SELECT id
+		FROM mdl_trx_patient_visit
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND delete_time IS NULL
+		FOR UPDATE
+	`
+
+	var ids []int64
+	err := c.writeSession(ctx).SQL(sql, visitID, institutionID).Find(&ids)
+	if err != nil {
+		return false, errors.Wrap(err, WrapMsgLockPatientVisit)
+	}
+	return len(ids) > 0, nil
+}
+
+func (c *Conn) InsertVisitPayment(ctx context.Context, payment *model.TrxVisitPayment) error {
+	_, err := c.writeSession(ctx).
+		Table(model.TrxVisitPaymentTableName).
+		InsertOne(payment)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertVisitPayment)
+	}
+	return nil
+}
+
+func (c *Conn) ListVisitPayments(ctx context.Context, institutionID, visitID int64) ([]model.TrxVisitPayment, error) {
+	payments := []model.TrxVisitPayment{}
+	err := c.readSession(ctx).
+		Table(model.TrxVisitPaymentTableName).
+		Where("id_mst_institution = ?", institutionID).
+		And("id_trx_patient_visit = ?", visitID).
+		OrderBy("id ASC").
+		Find(&payments)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListVisitPayments)
+	}
+	return payments, nil
+}
+
+func (c *Conn) InsertVisitRefund(ctx context.Context, refund *model.TrxVisitRefund) error {
+	session := c.writeSession(ctx)
+
+	_, err := session.
+		Table(model.TrxVisitRefundTableName).
+		InsertOne(refund)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertVisitRefund)
+	}
+
+	for i := range refund.Lines {
+		refund.Lines[i].IDTrxVisitRefund = refund.ID
+		_, err = session.
+			Table(model.DtlVisitRefundLineTableName).
+			InsertOne(&refund.Lines[i])
+		if err != nil {
+			return errors.Wrap(err, WrapMsgInsertVisitRefund)
+		}
+	}
+	return nil
+}
+
+func (c *Conn) ListVisitRefunds(ctx context.Context, institutionID, visitID int64) ([]model.TrxVisitRefund, error) {
+	session := c.readSession(ctx)
+
+	refunds := []model.TrxVisitRefund{}
+	err := session.
+		Table(model.TrxVisitRefundTableName).
+		Where("id_mst_institution = ?", institutionID).
+		And("id_trx_patient_visit = ?", visitID).
+		OrderBy("id ASC").
+		Find(&refunds)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListVisitRefunds)
+	}
+	if len(refunds) == 0 {
+		return refunds, nil
+	}
+
+	refundIDs := make([]int64, 0, len(refunds))
+	for _, refund := range refunds {
+		refundIDs = append(refundIDs, refund.ID)
+	}
+
+	lines := []model.DtlVisitRefundLine{}
+	err = session.
+		Table(model.DtlVisitRefundLineTableName).
+		Where("id_trx_visit_refund = any(?)", pq.Array(refundIDs)).
+		OrderBy("id ASC").
+		Find(&lines)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListVisitRefunds)
+	}
+
+	linesByRefund := make(map[int64][]model.DtlVisitRefundLine, len(refunds))
+	for _, line := range lines {
+		linesByRefund[line.IDTrxVisitRefund] = append(linesByRefund[line.IDTrxVisitRefund], line)
+	}
+	for i := range refunds {
+		refunds[i].Lines = linesByRefund[refunds[i].ID]
+		if refunds[i].Lines == nil {
+			refunds[i].Lines = []model.DtlVisitRefundLine{}
+		}
+	}
+	return refunds, nil
+}
+
+func (c *Conn) SumRefundedVisitLines(ctx context.Context, institutionID, visitID int64) ([]model.RefundedLineTotals, error) {
+	const sql = `
+		SELECT
+			id_trx_visit_product,
+			COALESCE(SUM(quantity), 0)::int AS quantity,
+			COALESCE(SUM(revenue), 0) AS revenue,
+			COALESCE(SUM(tax_amount), 0) AS tax_amount,
+			COALESCE(SUM(service_charge), 0) AS service_charge,
+			COALESCE(SUM(amount), 0) AS amount
+		FROM mdl_dtl_visit_refund_line
+		WHERE id_mst_institution = ?
+		  AND id_trx_patient_visit = ?
+		GROUP BY id_trx_visit_product
+	`
+
+	totals := []model.RefundedLineTotals{}
+	err := c.readSession(ctx).SQL(sql, institutionID, visitID).Find(&totals)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgSumRefundedVisitLines)
+	}
+	return totals, nil
+}
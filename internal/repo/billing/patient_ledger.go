@@ -0,0 +1,131 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgLockPatient            = WrapErrMsgPrefix + "LockPatient"
+	WrapMsgInsertLedgerEntries    = WrapErrMsgPrefix + "InsertLedgerEntries"
+	WrapMsgSumLedgerByType        = WrapErrMsgPrefix + "SumLedgerByType"
+	WrapMsgSumVisitCharges        = WrapErrMsgPrefix + "SumVisitCharges"
+	WrapMsgListLedgerEntries      = WrapErrMsgPrefix + "ListLedgerEntries"
+	WrapMsgGetLedgerBalanceBefore = WrapErrMsgPrefix + "GetLedgerBalanceBefore"
+)
+
+func (c *Conn) LockPatient(ctx context.Context, institutionID, patientID int64) (bool, error) {
+	const sql = `
+		SELECT id
+		FROM mdl_mst_patient_institution
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND delete_time IS NULL
+		FOR UPDATE
+	`
+
+	var ids []int64
+	err := c.writeSession(ctx).SQL(sql, patientID, institutionID).Find(&ids)
+	if err != nil {
+		return false, errors.Wrap(err, WrapMsgLockPatient)
+	}
+	return len(ids) > 0, nil
+}
+
+func (c *Conn) InsertLedgerEntries(ctx context.Context, entries []model.TrxPatientLedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	_, err := c.writeSession(ctx).
+		Table(model.TrxPatientLedgerEntryTableName).
+		Insert(&entries)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertLedgerEntries)
+	}
+	return nil
+}
+
+func (c *Conn) SumLedgerByType(ctx context.Context, institutionID, patientID int64) ([]model.PatientLedgerTypeTotal, error) {
+	const sql = `
+		SELECT entry_type, COALESCE(SUM(amount), 0) AS amount
+		FROM mdl_trx_patient_ledger_entry
+		WHERE id_mst_institution = ?
+		  AND id_mst_patient = ?
+		GROUP BY entry_type
+	`
+
+	totals := []model.PatientLedgerTypeTotal{}
+	err := c.readSession(ctx).SQL(sql, institutionID, patientID).Find(&totals)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgSumLedgerByType)
+	}
+	return totals, nil
+}
+
+func (c *Conn) SumVisitCharges(ctx context.Context, institutionID, visitID int64) (money.Money, error) {
+	const sql = `
+		SELECT COALESCE(-SUM(amount), 0) AS amount
+		FROM mdl_trx_patient_ledger_entry
+		WHERE id_mst_institution = ?
+		  AND id_trx_patient_visit = ?
+		  AND entry_type = 'charge'
+	`
+
+	var totals []model.PatientLedgerTypeTotal
+	err := c.readSession(ctx).SQL(sql, institutionID, visitID).Find(&totals)
+	if err != nil {
+		return money.Zero, errors.Wrap(err, WrapMsgSumVisitCharges)
+	}
+	if len(totals) == 0 {
+		return money.Zero, nil
+	}
+	return totals[0].Amount, nil
+}
+
+func (c *Conn) ListLedgerEntries(ctx context.Context, params model.PatientAccountStatementParams) ([]model.TrxPatientLedgerEntry, error) {
+	session := c.readSession(ctx).
+		Table(model.TrxPatientLedgerEntryTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution).
+		And("id_mst_patient = ?", params.IDMstPatient)
+
+	if !params.FromTime.IsZero() {
+		session.And("create_time >= ?", params.FromTime.UTC())
+	}
+	if !params.ToTime.IsZero() {
+		session.And("create_time <= ?", params.ToTime.UTC())
+	}
+
+	entries := []model.TrxPatientLedgerEntry{}
+	err := session.
+		OrderBy("create_time ASC, id ASC").
+		Find(&entries)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListLedgerEntries)
+	}
+	return entries, nil
+}
+
+func (c *Conn) GetLedgerBalanceBefore(ctx context.Context, institutionID, patientID int64, before time.Time) (money.Money, error) {
+	const sql = `
+		SELECT COALESCE(SUM(amount), 0) AS amount
+		FROM mdl_trx_patient_ledger_entry
+		WHERE id_mst_institution = ?
+		  AND id_mst_patient = ?
+		  AND create_time < ?
+	`
+
+	var totals []model.PatientLedgerTypeTotal
+	err := c.readSession(ctx).SQL(sql, institutionID, patientID, before.UTC()).Find(&totals)
+	if err != nil {
+		return money.Zero, errors.Wrap(err, WrapMsgGetLedgerBalanceBefore)
+	}
+	if len(totals) == 0 {
+		return money.Zero, nil
+	}
+	return totals[0].Amount, nil
+}
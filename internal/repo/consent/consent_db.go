@@ -0,0 +1,173 @@
+package consent
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	consentrepo "github.com/faisalhardin/medilink/internal/entity/repo/consent"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix                     = "ConsentDB."
+	WrapMsgListConsentTemplates          = WrapErrMsgPrefix + "ListConsentTemplates"
+	WrapMsgGetConsentTemplate            = WrapErrMsgPrefix + "GetConsentTemplate"
+	WrapMsgGetActiveConsentTemplate      = WrapErrMsgPrefix + "GetActiveConsentTemplate"
+	WrapMsgInsertConsentTemplate         = WrapErrMsgPrefix + "InsertConsentTemplate"
+	WrapMsgRetireConsentTemplate         = WrapErrMsgPrefix + "RetireConsentTemplate"
+	WrapMsgListRequiredConsentCategories = WrapErrMsgPrefix + "ListRequiredConsentCategories"
+	WrapMsgInsertConsent                 = WrapErrMsgPrefix + "InsertConsent"
+	WrapMsgListVisitConsents             = WrapErrMsgPrefix + "ListVisitConsents"
+	WrapMsgGetConsentSignature           = WrapErrMsgPrefix + "GetConsentSignature"
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewConsentDB returns a ConsentDB implementation bound to the xorm connection.
+func NewConsentDB(db *xormlib.DBConnect) consentrepo.ConsentDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) ListConsentTemplates(ctx context.Context, institutionID int64) ([]model.MstConsentTemplate, error) {
+	templates := []model.MstConsentTemplate{}
+	err := c.readSession(ctx).
+		Where("id_mst_institution = ?", institutionID).
+		Asc("procedure_category").
+		Find(&templates)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListConsentTemplates)
+	}
+	return templates, nil
+}
+
+func (c *Conn) GetConsentTemplate(ctx context.Context, institutionID, templateID int64) (model.MstConsentTemplate, bool, error) {
+	template := model.MstConsentTemplate{}
+	found, err := c.readSession(ctx).
+		Where("id = ?", templateID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&template)
+	if err != nil {
+		return template, false, errors.Wrap(err, WrapMsgGetConsentTemplate)
+	}
+	return template, found, nil
+}
+
+func (c *Conn) GetActiveConsentTemplate(ctx context.Context, institutionID int64, category string) (model.MstConsentTemplate, bool, error) {
+	sql := `
+		SELECT *
+		FROM mdl_mst_consent_template
+		WHERE id_mst_institution = ?
+		  AND procedure_category = ?
+		  AND delete_time IS NULL
+	`
+	if xormlib.GetDBSession(ctx) != nil {
+		sql += " FOR UPDATE"
+	}
+
+	template := model.MstConsentTemplate{}
+	found, err := c.readSession(ctx).SQL(sql, institutionID, category).Get(&template)
+	if err != nil {
+		return template, false, errors.Wrap(err, WrapMsgGetActiveConsentTemplate)
+	}
+	return template, found, nil
+}
+
+func (c *Conn) InsertConsentTemplate(ctx context.Context, template *model.MstConsentTemplate) error {
+	_, err := c.writeSession(ctx).Insert(template)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertConsentTemplate)
+	}
+	return nil
+}
+
+func (c *Conn) RetireConsentTemplate(ctx context.Context, institutionID, templateID int64) error {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", templateID).
+		And("id_mst_institution = ?", institutionID).
+		Delete(&model.MstConsentTemplate{})
+	if err != nil {
+		return errors.Wrap(err, WrapMsgRetireConsentTemplate)
+	}
+	if affected == 0 {
+		return errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgRetireConsentTemplate)
+	}
+	return nil
+}
+
+func (c *Conn) ListRequiredConsentCategories(ctx context.Context, institutionID int64) ([]string, error) {
+	const sql = `
+		SELECT procedure_category
+		FROM mdl_mst_consent_template
+		WHERE id_mst_institution = ?
+		  AND is_required
+		  AND delete_time IS NULL
+	`
+
+	categories := []string{}
+	err := c.readSession(ctx).SQL(sql, institutionID).Find(&categories)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListRequiredConsentCategories)
+	}
+	return categories, nil
+}
+
+func (c *Conn) InsertConsent(ctx context.Context, consent *model.TrxConsent) error {
+	_, err := c.writeSession(ctx).Insert(consent)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertConsent)
+	}
+	return nil
+}
+
+func (c *Conn) ListVisitConsents(ctx context.Context, institutionID, visitID int64) ([]model.TrxConsent, error) {
+	consents := []model.TrxConsent{}
+	err := c.readSession(ctx).
+		Omit("signature_image").
+		Where("id_mst_institution = ?", institutionID).
+		And("id_trx_patient_visit = ?", visitID).
+		Asc("signed_at", "id").
+		Find(&consents)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListVisitConsents)
+	}
+	return consents, nil
+}
+
+func (c *Conn) GetConsentSignature(ctx context.Context, institutionID, consentID int64) (model.ConsentSignature, bool, error) {
+	const sql = `
+		SELECT signature_content_type, signature_image
+		FROM mdl_trx_consent
+		WHERE id = ?
+		  AND id_mst_institution = ?
+	`
+
+	signature := model.ConsentSignature{}
+	found, err := c.readSession(ctx).SQL(sql, consentID, institutionID).Get(&signature)
+	if err != nil {
+		return signature, false, errors.Wrap(err, WrapMsgGetConsentSignature)
+	}
+	return signature, found, nil
+}
@@ -0,0 +1,229 @@
+package discount
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	discountrepo "github.com/faisalhardin/medilink/internal/entity/repo/discount"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix                 = "DiscountDB."
+	WrapMsgListDiscountPolicies      = WrapErrMsgPrefix + "ListDiscountPolicies"
+	WrapMsgReplaceDiscountPolicies   = WrapErrMsgPrefix + "ReplaceDiscountPolicies"
+	WrapMsgInsertDiscountApproval    = WrapErrMsgPrefix + "InsertDiscountApproval"
+	WrapMsgGetDiscountApprovalByID   = WrapErrMsgPrefix + "GetDiscountApprovalByID"
+	WrapMsgLockDiscountApproval      = WrapErrMsgPrefix + "LockDiscountApproval"
+	WrapMsgUpdateDiscountApproval    = WrapErrMsgPrefix + "UpdateDiscountApproval"
+	WrapMsgListDiscountApprovals     = WrapErrMsgPrefix + "ListDiscountApprovals"
+	WrapMsgInsertPriceOverrideAudits = WrapErrMsgPrefix + "InsertPriceOverrideAudits"
+	WrapMsgListPriceOverrideAudits   = WrapErrMsgPrefix + "ListPriceOverrideAudits"
+
+	defaultLimit = 30
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewDiscountDB returns a DiscountDB implementation bound to the xorm connection.
+func NewDiscountDB(db *xormlib.DBConnect) discountrepo.DiscountDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) ListDiscountPolicies(ctx context.Context, institutionID int64) ([]model.MstDiscountPolicy, error) {
+	policies := []model.MstDiscountPolicy{}
+	err := c.readSession(ctx).
+		Table(model.MstDiscountPolicyTableName).
+		Where("id_mst_institution = ?", institutionID).
+		OrderBy("role_name ASC").
+		Find(&policies)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListDiscountPolicies)
+	}
+	return policies, nil
+}
+
+func (c *Conn) ReplaceDiscountPolicies(ctx context.Context, institutionID int64, policies []model.MstDiscountPolicy) error {
+	const sql = `
+		UPDATE mdl_mst_discount_policy
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_mst_institution = ?
+		  AND delete_time IS NULL
+	`
+
+	session := c.writeSession(ctx)
+	if _, err := session.Exec(sql, institutionID); err != nil {
+		return errors.Wrap(err, WrapMsgReplaceDiscountPolicies)
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+
+	_, err := session.
+		Table(model.MstDiscountPolicyTableName).
+		Insert(&policies)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgReplaceDiscountPolicies)
+	}
+	return nil
+}
+
+func (c *Conn) InsertDiscountApproval(ctx context.Context, approval *model.TrxDiscountApproval) error {
+	_, err := c.writeSession(ctx).
+		Table(model.TrxDiscountApprovalTableName).
+		InsertOne(approval)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertDiscountApproval)
+	}
+	return nil
+}
+
+func (c *Conn) GetDiscountApprovalByID(ctx context.Context, institutionID, approvalID int64) (*model.TrxDiscountApproval, error) {
+	var approval model.TrxDiscountApproval
+	found, err := c.readSession(ctx).
+		Table(model.TrxDiscountApprovalTableName).
+		Where("id = ?", approvalID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&approval)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetDiscountApprovalByID)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &approval, nil
+}
+
+func (c *Conn) LockDiscountApproval(ctx context.Context, institutionID, approvalID int64) (*model.TrxDiscountApproval, error) {
+	const sql = `
+		SELECT *
+		FROM mdl_trx_discount_approval
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		FOR UPDATE
+	`
+
+	var approvals []model.TrxDiscountApproval
+	err := c.writeSession(ctx).SQL(sql, approvalID, institutionID).Find(&approvals)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgLockDiscountApproval)
+	}
+	if len(approvals) == 0 {
+		return nil, nil
+	}
+	return &approvals[0], nil
+}
+
+func (c *Conn) UpdateDiscountApproval(ctx context.Context, approval *model.TrxDiscountApproval) error {
+	const sql = `
+		UPDATE mdl_trx_discount_approval
+		SET status        = ?,
+		    decided_by    = ?,
+		    decided_at    = ?,
+		    decision_note = ?,
+		    update_time   = NOW()
+		WHERE id = ?
+		  AND id_mst_institution = ?
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql,
+		approval.Status,
+		approval.DecidedBy,
+		approval.DecidedAt,
+		approval.DecisionNote,
+		approval.ID,
+		approval.IDMstInstitution,
+	)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgUpdateDiscountApproval)
+	}
+	return nil
+}
+
+func (c *Conn) ListDiscountApprovals(ctx context.Context, params model.ListDiscountApprovalParams) ([]model.TrxDiscountApproval, error) {
+	session := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxDiscountApprovalTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution)
+
+	if len(params.Status) > 0 {
+		session.And("status = ?", params.Status)
+	}
+	if params.IDTrxPatientVisit > 0 {
+		session.And("id_trx_patient_visit = ?", params.IDTrxPatientVisit)
+	}
+	if !params.FromTime.IsZero() {
+		session.And("create_time >= ?", params.FromTime.UTC())
+	}
+	if !params.ToTime.IsZero() {
+		session.And("create_time <= ?", params.ToTime.UTC())
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	offset := params.Offset
+	if params.Page > 0 {
+		offset = limit * (params.Page - 1)
+	}
+
+	approvals := []model.TrxDiscountApproval{}
+	err := session.
+		OrderBy("id DESC").
+		Limit(limit, offset).
+		Find(&approvals)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListDiscountApprovals)
+	}
+	return approvals, nil
+}
+
+func (c *Conn) InsertPriceOverrideAudits(ctx context.Context, audits []model.TrxPriceOverrideAudit) error {
+	if len(audits) == 0 {
+		return nil
+	}
+
+	_, err := c.writeSession(ctx).
+		Table(model.TrxPriceOverrideAuditTableName).
+		Insert(&audits)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertPriceOverrideAudits)
+	}
+	return nil
+}
+
+func (c *Conn) ListPriceOverrideAudits(ctx context.Context, params model.ListPriceOverrideAuditParams) ([]model.TrxPriceOverrideAudit, error) {
+	audits := []model.TrxPriceOverrideAudit{}
+	err := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxPriceOverrideAuditTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution).
+		And("id_trx_patient_visit = ?", params.IDTrxPatientVisit).
+		OrderBy("id ASC").
+		Find(&audits)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListPriceOverrideAudits)
+	}
+	return audits, nil
+}
@@ -0,0 +1,205 @@
+package doctorfee
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	doctorfeerepo "github.com/faisalhardin/medilink/internal/entity/repo/doctorfee"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix               = "DoctorFeeDB."
+	WrapMsgListDoctorFeeRules      = WrapErrMsgPrefix + "ListDoctorFeeRules"
+	WrapMsgReplaceDoctorFeeRules   = WrapErrMsgPrefix + "ReplaceDoctorFeeRules"
+	WrapMsgListVisitTotals         = WrapErrMsgPrefix + "ListVisitTotals"
+	WrapMsgListPaidVisitProcedures = WrapErrMsgPrefix + "ListPaidVisitProcedures"
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewDoctorFeeDB returns a DoctorFeeDB implementation bound to the xorm connection.
+func NewDoctorFeeDB(db *xormlib.DBConnect) doctorfeerepo.DoctorFeeDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) ListDoctorFeeRules(ctx context.Context, institutionID int64) ([]model.MstDoctorFeeRule, error) {
+	rules := []model.MstDoctorFeeRule{}
+	err := c.readSession(ctx).
+		Table(model.MstDoctorFeeRuleTableName).
+		Where("id_mst_institution = ?", institutionID).
+		OrderBy("doctor_id ASC NULLS FIRST, id_trx_institution_product ASC NULLS FIRST, procedure_category ASC NULLS FIRST").
+		Find(&rules)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListDoctorFeeRules)
+	}
+	return rules, nil
+}
+
+func (c *Conn) ReplaceDoctorFeeRules(ctx context.Context, institutionID int64, rules []model.MstDoctorFeeRule) error {
+	const sql = `
+		UPDATE mdl_mst_doctor_fee_rule
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_mst_institution = ?
+		  AND delete_time IS NULL
+	`
+
+	session := c.writeSession(ctx)
+	if _, err := session.Exec(sql, institutionID); err != nil {
+		return errors.Wrap(err, WrapMsgReplaceDoctorFeeRules)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	_, err := session.
+		Table(model.MstDoctorFeeRuleTableName).
+		Insert(&rules)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgReplaceDoctorFeeRules)
+	}
+	return nil
+}
+
+// ListVisitTotals returns the invoice total of each visit created in the
+// period, with what was paid on it; a visit without payments has paid zero.
+func (c *Conn) ListVisitTotals(ctx context.Context, query model.DoctorFeeReportQuery) ([]model.DoctorFeeVisitTotals, error) {
+	const sql = `
+		SELECT
+			v.id AS id_trx_patient_visit,
+			v.id_mst_patient_payer,
+			v.payer_type,
+			v.payer_coverage_rate,
+			v.payer_coverage_limit,
+			inv.total AS invoice_total,
+			COALESCE(pay.total, 0) AS total_paid
+		FROM mdl_trx_patient_visit v
+		JOIN (
+			SELECT id_trx_patient_visit, SUM(tax_base + tax_amount + service_charge) AS total
+			FROM mdl_trx_visit_product
+			WHERE id_mst_institution = ?
+			  AND delete_time IS NULL
+			GROUP BY id_trx_patient_visit
+		) inv ON inv.id_trx_patient_visit = v.id
+		LEFT JOIN (
+			SELECT id_trx_patient_visit, SUM(amount) AS total
+			FROM mdl_trx_visit_payment
+			WHERE id_mst_institution = ?
+			GROUP BY id_trx_patient_visit
+		) pay ON pay.id_trx_patient_visit = v.id
+		WHERE v.id_mst_institution = ?
+		  AND v.create_time >= ?
+		  AND v.create_time <= ?
+		  AND v.delete_time IS NULL
+		ORDER BY v.id
+	`
+
+	rows := []model.DoctorFeeVisitTotals{}
+	err := c.readSession(ctx).SQL(sql,
+		query.IDMstInstitution,
+		query.IDMstInstitution,
+		query.IDMstInstitution, query.StartTime.UTC(), query.EndTime.UTC(),
+	).Find(&rows)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListVisitTotals)
+	}
+	return rows, nil
+}
+
+func (c *Conn) ListPaidVisitProcedures(ctx context.Context, query model.DoctorFeeReportQuery, visitIDs []int64) ([]model.DoctorFeeProcedureRow, error) {
+	// procedures are counted and ranked per visit product before the doctor
+	// filter, so a doctor's share does not depend on who else is reported;
+	// revenue is the tax base charged, less what refunds and credit notes
+	// returned of it
+	const sql = `
+		WITH paid AS (
+			SELECT v.id, v.create_time
+			FROM mdl_trx_patient_visit v
+			WHERE v.id_mst_institution = ?
+			  AND v.id = ANY(?)
+		), lines AS (
+			SELECT vp.id_trx_patient_visit, vp.id_trx_institution_product, SUM(vp.tax_base) AS revenue
+			FROM mdl_trx_visit_product vp
+			JOIN paid ON paid.id = vp.id_trx_patient_visit
+			WHERE vp.delete_time IS NULL
+			GROUP BY vp.id_trx_patient_visit, vp.id_trx_institution_product
+		), refunded AS (
+			SELECT rl.id_trx_patient_visit, rl.id_trx_institution_product, SUM(rl.amount - rl.tax_amount - rl.service_charge) AS revenue
+			FROM mdl_dtl_visit_refund_line rl
+			JOIN paid ON paid.id = rl.id_trx_patient_visit
+			GROUP BY rl.id_trx_patient_visit, rl.id_trx_institution_product
+		), procs AS (
+			SELECT
+				p.id,
+				p.visit_id,
+				paid.create_time AS visit_time,
+				p.doctor_id,
+				p.doctor_name,
+				p.product_id,
+				COALESCE(p.product_name, '') AS product_name,
+				COALESCE(p.category, '') AS category,
+				COUNT(*) OVER (PARTITION BY p.visit_id, p.product_id) AS procedure_count,
+				ROW_NUMBER() OVER (PARTITION BY p.visit_id, p.product_id ORDER BY p.rank, p.id) AS procedure_rank
+			FROM mdl_trx_visit_procedure p
+			JOIN paid ON paid.id = p.visit_id
+			WHERE p.institution_id = ?
+			  AND p.deleted_at IS NULL
+		)
+		SELECT
+			procs.id AS id_trx_visit_procedure,
+			procs.visit_id AS id_trx_patient_visit,
+			procs.visit_time,
+			procs.doctor_id,
+			procs.doctor_name,
+			procs.product_id,
+			procs.product_name,
+			procs.category AS procedure_category,
+			COALESCE(l.revenue, 0) AS line_revenue,
+			COALESCE(r.revenue, 0) AS refunded_revenue,
+			procs.procedure_count,
+			procs.procedure_rank
+		FROM procs
+		LEFT JOIN lines l
+			ON l.id_trx_patient_visit = procs.visit_id
+		   AND l.id_trx_institution_product = procs.product_id
+		LEFT JOIN refunded r
+			ON r.id_trx_patient_visit = procs.visit_id
+		   AND r.id_trx_institution_product = procs.product_id
+		WHERE (? = '' OR procs.doctor_id = ?)
+		ORDER BY procs.doctor_name ASC, procs.doctor_id ASC, procs.visit_time ASC, procs.id ASC
+	`
+
+	rows := []model.DoctorFeeProcedureRow{}
+	err := c.readSession(ctx).SQL(sql,
+		query.IDMstInstitution, pq.Array(visitIDs),
+		query.IDMstInstitution,
+		query.DoctorID, query.DoctorID,
+	).Find(&rows)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListPaidVisitProcedures)
+	}
+	return rows, nil
+}
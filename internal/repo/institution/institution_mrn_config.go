@@ -0,0 +1,76 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgGetInstitutionMRNConfig    = WrapErrMsgPrefix + "GetInstitutionMRNConfig"
+	WrapMsgUpsertInstitutionMRNConfig = WrapErrMsgPrefix + "UpsertInstitutionMRNConfig"
+)
+
+// GetInstitutionMRNConfig returns the institution's medical record number
+// format, or the default format when it never configured one.
+func (c *Conn) GetInstitutionMRNConfig(ctx context.Context, institutionID int64) (config model.MstInstitutionMRNConfig, err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	found, err := session.
+		Table(model.MstInstitutionMRNConfigTableName).
+		Where("id_mst_institution = ?", institutionID).
+		Get(&config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetInstitutionMRNConfig)
+		return
+	}
+	if !found {
+		config = model.DefaultMRNConfig(institutionID)
+	}
+	config.IDMstInstitution = institutionID
+
+	return
+}
+
+// UpsertInstitutionMRNConfig creates or replaces the institution's medical
+// record number format.
+func (c *Conn) UpsertInstitutionMRNConfig(ctx context.Context, config *model.MstInstitutionMRNConfig) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		INSERT INTO mdl_mst_institution_mrn_config
+			(id_mst_institution, prefix, separator, include_year, sequence_digits, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id_mst_institution) DO UPDATE
+		SET prefix = EXCLUDED.prefix,
+		    separator = EXCLUDED.separator,
+		    include_year = EXCLUDED.include_year,
+		    sequence_digits = EXCLUDED.sequence_digits,
+		    updated_by = EXCLUDED.updated_by,
+		    update_time = NOW()
+		RETURNING id, create_time, update_time
+	`
+
+	_, err = session.SQL(sql,
+		config.IDMstInstitution,
+		config.Prefix,
+		config.Separator,
+		config.IncludeYear,
+		config.SequenceDigits,
+		config.UpdatedBy,
+	).Get(config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpsertInstitutionMRNConfig)
+		return
+	}
+
+	return
+}
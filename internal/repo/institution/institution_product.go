@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/faisalhardin/medilink/internal/entity/constant/database"
 	"github.com/faisalhardin/medilink/internal/entity/model"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	"github.com/faisalhardin/medilink/internal/library/db/xorm"
@@ -18,6 +19,8 @@ const (
 	WrapMsgUpdateTrxInstitutionProduct       = WrapErrMsgPrefix + "UpdateTrxInstitutionProduct"
 	WrapMsgFindTrxInstitutionProductByParams = WrapErrMsgPrefix + "FindTrxInstitutionProductByParams"
 	WrapMsgGetProductStatistics              = WrapErrMsgPrefix + "GetProductStatistics"
+	WrapMsgIterateProductStatistics          = WrapErrMsgPrefix + "IterateProductStatistics"
+	WrapMsgFindProductCatalog                = WrapErrMsgPrefix + "FindProductCatalog"
 )
 
 func (c *Conn) InsertInstitutionProduct(ctx context.Context, product *model.TrxInstitutionProduct) (err error) {
@@ -73,6 +76,37 @@ func (c *Conn) FindTrxInstitutionProductByParams(ctx context.Context, request mo
 	return
 }
 
+// FindProductCatalog lists every product of the institution with its stock
+// unit, by name.
+func (c *Conn) FindProductCatalog(ctx context.Context, institutionID int64) (products []model.GetInstitutionProductResponse, err error) {
+	if institutionID == 0 {
+		err = commonerr.SetNewNoInstitutionError()
+		return
+	}
+
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	err = session.
+		Table(TrxInstitutionProduct).
+		Alias("mtip").
+		Join(database.SQLInner, "mdl_dtl_institution_product_stock mdips", "(mtip.id = mdips.id_trx_institution_product and mdips.delete_time is null)").
+		Where("mtip.id_mst_institution = ?", institutionID).
+		And("mtip.delete_time is null").
+		Select(`mtip.id, mtip.name, mtip.sku, mtip.id_mst_product, mtip.price,
+		mtip.is_item, mtip.is_treatment, mtip.tax_category, mdips.quantity, mdips.unit_type, mdips.avg_cost`).
+		OrderBy("lower(mtip.name) ASC, mtip.id ASC").
+		Find(&products)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindProductCatalog)
+		return
+	}
+
+	return
+}
+
 func (c *Conn) UpdateTrxInstitutionProduct(ctx context.Context, request *model.UpdateInstitutionProductRequest) (resp model.TrxInstitutionProduct, err error) {
 	session := xorm.GetDBSession(ctx)
 	if session == nil {
@@ -83,11 +117,24 @@ func (c *Conn) UpdateTrxInstitutionProduct(ctx context.Context, request *model.U
 		ID:           request.ID,
 		Name:         request.Name,
 		IDMstProduct: request.IDMstProduct,
-		Price:        request.Price,
+		Price:        request.Price.Round(),
 		IsItem:       request.IsItem.Bool,
 		IsTreatment:  request.IsTreatment.Bool,
+		TaxCategory:  request.TaxCategory.String,
 	}
 
+	// money fields are always written by xorm, so a zero price keeps the current one
+	if request.Price.IsZero() {
+		session.Omit("price")
+	}
+	switch {
+	case !request.SKU.Valid:
+		session.Omit("sku")
+	case request.SKU.String == "":
+		session.Omit("sku").SetExpr("sku", "NULL")
+	default:
+		trxProduct.SKU = request.SKU
+	}
 	if request.IsItem.Valid {
 		session.UseBool("is_item")
 	}
@@ -113,49 +160,118 @@ func (c *Conn) GetProductStatistics(ctx context.Context, query model.ProductStat
 		return
 	}
 
-	_, offsetSec := query.StartTime.Zone()
+	sql, args := productStatisticsSQL(query)
+	err = c.DB.SlaveDB.Context(ctx).SQL(sql, args...).Find(&rows)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetProductStatistics)
+		return
+	}
 
-	sql := `
-		SELECT
-			date_trunc('` + query.Granularity + `', (vp.create_time AT TIME ZONE 'UTC') + (? * interval '1 second')) AS period_start,
-			vp.id_trx_institution_product,
-			vp.name,
-			COALESCE(SUM(vp.quantity), 0)::bigint AS total_quantity,
-			COALESCE(SUM(vp.total_price), 0) AS total_revenue,
-			CASE
-				WHEN COALESCE(SUM(vp.quantity), 0) > 0
-				THEN COALESCE(SUM(vp.total_price), 0) / SUM(vp.quantity)
-				ELSE 0
-			END AS avg_unit_price
-		FROM ` + model.TrxVisitProductTableName + ` vp
-		WHERE vp.id_mst_institution = ?
-		  AND vp.create_time >= ?
-		  AND vp.create_time <= ?
-		  AND vp.delete_time IS NULL
-	`
+	return
+}
 
-	args := []interface{}{
-		offsetSec,
-		query.IDMstInstitution,
-		query.StartTime.UTC(),
-		query.EndTime.UTC(),
+// IterateProductStatistics passes the rows of GetProductStatistics to fn as
+// they are read, stopping at the first error fn returns.
+func (c *Conn) IterateProductStatistics(ctx context.Context, query model.ProductStatisticsQuery, fn func(model.ProductStatisticsRow) error) (err error) {
+	if query.IDMstInstitution == 0 {
+		return commonerr.SetNewNoInstitutionError()
 	}
 
-	if query.IDTrxInstitutionProduct > 0 {
-		sql += ` AND vp.id_trx_institution_product = ?`
-		args = append(args, query.IDTrxInstitutionProduct)
+	sql, args := productStatisticsSQL(query)
+	if err = c.iterateProductStatisticsRows(ctx, sql, args, fn); err != nil {
+		return errors.Wrap(err, WrapMsgIterateProductStatistics)
 	}
+	return
+}
 
-	sql += `
-		GROUP BY period_start, vp.id_trx_institution_product, vp.name
-		ORDER BY period_start ASC, vp.name ASC
-	`
-
-	err = c.DB.SlaveDB.Context(ctx).SQL(sql, args...).Find(&rows)
+func (c *Conn) iterateProductStatisticsRows(ctx context.Context, sql string, args []interface{}, fn func(model.ProductStatisticsRow) error) (err error) {
+	rows, err := c.DB.SlaveDB.Context(ctx).SQL(sql, args...).Rows(new(model.ProductStatisticsRow))
 	if err != nil {
-		err = errors.Wrap(err, WrapMsgGetProductStatistics)
 		return
 	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var row model.ProductStatisticsRow
+		if err = rows.Scan(&row); err != nil {
+			return
+		}
+		if err = fn(row); err != nil {
+			return
+		}
+	}
 	return
 }
+
+// productStatisticsSQL groups the visit lines and refund lines of the query
+// into its buckets. Revenue is the net amount charged, before PPN and service
+// charge: the tax base of a visit line, and what a refund line returned of it.
+// Refund lines count against the period they were issued in; returned items
+// give their cost back only when they went back to stock.
+func productStatisticsSQL(query model.ProductStatisticsQuery) (string, []interface{}) {
+	_, offsetSec := query.StartTime.Zone()
+
+	productFilter := ""
+	if query.IDTrxInstitutionProduct > 0 {
+		productFilter = ` AND id_trx_institution_product = ?`
+	}
+
+	sql := `
+		SELECT
+			date_trunc('` + query.Granularity + `', (s.create_time AT TIME ZONE 'UTC') + (? * interval '1 second')) AS period_start,
+			s.id_trx_institution_product,
+			s.name,
+			COALESCE(SUM(s.base_quantity), 0)::bigint AS total_quantity,
+			COALESCE(SUM(s.revenue), 0) AS total_revenue,
+			COALESCE(SUM(s.cost), 0) AS total_cost,
+			COALESCE(SUM(s.tax_amount), 0) AS total_tax,
+			COALESCE(SUM(s.service_charge), 0) AS total_service_charge,
+			CASE
+				WHEN COALESCE(SUM(s.base_quantity), 0) > 0
+				THEN COALESCE(SUM(s.revenue), 0) / SUM(s.base_quantity)
+				ELSE 0
+			END AS avg_unit_price
+		FROM (
+			SELECT
+				vp.create_time,
+				vp.id_trx_institution_product,
+				vp.name,
+				vp.quantity * vp.conversion_factor AS base_quantity,
+				vp.tax_base AS revenue,
+				vp.quantity * vp.unit_cost AS cost,
+				vp.tax_amount,
+				vp.service_charge
+			FROM ` + model.TrxVisitProductTableName + ` vp
+			WHERE vp.id_mst_institution = ?
+			  AND vp.create_time >= ?
+			  AND vp.create_time <= ?
+			  AND vp.delete_time IS NULL` + productFilter + `
+			UNION ALL
+			SELECT
+				rl.create_time,
+				rl.id_trx_institution_product,
+				rl.name,
+				-(rl.quantity * rl.conversion_factor) AS base_quantity,
+				-(rl.amount - rl.tax_amount - rl.service_charge) AS revenue,
+				CASE WHEN rl.restocked THEN -(rl.quantity * rl.unit_cost) ELSE 0 END AS cost,
+				-rl.tax_amount AS tax_amount,
+				-rl.service_charge AS service_charge
+			FROM ` + model.DtlVisitRefundLineTableName + ` rl
+			WHERE rl.id_mst_institution = ?
+			  AND rl.create_time >= ?
+			  AND rl.create_time <= ?` + productFilter + `
+		) s
+		GROUP BY period_start, s.id_trx_institution_product, s.name
+		ORDER BY period_start ASC, s.name ASC
+	`
+
+	args := []interface{}{offsetSec}
+	for i := 0; i < 2; i++ {
+		args = append(args, query.IDMstInstitution, query.StartTime.UTC(), query.EndTime.UTC())
+		if query.IDTrxInstitutionProduct > 0 {
+			args = append(args, query.IDTrxInstitutionProduct)
+		}
+	}
+
+	return sql, args
+}
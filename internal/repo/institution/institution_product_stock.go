@@ -92,8 +92,8 @@ func (c *Conn) FindTrxInstitutionProductJoinStockByParams(ctx context.Context, r
 
 	err = session.
 		Where("id_mst_institution = ?", request.IDMstInstitution).
-		Select(`mtip.id, mtip.name, mtip.id_mst_product, mtip.price, 
-		mtip.is_item, mtip.is_treatment, mdips.quantity, mdips.unit_type`).
+		Select(`mtip.id, mtip.name, mtip.sku, mtip.id_mst_product, mtip.price, 
+		mtip.is_item, mtip.is_treatment, mtip.tax_category, mdips.quantity, mdips.unit_type, mdips.avg_cost`).
 		OrderBy("mtip.id DESC").
 		Find(&products)
 	if err != nil {
@@ -158,7 +158,7 @@ func (c *Conn) UpdateDtlInstitutionProduct(ctx context.Context, request *model.D
 	}
 
 	_, err = session.
-		Omit("quantity").
+		Omit("quantity", "avg_cost").
 		Update(request)
 	if err != nil {
 		err = errors.Wrap(err, WrapMsgUpdateDtlInstitutionProduct)
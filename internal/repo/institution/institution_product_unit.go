@@ -0,0 +1,69 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgFindProductUnitsByProductIDs = WrapErrMsgPrefix + "FindProductUnitsByProductIDs"
+	WrapMsgReplaceProductUnits          = WrapErrMsgPrefix + "ReplaceProductUnits"
+)
+
+// FindProductUnitsByProductIDs returns the active alternative units of the given products.
+func (c *Conn) FindProductUnitsByProductIDs(ctx context.Context, productIDs []int64) (units []model.DtlInstitutionProductUnit, err error) {
+	if len(productIDs) == 0 {
+		return
+	}
+
+	err = c.DB.SlaveDB.Context(ctx).
+		Table(model.DtlInstitutionProductUnitTableName).
+		Where("id_trx_institution_product = ANY(?)", pq.Array(productIDs)).
+		OrderBy("id_trx_institution_product ASC, conversion_factor ASC").
+		Find(&units)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindProductUnitsByProductIDs)
+		return
+	}
+
+	return
+}
+
+// ReplaceProductUnits soft-deletes the product's current units and inserts the
+// given set, joining the caller's transaction when one is set on ctx.
+func (c *Conn) ReplaceProductUnits(ctx context.Context, productID int64, units []model.DtlInstitutionProductUnit) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		UPDATE mdl_dtl_institution_product_unit
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_trx_institution_product = ?
+		  AND delete_time IS NULL
+	`
+	_, err = session.Exec(sql, productID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReplaceProductUnits)
+		return
+	}
+
+	if len(units) == 0 {
+		return
+	}
+
+	_, err = session.
+		Table(model.DtlInstitutionProductUnitTableName).
+		Insert(&units)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReplaceProductUnits)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,73 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgGetInstitutionTaxConfig    = WrapErrMsgPrefix + "GetInstitutionTaxConfig"
+	WrapMsgUpsertInstitutionTaxConfig = WrapErrMsgPrefix + "UpsertInstitutionTaxConfig"
+)
+
+// GetInstitutionTaxConfig returns the institution's tax setup, reading through
+// the caller's transaction when one is set on ctx. An institution that never
+// configured tax gets the zero configuration: not PKP, no service charge.
+func (c *Conn) GetInstitutionTaxConfig(ctx context.Context, institutionID int64) (config model.MstInstitutionTaxConfig, err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	_, err = session.
+		Table(model.MstInstitutionTaxConfigTableName).
+		Where("id_mst_institution = ?", institutionID).
+		Get(&config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetInstitutionTaxConfig)
+		return
+	}
+	config.IDMstInstitution = institutionID
+
+	return
+}
+
+// UpsertInstitutionTaxConfig creates or replaces the institution's tax setup.
+func (c *Conn) UpsertInstitutionTaxConfig(ctx context.Context, config *model.MstInstitutionTaxConfig) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		INSERT INTO mdl_mst_institution_tax_config
+			(id_mst_institution, is_pkp, ppn_rate, price_includes_tax, service_charge_rate, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id_mst_institution) DO UPDATE
+		SET is_pkp = EXCLUDED.is_pkp,
+		    ppn_rate = EXCLUDED.ppn_rate,
+		    price_includes_tax = EXCLUDED.price_includes_tax,
+		    service_charge_rate = EXCLUDED.service_charge_rate,
+		    updated_by = EXCLUDED.updated_by,
+		    update_time = NOW()
+		RETURNING id, create_time, update_time
+	`
+
+	_, err = session.SQL(sql,
+		config.IDMstInstitution,
+		config.IsPKP,
+		config.PPNRate,
+		config.PriceIncludesTax,
+		config.ServiceChargeRate,
+		config.UpdatedBy,
+	).Get(config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpsertInstitutionTaxConfig)
+		return
+	}
+
+	return
+}
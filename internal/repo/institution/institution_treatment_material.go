@@ -0,0 +1,232 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgFindTreatmentMaterialsByProductIDs = WrapErrMsgPrefix + "FindTreatmentMaterialsByProductIDs"
+	WrapMsgReplaceTreatmentMaterials          = WrapErrMsgPrefix + "ReplaceTreatmentMaterials"
+	WrapMsgFindVisitTreatmentUsage            = WrapErrMsgPrefix + "FindVisitTreatmentUsage"
+	WrapMsgSumStockMovementsByReference       = WrapErrMsgPrefix + "SumStockMovementsByReference"
+	WrapMsgFindVisitTreatmentMaterials        = WrapErrMsgPrefix + "FindVisitTreatmentMaterials"
+	WrapMsgInsertVisitTreatmentMaterials      = WrapErrMsgPrefix + "InsertVisitTreatmentMaterials"
+)
+
+// FindTreatmentMaterialsByProductIDs returns the active bill of materials of the
+// given treatments, reading through the caller's transaction when one is set on ctx.
+func (c *Conn) FindTreatmentMaterialsByProductIDs(ctx context.Context, productIDs []int64) (materials []model.TreatmentMaterialRow, err error) {
+	if len(productIDs) == 0 {
+		return
+	}
+
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	const sql = `
+		SELECT mdtm.*, mtip.name, mdips.unit_type, mdips.avg_cost
+		FROM mdl_dtl_treatment_material mdtm
+		JOIN mdl_trx_institution_product mtip ON mtip.id = mdtm.id_material_product
+		LEFT JOIN mdl_dtl_institution_product_stock mdips
+		  ON mdips.id_trx_institution_product = mdtm.id_material_product
+		 AND mdips.delete_time IS NULL
+		WHERE mdtm.id_trx_institution_product = ANY(?)
+		  AND mdtm.delete_time IS NULL
+		ORDER BY mdtm.id_trx_institution_product ASC, mtip.name ASC
+	`
+
+	err = session.SQL(sql, pq.Array(productIDs)).Find(&materials)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindTreatmentMaterialsByProductIDs)
+		return
+	}
+
+	return
+}
+
+// ReplaceTreatmentMaterials soft-deletes the treatment's current materials and
+// inserts the given set, joining the caller's transaction when one is set on ctx.
+func (c *Conn) ReplaceTreatmentMaterials(ctx context.Context, productID int64, materials []model.DtlTreatmentMaterial) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		UPDATE mdl_dtl_treatment_material
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_trx_institution_product = ?
+		  AND delete_time IS NULL
+	`
+	_, err = session.Exec(sql, productID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReplaceTreatmentMaterials)
+		return
+	}
+
+	if len(materials) == 0 {
+		return
+	}
+
+	_, err = session.
+		Table(model.DtlTreatmentMaterialTableName).
+		Insert(&materials)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReplaceTreatmentMaterials)
+		return
+	}
+
+	return
+}
+
+// FindVisitTreatmentUsage locks the visit row and returns how many units of
+// each treatment the visit performed: the larger of the base quantity on the
+// visit cart and the number of procedures recorded with the treatment. It must
+// run inside the caller's transaction so concurrent edits of one visit serialise.
+func (c *Conn) FindVisitTreatmentUsage(ctx context.Context, institutionID, visitID int64) (usage []model.TreatmentUsage, err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const lockSQL = `
+		SELECT id
+		FROM mdl_trx_patient_visit
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		FOR UPDATE
+	`
+	_, err = session.Exec(lockSQL, visitID, institutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindVisitTreatmentUsage)
+		return
+	}
+
+	const sql = `
+		SELECT usage.id_trx_institution_product, MAX(usage.quantity) AS quantity
+		FROM (
+			SELECT mtvp.id_trx_institution_product,
+			       SUM(mtvp.quantity * mtvp.conversion_factor) AS quantity
+			FROM mdl_trx_visit_product mtvp
+			JOIN mdl_trx_institution_product mtip
+			  ON mtip.id = mtvp.id_trx_institution_product
+			 AND mtip.is_treatment = TRUE
+			WHERE mtvp.id_trx_patient_visit = ?
+			  AND mtvp.id_mst_institution = ?
+			  AND mtvp.delete_time IS NULL
+			GROUP BY mtvp.id_trx_institution_product
+
+			UNION ALL
+
+			SELECT mtvpr.product_id AS id_trx_institution_product,
+			       COUNT(*) AS quantity
+			FROM mdl_trx_visit_procedure mtvpr
+			WHERE mtvpr.visit_id = ?
+			  AND mtvpr.institution_id = ?
+			  AND mtvpr.product_id IS NOT NULL
+			  AND mtvpr.deleted_at IS NULL
+			GROUP BY mtvpr.product_id
+		) usage
+		GROUP BY usage.id_trx_institution_product
+	`
+
+	err = session.SQL(sql, visitID, institutionID, visitID, institutionID).Find(&usage)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindVisitTreatmentUsage)
+		return
+	}
+
+	return
+}
+
+// SumStockMovementsByReference returns the net quantity per product and stock
+// location that the given movement types have posted for one document, reading through the
+// caller's transaction when one is set on ctx.
+func (c *Conn) SumStockMovementsByReference(ctx context.Context, institutionID int64, referenceType string, referenceID int64, movementTypes []string) (balances []model.MaterialStockBalance, err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	const sql = `
+		SELECT id_trx_institution_product, COALESCE(id_mst_stock_location, 0) AS id_mst_stock_location, SUM(quantity) AS quantity
+		FROM mdl_trx_stock_movement
+		WHERE id_mst_institution = ?
+		  AND reference_type = ?
+		  AND reference_id = ?
+		  AND movement_type = ANY(?)
+		GROUP BY id_trx_institution_product, COALESCE(id_mst_stock_location, 0)
+		ORDER BY id_trx_institution_product ASC, COALESCE(id_mst_stock_location, 0) ASC
+	`
+
+	err = session.SQL(sql, institutionID, referenceType, referenceID, pq.Array(movementTypes)).Find(&balances)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSumStockMovementsByReference)
+		return
+	}
+
+	return
+}
+
+// FindVisitTreatmentMaterials returns the bills of materials recorded for the
+// treatments of a visit, the lines without a material included, joined with
+// each material's name, base unit and average cost. It reads through the
+// caller's transaction when one is set on ctx.
+func (c *Conn) FindVisitTreatmentMaterials(ctx context.Context, visitID int64) (materials []model.TreatmentMaterialRow, err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	const sql = `
+		SELECT mdvtm.id_trx_institution_product, mdvtm.id_material_product, mdvtm.quantity,
+		       COALESCE(mtip.name, '') AS name, COALESCE(mdips.unit_type, '') AS unit_type,
+		       COALESCE(mdips.avg_cost, 0) AS avg_cost
+		FROM mdl_dtl_visit_treatment_material mdvtm
+		LEFT JOIN mdl_trx_institution_product mtip ON mtip.id = mdvtm.id_material_product
+		LEFT JOIN mdl_dtl_institution_product_stock mdips
+		  ON mdips.id_trx_institution_product = mdvtm.id_material_product
+		 AND mdips.delete_time IS NULL
+		WHERE mdvtm.id_trx_patient_visit = ?
+		ORDER BY mdvtm.id_trx_institution_product ASC, mdvtm.id_material_product ASC
+	`
+
+	err = session.SQL(sql, visitID).Find(&materials)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindVisitTreatmentMaterials)
+		return
+	}
+
+	return
+}
+
+// InsertVisitTreatmentMaterials records the bills of materials of treatments a
+// visit consumes for the first time, joining the caller's transaction when one
+// is set on ctx.
+func (c *Conn) InsertVisitTreatmentMaterials(ctx context.Context, materials []model.DtlVisitTreatmentMaterial) (err error) {
+	if len(materials) == 0 {
+		return
+	}
+
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	_, err = session.
+		Table(model.DtlVisitTreatmentMaterialTableName).
+		Insert(&materials)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertVisitTreatmentMaterials)
+		return
+	}
+
+	return
+}
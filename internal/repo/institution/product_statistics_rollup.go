@@ -0,0 +1,327 @@
+package institution
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgTryLockRollup                       = WrapErrMsgPrefix + "TryLockRollup"
+	WrapMsgGetProductStatisticsSourceStart     = WrapErrMsgPrefix + "GetProductStatisticsSourceStart"
+	WrapMsgGetRollupRefreshedUntil             = WrapErrMsgPrefix + "GetRollupRefreshedUntil"
+	WrapMsgSetRollupRefreshedUntil             = WrapErrMsgPrefix + "SetRollupRefreshedUntil"
+	WrapMsgFindProductStatisticsDirtyDays      = WrapErrMsgPrefix + "FindProductStatisticsDirtyDays"
+	WrapMsgRebuildProductStatisticsRollups     = WrapErrMsgPrefix + "RebuildProductStatisticsRollups"
+	WrapMsgGetProductStatisticsFromRollups     = WrapErrMsgPrefix + "GetProductStatisticsFromRollups"
+	WrapMsgIterateProductStatisticsFromRollups = WrapErrMsgPrefix + "IterateProductStatisticsFromRollups"
+	productStatisticsRollupDateLayout          = "2006-01-02"
+	productStatisticsRollupSourceMarginInDays  = 1
+)
+
+// TryLockRollup takes the session-level advisory lock of the named rollup on a
+// connection of its own, so only one API instance refreshes it at a time and
+// no transaction stays open while it does. It reports false when another
+// session holds the lock. Once locked, call unlock to release the lock and the
+// connection.
+func (c *Conn) TryLockRollup(ctx context.Context, name string) (unlock func(), locked bool, err error) {
+	conn, err := c.DB.MasterDB.DB().Conn(ctx)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgTryLockRollup)
+		return
+	}
+
+	const lockSQL = `SELECT pg_try_advisory_lock(hashtext('mdl_agg_rollup_state:' || $1))`
+	if err = conn.QueryRowContext(ctx, lockSQL, name).Scan(&locked); err != nil || !locked {
+		conn.Close()
+		err = errors.Wrap(err, WrapMsgTryLockRollup)
+		return
+	}
+
+	unlock = func() {
+		const unlockSQL = `SELECT pg_advisory_unlock(hashtext('mdl_agg_rollup_state:' || $1))`
+		var unlocked bool
+		if err := conn.QueryRowContext(context.Background(), unlockSQL, name).Scan(&unlocked); err != nil || !unlocked {
+			// a connection that may still hold the lock must not go back to
+			// the pool; closing it ends the session and its lock
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return
+}
+
+// GetProductStatisticsSourceStart returns when the oldest visit line or refund
+// line the rollups are built from was written, or now when there is none.
+func (c *Conn) GetProductStatisticsSourceStart(ctx context.Context) (start time.Time, err error) {
+	const sql = `
+		SELECT COALESCE(LEAST(
+			(SELECT MIN(update_time) FROM mdl_trx_visit_product),
+			(SELECT MIN(create_time) FROM mdl_dtl_visit_refund_line)
+		), NOW())
+	`
+	if err = c.DB.MasterDB.DB().QueryRowContext(ctx, sql).Scan(&start); err != nil {
+		err = errors.Wrap(err, WrapMsgGetProductStatisticsSourceStart)
+		return
+	}
+	return
+}
+
+func (c *Conn) GetRollupRefreshedUntil(ctx context.Context, name string) (refreshedUntil time.Time, found bool, err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	var state model.AggRollupState
+	found, err = session.
+		Table(model.AggRollupStateTableName).
+		Where("name = ?", name).
+		Get(&state)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetRollupRefreshedUntil)
+		return
+	}
+	return state.RefreshedUntil, found, nil
+}
+
+func (c *Conn) SetRollupRefreshedUntil(ctx context.Context, name string, refreshedUntil time.Time) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		INSERT INTO mdl_agg_rollup_state (name, refreshed_until)
+		VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET refreshed_until = EXCLUDED.refreshed_until
+	`
+	if _, err = session.Exec(sql, name, refreshedUntil.UTC()); err != nil {
+		err = errors.Wrap(err, WrapMsgSetRollupRefreshedUntil)
+		return
+	}
+	return
+}
+
+// FindProductStatisticsDirtyDays returns the local days, at the given UTC
+// offset, that have visit lines changed or refund lines written from since
+// until until.
+func (c *Conn) FindProductStatisticsDirtyDays(ctx context.Context, since, until time.Time, utcOffsetSeconds int) (days []model.ProductStatisticsRollupDay, err error) {
+	const sql = `
+		SELECT DISTINCT d.id_mst_institution, d.stat_date
+		FROM (
+			SELECT
+				vp.id_mst_institution,
+				((vp.create_time AT TIME ZONE 'UTC') + (? * interval '1 second'))::date AS stat_date
+			FROM mdl_trx_visit_product vp
+			WHERE (vp.update_time >= ? AND vp.update_time < ?)
+			   OR (vp.delete_time >= ? AND vp.delete_time < ?)
+			UNION
+			SELECT
+				rl.id_mst_institution,
+				((rl.create_time AT TIME ZONE 'UTC') + (? * interval '1 second'))::date AS stat_date
+			FROM mdl_dtl_visit_refund_line rl
+			WHERE rl.create_time >= ?
+			  AND rl.create_time < ?
+		) d
+		ORDER BY d.id_mst_institution ASC, d.stat_date ASC
+	`
+
+	since, until = since.UTC(), until.UTC()
+	err = c.DB.MasterDB.Context(ctx).
+		SQL(sql, utcOffsetSeconds, since, until, since, until, utcOffsetSeconds, since, until).
+		Find(&days)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindProductStatisticsDirtyDays)
+		return
+	}
+	return
+}
+
+// RebuildProductStatisticsRollups replaces the rollups of days with the sums of
+// their visit lines less their refund lines, as GetProductStatistics counts them.
+func (c *Conn) RebuildProductStatisticsRollups(ctx context.Context, utcOffsetSeconds int, days []model.ProductStatisticsRollupDay) (err error) {
+	if len(days) == 0 {
+		return
+	}
+
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	institutionIDs := make([]int64, 0, len(days))
+	dates := make([]string, 0, len(days))
+	from, to := days[0].StatDate, days[0].StatDate
+	for _, day := range days {
+		institutionIDs = append(institutionIDs, day.IDMstInstitution)
+		dates = append(dates, day.StatDate.Format(productStatisticsRollupDateLayout))
+		if day.StatDate.Before(from) {
+			from = day.StatDate
+		}
+		if day.StatDate.After(to) {
+			to = day.StatDate
+		}
+	}
+	// the source rows of the days lie within a day of their local dates
+	sourceFrom := from.AddDate(0, 0, -productStatisticsRollupSourceMarginInDays)
+	sourceTo := to.AddDate(0, 0, 1+productStatisticsRollupSourceMarginInDays)
+
+	const deleteSQL = `
+		DELETE FROM mdl_agg_product_daily_stats a
+		USING unnest(?::bigint[], ?::date[]) AS d(id_mst_institution, stat_date)
+		WHERE a.utc_offset_seconds = ?
+		  AND a.id_mst_institution = d.id_mst_institution
+		  AND a.stat_date = d.stat_date
+	`
+	if _, err = session.Exec(deleteSQL, pq.Array(institutionIDs), pq.Array(dates), utcOffsetSeconds); err != nil {
+		err = errors.Wrap(err, WrapMsgRebuildProductStatisticsRollups)
+		return
+	}
+
+	const insertSQL = `
+		WITH days AS (
+			SELECT *
+			FROM unnest(?::bigint[], ?::date[]) AS d(id_mst_institution, stat_date)
+		), src AS (
+			SELECT
+				vp.id_mst_institution,
+				((vp.create_time AT TIME ZONE 'UTC') + (? * interval '1 second'))::date AS stat_date,
+				vp.id_trx_institution_product,
+				vp.name,
+				vp.quantity * vp.conversion_factor AS base_quantity,
+				vp.tax_base AS revenue,
+				vp.quantity * vp.unit_cost AS cost,
+				vp.tax_amount,
+				vp.service_charge
+			FROM mdl_trx_visit_product vp
+			WHERE vp.id_mst_institution = ANY(?::bigint[])
+			  AND vp.create_time >= ?
+			  AND vp.create_time < ?
+			  AND vp.delete_time IS NULL
+			UNION ALL
+			SELECT
+				rl.id_mst_institution,
+				((rl.create_time AT TIME ZONE 'UTC') + (? * interval '1 second'))::date AS stat_date,
+				rl.id_trx_institution_product,
+				rl.name,
+				-(rl.quantity * rl.conversion_factor) AS base_quantity,
+				-(rl.amount - rl.tax_amount - rl.service_charge) AS revenue,
+				CASE WHEN rl.restocked THEN -(rl.quantity * rl.unit_cost) ELSE 0 END AS cost,
+				-rl.tax_amount AS tax_amount,
+				-rl.service_charge AS service_charge
+			FROM mdl_dtl_visit_refund_line rl
+			WHERE rl.id_mst_institution = ANY(?::bigint[])
+			  AND rl.create_time >= ?
+			  AND rl.create_time < ?
+		)
+		INSERT INTO mdl_agg_product_daily_stats (
+			id_mst_institution, utc_offset_seconds, stat_date, id_trx_institution_product, name,
+			total_quantity, total_revenue, total_cost, total_tax, total_service_charge
+		)
+		SELECT
+			src.id_mst_institution,
+			?,
+			src.stat_date,
+			src.id_trx_institution_product,
+			MAX(src.name),
+			COALESCE(SUM(src.base_quantity), 0)::bigint,
+			COALESCE(SUM(src.revenue), 0),
+			COALESCE(SUM(src.cost), 0),
+			COALESCE(SUM(src.tax_amount), 0),
+			COALESCE(SUM(src.service_charge), 0)
+		FROM src
+		JOIN days
+		  ON days.id_mst_institution = src.id_mst_institution
+		 AND days.stat_date = src.stat_date
+		GROUP BY src.id_mst_institution, src.stat_date, src.id_trx_institution_product
+	`
+	_, err = session.Exec(insertSQL,
+		pq.Array(institutionIDs), pq.Array(dates),
+		utcOffsetSeconds, pq.Array(institutionIDs), sourceFrom, sourceTo,
+		utcOffsetSeconds, pq.Array(institutionIDs), sourceFrom, sourceTo,
+		utcOffsetSeconds,
+	)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgRebuildProductStatisticsRollups)
+		return
+	}
+	return
+}
+
+// GetProductStatisticsFromRollups groups the daily rollups of the local days
+// from StartTime to EndTime into month or year buckets.
+func (c *Conn) GetProductStatisticsFromRollups(ctx context.Context, query model.ProductStatisticsQuery) (rows []model.ProductStatisticsRow, err error) {
+	if query.IDMstInstitution == 0 {
+		err = commonerr.SetNewNoInstitutionError()
+		return
+	}
+
+	sql, args := productStatisticsRollupSQL(query)
+	err = c.DB.SlaveDB.Context(ctx).SQL(sql, args...).Find(&rows)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetProductStatisticsFromRollups)
+		return
+	}
+	return
+}
+
+// IterateProductStatisticsFromRollups passes the rows of
+// GetProductStatisticsFromRollups to fn as they are read.
+func (c *Conn) IterateProductStatisticsFromRollups(ctx context.Context, query model.ProductStatisticsQuery, fn func(model.ProductStatisticsRow) error) (err error) {
+	if query.IDMstInstitution == 0 {
+		return commonerr.SetNewNoInstitutionError()
+	}
+
+	sql, args := productStatisticsRollupSQL(query)
+	if err = c.iterateProductStatisticsRows(ctx, sql, args, fn); err != nil {
+		return errors.Wrap(err, WrapMsgIterateProductStatisticsFromRollups)
+	}
+	return
+}
+
+func productStatisticsRollupSQL(query model.ProductStatisticsQuery) (string, []interface{}) {
+	productFilter := ""
+	args := []interface{}{
+		query.IDMstInstitution,
+		query.UTCOffsetSeconds,
+		query.StartTime.Format(productStatisticsRollupDateLayout),
+		query.EndTime.Format(productStatisticsRollupDateLayout),
+	}
+	if query.IDTrxInstitutionProduct > 0 {
+		productFilter = ` AND id_trx_institution_product = ?`
+		args = append(args, query.IDTrxInstitutionProduct)
+	}
+
+	sql := `
+		SELECT
+			date_trunc('` + query.Granularity + `', stat_date::timestamp) AS period_start,
+			id_trx_institution_product,
+			MAX(name) AS name,
+			COALESCE(SUM(total_quantity), 0)::bigint AS total_quantity,
+			COALESCE(SUM(total_revenue), 0) AS total_revenue,
+			COALESCE(SUM(total_cost), 0) AS total_cost,
+			COALESCE(SUM(total_tax), 0) AS total_tax,
+			COALESCE(SUM(total_service_charge), 0) AS total_service_charge,
+			CASE
+				WHEN COALESCE(SUM(total_quantity), 0) > 0
+				THEN COALESCE(SUM(total_revenue), 0) / SUM(total_quantity)
+				ELSE 0
+			END AS avg_unit_price
+		FROM ` + model.AggProductDailyStatsTableName + `
+		WHERE id_mst_institution = ?
+		  AND utc_offset_seconds = ?
+		  AND stat_date >= ?::date
+		  AND stat_date <= ?::date` + productFilter + `
+		GROUP BY period_start, id_trx_institution_product
+		ORDER BY period_start ASC, name ASC
+	`
+
+	return sql, args
+}
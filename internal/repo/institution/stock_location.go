@@ -0,0 +1,334 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	xormlib "github.com/go-xorm/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgInsertStockLocation       = WrapErrMsgPrefix + "InsertStockLocation"
+	WrapMsgFindStockLocations        = WrapErrMsgPrefix + "FindStockLocations"
+	WrapMsgUpdateStockLocation       = WrapErrMsgPrefix + "UpdateStockLocation"
+	WrapMsgEnsureMainStockLocation   = WrapErrMsgPrefix + "EnsureMainStockLocation"
+	WrapMsgFindVisitStockLocation    = WrapErrMsgPrefix + "FindVisitStockLocation"
+	WrapMsgFindLocationStocks        = WrapErrMsgPrefix + "FindLocationStocks"
+	WrapMsgAdjustLocationStock       = WrapErrMsgPrefix + "AdjustLocationStock"
+	WrapMsgSaveLocationReorderLevels = WrapErrMsgPrefix + "SaveLocationReorderLevels"
+	WrapMsgInsertStockTransfer       = WrapErrMsgPrefix + "InsertStockTransfer"
+	WrapMsgFindStockTransfers        = WrapErrMsgPrefix + "FindStockTransfers"
+	WrapMsgGetStockTransfer          = WrapErrMsgPrefix + "GetStockTransfer"
+
+	defaultStockTransferLimit = 30
+)
+
+// writeSession returns the transaction session set on ctx by the usecase, or a
+// fresh master session for a single statement.
+func (c *Conn) writeSession(ctx context.Context) *xormlib.Session {
+	if session := xorm.GetDBSession(ctx); session != nil {
+		return session
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the transaction session set on ctx, so reads inside a
+// transaction see its own writes.
+func (c *Conn) readSession(ctx context.Context) *xormlib.Session {
+	if session := xorm.GetDBSession(ctx); session != nil {
+		return session
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) InsertStockLocation(ctx context.Context, location *model.MstStockLocation) (err error) {
+	_, err = c.writeSession(ctx).
+		Table(model.MstStockLocationTableName).
+		InsertOne(location)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertStockLocation)
+		return
+	}
+
+	return
+}
+
+// FindStockLocations returns the active locations of the institution, the main
+// location first, reading through the caller's transaction when one is set on ctx.
+func (c *Conn) FindStockLocations(ctx context.Context, institutionID int64) (locations []model.MstStockLocation, err error) {
+	err = c.readSession(ctx).
+		Table(model.MstStockLocationTableName).
+		Where("id_mst_institution = ?", institutionID).
+		OrderBy("is_main DESC, lower(name) ASC").
+		Find(&locations)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindStockLocations)
+		return
+	}
+
+	return
+}
+
+// UpdateStockLocation writes the name and service point of the location; an
+// invalid service point is written as NULL.
+func (c *Conn) UpdateStockLocation(ctx context.Context, location *model.MstStockLocation) (err error) {
+	_, err = c.writeSession(ctx).
+		Table(model.MstStockLocationTableName).
+		ID(location.ID).
+		Where("id_mst_institution = ?", location.IDMstInstitution).
+		Cols("name", "id_mst_service_point").
+		Update(location)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdateStockLocation)
+		return
+	}
+
+	return
+}
+
+// EnsureMainStockLocation returns the main location of the institution,
+// creating it on first use.
+func (c *Conn) EnsureMainStockLocation(ctx context.Context, institutionID int64) (location model.MstStockLocation, err error) {
+	const insertSQL = `
+		INSERT INTO mdl_mst_stock_location (id_mst_institution, name, is_main, create_time, update_time)
+		VALUES (?, ?, TRUE, NOW(), NOW())
+		ON CONFLICT (id_mst_institution) WHERE is_main AND delete_time IS NULL DO NOTHING
+	`
+	_, err = c.writeSession(ctx).Exec(insertSQL, institutionID, model.MainStockLocationName)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgEnsureMainStockLocation)
+		return
+	}
+
+	_, err = c.writeSession(ctx).
+		Table(model.MstStockLocationTableName).
+		Where("id_mst_institution = ?", institutionID).
+		And("is_main = TRUE").
+		Get(&location)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgEnsureMainStockLocation)
+		return
+	}
+
+	return
+}
+
+// FindVisitStockLocation returns the location that supplies the service point
+// of the visit. found is false when no location supplies it.
+func (c *Conn) FindVisitStockLocation(ctx context.Context, institutionID, visitID int64) (location model.MstStockLocation, found bool, err error) {
+	const sql = `
+		SELECT msl.*
+		FROM mdl_trx_patient_visit mtpv
+		JOIN mdl_mst_stock_location msl
+		  ON msl.id_mst_institution = mtpv.id_mst_institution
+		 AND msl.id_mst_service_point = mtpv.id_mst_service_point
+		 AND msl.delete_time IS NULL
+		WHERE mtpv.id = ?
+		  AND mtpv.id_mst_institution = ?
+		LIMIT 1
+	`
+	found, err = c.readSession(ctx).SQL(sql, visitID, institutionID).Get(&location)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindVisitStockLocation)
+		return
+	}
+
+	return
+}
+
+// FindLocationStocks returns the stock of item products per location, reading
+// through the caller's transaction when one is set on ctx.
+func (c *Conn) FindLocationStocks(ctx context.Context, params model.ListLocationStockParams) (rows []model.LocationStockRow, err error) {
+	sql := `
+		SELECT mdls.id_mst_stock_location,
+		       msl.name AS location_name,
+		       mdls.id_trx_institution_product,
+		       mtip.name,
+		       mtip.sku,
+		       COALESCE(mdips.unit_type, '') AS unit_type,
+		       mdls.quantity,
+		       mdls.reorder_level
+		FROM mdl_dtl_location_stock mdls
+		JOIN mdl_mst_stock_location msl
+		  ON msl.id = mdls.id_mst_stock_location
+		 AND msl.delete_time IS NULL
+		JOIN mdl_trx_institution_product mtip
+		  ON mtip.id = mdls.id_trx_institution_product
+		 AND mtip.is_item
+		 AND mtip.delete_time IS NULL
+		LEFT JOIN mdl_dtl_institution_product_stock mdips
+		  ON mdips.id_trx_institution_product = mtip.id
+		 AND mdips.delete_time IS NULL
+		WHERE msl.id_mst_institution = ?
+	`
+	args := []interface{}{params.IDMstInstitution}
+	if params.IDMstStockLocation > 0 {
+		sql += " AND mdls.id_mst_stock_location = ?"
+		args = append(args, params.IDMstStockLocation)
+	}
+	if len(params.ProductIDs) > 0 {
+		sql += " AND mdls.id_trx_institution_product = ANY(?)"
+		args = append(args, pq.Array(params.ProductIDs))
+	}
+	if params.LowStockOnly {
+		sql += " AND mdls.reorder_level > 0 AND mdls.quantity <= mdls.reorder_level"
+	}
+	sql += " ORDER BY msl.is_main DESC, lower(msl.name) ASC, lower(mtip.name) ASC"
+
+	err = c.readSession(ctx).SQL(sql, args...).Find(&rows)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindLocationStocks)
+		return
+	}
+
+	return
+}
+
+// AdjustLocationStock applies a signed quantity delta to the stock of the
+// product at the location, creating its row on first use. Only item products
+// keep stock per location; for other products it does nothing.
+func (c *Conn) AdjustLocationStock(ctx context.Context, locationID, productID, delta int64) (err error) {
+	const sql = `
+		INSERT INTO mdl_dtl_location_stock (id_mst_stock_location, id_trx_institution_product, quantity)
+		SELECT ?, mtip.id, ?
+		FROM mdl_trx_institution_product mtip
+		WHERE mtip.id = ?
+		  AND mtip.is_item
+		ON CONFLICT (id_mst_stock_location, id_trx_institution_product)
+		DO UPDATE SET quantity = mdl_dtl_location_stock.quantity + EXCLUDED.quantity,
+		              update_time = NOW()
+	`
+	_, err = c.writeSession(ctx).Exec(sql, locationID, delta, productID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAdjustLocationStock)
+		return
+	}
+
+	return
+}
+
+// SaveLocationReorderLevels sets the reorder level of products at the location,
+// joining the caller's transaction when one is set on ctx.
+func (c *Conn) SaveLocationReorderLevels(ctx context.Context, locationID int64, levels []model.ReorderLevelRequest) (err error) {
+	const sql = `
+		INSERT INTO mdl_dtl_location_stock (id_mst_stock_location, id_trx_institution_product, reorder_level)
+		VALUES (?, ?, ?)
+		ON CONFLICT (id_mst_stock_location, id_trx_institution_product)
+		DO UPDATE SET reorder_level = EXCLUDED.reorder_level,
+		              update_time = NOW()
+	`
+	for _, level := range levels {
+		_, err = c.writeSession(ctx).Exec(sql, locationID, level.IDTrxInstitutionProduct, level.ReorderLevel)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgSaveLocationReorderLevels)
+			return
+		}
+	}
+
+	return
+}
+
+// InsertStockTransfer inserts the transfer document and its lines, joining the
+// caller's transaction when one is set on ctx.
+func (c *Conn) InsertStockTransfer(ctx context.Context, transfer *model.TrxStockTransfer, items []model.DtlStockTransferItem) (err error) {
+	_, err = c.writeSession(ctx).
+		Table(model.TrxStockTransferTableName).
+		InsertOne(transfer)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertStockTransfer)
+		return
+	}
+
+	for i := range items {
+		items[i].IDTrxStockTransfer = transfer.ID
+	}
+	_, err = c.writeSession(ctx).
+		Table(model.DtlStockTransferItemTableName).
+		Insert(&items)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertStockTransfer)
+		return
+	}
+
+	return
+}
+
+// FindStockTransfers lists transfers newest first, without their lines. A
+// location selects the transfers into or out of it.
+func (c *Conn) FindStockTransfers(ctx context.Context, params model.ListStockTransferParams) (transfers []model.TrxStockTransfer, err error) {
+	session := c.DB.SlaveDB.
+		Table(model.TrxStockTransferTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution)
+
+	if params.IDMstStockLocation > 0 {
+		session.And("(from_id_mst_stock_location = ? OR to_id_mst_stock_location = ?)",
+			params.IDMstStockLocation, params.IDMstStockLocation)
+	}
+	if !params.FromTime.IsZero() {
+		session.And("create_time >= ?", params.FromTime.UTC())
+	}
+	if !params.ToTime.IsZero() {
+		session.And("create_time <= ?", params.ToTime.UTC())
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultStockTransferLimit
+	}
+	offset := params.Offset
+	if params.Page > 0 {
+		offset = limit * (params.Page - 1)
+	}
+
+	transfers = []model.TrxStockTransfer{}
+	err = session.
+		OrderBy("id DESC").
+		Limit(limit, offset).
+		Find(&transfers)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindStockTransfers)
+		return
+	}
+
+	return
+}
+
+// GetStockTransfer returns the transfer with its lines, or nil when the
+// institution has no such transfer.
+func (c *Conn) GetStockTransfer(ctx context.Context, institutionID, transferID int64) (transfer *model.TrxStockTransfer, err error) {
+	var header model.TrxStockTransfer
+	found, err := c.readSession(ctx).
+		Table(model.TrxStockTransferTableName).
+		Where("id = ?", transferID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&header)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetStockTransfer)
+		return
+	}
+	if !found {
+		return nil, nil
+	}
+
+	const sql = `
+		SELECT mdsti.*, mtip.name, COALESCE(mdips.unit_type, '') AS unit_type
+		FROM mdl_dtl_stock_transfer_item mdsti
+		JOIN mdl_trx_institution_product mtip ON mtip.id = mdsti.id_trx_institution_product
+		LEFT JOIN mdl_dtl_institution_product_stock mdips
+		  ON mdips.id_trx_institution_product = mdsti.id_trx_institution_product
+		 AND mdips.delete_time IS NULL
+		WHERE mdsti.id_trx_stock_transfer = ?
+		ORDER BY mdsti.id ASC
+	`
+	header.Items = []model.StockTransferItemRow{}
+	err = c.readSession(ctx).SQL(sql, header.ID).Find(&header.Items)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetStockTransfer)
+		return
+	}
+
+	return &header, nil
+}
@@ -0,0 +1,123 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgInsertStockMovements              = WrapErrMsgPrefix + "InsertStockMovements"
+	WrapMsgReceiveDtlInstitutionProductStock = WrapErrMsgPrefix + "ReceiveDtlInstitutionProductStock"
+	WrapMsgAdjustDtlInstitutionProductStock  = WrapErrMsgPrefix + "AdjustDtlInstitutionProductStock"
+)
+
+// InsertStockMovements appends rows to the stock ledger and moves the stock of
+// each row's location by its quantity, joining the caller's transaction when
+// one is set on ctx. Rows without a location are booked to the main location
+// of their institution.
+func (c *Conn) InsertStockMovements(ctx context.Context, movements []model.TrxStockMovement) (err error) {
+	if len(movements) == 0 {
+		return nil
+	}
+
+	mainLocations := map[int64]int64{}
+	for i := range movements {
+		movement := &movements[i]
+		if movement.IDMstStockLocation > 0 {
+			continue
+		}
+		if _, ok := mainLocations[movement.IDMstInstitution]; !ok {
+			var location model.MstStockLocation
+			location, err = c.EnsureMainStockLocation(ctx, movement.IDMstInstitution)
+			if err != nil {
+				err = errors.Wrap(err, WrapMsgInsertStockMovements)
+				return
+			}
+			mainLocations[movement.IDMstInstitution] = location.ID
+		}
+		movement.IDMstStockLocation = mainLocations[movement.IDMstInstitution]
+	}
+
+	_, err = c.writeSession(ctx).
+		Table(model.TrxStockMovementTableName).
+		Insert(&movements)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertStockMovements)
+		return
+	}
+
+	for _, movement := range movements {
+		err = c.AdjustLocationStock(ctx, movement.IDMstStockLocation, movement.IDTrxInstitutionProduct, movement.Quantity)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgInsertStockMovements)
+			return
+		}
+	}
+
+	return
+}
+
+// ReceiveDtlInstitutionProductStock adds received goods to the stock row and
+// folds their unit cost into avg_cost as a weighted average. The arithmetic runs
+// in a single UPDATE so concurrent receipts cannot interleave between read and write.
+// When the stock on hand is zero or negative the received cost becomes the new average.
+func (c *Conn) ReceiveDtlInstitutionProductStock(ctx context.Context, productID, quantity int64, unitCost money.Money) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		UPDATE mdl_dtl_institution_product_stock
+		SET avg_cost = CASE
+				WHEN quantity > 0 THEN (quantity * avg_cost + ?::numeric * ?::numeric) / (quantity + ?::numeric)
+				ELSE ?::numeric
+			END,
+			quantity = quantity + ?,
+			update_time = NOW()
+		WHERE id_trx_institution_product = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err = session.Exec(sql,
+		quantity, unitCost, quantity,
+		unitCost,
+		quantity,
+		productID,
+	)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReceiveDtlInstitutionProductStock)
+		return
+	}
+
+	return
+}
+
+// AdjustDtlInstitutionProductStock applies a signed quantity delta to the stock
+// row without touching avg_cost, joining the caller's transaction when one is set on ctx.
+func (c *Conn) AdjustDtlInstitutionProductStock(ctx context.Context, productID, delta int64) (err error) {
+	session := xorm.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.MasterDB.Context(ctx)
+	}
+
+	const sql = `
+		UPDATE mdl_dtl_institution_product_stock
+		SET quantity = quantity + ?,
+			update_time = NOW()
+		WHERE id_trx_institution_product = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err = session.Exec(sql, delta, productID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAdjustDtlInstitutionProductStock)
+		return
+	}
+
+	return
+}
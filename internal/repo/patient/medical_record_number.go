@@ -0,0 +1,128 @@
+package patient
+
+import (
+	"context"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgNextMedicalRecordNumber                = WrapErrMsgPrefix + "NextMedicalRecordNumber"
+	WrapMsgReserveMedicalRecordNumbers            = WrapErrMsgPrefix + "ReserveMedicalRecordNumbers"
+	WrapMsgListPatientsWithoutMedicalRecordNumber = WrapErrMsgPrefix + "ListPatientsWithoutMedicalRecordNumber"
+	WrapMsgSetMedicalRecordNumbers                = WrapErrMsgPrefix + "SetMedicalRecordNumbers"
+)
+
+// NextMedicalRecordNumber issues the institution's next medical record number
+// for a patient registered at registeredAt. The counter row stays locked until
+// the caller's transaction ends, so concurrent registrations are numbered one
+// after another, and a rollback returns the number: call it in the transaction
+// that stores the number.
+func (c *Conn) NextMedicalRecordNumber(ctx context.Context, institutionID int64, registeredAt time.Time) (number string, err error) {
+	numbers, err := c.ReserveMedicalRecordNumbers(ctx, institutionID, registeredAt, 1)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgNextMedicalRecordNumber)
+		return
+	}
+
+	return numbers[0], nil
+}
+
+// ReserveMedicalRecordNumbers issues count consecutive medical record numbers
+// of the institution for patients registered at registeredAt, in one counter
+// update. Like NextMedicalRecordNumber, the counter row stays locked until the
+// caller's transaction ends, so reserve as the transaction's last steps.
+func (c *Conn) ReserveMedicalRecordNumbers(ctx context.Context, institutionID int64, registeredAt time.Time, count int) (numbers []string, err error) {
+	if count <= 0 {
+		return
+	}
+
+	config := model.MstInstitutionMRNConfig{}
+	found, err := c.writeSession(ctx).
+		Table(model.MstInstitutionMRNConfigTableName).
+		Where("id_mst_institution = ?", institutionID).
+		Get(&config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReserveMedicalRecordNumbers)
+		return
+	}
+	if !found {
+		config = model.DefaultMRNConfig(institutionID)
+	}
+
+	const sql = `
+		INSERT INTO mdl_trx_mrn_counter (id_mst_institution, period, last_number)
+		VALUES (?, ?, ?)
+		ON CONFLICT (id_mst_institution, period) DO UPDATE
+		SET last_number = mdl_trx_mrn_counter.last_number + EXCLUDED.last_number,
+		    update_time = NOW()
+		RETURNING last_number
+	`
+	period := config.Period(registeredAt)
+	var sequences []int64
+	err = c.writeSession(ctx).SQL(sql, institutionID, period, count).Find(&sequences)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgReserveMedicalRecordNumbers)
+		return
+	}
+	if len(sequences) == 0 {
+		err = errors.Wrap(errors.New("counter returned no number"), WrapMsgReserveMedicalRecordNumbers)
+		return
+	}
+
+	first := sequences[0] - int64(count) + 1
+	numbers = make([]string, 0, count)
+	for sequence := first; sequence <= sequences[0]; sequence++ {
+		numbers = append(numbers, config.Format(period, sequence))
+	}
+	return
+}
+
+// ListPatientsWithoutMedicalRecordNumber returns up to limit patients, removed
+// ones included, that have no medical record number yet, oldest registration
+// first. An institutionID of 0 looks across institutions. Rows are locked and
+// rows locked by another backfill are skipped.
+func (c *Conn) ListPatientsWithoutMedicalRecordNumber(ctx context.Context, institutionID int64, limit int) (patients []model.MstPatientInstitution, err error) {
+	const sql = `
+		SELECT *
+		FROM mdl_mst_patient_institution
+		WHERE medical_record_number IS NULL
+		  AND (? = 0 OR id_mst_institution = ?)
+		ORDER BY create_time, id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED
+	`
+
+	patients = []model.MstPatientInstitution{}
+	err = c.writeSession(ctx).SQL(sql, institutionID, institutionID, limit).Find(&patients)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientsWithoutMedicalRecordNumber)
+		return
+	}
+
+	return
+}
+
+// SetMedicalRecordNumbers gives each patient without one the number at the
+// same position in numbers, in one statement.
+func (c *Conn) SetMedicalRecordNumbers(ctx context.Context, patientIDs []int64, numbers []string) (err error) {
+	if len(patientIDs) == 0 {
+		return
+	}
+
+	_, err = c.writeSession(ctx).Exec(`
+		UPDATE mdl_mst_patient_institution mpi
+		SET medical_record_number = assigned.number
+		FROM UNNEST(?::bigint[], ?::text[]) AS assigned (id, number)
+		WHERE mpi.id = assigned.id AND mpi.medical_record_number IS NULL
+	`, pq.Array(patientIDs), pq.Array(numbers))
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSetMedicalRecordNumbers)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,185 @@
+package patient
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgInsertPatientDataRequest = WrapErrMsgPrefix + "InsertPatientDataRequest"
+	WrapMsgListPatientDataRequests  = WrapErrMsgPrefix + "ListPatientDataRequests"
+	WrapMsgListMergedPatients       = WrapErrMsgPrefix + "ListMergedPatients"
+	WrapMsgAnonymisePatient         = WrapErrMsgPrefix + "AnonymisePatient"
+	WrapMsgAnonymisePatientRecords  = WrapErrMsgPrefix + "AnonymisePatientRecords"
+)
+
+func (c *Conn) InsertPatientDataRequest(ctx context.Context, request *model.TrxPatientDataRequest) (err error) {
+	_, err = c.writeSession(ctx).InsertOne(request)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertPatientDataRequest)
+		return
+	}
+	return
+}
+
+// ListPatientDataRequests lists the exports and anonymisations of a patient,
+// newest first.
+func (c *Conn) ListPatientDataRequests(ctx context.Context, institutionID, patientID int64) (requests []model.TrxPatientDataRequest, err error) {
+	requests = []model.TrxPatientDataRequest{}
+	err = c.readSession(ctx).
+		Where("id_mst_institution = ?", institutionID).
+		And("id_mst_patient = ?", patientID).
+		OrderBy("create_time DESC, id DESC").
+		Find(&requests)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientDataRequests)
+		return
+	}
+	return
+}
+
+// ListMergedPatients returns the removed patients that merges not undone
+// folded into survivorID.
+func (c *Conn) ListMergedPatients(ctx context.Context, institutionID, survivorID int64) (patients []model.MstPatientInstitution, err error) {
+	patients = []model.MstPatientInstitution{}
+	err = c.readSession(ctx).
+		Unscoped().
+		Where("id_mst_institution = ?", institutionID).
+		And(`id IN (
+			SELECT merged_id_mst_patient
+			FROM mdl_trx_patient_merge
+			WHERE survivor_id_mst_patient = ?
+			  AND undo_time IS NULL
+		)`, survivorID).
+		Find(&patients)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListMergedPatients)
+		return
+	}
+	return
+}
+
+// AnonymisePatient overwrites the demographics of the patient with those of
+// patient, as scrubbed by model.AnonymisedPatient. It returns
+// constant.ErrorNoAffectedRow when the patient was already anonymised.
+func (c *Conn) AnonymisePatient(ctx context.Context, patient model.MstPatientInstitution) (err error) {
+	const sql = `
+		UPDATE mdl_mst_patient_institution
+		SET name = ?,
+		    nik = NULLIF(?, ''),
+		    place_of_birth = NULLIF(?, ''),
+		    date_of_birth = ?,
+		    address = NULLIF(?, ''),
+		    religion = NULLIF(?, ''),
+		    phone_number = NULLIF(?, ''),
+		    occupation = NULLIF(?, ''),
+		    legacy_record_number = NULLIF(?, ''),
+		    anonymise_time = ?,
+		    update_time = NOW()
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND anonymise_time IS NULL
+	`
+
+	result, err := c.writeSession(ctx).Exec(sql,
+		patient.Name, patient.NIK, patient.PlaceOfBirth, patient.DateOfBirth,
+		patient.Address, patient.Religion, patient.PhoneNumber, patient.Occupation,
+		patient.LegacyRecordNumber, patient.AnonymiseTime,
+		patient.ID, patient.InstitutionID,
+	)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgAnonymisePatient)
+		return
+	}
+	return
+}
+
+// AnonymisePatientRecords scrubs what identifies patients outside their own
+// rows: their relations are removed, both ways, their payers are removed with
+// the member and policy numbers cleared, the consents of their visits lose the
+// signature and signer name and have identifiers replaced in their text, and
+// their attachments are renamed after their category.
+func (c *Conn) AnonymisePatientRecords(ctx context.Context, institutionID int64, patientIDs []int64, identifiers []string) (err error) {
+	session := c.writeSession(ctx)
+
+	_, err = session.Exec(`
+		UPDATE mdl_mst_patient_relation
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_mst_institution = ?
+		  AND (id_mst_patient = ANY(?) OR id_mst_patient_related = ANY(?))
+		  AND delete_time IS NULL
+	`, institutionID, pq.Array(patientIDs), pq.Array(patientIDs))
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatientRecords)
+		return
+	}
+
+	_, err = session.Exec(`
+		UPDATE mdl_mst_patient_payer
+		SET member_number = '',
+		    policy_number = '',
+		    policy_holder = '',
+		    delete_time = COALESCE(delete_time, NOW()),
+		    update_time = NOW()
+		WHERE id_mst_institution = ?
+		  AND id_mst_patient = ANY(?)
+	`, institutionID, pq.Array(patientIDs))
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatientRecords)
+		return
+	}
+
+	// every SET reads the row as it was, so the signer name is still there to
+	// be replaced in the text
+	renderedText := "replace(rendered_text, signer_name, ?)"
+	args := []interface{}{model.AnonymisedText}
+	for _, identifier := range identifiers {
+		renderedText = "replace(" + renderedText + ", ?, ?)"
+		args = append(args, identifier, model.AnonymisedText)
+	}
+	args = append(args, model.AnonymisedText, institutionID, institutionID, pq.Array(patientIDs))
+	consentSQL := `
+		UPDATE mdl_trx_consent
+		SET rendered_text = ` + renderedText + `,
+		    signer_name = ?,
+		    signature_image = ''::bytea
+		WHERE id_mst_institution = ?
+		  AND id_trx_patient_visit IN (
+		      SELECT id
+		      FROM mdl_trx_patient_visit
+		      WHERE id_mst_institution = ?
+		        AND id_mst_patient = ANY(?)
+		  )
+	`
+	_, err = session.Exec(append([]interface{}{consentSQL}, args...)...)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatientRecords)
+		return
+	}
+
+	_, err = session.Exec(`
+		UPDATE mdl_trx_patient_attachment
+		SET file_name = category || '-' || id || COALESCE(substring(file_name from '(\.[A-Za-z0-9]{1,8})$'), '')
+		WHERE id_mst_institution = ?
+		  AND id_mst_patient = ANY(?)
+	`, institutionID, pq.Array(patientIDs))
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatientRecords)
+		return
+	}
+
+	return
+}
@@ -53,12 +53,13 @@ func (c *Conn) RegisterNewPatient(ctx context.Context, patient *model.MstPatient
 		return
 	}
 
-	session := c.DB.MasterDB
+	session := c.writeSession(ctx)
 
 	sqlResult, err := session.SQL(`
 		INSERT INTO mdl_mst_patient_institution 
-		(nik, name, sex, place_of_birth, date_of_birth, address, religion, phone_number, id_mst_institution, occupation, create_time, update_time)
+		(medical_record_number, nik, name, sex, place_of_birth, date_of_birth, address, religion, phone_number, id_mst_institution, occupation, patient_category, legacy_record_number, legacy_last_visit_date, create_time, update_time)
 		VALUES (
+		NULLIF(?, ''), -- medical_record_number
 		?, -- nik
 		?, -- name
 		?, -- sex
@@ -69,10 +70,14 @@ func (c *Conn) RegisterNewPatient(ctx context.Context, patient *model.MstPatient
 		?, -- phone_number
 		?, -- id_mst_institution
 		?, -- occupation
+		?, -- patient_category
+		NULLIF(?, ''), -- legacy_record_number
+		?, -- legacy_last_visit_date
 		NOW(), -- create_time
 		NOW()) -- update_time
 		RETURNING id, uuid, create_time, update_time
 	`,
+		patient.MedicalRecordNumber,
 		patient.NIK,
 		patient.Name,
 		patient.Sex,
@@ -83,6 +88,9 @@ func (c *Conn) RegisterNewPatient(ctx context.Context, patient *model.MstPatient
 		patient.PhoneNumber,
 		patient.InstitutionID,
 		patient.Occupation,
+		patient.PatientCategory,
+		patient.LegacyRecordNumber,
+		patient.LegacyLastVisitDate,
 	).QueryInterface()
 
 	if err != nil {
@@ -159,7 +167,8 @@ func (c *Conn) GetPatients(ctx context.Context, params model.GetPatientParams) (
 		for _, name := range splitNames {
 			nameQuery = append(nameQuery, fmt.Sprintf("%%%s%%", name))
 		}
-		session.Where("mmpi.name ILIKE ANY(?)", pq.Array(nameQuery))
+		// the trigram match lets a misspelt name through
+		session.Where("(mmpi.name ILIKE ANY(?) OR ? <% mmpi.name)", pq.Array(nameQuery), params.Name)
 	}
 
 	if len(params.NIK) > 0 {
@@ -170,6 +179,12 @@ func (c *Conn) GetPatients(ctx context.Context, params model.GetPatientParams) (
 		session.Where("mmpi.phone_number ILIKE ?", fmt.Sprintf("%%%s%%", params.PhoneNumber))
 	}
 
+	if len(params.MedicalRecordNumber) > 0 {
+		// staff still look imported patients up by their old number
+		session.Where("(mmpi.medical_record_number ILIKE ? OR mmpi.legacy_record_number ILIKE ?)",
+			fmt.Sprintf("%%%s%%", params.MedicalRecordNumber), fmt.Sprintf("%%%s%%", params.MedicalRecordNumber))
+	}
+
 	if params.Limit > 0 {
 		session.Limit(params.Limit, params.Offset)
 	}
@@ -472,10 +487,12 @@ func (c *Conn) UpdateTrxVisitProduct(ctx context.Context, request *model.TrxVisi
 		session = c.DB.MasterDB.Context(ctx)
 	}
 
+	// a line re-taxed as exempt must overwrite its previous rate and inclusiveness
 	_, err = session.
 		Table(model.TrxVisitProductTableName).
 		ID(request.ID).
 		Where("id_mst_institution = ?", request.IDMstInstitution).
+		MustCols("tax_rate", "tax_inclusive").
 		Update(request)
 	if err != nil {
 		err = errors.Wrap(err, WrapMsgUpdateTrxVisitProduct)
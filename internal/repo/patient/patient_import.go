@@ -0,0 +1,176 @@
+package patient
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgGetPatientByLegacyRecordNumber = WrapErrMsgPrefix + "GetPatientByLegacyRecordNumber"
+	WrapMsgInsertPatientImport            = WrapErrMsgPrefix + "InsertPatientImport"
+	WrapMsgGetPatientImport               = WrapErrMsgPrefix + "GetPatientImport"
+	WrapMsgListPatientImports             = WrapErrMsgPrefix + "ListPatientImports"
+	WrapMsgClaimPatientImport             = WrapErrMsgPrefix + "ClaimPatientImport"
+	WrapMsgSavePatientImportProgress      = WrapErrMsgPrefix + "SavePatientImportProgress"
+	WrapMsgFinishPatientImport            = WrapErrMsgPrefix + "FinishPatientImport"
+)
+
+// GetPatientByLegacyRecordNumber finds the patient of the institution that had
+// number in the clinic's previous system.
+func (c *Conn) GetPatientByLegacyRecordNumber(ctx context.Context, institutionID int64, number string) (patient model.MstPatientInstitution, found bool, err error) {
+	found, err = c.readSession(ctx).
+		Where("id_mst_institution = ?", institutionID).
+		And("legacy_record_number = ?", number).
+		Get(&patient)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientByLegacyRecordNumber)
+		return
+	}
+	return
+}
+
+func (c *Conn) InsertPatientImport(ctx context.Context, patientImport *model.TrxPatientImport) (err error) {
+	_, err = c.writeSession(ctx).InsertOne(patientImport)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertPatientImport)
+		return
+	}
+	return
+}
+
+// GetPatientImport loads the status of an import, without its rows and errors.
+func (c *Conn) GetPatientImport(ctx context.Context, institutionID, importID int64) (patientImport model.TrxPatientImport, found bool, err error) {
+	found, err = c.readSession(ctx).
+		Omit("rows", "errors").
+		Where("id = ?", importID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&patientImport)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientImport)
+		return
+	}
+	return
+}
+
+// GetPatientImportWithRows loads an import with the rows of its file and the
+// errors of its lines.
+func (c *Conn) GetPatientImportWithRows(ctx context.Context, institutionID, importID int64) (patientImport model.TrxPatientImport, found bool, err error) {
+	found, err = c.readSession(ctx).
+		Where("id = ?", importID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&patientImport)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientImport)
+		return
+	}
+	return
+}
+
+// ListPatientImports lists the institution's imports, newest first, without
+// their rows and errors.
+func (c *Conn) ListPatientImports(ctx context.Context, params model.ListPatientImportParams) (patientImports []model.TrxPatientImport, err error) {
+	session := c.readSession(ctx).
+		Omit("rows", "errors").
+		Where("id_mst_institution = ?", params.IDMstInstitution).
+		OrderBy("create_time DESC, id DESC")
+	if params.Limit > 0 {
+		session.Limit(params.Limit, params.Offset)
+	}
+
+	patientImports = []model.TrxPatientImport{}
+	err = session.Find(&patientImports)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientImports)
+		return
+	}
+	return
+}
+
+// ClaimPatientImport marks the oldest queued import as processing and returns
+// it, rows included. An import still processing but without progress since
+// staleBefore was left by a stopped server and is claimed again. Imports
+// being claimed by another server are skipped.
+func (c *Conn) ClaimPatientImport(ctx context.Context, staleBefore time.Time) (patientImport model.TrxPatientImport, found bool, err error) {
+	const sql = `
+		UPDATE mdl_trx_patient_import
+		SET status = ?,
+		    start_time = COALESCE(start_time, NOW()),
+		    update_time = NOW()
+		WHERE id = (
+		    SELECT id
+		    FROM mdl_trx_patient_import
+		    WHERE status = ?
+		       OR (status = ? AND update_time < ?)
+		    ORDER BY id
+		    LIMIT 1
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+
+	claimed := []model.TrxPatientImport{}
+	err = c.writeSession(ctx).SQL(sql,
+		model.PatientImportStatusProcessing,
+		model.PatientImportStatusQueued,
+		model.PatientImportStatusProcessing, staleBefore,
+	).Find(&claimed)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgClaimPatientImport)
+		return
+	}
+	if len(claimed) == 0 {
+		return
+	}
+	return claimed[0], true, nil
+}
+
+// SavePatientImportProgress stores the counts of patientImport and adds the
+// errors of the lines just processed to those of the import.
+func (c *Conn) SavePatientImportProgress(ctx context.Context, patientImport model.TrxPatientImport, lineErrors []model.PatientImportLineError) (err error) {
+	if lineErrors == nil {
+		lineErrors = []model.PatientImportLineError{}
+	}
+	encoded, err := json.Marshal(lineErrors)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientImportProgress)
+		return
+	}
+
+	_, err = c.writeSession(ctx).Exec(`
+		UPDATE mdl_trx_patient_import
+		SET processed_rows = ?,
+		    imported_rows = ?,
+		    duplicate_rows = ?,
+		    failed_rows = ?,
+		    errors = errors || ?::jsonb,
+		    update_time = NOW()
+		WHERE id = ?
+	`, patientImport.ProcessedRows, patientImport.ImportedRows, patientImport.DuplicateRows,
+		patientImport.FailedRows, string(encoded), patientImport.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientImportProgress)
+		return
+	}
+	return
+}
+
+// FinishPatientImport records that an import completed or failed.
+func (c *Conn) FinishPatientImport(ctx context.Context, importID int64, status, failureReason string) (err error) {
+	_, err = c.writeSession(ctx).Exec(`
+		UPDATE mdl_trx_patient_import
+		SET status = ?,
+		    failure_reason = ?,
+		    finish_time = NOW(),
+		    update_time = NOW()
+		WHERE id = ?
+	`, status, failureReason, importID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFinishPatientImport)
+		return
+	}
+	return
+}
@@ -0,0 +1,353 @@
+package patient
+
+import (
+	"context"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgFindPatientDuplicateCandidates = WrapErrMsgPrefix + "FindPatientDuplicateCandidates"
+	WrapMsgMovePatientRecords             = WrapErrMsgPrefix + "MovePatientRecords"
+	WrapMsgRestorePatientRecords          = WrapErrMsgPrefix + "RestorePatientRecords"
+	WrapMsgSetPatientDeleted              = WrapErrMsgPrefix + "SetPatientDeleted"
+	WrapMsgInsertPatientMerge             = WrapErrMsgPrefix + "InsertPatientMerge"
+	WrapMsgGetPatientMerge                = WrapErrMsgPrefix + "GetPatientMerge"
+	WrapMsgListPatientMerges              = WrapErrMsgPrefix + "ListPatientMerges"
+	WrapMsgMarkPatientMergeUndone         = WrapErrMsgPrefix + "MarkPatientMergeUndone"
+
+	// duplicateCandidateScanLimit bounds the patients pulled in for scoring.
+	duplicateCandidateScanLimit = 200
+)
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+// FindPatientDuplicateCandidates returns the institution's other patients that
+// share something with patient: a NIK, or one with the same first or last eight
+// digits, the date of birth or its day/month swap, the phone number, or a word
+// of the name. The caller scores them.
+func (c *Conn) FindPatientDuplicateCandidates(ctx context.Context, patient model.MstPatientInstitution) (candidates []model.MstPatientInstitution, err error) {
+	const sql = `
+		SELECT *
+		FROM mdl_mst_patient_institution
+		WHERE id_mst_institution = ?
+		  AND id <> ?
+		  AND delete_time IS NULL
+		  AND (
+		        (length(?) >= 10 AND (
+		            regexp_replace(nik, '\D', '', 'g') = ?
+		            OR left(regexp_replace(nik, '\D', '', 'g'), 8) = left(?, 8)
+		            OR right(regexp_replace(nik, '\D', '', 'g'), 8) = right(?, 8)))
+		     OR date_of_birth IN (?::date, ?::date)
+		     OR (length(?) >= 8 AND right(regexp_replace(phone_number, '\D', '', 'g'), 8) = right(?, 8))
+		     OR lower(name) LIKE ANY (?)
+		  )
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	nik := model.NormaliseNIK(patient.NIK)
+	phone := model.NormalisePhoneNumber(patient.PhoneNumber)
+	dateOfBirth, swapped := patient.DateOfBirth, patient.DateOfBirth
+	if day := dateOfBirth.Day(); day <= 12 {
+		swapped = time.Date(dateOfBirth.Year(), time.Month(day), int(dateOfBirth.Month()), 0, 0, 0, 0, time.UTC)
+	}
+	namePatterns := []string{}
+	for _, token := range model.NameSearchTokens(patient.Name) {
+		namePatterns = append(namePatterns, "%"+token+"%")
+	}
+
+	err = c.readSession(ctx).SQL(sql,
+		patient.InstitutionID,
+		patient.ID,
+		nik, nik, nik, nik,
+		dateOfBirth.Format(constant.DateFormatYYYYMMDDDashed), swapped.Format(constant.DateFormatYYYYMMDDDashed),
+		phone, phone,
+		pq.Array(namePatterns),
+		duplicateCandidateScanLimit,
+	).Find(&candidates)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindPatientDuplicateCandidates)
+		return
+	}
+
+	return
+}
+
+// MovePatientRecords re-points the visits, recalls, ledger entries, payers and
+// odontogram events of patient fromID to patient toID. Odontogram events are
+// renumbered after toID's own, and both patients' odontogram snapshots are
+// dropped so they are rebuilt from the events. The returned undo data lists
+// every row moved. Diagnoses, procedures and anamnesa hang off the visits and
+// move with them.
+func (c *Conn) MovePatientRecords(ctx context.Context, institutionID, fromID, toID int64) (undo model.PatientMergeUndo, err error) {
+	for _, move := range []struct {
+		table  string
+		column string
+		ids    *[]int64
+	}{
+		{model.TrxPatientVisitTableName, "id_mst_patient", &undo.VisitIDs},
+		{model.TrxRecallTableName, "id_mst_patient", &undo.RecallIDs},
+		{model.TrxPatientLedgerEntryTableName, "id_mst_patient", &undo.LedgerEntryIDs},
+		{model.TrxPatientAttachmentTableName, "id_mst_patient", &undo.AttachmentIDs},
+		{model.MstPatientRelationTableName, "id_mst_patient", &undo.RelationIDs},
+		{model.MstPatientRelationTableName, "id_mst_patient_related", &undo.RelatedRelationIDs},
+		{model.MstPatientPayerTableName, "id_mst_patient", &undo.PayerIDs},
+	} {
+		sql := `
+			UPDATE ` + move.table + `
+			SET ` + move.column + ` = ?
+			WHERE ` + move.column + ` = ?
+			  AND id_mst_institution = ?
+			RETURNING id
+		`
+		err = c.writeSession(ctx).SQL(sql, toID, fromID, institutionID).Find(move.ids)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgMovePatientRecords)
+			return
+		}
+	}
+
+	const moveOdontogramSQL = `
+		WITH base AS (
+			SELECT COALESCE(MAX(sequence_number), 0) AS offset_sequence
+			FROM mdl_hst_odontogram
+			WHERE patient_id = ?
+		)
+		UPDATE mdl_hst_odontogram h
+		SET patient_id = ?,
+		    sequence_number = h.sequence_number + base.offset_sequence
+		FROM base
+		WHERE h.patient_id = ?
+		  AND h.institution_id = ?
+		RETURNING h.event_id::text AS event_id, h.sequence_number - base.offset_sequence AS sequence_number
+	`
+	err = c.writeSession(ctx).SQL(moveOdontogramSQL, toID, toID, fromID, institutionID).Find(&undo.OdontogramEvents)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMovePatientRecords)
+		return
+	}
+
+	err = c.dropOdontogramSnapshots(ctx, fromID, toID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMovePatientRecords)
+		return
+	}
+
+	return
+}
+
+// RestorePatientRecords moves the rows listed in undo from patient fromID back
+// to patient toID, giving odontogram events their original sequence numbers.
+// Rows no longer on fromID are left alone.
+func (c *Conn) RestorePatientRecords(ctx context.Context, institutionID, fromID, toID int64, undo model.PatientMergeUndo) (err error) {
+	for _, restore := range []struct {
+		table  string
+		column string
+		ids    []int64
+	}{
+		{model.TrxPatientVisitTableName, "id_mst_patient", undo.VisitIDs},
+		{model.TrxRecallTableName, "id_mst_patient", undo.RecallIDs},
+		{model.TrxPatientLedgerEntryTableName, "id_mst_patient", undo.LedgerEntryIDs},
+		{model.TrxPatientAttachmentTableName, "id_mst_patient", undo.AttachmentIDs},
+		{model.MstPatientRelationTableName, "id_mst_patient", undo.RelationIDs},
+		{model.MstPatientRelationTableName, "id_mst_patient_related", undo.RelatedRelationIDs},
+		{model.MstPatientPayerTableName, "id_mst_patient", undo.PayerIDs},
+	} {
+		if len(restore.ids) == 0 {
+			continue
+		}
+		sql := `
+			UPDATE ` + restore.table + `
+			SET ` + restore.column + ` = ?
+			WHERE id = ANY (?)
+			  AND ` + restore.column + ` = ?
+			  AND id_mst_institution = ?
+		`
+		_, err = c.writeSession(ctx).Exec(sql, toID, pq.Array(restore.ids), fromID, institutionID)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgRestorePatientRecords)
+			return
+		}
+	}
+
+	if len(undo.OdontogramEvents) > 0 {
+		eventIDs := make([]string, 0, len(undo.OdontogramEvents))
+		sequences := make([]int64, 0, len(undo.OdontogramEvents))
+		for _, event := range undo.OdontogramEvents {
+			eventIDs = append(eventIDs, event.EventID)
+			sequences = append(sequences, event.SequenceNumber)
+		}
+
+		const restoreOdontogramSQL = `
+			UPDATE mdl_hst_odontogram h
+			SET patient_id = ?,
+			    sequence_number = u.sequence_number
+			FROM unnest(?::uuid[], ?::int8[]) AS u(event_id, sequence_number)
+			WHERE h.event_id = u.event_id
+			  AND h.patient_id = ?
+			  AND h.institution_id = ?
+		`
+		_, err = c.writeSession(ctx).Exec(restoreOdontogramSQL, toID, pq.Array(eventIDs), pq.Array(sequences), fromID, institutionID)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgRestorePatientRecords)
+			return
+		}
+	}
+
+	err = c.dropOdontogramSnapshots(ctx, fromID, toID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgRestorePatientRecords)
+		return
+	}
+
+	return
+}
+
+func (c *Conn) dropOdontogramSnapshots(ctx context.Context, patientIDs ...int64) (err error) {
+	_, err = c.writeSession(ctx).Exec(`
+		DELETE FROM mdl_mst_patient_odontogram
+		WHERE patient_id = ANY (?)
+	`, pq.Array(patientIDs))
+	return
+}
+
+// SetPatientDeleted removes or restores a patient. It reports
+// constant.ErrorNoAffectedRow when the patient is not in the opposite state.
+func (c *Conn) SetPatientDeleted(ctx context.Context, institutionID, patientID int64, deleted bool) (err error) {
+	sql := `
+		UPDATE mdl_mst_patient_institution
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id = ? AND id_mst_institution = ? AND delete_time IS NULL
+	`
+	if !deleted {
+		sql = `
+			UPDATE mdl_mst_patient_institution
+			SET delete_time = NULL, update_time = NOW()
+			WHERE id = ? AND id_mst_institution = ? AND delete_time IS NOT NULL
+		`
+	}
+
+	result, err := c.writeSession(ctx).Exec(sql, patientID, institutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSetPatientDeleted)
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSetPatientDeleted)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgSetPatientDeleted)
+		return
+	}
+
+	return
+}
+
+func (c *Conn) InsertPatientMerge(ctx context.Context, merge *model.TrxPatientMerge) (err error) {
+	_, err = c.writeSession(ctx).Table(model.TrxPatientMergeTableName).InsertOne(merge)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertPatientMerge)
+		return
+	}
+
+	return
+}
+
+const patientMergeRowSQL = `
+	SELECT m.*,
+	       survivor.uuid AS survivor_uuid, survivor.name AS survivor_name,
+	       merged.uuid AS merged_uuid, merged.name AS merged_name
+	FROM mdl_trx_patient_merge m
+	JOIN mdl_mst_patient_institution survivor ON survivor.id = m.survivor_id_mst_patient
+	JOIN mdl_mst_patient_institution merged ON merged.id = m.merged_id_mst_patient
+	WHERE m.id_mst_institution = ?
+`
+
+// GetPatientMerge loads a merge of the institution, locking it when called in
+// a transaction.
+func (c *Conn) GetPatientMerge(ctx context.Context, institutionID, mergeID int64) (merge model.PatientMergeRow, found bool, err error) {
+	sql := patientMergeRowSQL + ` AND m.id = ?`
+	if xormlib.GetDBSession(ctx) != nil {
+		sql += ` FOR UPDATE OF m`
+	}
+
+	found, err = c.readSession(ctx).SQL(sql, institutionID, mergeID).Get(&merge)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientMerge)
+		return
+	}
+
+	return
+}
+
+// ListPatientMerges lists the institution's merges, newest first, optionally
+// those involving one patient.
+func (c *Conn) ListPatientMerges(ctx context.Context, params model.ListPatientMergeParams) (merges []model.PatientMergeRow, err error) {
+	sql := patientMergeRowSQL
+	args := []interface{}{params.IDMstInstitution}
+	if params.PatientUUID != "" {
+		sql += ` AND (survivor.uuid = ? OR merged.uuid = ?)`
+		args = append(args, params.PatientUUID, params.PatientUUID)
+	}
+	sql += ` ORDER BY m.create_time DESC, m.id DESC LIMIT ? OFFSET ?`
+	args = append(args, params.Limit, params.Offset)
+
+	merges = []model.PatientMergeRow{}
+	err = c.readSession(ctx).SQL(sql, args...).Find(&merges)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientMerges)
+		return
+	}
+
+	return
+}
+
+// MarkPatientMergeUndone records who undid a merge. It reports
+// constant.ErrorNoAffectedRow when the merge was already undone.
+func (c *Conn) MarkPatientMergeUndone(ctx context.Context, institutionID, mergeID int64, undoneBy string) (err error) {
+	result, err := c.writeSession(ctx).Exec(`
+		UPDATE mdl_trx_patient_merge
+		SET undone_by = ?, undo_time = NOW()
+		WHERE id = ? AND id_mst_institution = ? AND undo_time IS NULL
+	`, undoneBy, mergeID, institutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMarkPatientMergeUndone)
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMarkPatientMergeUndone)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgMarkPatientMergeUndone)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,174 @@
+package patient
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgListPatientPayers               = WrapErrMsgPrefix + "ListPatientPayers"
+	WrapMsgGetPatientPayer                 = WrapErrMsgPrefix + "GetPatientPayer"
+	WrapMsgFindPatientPayersByMemberNumber = WrapErrMsgPrefix + "FindPatientPayersByMemberNumber"
+	WrapMsgInsertPatientPayer              = WrapErrMsgPrefix + "InsertPatientPayer"
+	WrapMsgUpdatePatientPayer              = WrapErrMsgPrefix + "UpdatePatientPayer"
+	WrapMsgDeletePatientPayer              = WrapErrMsgPrefix + "DeletePatientPayer"
+	WrapMsgClearDefaultPatientPayer        = WrapErrMsgPrefix + "ClearDefaultPatientPayer"
+	WrapMsgUpdatePatientPayerEligibility   = WrapErrMsgPrefix + "UpdatePatientPayerEligibility"
+	WrapMsgUpdateVisitPayer                = WrapErrMsgPrefix + "UpdateVisitPayer"
+)
+
+// ListPatientPayers returns the payers of a patient, the default payer first.
+func (c *Conn) ListPatientPayers(ctx context.Context, institutionID, patientID int64) (payers []model.MstPatientPayer, err error) {
+	payers = []model.MstPatientPayer{}
+	err = c.readSession(ctx).
+		Where("id_mst_institution = ?", institutionID).
+		And("id_mst_patient = ?", patientID).
+		OrderBy("is_default DESC, update_time DESC, id").
+		Find(&payers)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientPayers)
+		return
+	}
+	return
+}
+
+func (c *Conn) GetPatientPayer(ctx context.Context, institutionID, patientID, payerID int64) (payer model.MstPatientPayer, found bool, err error) {
+	found, err = c.readSession(ctx).
+		Where("id = ?", payerID).
+		And("id_mst_patient = ?", patientID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&payer)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientPayer)
+		return
+	}
+	return
+}
+
+// FindPatientPayersByMemberNumber returns the payers of any patient of the
+// institution with the given membership.
+func (c *Conn) FindPatientPayersByMemberNumber(ctx context.Context, institutionID int64, payerType, memberNumber string) (payers []model.MstPatientPayer, err error) {
+	payers = []model.MstPatientPayer{}
+	err = c.readSession(ctx).
+		Where("id_mst_institution = ?", institutionID).
+		And("payer_type = ?", payerType).
+		And("member_number = ?", memberNumber).
+		Find(&payers)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgFindPatientPayersByMemberNumber)
+		return
+	}
+	return
+}
+
+func (c *Conn) InsertPatientPayer(ctx context.Context, payer *model.MstPatientPayer) (err error) {
+	_, err = c.writeSession(ctx).Insert(payer)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertPatientPayer)
+		return
+	}
+	return
+}
+
+// UpdatePatientPayer replaces the membership details of a payer. A changed
+// membership has to be checked again, so the eligibility result is reset.
+func (c *Conn) UpdatePatientPayer(ctx context.Context, payer *model.MstPatientPayer) (err error) {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", payer.ID).
+		And("id_mst_patient = ?", payer.IDMstPatient).
+		And("id_mst_institution = ?", payer.IDMstInstitution).
+		Cols("payer_type", "member_number", "bpjs_class", "fktp_code", "fktp_name", "insurer_name",
+			"policy_number", "policy_holder", "coverage_rate", "coverage_limit", "valid_from", "valid_to",
+			"is_default", "eligibility_status", "eligibility_message", "eligibility_check_time",
+			"notes", "updated_by").
+		Update(payer)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdatePatientPayer)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgUpdatePatientPayer)
+		return
+	}
+	return
+}
+
+func (c *Conn) DeletePatientPayer(ctx context.Context, institutionID, patientID, payerID int64) (err error) {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", payerID).
+		And("id_mst_patient = ?", patientID).
+		And("id_mst_institution = ?", institutionID).
+		Delete(&model.MstPatientPayer{})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgDeletePatientPayer)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgDeletePatientPayer)
+		return
+	}
+	return
+}
+
+// ClearDefaultPatientPayer unmarks the patient's default payer, so another
+// payer can take its place.
+func (c *Conn) ClearDefaultPatientPayer(ctx context.Context, institutionID, patientID int64) (err error) {
+	const sql = `
+		UPDATE mdl_mst_patient_payer
+		SET is_default = FALSE,
+		    update_time = NOW()
+		WHERE id_mst_patient = ?
+		  AND id_mst_institution = ?
+		  AND is_default
+		  AND delete_time IS NULL
+	`
+	_, err = c.writeSession(ctx).Exec(sql, patientID, institutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgClearDefaultPatientPayer)
+		return
+	}
+	return
+}
+
+// UpdatePatientPayerEligibility keeps the result of the last eligibility
+// check of a payer.
+func (c *Conn) UpdatePatientPayerEligibility(ctx context.Context, payer model.MstPatientPayer) (err error) {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", payer.ID).
+		And("id_mst_institution = ?", payer.IDMstInstitution).
+		Cols("eligibility_status", "eligibility_message", "eligibility_check_time").
+		NoAutoTime().
+		Update(&payer)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdatePatientPayerEligibility)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgUpdatePatientPayerEligibility)
+		return
+	}
+	return
+}
+
+// UpdateVisitPayer saves the payer selected for a visit with the category
+// and coverage it brings, see model.TrxPatientVisit.SelectPayer.
+func (c *Conn) UpdateVisitPayer(ctx context.Context, visit model.TrxPatientVisit) (err error) {
+	affected, err := c.writeSession(ctx).
+		Table(model.TrxPatientVisitTableName).
+		Where("id = ?", visit.ID).
+		And("id_mst_institution = ?", visit.IDMstInstitution).
+		Cols("id_mst_patient_payer", "payer_type", "payer_coverage_rate", "payer_coverage_limit", "patient_category").
+		Update(&visit)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdateVisitPayer)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgUpdateVisitPayer)
+		return
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package patient
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgListPatientRelations  = WrapErrMsgPrefix + "ListPatientRelations"
+	WrapMsgGetPatientRelation    = WrapErrMsgPrefix + "GetPatientRelation"
+	WrapMsgInsertPatientRelation = WrapErrMsgPrefix + "InsertPatientRelation"
+	WrapMsgUpdatePatientRelation = WrapErrMsgPrefix + "UpdatePatientRelation"
+	WrapMsgDeletePatientRelation = WrapErrMsgPrefix + "DeletePatientRelation"
+	WrapMsgClearRecallContact    = WrapErrMsgPrefix + "ClearRecallContact"
+)
+
+// ListPatientRelations returns the relations of the patients, each patient's
+// recall contact first. A relation to a patient shows that patient's current
+// name and contact details.
+func (c *Conn) ListPatientRelations(ctx context.Context, institutionID int64, patientIDs []int64) (relations []model.PatientRelationResponse, err error) {
+	relations = []model.PatientRelationResponse{}
+	if len(patientIDs) == 0 {
+		return
+	}
+
+	const sql = `
+		SELECT r.id, r.id_mst_patient, r.relationship, rp.uuid AS related_patient_uuid,
+		       CASE WHEN rp.id IS NULL THEN r.name ELSE rp.name END AS name,
+		       CASE WHEN rp.id IS NULL THEN r.nik ELSE rp.nik END AS nik,
+		       CASE WHEN rp.id IS NULL THEN r.sex ELSE rp.sex END AS sex,
+		       rp.date_of_birth,
+		       CASE WHEN rp.id IS NULL THEN r.phone_number ELSE rp.phone_number END AS phone_number,
+		       CASE WHEN rp.id IS NULL THEN r.address ELSE rp.address END AS address,
+		       r.is_recall_contact, r.notes, r.update_time
+		FROM mdl_mst_patient_relation r
+		LEFT JOIN mdl_mst_patient_institution rp
+		       ON rp.id = r.id_mst_patient_related
+		      AND rp.delete_time IS NULL
+		WHERE r.id_mst_institution = ?
+		  AND r.id_mst_patient = ANY (?)
+		  AND r.delete_time IS NULL
+		ORDER BY r.id_mst_patient, r.is_recall_contact DESC, r.update_time DESC, r.id
+	`
+	err = c.readSession(ctx).SQL(sql, institutionID, pq.Array(patientIDs)).Find(&relations)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientRelations)
+		return
+	}
+	return
+}
+
+func (c *Conn) GetPatientRelation(ctx context.Context, institutionID, patientID, relationID int64) (relation model.MstPatientRelation, found bool, err error) {
+	found, err = c.readSession(ctx).
+		Where("id = ?", relationID).
+		And("id_mst_patient = ?", patientID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&relation)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientRelation)
+		return
+	}
+	return
+}
+
+func (c *Conn) InsertPatientRelation(ctx context.Context, relation *model.MstPatientRelation) (err error) {
+	_, err = c.writeSession(ctx).Insert(relation)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertPatientRelation)
+		return
+	}
+	return
+}
+
+func (c *Conn) UpdatePatientRelation(ctx context.Context, relation *model.MstPatientRelation) (err error) {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", relation.ID).
+		And("id_mst_patient = ?", relation.IDMstPatient).
+		And("id_mst_institution = ?", relation.IDMstInstitution).
+		Cols("id_mst_patient_related", "relationship", "name", "nik", "sex", "phone_number",
+			"address", "is_recall_contact", "notes", "updated_by").
+		Update(relation)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdatePatientRelation)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgUpdatePatientRelation)
+		return
+	}
+	return
+}
+
+func (c *Conn) DeletePatientRelation(ctx context.Context, institutionID, patientID, relationID int64) (err error) {
+	affected, err := c.writeSession(ctx).
+		Where("id = ?", relationID).
+		And("id_mst_patient = ?", patientID).
+		And("id_mst_institution = ?", institutionID).
+		Delete(&model.MstPatientRelation{})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgDeletePatientRelation)
+		return
+	}
+	if affected == 0 {
+		err = errors.Wrap(constant.ErrorNoAffectedRow, WrapMsgDeletePatientRelation)
+		return
+	}
+	return
+}
+
+// ClearRecallContact unmarks the patient's recall contact, so another
+// relation can take its place.
+func (c *Conn) ClearRecallContact(ctx context.Context, institutionID, patientID int64) (err error) {
+	const sql = `
+		UPDATE mdl_mst_patient_relation
+		SET is_recall_contact = FALSE,
+		    update_time = NOW()
+		WHERE id_mst_patient = ?
+		  AND id_mst_institution = ?
+		  AND is_recall_contact
+		  AND delete_time IS NULL
+	`
+	_, err = c.writeSession(ctx).Exec(sql, patientID, institutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgClearRecallContact)
+		return
+	}
+	return
+}
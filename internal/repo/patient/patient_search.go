@@ -0,0 +1,100 @@
+package patient
+
+import (
+	"context"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgSearchPatients = WrapErrMsgPrefix + "SearchPatients"
+
+	// patientSearchCandidateLimit is how many of the best matches are ranked
+	// by latest visit and paged through.
+	patientSearchCandidateLimit = 200
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is a LIKE pattern matching s anywhere, with s taken literally.
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// SearchPatients finds the institution's patients matching params.Query: by
+// trigram word similarity on the name, so misspelt names are found, and by
+// substring on the medical record number, NIK and phone number. The best
+// matches come first, then the patients seen most recently. Only the
+// patientSearchCandidateLimit best matches are ranked and paged, so a page
+// costs the same however many patients match.
+func (c *Conn) SearchPatients(ctx context.Context, params model.GetPatientParams) (patients []model.MstPatientInstitution, err error) {
+	const sql = `
+		SELECT mmpi.*
+		FROM (
+			SELECT p.*,
+			       GREATEST(
+			           word_similarity(?, p.name),
+			           CASE WHEN p.medical_record_number ILIKE ? THEN 1
+			                WHEN p.medical_record_number ILIKE ? THEN 0.8
+			                ELSE 0 END,
+			           CASE WHEN ? = '' THEN 0
+			                WHEN p.nik = ? THEN 1
+			                WHEN p.nik LIKE ? THEN 0.7
+			                ELSE 0 END,
+			           CASE WHEN ? = '' THEN 0
+			                WHEN regexp_replace(p.phone_number, '\D', '', 'g') LIKE ? THEN 0.7
+			                ELSE 0 END
+			       ) AS search_score
+			FROM mdl_mst_patient_institution p
+			WHERE p.id_mst_institution = ?
+			  AND p.delete_time IS NULL
+			  AND (
+			        ? <% p.name
+			     OR p.name ILIKE ?
+			     OR p.medical_record_number ILIKE ?
+			     OR (? <> '' AND p.nik LIKE ?)
+			     OR (? <> '' AND regexp_replace(p.phone_number, '\D', '', 'g') LIKE ?)
+			  )
+			ORDER BY search_score DESC, p.id DESC
+			LIMIT ?
+		) mmpi
+		LEFT JOIN LATERAL (
+			SELECT v.create_time AS last_visit_time
+			FROM mdl_trx_patient_visit v
+			WHERE v.id_mst_patient = mmpi.id
+			  AND v.delete_time IS NULL
+			ORDER BY v.create_time DESC
+			LIMIT 1
+		) lv ON TRUE
+		ORDER BY mmpi.search_score DESC, lv.last_visit_time DESC NULLS LAST, mmpi.id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	terms := model.NewPatientSearchTerms(params.Query)
+	text, textPattern := terms.Text, containsPattern(terms.Text)
+	nikPattern, phonePattern := containsPattern(terms.Digits), containsPattern(terms.PhoneDigits)
+
+	patients = []model.MstPatientInstitution{}
+	err = c.readSession(ctx).SQL(sql,
+		text,
+		likeEscaper.Replace(text), textPattern,
+		terms.Digits, terms.Digits, nikPattern,
+		terms.PhoneDigits, phonePattern,
+		params.InstitutionID,
+		text,
+		textPattern,
+		textPattern,
+		terms.Digits, nikPattern,
+		terms.PhoneDigits, phonePattern,
+		patientSearchCandidateLimit,
+		params.Limit, params.Offset,
+	).Find(&patients)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSearchPatients)
+		return
+	}
+
+	return
+}
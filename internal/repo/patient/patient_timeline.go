@@ -0,0 +1,160 @@
+package patient
+
+import (
+	"context"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapMsgListPatientTimeline = WrapErrMsgPrefix + "ListPatientTimeline"
+)
+
+// timelineBranches select each kind of timeline entry as (kind, entry_id,
+// occurred_at, visit_id, title, detail), every branch naming its columns since
+// any of them may come first in the UNION. Branches on visit data read the
+// patient's visits from the patient_visits CTE; the others take the patient and
+// institution IDs as arguments.
+var timelineBranches = map[string]struct {
+	sql           string
+	takesPatients bool
+}{
+	model.TimelineKindVisit: {sql: `
+		SELECT 'visit' AS kind, v.id::text AS entry_id, v.create_time AS occurred_at, v.id::int8 AS visit_id,
+		       COALESCE(v.action, '') AS title,
+		       jsonb_build_object('status', v.status, 'notes', v.notes, 'service_point_id', v.id_mst_service_point) AS detail
+		FROM patient_visits v`},
+	model.TimelineKindTouchpoint: {sql: `
+		SELECT 'touchpoint' AS kind, d.id::text AS entry_id, d.create_time AS occurred_at, d.id_trx_patient_visit::int8 AS visit_id,
+		       COALESCE(d.name_mst_journey_point, '') AS title,
+		       jsonb_build_object('journey_point_id', d.id_mst_journey_point, 'service_point_id', d.id_mst_service_point,
+		                          'action_by_staff_id', d.action_by_id_mst_staff, 'notes', d.notes, 'contributors', d.contributors) AS detail
+		FROM mdl_dtl_patient_visit d
+		JOIN patient_visits v ON v.id = d.id_trx_patient_visit
+		WHERE d.delete_time IS NULL`},
+	model.TimelineKindDiagnosis: {sql: `
+		SELECT 'diagnosis' AS kind, dg.id::text AS entry_id, dg.created_at::timestamptz AS occurred_at, dg.visit_id::int8 AS visit_id,
+		       dg.icd10_code || ' ' || dg.icd10_display AS title,
+		       jsonb_build_object('icd10_code', dg.icd10_code, 'icd10_display', dg.icd10_display, 'type', dg.type,
+		                          'rank', dg.rank, 'case', dg."case", 'clinical_status', dg.clinical_status, 'note', dg.note) AS detail
+		FROM mdl_trx_diagnosis dg
+		JOIN patient_visits v ON v.id = dg.visit_id AND v.id_mst_institution = dg.institution_id
+		WHERE dg.deleted_at IS NULL`},
+	model.TimelineKindAnamnesa: {sql: `
+		SELECT 'anamnesa' AS kind, a.id::text AS entry_id, a.created_at::timestamptz AS occurred_at, a.visit_id::int8 AS visit_id,
+		       COALESCE(a.chief_complaint, '') AS title,
+		       jsonb_build_object('systolic', a.vs_systolic, 'diastolic', a.vs_diastolic, 'pulse', a.vs_pulse,
+		                          'temperature', a.vs_temperature, 'respiratory_rate', a.vs_respiratory_rate,
+		                          'oxygen_saturation', a.vs_oxygen_saturation, 'weight', a.vs_weight, 'height', a.vs_height,
+		                          'bmi', a.vs_bmi, 'pain_scale', a.pain_scale) AS detail
+		FROM mdl_trx_anamnesa a
+		JOIN patient_visits v ON v.id = a.visit_id AND v.id_mst_institution = a.institution_id`},
+	model.TimelineKindProcedure: {sql: `
+		SELECT 'procedure' AS kind, pr.id::text AS entry_id, pr.created_at AS occurred_at, pr.visit_id::int8 AS visit_id,
+		       COALESCE(pr.icd9cm_display, pr.product_name, '') AS title,
+		       jsonb_build_object('icd9cm_code', pr.icd9cm_code, 'product_name', pr.product_name,
+		                          'doctor_name', pr.doctor_name, 'category', pr.category, 'notes', pr.notes) AS detail
+		FROM mdl_trx_visit_procedure pr
+		JOIN patient_visits v ON v.id = pr.visit_id AND v.id_mst_institution = pr.institution_id
+		WHERE pr.deleted_at IS NULL`},
+	model.TimelineKindOdontogram: {takesPatients: true, sql: `
+		SELECT 'odontogram' AS kind, h.event_id::text AS entry_id, to_timestamp(h.create_time) AS occurred_at, h.visit_id::int8 AS visit_id,
+		       h.event_type || ' ' || h.tooth_id AS title,
+		       jsonb_build_object('tooth_id', h.tooth_id, 'event_type', h.event_type, 'event_data', h.event_data,
+		                          'created_by', h.created_by) AS detail
+		FROM mdl_hst_odontogram h
+		WHERE h.patient_id = ? AND h.institution_id = ?`},
+	model.TimelineKindProduct: {sql: `
+		SELECT 'product' AS kind, vp.id::text AS entry_id, vp.create_time AS occurred_at, vp.id_trx_patient_visit::int8 AS visit_id,
+		       COALESCE(vp.name, '') AS title,
+		       jsonb_build_object('product_id', vp.id_trx_institution_product, 'quantity', vp.quantity,
+		                          'unit_type', vp.unit_type, 'total_price', vp.total_price) AS detail
+		FROM mdl_trx_visit_product vp
+		JOIN patient_visits v ON v.id = vp.id_trx_patient_visit
+		WHERE vp.delete_time IS NULL`},
+	model.TimelineKindRecall: {takesPatients: true, sql: `
+		SELECT 'recall' AS kind, r.id::text AS entry_id, r.scheduled_at AS occurred_at, r.id_trx_patient_visit::int8 AS visit_id,
+		       r.recall_type AS title,
+		       jsonb_build_object('notes', r.notes, 'create_time', r.create_time) AS detail
+		FROM mdl_trx_recall r
+		WHERE r.id_mst_patient = ? AND r.id_mst_institution = ? AND r.delete_time IS NULL`},
+}
+
+// ListPatientTimeline returns up to limit entries of the patient's timeline of
+// the given kinds, newest first, starting after cursor when one is given.
+// Recalls are placed at their scheduled time and odontogram events at the time
+// they were recorded.
+func (c *Conn) ListPatientTimeline(ctx context.Context, institutionID, patientID int64, params model.GetPatientTimelineParams, cursor *model.TimelineCursor, limit int) (entries []model.TimelineEntry, err error) {
+	kinds := params.Kinds
+	if len(kinds) == 0 {
+		kinds = model.TimelineKinds
+	}
+
+	args := []interface{}{patientID, institutionID}
+	branches := make([]string, 0, len(kinds))
+	for _, kind := range model.TimelineKinds {
+		if !containsString(kinds, kind) {
+			continue
+		}
+		branch := timelineBranches[kind]
+		branches = append(branches, branch.sql)
+		if branch.takesPatients {
+			args = append(args, patientID, institutionID)
+		}
+	}
+
+	var conds []string
+	if !params.FromTime.IsZero() {
+		conds = append(conds, "t.occurred_at >= ?")
+		args = append(args, params.FromTime.UTC())
+	}
+	if !params.ToTime.IsZero() {
+		conds = append(conds, "t.occurred_at <= ?")
+		args = append(args, params.ToTime.UTC())
+	}
+	if cursor != nil {
+		conds = append(conds, "(t.occurred_at, t.kind, t.entry_id) < (?::timestamptz, ?, ?)")
+		args = append(args, cursor.OccurredAt.UTC(), cursor.Kind, cursor.ID)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+
+	sql := `
+		WITH patient_visits AS (
+			SELECT *
+			FROM mdl_trx_patient_visit
+			WHERE id_mst_patient = ?
+			  AND id_mst_institution = ?
+			  AND delete_time IS NULL
+		)
+		SELECT t.*
+		FROM (` + strings.Join(branches, "\n\t\tUNION ALL") + `
+		) t
+		` + where + `
+		ORDER BY t.occurred_at DESC, t.kind DESC, t.entry_id DESC
+		LIMIT ?
+	`
+
+	entries = []model.TimelineEntry{}
+	err = c.readSession(ctx).SQL(sql, args...).Find(&entries)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientTimeline)
+		return
+	}
+
+	return
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
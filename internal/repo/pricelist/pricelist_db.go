@@ -0,0 +1,326 @@
+package pricelist
+
+import (
+	"context"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	pricelistrepo "github.com/faisalhardin/medilink/internal/entity/repo/pricelist"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix                   = "PriceListDB."
+	WrapMsgInsertPriceList             = WrapErrMsgPrefix + "InsertPriceList"
+	WrapMsgGetPriceListByID            = WrapErrMsgPrefix + "GetPriceListByID"
+	WrapMsgListPriceLists              = WrapErrMsgPrefix + "ListPriceLists"
+	WrapMsgGetLatestPriceList          = WrapErrMsgPrefix + "GetLatestPriceList"
+	WrapMsgSetPriceListEffectiveTo     = WrapErrMsgPrefix + "SetPriceListEffectiveTo"
+	WrapMsgReopenPriceListEndingAt     = WrapErrMsgPrefix + "ReopenPriceListEndingAt"
+	WrapMsgDeletePriceList             = WrapErrMsgPrefix + "DeletePriceList"
+	WrapMsgGetPriceListItems           = WrapErrMsgPrefix + "GetPriceListItems"
+	WrapMsgReplacePriceListItems       = WrapErrMsgPrefix + "ReplacePriceListItems"
+	WrapMsgFindApplicablePrices        = WrapErrMsgPrefix + "FindApplicablePrices"
+	WrapMsgFindProductPriceListEntries = WrapErrMsgPrefix + "FindProductPriceListEntries"
+	WrapMsgInsertProductPriceHistory   = WrapErrMsgPrefix + "InsertProductPriceHistory"
+	WrapMsgFindProductPriceHistory     = WrapErrMsgPrefix + "FindProductPriceHistory"
+
+	defaultLimit = 30
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewPriceListDB returns a PriceListDB implementation bound to the xorm connection.
+func NewPriceListDB(db *xormlib.DBConnect) pricelistrepo.PriceListDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) InsertPriceList(ctx context.Context, priceList *model.MstPriceList) error {
+	_, err := c.writeSession(ctx).
+		Table(model.MstPriceListTableName).
+		InsertOne(priceList)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertPriceList)
+	}
+	return nil
+}
+
+func (c *Conn) GetPriceListByID(ctx context.Context, institutionID, priceListID int64) (*model.MstPriceList, error) {
+	var priceList model.MstPriceList
+	found, err := c.readSession(ctx).
+		Table(model.MstPriceListTableName).
+		Where("id = ?", priceListID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&priceList)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetPriceListByID)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &priceList, nil
+}
+
+func (c *Conn) ListPriceLists(ctx context.Context, params model.ListPriceListParams) ([]model.MstPriceList, error) {
+	session := c.DB.SlaveDB.Context(ctx).
+		Table(model.MstPriceListTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution)
+
+	if len(params.PatientCategory) > 0 {
+		session.And("patient_category = ?", params.PatientCategory)
+	}
+	if !params.EffectiveAt.IsZero() {
+		session.And("effective_from <= ?", params.EffectiveAt.UTC()).
+			And("(effective_to IS NULL OR effective_to > ?)", params.EffectiveAt.UTC())
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	offset := params.Offset
+	if params.Page > 0 {
+		offset = limit * (params.Page - 1)
+	}
+
+	priceLists := []model.MstPriceList{}
+	err := session.
+		OrderBy("patient_category ASC, effective_from DESC").
+		Limit(limit, offset).
+		Find(&priceLists)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListPriceLists)
+	}
+	return priceLists, nil
+}
+
+func (c *Conn) GetLatestPriceList(ctx context.Context, institutionID int64, patientCategory string) (*model.MstPriceList, error) {
+	const sql = `
+		SELECT *
+		FROM mdl_mst_price_list
+		WHERE id_mst_institution = ?
+		  AND patient_category = ?
+		  AND delete_time IS NULL
+		ORDER BY effective_from DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var priceLists []model.MstPriceList
+	err := c.writeSession(ctx).SQL(sql, institutionID, patientCategory).Find(&priceLists)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetLatestPriceList)
+	}
+	if len(priceLists) == 0 {
+		return nil, nil
+	}
+	return &priceLists[0], nil
+}
+
+func (c *Conn) SetPriceListEffectiveTo(ctx context.Context, priceListID int64, effectiveTo *time.Time) error {
+	const sql = `
+		UPDATE mdl_mst_price_list
+		SET effective_to = ?, update_time = NOW()
+		WHERE id = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql, effectiveTo, priceListID)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgSetPriceListEffectiveTo)
+	}
+	return nil
+}
+
+func (c *Conn) ReopenPriceListEndingAt(ctx context.Context, institutionID int64, patientCategory string, effectiveTo time.Time) error {
+	const sql = `
+		UPDATE mdl_mst_price_list
+		SET effective_to = NULL, update_time = NOW()
+		WHERE id_mst_institution = ?
+		  AND patient_category = ?
+		  AND effective_to = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql, institutionID, patientCategory, effectiveTo)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgReopenPriceListEndingAt)
+	}
+	return nil
+}
+
+func (c *Conn) DeletePriceList(ctx context.Context, priceListID int64) error {
+	session := c.writeSession(ctx)
+
+	const itemsSQL = `
+		UPDATE mdl_dtl_price_list_item
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_mst_price_list = ?
+		  AND delete_time IS NULL
+	`
+	if _, err := session.Exec(itemsSQL, priceListID); err != nil {
+		return errors.Wrap(err, WrapMsgDeletePriceList)
+	}
+
+	const listSQL = `
+		UPDATE mdl_mst_price_list
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id = ?
+		  AND delete_time IS NULL
+	`
+	if _, err := session.Exec(listSQL, priceListID); err != nil {
+		return errors.Wrap(err, WrapMsgDeletePriceList)
+	}
+	return nil
+}
+
+func (c *Conn) GetPriceListItems(ctx context.Context, priceListID int64) ([]model.PriceListItemRow, error) {
+	const sql = `
+		SELECT mdpli.*, mtip.name
+		FROM mdl_dtl_price_list_item mdpli
+		JOIN mdl_trx_institution_product mtip ON mtip.id = mdpli.id_trx_institution_product
+		WHERE mdpli.id_mst_price_list = ?
+		  AND mdpli.delete_time IS NULL
+		ORDER BY mtip.name ASC, mdpli.id ASC
+	`
+
+	rows := []model.PriceListItemRow{}
+	err := c.readSession(ctx).SQL(sql, priceListID).Find(&rows)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetPriceListItems)
+	}
+	return rows, nil
+}
+
+func (c *Conn) ReplacePriceListItems(ctx context.Context, priceListID int64, items []model.DtlPriceListItem) error {
+	session := c.writeSession(ctx)
+
+	const sql = `
+		UPDATE mdl_dtl_price_list_item
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_mst_price_list = ?
+		  AND delete_time IS NULL
+	`
+	if _, err := session.Exec(sql, priceListID); err != nil {
+		return errors.Wrap(err, WrapMsgReplacePriceListItems)
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := session.
+		Table(model.DtlPriceListItemTableName).
+		Insert(&items)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgReplacePriceListItems)
+	}
+	return nil
+}
+
+func (c *Conn) FindApplicablePrices(ctx context.Context, institutionID int64, patientCategories []string, at time.Time, productIDs []int64) ([]model.ApplicablePrice, error) {
+	prices := []model.ApplicablePrice{}
+	if len(productIDs) == 0 || len(patientCategories) == 0 {
+		return prices, nil
+	}
+
+	const sql = `
+		SELECT mmpl.id AS id_mst_price_list,
+		       mmpl.patient_category,
+		       mdpli.id_trx_institution_product,
+		       mdpli.price
+		FROM mdl_mst_price_list mmpl
+		JOIN mdl_dtl_price_list_item mdpli
+		  ON mdpli.id_mst_price_list = mmpl.id
+		 AND mdpli.delete_time IS NULL
+		WHERE mmpl.id_mst_institution = ?
+		  AND mmpl.patient_category = ANY(?)
+		  AND mmpl.effective_from <= ?
+		  AND (mmpl.effective_to IS NULL OR mmpl.effective_to > ?)
+		  AND mmpl.delete_time IS NULL
+		  AND mdpli.id_trx_institution_product = ANY(?)
+	`
+
+	err := c.readSession(ctx).SQL(sql,
+		institutionID,
+		pq.Array(patientCategories),
+		at.UTC(), at.UTC(),
+		pq.Array(productIDs),
+	).Find(&prices)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgFindApplicablePrices)
+	}
+	return prices, nil
+}
+
+func (c *Conn) FindProductPriceListEntries(ctx context.Context, institutionID, productID int64) ([]model.ProductPriceListEntry, error) {
+	const sql = `
+		SELECT mmpl.id AS id_mst_price_list,
+		       mmpl.name,
+		       mmpl.patient_category,
+		       mmpl.effective_from,
+		       mmpl.effective_to,
+		       mdpli.price
+		FROM mdl_mst_price_list mmpl
+		JOIN mdl_dtl_price_list_item mdpli
+		  ON mdpli.id_mst_price_list = mmpl.id
+		 AND mdpli.delete_time IS NULL
+		WHERE mmpl.id_mst_institution = ?
+		  AND mmpl.delete_time IS NULL
+		  AND mdpli.id_trx_institution_product = ?
+		ORDER BY mmpl.effective_from DESC, mmpl.patient_category ASC
+	`
+
+	entries := []model.ProductPriceListEntry{}
+	err := c.DB.SlaveDB.Context(ctx).SQL(sql, institutionID, productID).Find(&entries)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgFindProductPriceListEntries)
+	}
+	return entries, nil
+}
+
+func (c *Conn) InsertProductPriceHistory(ctx context.Context, history *model.TrxProductPriceHistory) error {
+	_, err := c.writeSession(ctx).
+		Table(model.TrxProductPriceHistoryTableName).
+		InsertOne(history)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertProductPriceHistory)
+	}
+	return nil
+}
+
+func (c *Conn) FindProductPriceHistory(ctx context.Context, institutionID, productID int64) ([]model.TrxProductPriceHistory, error) {
+	history := []model.TrxProductPriceHistory{}
+	err := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxProductPriceHistoryTableName).
+		Where("id_mst_institution = ?", institutionID).
+		And("id_trx_institution_product = ?", productID).
+		OrderBy("create_time DESC, id DESC").
+		Find(&history)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgFindProductPriceHistory)
+	}
+	return history, nil
+}
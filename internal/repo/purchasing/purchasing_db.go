@@ -0,0 +1,339 @@
+package purchasing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant/database"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	purchasingrepo "github.com/faisalhardin/medilink/internal/entity/repo/purchasing"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix                   = "PurchasingDB."
+	WrapMsgInsertSupplier              = WrapErrMsgPrefix + "InsertSupplier"
+	WrapMsgUpdateSupplier              = WrapErrMsgPrefix + "UpdateSupplier"
+	WrapMsgGetSupplierByID             = WrapErrMsgPrefix + "GetSupplierByID"
+	WrapMsgListSuppliers               = WrapErrMsgPrefix + "ListSuppliers"
+	WrapMsgInsertPurchaseOrder         = WrapErrMsgPrefix + "InsertPurchaseOrder"
+	WrapMsgUpdatePurchaseOrder         = WrapErrMsgPrefix + "UpdatePurchaseOrder"
+	WrapMsgGetPurchaseOrderByID        = WrapErrMsgPrefix + "GetPurchaseOrderByID"
+	WrapMsgLockPurchaseOrder           = WrapErrMsgPrefix + "LockPurchaseOrder"
+	WrapMsgListPurchaseOrders          = WrapErrMsgPrefix + "ListPurchaseOrders"
+	WrapMsgInsertPurchaseOrderItems    = WrapErrMsgPrefix + "InsertPurchaseOrderItems"
+	WrapMsgSoftDeletePurchaseOrderItem = WrapErrMsgPrefix + "SoftDeletePurchaseOrderItems"
+	WrapMsgGetPurchaseOrderItems       = WrapErrMsgPrefix + "GetPurchaseOrderItems"
+	WrapMsgAddReceivedQuantity         = WrapErrMsgPrefix + "AddReceivedQuantity"
+
+	defaultLimit = 30
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewPurchasingDB returns a PurchasingDB implementation bound to the xorm connection.
+func NewPurchasingDB(db *xormlib.DBConnect) purchasingrepo.PurchasingDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+func (c *Conn) InsertSupplier(ctx context.Context, supplier *model.MstSupplier) error {
+	_, err := c.writeSession(ctx).
+		Table(model.MstSupplierTableName).
+		InsertOne(supplier)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertSupplier)
+	}
+	return nil
+}
+
+func (c *Conn) UpdateSupplier(ctx context.Context, institutionID int64, req model.UpdateSupplierRequest) (bool, error) {
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.ContactPerson != nil {
+		updates["contact_person"] = *req.ContactPerson
+	}
+	if req.PhoneNumber != nil {
+		updates["phone_number"] = *req.PhoneNumber
+	}
+	if req.Email != nil {
+		updates["email"] = *req.Email
+	}
+	if req.Address != nil {
+		updates["address"] = *req.Address
+	}
+	if req.Notes != nil {
+		updates["notes"] = *req.Notes
+	}
+	if len(updates) == 0 {
+		supplier, err := c.GetSupplierByID(ctx, institutionID, req.ID)
+		if err != nil {
+			return false, errors.Wrap(err, WrapMsgUpdateSupplier)
+		}
+		return supplier != nil, nil
+	}
+
+	affected, err := c.writeSession(ctx).
+		Table(model.MstSupplierTableName).
+		Where("id = ?", req.ID).
+		And("id_mst_institution = ?", institutionID).
+		And("delete_time IS NULL").
+		Update(updates)
+	if err != nil {
+		return false, errors.Wrap(err, WrapMsgUpdateSupplier)
+	}
+	return affected > 0, nil
+}
+
+func (c *Conn) GetSupplierByID(ctx context.Context, institutionID, supplierID int64) (*model.MstSupplier, error) {
+	var supplier model.MstSupplier
+	found, err := c.DB.SlaveDB.Context(ctx).
+		Table(model.MstSupplierTableName).
+		Where("id = ?", supplierID).
+		And("id_mst_institution = ?", institutionID).
+		And("delete_time IS NULL").
+		Get(&supplier)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetSupplierByID)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &supplier, nil
+}
+
+func (c *Conn) ListSuppliers(ctx context.Context, params model.ListSupplierParams) ([]model.MstSupplier, error) {
+	session := c.DB.SlaveDB.Context(ctx).
+		Table(model.MstSupplierTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution)
+
+	if len(params.Name) > 0 {
+		session.And("name ILIKE ?", fmt.Sprintf("%%%s%%", params.Name))
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	offset := params.Offset
+	if params.Page > 0 {
+		offset = limit * (params.Page - 1)
+	}
+
+	suppliers := []model.MstSupplier{}
+	err := session.
+		OrderBy("name ASC").
+		Limit(limit, offset).
+		Find(&suppliers)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListSuppliers)
+	}
+	return suppliers, nil
+}
+
+func (c *Conn) InsertPurchaseOrder(ctx context.Context, order *model.TrxPurchaseOrder) error {
+	_, err := c.writeSession(ctx).
+		Table(model.TrxPurchaseOrderTableName).
+		InsertOne(order)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertPurchaseOrder)
+	}
+	return nil
+}
+
+func (c *Conn) UpdatePurchaseOrder(ctx context.Context, order *model.TrxPurchaseOrder) error {
+	const sql = `
+		UPDATE mdl_trx_purchase_order
+		SET id_mst_supplier = ?,
+		    status          = ?,
+		    notes           = ?,
+		    total_amount    = ?,
+		    ordered_at      = ?,
+		    received_at     = ?,
+		    update_time     = NOW()
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql,
+		order.IDMstSupplier,
+		order.Status,
+		order.Notes,
+		order.TotalAmount,
+		order.OrderedAt,
+		order.ReceivedAt,
+		order.ID,
+		order.IDMstInstitution,
+	)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgUpdatePurchaseOrder)
+	}
+	return nil
+}
+
+func (c *Conn) GetPurchaseOrderByID(ctx context.Context, institutionID, orderID int64) (*model.TrxPurchaseOrderJoinSupplier, error) {
+	var order model.TrxPurchaseOrderJoinSupplier
+	found, err := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxPurchaseOrderTableName).
+		Alias("mtpo").
+		Join(database.SQLLeft, "mdl_mst_supplier mms", "mms.id = mtpo.id_mst_supplier").
+		Select("mtpo.*, mms.name AS supplier_name").
+		Where("mtpo.id = ?", orderID).
+		And("mtpo.id_mst_institution = ?", institutionID).
+		And("mtpo.delete_time IS NULL").
+		Get(&order)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetPurchaseOrderByID)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+func (c *Conn) LockPurchaseOrder(ctx context.Context, institutionID, orderID int64) (*model.TrxPurchaseOrder, error) {
+	const sql = `
+		SELECT *
+		FROM mdl_trx_purchase_order
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND delete_time IS NULL
+		FOR UPDATE
+	`
+
+	var orders []model.TrxPurchaseOrder
+	err := c.writeSession(ctx).SQL(sql, orderID, institutionID).Find(&orders)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgLockPurchaseOrder)
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	return &orders[0], nil
+}
+
+func (c *Conn) ListPurchaseOrders(ctx context.Context, params model.ListPurchaseOrderParams) ([]model.TrxPurchaseOrderJoinSupplier, error) {
+	session := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxPurchaseOrderTableName).
+		Alias("mtpo").
+		Join(database.SQLLeft, "mdl_mst_supplier mms", "mms.id = mtpo.id_mst_supplier").
+		Where("mtpo.id_mst_institution = ?", params.IDMstInstitution).
+		And("mtpo.delete_time IS NULL")
+
+	if len(params.Status) > 0 {
+		session.And("mtpo.status = ?", params.Status)
+	}
+	if params.IDMstSupplier > 0 {
+		session.And("mtpo.id_mst_supplier = ?", params.IDMstSupplier)
+	}
+	if !params.FromTime.IsZero() {
+		session.And("mtpo.create_time >= ?", params.FromTime.UTC())
+	}
+	if !params.ToTime.IsZero() {
+		session.And("mtpo.create_time <= ?", params.ToTime.UTC())
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	offset := params.Offset
+	if params.Page > 0 {
+		offset = limit * (params.Page - 1)
+	}
+
+	orders := []model.TrxPurchaseOrderJoinSupplier{}
+	err := session.
+		Select("mtpo.*, mms.name AS supplier_name").
+		OrderBy("mtpo.id DESC").
+		Limit(limit, offset).
+		Find(&orders)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListPurchaseOrders)
+	}
+	return orders, nil
+}
+
+func (c *Conn) InsertPurchaseOrderItems(ctx context.Context, items []model.DtlPurchaseOrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	_, err := c.writeSession(ctx).
+		Table(model.DtlPurchaseOrderItemTableName).
+		Insert(&items)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertPurchaseOrderItems)
+	}
+	return nil
+}
+
+func (c *Conn) SoftDeletePurchaseOrderItems(ctx context.Context, orderID int64) error {
+	const sql = `
+		UPDATE mdl_dtl_purchase_order_item
+		SET delete_time = NOW(), update_time = NOW()
+		WHERE id_trx_purchase_order = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql, orderID)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgSoftDeletePurchaseOrderItem)
+	}
+	return nil
+}
+
+// GetPurchaseOrderItems reads through the write session when one is active so
+// receipts see lines locked by the surrounding transaction.
+func (c *Conn) GetPurchaseOrderItems(ctx context.Context, orderID int64) ([]model.DtlPurchaseOrderItem, error) {
+	const sql = `
+		SELECT *
+		FROM mdl_dtl_purchase_order_item
+		WHERE id_trx_purchase_order = ?
+		  AND delete_time IS NULL
+		ORDER BY id ASC
+	`
+
+	session := xormlib.GetDBSession(ctx)
+	if session == nil {
+		session = c.DB.SlaveDB.Context(ctx)
+	}
+
+	items := []model.DtlPurchaseOrderItem{}
+	err := session.SQL(sql, orderID).Find(&items)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetPurchaseOrderItems)
+	}
+	return items, nil
+}
+
+func (c *Conn) AddReceivedQuantity(ctx context.Context, itemID, quantity int64) error {
+	const sql = `
+		UPDATE mdl_dtl_purchase_order_item
+		SET received_quantity = received_quantity + ?,
+		    update_time       = NOW()
+		WHERE id = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql, quantity, itemID)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgAddReceivedQuantity)
+	}
+	return nil
+}
@@ -85,7 +85,7 @@ func (c *Conn) GetByID(ctx context.Context, id int64, institutionID int64) (mode
 		Join(database.SQLInner, "mdl_mst_patient_institution mmpi", "mmpi.id = mtr.id_mst_patient and mmpi.delete_time is null").
 		Where("mtr.id = ?", id).
 		And("mtr.id_mst_institution = ?", institutionID).
-		Select("mtr.*, mmpi.uuid, mmpi.name").
+		Select("mtr.*, mmpi.uuid, mmpi.name, mmpi.phone_number").
 		Get(&rec)
 	if err != nil {
 		return model.TrxRecallJoinPatient{}, errors.Wrap(err, wrapMsgGetByID)
@@ -104,7 +104,7 @@ func (c *Conn) GetNextByPatient(ctx context.Context, patientUUID string, institu
 		And("mtr.id_mst_institution = ?", institutionID).
 		And("mtr.scheduled_at > ?", time.Now()).
 		Asc("mtr.scheduled_at").
-		Select("mtr.*, mmpi.uuid, mmpi.name").
+		Select("mtr.*, mmpi.uuid, mmpi.name, mmpi.phone_number").
 		Limit(1).
 		Get(&rec)
 	if err != nil {
@@ -144,7 +144,7 @@ func (c *Conn) ListUpcoming(ctx context.Context, params model.GetRecallParams, i
 		limit = 50
 	}
 	session = session.Limit(limit, params.Offset).
-		Select("mtr.*, mmpi.uuid, mmpi.name")
+		Select("mtr.*, mmpi.uuid, mmpi.name, mmpi.phone_number")
 
 	var list []model.TrxRecallJoinPatient
 	err := session.Find(&list)
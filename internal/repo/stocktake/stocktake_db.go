@@ -0,0 +1,269 @@
+package stocktake
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	stocktakerepo "github.com/faisalhardin/medilink/internal/entity/repo/stocktake"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/go-xorm/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	WrapErrMsgPrefix                 = "StockTakeDB."
+	WrapMsgInsertStockTake           = WrapErrMsgPrefix + "InsertStockTake"
+	WrapMsgSnapshotStockTakeItems    = WrapErrMsgPrefix + "SnapshotStockTakeItems"
+	WrapMsgUpdateStockTake           = WrapErrMsgPrefix + "UpdateStockTake"
+	WrapMsgGetStockTakeByID          = WrapErrMsgPrefix + "GetStockTakeByID"
+	WrapMsgLockStockTake             = WrapErrMsgPrefix + "LockStockTake"
+	WrapMsgListStockTakes            = WrapErrMsgPrefix + "ListStockTakes"
+	WrapMsgGetStockTakeItems         = WrapErrMsgPrefix + "GetStockTakeItems"
+	WrapMsgGetStockTakeCounts        = WrapErrMsgPrefix + "GetStockTakeCounts"
+	WrapMsgUpsertStockTakeCounts     = WrapErrMsgPrefix + "UpsertStockTakeCounts"
+	WrapMsgUpdateStockTakeItemReason = WrapErrMsgPrefix + "UpdateStockTakeItemReason"
+
+	defaultLimit = 30
+)
+
+type Conn struct {
+	DB *xormlib.DBConnect
+}
+
+// NewStockTakeDB returns a StockTakeDB implementation bound to the xorm connection.
+func NewStockTakeDB(db *xormlib.DBConnect) stocktakerepo.StockTakeDB {
+	return &Conn{DB: db}
+}
+
+// writeSession returns the active TX session if one was put on ctx by the
+// usecase; otherwise returns a fresh master-engine session.
+func (c *Conn) writeSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.MasterDB.Context(ctx)
+}
+
+// readSession prefers the active TX session so reads inside a transaction see
+// its own writes and locks.
+func (c *Conn) readSession(ctx context.Context) *xorm.Session {
+	if s := xormlib.GetDBSession(ctx); s != nil {
+		return s
+	}
+	return c.DB.SlaveDB.Context(ctx)
+}
+
+func (c *Conn) InsertStockTake(ctx context.Context, stockTake *model.TrxStockTake) error {
+	_, err := c.writeSession(ctx).
+		Table(model.TrxStockTakeTableName).
+		InsertOne(stockTake)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgInsertStockTake)
+	}
+	return nil
+}
+
+func (c *Conn) SnapshotStockTakeItems(ctx context.Context, institutionID, locationID, stockTakeID int64) (int64, error) {
+	const sql = `
+		INSERT INTO mdl_dtl_stock_take_item
+			(id_trx_stock_take, id_trx_institution_product, name, unit_type, system_quantity, unit_cost)
+		SELECT ?, mtip.id, mtip.name, mdips.unit_type, COALESCE(mdls.quantity, 0), mdips.avg_cost
+		FROM mdl_trx_institution_product mtip
+		JOIN mdl_dtl_institution_product_stock mdips
+		  ON mdips.id_trx_institution_product = mtip.id
+		 AND mdips.delete_time IS NULL
+		LEFT JOIN mdl_dtl_location_stock mdls
+		  ON mdls.id_trx_institution_product = mtip.id
+		 AND mdls.id_mst_stock_location = ?
+		WHERE mtip.id_mst_institution = ?
+		  AND mtip.is_item = TRUE
+		  AND mtip.delete_time IS NULL
+		ORDER BY mtip.name ASC
+	`
+
+	res, err := c.writeSession(ctx).Exec(sql, stockTakeID, locationID, institutionID)
+	if err != nil {
+		return 0, errors.Wrap(err, WrapMsgSnapshotStockTakeItems)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, WrapMsgSnapshotStockTakeItems)
+	}
+	return affected, nil
+}
+
+func (c *Conn) UpdateStockTake(ctx context.Context, stockTake *model.TrxStockTake) error {
+	const sql = `
+		UPDATE mdl_trx_stock_take
+		SET status          = ?,
+		    finalize_reason = ?,
+		    finalized_by    = ?,
+		    finalized_at    = ?,
+		    update_time     = NOW()
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND delete_time IS NULL
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql,
+		stockTake.Status,
+		stockTake.FinalizeReason,
+		stockTake.FinalizedBy,
+		stockTake.FinalizedAt,
+		stockTake.ID,
+		stockTake.IDMstInstitution,
+	)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgUpdateStockTake)
+	}
+	return nil
+}
+
+func (c *Conn) GetStockTakeByID(ctx context.Context, institutionID, stockTakeID int64) (*model.TrxStockTake, error) {
+	var stockTake model.TrxStockTake
+	found, err := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxStockTakeTableName).
+		Where("id = ?", stockTakeID).
+		And("id_mst_institution = ?", institutionID).
+		Get(&stockTake)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetStockTakeByID)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &stockTake, nil
+}
+
+func (c *Conn) LockStockTake(ctx context.Context, institutionID, stockTakeID int64) (*model.TrxStockTake, error) {
+	const sql = `
+		SELECT *
+		FROM mdl_trx_stock_take
+		WHERE id = ?
+		  AND id_mst_institution = ?
+		  AND delete_time IS NULL
+		FOR UPDATE
+	`
+
+	var stockTakes []model.TrxStockTake
+	err := c.writeSession(ctx).SQL(sql, stockTakeID, institutionID).Find(&stockTakes)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgLockStockTake)
+	}
+	if len(stockTakes) == 0 {
+		return nil, nil
+	}
+	return &stockTakes[0], nil
+}
+
+func (c *Conn) ListStockTakes(ctx context.Context, params model.ListStockTakeParams) ([]model.TrxStockTake, error) {
+	session := c.DB.SlaveDB.Context(ctx).
+		Table(model.TrxStockTakeTableName).
+		Where("id_mst_institution = ?", params.IDMstInstitution)
+
+	if len(params.Status) > 0 {
+		session.And("status = ?", params.Status)
+	}
+	if params.IDMstStockLocation > 0 {
+		session.And("id_mst_stock_location = ?", params.IDMstStockLocation)
+	}
+	if !params.FromTime.IsZero() {
+		session.And("create_time >= ?", params.FromTime.UTC())
+	}
+	if !params.ToTime.IsZero() {
+		session.And("create_time <= ?", params.ToTime.UTC())
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	offset := params.Offset
+	if params.Page > 0 {
+		offset = limit * (params.Page - 1)
+	}
+
+	stockTakes := []model.TrxStockTake{}
+	err := session.
+		OrderBy("id DESC").
+		Limit(limit, offset).
+		Find(&stockTakes)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgListStockTakes)
+	}
+	return stockTakes, nil
+}
+
+func (c *Conn) GetStockTakeItems(ctx context.Context, stockTakeID int64) ([]model.StockTakeItemRow, error) {
+	const sql = `
+		SELECT mdsti.*,
+		       counts.counted_quantity,
+		       COALESCE(counts.counter_total, 0) AS counter_total
+		FROM mdl_dtl_stock_take_item mdsti
+		LEFT JOIN (
+			SELECT id_dtl_stock_take_item,
+			       SUM(counted_quantity) AS counted_quantity,
+			       COUNT(*)              AS counter_total
+			FROM mdl_dtl_stock_take_count
+			GROUP BY id_dtl_stock_take_item
+		) counts ON counts.id_dtl_stock_take_item = mdsti.id
+		WHERE mdsti.id_trx_stock_take = ?
+		ORDER BY mdsti.name ASC, mdsti.id ASC
+	`
+
+	rows := []model.StockTakeItemRow{}
+	err := c.readSession(ctx).SQL(sql, stockTakeID).Find(&rows)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetStockTakeItems)
+	}
+	return rows, nil
+}
+
+func (c *Conn) GetStockTakeCounts(ctx context.Context, stockTakeID int64) ([]model.DtlStockTakeCount, error) {
+	const sql = `
+		SELECT mdstc.*
+		FROM mdl_dtl_stock_take_count mdstc
+		JOIN mdl_dtl_stock_take_item mdsti ON mdsti.id = mdstc.id_dtl_stock_take_item
+		WHERE mdsti.id_trx_stock_take = ?
+		ORDER BY mdstc.id_dtl_stock_take_item ASC, mdstc.counted_by ASC
+	`
+
+	counts := []model.DtlStockTakeCount{}
+	err := c.readSession(ctx).SQL(sql, stockTakeID).Find(&counts)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgGetStockTakeCounts)
+	}
+	return counts, nil
+}
+
+func (c *Conn) UpsertStockTakeCounts(ctx context.Context, counts []model.DtlStockTakeCount) error {
+	const sql = `
+		INSERT INTO mdl_dtl_stock_take_count (id_dtl_stock_take_item, counted_by, counted_quantity)
+		VALUES (?, ?, ?)
+		ON CONFLICT (id_dtl_stock_take_item, counted_by)
+		DO UPDATE SET counted_quantity = EXCLUDED.counted_quantity,
+		              update_time      = NOW()
+	`
+
+	session := c.writeSession(ctx)
+	for _, count := range counts {
+		if _, err := session.Exec(sql, count.IDDtlStockTakeItem, count.CountedBy, count.CountedQuantity); err != nil {
+			return errors.Wrap(err, WrapMsgUpsertStockTakeCounts)
+		}
+	}
+	return nil
+}
+
+func (c *Conn) UpdateStockTakeItemReason(ctx context.Context, itemID int64, reason string) error {
+	const sql = `
+		UPDATE mdl_dtl_stock_take_item
+		SET adjustment_reason = ?, update_time = NOW()
+		WHERE id = ?
+	`
+
+	_, err := c.writeSession(ctx).Exec(sql, reason, itemID)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgUpdateStockTakeItemReason)
+	}
+	return nil
+}
@@ -25,6 +25,14 @@ func RegisterRoutes(m *module) http.Handler {
 			authed.Route("/institution", func(institution chi.Router) {
 				institution.Post("/", m.httpHandler.InstitutionHandler.InsertNewInstitution)
 				institution.Get("/", m.httpHandler.InstitutionHandler.GetUserInstitution)
+				institution.With(m.middlewareModule.RequirePermission(permconst.TaxConfigRead)).
+					Get("/tax-config", m.httpHandler.InstitutionHandler.GetTaxConfig)
+				institution.With(m.middlewareModule.RequirePermission(permconst.TaxConfigUpdate)).
+					Put("/tax-config", m.httpHandler.InstitutionHandler.UpdateTaxConfig)
+				institution.With(m.middlewareModule.RequirePermission(permconst.MRNConfigRead)).
+					Get("/mrn-config", m.httpHandler.InstitutionHandler.GetMRNConfig)
+				institution.With(m.middlewareModule.RequirePermission(permconst.MRNConfigUpdate)).
+					Put("/mrn-config", m.httpHandler.InstitutionHandler.UpdateMRNConfig)
 				institution.Route("/product", func(product chi.Router) {
 					product.Get("/", m.httpHandler.InstitutionHandler.FindInstitutionProducts)
 					product.With(m.middlewareModule.RequirePermission(permconst.ProductStatistics)).
@@ -32,17 +40,203 @@ func RegisterRoutes(m *module) http.Handler {
 					product.Post("/", m.httpHandler.InstitutionHandler.InsertInstitutionProduct)
 					product.Patch("/", m.httpHandler.InstitutionHandler.UpdateInstitutionProduct)
 					product.Post("/resupply", m.httpHandler.InstitutionHandler.UpdateInstitutionProductStock)
+					product.With(
+						m.middlewareModule.RequirePermission(permconst.ProductCreate),
+						m.middlewareModule.RequirePermission(permconst.ProductUpdate),
+					).Post("/import", m.httpHandler.InstitutionHandler.ImportProducts)
+					product.With(m.middlewareModule.RequirePermission(permconst.ProductRead)).
+						Get("/export", m.httpHandler.InstitutionHandler.ExportProducts)
+					product.Get("/unit", m.httpHandler.InstitutionHandler.GetProductUnits)
+					product.Put("/unit", m.httpHandler.InstitutionHandler.SaveProductUnits)
+					product.Get("/material", m.httpHandler.InstitutionHandler.GetTreatmentMaterials)
+					product.Put("/material", m.httpHandler.InstitutionHandler.SaveTreatmentMaterials)
+					product.With(m.middlewareModule.RequirePermission(permconst.ProductRead)).
+						Get("/price-history", m.httpHandler.PriceListHandler.GetProductPriceHistory)
+				})
+				institution.Route("/stock-location", func(location chi.Router) {
+					location.With(m.middlewareModule.RequirePermission(permconst.StockLocationRead)).
+						Get("/", m.httpHandler.InstitutionHandler.ListStockLocations)
+					location.With(m.middlewareModule.RequirePermission(permconst.StockLocationUpdate)).
+						Post("/", m.httpHandler.InstitutionHandler.CreateStockLocation)
+					location.With(m.middlewareModule.RequirePermission(permconst.StockLocationUpdate)).
+						Patch("/", m.httpHandler.InstitutionHandler.UpdateStockLocation)
+					location.With(m.middlewareModule.RequirePermission(permconst.StockLocationRead)).
+						Get("/stock", m.httpHandler.InstitutionHandler.ListLocationStock)
+					location.With(m.middlewareModule.RequirePermission(permconst.StockLocationRead)).
+						Get("/low-stock", m.httpHandler.InstitutionHandler.GetLowStockReport)
+					location.With(m.middlewareModule.RequirePermission(permconst.StockLocationUpdate)).
+						Put("/{id}/reorder-level", m.httpHandler.InstitutionHandler.SaveReorderLevels)
+				})
+				institution.Route("/stock-transfer", func(transfer chi.Router) {
+					transfer.With(m.middlewareModule.RequirePermission(permconst.StockLocationRead)).
+						Get("/", m.httpHandler.InstitutionHandler.ListStockTransfers)
+					transfer.With(m.middlewareModule.RequirePermission(permconst.StockLocationTransfer)).
+						Post("/", m.httpHandler.InstitutionHandler.CreateStockTransfer)
+					transfer.With(m.middlewareModule.RequirePermission(permconst.StockLocationRead)).
+						Get("/{id}", m.httpHandler.InstitutionHandler.GetStockTransfer)
+				})
+				institution.Route("/supplier", func(supplier chi.Router) {
+					supplier.With(m.middlewareModule.RequirePermission(permconst.PurchasingRead)).
+						Get("/", m.httpHandler.PurchasingHandler.ListSuppliers)
+					supplier.With(m.middlewareModule.RequirePermission(permconst.PurchasingCreate)).
+						Post("/", m.httpHandler.PurchasingHandler.CreateSupplier)
+					supplier.With(m.middlewareModule.RequirePermission(permconst.PurchasingUpdate)).
+						Patch("/", m.httpHandler.PurchasingHandler.UpdateSupplier)
+				})
+				institution.Route("/purchase-order", func(order chi.Router) {
+					order.With(m.middlewareModule.RequirePermission(permconst.PurchasingRead)).
+						Get("/", m.httpHandler.PurchasingHandler.ListPurchaseOrders)
+					order.With(m.middlewareModule.RequirePermission(permconst.PurchasingCreate)).
+						Post("/", m.httpHandler.PurchasingHandler.CreatePurchaseOrder)
+					order.Route("/{id}", func(order chi.Router) {
+						order.With(m.middlewareModule.RequirePermission(permconst.PurchasingRead)).
+							Get("/", m.httpHandler.PurchasingHandler.GetPurchaseOrder)
+						order.With(m.middlewareModule.RequirePermission(permconst.PurchasingUpdate)).
+							Patch("/", m.httpHandler.PurchasingHandler.UpdatePurchaseOrder)
+						order.With(m.middlewareModule.RequirePermission(permconst.PurchasingUpdate)).
+							Post("/order", m.httpHandler.PurchasingHandler.OrderPurchaseOrder)
+						order.With(m.middlewareModule.RequirePermission(permconst.PurchasingUpdate)).
+							Post("/cancel", m.httpHandler.PurchasingHandler.CancelPurchaseOrder)
+						order.With(m.middlewareModule.RequirePermission(permconst.PurchasingReceive)).
+							Post("/receive", m.httpHandler.PurchasingHandler.ReceivePurchaseOrder)
+					})
+				})
+				institution.Route("/stock-take", func(stockTake chi.Router) {
+					stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeRead)).
+						Get("/", m.httpHandler.StockTakeHandler.ListStockTakes)
+					stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeCreate)).
+						Post("/", m.httpHandler.StockTakeHandler.CreateStockTake)
+					stockTake.Route("/{id}", func(stockTake chi.Router) {
+						stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeRead)).
+							Get("/", m.httpHandler.StockTakeHandler.GetStockTake)
+						stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeRead)).
+							Get("/variance-report", m.httpHandler.StockTakeHandler.DownloadVarianceReport)
+						stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeCount)).
+							Post("/count", m.httpHandler.StockTakeHandler.SaveCounts)
+						stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeFinalize)).
+							Post("/finalize", m.httpHandler.StockTakeHandler.FinalizeStockTake)
+						stockTake.With(m.middlewareModule.RequirePermission(permconst.StockTakeCreate)).
+							Post("/cancel", m.httpHandler.StockTakeHandler.CancelStockTake)
+					})
+				})
+				institution.Route("/price-list", func(priceList chi.Router) {
+					priceList.With(m.middlewareModule.RequirePermission(permconst.ProductRead)).
+						Get("/", m.httpHandler.PriceListHandler.ListPriceLists)
+					priceList.With(m.middlewareModule.RequirePermission(permconst.ProductUpdate)).
+						Post("/", m.httpHandler.PriceListHandler.CreatePriceList)
+					priceList.Route("/{id}", func(priceList chi.Router) {
+						priceList.With(m.middlewareModule.RequirePermission(permconst.ProductRead)).
+							Get("/", m.httpHandler.PriceListHandler.GetPriceList)
+						priceList.With(m.middlewareModule.RequirePermission(permconst.ProductUpdate)).
+							Put("/items", m.httpHandler.PriceListHandler.UpdatePriceListItems)
+						priceList.With(m.middlewareModule.RequirePermission(permconst.ProductUpdate)).
+							Delete("/", m.httpHandler.PriceListHandler.DeletePriceList)
+					})
+				})
+				institution.Route("/discount-policy", func(policy chi.Router) {
+					policy.With(m.middlewareModule.RequirePermission(permconst.DiscountPolicyRead)).
+						Get("/", m.httpHandler.DiscountHandler.ListPolicies)
+					policy.With(m.middlewareModule.RequirePermission(permconst.DiscountPolicyUpdate)).
+						Put("/", m.httpHandler.DiscountHandler.SavePolicies)
+				})
+				institution.Route("/doctor-fee-rule", func(rule chi.Router) {
+					rule.With(m.middlewareModule.RequirePermission(permconst.DoctorFeeRead)).
+						Get("/", m.httpHandler.DoctorFeeHandler.ListRules)
+					rule.With(m.middlewareModule.RequirePermission(permconst.DoctorFeeUpdate)).
+						Put("/", m.httpHandler.DoctorFeeHandler.SaveRules)
+				})
+				institution.Route("/report", func(report chi.Router) {
+					report.With(m.middlewareModule.RequirePermission(permconst.DoctorFeeRead)).
+						Get("/doctor-fees", m.httpHandler.DoctorFeeHandler.GetReport)
+					report.With(m.middlewareModule.RequirePermission(permconst.DoctorFeeRead)).
+						Get("/doctor-fees/export", m.httpHandler.DoctorFeeHandler.DownloadReport)
+				})
+				institution.Route("/consent-template", func(template chi.Router) {
+					template.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/", m.httpHandler.ConsentHandler.ListTemplates)
+					template.With(m.middlewareModule.RequirePermission(permconst.ConsentTemplateUpdate)).
+						Put("/", m.httpHandler.ConsentHandler.SaveTemplate)
+					template.With(m.middlewareModule.RequirePermission(permconst.ConsentTemplateUpdate)).
+						Delete("/{id}", m.httpHandler.ConsentHandler.RetireTemplate)
+				})
+				institution.Route("/discount-approval", func(approval chi.Router) {
+					approval.With(m.middlewareModule.RequirePermission(permconst.DiscountApprove)).
+						Get("/", m.httpHandler.DiscountHandler.ListApprovals)
+					approval.Route("/{id}", func(approval chi.Router) {
+						approval.With(m.middlewareModule.RequirePermission(permconst.DiscountApprove)).
+							Post("/approve", m.httpHandler.DiscountHandler.ApproveRequest)
+						approval.With(m.middlewareModule.RequirePermission(permconst.DiscountApprove)).
+							Post("/reject", m.httpHandler.DiscountHandler.RejectRequest)
+					})
 				})
 			})
 			authed.Route("/patient", func(patient chi.Router) {
 				patient.Post("/", m.httpHandler.PatientHandler.RegisterNewPatient)
 				patient.Put("/", m.httpHandler.PatientHandler.UpdatePatient)
 				patient.Get("/", m.httpHandler.PatientHandler.ListPatient)
+				patient.Route("/merge", func(merge chi.Router) {
+					merge.With(m.middlewareModule.RequirePermission(permconst.PatientMerge)).
+						Get("/", m.httpHandler.PatientHandler.ListPatientMerges)
+					merge.With(m.middlewareModule.RequirePermission(permconst.PatientMerge)).
+						Post("/", m.httpHandler.PatientHandler.MergePatients)
+					merge.With(m.middlewareModule.RequirePermission(permconst.PatientMerge)).
+						Post("/{id}/undo", m.httpHandler.PatientHandler.UndoPatientMerge)
+				})
+				patient.Route("/import", func(patientImport chi.Router) {
+					patientImport.With(m.middlewareModule.RequirePermission(permconst.PatientImport)).
+						Get("/", m.httpHandler.PatientHandler.ListPatientImports)
+					patientImport.With(m.middlewareModule.RequirePermission(permconst.PatientImport)).
+						Post("/", m.httpHandler.PatientHandler.ImportPatients)
+					patientImport.With(m.middlewareModule.RequirePermission(permconst.PatientImport)).
+						Get("/{id}", m.httpHandler.PatientHandler.GetPatientImport)
+					patientImport.With(m.middlewareModule.RequirePermission(permconst.PatientImport)).
+						Get("/{id}/report", m.httpHandler.PatientHandler.DownloadPatientImportReport)
+				})
 				patient.Route("/{uuid}", func(patient chi.Router) {
 					patient.Get("/", m.httpHandler.PatientHandler.GetPatient)
+					patient.Get("/duplicates", m.httpHandler.PatientHandler.ListPatientDuplicates)
 					patient.Get("/visit", m.httpHandler.PatientHandler.ListPatientVisitsByPatientUUID)
+					patient.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/timeline", m.httpHandler.PatientHandler.GetPatientTimeline)
+					patient.Route("/relation", func(relation chi.Router) {
+						relation.Get("/", m.httpHandler.PatientHandler.ListPatientRelations)
+						relation.Post("/", m.httpHandler.PatientHandler.SavePatientRelation)
+						relation.Put("/{id}", m.httpHandler.PatientHandler.SavePatientRelation)
+						relation.Delete("/{id}", m.httpHandler.PatientHandler.DeletePatientRelation)
+					})
+					patient.Route("/payer", func(payer chi.Router) {
+						payer.Get("/", m.httpHandler.PatientHandler.ListPatientPayers)
+						payer.With(m.middlewareModule.RequirePermission(permconst.PatientPayerUpdate)).
+							Post("/", m.httpHandler.PatientHandler.SavePatientPayer)
+						payer.With(m.middlewareModule.RequirePermission(permconst.PatientPayerUpdate)).
+							Put("/{id}", m.httpHandler.PatientHandler.SavePatientPayer)
+						payer.With(m.middlewareModule.RequirePermission(permconst.PatientPayerUpdate)).
+							Delete("/{id}", m.httpHandler.PatientHandler.DeletePatientPayer)
+						payer.With(m.middlewareModule.RequirePermission(permconst.PatientPayerUpdate)).
+							Post("/{id}/eligibility", m.httpHandler.PatientHandler.CheckPayerEligibility)
+					})
+					patient.With(m.middlewareModule.RequirePermission(permconst.AttachmentRead)).
+						Get("/attachment", m.httpHandler.AttachmentHandler.ListAttachments)
+					patient.With(m.middlewareModule.RequirePermission(permconst.AttachmentCreate)).
+						Post("/attachment", m.httpHandler.AttachmentHandler.UploadAttachment)
 					patient.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
 						Get("/procedure/history", m.httpHandler.ProcedureHandler.GetPatientHistory)
+					patient.With(m.middlewareModule.RequirePermission(permconst.PatientDataExport)).
+						Get("/data-export", m.httpHandler.PatientHandler.ExportPatientData)
+					patient.With(m.middlewareModule.RequirePermission(permconst.PatientAnonymise)).
+						Post("/anonymise", m.httpHandler.PatientHandler.AnonymisePatient)
+					patient.Route("/account", func(account chi.Router) {
+						account.With(m.middlewareModule.RequirePermission(permconst.PatientAccountRead)).
+							Get("/", m.httpHandler.BillingHandler.GetPatientAccount)
+						account.With(m.middlewareModule.RequirePermission(permconst.PatientAccountDeposit)).
+							Post("/deposit", m.httpHandler.BillingHandler.RecordDeposit)
+						account.With(m.middlewareModule.RequirePermission(permconst.RefundCreate)).
+							Post("/refund", m.httpHandler.BillingHandler.RefundPatientCredit)
+						account.With(m.middlewareModule.RequirePermission(permconst.PatientAccountRead)).
+							Get("/statement", m.httpHandler.BillingHandler.GetPatientStatement)
+						account.With(m.middlewareModule.RequirePermission(permconst.PatientAccountRead)).
+							Get("/statement/export", m.httpHandler.BillingHandler.DownloadPatientStatement)
+					})
 				})
 			})
 
@@ -56,6 +250,10 @@ func RegisterRoutes(m *module) http.Handler {
 					visit.Patch("/", m.httpHandler.PatientHandler.UpdatePatientVisit)
 					visit.Get("/", m.httpHandler.PatientHandler.GetPatientVisits)
 					visit.Get("/detail", m.httpHandler.PatientHandler.ListVisitTouchpoints)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/invoice", m.httpHandler.PatientHandler.GetVisitInvoice)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitUpdate)).
+						Put("/payer", m.httpHandler.PatientHandler.SelectVisitPayer)
 					visit.Get("/diagnosis", m.httpHandler.DiagnosisHandler.GetByVisitID)
 					visit.Post("/diagnosis", m.httpHandler.DiagnosisHandler.Save)
 					visit.Delete("/diagnosis/{diagnosis_id}", m.httpHandler.DiagnosisHandler.Delete)
@@ -67,6 +265,26 @@ func RegisterRoutes(m *module) http.Handler {
 						Post("/procedure", m.httpHandler.ProcedureHandler.Save)
 					visit.With(m.middlewareModule.RequirePermission(permconst.VisitUpdate)).
 						Delete("/procedure/{procedure_id}", m.httpHandler.ProcedureHandler.Delete)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/consent", m.httpHandler.ConsentHandler.ListVisitConsents)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/consent/preview", m.httpHandler.ConsentHandler.PreviewConsent)
+					visit.With(m.middlewareModule.RequirePermission(permconst.ConsentCreate)).
+						Post("/consent", m.httpHandler.ConsentHandler.CreateConsent)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitUpdate)).
+						Post("/discount-approval", m.httpHandler.DiscountHandler.RequestApproval)
+					visit.With(m.middlewareModule.RequirePermission(permconst.DiscountAuditRead)).
+						Get("/price-audit", m.httpHandler.DiscountHandler.ListPriceAudit)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/billing", m.httpHandler.BillingHandler.GetVisitBilling)
+					visit.With(m.middlewareModule.RequirePermission(permconst.PaymentCreate)).
+						Post("/payment", m.httpHandler.BillingHandler.RecordPayment)
+					visit.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+						Get("/refund", m.httpHandler.BillingHandler.ListRefunds)
+					visit.With(m.middlewareModule.RequirePermission(permconst.RefundCreate)).
+						Post("/refund", m.httpHandler.BillingHandler.CreateRefund)
+					visit.With(m.middlewareModule.RequirePermission(permconst.PaymentCreate)).
+						Post("/apply-deposit", m.httpHandler.BillingHandler.ApplyDeposit)
 				})
 				visit.Get("/product", m.httpHandler.PatientHandler.ListVisitProducts)
 				visit.Post("/product", m.httpHandler.PatientHandler.InsertVisitProduct)
@@ -128,6 +346,16 @@ func RegisterRoutes(m *module) http.Handler {
 					Patch("/{uuid}/activate", m.httpHandler.StaffHandler.ActivateStaff)
 			})
 
+			authed.Route("/attachment/{id}", func(attachment chi.Router) {
+				attachment.With(m.middlewareModule.RequirePermission(permconst.AttachmentRead)).
+					Get("/", m.httpHandler.AttachmentHandler.GetAttachment)
+				attachment.With(m.middlewareModule.RequirePermission(permconst.AttachmentDelete)).
+					Delete("/", m.httpHandler.AttachmentHandler.DeleteAttachment)
+			})
+
+			authed.With(m.middlewareModule.RequirePermission(permconst.VisitRead)).
+				Get("/consent/{id}/signature", m.httpHandler.ConsentHandler.GetSignature)
+
 			// Recall: doctor reminder for next scheduled control or appointment
 			authed.Route("/recall", func(recall chi.Router) {
 				recall.Post("/", m.httpHandler.RecallHandler.CreateRecall)
@@ -145,6 +373,9 @@ func RegisterRoutes(m *module) http.Handler {
 				Get("/icd9cm/search", m.httpHandler.ProcedureHandler.SearchICD9CM)
 		})
 
+		// Signed, time-limited attachment links; the signature replaces the session.
+		v1.Get("/attachment/{id}/content", m.httpHandler.AttachmentHandler.DownloadAttachment)
+
 		v1.Route("/auth", func(auth chi.Router) {
 
 			auth.Get("/{provider}/callback", m.httpHandler.AuthHandler.GetAuthCallbackFunction)
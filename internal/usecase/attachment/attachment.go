@@ -0,0 +1,362 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	attachmentrepo "github.com/faisalhardin/medilink/internal/entity/repo/attachment"
+	patientrepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/imaging"
+	"github.com/faisalhardin/medilink/internal/library/common/log"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/internal/library/storage"
+	"github.com/faisalhardin/medilink/internal/library/util/signedurl"
+)
+
+const (
+	wrapMsgUploadAttachment = "AttachmentUC.UploadAttachment"
+	wrapMsgListAttachments  = "AttachmentUC.ListAttachments"
+	wrapMsgGetAttachment    = "AttachmentUC.GetAttachment"
+	wrapMsgDeleteAttachment = "AttachmentUC.DeleteAttachment"
+	wrapMsgOpenAttachment   = "AttachmentUC.OpenAttachment"
+	wrapMsgResponse         = "AttachmentUC.response"
+
+	maxFileNameLength = 255
+)
+
+type AttachmentUC struct {
+	AttachmentDB attachmentrepo.AttachmentDB
+	PatientDB    patientrepo.PatientDB
+	Storage      storage.Storage
+	// Signer signs the download links served by the API; nil disables them
+	// when no signing key is configured.
+	Signer *signedurl.Signer
+	// BaseURL prefixes the download links served by the API, e.g.
+	// https://api.example.com; empty keeps them relative.
+	BaseURL string
+	URLTTL  time.Duration
+}
+
+func NewAttachmentUC(u *AttachmentUC) *AttachmentUC {
+	if u.URLTTL <= 0 {
+		u.URLTTL = model.DefaultAttachmentURLTTL
+	}
+	return u
+}
+
+// UploadAttachment stores the file for the patient, with a thumbnail when it
+// is an image. The file type is taken from the content, not the file name.
+func (u *AttachmentUC) UploadAttachment(ctx context.Context, req model.UploadAttachmentRequest) (model.AttachmentResponse, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.AttachmentResponse{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	contentType := http.DetectContentType(req.Content)
+	ext, accepted := model.AttachmentContentTypes[contentType]
+
+	errMsg := commonerr.NewErrorMessage()
+	if len(req.Content) == 0 {
+		errMsg.Append("file", "file is empty")
+	} else if !accepted {
+		errMsg.Append("file", fmt.Sprintf("files of type %s are not accepted; upload a JPEG, PNG, GIF or WebP image or a PDF", contentType))
+	}
+	if req.ToothID != "" && !constant.ValidToothNumbers[req.ToothID] {
+		errMsg.Append("tooth_id", fmt.Sprintf("%s is not a valid FDI tooth number", req.ToothID))
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return model.AttachmentResponse{}, errMsg
+	}
+
+	patient, err := u.PatientDB.GetPatientByParams(ctx, model.MstPatientInstitution{
+		UUID:          req.PatientUUID,
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgUploadAttachment)
+	}
+	if patient.ID == 0 {
+		return model.AttachmentResponse{}, commonerr.SetNewBadRequest("patient not found",
+			fmt.Sprintf("no patient with uuid %s in this institution", req.PatientUUID))
+	}
+
+	if req.VisitID > 0 {
+		visit, err := u.PatientDB.GetPatientVisitsByID(ctx, req.VisitID)
+		if err != nil {
+			return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgUploadAttachment)
+		}
+		if visit.ID == 0 || visit.IDMstPatient != patient.ID || visit.IDMstInstitution != userDetail.InstitutionID {
+			return model.AttachmentResponse{}, commonerr.SetNewBadRequest("visit not found",
+				fmt.Sprintf("patient %s has no visit %d", req.PatientUUID, req.VisitID))
+		}
+	}
+
+	var thumbnail []byte
+	if model.HasAttachmentThumbnail(contentType) {
+		thumbnail, err = imaging.Thumbnail(req.Content, model.AttachmentThumbnailMaxSide)
+		if err != nil {
+			return model.AttachmentResponse{}, commonerr.SetNewUnprocessableEntityError("file", "the image could not be read")
+		}
+	}
+
+	sum := sha256.Sum256(req.Content)
+	attachment := model.TrxPatientAttachment{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+		Category:         req.Category,
+		Description:      strings.TrimSpace(req.Description),
+		FileName:         attachmentFileName(req.FileName, ext),
+		ContentType:      contentType,
+		SizeBytes:        int64(len(req.Content)),
+		Checksum:         hex.EncodeToString(sum[:]),
+		StorageKey:       model.AttachmentStorageKey(userDetail.InstitutionID, patient.UUID, uuid.NewString(), ext),
+		UploadedBy:       userDetail.Email,
+	}
+	if req.VisitID > 0 {
+		attachment.IDTrxPatientVisit = null.Int64From(req.VisitID)
+	}
+	if req.ToothID != "" {
+		attachment.ToothID = null.StringFrom(req.ToothID)
+	}
+
+	if err = u.Storage.Put(ctx, attachment.StorageKey, bytes.NewReader(req.Content)); err != nil {
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgUploadAttachment)
+	}
+	if thumbnail != nil {
+		attachment.ThumbnailKey = null.StringFrom(model.AttachmentThumbnailKey(attachment.StorageKey))
+		if err = u.Storage.Put(ctx, attachment.ThumbnailKey.String, bytes.NewReader(thumbnail)); err != nil {
+			u.removeFiles(ctx, attachment)
+			return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgUploadAttachment)
+		}
+	}
+
+	if err = u.AttachmentDB.InsertAttachment(ctx, &attachment); err != nil {
+		u.removeFiles(ctx, attachment)
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgUploadAttachment)
+	}
+
+	resp, err := u.response(ctx, attachment, patient.UUID)
+	if err != nil {
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgUploadAttachment)
+	}
+	return resp, nil
+}
+
+// removeFiles cleans up the files of an upload that could not be recorded.
+func (u *AttachmentUC) removeFiles(ctx context.Context, attachment model.TrxPatientAttachment) {
+	keys := []string{attachment.StorageKey}
+	if attachment.ThumbnailKey.Valid {
+		keys = append(keys, attachment.ThumbnailKey.String)
+	}
+	for _, key := range keys {
+		if err := u.Storage.Delete(ctx, key); err != nil {
+			log.Errorf("remove attachment file %s: %v", key, err)
+		}
+	}
+}
+
+func (u *AttachmentUC) ListAttachments(ctx context.Context, params model.ListAttachmentParams) ([]model.AttachmentResponse, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	patient, err := u.PatientDB.GetPatientByParams(ctx, model.MstPatientInstitution{
+		UUID:          params.PatientUUID,
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListAttachments)
+	}
+	if patient.ID == 0 {
+		return nil, commonerr.SetNewBadRequest("patient not found",
+			fmt.Sprintf("no patient with uuid %s in this institution", params.PatientUUID))
+	}
+
+	attachments, err := u.AttachmentDB.ListAttachments(ctx, userDetail.InstitutionID, patient.ID, params)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListAttachments)
+	}
+
+	resp := make([]model.AttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		item, err := u.response(ctx, attachment, patient.UUID)
+		if err != nil {
+			return nil, errors.Wrap(err, wrapMsgListAttachments)
+		}
+		resp = append(resp, item)
+	}
+	return resp, nil
+}
+
+// GetAttachment returns the attachment with fresh download links.
+func (u *AttachmentUC) GetAttachment(ctx context.Context, attachmentID int64) (model.AttachmentResponse, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.AttachmentResponse{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	attachment, found, err := u.AttachmentDB.GetAttachment(ctx, userDetail.InstitutionID, attachmentID)
+	if err != nil {
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgGetAttachment)
+	}
+	if !found {
+		return model.AttachmentResponse{}, commonerr.SetNewBadRequest("attachment not found",
+			fmt.Sprintf("no attachment with id %d in this institution", attachmentID))
+	}
+
+	patient, err := u.PatientDB.GetPatientByID(ctx, attachment.IDMstPatient)
+	if err != nil {
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgGetAttachment)
+	}
+
+	resp, err := u.response(ctx, attachment, patient.UUID)
+	if err != nil {
+		return model.AttachmentResponse{}, errors.Wrap(err, wrapMsgGetAttachment)
+	}
+	return resp, nil
+}
+
+// DeleteAttachment hides the attachment. Its files are kept with the medical
+// record, but links to them stop working.
+func (u *AttachmentUC) DeleteAttachment(ctx context.Context, attachmentID int64) error {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	err := u.AttachmentDB.DeleteAttachment(ctx, userDetail.InstitutionID, attachmentID)
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		return commonerr.SetNewBadRequest("attachment not found",
+			fmt.Sprintf("no attachment with id %d in this institution", attachmentID))
+	}
+	if err != nil {
+		return errors.Wrap(err, wrapMsgDeleteAttachment)
+	}
+	return nil
+}
+
+// OpenAttachment serves a link made by response. The signature stands in for
+// the session, so the link works where no token can be sent, e.g. in <img>.
+func (u *AttachmentUC) OpenAttachment(ctx context.Context, params model.DownloadAttachmentParams) (model.AttachmentContent, error) {
+	if params.Variant == "" {
+		params.Variant = model.AttachmentVariantOriginal
+	}
+	if u.Signer == nil {
+		return model.AttachmentContent{}, errAttachmentLinksDisabled()
+	}
+	resource := model.AttachmentResource(params.InstitutionID, params.ID, params.Variant)
+	err := u.Signer.Verify(resource, time.Unix(params.Expires, 0), params.Signature, time.Now())
+	if errors.Is(err, signedurl.ErrExpired) {
+		return model.AttachmentContent{}, commonerr.SetNewError(http.StatusForbidden, "link expired", "this download link has expired; request a new one")
+	}
+	if err != nil {
+		return model.AttachmentContent{}, commonerr.SetNewError(http.StatusForbidden, "invalid link", "this download link is not valid")
+	}
+
+	attachment, found, err := u.AttachmentDB.GetAttachment(ctx, params.InstitutionID, params.ID)
+	if err != nil {
+		return model.AttachmentContent{}, errors.Wrap(err, wrapMsgOpenAttachment)
+	}
+	if !found {
+		return model.AttachmentContent{}, commonerr.Set404()
+	}
+
+	content := model.AttachmentContent{
+		ContentType: attachment.ContentType,
+		FileName:    attachment.FileName,
+	}
+	key := attachment.StorageKey
+	if params.Variant == model.AttachmentVariantThumbnail {
+		if !attachment.ThumbnailKey.Valid {
+			return model.AttachmentContent{}, commonerr.Set404()
+		}
+		key = attachment.ThumbnailKey.String
+		content.ContentType = "image/jpeg"
+		content.FileName = strings.TrimSuffix(attachment.FileName, filepath.Ext(attachment.FileName)) + "_thumbnail.jpg"
+	}
+
+	content.Body, err = u.Storage.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return model.AttachmentContent{}, commonerr.Set404()
+	}
+	if err != nil {
+		return model.AttachmentContent{}, errors.Wrap(err, wrapMsgOpenAttachment)
+	}
+	return content, nil
+}
+
+// response adds download links valid for URLTTL to attachment, straight from
+// the store when it can presign them and through the API otherwise. Without a
+// signer links through the API are left out.
+func (u *AttachmentUC) response(ctx context.Context, attachment model.TrxPatientAttachment, patientUUID string) (resp model.AttachmentResponse, err error) {
+	resp = model.AttachmentResponse{
+		TrxPatientAttachment: attachment,
+		PatientUUID:          patientUUID,
+		URLExpiresAt:         time.Now().Add(u.URLTTL).Truncate(time.Second),
+	}
+
+	resp.URL, err = u.downloadURL(ctx, attachment, attachment.StorageKey, model.AttachmentVariantOriginal, resp.URLExpiresAt)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgResponse)
+	}
+	if attachment.ThumbnailKey.Valid {
+		resp.ThumbnailURL, err = u.downloadURL(ctx, attachment, attachment.ThumbnailKey.String, model.AttachmentVariantThumbnail, resp.URLExpiresAt)
+		if err != nil {
+			return resp, errors.Wrap(err, wrapMsgResponse)
+		}
+	}
+	return resp, nil
+}
+
+func (u *AttachmentUC) downloadURL(ctx context.Context, attachment model.TrxPatientAttachment, key, variant string, expires time.Time) (string, error) {
+	if presigner, ok := u.Storage.(storage.Presigner); ok {
+		return presigner.PresignGet(ctx, key, expires)
+	}
+	if u.Signer == nil {
+		return "", nil
+	}
+
+	query := url.Values{}
+	query.Set("institution_id", strconv.FormatInt(attachment.IDMstInstitution, 10))
+	query.Set("variant", variant)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", u.Signer.Sign(model.AttachmentResource(attachment.IDMstInstitution, attachment.ID, variant), expires))
+	return fmt.Sprintf("%s/v1/attachment/%d/content?%s", strings.TrimSuffix(u.BaseURL, "/"), attachment.ID, query.Encode()), nil
+}
+
+// attachmentFileName keeps the base of the uploaded name for display and
+// downloads, falling back to a generic name with the sniffed extension.
+func attachmentFileName(name, ext string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment" + ext
+	}
+	for len(name) > maxFileNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+func errAttachmentLinksDisabled() error {
+	return commonerr.SetNewError(http.StatusServiceUnavailable, "attachment links disabled", "attachment download links are not configured on this server")
+}
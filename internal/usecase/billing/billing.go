@@ -0,0 +1,501 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	billingrepo "github.com/faisalhardin/medilink/internal/entity/repo/billing"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	patientrepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	wrapMsgGetVisitBilling  = "BillingUC.GetVisitBilling"
+	wrapMsgRecordPayment    = "BillingUC.RecordPayment"
+	wrapMsgCreateRefund     = "BillingUC.CreateRefund"
+	wrapMsgListRefunds      = "BillingUC.ListRefunds"
+	wrapMsgLockVisitAccount = "BillingUC.lockVisitAccount"
+	wrapMsgSyncVisitCharge  = "BillingUC.syncVisitCharge"
+)
+
+type BillingUC struct {
+	BillingDB       billingrepo.BillingDB
+	PatientDB       patientrepo.PatientDB
+	InstitutionRepo institutionrepo.InstitutionDB
+	Transaction     xormlib.DBTransactionInterface
+}
+
+func NewBillingUC(u *BillingUC) *BillingUC {
+	return u
+}
+
+func (u *BillingUC) GetVisitBilling(ctx context.Context, visitID int64) (resp model.VisitBillingResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	visit, err := u.validateVisit(ctx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return resp, err
+	}
+
+	_, summary, payments, refunds, err := u.loadVisitBilling(ctx, visit)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgGetVisitBilling)
+	}
+
+	return model.VisitBillingResponse{
+		IDTrxPatientVisit: visitID,
+		Summary:           summary,
+		Payments:          payments,
+		Refunds:           refunds,
+	}, nil
+}
+
+func (u *BillingUC) RecordPayment(ctx context.Context, visitID int64, req model.RecordVisitPaymentRequest) (payment model.TrxVisitPayment, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return payment, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if !model.IsValidPaymentMethod(req.PaymentMethod) {
+		return payment, commonerr.SetNewBadRequest("invalid_payment_method", fmt.Sprintf("payment method %s is not supported", req.PaymentMethod))
+	}
+	amount := req.Amount.Round()
+	if !amount.IsPositive() {
+		return payment, commonerr.SetNewBadRequest("invalid_amount", "amount must be positive")
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	visit, err := u.lockVisitAccount(txCtx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return payment, err
+	}
+
+	_, summary, _, _, err := u.loadVisitBilling(txCtx, visit)
+	if err != nil {
+		return payment, errors.Wrap(err, wrapMsgRecordPayment)
+	}
+	if amount.GreaterThan(summary.Outstanding) {
+		return payment, commonerr.SetNewBadRequest("payment_exceeds_outstanding",
+			fmt.Sprintf("amount %v exceeds the outstanding %v", amount, summary.Outstanding))
+	}
+
+	payment = model.TrxVisitPayment{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDTrxPatientVisit: visitID,
+		PaymentMethod:     req.PaymentMethod,
+		Amount:            amount,
+		Reference:         strings.TrimSpace(req.Reference),
+		ReceivedBy:        userDetail.Email,
+	}
+	if err = u.BillingDB.InsertVisitPayment(txCtx, &payment); err != nil {
+		return payment, errors.Wrap(err, wrapMsgRecordPayment)
+	}
+
+	// the patient account is charged the invoice and credited the payment
+	if err = u.syncVisitCharge(txCtx, userDetail, visit, summary.PatientShare); err != nil {
+		return payment, err
+	}
+	err = u.BillingDB.InsertLedgerEntries(txCtx, []model.TrxPatientLedgerEntry{{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDMstPatient:      visit.IDMstPatient,
+		EntryType:         model.PatientLedgerEntryPayment,
+		Amount:            payment.Amount,
+		IDTrxPatientVisit: null.Int64From(visitID),
+		ReferenceType:     model.PatientLedgerReferenceVisitPayment,
+		ReferenceID:       payment.ID,
+		PaymentMethod:     payment.PaymentMethod,
+		Notes:             payment.Reference,
+		CreatedBy:         userDetail.Email,
+	}})
+	if err != nil {
+		return payment, errors.Wrap(err, wrapMsgRecordPayment)
+	}
+	return payment, nil
+}
+
+// CreateRefund returns the requested quantities of lines of a paid visit. Each
+// line can only be returned up to what earlier documents left of it. Restocked
+// items go back to stock at the cost they were billed with.
+func (u *BillingUC) CreateRefund(ctx context.Context, visitID int64, req model.CreateVisitRefundRequest) (refund model.TrxVisitRefund, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return refund, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return refund, commonerr.SetNewBadRequest("reason_required", "reason is required")
+	}
+	switch req.DocumentType {
+	case model.RefundDocumentTypeRefund:
+		if !model.IsValidPaymentMethod(req.RefundMethod) {
+			return refund, commonerr.SetNewBadRequest("invalid_refund_method", "refund_method must be a supported payment method")
+		}
+	case model.RefundDocumentTypeCreditNote:
+		if req.RefundMethod != "" {
+			return refund, commonerr.SetNewBadRequest("invalid_refund_method", "a credit note keeps the amount as credit and takes no refund_method")
+		}
+	default:
+		return refund, commonerr.SetNewBadRequest("invalid_document_type", "document_type must be refund or credit_note")
+	}
+	if len(req.Lines) == 0 {
+		return refund, commonerr.SetNewBadRequest("lines_required", "select at least one line to return")
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	visit, err := u.lockVisitAccount(txCtx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return refund, err
+	}
+
+	visitLines, summary, _, _, err := u.loadVisitBilling(txCtx, visit)
+	if err != nil {
+		return refund, errors.Wrap(err, wrapMsgCreateRefund)
+	}
+	if !summary.IsPaid {
+		return refund, commonerr.SetNewBadRequest("visit_not_paid", "refunds and credit notes can only be issued for paid visits")
+	}
+	if summary.PayerShare.IsPositive() {
+		return refund, commonerr.SetNewBadRequest("visit_billed_to_payer", "returns of a visit billed to a payer are settled with the payer")
+	}
+
+	refundedTotals, err := u.BillingDB.SumRefundedVisitLines(txCtx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return refund, errors.Wrap(err, wrapMsgCreateRefund)
+	}
+	refundedByLine := make(map[int64]model.RefundedLineTotals, len(refundedTotals))
+	for _, refunded := range refundedTotals {
+		refundedByLine[refunded.IDTrxVisitProduct] = refunded
+	}
+
+	linesByID := make(map[int64]model.TrxVisitProduct, len(visitLines))
+	for _, line := range visitLines {
+		linesByID[line.ID] = line
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	seen := map[int64]struct{}{}
+	refundLines := make([]model.DtlVisitRefundLine, 0, len(req.Lines))
+	for i, lineReq := range req.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		line, ok := linesByID[lineReq.IDTrxVisitProduct]
+		if !ok {
+			errMsg.Append(field+".visit_product_id", "line is not part of this visit")
+			continue
+		}
+		if _, dup := seen[line.ID]; dup {
+			errMsg.Append(field+".visit_product_id", "line is selected more than once")
+			continue
+		}
+		seen[line.ID] = struct{}{}
+
+		refunded := refundedByLine[line.ID]
+		remaining := line.Quantity - refunded.Quantity
+		if lineReq.Quantity <= 0 || lineReq.Quantity > remaining {
+			errMsg.Append(field+".quantity", fmt.Sprintf("quantity must be between 1 and %d, the returnable quantity of %s", remaining, line.Name))
+			continue
+		}
+		refundLines = append(refundLines, model.NewRefundLine(line, refunded, lineReq.Quantity))
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return refund, errMsg
+	}
+
+	if req.Restock {
+		if err = u.markRestockedLines(txCtx, userDetail.InstitutionID, refundLines); err != nil {
+			return refund, err
+		}
+	}
+
+	refund = model.TrxVisitRefund{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDTrxPatientVisit: visitID,
+		DocumentType:      req.DocumentType,
+		RefundMethod:      req.RefundMethod,
+		Reason:            reason,
+		Restock:           req.Restock,
+		TotalAmount:       money.Zero,
+		TaxAmount:         money.Zero,
+		ServiceCharge:     money.Zero,
+		CreatedBy:         userDetail.Email,
+		Lines:             refundLines,
+	}
+	for _, line := range refundLines {
+		refund.TotalAmount = refund.TotalAmount.Add(line.Amount)
+		refund.TaxAmount = refund.TaxAmount.Add(line.TaxAmount)
+		refund.ServiceCharge = refund.ServiceCharge.Add(line.ServiceCharge)
+	}
+
+	if err = u.BillingDB.InsertVisitRefund(txCtx, &refund); err != nil {
+		return refund, errors.Wrap(err, wrapMsgCreateRefund)
+	}
+
+	if err = u.restock(txCtx, userDetail, refund, linesByID); err != nil {
+		return refund, err
+	}
+
+	// returned items credit the patient account; a refund pays the credit out
+	if err = u.syncVisitCharge(txCtx, userDetail, visit, summary.PatientShare); err != nil {
+		return refund, err
+	}
+	entries := []model.TrxPatientLedgerEntry{{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDMstPatient:      visit.IDMstPatient,
+		EntryType:         model.PatientLedgerEntryReturn,
+		Amount:            refund.TotalAmount,
+		IDTrxPatientVisit: null.Int64From(visitID),
+		ReferenceType:     model.PatientLedgerReferenceVisitRefund,
+		ReferenceID:       refund.ID,
+		Notes:             refund.Reason,
+		CreatedBy:         userDetail.Email,
+	}}
+	if refund.DocumentType == model.RefundDocumentTypeRefund {
+		entries = append(entries, model.TrxPatientLedgerEntry{
+			IDMstInstitution:  userDetail.InstitutionID,
+			IDMstPatient:      visit.IDMstPatient,
+			EntryType:         model.PatientLedgerEntryRefund,
+			Amount:            refund.TotalAmount.Neg(),
+			IDTrxPatientVisit: null.Int64From(visitID),
+			ReferenceType:     model.PatientLedgerReferenceVisitRefund,
+			ReferenceID:       refund.ID,
+			PaymentMethod:     refund.RefundMethod,
+			Notes:             refund.Reason,
+			CreatedBy:         userDetail.Email,
+		})
+	}
+	if err = u.BillingDB.InsertLedgerEntries(txCtx, entries); err != nil {
+		return refund, errors.Wrap(err, wrapMsgCreateRefund)
+	}
+	return refund, nil
+}
+
+func (u *BillingUC) ListRefunds(ctx context.Context, visitID int64) ([]model.TrxVisitRefund, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if _, err := u.validateVisit(ctx, userDetail.InstitutionID, visitID); err != nil {
+		return nil, err
+	}
+
+	refunds, err := u.BillingDB.ListVisitRefunds(ctx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListRefunds)
+	}
+	return refunds, nil
+}
+
+// markRestockedLines flags the lines of stocked items; treatments and other
+// services have nothing to put back.
+func (u *BillingUC) markRestockedLines(ctx context.Context, institutionID int64, lines []model.DtlVisitRefundLine) error {
+	productIDs := make([]int64, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.IDTrxInstitutionProduct)
+	}
+
+	products, err := u.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              productIDs,
+		IDMstInstitution: institutionID,
+	})
+	if err != nil {
+		return errors.Wrap(err, wrapMsgCreateRefund)
+	}
+	isItem := make(map[int64]bool, len(products))
+	for _, product := range products {
+		isItem[product.ID] = product.IsItem
+	}
+
+	for i := range lines {
+		lines[i].Restocked = isItem[lines[i].IDTrxInstitutionProduct]
+	}
+	return nil
+}
+
+// restock puts the restocked lines of refund back into stock at the location
+// each visit line was sold from and records their stock movements against the
+// document. Lines sold before the location was recorded on them return to the
+// visit's location.
+func (u *BillingUC) restock(ctx context.Context, userDetail model.UserJWTPayload, refund model.TrxVisitRefund, visitLines map[int64]model.TrxVisitProduct) error {
+	var (
+		visitLocationID     int64
+		visitLocationLoaded bool
+	)
+	movements := make([]model.TrxStockMovement, 0, len(refund.Lines))
+	for _, line := range refund.Lines {
+		if !line.Restocked {
+			continue
+		}
+
+		locationID := visitLines[line.IDTrxVisitProduct].IDMstStockLocation.Int64
+		if locationID == 0 {
+			if !visitLocationLoaded {
+				location, found, err := u.InstitutionRepo.FindVisitStockLocation(ctx, userDetail.InstitutionID, refund.IDTrxPatientVisit)
+				if err != nil {
+					return errors.Wrap(err, wrapMsgCreateRefund)
+				}
+				// without a location of its own the movement books to the main location
+				if found {
+					visitLocationID = location.ID
+				}
+				visitLocationLoaded = true
+			}
+			locationID = visitLocationID
+		}
+
+		baseQuantity := line.BaseQuantity()
+		// line unit cost spread over the base units it holds
+		unitCost := line.UnitCost.MulInt(int64(line.Quantity)).Div(baseQuantity).Round()
+		err := u.InstitutionRepo.ReceiveDtlInstitutionProductStock(ctx, line.IDTrxInstitutionProduct, baseQuantity, unitCost)
+		if err != nil {
+			return errors.Wrap(err, wrapMsgCreateRefund)
+		}
+
+		movements = append(movements, model.TrxStockMovement{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: line.IDTrxInstitutionProduct,
+			IDMstStockLocation:      locationID,
+			MovementType:            model.StockMovementTypeRefundRestock,
+			Quantity:                baseQuantity,
+			UnitCost:                unitCost,
+			ReferenceType:           model.StockMovementReferenceVisitRefund,
+			ReferenceID:             refund.ID,
+			Notes:                   refund.Reason,
+			CreatedBy:               userDetail.Email,
+		})
+	}
+
+	if err := u.InstitutionRepo.InsertStockMovements(ctx, movements); err != nil {
+		return errors.Wrap(err, wrapMsgCreateRefund)
+	}
+	return nil
+}
+
+// loadVisitBilling reads the lines, payments and refund documents of a visit
+// and summarises them against the patient's share of the invoice.
+func (u *BillingUC) loadVisitBilling(ctx context.Context, visit model.TrxPatientVisit) (
+	lines []model.TrxVisitProduct,
+	summary model.VisitPaymentSummary,
+	payments []model.TrxVisitPayment,
+	refunds []model.TrxVisitRefund,
+	err error,
+) {
+	lines, err = u.PatientDB.GetTrxVisitProduct(ctx, model.GetVisitProductRequest{
+		VisitID:       visit.ID,
+		InstitutionID: visit.IDMstInstitution,
+	})
+	if err != nil {
+		return
+	}
+
+	payments, err = u.BillingDB.ListVisitPayments(ctx, visit.IDMstInstitution, visit.ID)
+	if err != nil {
+		return
+	}
+
+	refunds, err = u.BillingDB.ListVisitRefunds(ctx, visit.IDMstInstitution, visit.ID)
+	if err != nil {
+		return
+	}
+
+	totals := model.NewVisitTotals(lines)
+	summary = model.NewVisitPaymentSummary(totals, payments, refunds).
+		WithInvoiceSplit(model.NewVisitInvoiceSplit(visit, totals.GrandTotal))
+	return
+}
+
+// lockVisitAccount locks the account of the visit's patient, then the visit.
+func (u *BillingUC) lockVisitAccount(ctx context.Context, institutionID, visitID int64) (visit model.TrxPatientVisit, err error) {
+	visit, err = u.PatientDB.GetPatientVisitsByID(ctx, visitID)
+	if err != nil {
+		return visit, errors.Wrap(err, wrapMsgLockVisitAccount)
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != institutionID {
+		return visit, visitNotFoundError()
+	}
+
+	found, err := u.BillingDB.LockPatient(ctx, institutionID, visit.IDMstPatient)
+	if err != nil {
+		return visit, errors.Wrap(err, wrapMsgLockVisitAccount)
+	}
+	if !found {
+		return visit, patientNotFoundError()
+	}
+
+	found, err = u.BillingDB.LockPatientVisit(ctx, institutionID, visitID)
+	if err != nil {
+		return visit, errors.Wrap(err, wrapMsgLockVisitAccount)
+	}
+	if !found {
+		return visit, visitNotFoundError()
+	}
+	return visit, nil
+}
+
+// syncVisitCharge posts the difference between the patient's share of the
+// visit invoice and what the patient account was charged for the visit so
+// far. The payer's share is not the patient's to pay and stays off the account.
+func (u *BillingUC) syncVisitCharge(ctx context.Context, userDetail model.UserJWTPayload, visit model.TrxPatientVisit, patientShare money.Money) error {
+	charged, err := u.BillingDB.SumVisitCharges(ctx, userDetail.InstitutionID, visit.ID)
+	if err != nil {
+		return errors.Wrap(err, wrapMsgSyncVisitCharge)
+	}
+
+	difference := patientShare.Sub(charged)
+	if difference.IsZero() {
+		return nil
+	}
+
+	err = u.BillingDB.InsertLedgerEntries(ctx, []model.TrxPatientLedgerEntry{{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDMstPatient:      visit.IDMstPatient,
+		EntryType:         model.PatientLedgerEntryCharge,
+		Amount:            difference.Neg(),
+		IDTrxPatientVisit: null.Int64From(visit.ID),
+		ReferenceType:     model.PatientLedgerReferenceVisit,
+		ReferenceID:       visit.ID,
+		CreatedBy:         userDetail.Email,
+	}})
+	if err != nil {
+		return errors.Wrap(err, wrapMsgSyncVisitCharge)
+	}
+	return nil
+}
+
+func (u *BillingUC) validateVisit(ctx context.Context, institutionID, visitID int64) (model.TrxPatientVisit, error) {
+	visit, err := u.PatientDB.GetPatientVisitsByID(ctx, visitID)
+	if err != nil {
+		return visit, errors.Wrap(err, wrapMsgGetVisitBilling)
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != institutionID {
+		return visit, visitNotFoundError()
+	}
+	return visit, nil
+}
+
+func visitNotFoundError() error {
+	return commonerr.SetNewError(http.StatusNotFound, "visit_not_found", "visit was not found in this institution")
+}
+
+func patientNotFoundError() error {
+	return commonerr.SetNewError(http.StatusNotFound, "patient_not_found", "patient was not found in this institution")
+}
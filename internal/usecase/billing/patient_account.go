@@ -0,0 +1,270 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+)
+
+const (
+	wrapMsgGetPatientAccount   = "BillingUC.GetPatientAccount"
+	wrapMsgRecordDeposit       = "BillingUC.RecordDeposit"
+	wrapMsgRefundPatientCredit = "BillingUC.RefundPatientCredit"
+	wrapMsgApplyDeposit        = "BillingUC.ApplyDeposit"
+	wrapMsgGetPatientStatement = "BillingUC.GetPatientStatement"
+	wrapMsgGetPatientByUUID    = "BillingUC.getPatientByUUID"
+)
+
+func (u *BillingUC) GetPatientAccount(ctx context.Context, patientUUID string) (summary model.PatientAccountSummary, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return summary, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		return summary, err
+	}
+
+	totals, err := u.BillingDB.SumLedgerByType(ctx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		return summary, errors.Wrap(err, wrapMsgGetPatientAccount)
+	}
+	return model.NewPatientAccountSummary(patient.UUID, totals), nil
+}
+
+func (u *BillingUC) RecordDeposit(ctx context.Context, patientUUID string, req model.PatientAccountDepositRequest) (entry model.TrxPatientLedgerEntry, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return entry, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if !model.IsValidPaymentMethod(req.PaymentMethod) {
+		return entry, commonerr.SetNewBadRequest("invalid_payment_method", fmt.Sprintf("payment method %s is not supported", req.PaymentMethod))
+	}
+	amount := req.Amount.Round()
+	if !amount.IsPositive() {
+		return entry, commonerr.SetNewBadRequest("invalid_amount", "amount must be positive")
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		return entry, err
+	}
+
+	notes := strings.TrimSpace(req.Notes)
+	if reference := strings.TrimSpace(req.Reference); reference != "" {
+		notes = strings.TrimSpace(reference + " " + notes)
+	}
+	entry = model.TrxPatientLedgerEntry{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+		EntryType:        model.PatientLedgerEntryDeposit,
+		Amount:           amount,
+		PaymentMethod:    req.PaymentMethod,
+		Notes:            notes,
+		CreatedBy:        userDetail.Email,
+	}
+	if err = u.BillingDB.InsertLedgerEntries(ctx, []model.TrxPatientLedgerEntry{entry}); err != nil {
+		return entry, errors.Wrap(err, wrapMsgRecordDeposit)
+	}
+	return entry, nil
+}
+
+// RefundPatientCredit pays unused credit back, up to the account balance.
+func (u *BillingUC) RefundPatientCredit(ctx context.Context, patientUUID string, req model.PatientAccountRefundRequest) (entry model.TrxPatientLedgerEntry, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return entry, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if !model.IsValidPaymentMethod(req.RefundMethod) {
+		return entry, commonerr.SetNewBadRequest("invalid_refund_method", "refund_method must be a supported payment method")
+	}
+	amount := req.Amount.Round()
+	if !amount.IsPositive() {
+		return entry, commonerr.SetNewBadRequest("invalid_amount", "amount must be positive")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return entry, commonerr.SetNewBadRequest("reason_required", "reason is required")
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		return entry, err
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	found, err = u.BillingDB.LockPatient(txCtx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		return entry, errors.Wrap(err, wrapMsgRefundPatientCredit)
+	}
+	if !found {
+		return entry, patientNotFoundError()
+	}
+
+	totals, err := u.BillingDB.SumLedgerByType(txCtx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		return entry, errors.Wrap(err, wrapMsgRefundPatientCredit)
+	}
+	summary := model.NewPatientAccountSummary(patient.UUID, totals)
+	if amount.GreaterThan(summary.AvailableCredit) {
+		return entry, commonerr.SetNewBadRequest("insufficient_credit",
+			fmt.Sprintf("amount %v exceeds the available credit %v", amount, summary.AvailableCredit))
+	}
+
+	entry = model.TrxPatientLedgerEntry{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+		EntryType:        model.PatientLedgerEntryRefund,
+		Amount:           amount.Neg(),
+		PaymentMethod:    req.RefundMethod,
+		Notes:            reason,
+		CreatedBy:        userDetail.Email,
+	}
+	if err = u.BillingDB.InsertLedgerEntries(txCtx, []model.TrxPatientLedgerEntry{entry}); err != nil {
+		return entry, errors.Wrap(err, wrapMsgRefundPatientCredit)
+	}
+	return entry, nil
+}
+
+// ApplyDeposit pays a visit from the patient's credit. The visit is charged
+// first, so the credit left for it is the balance plus what the visit still
+// owes; invoices of other visits that are charged but unpaid keep their share.
+func (u *BillingUC) ApplyDeposit(ctx context.Context, visitID int64, req model.ApplyDepositRequest) (payment model.TrxVisitPayment, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return payment, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	amount := req.Amount.Round()
+	if amount.IsNegative() {
+		return payment, commonerr.SetNewBadRequest("invalid_amount", "amount must not be negative")
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	visit, err := u.lockVisitAccount(txCtx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return payment, err
+	}
+
+	_, summary, _, _, err := u.loadVisitBilling(txCtx, visit)
+	if err != nil {
+		return payment, errors.Wrap(err, wrapMsgApplyDeposit)
+	}
+	if !summary.Outstanding.IsPositive() {
+		return payment, commonerr.SetNewBadRequest("nothing_outstanding", "the visit invoice has nothing outstanding")
+	}
+
+	if err = u.syncVisitCharge(txCtx, userDetail, visit, summary.PatientShare); err != nil {
+		return payment, err
+	}
+	totals, err := u.BillingDB.SumLedgerByType(txCtx, userDetail.InstitutionID, visit.IDMstPatient)
+	if err != nil {
+		return payment, errors.Wrap(err, wrapMsgApplyDeposit)
+	}
+	balance := model.NewPatientAccountSummary("", totals).Balance
+	available := money.Min(summary.Outstanding, balance.Add(summary.Outstanding))
+	if !available.IsPositive() {
+		return payment, commonerr.SetNewBadRequest("insufficient_credit", "the patient has no credit to apply")
+	}
+
+	if amount.IsZero() {
+		amount = available
+	}
+	if amount.GreaterThan(available) {
+		return payment, commonerr.SetNewBadRequest("insufficient_credit",
+			fmt.Sprintf("amount %v exceeds the %v that can be applied", amount, available))
+	}
+
+	// the charge already spends the credit, so the payment posts no ledger entry
+	payment = model.TrxVisitPayment{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDTrxPatientVisit: visitID,
+		PaymentMethod:     model.PaymentMethodDeposit,
+		Amount:            amount,
+		ReceivedBy:        userDetail.Email,
+	}
+	if err = u.BillingDB.InsertVisitPayment(txCtx, &payment); err != nil {
+		return payment, errors.Wrap(err, wrapMsgApplyDeposit)
+	}
+	return payment, nil
+}
+
+// GetPatientStatement lists the ledger entries of a period with their running
+// balance. Without from_time the statement starts at the first entry.
+func (u *BillingUC) GetPatientStatement(ctx context.Context, patientUUID string, params model.PatientAccountStatementParams) (statement model.PatientAccountStatement, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return statement, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if !params.FromTime.IsZero() && !params.ToTime.IsZero() && params.ToTime.Before(params.FromTime.Time) {
+		return statement, commonerr.SetNewBadRequest("invalid time range", "to_time must not be before from_time")
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		return statement, err
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+	params.IDMstPatient = patient.ID
+
+	opening := money.Zero
+	if !params.FromTime.IsZero() {
+		opening, err = u.BillingDB.GetLedgerBalanceBefore(ctx, userDetail.InstitutionID, patient.ID, params.FromTime.Time)
+		if err != nil {
+			return statement, errors.Wrap(err, wrapMsgGetPatientStatement)
+		}
+	}
+
+	entries, err := u.BillingDB.ListLedgerEntries(ctx, params)
+	if err != nil {
+		return statement, errors.Wrap(err, wrapMsgGetPatientStatement)
+	}
+
+	statement = model.NewPatientAccountStatement(opening, entries)
+	statement.PatientUUID = patient.UUID
+	statement.PatientName = patient.Name
+	if !params.FromTime.IsZero() {
+		fromTime := params.FromTime.Time
+		statement.FromTime = &fromTime
+	}
+	if !params.ToTime.IsZero() {
+		toTime := params.ToTime.Time
+		statement.ToTime = &toTime
+	}
+	return statement, nil
+}
+
+func (u *BillingUC) getPatientByUUID(ctx context.Context, institutionID int64, patientUUID string) (model.MstPatientInstitution, error) {
+	if patientUUID == "" {
+		return model.MstPatientInstitution{}, patientNotFoundError()
+	}
+
+	patient, err := u.PatientDB.GetPatientByParams(ctx, model.MstPatientInstitution{
+		UUID:          patientUUID,
+		InstitutionID: institutionID,
+	})
+	if err != nil {
+		return patient, errors.Wrap(err, wrapMsgGetPatientByUUID)
+	}
+	if patient.ID == 0 {
+		return patient, patientNotFoundError()
+	}
+	return patient, nil
+}
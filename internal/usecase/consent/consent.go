@@ -0,0 +1,342 @@
+package consent
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	consentrepo "github.com/faisalhardin/medilink/internal/entity/repo/consent"
+	patientrepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
+	procedurerepo "github.com/faisalhardin/medilink/internal/entity/repo/procedure"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+const (
+	wrapMsgListTemplates     = "ConsentUC.ListTemplates"
+	wrapMsgSaveTemplate      = "ConsentUC.SaveTemplate"
+	wrapMsgRetireTemplate    = "ConsentUC.RetireTemplate"
+	wrapMsgPreviewConsent    = "ConsentUC.PreviewConsent"
+	wrapMsgCreateConsent     = "ConsentUC.CreateConsent"
+	wrapMsgListVisitConsents = "ConsentUC.ListVisitConsents"
+	wrapMsgGetSignature      = "ConsentUC.GetSignature"
+)
+
+// signatureContentTypes are the image types accepted as a signature.
+var signatureContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+}
+
+type ConsentUC struct {
+	ConsentDB   consentrepo.ConsentDB
+	PatientDB   patientrepo.PatientDB
+	ProcedureDB procedurerepo.ProcedureDB
+	Transaction xormlib.DBTransactionInterface
+}
+
+func NewConsentUC(u *ConsentUC) *ConsentUC {
+	return u
+}
+
+func (u *ConsentUC) ListTemplates(ctx context.Context) ([]model.MstConsentTemplate, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	templates, err := u.ConsentDB.ListConsentTemplates(ctx, userDetail.InstitutionID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListTemplates)
+	}
+	return templates, nil
+}
+
+func (u *ConsentUC) SaveTemplate(ctx context.Context, req model.SaveConsentTemplateRequest) (template model.MstConsentTemplate, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return template, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		return template, errors.Wrap(err, wrapMsgSaveTemplate)
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	active, found, err := u.ConsentDB.GetActiveConsentTemplate(ctx, userDetail.InstitutionID, req.ProcedureCategory)
+	if err != nil {
+		return template, errors.Wrap(err, wrapMsgSaveTemplate)
+	}
+
+	template = model.MstConsentTemplate{
+		IDMstInstitution:  userDetail.InstitutionID,
+		ProcedureCategory: req.ProcedureCategory,
+		Title:             strings.TrimSpace(req.Title),
+		Body:              req.Body,
+		IsRequired:        req.IsRequired,
+		Version:           1,
+		UpdatedBy:         userDetail.Email,
+	}
+	if found {
+		if err = u.ConsentDB.RetireConsentTemplate(ctx, userDetail.InstitutionID, active.ID); err != nil {
+			return template, errors.Wrap(err, wrapMsgSaveTemplate)
+		}
+		template.Version = active.Version + 1
+	}
+
+	if err = u.ConsentDB.InsertConsentTemplate(ctx, &template); err != nil {
+		return template, errors.Wrap(err, wrapMsgSaveTemplate)
+	}
+	return template, nil
+}
+
+func (u *ConsentUC) RetireTemplate(ctx context.Context, templateID int64) error {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	err := u.ConsentDB.RetireConsentTemplate(ctx, userDetail.InstitutionID, templateID)
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		return commonerr.SetNewError(http.StatusNotFound, "consent_template_not_found", "consent template was not found in this institution")
+	}
+	if err != nil {
+		return errors.Wrap(err, wrapMsgRetireTemplate)
+	}
+	return nil
+}
+
+func (u *ConsentUC) PreviewConsent(ctx context.Context, params model.ConsentPreviewParams) (model.ConsentPreview, error) {
+	userDetail, visit, err := u.authorizeVisit(ctx, params.VisitID)
+	if err != nil {
+		return model.ConsentPreview{}, err
+	}
+
+	template, procedure, err := u.resolveTemplate(ctx, userDetail.InstitutionID, visit.ID, params.TemplateID, params.ProcedureID)
+	if err != nil {
+		return model.ConsentPreview{}, err
+	}
+
+	renderedText, err := u.render(ctx, visit, template, procedure, params.SignerName, time.Now())
+	if err != nil {
+		return model.ConsentPreview{}, errors.Wrap(err, wrapMsgPreviewConsent)
+	}
+
+	return model.ConsentPreview{
+		TemplateID:        template.ID,
+		TemplateVersion:   template.Version,
+		ProcedureCategory: template.ProcedureCategory,
+		Title:             template.Title,
+		RenderedText:      renderedText,
+	}, nil
+}
+
+// CreateConsent renders the template for the visit and records it with the
+// signature. The text is rendered here rather than taken from the client, so
+// the stored text is the one the template produced at signing time.
+func (u *ConsentUC) CreateConsent(ctx context.Context, req model.CreateConsentRequest) (model.TrxConsent, error) {
+	userDetail, visit, err := u.authorizeVisit(ctx, req.VisitID)
+	if err != nil {
+		return model.TrxConsent{}, err
+	}
+
+	signature, contentType, ok := decodeSignature(req.SignatureImage)
+	if !ok {
+		return model.TrxConsent{}, commonerr.SetNewUnprocessableEntityError("signature_image",
+			fmt.Sprintf("signature_image must be a base64 PNG or JPEG image of at most %d KB", model.ConsentSignatureMaxSize>>10))
+	}
+
+	template, procedure, err := u.resolveTemplate(ctx, userDetail.InstitutionID, visit.ID, req.TemplateID, req.ProcedureID.Int64)
+	if err != nil {
+		return model.TrxConsent{}, err
+	}
+
+	signedAt := time.Now()
+	signerName := strings.TrimSpace(req.SignerName)
+	renderedText, err := u.render(ctx, visit, template, procedure, signerName, signedAt)
+	if err != nil {
+		return model.TrxConsent{}, errors.Wrap(err, wrapMsgCreateConsent)
+	}
+
+	consent := model.TrxConsent{
+		IDMstInstitution:     userDetail.InstitutionID,
+		VisitID:              visit.ID,
+		TemplateID:           template.ID,
+		TemplateVersion:      template.Version,
+		ProcedureCategory:    template.ProcedureCategory,
+		Title:                template.Title,
+		RenderedText:         renderedText,
+		SignerType:           req.SignerType,
+		SignerName:           signerName,
+		SignatureImage:       signature,
+		SignatureContentType: contentType,
+		WitnessName:          strings.TrimSpace(req.WitnessName),
+		ContentHash:          model.ConsentContentHash(renderedText, signature),
+		SignedAt:             signedAt,
+		RecordedBy:           userDetail.Email,
+	}
+	if procedure.ID > 0 {
+		consent.ProcedureID = null.Int64From(procedure.ID)
+	}
+	if req.SignerType == model.ConsentSignerGuardian {
+		consent.SignerRelationship = null.StringFrom(strings.TrimSpace(req.SignerRelationship))
+	}
+
+	if err = u.ConsentDB.InsertConsent(ctx, &consent); err != nil {
+		return model.TrxConsent{}, errors.Wrap(err, wrapMsgCreateConsent)
+	}
+	return consent, nil
+}
+
+func (u *ConsentUC) ListVisitConsents(ctx context.Context, visitID int64) ([]model.TrxConsent, error) {
+	userDetail, _, err := u.authorizeVisit(ctx, visitID)
+	if err != nil {
+		return nil, err
+	}
+
+	consents, err := u.ConsentDB.ListVisitConsents(ctx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListVisitConsents)
+	}
+	return consents, nil
+}
+
+func (u *ConsentUC) GetSignature(ctx context.Context, consentID int64) (model.ConsentSignature, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.ConsentSignature{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	signature, found, err := u.ConsentDB.GetConsentSignature(ctx, userDetail.InstitutionID, consentID)
+	if err != nil {
+		return model.ConsentSignature{}, errors.Wrap(err, wrapMsgGetSignature)
+	}
+	if !found {
+		return model.ConsentSignature{}, commonerr.SetNewError(http.StatusNotFound, "consent_not_found", "consent was not found in this institution")
+	}
+	return signature, nil
+}
+
+func (u *ConsentUC) authorizeVisit(ctx context.Context, visitID int64) (model.UserJWTPayload, model.TrxPatientVisit, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.UserJWTPayload{}, model.TrxPatientVisit{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	visit, err := u.PatientDB.GetPatientVisitsByID(ctx, visitID)
+	if err != nil {
+		return model.UserJWTPayload{}, model.TrxPatientVisit{}, err
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != userDetail.InstitutionID {
+		return model.UserJWTPayload{}, model.TrxPatientVisit{}, commonerr.SetNewError(http.StatusNotFound, "visit_not_found", "visit was not found in this institution")
+	}
+	return userDetail, visit, nil
+}
+
+// resolveTemplate loads the active template and, when procedureID is set, the
+// procedure of the visit it is signed for, which must be of the template's
+// category.
+func (u *ConsentUC) resolveTemplate(ctx context.Context, institutionID, visitID, templateID, procedureID int64) (model.MstConsentTemplate, model.TrxVisitProcedure, error) {
+	template, found, err := u.ConsentDB.GetConsentTemplate(ctx, institutionID, templateID)
+	if err != nil {
+		return template, model.TrxVisitProcedure{}, err
+	}
+	if !found {
+		return template, model.TrxVisitProcedure{}, commonerr.SetNewUnprocessableEntityError("template_id", "consent template was not found or has been replaced by a newer version")
+	}
+	if procedureID == 0 {
+		return template, model.TrxVisitProcedure{}, nil
+	}
+
+	procedures, err := u.ProcedureDB.GetActiveByVisitID(ctx, institutionID, visitID)
+	if err != nil {
+		return template, model.TrxVisitProcedure{}, err
+	}
+	for _, procedure := range procedures {
+		if procedure.ID != procedureID {
+			continue
+		}
+		if procedure.Category.String != template.ProcedureCategory {
+			return template, procedure, commonerr.SetNewUnprocessableEntityError("procedure_id", "procedure is not of the consent template's category")
+		}
+		return template, procedure, nil
+	}
+	return template, model.TrxVisitProcedure{}, commonerr.SetNewUnprocessableEntityError("procedure_id", "procedure id not found for this visit")
+}
+
+// render fills the template with the visit's patient and procedure. A consent
+// for the whole visit names the procedure category instead of a procedure.
+func (u *ConsentUC) render(ctx context.Context, visit model.TrxPatientVisit, template model.MstConsentTemplate, procedure model.TrxVisitProcedure, signerName string, at time.Time) (string, error) {
+	patient, err := u.PatientDB.GetPatientByID(ctx, visit.IDMstPatient)
+	if err != nil {
+		return "", err
+	}
+
+	categoryName := model.ProcedureCategoryDisplay[template.ProcedureCategory]
+	values := map[string]string{
+		model.ConsentPlaceholderPatientName:         patient.Name,
+		model.ConsentPlaceholderMedicalRecordNumber: patient.MedicalRecordNumber,
+		model.ConsentPlaceholderNIK:                 patient.NIK,
+		model.ConsentPlaceholderDateOfBirth:         patient.DateOfBirth.Format(model.ConsentDateLayout),
+		model.ConsentPlaceholderProcedureCategory:   categoryName,
+		model.ConsentPlaceholderProcedureName:       categoryName,
+		model.ConsentPlaceholderDoctorName:          "",
+		model.ConsentPlaceholderSignerName:          signerName,
+		model.ConsentPlaceholderDate:                at.Format(model.ConsentDateLayout),
+	}
+	if procedure.ID > 0 {
+		values[model.ConsentPlaceholderProcedureName] = procedureName(procedure, categoryName)
+		values[model.ConsentPlaceholderDoctorName] = procedure.DoctorName
+	}
+	return model.RenderConsent(template.Body, values), nil
+}
+
+// procedureName is the most specific name recorded on the procedure.
+func procedureName(procedure model.TrxVisitProcedure, fallback string) string {
+	switch {
+	case procedure.ICD9CMDisplay.Valid && procedure.ICD9CMDisplay.String != "":
+		return procedure.ICD9CMDisplay.String
+	case procedure.ProductName.Valid && procedure.ProductName.String != "":
+		return procedure.ProductName.String
+	case procedure.Description.Valid && procedure.Description.String != "":
+		return procedure.Description.String
+	}
+	return fallback
+}
+
+// decodeSignature decodes a base64 signature, optionally written as a data
+// URL, and reports whether it is a PNG or JPEG within the size limit.
+func decodeSignature(encoded string) (image []byte, contentType string, ok bool) {
+	encoded = strings.TrimSpace(encoded)
+	if strings.HasPrefix(encoded, "data:") {
+		comma := strings.IndexByte(encoded, ',')
+		if comma < 0 || !strings.HasSuffix(encoded[:comma], ";base64") {
+			return nil, "", false
+		}
+		encoded = encoded[comma+1:]
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > model.ConsentSignatureMaxSize+3 {
+		return nil, "", false
+	}
+
+	image, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(image) == 0 || len(image) > model.ConsentSignatureMaxSize {
+		return nil, "", false
+	}
+	contentType = http.DetectContentType(image)
+	if !signatureContentTypes[contentType] {
+		return nil, "", false
+	}
+	return image, contentType, true
+}
@@ -0,0 +1,53 @@
+package consent
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+)
+
+func TestDecodeSignature(t *testing.T) {
+	t.Parallel()
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	encodedPNG := base64.StdEncoding.EncodeToString(png)
+
+	cases := []struct {
+		name    string
+		encoded string
+		want    string
+		ok      bool
+	}{
+		{"plain base64", encodedPNG, "image/png", true},
+		{"data URL", "data:image/png;base64," + encodedPNG, "image/png", true},
+		{"data URL without base64", "data:image/png," + encodedPNG, "", false},
+		{"not base64", "not an image", "", false},
+		{"not an image", base64.StdEncoding.EncodeToString([]byte("hello, world")), "", false},
+		{"empty", "", "", false},
+		{"too large", base64.StdEncoding.EncodeToString(append(png, make([]byte, model.ConsentSignatureMaxSize)...)), "", false},
+	}
+	for _, c := range cases {
+		image, contentType, ok := decodeSignature(c.encoded)
+		if ok != c.ok || contentType != c.want {
+			t.Fatalf("%s: got (%q, %v), want (%q, %v)", c.name, contentType, ok, c.want, c.ok)
+		}
+		if ok && len(image) != len(png) {
+			t.Fatalf("%s: decoded %d bytes, want %d", c.name, len(image), len(png))
+		}
+	}
+}
+
+func TestProcedureName(t *testing.T) {
+	t.Parallel()
+
+	procedure := model.TrxVisitProcedure{}
+	if got := procedureName(procedure, "Surgical procedure"); got != "Surgical procedure" {
+		t.Fatalf("procedureName() = %q, want the fallback", got)
+	}
+	procedure.Description.String, procedure.Description.Valid = "Cabut gigi 36", true
+	procedure.ICD9CMDisplay.String, procedure.ICD9CMDisplay.Valid = "Extraction of tooth", true
+	if got := procedureName(procedure, ""); got != "Extraction of tooth" {
+		t.Fatalf("procedureName() = %q, want the ICD-9-CM display", got)
+	}
+}
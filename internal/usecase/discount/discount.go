@@ -0,0 +1,320 @@
+package discount
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	roleconst "github.com/faisalhardin/medilink/internal/entity/constant/role"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	discountrepo "github.com/faisalhardin/medilink/internal/entity/repo/discount"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	patientrepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	wrapMsgListPolicies          = "DiscountUC.ListPolicies"
+	wrapMsgSavePolicies          = "DiscountUC.SavePolicies"
+	wrapMsgRequestApproval       = "DiscountUC.RequestApproval"
+	wrapMsgListApprovals         = "DiscountUC.ListApprovals"
+	wrapMsgDecideRequest         = "DiscountUC.DecideRequest"
+	wrapMsgAuthorizePriceChanges = "DiscountUC.AuthorizePriceChanges"
+	wrapMsgListPriceAudit        = "DiscountUC.ListPriceAudit"
+)
+
+type DiscountUC struct {
+	DiscountDB      discountrepo.DiscountDB
+	PatientDB       patientrepo.PatientDB
+	InstitutionRepo institutionrepo.InstitutionDB
+	Transaction     xormlib.DBTransactionInterface
+}
+
+func NewDiscountUC(u *DiscountUC) *DiscountUC {
+	return u
+}
+
+func (u *DiscountUC) ListPolicies(ctx context.Context) ([]model.MstDiscountPolicy, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	policies, err := u.DiscountDB.ListDiscountPolicies(ctx, userDetail.InstitutionID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListPolicies)
+	}
+	return policies, nil
+}
+
+func (u *DiscountUC) SavePolicies(ctx context.Context, req model.SaveDiscountPoliciesRequest) (policies []model.MstDiscountPolicy, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	seen := map[string]struct{}{}
+	newPolicies := make([]model.MstDiscountPolicy, 0, len(req.Policies))
+	for i, policy := range req.Policies {
+		roleName := strings.ToLower(strings.TrimSpace(policy.RoleName))
+		if roleName == "" {
+			errMsg.Append(fmt.Sprintf("policies[%d].role_name", i), "role_name is required")
+			continue
+		}
+		if policy.MaxDiscountRate < 0 || policy.MaxDiscountRate > 1 {
+			errMsg.Append(fmt.Sprintf("policies[%d].max_discount_rate", i), "max_discount_rate must be a fraction between 0 and 1, e.g. 0.1")
+			continue
+		}
+		if _, dup := seen[roleName]; dup {
+			errMsg.Append(fmt.Sprintf("policies[%d].role_name", i), "role already has a policy")
+			continue
+		}
+		seen[roleName] = struct{}{}
+
+		newPolicies = append(newPolicies, model.MstDiscountPolicy{
+			IDMstInstitution: userDetail.InstitutionID,
+			RoleName:         roleName,
+			MaxDiscountRate:  policy.MaxDiscountRate,
+			UpdatedBy:        userDetail.Email,
+		})
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return nil, errMsg
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	if err = u.DiscountDB.ReplaceDiscountPolicies(txCtx, userDetail.InstitutionID, newPolicies); err != nil {
+		return nil, errors.Wrap(err, wrapMsgSavePolicies)
+	}
+
+	policies, err = u.DiscountDB.ListDiscountPolicies(txCtx, userDetail.InstitutionID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgSavePolicies)
+	}
+	return policies, nil
+}
+
+func (u *DiscountUC) RequestApproval(ctx context.Context, visitID int64, req model.CreateDiscountApprovalRequest) (approval model.TrxDiscountApproval, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return approval, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return approval, commonerr.SetNewBadRequest("reason_required", "reason is required")
+	}
+	if req.DiscountRate <= 0 || req.DiscountRate > 1 {
+		return approval, commonerr.SetNewBadRequest("invalid_discount_rate", "discount_rate must be a fraction between 0 and 1, e.g. 0.25")
+	}
+
+	visit, err := u.PatientDB.GetPatientVisitsByID(ctx, visitID)
+	if err != nil {
+		return approval, errors.Wrap(err, wrapMsgRequestApproval)
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != userDetail.InstitutionID {
+		return approval, commonerr.SetNewError(http.StatusNotFound, "visit_not_found", "visit was not found in this institution")
+	}
+
+	products, err := u.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              []int64{req.IDTrxInstitutionProduct},
+		IDMstInstitution: userDetail.InstitutionID,
+	})
+	if err != nil {
+		return approval, errors.Wrap(err, wrapMsgRequestApproval)
+	}
+	if len(products) == 0 {
+		return approval, commonerr.SetNewBadRequest("product_not_found", "product was not found in this institution")
+	}
+
+	approval = model.TrxDiscountApproval{
+		IDMstInstitution:        userDetail.InstitutionID,
+		IDTrxPatientVisit:       visit.ID,
+		IDTrxInstitutionProduct: req.IDTrxInstitutionProduct,
+		DiscountRate:            req.DiscountRate,
+		Reason:                  reason,
+		Status:                  model.DiscountApprovalStatusPending,
+		RequestedBy:             userDetail.Email,
+	}
+	if err = u.DiscountDB.InsertDiscountApproval(ctx, &approval); err != nil {
+		return approval, errors.Wrap(err, wrapMsgRequestApproval)
+	}
+	return approval, nil
+}
+
+func (u *DiscountUC) ListApprovals(ctx context.Context, params model.ListDiscountApprovalParams) ([]model.TrxDiscountApproval, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	params.IDMstInstitution = userDetail.InstitutionID
+	approvals, err := u.DiscountDB.ListDiscountApprovals(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListApprovals)
+	}
+	return approvals, nil
+}
+
+func (u *DiscountUC) ApproveRequest(ctx context.Context, approvalID int64, req model.DecideDiscountApprovalRequest) (model.TrxDiscountApproval, error) {
+	return u.decideRequest(ctx, approvalID, model.DiscountApprovalStatusApproved, req)
+}
+
+func (u *DiscountUC) RejectRequest(ctx context.Context, approvalID int64, req model.DecideDiscountApprovalRequest) (model.TrxDiscountApproval, error) {
+	return u.decideRequest(ctx, approvalID, model.DiscountApprovalStatusRejected, req)
+}
+
+func (u *DiscountUC) decideRequest(ctx context.Context, approvalID int64, status string, req model.DecideDiscountApprovalRequest) (approval model.TrxDiscountApproval, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return approval, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	locked, err := u.DiscountDB.LockDiscountApproval(txCtx, userDetail.InstitutionID, approvalID)
+	if err != nil {
+		return approval, errors.Wrap(err, wrapMsgDecideRequest)
+	}
+	if locked == nil {
+		return approval, commonerr.SetNewError(http.StatusNotFound, "discount_approval_not_found", "discount approval was not found in this institution")
+	}
+	if locked.Status != model.DiscountApprovalStatusPending {
+		return approval, commonerr.SetNewBadRequest("discount_approval_decided", fmt.Sprintf("discount approval is already %s", locked.Status))
+	}
+	if strings.EqualFold(locked.RequestedBy, userDetail.Email) {
+		return approval, commonerr.SetNewError(http.StatusForbidden, "self_approval", "a discount request must be decided by someone other than the requester")
+	}
+
+	now := time.Now()
+	locked.Status = status
+	locked.DecidedBy = userDetail.Email
+	locked.DecidedAt = &now
+	locked.DecisionNote = strings.TrimSpace(req.Note)
+	if err = u.DiscountDB.UpdateDiscountApproval(txCtx, locked); err != nil {
+		return approval, errors.Wrap(err, wrapMsgDecideRequest)
+	}
+	return *locked, nil
+}
+
+// AuthorizePriceChanges measures each manually discounted line as the share of
+// its gross amount it no longer charges. Discounts within the user's limit pass;
+// larger ones need an approved request for the same visit and product covering
+// the discount. Every manual change is audited, allowed or not by an approval.
+func (u *DiscountUC) AuthorizePriceChanges(ctx context.Context, changes []model.VisitLinePriceChange) error {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	manual := make([]model.VisitLinePriceChange, 0, len(changes))
+	for _, change := range changes {
+		if change.After.Quantity > 0 && change.IsManualChange() {
+			manual = append(manual, change)
+		}
+	}
+	if len(manual) == 0 {
+		return nil
+	}
+
+	limit, err := u.discountLimit(ctx, userDetail)
+	if err != nil {
+		return err
+	}
+
+	audits := make([]model.TrxPriceOverrideAudit, 0, len(manual))
+	for _, change := range manual {
+		gross := change.GrossAmount()
+		rate := model.EffectiveDiscountRate(gross, change.After.ChargedAmount())
+
+		var approvalID null.Int64
+		if !limit.Allows(rate) {
+			if !change.DiscountApprovalID.Valid {
+				return commonerr.SetNewError(http.StatusForbidden, "discount_approval_required",
+					fmt.Sprintf("discount of %.2f%% on %s exceeds your limit of %.2f%%; request a discount approval",
+						rate*100, change.After.Name, limit.MaxDiscountRate*100))
+			}
+			approval, err := u.DiscountDB.GetDiscountApprovalByID(ctx, userDetail.InstitutionID, change.DiscountApprovalID.Int64)
+			if err != nil {
+				return errors.Wrap(err, wrapMsgAuthorizePriceChanges)
+			}
+			if approval == nil || !approval.Covers(change.After.IDTrxPatientVisit, change.After.IDTrxInstitutionProduct, rate) {
+				return commonerr.SetNewError(http.StatusForbidden, "discount_approval_invalid",
+					fmt.Sprintf("discount approval %d does not allow a discount of %.2f%% on %s",
+						change.DiscountApprovalID.Int64, rate*100, change.After.Name))
+			}
+			approvalID = null.Int64From(approval.ID)
+		}
+
+		audits = append(audits, model.TrxPriceOverrideAudit{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxPatientVisit:       change.After.IDTrxPatientVisit,
+			IDTrxVisitProduct:       change.After.ID,
+			IDTrxInstitutionProduct: change.After.IDTrxInstitutionProduct,
+			GrossAmount:             gross,
+			OldDiscountRate:         change.Before.DiscountRate,
+			NewDiscountRate:         change.After.DiscountRate,
+			OldDiscountPrice:        change.Before.DiscountPrice,
+			NewDiscountPrice:        change.After.DiscountPrice,
+			OldAdjustedPrice:        change.Before.AdjustedPrice,
+			NewAdjustedPrice:        change.After.AdjustedPrice,
+			EffectiveDiscountRate:   rate,
+			Reason:                  strings.TrimSpace(change.Reason),
+			IDTrxDiscountApproval:   approvalID,
+			ChangedBy:               userDetail.Email,
+		})
+	}
+
+	if err = u.DiscountDB.InsertPriceOverrideAudits(ctx, audits); err != nil {
+		return errors.Wrap(err, wrapMsgAuthorizePriceChanges)
+	}
+	return nil
+}
+
+func (u *DiscountUC) ListPriceAudit(ctx context.Context, visitID int64) ([]model.TrxPriceOverrideAudit, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	audits, err := u.DiscountDB.ListPriceOverrideAudits(ctx, model.ListPriceOverrideAuditParams{
+		IDTrxPatientVisit: visitID,
+		IDMstInstitution:  userDetail.InstitutionID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListPriceAudit)
+	}
+	return audits, nil
+}
+
+// discountLimit is the user's discount cap; administrators are never capped.
+func (u *DiscountUC) discountLimit(ctx context.Context, userDetail model.UserJWTPayload) (model.DiscountLimit, error) {
+	userDetail.EnsureAuthSets()
+	if userDetail.RolesIDSet[roleconst.Administrator] {
+		return model.DiscountLimit{Unlimited: true}, nil
+	}
+
+	policies, err := u.DiscountDB.ListDiscountPolicies(ctx, userDetail.InstitutionID)
+	if err != nil {
+		return model.DiscountLimit{}, errors.Wrap(err, wrapMsgAuthorizePriceChanges)
+	}
+
+	roles := make([]string, 0, len(userDetail.Roles))
+	for _, role := range userDetail.Roles {
+		roles = append(roles, role.Name)
+	}
+	return model.DiscountLimitForRoles(policies, roles), nil
+}
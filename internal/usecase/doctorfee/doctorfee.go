@@ -0,0 +1,243 @@
+package doctorfee
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	doctorfeerepo "github.com/faisalhardin/medilink/internal/entity/repo/doctorfee"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	practitionerrepo "github.com/faisalhardin/medilink/internal/entity/repo/practitioner"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+)
+
+const (
+	wrapMsgListRules     = "DoctorFeeUC.ListRules"
+	wrapMsgSaveRules     = "DoctorFeeUC.SaveRules"
+	wrapMsgValidateRules = "DoctorFeeUC.validateRules"
+	wrapMsgGetReport     = "DoctorFeeUC.GetReport"
+
+	// doctorFeeReportMaxWindow keeps a report to about a quarter.
+	doctorFeeReportMaxWindow = 92 * 24 * time.Hour
+)
+
+type DoctorFeeUC struct {
+	DoctorFeeDB     doctorfeerepo.DoctorFeeDB
+	InstitutionRepo institutionrepo.InstitutionDB
+	PractitionerDB  practitionerrepo.PractitionerDB
+	Transaction     xormlib.DBTransactionInterface
+}
+
+func NewDoctorFeeUC(u *DoctorFeeUC) *DoctorFeeUC {
+	return u
+}
+
+func (u *DoctorFeeUC) ListRules(ctx context.Context) ([]model.MstDoctorFeeRule, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	rules, err := u.DoctorFeeDB.ListDoctorFeeRules(ctx, userDetail.InstitutionID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListRules)
+	}
+	return rules, nil
+}
+
+func (u *DoctorFeeUC) SaveRules(ctx context.Context, req model.SaveDoctorFeeRulesRequest) (rules []model.MstDoctorFeeRule, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	newRules, err := u.validateRules(ctx, userDetail.InstitutionID, req.Rules)
+	if err != nil {
+		return nil, err
+	}
+	for i := range newRules {
+		newRules[i].UpdatedBy = userDetail.Email
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	if err = u.DoctorFeeDB.ReplaceDoctorFeeRules(txCtx, userDetail.InstitutionID, newRules); err != nil {
+		return nil, errors.Wrap(err, wrapMsgSaveRules)
+	}
+
+	rules, err = u.DoctorFeeDB.ListDoctorFeeRules(txCtx, userDetail.InstitutionID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgSaveRules)
+	}
+	return rules, nil
+}
+
+// validateRules checks every rule on its own, that no two rules share a scope,
+// and that the doctors and treatment products they name exist.
+func (u *DoctorFeeUC) validateRules(ctx context.Context, institutionID int64, reqs []model.DoctorFeeRuleRequest) ([]model.MstDoctorFeeRule, error) {
+	errMsg := commonerr.NewErrorMessage()
+	seen := map[string]struct{}{}
+	doctorIDs := []string{}
+	productIDs := []int64{}
+	rules := make([]model.MstDoctorFeeRule, 0, len(reqs))
+
+	for i, req := range reqs {
+		field := fmt.Sprintf("rules[%d]", i)
+		rule := model.MstDoctorFeeRule{
+			IDMstInstitution: institutionID,
+			FeeType:          req.FeeType,
+			FeeAmount:        money.Zero,
+		}
+
+		if doctorID := strings.TrimSpace(req.DoctorID.String); req.DoctorID.Valid && doctorID != "" {
+			rule.DoctorID = null.StringFrom(doctorID)
+			doctorIDs = append(doctorIDs, doctorID)
+		}
+		if req.ProductID.Valid && req.ProductID.Int64 > 0 {
+			rule.IDTrxInstitutionProduct = req.ProductID
+			productIDs = append(productIDs, req.ProductID.Int64)
+		}
+		if req.ProcedureCategory.Valid && req.ProcedureCategory.String != "" {
+			rule.ProcedureCategory = req.ProcedureCategory
+		}
+		if rule.IDTrxInstitutionProduct.Valid && rule.ProcedureCategory.Valid {
+			errMsg.Append(field+".product_id", "a rule names either a product or a procedure category, not both")
+			continue
+		}
+
+		switch req.FeeType {
+		case model.DoctorFeeTypePercentage:
+			if req.FeeRate <= 0 || req.FeeRate > 1 {
+				errMsg.Append(field+".fee_rate", "fee_rate must be a fraction between 0 and 1, e.g. 0.3")
+				continue
+			}
+			rule.FeeRate = req.FeeRate
+		case model.DoctorFeeTypeFixed:
+			if !req.FeeAmount.IsPositive() {
+				errMsg.Append(field+".fee_amount", "fee_amount must be positive")
+				continue
+			}
+			rule.FeeAmount = req.FeeAmount.Round()
+		default:
+			errMsg.Append(field+".fee_type", "fee_type must be percentage or fixed")
+			continue
+		}
+
+		scope := fmt.Sprintf("%s|%d|%s", rule.DoctorID.String, rule.IDTrxInstitutionProduct.Int64, rule.ProcedureCategory.String)
+		if _, dup := seen[scope]; dup {
+			errMsg.Append(field, "another rule has the same doctor, product and procedure category")
+			continue
+		}
+		seen[scope] = struct{}{}
+
+		rules = append(rules, rule)
+	}
+
+	if len(doctorIDs) > 0 {
+		missing, err := u.PractitionerDB.MissingDoctorIDs(ctx, institutionID, doctorIDs)
+		if err != nil {
+			return nil, errors.Wrap(err, wrapMsgValidateRules)
+		}
+		missingSet := make(map[string]struct{}, len(missing))
+		for _, id := range missing {
+			missingSet[id] = struct{}{}
+		}
+		for i, req := range reqs {
+			if _, miss := missingSet[strings.TrimSpace(req.DoctorID.String)]; miss && req.DoctorID.Valid {
+				errMsg.Append(fmt.Sprintf("rules[%d].doctor_id", i), "doctor_id does not exist")
+			}
+		}
+	}
+
+	if len(productIDs) > 0 {
+		products, err := u.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+			IDs:              productIDs,
+			IDMstInstitution: institutionID,
+			IsTreatment:      true,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, wrapMsgValidateRules)
+		}
+		found := make(map[int64]struct{}, len(products))
+		for _, product := range products {
+			found[product.ID] = struct{}{}
+		}
+		for i, req := range reqs {
+			if _, ok := found[req.ProductID.Int64]; req.ProductID.Valid && req.ProductID.Int64 > 0 && !ok {
+				errMsg.Append(fmt.Sprintf("rules[%d].product_id", i), "product_id does not exist as a treatment product in this institution")
+			}
+		}
+	}
+
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return nil, errMsg
+	}
+	return rules, nil
+}
+
+func (u *DoctorFeeUC) GetReport(ctx context.Context, params model.DoctorFeeReportParams) (report model.DoctorFeeReport, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return report, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if params.StartTime.IsZero() {
+		return report, commonerr.SetNewBadRequest("invalid start_time", "start_time must be RFC3339 with timezone offset")
+	}
+	if params.EndTime.IsZero() {
+		return report, commonerr.SetNewBadRequest("invalid end_time", "end_time must be RFC3339 with timezone offset")
+	}
+	if !params.EndTime.After(params.StartTime.Time) {
+		return report, commonerr.SetNewBadRequest("invalid time range", "end_time must be after start_time")
+	}
+	if params.EndTime.Sub(params.StartTime.Time) > doctorFeeReportMaxWindow {
+		return report, commonerr.SetNewBadRequest("invalid time range", "time range must not exceed 92 days")
+	}
+
+	query := model.DoctorFeeReportQuery{
+		IDMstInstitution: userDetail.InstitutionID,
+		StartTime:        params.StartTime.Time,
+		EndTime:          params.EndTime.Time,
+		DoctorID:         strings.TrimSpace(params.DoctorID),
+	}
+
+	rules, err := u.DoctorFeeDB.ListDoctorFeeRules(ctx, userDetail.InstitutionID)
+	if err != nil {
+		return report, errors.Wrap(err, wrapMsgGetReport)
+	}
+	// fees are paid on visits whose patient share is settled; a payer's share
+	// is owed by the payer and does not hold the fee back
+	visits, err := u.DoctorFeeDB.ListVisitTotals(ctx, query)
+	if err != nil {
+		return report, errors.Wrap(err, wrapMsgGetReport)
+	}
+	paidVisitIDs := []int64{}
+	for _, visit := range visits {
+		if visit.IsPaid() {
+			paidVisitIDs = append(paidVisitIDs, visit.IDTrxPatientVisit)
+		}
+	}
+
+	rows := []model.DoctorFeeProcedureRow{}
+	if len(paidVisitIDs) > 0 {
+		rows, err = u.DoctorFeeDB.ListPaidVisitProcedures(ctx, query, paidVisitIDs)
+		if err != nil {
+			return report, errors.Wrap(err, wrapMsgGetReport)
+		}
+	}
+
+	report = model.NewDoctorFeeReport(rows, rules)
+	report.StartTime = query.StartTime
+	report.EndTime = query.EndTime
+	return report, nil
+}
@@ -0,0 +1,83 @@
+package doctorfee
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	doctorfeerepo "github.com/faisalhardin/medilink/internal/entity/repo/doctorfee"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	customtime "github.com/faisalhardin/medilink/pkg/type/time"
+	"github.com/volatiletech/null/v8"
+)
+
+// doctorFeeDB serves the visit totals it holds and records which visits the
+// procedures were asked for.
+type doctorFeeDB struct {
+	doctorfeerepo.DoctorFeeDB
+
+	visits       []model.DoctorFeeVisitTotals
+	paidVisitIDs []int64
+}
+
+func (db *doctorFeeDB) ListDoctorFeeRules(ctx context.Context, institutionID int64) ([]model.MstDoctorFeeRule, error) {
+	return nil, nil
+}
+
+func (db *doctorFeeDB) ListVisitTotals(ctx context.Context, query model.DoctorFeeReportQuery) ([]model.DoctorFeeVisitTotals, error) {
+	return db.visits, nil
+}
+
+func (db *doctorFeeDB) ListPaidVisitProcedures(ctx context.Context, query model.DoctorFeeReportQuery, visitIDs []int64) ([]model.DoctorFeeProcedureRow, error) {
+	db.paidVisitIDs = visitIDs
+	return nil, nil
+}
+
+func TestGetReportPaidVisits(t *testing.T) {
+	t.Parallel()
+
+	insured := func(id int64, rate float64, limit money.NullMoney, total, paid int64) model.DoctorFeeVisitTotals {
+		return model.DoctorFeeVisitTotals{
+			IDTrxPatientVisit:  id,
+			IDMstPatientPayer:  null.Int64From(9),
+			PayerType:          model.PayerTypeInsurance,
+			PayerCoverageRate:  rate,
+			PayerCoverageLimit: limit,
+			InvoiceTotal:       money.New(total),
+			TotalPaid:          money.New(paid),
+		}
+	}
+	db := &doctorFeeDB{visits: []model.DoctorFeeVisitTotals{
+		// covered in full, nothing for the patient to pay
+		insured(1, 1, money.NullMoney{}, 250000, 0),
+		// covered for 80%, the patient paid their 20%
+		insured(2, 0.8, money.NullMoney{}, 100000, 20000),
+		// covered up to the limit, the patient paid less than the rest
+		insured(3, 1, money.NullMoneyFrom(money.New(50000)), 100000, 20000),
+		// covered up to the limit, the patient paid the rest
+		insured(4, 1, money.NullMoneyFrom(money.New(50000)), 100000, 50000),
+		// self-pay, paid in full and not paid at all
+		{IDTrxPatientVisit: 5, InvoiceTotal: money.New(75000), TotalPaid: money.New(75000)},
+		{IDTrxPatientVisit: 6, InvoiceTotal: money.New(75000), TotalPaid: money.Zero},
+		// no charge, nothing to pay a fee on
+		insured(7, 1, money.NullMoney{}, 0, 0),
+	}}
+	uc := &DoctorFeeUC{DoctorFeeDB: db}
+
+	ctx := auth.SetUserDetailToCtx(context.Background(), model.UserJWTPayload{InstitutionID: 3})
+	start := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	_, err := uc.GetReport(ctx, model.DoctorFeeReportParams{
+		StartTime: customtime.Time{Time: start},
+		EndTime:   customtime.Time{Time: start.AddDate(0, 1, 0)},
+	})
+	if err != nil {
+		t.Fatalf("GetReport() = %v", err)
+	}
+
+	if want := []int64{1, 2, 4, 5}; !reflect.DeepEqual(db.paidVisitIDs, want) {
+		t.Fatalf("procedures listed for visits %v, want %v", db.paidVisitIDs, want)
+	}
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
 	institutionRepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	journeyRepo "github.com/faisalhardin/medilink/internal/entity/repo/journey"
+	pricelistRepo "github.com/faisalhardin/medilink/internal/entity/repo/pricelist"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	"github.com/faisalhardin/medilink/internal/library/db/xorm"
 	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
@@ -22,6 +24,8 @@ var (
 
 type InstitutionUC struct {
 	InstitutionRepo institutionRepo.InstitutionDB
+	PriceListDB     pricelistRepo.PriceListDB
+	JourneyRepo     journeyRepo.JourneyDB
 	Transaction     xorm.DBTransactionInterface
 }
 
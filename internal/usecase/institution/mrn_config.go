@@ -0,0 +1,70 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+)
+
+var (
+	WrapMsgGetMRNConfig    = WrapErrMsgPrefix + "GetMRNConfig"
+	WrapMsgUpdateMRNConfig = WrapErrMsgPrefix + "UpdateMRNConfig"
+)
+
+func (uc *InstitutionUC) GetMRNConfig(ctx context.Context) (config model.MstInstitutionMRNConfig, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	config, err = uc.InstitutionRepo.GetInstitutionMRNConfig(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetMRNConfig)
+		return
+	}
+
+	return
+}
+
+// UpdateMRNConfig replaces the institution's medical record number format.
+// Numbers already issued keep their format; new registrations use the new one.
+func (uc *InstitutionUC) UpdateMRNConfig(ctx context.Context, request model.UpdateMRNConfigRequest) (config model.MstInstitutionMRNConfig, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	if request.SequenceDigits < 3 || request.SequenceDigits > 10 {
+		errMsg.Append("sequence_digits", "sequence_digits must be between 3 and 10")
+	}
+	if request.Prefix == "" && !request.IncludeYear && request.Separator != "" {
+		errMsg.Append("separator", "a format of only the sequence has nothing to separate")
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	config = model.MstInstitutionMRNConfig{
+		IDMstInstitution: userDetail.InstitutionID,
+		Prefix:           request.Prefix,
+		Separator:        request.Separator,
+		IncludeYear:      request.IncludeYear,
+		SequenceDigits:   request.SequenceDigits,
+		UpdatedBy:        userDetail.Email,
+	}
+	err = uc.InstitutionRepo.UpsertInstitutionMRNConfig(ctx, &config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdateMRNConfig)
+		return
+	}
+
+	return
+}
@@ -2,12 +2,14 @@ package institution
 
 import (
 	"context"
+	"strings"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	"github.com/faisalhardin/medilink/internal/library/db/xorm"
 	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
 	"github.com/friendsofgo/errors"
+	"github.com/volatiletech/null/v8"
 )
 
 func (uc *InstitutionUC) InserInstitutionProduct(ctx context.Context, request model.InsertInstitutionProductRequest) (resp model.TrxInstitutionProduct, err error) {
@@ -21,13 +23,19 @@ func (uc *InstitutionUC) InserInstitutionProduct(ctx context.Context, request mo
 	defer uc.Transaction.Finish(session, &err)
 	ctx = xorm.SetDBSession(ctx, session)
 
+	sku := strings.TrimSpace(request.SKU)
 	product := model.TrxInstitutionProduct{
 		Name:             request.Name,
+		SKU:              null.NewString(sku, sku != ""),
 		IDMstProduct:     request.IDMstProduct,
 		IDMstInstitution: userDetail.InstitutionID,
-		Price:            request.Price,
+		Price:            request.Price.Round(),
 		IsItem:           request.IsItem,
 		IsTreatment:      request.IsTreatment,
+		TaxCategory:      request.TaxCategory,
+	}
+	if product.TaxCategory == "" {
+		product.TaxCategory = model.TaxCategoryExempt
 	}
 
 	if !request.IsItem {
@@ -51,6 +59,32 @@ func (uc *InstitutionUC) InserInstitutionProduct(ctx context.Context, request mo
 		return
 	}
 
+	// the opening quantity is booked at the main location, like an imported product
+	if request.IsItem && request.Quantity > 0 {
+		err = uc.InstitutionRepo.InsertStockMovements(ctx, []model.TrxStockMovement{{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: product.ID,
+			MovementType:            model.StockMovementTypeCatalogImport,
+			Quantity:                request.Quantity,
+			CreatedBy:               userDetail.Email,
+		}})
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgInserInstitutionProduct)
+			return
+		}
+	}
+
+	err = uc.PriceListDB.InsertProductPriceHistory(ctx, &model.TrxProductPriceHistory{
+		IDMstInstitution:        userDetail.InstitutionID,
+		IDTrxInstitutionProduct: product.ID,
+		NewPrice:                product.Price,
+		ChangedBy:               userDetail.Email,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInserInstitutionProduct)
+		return
+	}
+
 	return product, nil
 
 }
@@ -66,15 +100,61 @@ func (uc *InstitutionUC) FindInstitutionProductByParams(ctx context.Context, par
 }
 
 func (uc *InstitutionUC) UpdateInstitutionProduct(ctx context.Context, request model.UpdateInstitutionProductRequest) (err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	if request.TaxCategory.Valid && !model.IsValidTaxCategory(request.TaxCategory.String) {
+		err = commonerr.SetNewBadRequest("invalid tax category", "tax_category must be exempt or ppn")
+		return
+	}
 
 	session, err := uc.Transaction.Begin(ctx)
 	defer uc.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	// a zero price leaves the current price untouched, so only a real change is logged
+	var priceHistory *model.TrxProductPriceHistory
+	if !request.Price.IsZero() {
+		var products []model.TrxInstitutionProduct
+		products, err = uc.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+			IDs:              []int64{request.ID},
+			IDMstInstitution: userDetail.InstitutionID,
+		})
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgUpdateInstitutionProduct)
+			return
+		}
+		if len(products) == 0 {
+			err = commonerr.SetNewBadRequest("product_not_found", "product was not found in this institution")
+			return
+		}
+		if newPrice := request.Price.Round(); !products[0].Price.Equal(newPrice) {
+			priceHistory = &model.TrxProductPriceHistory{
+				IDMstInstitution:        userDetail.InstitutionID,
+				IDTrxInstitutionProduct: request.ID,
+				OldPrice:                products[0].Price,
+				NewPrice:                newPrice,
+				ChangedBy:               userDetail.Email,
+			}
+		}
+	}
 
 	_, err = uc.InstitutionRepo.UpdateTrxInstitutionProduct(ctx, &request)
 	if err != nil {
 		return err
 	}
 
+	if priceHistory != nil {
+		err = uc.PriceListDB.InsertProductPriceHistory(ctx, priceHistory)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgUpdateInstitutionProduct)
+			return
+		}
+	}
+
 	if !request.UnitType.Valid {
 		return nil
 	}
@@ -101,19 +181,9 @@ func (uc *InstitutionUC) UpdateInstitutionProductStock(ctx context.Context, requ
 		return
 	}
 
-	productIDs := make([]int64, len(request.Products))
-	for _, product := range request.Products {
-		productIDs = append(productIDs, product.IDTrxInstitutionProduct)
-	}
-
-	productStocks, err := uc.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
-		IDMstProducts:    productIDs,
-		IDMstInstitution: userDetail.InstitutionID,
-	})
-
-	if len(productStocks) == 0 {
-		err = commonerr.SetNewBadRequest("product invalid", "One or more products are invalid")
-		return
+	conversions, err := uc.getProductUnitConversions(ctx, userDetail.InstitutionID, request.Products)
+	if err != nil {
+		return err
 	}
 
 	// update product stock
@@ -121,15 +191,31 @@ func (uc *InstitutionUC) UpdateInstitutionProductStock(ctx context.Context, requ
 	defer uc.Transaction.Finish(session, &err)
 	ctx = xorm.SetDBSession(ctx, session)
 
+	movements := make([]model.TrxStockMovement, 0, len(request.Products))
 	for _, product := range request.Products {
+		// resupply may be entered in any configured unit; stock is kept in the base unit
+		factor, _ := conversions[product.IDTrxInstitutionProduct].Factor(product.UnitType)
 		productStock := model.DtlInstitutionProductStock{
 			IDTrxInstitutionProduct: product.IDTrxInstitutionProduct,
-			Quantity:                product.Quantity,
+			Quantity:                product.Quantity * factor,
 		}
 		err = uc.InstitutionRepo.RestockDtlInstitutionProductStock(ctx, &productStock)
 		if err != nil {
 			return err
 		}
+
+		movements = append(movements, model.TrxStockMovement{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: product.IDTrxInstitutionProduct,
+			MovementType:            model.StockMovementTypeResupply,
+			Quantity:                productStock.Quantity,
+			CreatedBy:               userDetail.Email,
+		})
+	}
+
+	err = uc.InstitutionRepo.InsertStockMovements(ctx, movements)
+	if err != nil {
+		return err
 	}
 
 	return
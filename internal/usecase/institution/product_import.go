@@ -0,0 +1,199 @@
+package institution
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+)
+
+var (
+	WrapMsgImportProducts     = WrapErrMsgPrefix + "ImportProducts"
+	WrapMsgListProductCatalog = WrapErrMsgPrefix + "ListProductCatalog"
+)
+
+// ImportProducts creates and updates products from the rows of a catalog file
+// and sets the initial stock of the ones it creates. Every line is checked
+// before anything is written: an import with an invalid line writes nothing
+// and reports each such line, and a dry run only reports what it would do.
+func (uc *InstitutionUC) ImportProducts(ctx context.Context, request model.ProductImportRequest) (result model.ProductImportResult, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	rows, lineErrors := model.ParseProductImportRows(request.Rows)
+	catalog, err := uc.InstitutionRepo.FindProductCatalog(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgImportProducts)
+		return
+	}
+	plan := model.PlanProductImport(rows, catalog)
+	plan.Errors = append(lineErrors, plan.Errors...)
+	sort.SliceStable(plan.Errors, func(i, j int) bool {
+		return plan.Errors[i].Line < plan.Errors[j].Line
+	})
+
+	if request.DryRun {
+		return plan.Result(true), nil
+	}
+	if len(plan.Errors) > 0 {
+		errMsg := commonerr.NewErrorMessage()
+		for _, lineErr := range plan.Errors {
+			name := fmt.Sprintf("line %d", lineErr.Line)
+			if lineErr.Column != "" {
+				name += "." + lineErr.Column
+			}
+			errMsg.Append(name, lineErr.Message)
+		}
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	session, err := uc.Transaction.Begin(ctx)
+	defer uc.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	movements := []model.TrxStockMovement{}
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		switch item.Action {
+		case model.ProductImportActionCreate:
+			if err = uc.createImportedProduct(ctx, userDetail.InstitutionID, userDetail.Email, item); err != nil {
+				return
+			}
+			if item.Row.IsItem && item.Row.Quantity > 0 {
+				movements = append(movements, model.TrxStockMovement{
+					IDMstInstitution:        userDetail.InstitutionID,
+					IDTrxInstitutionProduct: item.ProductID,
+					MovementType:            model.StockMovementTypeCatalogImport,
+					Quantity:                item.Row.Quantity,
+					UnitCost:                item.Row.UnitCost,
+					CreatedBy:               userDetail.Email,
+				})
+			}
+		case model.ProductImportActionUpdate:
+			if err = uc.updateImportedProduct(ctx, userDetail.InstitutionID, userDetail.Email, *item); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = uc.InstitutionRepo.InsertStockMovements(ctx, movements); err != nil {
+		err = errors.Wrap(err, WrapMsgImportProducts)
+		return
+	}
+	return plan.Result(false), nil
+}
+
+// createImportedProduct inserts the product of item the way InserInstitutionProduct
+// does, with the row's quantity and unit cost as its opening stock.
+func (uc *InstitutionUC) createImportedProduct(ctx context.Context, institutionID int64, email string, item *model.ProductImportItem) (err error) {
+	row := item.Row
+	product := model.TrxInstitutionProduct{
+		Name:             row.Name,
+		SKU:              null.NewString(row.SKU, row.SKU != ""),
+		IDMstInstitution: institutionID,
+		Price:            row.Price,
+		IsItem:           row.IsItem,
+		IsTreatment:      row.IsTreatment,
+		TaxCategory:      row.TaxCategory,
+	}
+	if err = uc.InstitutionRepo.InsertInstitutionProduct(ctx, &product); err != nil {
+		return errors.Wrap(err, WrapMsgImportProducts)
+	}
+	item.ProductID = product.ID
+
+	stock := model.DtlInstitutionProductStock{
+		IDTrxInstitutionProduct: product.ID,
+		Quantity:                row.Quantity,
+		UnitType:                row.UnitType,
+		AvgCost:                 row.UnitCost,
+	}
+	if !row.IsItem {
+		stock.Quantity, stock.AvgCost = 1, money.Zero
+	}
+	if err = uc.InstitutionRepo.InsertInstitutionProductStock(ctx, &stock); err != nil {
+		return errors.Wrap(err, WrapMsgImportProducts)
+	}
+
+	err = uc.PriceListDB.InsertProductPriceHistory(ctx, &model.TrxProductPriceHistory{
+		IDMstInstitution:        institutionID,
+		IDTrxInstitutionProduct: product.ID,
+		NewPrice:                product.Price,
+		ChangedBy:               email,
+	})
+	if err != nil {
+		return errors.Wrap(err, WrapMsgImportProducts)
+	}
+	return nil
+}
+
+func (uc *InstitutionUC) updateImportedProduct(ctx context.Context, institutionID int64, email string, item model.ProductImportItem) (err error) {
+	row, current := item.Row, item.Current
+
+	request := model.UpdateInstitutionProductRequest{
+		ID:          current.ID,
+		Name:        row.Name,
+		Price:       row.Price,
+		IsItem:      null.BoolFrom(row.IsItem),
+		IsTreatment: null.BoolFrom(row.IsTreatment),
+		TaxCategory: null.StringFrom(row.TaxCategory),
+		SKU:         null.NewString(row.SKU, row.SKU != ""),
+		// the update writes the master product even when unset
+		IDMstProduct: current.IDMstProduct,
+	}
+	if _, err = uc.InstitutionRepo.UpdateTrxInstitutionProduct(ctx, &request); err != nil {
+		return errors.Wrap(err, WrapMsgImportProducts)
+	}
+
+	if !row.Price.Equal(current.Price) {
+		err = uc.PriceListDB.InsertProductPriceHistory(ctx, &model.TrxProductPriceHistory{
+			IDMstInstitution:        institutionID,
+			IDTrxInstitutionProduct: current.ID,
+			OldPrice:                current.Price,
+			NewPrice:                row.Price,
+			ChangedBy:               email,
+		})
+		if err != nil {
+			return errors.Wrap(err, WrapMsgImportProducts)
+		}
+	}
+
+	if row.UnitType != current.UnitType {
+		err = uc.InstitutionRepo.UpdateDtlInstitutionProduct(ctx, &model.DtlInstitutionProductStock{
+			IDTrxInstitutionProduct: current.ID,
+			UnitType:                row.UnitType,
+		})
+		if err != nil {
+			return errors.Wrap(err, WrapMsgImportProducts)
+		}
+	}
+	return nil
+}
+
+// ListProductCatalog returns every product of the institution, as catalog
+// exports write them.
+func (uc *InstitutionUC) ListProductCatalog(ctx context.Context) (products []model.GetInstitutionProductResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	products, err = uc.InstitutionRepo.FindProductCatalog(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListProductCatalog)
+		return
+	}
+	return
+}
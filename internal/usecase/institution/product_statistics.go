@@ -9,6 +9,7 @@ import (
 	"github.com/faisalhardin/medilink/internal/entity/model"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	"github.com/pkg/errors"
 )
 
@@ -18,7 +19,8 @@ const (
 )
 
 var (
-	WrapMsgGetProductStatistics = WrapErrMsgPrefix + "GetProductStatistics"
+	WrapMsgGetProductStatistics    = WrapErrMsgPrefix + "GetProductStatistics"
+	WrapMsgStreamProductStatistics = WrapErrMsgPrefix + "StreamProductStatistics"
 )
 
 func (uc *InstitutionUC) GetProductStatistics(ctx context.Context, params model.ProductStatisticsParams) (result model.ProductStatisticsResponse, err error) {
@@ -34,7 +36,12 @@ func (uc *InstitutionUC) GetProductStatistics(ctx context.Context, params model.
 	}
 	query.IDMstInstitution = userDetail.InstitutionID
 
-	rows, err := uc.InstitutionRepo.GetProductStatistics(ctx, query)
+	var rows []model.ProductStatisticsRow
+	if isRollupGranularity(query.Granularity) {
+		rows, err = uc.InstitutionRepo.GetProductStatisticsFromRollups(ctx, query)
+	} else {
+		rows, err = uc.InstitutionRepo.GetProductStatistics(ctx, query)
+	}
 	if err != nil {
 		err = errors.Wrap(err, WrapMsgGetProductStatistics)
 		return
@@ -44,6 +51,46 @@ func (uc *InstitutionUC) GetProductStatistics(ctx context.Context, params model.
 	return
 }
 
+// BuildProductStatisticsQuery validates params as GetProductStatistics does
+// and scopes them to the user's institution, ready for StreamProductStatistics.
+func (uc *InstitutionUC) BuildProductStatisticsQuery(ctx context.Context, params model.ProductStatisticsParams) (query model.ProductStatisticsQuery, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	query, _, _, err = buildProductStatisticsQuery(params)
+	if err != nil {
+		return
+	}
+	query.IDMstInstitution = userDetail.InstitutionID
+	return
+}
+
+// StreamProductStatistics passes every product of every bucket of query to fn
+// as it is read, in period order, without holding the statistics in memory.
+func (uc *InstitutionUC) StreamProductStatistics(ctx context.Context, query model.ProductStatisticsQuery, fn func(model.ProductStatisticsLine) error) (err error) {
+	loc := query.StartTime.Location()
+	streamRow := func(row model.ProductStatisticsRow) error {
+		return fn(model.ProductStatisticsLine{
+			PeriodStart:                  inLocation(row.PeriodStart, loc),
+			PeriodEnd:                    inLocation(bucketPeriodEnd(row.PeriodStart, query.Granularity), loc),
+			ProductStatisticsProductItem: productStatisticsItem(row),
+		})
+	}
+
+	if isRollupGranularity(query.Granularity) {
+		err = uc.InstitutionRepo.IterateProductStatisticsFromRollups(ctx, query, streamRow)
+	} else {
+		err = uc.InstitutionRepo.IterateProductStatistics(ctx, query, streamRow)
+	}
+	if err != nil {
+		return errors.Wrap(err, WrapMsgStreamProductStatistics)
+	}
+	return
+}
+
 func buildProductStatisticsQuery(params model.ProductStatisticsParams) (query model.ProductStatisticsQuery, startLocal, endLocal time.Time, err error) {
 	if params.StartTime.IsZero() {
 		err = commonerr.SetNewBadRequest("invalid start_time", "start_time must be RFC3339 with timezone offset")
@@ -62,11 +109,6 @@ func buildProductStatisticsQuery(params model.ProductStatisticsParams) (query mo
 		return
 	}
 
-	if endLocal.Sub(startLocal) > productStatisticsMaxWindow {
-		err = commonerr.SetNewBadRequest("invalid time range", "time range must not exceed 31 days")
-		return
-	}
-
 	granularity := params.Granularity
 	if granularity == "" {
 		granularity = model.ProductStatisticsGranularityDay
@@ -74,9 +116,11 @@ func buildProductStatisticsQuery(params model.ProductStatisticsParams) (query mo
 	switch granularity {
 	case model.ProductStatisticsGranularityHour,
 		model.ProductStatisticsGranularityDay,
-		model.ProductStatisticsGranularityWeek:
+		model.ProductStatisticsGranularityWeek,
+		model.ProductStatisticsGranularityMonth,
+		model.ProductStatisticsGranularityYear:
 	default:
-		err = commonerr.SetNewBadRequest("invalid granularity", "granularity must be hour, day, or week")
+		err = commonerr.SetNewBadRequest("invalid granularity", "granularity must be hour, day, week, month, or year")
 		return
 	}
 
@@ -85,10 +129,51 @@ func buildProductStatisticsQuery(params model.ProductStatisticsParams) (query mo
 		EndTime:                 endLocal,
 		Granularity:             granularity,
 		IDTrxInstitutionProduct: params.ProductID,
+		UTCOffsetSeconds:        zoneOffsetSeconds(startLocal),
+	}
+
+	if !isRollupGranularity(granularity) {
+		if endLocal.Sub(startLocal) > productStatisticsMaxWindow {
+			err = commonerr.SetNewBadRequest("invalid time range", "time range must not exceed 31 days")
+			return
+		}
+		return
+	}
+
+	// rollups hold whole local days of a few offsets: the range covers the
+	// days from start_time to end_time, excluding a day end_time only opens
+	if !isRollupOffset(query.UTCOffsetSeconds) {
+		err = commonerr.SetNewBadRequest("invalid start_time",
+			"monthly and yearly statistics are available for UTC offsets +07:00, +08:00 and +09:00")
+		return
+	}
+	endLocal = endLocal.In(startLocal.Location())
+	if endLocal.Equal(startOfDay(endLocal)) {
+		query.EndTime = endLocal.AddDate(0, 0, -1)
+	} else {
+		query.EndTime = endLocal
 	}
 	return
 }
 
+func isRollupGranularity(granularity string) bool {
+	return granularity == model.ProductStatisticsGranularityMonth ||
+		granularity == model.ProductStatisticsGranularityYear
+}
+
+func isRollupOffset(offsetSec int) bool {
+	for _, offset := range model.ProductStatisticsRollupOffsets {
+		if offset == offsetSec {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
 func assembleProductStatisticsResponse(
 	query model.ProductStatisticsQuery,
 	startLocal, endLocal time.Time,
@@ -100,7 +185,8 @@ func assembleProductStatisticsResponse(
 	buckets := make([]model.ProductStatisticsBucket, 0)
 	summaryByProduct := make(map[int64]*model.ProductStatisticsSummaryItem)
 
-	var summaryRevenue float64
+	summaryRevenue, summaryCost := money.Zero, money.Zero
+	summaryTax, summaryServiceCharge := money.Zero, money.Zero
 	var summaryQuantity int64
 
 	for _, row := range rows {
@@ -116,35 +202,45 @@ func assembleProductStatisticsResponse(
 			bucketIndex[row.PeriodStart] = idx
 		}
 
-		item := model.ProductStatisticsProductItem{
-			ProductID:     row.IDTrxInstitutionProduct,
-			Name:          row.Name,
-			UnitPrice:     row.AvgUnitPrice,
-			TotalQuantity: row.TotalQuantity,
-			TotalRevenue:  row.TotalRevenue,
-		}
+		item := productStatisticsItem(row)
+		margin := item.TotalMargin
 		buckets[idx].Products = append(buckets[idx].Products, item)
-		buckets[idx].TotalRevenue += row.TotalRevenue
+		buckets[idx].TotalRevenue = buckets[idx].TotalRevenue.Add(row.TotalRevenue)
+		buckets[idx].TotalCost = buckets[idx].TotalCost.Add(row.TotalCost)
+		buckets[idx].TotalMargin = buckets[idx].TotalMargin.Add(margin)
+		buckets[idx].TotalTax = buckets[idx].TotalTax.Add(row.TotalTax)
+		buckets[idx].TotalServiceCharge = buckets[idx].TotalServiceCharge.Add(row.TotalServiceCharge)
 		buckets[idx].TotalQuantity += row.TotalQuantity
 
-		summaryRevenue += row.TotalRevenue
+		summaryRevenue = summaryRevenue.Add(row.TotalRevenue)
+		summaryCost = summaryCost.Add(row.TotalCost)
+		summaryTax = summaryTax.Add(row.TotalTax)
+		summaryServiceCharge = summaryServiceCharge.Add(row.TotalServiceCharge)
 		summaryQuantity += row.TotalQuantity
 
 		agg, exists := summaryByProduct[row.IDTrxInstitutionProduct]
 		if !exists {
 			summaryByProduct[row.IDTrxInstitutionProduct] = &model.ProductStatisticsSummaryItem{
-				ProductID:     row.IDTrxInstitutionProduct,
-				Name:          row.Name,
-				UnitPrice:     row.AvgUnitPrice,
-				TotalQuantity: row.TotalQuantity,
-				TotalRevenue:  row.TotalRevenue,
+				ProductID:          row.IDTrxInstitutionProduct,
+				Name:               row.Name,
+				UnitPrice:          row.AvgUnitPrice.Round(),
+				TotalQuantity:      row.TotalQuantity,
+				TotalRevenue:       row.TotalRevenue,
+				TotalCost:          row.TotalCost,
+				TotalMargin:        margin,
+				TotalTax:           row.TotalTax,
+				TotalServiceCharge: row.TotalServiceCharge,
 			}
 			continue
 		}
 		agg.TotalQuantity += row.TotalQuantity
-		agg.TotalRevenue += row.TotalRevenue
+		agg.TotalRevenue = agg.TotalRevenue.Add(row.TotalRevenue)
+		agg.TotalCost = agg.TotalCost.Add(row.TotalCost)
+		agg.TotalMargin = agg.TotalMargin.Add(margin)
+		agg.TotalTax = agg.TotalTax.Add(row.TotalTax)
+		agg.TotalServiceCharge = agg.TotalServiceCharge.Add(row.TotalServiceCharge)
 		if agg.TotalQuantity > 0 {
-			agg.UnitPrice = agg.TotalRevenue / float64(agg.TotalQuantity)
+			agg.UnitPrice = agg.TotalRevenue.Div(agg.TotalQuantity).Round()
 		}
 	}
 
@@ -163,6 +259,10 @@ func assembleProductStatisticsResponse(
 		Buckets:     buckets,
 		Summary: model.ProductStatisticsSummary{
 			TotalRevenue:          summaryRevenue,
+			TotalCost:             summaryCost,
+			TotalMargin:           summaryRevenue.Sub(summaryCost),
+			TotalTax:              summaryTax,
+			TotalServiceCharge:    summaryServiceCharge,
 			TotalQuantity:         summaryQuantity,
 			TopProductsByRevenue:  topProductSummaryItems(summaryItems, productStatisticsTopN, true),
 			TopProductsByQuantity: topProductSummaryItems(summaryItems, productStatisticsTopN, false),
@@ -170,6 +270,20 @@ func assembleProductStatisticsResponse(
 	}
 }
 
+func productStatisticsItem(row model.ProductStatisticsRow) model.ProductStatisticsProductItem {
+	return model.ProductStatisticsProductItem{
+		ProductID:          row.IDTrxInstitutionProduct,
+		Name:               row.Name,
+		UnitPrice:          row.AvgUnitPrice.Round(),
+		TotalQuantity:      row.TotalQuantity,
+		TotalRevenue:       row.TotalRevenue,
+		TotalCost:          row.TotalCost,
+		TotalMargin:        row.TotalRevenue.Sub(row.TotalCost),
+		TotalTax:           row.TotalTax,
+		TotalServiceCharge: row.TotalServiceCharge,
+	}
+}
+
 func topProductSummaryItems(items []model.ProductStatisticsSummaryItem, n int, byRevenue bool) []model.ProductStatisticsSummaryItem {
 	if len(items) == 0 {
 		return []model.ProductStatisticsSummaryItem{}
@@ -177,13 +291,13 @@ func topProductSummaryItems(items []model.ProductStatisticsSummaryItem, n int, b
 
 	sort.Slice(items, func(i, j int) bool {
 		if byRevenue {
-			if items[i].TotalRevenue == items[j].TotalRevenue {
+			if items[i].TotalRevenue.Equal(items[j].TotalRevenue) {
 				return items[i].TotalQuantity > items[j].TotalQuantity
 			}
-			return items[i].TotalRevenue > items[j].TotalRevenue
+			return items[i].TotalRevenue.GreaterThan(items[j].TotalRevenue)
 		}
 		if items[i].TotalQuantity == items[j].TotalQuantity {
-			return items[i].TotalRevenue > items[j].TotalRevenue
+			return items[i].TotalRevenue.GreaterThan(items[j].TotalRevenue)
 		}
 		return items[i].TotalQuantity > items[j].TotalQuantity
 	})
@@ -200,13 +314,23 @@ func bucketPeriodEnd(start time.Time, granularity string) time.Time {
 		return start.Add(time.Hour).Add(-time.Second)
 	case model.ProductStatisticsGranularityWeek:
 		return start.Add(7 * 24 * time.Hour).Add(-time.Second)
+	case model.ProductStatisticsGranularityMonth:
+		return start.AddDate(0, 1, 0).Add(-time.Second)
+	case model.ProductStatisticsGranularityYear:
+		return start.AddDate(1, 0, 0).Add(-time.Second)
 	default:
 		return start.Add(24 * time.Hour).Add(-time.Second)
 	}
 }
 
 func formatTimeInLocation(t time.Time, loc *time.Location) string {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc).Format(time.RFC3339)
+	return inLocation(t, loc).Format(time.RFC3339)
+}
+
+// inLocation reads the wall clock of t, a bucket boundary the database
+// computed in local time, as a time in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
 }
 
 func zoneOffsetSeconds(t time.Time) int {
@@ -0,0 +1,130 @@
+package institution
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	productStatisticsRollupInterval  = 15 * time.Minute
+	productStatisticsRollupOverlap   = 10 * time.Minute
+	productStatisticsRollupBatchSize = 500
+	// productStatisticsRollupWindow bounds the changes one step of a refresh
+	// looks at, so the first run works through the history a week at a time.
+	productStatisticsRollupWindow = 7 * 24 * time.Hour
+)
+
+var (
+	WrapMsgRefreshProductStatisticsRollups = WrapErrMsgPrefix + "RefreshProductStatisticsRollups"
+)
+
+// RunProductStatisticsRollupJob refreshes the daily product statistics
+// rollups now and then every productStatisticsRollupInterval until ctx is done.
+func (uc *InstitutionUC) RunProductStatisticsRollupJob(ctx context.Context) {
+	ticker := time.NewTicker(productStatisticsRollupInterval)
+	defer ticker.Stop()
+
+	log.Println("Product statistics rollup job started")
+	for {
+		if err := uc.RefreshProductStatisticsRollups(ctx); err != nil {
+			log.Printf("Refresh product statistics rollups error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Product statistics rollup job stopped (context cancelled)")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshProductStatisticsRollups rebuilds the rollups of every local day with
+// visit lines changed or refund lines written since the last refresh. The
+// previous refresh is overlapped so lines committed while it ran are not
+// missed; rebuilding a day twice is harmless. Changes are taken a window at a
+// time, and the refresh is saved after each, so the first run, which starts
+// from the oldest line, can be interrupted and carried on.
+//
+// Every API instance runs the job; the refresh holds an advisory lock for its
+// duration and is skipped while another instance holds it.
+func (uc *InstitutionUC) RefreshProductStatisticsRollups(ctx context.Context) (err error) {
+	unlock, locked, err := uc.InstitutionRepo.TryLockRollup(ctx, model.ProductStatisticsRollupName)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgRefreshProductStatisticsRollups)
+	}
+	if !locked {
+		return nil
+	}
+	defer unlock()
+
+	return uc.refreshProductStatisticsRollups(ctx)
+}
+
+func (uc *InstitutionUC) refreshProductStatisticsRollups(ctx context.Context) (err error) {
+	startedAt := time.Now()
+
+	refreshedUntil, found, err := uc.InstitutionRepo.GetRollupRefreshedUntil(ctx, model.ProductStatisticsRollupName)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgRefreshProductStatisticsRollups)
+	}
+	since := refreshedUntil.Add(-productStatisticsRollupOverlap)
+	if !found {
+		since, err = uc.InstitutionRepo.GetProductStatisticsSourceStart(ctx)
+		if err != nil {
+			return errors.Wrap(err, WrapMsgRefreshProductStatisticsRollups)
+		}
+	}
+
+	for since.Before(startedAt) {
+		until := since.Add(productStatisticsRollupWindow)
+		if until.After(startedAt) {
+			until = startedAt
+		}
+		if err = uc.refreshProductStatisticsRollupWindow(ctx, since, until); err != nil {
+			return err
+		}
+		if err = uc.InstitutionRepo.SetRollupRefreshedUntil(ctx, model.ProductStatisticsRollupName, until); err != nil {
+			return errors.Wrap(err, WrapMsgRefreshProductStatisticsRollups)
+		}
+		since = until
+	}
+	return nil
+}
+
+// refreshProductStatisticsRollupWindow rebuilds the days with changes from
+// since until until, productStatisticsRollupBatchSize days per transaction.
+func (uc *InstitutionUC) refreshProductStatisticsRollupWindow(ctx context.Context, since, until time.Time) (err error) {
+	for _, offset := range model.ProductStatisticsRollupOffsets {
+		days, err := uc.InstitutionRepo.FindProductStatisticsDirtyDays(ctx, since, until, offset)
+		if err != nil {
+			return errors.Wrap(err, WrapMsgRefreshProductStatisticsRollups)
+		}
+		for start := 0; start < len(days); start += productStatisticsRollupBatchSize {
+			end := start + productStatisticsRollupBatchSize
+			if end > len(days) {
+				end = len(days)
+			}
+			if err = uc.rebuildProductStatisticsRollups(ctx, offset, days[start:end]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (uc *InstitutionUC) rebuildProductStatisticsRollups(ctx context.Context, offset int, days []model.ProductStatisticsRollupDay) (err error) {
+	session, err := uc.Transaction.Begin(ctx)
+	defer uc.Transaction.Finish(session, &err)
+	txCtx := xorm.SetDBSession(ctx, session)
+
+	if err = uc.InstitutionRepo.RebuildProductStatisticsRollups(txCtx, offset, days); err != nil {
+		return errors.Wrap(err, WrapMsgRefreshProductStatisticsRollups)
+	}
+	return nil
+}
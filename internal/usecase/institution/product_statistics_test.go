@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	customtime "github.com/faisalhardin/medilink/pkg/type/time"
 )
 
@@ -48,6 +49,41 @@ func TestBuildProductStatisticsQueryRejectsRangeOver31Days(t *testing.T) {
 	}
 }
 
+func TestBuildProductStatisticsQueryMonthlyFromRollups(t *testing.T) {
+	t.Parallel()
+
+	query, _, _, err := buildProductStatisticsQuery(model.ProductStatisticsParams{
+		StartTime:   mustParseRFC3339(t, "2025-01-01T00:00:00+07:00"),
+		EndTime:     mustParseRFC3339(t, "2026-01-01T00:00:00+07:00"),
+		Granularity: model.ProductStatisticsGranularityMonth,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.UTCOffsetSeconds != 7*3600 {
+		t.Fatalf("expected offset 25200, got %d", query.UTCOffsetSeconds)
+	}
+	if got := query.EndTime.Format("2006-01-02"); got != "2025-12-31" {
+		t.Fatalf("expected the last day to be 2025-12-31, got %s", got)
+	}
+	if end := bucketPeriodEnd(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), query.Granularity); !end.Equal(time.Date(2025, 2, 28, 23, 59, 59, 0, time.UTC)) {
+		t.Fatalf("unexpected month bucket end: %s", end)
+	}
+}
+
+func TestBuildProductStatisticsQueryRejectsRollupOffset(t *testing.T) {
+	t.Parallel()
+
+	_, _, _, err := buildProductStatisticsQuery(model.ProductStatisticsParams{
+		StartTime:   mustParseRFC3339(t, "2025-01-01T00:00:00+05:30"),
+		EndTime:     mustParseRFC3339(t, "2026-01-01T00:00:00+05:30"),
+		Granularity: model.ProductStatisticsGranularityYear,
+	})
+	if err == nil {
+		t.Fatal("expected error for an offset without rollups")
+	}
+}
+
 func TestAssembleProductStatisticsResponse(t *testing.T) {
 	t.Parallel()
 
@@ -65,16 +101,17 @@ func TestAssembleProductStatisticsResponse(t *testing.T) {
 				IDTrxInstitutionProduct: 1,
 				Name:                    "Paracetamol",
 				TotalQuantity:           2,
-				TotalRevenue:            10000,
-				AvgUnitPrice:            5000,
+				TotalRevenue:            money.New(10000),
+				TotalCost:               money.New(6000),
+				AvgUnitPrice:            money.New(5000),
 			},
 			{
 				PeriodStart:             periodStart,
 				IDTrxInstitutionProduct: 2,
 				Name:                    "Vitamin C",
 				TotalQuantity:           1,
-				TotalRevenue:            3000,
-				AvgUnitPrice:            3000,
+				TotalRevenue:            money.New(3000),
+				AvgUnitPrice:            money.New(3000),
 			},
 		},
 	)
@@ -82,9 +119,12 @@ func TestAssembleProductStatisticsResponse(t *testing.T) {
 	if len(resp.Buckets) != 1 {
 		t.Fatalf("expected 1 bucket, got %d", len(resp.Buckets))
 	}
-	if resp.Buckets[0].TotalRevenue != 13000 {
+	if !resp.Buckets[0].TotalRevenue.Equal(money.New(13000)) {
 		t.Fatalf("expected bucket revenue 13000, got %v", resp.Buckets[0].TotalRevenue)
 	}
+	if !resp.Buckets[0].TotalMargin.Equal(money.New(7000)) {
+		t.Fatalf("expected bucket margin 7000, got %v", resp.Buckets[0].TotalMargin)
+	}
 	if resp.Summary.TotalQuantity != 3 {
 		t.Fatalf("expected summary quantity 3, got %d", resp.Summary.TotalQuantity)
 	}
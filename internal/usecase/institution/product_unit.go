@@ -0,0 +1,162 @@
+package institution
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+)
+
+var (
+	WrapMsgGetProductUnits  = WrapErrMsgPrefix + "GetProductUnits"
+	WrapMsgSaveProductUnits = WrapErrMsgPrefix + "SaveProductUnits"
+)
+
+func (uc *InstitutionUC) GetProductUnits(ctx context.Context, params model.ListProductUnitsParams) (resp model.ProductUnitsResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	product, err := uc.getProductJoinStock(ctx, userDetail.InstitutionID, params.IDTrxInstitutionProduct)
+	if err != nil {
+		return
+	}
+
+	units, err := uc.InstitutionRepo.FindProductUnitsByProductIDs(ctx, []int64{product.ID})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetProductUnits)
+		return
+	}
+	if units == nil {
+		units = []model.DtlInstitutionProductUnit{}
+	}
+
+	return model.ProductUnitsResponse{
+		IDTrxInstitutionProduct: product.ID,
+		BaseUnitType:            product.UnitType,
+		Units:                   units,
+	}, nil
+}
+
+func (uc *InstitutionUC) SaveProductUnits(ctx context.Context, request model.SaveProductUnitsRequest) (resp model.ProductUnitsResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	product, err := uc.getProductJoinStock(ctx, userDetail.InstitutionID, request.IDTrxInstitutionProduct)
+	if err != nil {
+		return
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	seen := map[string]struct{}{
+		strings.ToLower(strings.TrimSpace(product.UnitType)): {},
+	}
+	units := make([]model.DtlInstitutionProductUnit, 0, len(request.Units))
+	for i, unit := range request.Units {
+		unitType := strings.TrimSpace(unit.UnitType)
+		if unit.Price.Valid {
+			unit.Price.Money = unit.Price.Money.Round()
+		}
+		key := strings.ToLower(unitType)
+		if _, dup := seen[key]; dup {
+			errMsg.Append(fmt.Sprintf("units[%d].unit_type", i), fmt.Sprintf("%s is the base unit or already listed", unitType))
+			continue
+		}
+		seen[key] = struct{}{}
+
+		units = append(units, model.DtlInstitutionProductUnit{
+			IDTrxInstitutionProduct: product.ID,
+			UnitType:                unitType,
+			ConversionFactor:        unit.ConversionFactor,
+			Price:                   unit.Price,
+		})
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	session, err := uc.Transaction.Begin(ctx)
+	defer uc.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	err = uc.InstitutionRepo.ReplaceProductUnits(ctx, product.ID, units)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSaveProductUnits)
+		return
+	}
+
+	return model.ProductUnitsResponse{
+		IDTrxInstitutionProduct: product.ID,
+		BaseUnitType:            product.UnitType,
+		Units:                   units,
+	}, nil
+}
+
+func (uc *InstitutionUC) getProductJoinStock(ctx context.Context, institutionID, productID int64) (product model.GetInstitutionProductResponse, err error) {
+	products, err := uc.InstitutionRepo.FindTrxInstitutionProductJoinStockByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              []int64{productID},
+		IDMstInstitution: institutionID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetProductUnits)
+		return
+	}
+	if len(products) == 0 {
+		err = commonerr.SetNewBadRequest("product invalid", "product is not found")
+		return
+	}
+	return products[0], nil
+}
+
+// getProductUnitConversions validates that every resupplied product belongs to
+// the institution and that its unit is configured, returning the conversions
+// keyed by product ID.
+func (uc *InstitutionUC) getProductUnitConversions(ctx context.Context, institutionID int64, items []model.ProductStockResupplyItem) (map[int64]model.ProductUnitConversion, error) {
+	productIDs := make([]int64, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.IDTrxInstitutionProduct)
+	}
+
+	products, err := uc.InstitutionRepo.FindTrxInstitutionProductJoinStockByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:                  productIDs,
+		IDMstInstitution:     institutionID,
+		CommonRequestPayload: model.CommonRequestPayload{Limit: len(productIDs)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	units, err := uc.InstitutionRepo.FindProductUnitsByProductIDs(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	conversions := model.NewProductUnitConversions(products, units)
+
+	errMsg := commonerr.NewErrorMessage()
+	for i, item := range items {
+		conversion, ok := conversions[item.IDTrxInstitutionProduct]
+		if !ok {
+			errMsg.Append(fmt.Sprintf("products[%d].product_id", i), "product is not found")
+			continue
+		}
+		if _, ok = conversion.Factor(item.UnitType); !ok {
+			errMsg.Append(fmt.Sprintf("products[%d].unit_type", i), fmt.Sprintf("unit %s is not configured for this product", item.UnitType))
+		}
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return nil, errMsg
+	}
+	return conversions, nil
+}
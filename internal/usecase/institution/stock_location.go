@@ -0,0 +1,450 @@
+package institution
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+)
+
+var (
+	WrapMsgListStockLocations    = WrapErrMsgPrefix + "ListStockLocations"
+	WrapMsgCreateStockLocation   = WrapErrMsgPrefix + "CreateStockLocation"
+	WrapMsgUpdateStockLocation   = WrapErrMsgPrefix + "UpdateStockLocation"
+	WrapMsgListLocationStock     = WrapErrMsgPrefix + "ListLocationStock"
+	WrapMsgSaveReorderLevels     = WrapErrMsgPrefix + "SaveReorderLevels"
+	WrapMsgGetVisitStockLocation = WrapErrMsgPrefix + "GetVisitStockLocation"
+	WrapMsgCreateStockTransfer   = WrapErrMsgPrefix + "CreateStockTransfer"
+	WrapMsgListStockTransfers    = WrapErrMsgPrefix + "ListStockTransfers"
+	WrapMsgGetStockTransfer      = WrapErrMsgPrefix + "GetStockTransfer"
+	WrapMsgCheckStockLocation    = WrapErrMsgPrefix + "checkStockLocation"
+)
+
+// ListStockLocations returns the locations of the institution, the main
+// location first. The main location is created on first use.
+func (uc *InstitutionUC) ListStockLocations(ctx context.Context) (locations []model.MstStockLocation, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	_, err = uc.InstitutionRepo.EnsureMainStockLocation(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListStockLocations)
+		return
+	}
+
+	locations, err = uc.InstitutionRepo.FindStockLocations(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListStockLocations)
+		return
+	}
+	return
+}
+
+// CreateStockLocation adds a location, optionally supplying one service point.
+// Sales on visits at that service point then draw from it.
+func (uc *InstitutionUC) CreateStockLocation(ctx context.Context, request model.CreateStockLocationRequest) (location model.MstStockLocation, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	location = model.MstStockLocation{
+		IDMstInstitution:  userDetail.InstitutionID,
+		Name:              strings.TrimSpace(request.Name),
+		IDMstServicePoint: request.IDMstServicePoint,
+	}
+	if location.IDMstServicePoint.Valid && location.IDMstServicePoint.Int64 == 0 {
+		location.IDMstServicePoint = null.Int64{}
+	}
+	if err = uc.checkStockLocation(ctx, location); err != nil {
+		return
+	}
+
+	err = uc.InstitutionRepo.InsertStockLocation(ctx, &location)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCreateStockLocation)
+		return
+	}
+	return
+}
+
+// UpdateStockLocation renames a location or changes the service point it
+// supplies. Its stock stays where it is.
+func (uc *InstitutionUC) UpdateStockLocation(ctx context.Context, request model.UpdateStockLocationRequest) (location model.MstStockLocation, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	location, err = uc.getStockLocation(ctx, userDetail.InstitutionID, request.ID)
+	if err != nil {
+		return
+	}
+	if request.Name.Valid {
+		location.Name = strings.TrimSpace(request.Name.String)
+	}
+	if request.IDMstServicePoint.Valid {
+		location.IDMstServicePoint = null.NewInt64(request.IDMstServicePoint.Int64, request.IDMstServicePoint.Int64 != 0)
+	}
+	if location.IsMain && location.IDMstServicePoint.Valid {
+		err = commonerr.SetNewBadRequest("invalid service point", "the main location supplies every service point without a location of its own")
+		return
+	}
+	if err = uc.checkStockLocation(ctx, location); err != nil {
+		return
+	}
+
+	err = uc.InstitutionRepo.UpdateStockLocation(ctx, &location)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdateStockLocation)
+		return
+	}
+	return
+}
+
+// checkStockLocation rejects a location whose name is empty or taken, or whose
+// service point is not the institution's or already has a location.
+func (uc *InstitutionUC) checkStockLocation(ctx context.Context, location model.MstStockLocation) (err error) {
+	errMsg := commonerr.NewErrorMessage()
+	if location.Name == "" {
+		errMsg.Append("name", "name is required")
+	}
+
+	locations, err := uc.InstitutionRepo.FindStockLocations(ctx, location.IDMstInstitution)
+	if err != nil {
+		return errors.Wrap(err, WrapMsgCheckStockLocation)
+	}
+	for _, other := range locations {
+		if other.ID == location.ID {
+			continue
+		}
+		if strings.EqualFold(other.Name, location.Name) {
+			errMsg.Append("name", "another location already has this name")
+		}
+		if location.IDMstServicePoint.Valid && other.IDMstServicePoint == location.IDMstServicePoint {
+			errMsg.Append("service_point_id", fmt.Sprintf("service point is already supplied by %s", other.Name))
+		}
+	}
+
+	if location.IDMstServicePoint.Valid {
+		var servicePoint *model.MstServicePoint
+		servicePoint, err = uc.JourneyRepo.GetServicePoint(ctx, model.MstServicePoint{ID: location.IDMstServicePoint.Int64})
+		if err != nil && !errors.Is(err, constant.ErrorNoAffectedRow) {
+			return errors.Wrap(err, WrapMsgCheckStockLocation)
+		}
+		if err != nil || servicePoint.IDMstInstitution != location.IDMstInstitution {
+			errMsg.Append("service_point_id", "service point was not found in this institution")
+		}
+	}
+
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return errMsg
+	}
+	return nil
+}
+
+func (uc *InstitutionUC) getStockLocation(ctx context.Context, institutionID, locationID int64) (location model.MstStockLocation, err error) {
+	locations, err := uc.InstitutionRepo.FindStockLocations(ctx, institutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListStockLocations)
+		return
+	}
+	for _, location := range locations {
+		if location.ID == locationID {
+			return location, nil
+		}
+	}
+	err = commonerr.SetNewBadRequest("location_not_found", "stock location was not found in this institution")
+	return
+}
+
+// ListLocationStock returns the stock of item products per location. With
+// low_stock it is the low-stock report: the products at or below their
+// reorder level.
+func (uc *InstitutionUC) ListLocationStock(ctx context.Context, params model.ListLocationStockParams) (rows []model.LocationStockRow, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	params.IDMstInstitution = userDetail.InstitutionID
+	rows, err = uc.InstitutionRepo.FindLocationStocks(ctx, params)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListLocationStock)
+		return
+	}
+	if rows == nil {
+		rows = []model.LocationStockRow{}
+	}
+	return
+}
+
+// SaveReorderLevels sets the reorder levels of item products at a location.
+func (uc *InstitutionUC) SaveReorderLevels(ctx context.Context, request model.SaveReorderLevelsRequest) (rows []model.LocationStockRow, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	location, err := uc.getStockLocation(ctx, userDetail.InstitutionID, request.IDMstStockLocation)
+	if err != nil {
+		return
+	}
+
+	productIDs := make([]int64, 0, len(request.Items))
+	for _, item := range request.Items {
+		productIDs = append(productIDs, item.IDTrxInstitutionProduct)
+	}
+	products, err := uc.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              productIDs,
+		IDMstInstitution: userDetail.InstitutionID,
+		IsItem:           true,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSaveReorderLevels)
+		return
+	}
+	items := make(map[int64]struct{}, len(products))
+	for _, product := range products {
+		items[product.ID] = struct{}{}
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	seen := make(map[int64]struct{}, len(request.Items))
+	for i, item := range request.Items {
+		field := fmt.Sprintf("items[%d].product_id", i)
+		if _, ok := items[item.IDTrxInstitutionProduct]; !ok {
+			errMsg.Append(field, "product must be an item product of this institution")
+			continue
+		}
+		if _, dup := seen[item.IDTrxInstitutionProduct]; dup {
+			errMsg.Append(field, "product is already listed")
+			continue
+		}
+		seen[item.IDTrxInstitutionProduct] = struct{}{}
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	err = uc.InstitutionRepo.SaveLocationReorderLevels(ctx, location.ID, request.Items)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSaveReorderLevels)
+		return
+	}
+
+	return uc.ListLocationStock(ctx, model.ListLocationStockParams{
+		IDMstStockLocation: location.ID,
+		ProductIDs:         productIDs,
+	})
+}
+
+// GetVisitStockLocation returns the location sales on the visit draw from: the
+// location of the visit's service point, or the main location when the service
+// point has none.
+func (uc *InstitutionUC) GetVisitStockLocation(ctx context.Context, visitID int64) (location model.MstStockLocation, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	location, found, err = uc.InstitutionRepo.FindVisitStockLocation(ctx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetVisitStockLocation)
+		return
+	}
+	if found {
+		return
+	}
+
+	location, err = uc.InstitutionRepo.EnsureMainStockLocation(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetVisitStockLocation)
+		return
+	}
+	return
+}
+
+// CreateStockTransfer moves item stock from one location to another. The
+// source must hold every quantity moved; the institution's total stock does
+// not change.
+func (uc *InstitutionUC) CreateStockTransfer(ctx context.Context, request model.CreateStockTransferRequest) (transfer *model.TrxStockTransfer, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	if request.FromIDMstStockLocation == request.ToIDMstStockLocation {
+		err = commonerr.SetNewBadRequest("invalid location", "stock can only be transferred between two different locations")
+		return
+	}
+	from, err := uc.getStockLocation(ctx, userDetail.InstitutionID, request.FromIDMstStockLocation)
+	if err != nil {
+		return
+	}
+	to, err := uc.getStockLocation(ctx, userDetail.InstitutionID, request.ToIDMstStockLocation)
+	if err != nil {
+		return
+	}
+
+	productIDs := make([]int64, 0, len(request.Items))
+	for _, item := range request.Items {
+		productIDs = append(productIDs, item.IDTrxInstitutionProduct)
+	}
+	products, err := uc.InstitutionRepo.FindTrxInstitutionProductJoinStockByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              productIDs,
+		IDMstInstitution: userDetail.InstitutionID,
+		IsItem:           true,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCreateStockTransfer)
+		return
+	}
+
+	session, err := uc.Transaction.Begin(ctx)
+	defer uc.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	// quantities are read inside the transaction so they include its own writes
+	onHand := make(map[int64]int64, len(products))
+	for _, product := range products {
+		onHand[product.ID] = 0
+	}
+	stocks, err := uc.InstitutionRepo.FindLocationStocks(ctx, model.ListLocationStockParams{
+		IDMstInstitution:   userDetail.InstitutionID,
+		IDMstStockLocation: from.ID,
+		ProductIDs:         productIDs,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCreateStockTransfer)
+		return
+	}
+	for _, stock := range stocks {
+		onHand[stock.IDTrxInstitutionProduct] = stock.Quantity
+	}
+
+	if itemErrors := model.CheckStockTransferItems(request.Items, onHand); len(itemErrors) > 0 {
+		errMsg := commonerr.NewErrorMessage()
+		for _, itemErr := range itemErrors {
+			errMsg.Append(fmt.Sprintf("items[%d].%s", itemErr.Index, itemErr.Field), itemErr.Message)
+		}
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	transfer = &model.TrxStockTransfer{
+		IDMstInstitution:       userDetail.InstitutionID,
+		FromIDMstStockLocation: from.ID,
+		ToIDMstStockLocation:   to.ID,
+		Notes:                  strings.TrimSpace(request.Notes),
+		CreatedBy:              userDetail.Email,
+	}
+	items := make([]model.DtlStockTransferItem, 0, len(request.Items))
+	for _, item := range request.Items {
+		items = append(items, model.DtlStockTransferItem{
+			IDTrxInstitutionProduct: item.IDTrxInstitutionProduct,
+			Quantity:                item.Quantity,
+		})
+	}
+	err = uc.InstitutionRepo.InsertStockTransfer(ctx, transfer, items)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCreateStockTransfer)
+		return
+	}
+
+	productByID := make(map[int64]model.GetInstitutionProductResponse, len(products))
+	for _, product := range products {
+		productByID[product.ID] = product
+	}
+	movements := make([]model.TrxStockMovement, 0, 2*len(items))
+	for _, item := range items {
+		movement := model.TrxStockMovement{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: item.IDTrxInstitutionProduct,
+			UnitCost:                productByID[item.IDTrxInstitutionProduct].AvgCost,
+			ReferenceType:           model.StockMovementReferenceStockTransfer,
+			ReferenceID:             transfer.ID,
+			Notes:                   transfer.Notes,
+			CreatedBy:               userDetail.Email,
+		}
+
+		out := movement
+		out.IDMstStockLocation = from.ID
+		out.MovementType = model.StockMovementTypeTransferOut
+		out.Quantity = -item.Quantity
+
+		in := movement
+		in.IDMstStockLocation = to.ID
+		in.MovementType = model.StockMovementTypeTransferIn
+		in.Quantity = item.Quantity
+
+		movements = append(movements, out, in)
+	}
+	err = uc.InstitutionRepo.InsertStockMovements(ctx, movements)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCreateStockTransfer)
+		return
+	}
+
+	transfer, err = uc.InstitutionRepo.GetStockTransfer(ctx, userDetail.InstitutionID, transfer.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCreateStockTransfer)
+		return
+	}
+	return
+}
+
+func (uc *InstitutionUC) ListStockTransfers(ctx context.Context, params model.ListStockTransferParams) (transfers []model.TrxStockTransfer, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	params.IDMstInstitution = userDetail.InstitutionID
+	transfers, err = uc.InstitutionRepo.FindStockTransfers(ctx, params)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListStockTransfers)
+		return
+	}
+	return
+}
+
+func (uc *InstitutionUC) GetStockTransfer(ctx context.Context, transferID int64) (transfer *model.TrxStockTransfer, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	transfer, err = uc.InstitutionRepo.GetStockTransfer(ctx, userDetail.InstitutionID, transferID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetStockTransfer)
+		return
+	}
+	if transfer == nil {
+		err = commonerr.SetNewBadRequest("transfer_not_found", "stock transfer was not found in this institution")
+		return
+	}
+	return
+}
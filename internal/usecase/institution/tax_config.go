@@ -0,0 +1,73 @@
+package institution
+
+import (
+	"context"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+)
+
+var (
+	WrapMsgGetTaxConfig    = WrapErrMsgPrefix + "GetTaxConfig"
+	WrapMsgUpdateTaxConfig = WrapErrMsgPrefix + "UpdateTaxConfig"
+)
+
+func (uc *InstitutionUC) GetTaxConfig(ctx context.Context) (config model.MstInstitutionTaxConfig, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	config, err = uc.InstitutionRepo.GetInstitutionTaxConfig(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetTaxConfig)
+		return
+	}
+
+	return
+}
+
+// UpdateTaxConfig replaces the institution's tax setup. Only visit lines added
+// or changed afterwards are taxed with it; existing lines keep their snapshot.
+func (uc *InstitutionUC) UpdateTaxConfig(ctx context.Context, request model.UpdateTaxConfigRequest) (config model.MstInstitutionTaxConfig, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	if request.PPNRate < 0 || request.PPNRate >= 1 {
+		errMsg.Append("ppn_rate", "ppn_rate must be a fraction between 0 and 1, e.g. 0.11")
+	}
+	if request.IsPKP && request.PPNRate == 0 {
+		errMsg.Append("ppn_rate", "a PKP institution must set its ppn_rate")
+	}
+	if request.ServiceChargeRate < 0 || request.ServiceChargeRate >= 1 {
+		errMsg.Append("service_charge_rate", "service_charge_rate must be a fraction between 0 and 1, e.g. 0.05")
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	config = model.MstInstitutionTaxConfig{
+		IDMstInstitution:  userDetail.InstitutionID,
+		IsPKP:             request.IsPKP,
+		PPNRate:           request.PPNRate,
+		PriceIncludesTax:  request.PriceIncludesTax,
+		ServiceChargeRate: request.ServiceChargeRate,
+		UpdatedBy:         userDetail.Email,
+	}
+	err = uc.InstitutionRepo.UpsertInstitutionTaxConfig(ctx, &config)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUpdateTaxConfig)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,335 @@
+package institution
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+)
+
+var (
+	WrapMsgGetTreatmentMaterials     = WrapErrMsgPrefix + "GetTreatmentMaterials"
+	WrapMsgSaveTreatmentMaterials    = WrapErrMsgPrefix + "SaveTreatmentMaterials"
+	WrapMsgConsumeTreatmentMaterials = WrapErrMsgPrefix + "ConsumeTreatmentMaterials"
+)
+
+var treatmentMovementTypes = []string{
+	model.StockMovementTypeTreatmentConsumption,
+	model.StockMovementTypeTreatmentConsumptionVoid,
+}
+
+func (uc *InstitutionUC) GetTreatmentMaterials(ctx context.Context, params model.ListTreatmentMaterialsParams) (resp model.TreatmentMaterialsResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	product, err := uc.getTreatmentProduct(ctx, userDetail.InstitutionID, params.IDTrxInstitutionProduct)
+	if err != nil {
+		return
+	}
+
+	materials, err := uc.InstitutionRepo.FindTreatmentMaterialsByProductIDs(ctx, []int64{product.ID})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetTreatmentMaterials)
+		return
+	}
+	if materials == nil {
+		materials = []model.TreatmentMaterialRow{}
+	}
+
+	return model.TreatmentMaterialsResponse{
+		IDTrxInstitutionProduct: product.ID,
+		Materials:               materials,
+	}, nil
+}
+
+// SaveTreatmentMaterials replaces the bill of materials of a treatment. Every
+// material must be an item product of the institution. The new list applies to
+// visits that have not consumed the treatment yet; visits that have keep the
+// list they first consumed it by.
+func (uc *InstitutionUC) SaveTreatmentMaterials(ctx context.Context, request model.SaveTreatmentMaterialsRequest) (resp model.TreatmentMaterialsResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	product, err := uc.getTreatmentProduct(ctx, userDetail.InstitutionID, request.IDTrxInstitutionProduct)
+	if err != nil {
+		return
+	}
+
+	materialIDs := make([]int64, 0, len(request.Materials))
+	for _, material := range request.Materials {
+		materialIDs = append(materialIDs, material.IDMaterialProduct)
+	}
+	items := map[int64]struct{}{}
+	if len(materialIDs) > 0 {
+		var products []model.TrxInstitutionProduct
+		products, err = uc.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+			IDs:              materialIDs,
+			IDMstInstitution: userDetail.InstitutionID,
+			IsItem:           true,
+		})
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgSaveTreatmentMaterials)
+			return
+		}
+		for _, p := range products {
+			items[p.ID] = struct{}{}
+		}
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	seen := map[int64]struct{}{}
+	materials := make([]model.DtlTreatmentMaterial, 0, len(request.Materials))
+	for i, material := range request.Materials {
+		field := fmt.Sprintf("materials[%d].material_product_id", i)
+		if material.IDMaterialProduct == product.ID {
+			errMsg.Append(field, "a treatment cannot consume itself")
+			continue
+		}
+		if _, ok := items[material.IDMaterialProduct]; !ok {
+			errMsg.Append(field, "material must be an item product of this institution")
+			continue
+		}
+		if _, dup := seen[material.IDMaterialProduct]; dup {
+			errMsg.Append(field, "material is already listed")
+			continue
+		}
+		seen[material.IDMaterialProduct] = struct{}{}
+
+		materials = append(materials, model.DtlTreatmentMaterial{
+			IDTrxInstitutionProduct: product.ID,
+			IDMaterialProduct:       material.IDMaterialProduct,
+			Quantity:                material.Quantity,
+		})
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	session, err := uc.Transaction.Begin(ctx)
+	defer uc.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	err = uc.InstitutionRepo.ReplaceTreatmentMaterials(ctx, product.ID, materials)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSaveTreatmentMaterials)
+		return
+	}
+
+	rows, err := uc.InstitutionRepo.FindTreatmentMaterialsByProductIDs(ctx, []int64{product.ID})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSaveTreatmentMaterials)
+		return
+	}
+	if rows == nil {
+		rows = []model.TreatmentMaterialRow{}
+	}
+
+	return model.TreatmentMaterialsResponse{
+		IDTrxInstitutionProduct: product.ID,
+		Materials:               rows,
+	}, nil
+}
+
+// ConsumeTreatmentMaterials brings the stock of a visit's treatment materials in
+// line with the treatments it currently holds, on the cart or as procedures.
+// Only the difference to what was already posted for the visit is moved, so it
+// is called after every change and reverses consumption when treatments are
+// voided. A treatment is consumed by the bill of materials it had when the
+// visit first consumed it, which is recorded then. Materials are taken from
+// the stock location of the visit's service point and may go below zero: the
+// treatment was performed, and the shortfall surfaces in the next stock-take.
+// Reversals put materials back at the locations they were taken from. It must
+// run inside the caller's transaction.
+func (uc *InstitutionUC) ConsumeTreatmentMaterials(ctx context.Context, visitID int64) (err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	usage, err := uc.InstitutionRepo.FindVisitTreatmentUsage(ctx, userDetail.InstitutionID, visitID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgConsumeTreatmentMaterials)
+		return
+	}
+
+	materials, err := uc.visitTreatmentMaterials(ctx, visitID, usage)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgConsumeTreatmentMaterials)
+		return
+	}
+
+	posted, err := uc.InstitutionRepo.SumStockMovementsByReference(ctx, userDetail.InstitutionID,
+		model.StockMovementReferenceVisit, visitID, treatmentMovementTypes)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgConsumeTreatmentMaterials)
+		return
+	}
+
+	deltas := model.TreatmentMaterialDeltas(usage, materials, posted)
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	// materials are taken from the stock location of the visit's service point
+	var location model.MstStockLocation
+	for _, delta := range deltas {
+		if delta >= 0 {
+			continue
+		}
+		location, err = uc.GetVisitStockLocation(ctx, visitID)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgConsumeTreatmentMaterials)
+			return
+		}
+		break
+	}
+
+	unitCosts := make(map[int64]money.Money, len(materials))
+	for _, material := range materials {
+		unitCosts[material.IDMaterialProduct] = material.AvgCost
+	}
+
+	// a stable order keeps row locks on the stock table acquired consistently
+	productIDs := make([]int64, 0, len(deltas))
+	for productID := range deltas {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+
+	movements := make([]model.TrxStockMovement, 0, len(productIDs))
+	for _, productID := range productIDs {
+		delta := deltas[productID]
+		err = uc.InstitutionRepo.AdjustDtlInstitutionProductStock(ctx, productID, delta)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgConsumeTreatmentMaterials)
+			return
+		}
+
+		movement := model.TrxStockMovement{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: productID,
+			IDMstStockLocation:      location.ID,
+			MovementType:            model.StockMovementTypeTreatmentConsumption,
+			Quantity:                delta,
+			UnitCost:                unitCosts[productID],
+			ReferenceType:           model.StockMovementReferenceVisit,
+			ReferenceID:             visitID,
+			CreatedBy:               userDetail.Email,
+		}
+		if delta < 0 {
+			movements = append(movements, movement)
+			continue
+		}
+
+		// a reversal returns each location what was taken from it
+		restored := model.RestoreMaterialStock(productID, delta, posted)
+		locationIDs := make([]int64, 0, len(restored))
+		for locationID := range restored {
+			locationIDs = append(locationIDs, locationID)
+		}
+		sort.Slice(locationIDs, func(i, j int) bool { return locationIDs[i] < locationIDs[j] })
+		for _, locationID := range locationIDs {
+			movement.IDMstStockLocation = locationID
+			movement.MovementType = model.StockMovementTypeTreatmentConsumptionVoid
+			movement.Quantity = restored[locationID]
+			movements = append(movements, movement)
+		}
+	}
+
+	err = uc.InstitutionRepo.InsertStockMovements(ctx, movements)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgConsumeTreatmentMaterials)
+		return
+	}
+
+	return nil
+}
+
+// visitTreatmentMaterials returns the bills of materials the visit consumes its
+// treatments by: the ones recorded for it, and the current bill of materials of
+// treatments in usage the visit has not consumed before, which is recorded now.
+func (uc *InstitutionUC) visitTreatmentMaterials(ctx context.Context, visitID int64, usage []model.TreatmentUsage) (materials []model.TreatmentMaterialRow, err error) {
+	recorded, err := uc.InstitutionRepo.FindVisitTreatmentMaterials(ctx, visitID)
+	if err != nil {
+		return
+	}
+
+	hasRecord := make(map[int64]bool, len(recorded))
+	for _, material := range recorded {
+		hasRecord[material.IDTrxInstitutionProduct] = true
+		if material.IDMaterialProduct > 0 {
+			materials = append(materials, material)
+		}
+	}
+
+	newTreatmentIDs := []int64{}
+	for _, u := range usage {
+		if u.Quantity > 0 && !hasRecord[u.IDTrxInstitutionProduct] {
+			newTreatmentIDs = append(newTreatmentIDs, u.IDTrxInstitutionProduct)
+		}
+	}
+	if len(newTreatmentIDs) == 0 {
+		return
+	}
+
+	current, err := uc.InstitutionRepo.FindTreatmentMaterialsByProductIDs(ctx, newTreatmentIDs)
+	if err != nil {
+		return
+	}
+	hasMaterials := make(map[int64]bool, len(current))
+	records := make([]model.DtlVisitTreatmentMaterial, 0, len(current)+len(newTreatmentIDs))
+	for _, material := range current {
+		hasMaterials[material.IDTrxInstitutionProduct] = true
+		records = append(records, model.DtlVisitTreatmentMaterial{
+			IDTrxPatientVisit:       visitID,
+			IDTrxInstitutionProduct: material.IDTrxInstitutionProduct,
+			IDMaterialProduct:       material.IDMaterialProduct,
+			Quantity:                material.Quantity,
+		})
+	}
+	for _, treatmentID := range newTreatmentIDs {
+		if !hasMaterials[treatmentID] {
+			records = append(records, model.DtlVisitTreatmentMaterial{
+				IDTrxPatientVisit:       visitID,
+				IDTrxInstitutionProduct: treatmentID,
+			})
+		}
+	}
+	if err = uc.InstitutionRepo.InsertVisitTreatmentMaterials(ctx, records); err != nil {
+		return
+	}
+
+	return append(materials, current...), nil
+}
+
+func (uc *InstitutionUC) getTreatmentProduct(ctx context.Context, institutionID, productID int64) (product model.TrxInstitutionProduct, err error) {
+	products, err := uc.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              []int64{productID},
+		IDMstInstitution: institutionID,
+		IsTreatment:      true,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetTreatmentMaterials)
+		return
+	}
+	if len(products) == 0 {
+		err = commonerr.SetNewBadRequest("product invalid", "treatment product is not found")
+		return
+	}
+	return products[0], nil
+}
@@ -0,0 +1,131 @@
+package institution
+
+import (
+	"context"
+	"testing"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	institutionRepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+// treatmentDB keeps the bills of materials, the recorded bills of the visit and
+// its movements in memory. Methods the test does not reach are left to the
+// embedded interface.
+type treatmentDB struct {
+	institutionRepo.InstitutionDB
+
+	usage     []model.TreatmentUsage
+	current   []model.TreatmentMaterialRow
+	recorded  []model.DtlVisitTreatmentMaterial
+	location  model.MstStockLocation
+	movements []model.TrxStockMovement
+}
+
+func (db *treatmentDB) FindVisitTreatmentUsage(ctx context.Context, institutionID, visitID int64) ([]model.TreatmentUsage, error) {
+	return db.usage, nil
+}
+
+func (db *treatmentDB) FindTreatmentMaterialsByProductIDs(ctx context.Context, productIDs []int64) ([]model.TreatmentMaterialRow, error) {
+	var materials []model.TreatmentMaterialRow
+	for _, material := range db.current {
+		for _, productID := range productIDs {
+			if material.IDTrxInstitutionProduct == productID {
+				materials = append(materials, material)
+			}
+		}
+	}
+	return materials, nil
+}
+
+func (db *treatmentDB) FindVisitTreatmentMaterials(ctx context.Context, visitID int64) ([]model.TreatmentMaterialRow, error) {
+	var materials []model.TreatmentMaterialRow
+	for _, record := range db.recorded {
+		materials = append(materials, model.TreatmentMaterialRow{DtlTreatmentMaterial: model.DtlTreatmentMaterial{
+			IDTrxInstitutionProduct: record.IDTrxInstitutionProduct,
+			IDMaterialProduct:       record.IDMaterialProduct,
+			Quantity:                record.Quantity,
+		}})
+	}
+	return materials, nil
+}
+
+func (db *treatmentDB) InsertVisitTreatmentMaterials(ctx context.Context, materials []model.DtlVisitTreatmentMaterial) error {
+	db.recorded = append(db.recorded, materials...)
+	return nil
+}
+
+func (db *treatmentDB) SumStockMovementsByReference(ctx context.Context, institutionID int64, referenceType string, referenceID int64, movementTypes []string) ([]model.MaterialStockBalance, error) {
+	var balances []model.MaterialStockBalance
+	for _, movement := range db.movements {
+		balances = append(balances, model.MaterialStockBalance{
+			IDTrxInstitutionProduct: movement.IDTrxInstitutionProduct,
+			IDMstStockLocation:      movement.IDMstStockLocation,
+			Quantity:                movement.Quantity,
+		})
+	}
+	return balances, nil
+}
+
+func (db *treatmentDB) FindVisitStockLocation(ctx context.Context, institutionID, visitID int64) (model.MstStockLocation, bool, error) {
+	return db.location, true, nil
+}
+
+func (db *treatmentDB) AdjustDtlInstitutionProductStock(ctx context.Context, productID, delta int64) error {
+	return nil
+}
+
+func (db *treatmentDB) InsertStockMovements(ctx context.Context, movements []model.TrxStockMovement) error {
+	db.movements = append(db.movements, movements...)
+	return nil
+}
+
+func TestConsumeTreatmentMaterialsKeepsRecordedMaterials(t *testing.T) {
+	t.Parallel()
+
+	ctx := auth.SetUserDetailToCtx(context.Background(), model.UserJWTPayload{InstitutionID: 7, Email: "nurse@clinic"})
+	material := func(treatmentID, materialID, quantity int64) model.TreatmentMaterialRow {
+		return model.TreatmentMaterialRow{DtlTreatmentMaterial: model.DtlTreatmentMaterial{
+			IDTrxInstitutionProduct: treatmentID,
+			IDMaterialProduct:       materialID,
+			Quantity:                quantity,
+		}}
+	}
+	db := &treatmentDB{
+		usage:    []model.TreatmentUsage{{IDTrxInstitutionProduct: 1, Quantity: 1}, {IDTrxInstitutionProduct: 2, Quantity: 1}},
+		current:  []model.TreatmentMaterialRow{material(1, 10, 2)},
+		location: model.MstStockLocation{ID: 5},
+	}
+	uc := &InstitutionUC{InstitutionRepo: db}
+
+	if err := uc.ConsumeTreatmentMaterials(ctx, 30); err != nil {
+		t.Fatalf("ConsumeTreatmentMaterials() = %v", err)
+	}
+	if len(db.movements) != 1 || db.movements[0].Quantity != -2 || db.movements[0].IDMstStockLocation != 5 {
+		t.Fatalf("first consumption posted %+v, want -2 of material 10 at location 5", db.movements)
+	}
+
+	// both treatments change their materials and the visit moves to another
+	// service point; the next change keeps consuming by the recorded lists
+	db.current = []model.TreatmentMaterialRow{material(1, 11, 1), material(2, 12, 1)}
+	db.location = model.MstStockLocation{ID: 6}
+	if err := uc.ConsumeTreatmentMaterials(ctx, 30); err != nil {
+		t.Fatalf("ConsumeTreatmentMaterials() = %v", err)
+	}
+	if len(db.movements) != 1 {
+		t.Fatalf("changed materials posted %+v, want nothing", db.movements[1:])
+	}
+
+	// voided: material 10 goes back where it was taken from
+	db.usage = nil
+	if err := uc.ConsumeTreatmentMaterials(ctx, 30); err != nil {
+		t.Fatalf("ConsumeTreatmentMaterials() = %v", err)
+	}
+	if len(db.movements) != 2 {
+		t.Fatalf("void posted %d movements, want 1", len(db.movements)-1)
+	}
+	if void := db.movements[1]; void.IDTrxInstitutionProduct != 10 || void.Quantity != 2 || void.IDMstStockLocation != 5 ||
+		void.MovementType != model.StockMovementTypeTreatmentConsumptionVoid {
+		t.Fatalf("void posted %+v, want +2 of material 10 at location 5", void)
+	}
+}
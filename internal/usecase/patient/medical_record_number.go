@@ -0,0 +1,113 @@
+package patient
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+)
+
+const (
+	WrapMsgBackfillMedicalRecordNumbers = WrapErrMsg + "BackfillMedicalRecordNumbers"
+
+	defaultBackfillBatchSize = 500
+)
+
+// registerPatient stores a new patient with the next medical record number of
+// its institution in one transaction, so a failed insert gives the number back.
+func (u *PatientUC) registerPatient(ctx context.Context, patient *model.MstPatientInstitution) (err error) {
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	patient.MedicalRecordNumber, err = u.PatientDB.NextMedicalRecordNumber(ctx, patient.InstitutionID, time.Now())
+	if err != nil {
+		return
+	}
+
+	return u.PatientDB.RegisterNewPatient(ctx, patient)
+}
+
+// BackfillMedicalRecordNumbers numbers the patients registered before medical
+// record numbers existed, oldest first, each from the counter of the year it
+// was registered in. An institutionID of 0 numbers every institution. Each
+// batch commits on its own, so an interrupted run can simply be started again.
+func (u *PatientUC) BackfillMedicalRecordNumbers(ctx context.Context, institutionID int64, batchSize int) (numbered int, err error) {
+	if batchSize <= 0 {
+		batchSize = defaultBackfillBatchSize
+	}
+
+	for {
+		var count int
+		count, err = u.backfillMedicalRecordNumberBatch(ctx, institutionID, batchSize)
+		numbered += count
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgBackfillMedicalRecordNumbers)
+			return
+		}
+		if count < batchSize {
+			return
+		}
+	}
+}
+
+func (u *PatientUC) backfillMedicalRecordNumberBatch(ctx context.Context, institutionID int64, batchSize int) (count int, err error) {
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	patients, err := u.PatientDB.ListPatientsWithoutMedicalRecordNumber(ctx, institutionID, batchSize)
+	if err != nil {
+		return
+	}
+
+	// numbers are reserved per institution and registration year, one counter
+	// update each, after the batch is listed so the counters are only locked
+	// for the batch's last statements
+	type counterKey struct {
+		institutionID int64
+		year          int
+	}
+	var (
+		keys   []counterKey
+		groups = map[counterKey][]model.MstPatientInstitution{}
+	)
+	for _, patient := range patients {
+		key := counterKey{institutionID: patient.InstitutionID, year: patient.CreateTime.Year()}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], patient)
+	}
+
+	patientIDs := make([]int64, 0, len(patients))
+	numbers := make([]string, 0, len(patients))
+	for _, key := range keys {
+		group := groups[key]
+		var reserved []string
+		reserved, err = u.PatientDB.ReserveMedicalRecordNumbers(ctx, key.institutionID, group[0].CreateTime, len(group))
+		if err != nil {
+			return 0, err
+		}
+		for i, patient := range group {
+			patientIDs = append(patientIDs, patient.ID)
+			numbers = append(numbers, reserved[i])
+		}
+	}
+
+	err = u.PatientDB.SetMedicalRecordNumbers(ctx, patientIDs, numbers)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(patients), nil
+}
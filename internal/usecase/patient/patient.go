@@ -3,14 +3,22 @@ package patient
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
+	attachmentRepo "github.com/faisalhardin/medilink/internal/entity/repo/attachment"
+	billingRepo "github.com/faisalhardin/medilink/internal/entity/repo/billing"
+	consentRepo "github.com/faisalhardin/medilink/internal/entity/repo/consent"
 	patientRepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/log"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/eligibility"
 	"github.com/faisalhardin/medilink/internal/library/idempotency"
 	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/internal/library/storage"
 	"github.com/faisalhardin/medilink/internal/library/util/hash"
 )
 
@@ -24,6 +32,15 @@ const (
 type PatientUC struct {
 	PatientDB   patientRepo.PatientDB
 	Idempotency *idempotency.Service
+	Transaction xormlib.DBTransactionInterface
+	// AttachmentDB, ConsentDB, BillingDB and Storage are read by personal
+	// data exports.
+	AttachmentDB attachmentRepo.AttachmentDB
+	ConsentDB    consentRepo.ConsentDB
+	BillingDB    billingRepo.BillingDB
+	Storage      storage.Storage
+	// Eligibility asks payers whether they cover a patient.
+	Eligibility eligibility.Checker
 }
 
 func NewPatientUC(u *PatientUC) *PatientUC {
@@ -55,36 +72,34 @@ func (u *PatientUC) RegisterNewPatient(ctx context.Context, req model.RegisterNe
 	}
 
 	newPatient := model.MstPatientInstitution{
-		NIK:           req.NIK,
-		Name:          req.Name,
-		Sex:           req.Sex,
-		DateOfBirth:   req.DateOfBirth.Time(),
-		PlaceOfBirth:  req.PlaceOfBirth,
-		Address:       req.Address,
-		Religion:      req.Religion,
-		PhoneNumber:   req.PhoneNumber,
-		InstitutionID: userDetail.InstitutionID,
-		Occupation:    req.Occupation,
+		NIK:             req.NIK,
+		Name:            req.Name,
+		Sex:             req.Sex,
+		DateOfBirth:     req.DateOfBirth.Time(),
+		PlaceOfBirth:    req.PlaceOfBirth,
+		Address:         req.Address,
+		Religion:        req.Religion,
+		PhoneNumber:     req.PhoneNumber,
+		InstitutionID:   userDetail.InstitutionID,
+		Occupation:      req.Occupation,
+		PatientCategory: model.NormalisePatientCategory(req.PatientCategory),
 	}
 
-	err = u.PatientDB.RegisterNewPatient(ctx, &newPatient)
+	err = u.registerPatient(ctx, &newPatient)
 	if err != nil {
 		idempotency.Release(u.Idempotency, cacheKey)
 		err = errors.Wrap(err, WrapMsgRegisterNewPatient)
 		return
 	}
 
-	newPatientResponse = model.GetPatientResponse{
-		UUID:         newPatient.UUID,
-		NIK:          newPatient.NIK,
-		Name:         newPatient.Name,
-		PlaceOfBirth: newPatient.PlaceOfBirth,
-		DateOfBirth:  newPatient.DateOfBirth,
-		Address:      newPatient.Address,
-		Religion:     newPatient.Religion,
-		PhoneNumber:  newPatient.PhoneNumber,
-		Sex:          newPatient.Sex,
-		Occupation:   newPatient.Occupation,
+	newPatientResponse = patientResponse(newPatient)
+
+	// The patient is registered either way; possible duplicates are only a
+	// warning for the front desk to review.
+	newPatientResponse.PossibleDuplicates, err = u.findDuplicates(ctx, newPatient)
+	if err != nil {
+		log.Errorf("%s: find duplicates of patient %d: %v", WrapMsgRegisterNewPatient, newPatient.ID, err)
+		err = nil
 	}
 
 	idempotency.Complete(u.Idempotency, cacheKey, reqHash, newPatientResponse)
@@ -114,18 +129,7 @@ func (u *PatientUC) GetPatients(ctx context.Context, patientUUID string) (patien
 		return
 	}
 
-	patient = model.GetPatientResponse{
-		UUID:         mstPatients[0].UUID,
-		NIK:          mstPatients[0].NIK,
-		Name:         mstPatients[0].Name,
-		PlaceOfBirth: mstPatients[0].PlaceOfBirth,
-		DateOfBirth:  mstPatients[0].DateOfBirth,
-		Address:      mstPatients[0].Address,
-		Religion:     mstPatients[0].Religion,
-		Sex:          mstPatients[0].Sex,
-		PhoneNumber:  mstPatients[0].PhoneNumber,
-		Occupation:   mstPatients[0].Occupation,
-	}
+	patient = patientResponse(mstPatients[0])
 
 	return
 }
@@ -143,25 +147,23 @@ func (u *PatientUC) ListPatients(ctx context.Context, req model.GetPatientParams
 	}
 
 	req.InstitutionID = userDetail.InstitutionID
-	mstPatients, err := u.PatientDB.GetPatients(ctx, req)
+	var mstPatients []model.MstPatientInstitution
+	if strings.TrimSpace(req.Query) != "" {
+		if model.NewPatientSearchTerms(req.Query).TooShort() {
+			err = commonerr.SetNewUnprocessableEntityError("q", fmt.Sprintf("must be at least %d characters", model.PatientSearchMinLength))
+			return
+		}
+		mstPatients, err = u.PatientDB.SearchPatients(ctx, req)
+	} else {
+		mstPatients, err = u.PatientDB.GetPatients(ctx, req)
+	}
 	if err != nil {
 		err = errors.Wrap(err, WrapMsgRegisterNewPatient)
 		return
 	}
 
 	for _, patient := range mstPatients {
-		patients = append(patients, model.GetPatientResponse{
-			UUID:         patient.UUID,
-			NIK:          patient.NIK,
-			Name:         patient.Name,
-			PlaceOfBirth: patient.PlaceOfBirth,
-			DateOfBirth:  patient.DateOfBirth,
-			Address:      patient.Address,
-			Religion:     patient.Religion,
-			PhoneNumber:  patient.PhoneNumber,
-			Sex:          patient.Sex,
-			Occupation:   patient.Occupation,
-		})
+		patients = append(patients, patientResponse(patient))
 	}
 
 	return
@@ -0,0 +1,333 @@
+package patient
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/log"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/internal/library/storage"
+)
+
+const (
+	WrapMsgExportPatientData      = WrapErrMsg + "ExportPatientData"
+	WrapMsgWritePatientDataExport = WrapErrMsg + "WritePatientDataExport"
+	WrapMsgAnonymisePatient       = WrapErrMsg + "AnonymisePatient"
+
+	// patientDataExportMergeLimit bounds the merges listed in an export.
+	patientDataExportMergeLimit = 1000
+)
+
+// ExportPatientData gathers everything held about a patient, for their access
+// request under UU PDP, and records the export. The archive is written with
+// WritePatientDataExport.
+func (u *PatientUC) ExportPatientData(ctx context.Context, params model.ExportPatientDataParams) (export model.PatientDataExport, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, params.PatientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+	export.Patient = patientResponse(patient)
+
+	export.Relations, err = u.PatientDB.ListPatientRelations(ctx, userDetail.InstitutionID, []int64{patient.ID})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	export.Payers, err = u.PatientDB.ListPatientPayers(ctx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	export.Timeline = []model.TimelineEntry{}
+	var cursor *model.TimelineCursor
+	for {
+		entries, errList := u.PatientDB.ListPatientTimeline(ctx, userDetail.InstitutionID, patient.ID,
+			model.GetPatientTimelineParams{}, cursor, model.PatientDataExportTimelinePageSize)
+		if errList != nil {
+			err = errors.Wrap(errList, WrapMsgExportPatientData)
+			return
+		}
+		export.Timeline = append(export.Timeline, entries...)
+		if len(entries) < model.PatientDataExportTimelinePageSize {
+			break
+		}
+		next := model.NewTimelineCursor(entries[len(entries)-1])
+		cursor = &next
+	}
+
+	export.Consents = []model.TrxConsent{}
+	for _, entry := range export.Timeline {
+		if entry.Kind != model.TimelineKindVisit || !entry.VisitID.Valid {
+			continue
+		}
+		consents, errList := u.ConsentDB.ListVisitConsents(ctx, userDetail.InstitutionID, entry.VisitID.Int64)
+		if errList != nil {
+			err = errors.Wrap(errList, WrapMsgExportPatientData)
+			return
+		}
+		export.Consents = append(export.Consents, consents...)
+	}
+
+	export.Attachments, err = u.AttachmentDB.ListAttachments(ctx, userDetail.InstitutionID, patient.ID, model.ListAttachmentParams{})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	export.Ledger, err = u.BillingDB.ListLedgerEntries(ctx, model.PatientAccountStatementParams{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	export.Merges, err = u.PatientDB.ListPatientMerges(ctx, model.ListPatientMergeParams{
+		PatientUUID:          patient.UUID,
+		IDMstInstitution:     userDetail.InstitutionID,
+		CommonRequestPayload: model.CommonRequestPayload{Limit: patientDataExportMergeLimit},
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	request := model.TrxPatientDataRequest{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+		RequestType:      model.PatientDataRequestTypeExport,
+		Reason:           strings.TrimSpace(params.Reason),
+		RequestedBy:      userDetail.Email,
+	}
+	err = u.PatientDB.InsertPatientDataRequest(ctx, &request)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	export.DataRequests, err = u.PatientDB.ListPatientDataRequests(ctx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgExportPatientData)
+		return
+	}
+
+	export.Manifest = model.PatientDataExportManifest{
+		PatientUUID: patient.UUID,
+		RequestID:   request.ID,
+		ExportedBy:  userDetail.Email,
+		ExportedAt:  time.Now(),
+	}
+	return
+}
+
+// WritePatientDataExport writes export to w as a zip archive: a JSON file per
+// part of the record, the consent signatures and the attachment files, and a
+// manifest listing them. Attachment files missing from storage are listed in
+// the manifest and skipped.
+func (u *PatientUC) WritePatientDataExport(ctx context.Context, export model.PatientDataExport, w io.Writer) (err error) {
+	archive := zip.NewWriter(w)
+	manifest := export.Manifest
+
+	create := func(name string) (io.Writer, error) {
+		manifest.Files = append(manifest.Files, name)
+		return archive.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: manifest.ExportedAt,
+		})
+	}
+	writeJSON := func(name string, content interface{}) error {
+		file, err := create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(content)
+	}
+
+	for _, part := range []struct {
+		name    string
+		content interface{}
+	}{
+		{model.PatientDataExportPatientFile, model.PatientDataExportPatient{Patient: export.Patient, Relations: export.Relations, Payers: export.Payers}},
+		{model.PatientDataExportTimelineFile, export.Timeline},
+		{model.PatientDataExportConsentFile, export.Consents},
+		{model.PatientDataExportAttachmentFile, export.Attachments},
+		{model.PatientDataExportBillingFile, export.Ledger},
+		{model.PatientDataExportAuditFile, model.PatientDataExportAudit{Merges: export.Merges, DataRequests: export.DataRequests}},
+	} {
+		if err = writeJSON(part.name, part.content); err != nil {
+			err = errors.Wrap(err, WrapMsgWritePatientDataExport)
+			return
+		}
+	}
+
+	for _, consent := range export.Consents {
+		signature, found, errGet := u.ConsentDB.GetConsentSignature(ctx, consent.IDMstInstitution, consent.ID)
+		if errGet != nil {
+			err = errors.Wrap(errGet, WrapMsgWritePatientDataExport)
+			return
+		}
+		if !found || len(signature.Image) == 0 {
+			continue
+		}
+		file, errCreate := create(model.PatientDataExportSignaturePath(consent))
+		if errCreate != nil {
+			err = errors.Wrap(errCreate, WrapMsgWritePatientDataExport)
+			return
+		}
+		if _, err = file.Write(signature.Image); err != nil {
+			err = errors.Wrap(err, WrapMsgWritePatientDataExport)
+			return
+		}
+	}
+
+	for _, attachment := range export.Attachments {
+		err = u.writeExportAttachment(ctx, attachment, create)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Errorf("export patient %s: attachment %d is missing from storage", manifest.PatientUUID, attachment.ID)
+			manifest.MissingFiles = append(manifest.MissingFiles, model.PatientDataExportAttachmentPath(attachment))
+			continue
+		}
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgWritePatientDataExport)
+			return
+		}
+	}
+
+	// the manifest comes last so it lists every file of the archive
+	if err = writeJSON(model.PatientDataExportManifestFile, &manifest); err != nil {
+		err = errors.Wrap(err, WrapMsgWritePatientDataExport)
+		return
+	}
+
+	if err = archive.Close(); err != nil {
+		err = errors.Wrap(err, WrapMsgWritePatientDataExport)
+		return
+	}
+	return
+}
+
+func (u *PatientUC) writeExportAttachment(ctx context.Context, attachment model.TrxPatientAttachment, create func(name string) (io.Writer, error)) (err error) {
+	content, err := u.Storage.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return
+	}
+	defer content.Close()
+
+	file, err := create(model.PatientDataExportAttachmentPath(attachment))
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(file, content)
+	return
+}
+
+// AnonymisePatient scrubs what identifies a patient, for their deletion
+// request under UU PDP, and records who did it and why. The patient keeps
+// their medical record number, sex and year of birth, and their visits,
+// clinical records, attachments and billing stay for their retention periods.
+// Patients merged into them are scrubbed too. It cannot be undone.
+func (u *PatientUC) AnonymisePatient(ctx context.Context, req model.AnonymisePatientRequest) (response model.GetPatientResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		errMsg := commonerr.NewErrorMessage()
+		errMsg.Append("reason", "reason is required")
+		err = errMsg.SetUnprocessableEntity()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, req.PatientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+	if patient.AnonymiseTime.Valid {
+		err = commonerr.SetNewBadRequest("patient already anonymised", "the patient has already been anonymised")
+		return
+	}
+
+	merged, err := u.PatientDB.ListMergedPatients(ctx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+	patients := append([]model.MstPatientInstitution{patient}, merged...)
+	identifiers := model.PatientIdentifiers(patients, model.ConsentDateLayout)
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	now := time.Now()
+	patientIDs := make([]int64, 0, len(patients))
+	for i, p := range patients {
+		patientIDs = append(patientIDs, p.ID)
+		err = u.PatientDB.AnonymisePatient(ctx, model.AnonymisedPatient(p, now))
+		if errors.Is(err, constant.ErrorNoAffectedRow) {
+			if i == 0 {
+				err = commonerr.SetNewBadRequest("patient already anonymised", "the patient has already been anonymised")
+				return
+			}
+			// a merged patient anonymised before the merge
+			err = nil
+			continue
+		}
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgAnonymisePatient)
+			return
+		}
+	}
+
+	err = u.PatientDB.AnonymisePatientRecords(ctx, userDetail.InstitutionID, patientIDs, identifiers)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+
+	err = u.PatientDB.InsertPatientDataRequest(ctx, &model.TrxPatientDataRequest{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+		RequestType:      model.PatientDataRequestTypeAnonymise,
+		Reason:           req.Reason,
+		RequestedBy:      userDetail.Email,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgAnonymisePatient)
+		return
+	}
+
+	response = patientResponse(model.AnonymisedPatient(patient, now))
+	return
+}
@@ -0,0 +1,405 @@
+package patient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/log"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+const (
+	WrapMsgImportPatients           = WrapErrMsg + "ImportPatients"
+	WrapMsgGetPatientImport         = WrapErrMsg + "GetPatientImport"
+	WrapMsgListPatientImports       = WrapErrMsg + "ListPatientImports"
+	WrapMsgGetPatientImportReport   = WrapErrMsg + "GetPatientImportReport"
+	WrapMsgProcessNextPatientImport = WrapErrMsg + "ProcessNextPatientImport"
+
+	patientImportPollInterval = 5 * time.Second
+	// patientImportStaleAfter is how long an import may go without progress
+	// before it is taken for one left by a stopped server.
+	patientImportStaleAfter = 10 * time.Minute
+)
+
+// ImportPatients queues an uploaded patient file for the patient import job
+// once its header maps to the fields a patient needs. Its lines are only
+// validated as they are imported.
+func (u *PatientUC) ImportPatients(ctx context.Context, req model.PatientImportRequest) (response model.PatientImportResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	if req.Options.DateOrder == "" {
+		req.Options.DateOrder = model.DateOrderDMY
+	}
+	if !model.IsValidDateOrder(req.Options.DateOrder) {
+		err = commonerr.SetNewUnprocessableEntityError("date_order", "must be dmy, mdy or ymd")
+		return
+	}
+	if len(req.Rows) < 2 {
+		err = commonerr.SetNewUnprocessableEntityError("file", "has no patients below its header")
+		return
+	}
+	_, lineErrors := model.ResolvePatientImportColumns(req.Rows[0], req.Options.Mapping)
+	if len(lineErrors) > 0 {
+		errMsg := commonerr.NewErrorMessage()
+		for _, lineErr := range lineErrors {
+			errMsg.Append(fmt.Sprintf("line %d.%s", lineErr.Line, lineErr.Column), lineErr.Message)
+		}
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	rows, err := json.Marshal(req.Rows)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgImportPatients)
+		return
+	}
+	options, err := json.Marshal(req.Options)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgImportPatients)
+		return
+	}
+
+	patientImport := model.TrxPatientImport{
+		IDMstInstitution: userDetail.InstitutionID,
+		FileName:         req.FileName,
+		Status:           model.PatientImportStatusQueued,
+		Options:          options,
+		Rows:             rows,
+		TotalRows:        len(req.Rows) - 1,
+		Errors:           json.RawMessage(`[]`),
+		CreatedBy:        userDetail.Email,
+	}
+	err = u.PatientDB.InsertPatientImport(ctx, &patientImport)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgImportPatients)
+		return
+	}
+
+	return patientImportResponse(patientImport), nil
+}
+
+// GetPatientImport returns the status and progress of an import.
+func (u *PatientUC) GetPatientImport(ctx context.Context, importID int64) (response model.PatientImportResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patientImport, found, err := u.PatientDB.GetPatientImport(ctx, userDetail.InstitutionID, importID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientImport)
+		return
+	}
+	if !found {
+		err = commonerr.SetNewBadRequest("import not found", fmt.Sprintf("no patient import %d in this institution", importID))
+		return
+	}
+
+	return patientImportResponse(patientImport), nil
+}
+
+func (u *PatientUC) ListPatientImports(ctx context.Context, params model.ListPatientImportParams) (responses []model.PatientImportResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = defaultLimit
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+
+	patientImports, err := u.PatientDB.ListPatientImports(ctx, params)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientImports)
+		return
+	}
+
+	responses = make([]model.PatientImportResponse, 0, len(patientImports))
+	for _, patientImport := range patientImports {
+		responses = append(responses, patientImportResponse(patientImport))
+	}
+	return
+}
+
+// GetPatientImportReport returns the lines of an import that were not
+// imported so far, with why.
+func (u *PatientUC) GetPatientImportReport(ctx context.Context, importID int64) (report model.PatientImportReport, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patientImport, found, err := u.PatientDB.GetPatientImportWithRows(ctx, userDetail.InstitutionID, importID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientImportReport)
+		return
+	}
+	if !found {
+		err = commonerr.SetNewBadRequest("import not found", fmt.Sprintf("no patient import %d in this institution", importID))
+		return
+	}
+
+	var rows [][]string
+	lineErrors := []model.PatientImportLineError{}
+	if err = json.Unmarshal(patientImport.Rows, &rows); err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientImportReport)
+		return
+	}
+	if err = json.Unmarshal(patientImport.Errors, &lineErrors); err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientImportReport)
+		return
+	}
+
+	return model.BuildPatientImportReport(rows, lineErrors), nil
+}
+
+// RunPatientImportJob imports the queued patient files one after another, then
+// looks for new ones every patientImportPollInterval until ctx is done.
+func (u *PatientUC) RunPatientImportJob(ctx context.Context) {
+	ticker := time.NewTicker(patientImportPollInterval)
+	defer ticker.Stop()
+
+	log.Info("Patient import job started")
+	for {
+		for {
+			processed, err := u.ProcessNextPatientImport(ctx)
+			if err != nil {
+				log.Errorf("Patient import error: %v", err)
+				break
+			}
+			if !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Patient import job stopped (context cancelled)")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNextPatientImport claims the next import waiting and imports it to
+// the end, reporting whether there was one. An import that cannot go on is
+// marked failed; one interrupted by ctx is left to be claimed again.
+func (u *PatientUC) ProcessNextPatientImport(ctx context.Context) (processed bool, err error) {
+	patientImport, found, err := u.PatientDB.ClaimPatientImport(ctx, time.Now().Add(-patientImportStaleAfter))
+	if err != nil || !found {
+		err = errors.Wrap(err, WrapMsgProcessNextPatientImport)
+		return
+	}
+
+	status, failureReason := model.PatientImportStatusCompleted, ""
+	err = u.importPatientFile(ctx, &patientImport)
+	if ctx.Err() != nil {
+		return true, errors.Wrap(ctx.Err(), WrapMsgProcessNextPatientImport)
+	}
+	if err != nil {
+		log.Errorf("%s: patient import %d: %v", WrapMsgProcessNextPatientImport, patientImport.ID, err)
+		status, failureReason = model.PatientImportStatusFailed, "the import stopped on an unexpected error; the lines processed before it were kept"
+		if lineErr, ok := errors.Cause(err).(model.PatientImportLineError); ok {
+			failureReason = lineErr.Error()
+		}
+	}
+
+	err = u.PatientDB.FinishPatientImport(ctx, patientImport.ID, status, failureReason)
+	if err != nil {
+		return true, errors.Wrap(err, WrapMsgProcessNextPatientImport)
+	}
+	return true, nil
+}
+
+// importPatientFile imports the lines of patientImport after those already
+// processed, PatientImportBatchSize lines per transaction.
+func (u *PatientUC) importPatientFile(ctx context.Context, patientImport *model.TrxPatientImport) (err error) {
+	var rows [][]string
+	options := model.PatientImportOptions{}
+	if err = json.Unmarshal(patientImport.Rows, &rows); err != nil {
+		return
+	}
+	if err = json.Unmarshal(patientImport.Options, &options); err != nil {
+		return
+	}
+	if len(rows) == 0 {
+		return errors.New("the file has no rows")
+	}
+
+	columns, lineErrors := model.ResolvePatientImportColumns(rows[0], options.Mapping)
+	if len(lineErrors) > 0 {
+		return lineErrors[0]
+	}
+
+	records := rows[1:]
+	for patientImport.ProcessedRows < len(records) {
+		end := patientImport.ProcessedRows + model.PatientImportBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		if err = u.importPatientBatch(ctx, patientImport, columns, options, records, end); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// importPatientBatch imports the records from the import's processed count up
+// to end and saves its progress, all in one transaction. A line that is
+// invalid, or a patient the institution already has, is recorded as an error
+// of the import rather than stopping it. The imported patients are numbered in
+// one counter update at the end, so the institution's counter is only locked
+// for the last steps of the batch and registrations are not held up by it.
+func (u *PatientUC) importPatientBatch(ctx context.Context, patientImport *model.TrxPatientImport, columns model.PatientImportColumns, options model.PatientImportOptions, records [][]string, end int) (err error) {
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	progress := *patientImport
+	lineErrors := []model.PatientImportLineError{}
+	importedIDs := []int64{}
+	now := time.Now()
+	for i := progress.ProcessedRows; i < end; i++ {
+		if model.IsBlankImportRecord(records[i]) {
+			continue
+		}
+
+		// line numbers count the header as line 1
+		row, errs := model.ParsePatientImportRow(i+2, records[i], columns, options.DateOrder, now)
+		if len(errs) > 0 {
+			progress.FailedRows++
+			lineErrors = append(lineErrors, errs...)
+			continue
+		}
+
+		patient := row.Patient(progress.IDMstInstitution)
+		var (
+			duplicate   model.PatientImportLineError
+			isDuplicate bool
+		)
+		duplicate, isDuplicate, err = u.importDuplicate(ctx, columns, row, patient, options.ImportPossibleDuplicates)
+		if err != nil {
+			return
+		}
+		if isDuplicate {
+			progress.DuplicateRows++
+			lineErrors = append(lineErrors, duplicate)
+			continue
+		}
+
+		if err = u.PatientDB.RegisterNewPatient(ctx, &patient); err != nil {
+			return
+		}
+		importedIDs = append(importedIDs, patient.ID)
+		progress.ImportedRows++
+	}
+	progress.ProcessedRows = end
+
+	numbers, err := u.PatientDB.ReserveMedicalRecordNumbers(ctx, progress.IDMstInstitution, now, len(importedIDs))
+	if err != nil {
+		return
+	}
+	if err = u.PatientDB.SetMedicalRecordNumbers(ctx, importedIDs, numbers); err != nil {
+		return
+	}
+
+	if err = u.PatientDB.SavePatientImportProgress(ctx, progress, lineErrors); err != nil {
+		return
+	}
+	*patientImport = progress
+	return
+}
+
+// importDuplicate tells whether the institution already has the patient of an
+// import line: a patient with its legacy record number or NIK, or, unless
+// possible duplicates are imported, one that scores as a possible duplicate.
+// Patients imported by earlier lines count, so a file repeating a patient
+// imports them once.
+func (u *PatientUC) importDuplicate(ctx context.Context, columns model.PatientImportColumns, row model.PatientImportRow, patient model.MstPatientInstitution, importPossibleDuplicates bool) (lineErr model.PatientImportLineError, found bool, err error) {
+	if row.LegacyRecordNumber != "" {
+		existing, exists, getErr := u.PatientDB.GetPatientByLegacyRecordNumber(ctx, patient.InstitutionID, row.LegacyRecordNumber)
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		if exists {
+			return model.PatientImportLineError{
+				Line:        row.Line,
+				Column:      columns.Header(model.PatientImportFieldLegacyRecordNumber),
+				Message:     fmt.Sprintf("already belongs to patient %s", importPatientLabel(existing)),
+				DuplicateOf: existing.UUID,
+			}, true, nil
+		}
+	}
+
+	others, err := u.PatientDB.FindPatientDuplicateCandidates(ctx, patient)
+	if err != nil {
+		return
+	}
+
+	var (
+		best        model.MstPatientInstitution
+		bestScore   float64
+		bestReasons []string
+	)
+	for _, other := range others {
+		score, reasons := model.ScorePatientDuplicate(patient, other)
+		if len(reasons) > 0 && reasons[0] == model.DuplicateReasonNIK {
+			return model.PatientImportLineError{
+				Line:        row.Line,
+				Column:      columns.Header(model.PatientImportFieldNIK),
+				Message:     fmt.Sprintf("already belongs to patient %s", importPatientLabel(other)),
+				DuplicateOf: other.UUID,
+			}, true, nil
+		}
+		if score > bestScore {
+			best, bestScore, bestReasons = other, score, reasons
+		}
+	}
+
+	if importPossibleDuplicates || bestScore < model.PatientDuplicateMinScore {
+		return
+	}
+	return model.PatientImportLineError{
+		Line: row.Line,
+		Message: fmt.Sprintf("looks like patient %s (score %.2f, matching %s); import possible duplicates to add it anyway",
+			importPatientLabel(best), bestScore, strings.Join(bestReasons, ", ")),
+		DuplicateOf: best.UUID,
+	}, true, nil
+}
+
+// importPatientLabel names a patient in an import line error. A patient imported
+// earlier in the same batch has no medical record number yet.
+func importPatientLabel(patient model.MstPatientInstitution) string {
+	if patient.MedicalRecordNumber == "" {
+		return patient.Name
+	}
+	return fmt.Sprintf("%s, %s", patient.MedicalRecordNumber, patient.Name)
+}
+
+func patientImportResponse(patientImport model.TrxPatientImport) model.PatientImportResponse {
+	return model.PatientImportResponse{
+		TrxPatientImport: patientImport,
+		Progress:         patientImport.Progress(),
+	}
+}
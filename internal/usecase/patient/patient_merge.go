@@ -0,0 +1,292 @@
+package patient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+const (
+	WrapMsgListPatientDuplicates = WrapErrMsg + "ListPatientDuplicates"
+	WrapMsgMergePatients         = WrapErrMsg + "MergePatients"
+	WrapMsgUndoPatientMerge      = WrapErrMsg + "UndoPatientMerge"
+	WrapMsgListPatientMerges     = WrapErrMsg + "ListPatientMerges"
+)
+
+// ListPatientDuplicates returns the patients that may be the same person as
+// the given one, best match first.
+func (u *PatientUC) ListPatientDuplicates(ctx context.Context, patientUUID string) (candidates []model.PatientDuplicateCandidate, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patients, err := u.PatientDB.GetPatients(ctx, model.GetPatientParams{
+		PatientUUIDs:  []string{patientUUID},
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientDuplicates)
+		return
+	}
+	if len(patients) == 0 {
+		err = commonerr.SetNewBadRequest("patient not found", fmt.Sprintf("there is no patient with registered with uuid = %v", patientUUID))
+		return
+	}
+
+	candidates, err = u.findDuplicates(ctx, patients[0])
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientDuplicates)
+		return
+	}
+
+	return
+}
+
+// findDuplicates scores the institution's patients that share a NIK, date of
+// birth, phone number or name word with patient and keeps the likely ones.
+func (u *PatientUC) findDuplicates(ctx context.Context, patient model.MstPatientInstitution) (candidates []model.PatientDuplicateCandidate, err error) {
+	others, err := u.PatientDB.FindPatientDuplicateCandidates(ctx, patient)
+	if err != nil {
+		return
+	}
+
+	candidates = []model.PatientDuplicateCandidate{}
+	for _, other := range others {
+		score, reasons := model.ScorePatientDuplicate(patient, other)
+		if score < model.PatientDuplicateMinScore {
+			continue
+		}
+		candidates = append(candidates, model.PatientDuplicateCandidate{
+			GetPatientResponse: patientResponse(other),
+			Score:              score,
+			Reasons:            reasons,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > model.PatientDuplicateLimit {
+		candidates = candidates[:model.PatientDuplicateLimit]
+	}
+
+	return
+}
+
+// MergePatients folds one patient into another in a single transaction: the
+// merged patient's visits, with their diagnoses and procedures, recalls,
+// ledger entries and odontogram events move to the survivor, the merged
+// patient is removed, and a merge record keeps what moved so it can be undone.
+func (u *PatientUC) MergePatients(ctx context.Context, req model.MergePatientsRequest) (merge model.PatientMergeRow, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patients, err := u.PatientDB.GetPatients(ctx, model.GetPatientParams{
+		PatientUUIDs:  []string{req.SurvivorUUID, req.MergedUUID},
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+	var survivor, merged model.MstPatientInstitution
+	for _, patient := range patients {
+		switch patient.UUID {
+		case req.SurvivorUUID:
+			survivor = patient
+		case req.MergedUUID:
+			merged = patient
+		}
+	}
+	errMsg := commonerr.NewErrorMessage()
+	if survivor.ID == 0 {
+		errMsg.Append("survivor_uuid", "patient not found")
+	}
+	if merged.ID == 0 {
+		errMsg.Append("merged_uuid", "patient not found")
+	}
+	if survivor.ID == 0 || merged.ID == 0 {
+		err = errMsg.SetUnprocessableEntity()
+		return
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	err = u.PatientDB.SetPatientDeleted(ctx, userDetail.InstitutionID, merged.ID, true)
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		err = commonerr.SetNewBadRequest("patient already merged", "the patient to merge has already been merged or removed")
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+
+	undo, err := u.PatientDB.MovePatientRecords(ctx, userDetail.InstitutionID, merged.ID, survivor.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+	undoData, err := json.Marshal(undo)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+
+	record := model.TrxPatientMerge{
+		IDMstInstitution:     userDetail.InstitutionID,
+		SurvivorIDMstPatient: survivor.ID,
+		MergedIDMstPatient:   merged.ID,
+		Reason:               req.Reason,
+		UndoData:             undoData,
+		MergedBy:             userDetail.Email,
+	}
+	err = u.PatientDB.InsertPatientMerge(ctx, &record)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+
+	merge, _, err = u.PatientDB.GetPatientMerge(ctx, userDetail.InstitutionID, record.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgMergePatients)
+		return
+	}
+
+	return
+}
+
+// UndoPatientMerge reverses a merge: the merged patient comes back and the
+// rows the merge moved return to it. Anything recorded on the survivor since
+// the merge stays there.
+func (u *PatientUC) UndoPatientMerge(ctx context.Context, mergeID int64) (merge model.PatientMergeRow, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	merge, found, err = u.PatientDB.GetPatientMerge(ctx, userDetail.InstitutionID, mergeID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+	if !found {
+		err = commonerr.SetNewBadRequest("merge not found", fmt.Sprintf("there is no patient merge with id = %d", mergeID))
+		return
+	}
+	if merge.UndoTime != nil {
+		err = commonerr.SetNewBadRequest("merge already undone", "this patient merge has already been undone")
+		return
+	}
+
+	survivor, err := u.PatientDB.GetPatientByID(ctx, merge.SurvivorIDMstPatient)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+	if survivor.ID == 0 {
+		err = commonerr.SetNewBadRequest("survivor removed", "the surviving patient has since been merged or removed; undo that first")
+		return
+	}
+
+	undo := model.PatientMergeUndo{}
+	err = json.Unmarshal(merge.UndoData, &undo)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+
+	err = u.PatientDB.SetPatientDeleted(ctx, userDetail.InstitutionID, merge.MergedIDMstPatient, false)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+	err = u.PatientDB.RestorePatientRecords(ctx, userDetail.InstitutionID, merge.SurvivorIDMstPatient, merge.MergedIDMstPatient, undo)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+	err = u.PatientDB.MarkPatientMergeUndone(ctx, userDetail.InstitutionID, mergeID, userDetail.Email)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+
+	merge, _, err = u.PatientDB.GetPatientMerge(ctx, userDetail.InstitutionID, mergeID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgUndoPatientMerge)
+		return
+	}
+
+	return
+}
+
+func (u *PatientUC) ListPatientMerges(ctx context.Context, params model.ListPatientMergeParams) (merges []model.PatientMergeRow, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = defaultLimit
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+
+	merges, err = u.PatientDB.ListPatientMerges(ctx, params)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientMerges)
+		return
+	}
+
+	return
+}
+
+func patientResponse(patient model.MstPatientInstitution) model.GetPatientResponse {
+	return model.GetPatientResponse{
+		UUID:                patient.UUID,
+		MedicalRecordNumber: patient.MedicalRecordNumber,
+		NIK:                 patient.NIK,
+		Name:                patient.Name,
+		PlaceOfBirth:        patient.PlaceOfBirth,
+		DateOfBirth:         patient.DateOfBirth,
+		Address:             patient.Address,
+		Religion:            patient.Religion,
+		PhoneNumber:         patient.PhoneNumber,
+		Sex:                 patient.Sex,
+		Occupation:          patient.Occupation,
+		PatientCategory:     patient.PatientCategory,
+		LegacyRecordNumber:  patient.LegacyRecordNumber,
+		LegacyLastVisitDate: patient.LegacyLastVisitDate,
+		AnonymiseTime:       patient.AnonymiseTime,
+	}
+}
@@ -0,0 +1,264 @@
+package patient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/common/log"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/eligibility"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+const (
+	WrapMsgListPatientPayers     = WrapErrMsg + "ListPatientPayers"
+	WrapMsgSavePatientPayer      = WrapErrMsg + "SavePatientPayer"
+	WrapMsgDeletePatientPayer    = WrapErrMsg + "DeletePatientPayer"
+	WrapMsgCheckPayerEligibility = WrapErrMsg + "CheckPayerEligibility"
+)
+
+// ListPatientPayers returns the patient's payers, the default payer first.
+func (u *PatientUC) ListPatientPayers(ctx context.Context, patientUUID string) (payers []model.MstPatientPayer, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientPayers)
+		return
+	}
+
+	payers, err = u.PatientDB.ListPatientPayers(ctx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientPayers)
+		return
+	}
+	return
+}
+
+// SavePatientPayer adds a payer to the patient, or replaces the one with
+// req.ID. A payer is the default while the patient has no other; making
+// another the default unmarks the previous one. A membership can only be on
+// one patient.
+func (u *PatientUC) SavePatientPayer(ctx context.Context, req model.SavePatientPayerRequest) (payer model.MstPatientPayer, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, req.PatientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientPayer)
+		return
+	}
+
+	payer = model.MstPatientPayer{
+		ID:                req.ID,
+		IDMstInstitution:  userDetail.InstitutionID,
+		IDMstPatient:      patient.ID,
+		PayerType:         req.PayerType,
+		MemberNumber:      strings.TrimSpace(req.MemberNumber),
+		CoverageRate:      1,
+		CoverageLimit:     req.CoverageLimit,
+		IsDefault:         req.IsDefault,
+		EligibilityStatus: model.EligibilityStatusUnchecked,
+		Notes:             req.Notes,
+		UpdatedBy:         userDetail.Email,
+	}
+	if req.CoverageRate != nil {
+		payer.CoverageRate = *req.CoverageRate
+	}
+	if req.ValidFrom != nil {
+		payer.ValidFrom = &req.ValidFrom.Time
+	}
+	if req.ValidTo != nil {
+		payer.ValidTo = &req.ValidTo.Time
+	}
+	if req.PayerType == model.PayerTypeBPJS {
+		if req.BPJSClass != "" {
+			payer.BPJSClass = null.StringFrom(req.BPJSClass)
+		}
+		payer.FKTPCode = strings.TrimSpace(req.FKTPCode)
+		payer.FKTPName = strings.TrimSpace(req.FKTPName)
+	} else {
+		payer.InsurerName = strings.TrimSpace(req.InsurerName)
+		payer.PolicyNumber = strings.TrimSpace(req.PolicyNumber)
+		payer.PolicyHolder = strings.TrimSpace(req.PolicyHolder)
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	if payer.PayerType == model.PayerTypeInsurance && payer.InsurerName == "" {
+		errMsg.Append("insurer_name", "insurer_name is required for insurance")
+	}
+	if payer.CoverageLimit.Valid && payer.CoverageLimit.Money.IsNegative() {
+		errMsg.Append("coverage_limit", "coverage_limit must not be negative")
+	}
+	if payer.ValidFrom != nil && payer.ValidTo != nil && payer.ValidTo.Before(*payer.ValidFrom) {
+		errMsg.Append("valid_to", "valid_to must not be before valid_from")
+	}
+	if len(errMsg.ErrorList) > 0 {
+		err = errMsg.SetUnprocessableEntity()
+		return
+	}
+
+	holders, err := u.PatientDB.FindPatientPayersByMemberNumber(ctx, userDetail.InstitutionID, payer.PayerType, payer.MemberNumber)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientPayer)
+		return
+	}
+	for _, holder := range holders {
+		if holder.ID == payer.ID {
+			continue
+		}
+		if holder.IDMstPatient == patient.ID {
+			err = commonerr.SetNewUnprocessableEntityError("member_number", "the patient already has this membership")
+			return
+		}
+		err = commonerr.SetNewUnprocessableEntityError("member_number", "the membership is registered to another patient")
+		return
+	}
+
+	payers, err := u.PatientDB.ListPatientPayers(ctx, userDetail.InstitutionID, patient.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientPayer)
+		return
+	}
+	hasDefault := false
+	for _, other := range payers {
+		if other.IsDefault && other.ID != payer.ID {
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		payer.IsDefault = true
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientPayer)
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	if payer.IsDefault {
+		err = u.PatientDB.ClearDefaultPatientPayer(ctx, userDetail.InstitutionID, patient.ID)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgSavePatientPayer)
+			return
+		}
+	}
+
+	if payer.ID == 0 {
+		err = u.PatientDB.InsertPatientPayer(ctx, &payer)
+	} else {
+		err = u.PatientDB.UpdatePatientPayer(ctx, &payer)
+	}
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		err = commonerr.SetNewBadRequest("payer not found", fmt.Sprintf("patient %s has no payer %d", req.PatientUUID, req.ID))
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientPayer)
+		return
+	}
+	return
+}
+
+func (u *PatientUC) DeletePatientPayer(ctx context.Context, patientUUID string, payerID int64) (err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgDeletePatientPayer)
+		return
+	}
+
+	err = u.PatientDB.DeletePatientPayer(ctx, userDetail.InstitutionID, patient.ID, payerID)
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		err = commonerr.SetNewBadRequest("payer not found", fmt.Sprintf("patient %s has no payer %d", patientUUID, payerID))
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgDeletePatientPayer)
+		return
+	}
+	return
+}
+
+// CheckPayerEligibility asks the payer whether it covers the patient on the
+// service date and keeps the answer on the payer. An ineligible member is not
+// an error; the returned payer says why in its eligibility message.
+func (u *PatientUC) CheckPayerEligibility(ctx context.Context, req model.CheckPatientPayerEligibilityRequest) (payer model.MstPatientPayer, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, req.PatientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCheckPayerEligibility)
+		return
+	}
+
+	payer, found, err = u.PatientDB.GetPatientPayer(ctx, userDetail.InstitutionID, patient.ID, req.ID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCheckPayerEligibility)
+		return
+	}
+	if !found {
+		err = commonerr.SetNewBadRequest("payer not found", fmt.Sprintf("patient %s has no payer %d", req.PatientUUID, req.ID))
+		return
+	}
+
+	serviceDate := req.ServiceDate.Time
+	if serviceDate.IsZero() {
+		serviceDate = time.Now()
+	}
+	result, err := u.Eligibility.Check(ctx, eligibility.Request{
+		PayerType:    payer.PayerType,
+		MemberNumber: payer.MemberNumber,
+		FKTPCode:     payer.FKTPCode,
+		ValidFrom:    payer.ValidFrom,
+		ValidTo:      payer.ValidTo,
+		ServiceDate:  serviceDate,
+	})
+	if err != nil {
+		log.Errorf("check eligibility of payer %d: %v", payer.ID, err)
+		err = commonerr.SetNewError(http.StatusBadGateway, "eligibility_unavailable",
+			"the payer could not be asked about eligibility, try again or bill the patient")
+		return
+	}
+
+	payer.EligibilityStatus = model.EligibilityStatusIneligible
+	if result.Eligible() {
+		payer.EligibilityStatus = model.EligibilityStatusEligible
+	}
+	payer.EligibilityMessage = result.Message
+	payer.EligibilityCheckTime = null.TimeFrom(result.CheckedAt)
+
+	err = u.PatientDB.UpdatePatientPayerEligibility(ctx, payer)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgCheckPayerEligibility)
+		return
+	}
+	return
+}
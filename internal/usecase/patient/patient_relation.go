@@ -0,0 +1,184 @@
+package patient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+const (
+	WrapMsgListPatientRelations  = WrapErrMsg + "ListPatientRelations"
+	WrapMsgSavePatientRelation   = WrapErrMsg + "SavePatientRelation"
+	WrapMsgDeletePatientRelation = WrapErrMsg + "DeletePatientRelation"
+)
+
+// ListPatientRelations returns the patient's relations, the recall contact
+// first.
+func (u *PatientUC) ListPatientRelations(ctx context.Context, patientUUID string) (relations []model.PatientRelationResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientRelations)
+		return
+	}
+
+	relations, err = u.PatientDB.ListPatientRelations(ctx, userDetail.InstitutionID, []int64{patient.ID})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgListPatientRelations)
+		return
+	}
+	return
+}
+
+// SavePatientRelation adds a relation to the patient, or replaces the one
+// with req.ID. Making it the recall contact unmarks the previous one.
+func (u *PatientUC) SavePatientRelation(ctx context.Context, req model.SavePatientRelationRequest) (relation model.PatientRelationResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, req.PatientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientRelation)
+		return
+	}
+
+	row := model.MstPatientRelation{
+		ID:               req.ID,
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstPatient:     patient.ID,
+		Relationship:     req.Relationship,
+		Name:             strings.TrimSpace(req.Name),
+		NIK:              strings.TrimSpace(req.NIK),
+		Sex:              req.Sex,
+		PhoneNumber:      strings.TrimSpace(req.PhoneNumber),
+		Address:          strings.TrimSpace(req.Address),
+		IsRecallContact:  req.IsRecallContact,
+		Notes:            req.Notes,
+		UpdatedBy:        userDetail.Email,
+	}
+	if req.RelatedPatientUUID != "" {
+		related, getErr := u.PatientDB.GetPatientByParams(ctx, model.MstPatientInstitution{
+			UUID:          req.RelatedPatientUUID,
+			InstitutionID: userDetail.InstitutionID,
+		})
+		if getErr != nil {
+			err = errors.Wrap(getErr, WrapMsgSavePatientRelation)
+			return
+		}
+		if related.ID == 0 {
+			err = commonerr.SetNewUnprocessableEntityError("related_patient_uuid", "patient not found")
+			return
+		}
+		if related.ID == patient.ID {
+			err = commonerr.SetNewUnprocessableEntityError("related_patient_uuid", "a patient cannot be related to themself")
+			return
+		}
+		// the related patient's record is shown while it exists; these
+		// columns keep who they were if it is merged away
+		row.RelatedPatientID = null.Int64From(related.ID)
+		row.Name = related.Name
+		row.NIK = related.NIK
+		row.Sex = related.Sex
+		row.PhoneNumber = related.PhoneNumber
+		row.Address = related.Address
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientRelation)
+		return
+	}
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	if row.IsRecallContact {
+		err = u.PatientDB.ClearRecallContact(ctx, userDetail.InstitutionID, patient.ID)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgSavePatientRelation)
+			return
+		}
+	}
+
+	if row.ID == 0 {
+		err = u.PatientDB.InsertPatientRelation(ctx, &row)
+	} else {
+		err = u.PatientDB.UpdatePatientRelation(ctx, &row)
+	}
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		err = commonerr.SetNewBadRequest("relation not found", fmt.Sprintf("patient %s has no relation %d", req.PatientUUID, req.ID))
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientRelation)
+		return
+	}
+
+	relations, err := u.PatientDB.ListPatientRelations(ctx, userDetail.InstitutionID, []int64{patient.ID})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSavePatientRelation)
+		return
+	}
+	for _, saved := range relations {
+		if saved.ID == row.ID {
+			relation = saved
+		}
+	}
+	return
+}
+
+func (u *PatientUC) DeletePatientRelation(ctx context.Context, patientUUID string, relationID int64) (err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	patient, err := u.getPatientByUUID(ctx, userDetail.InstitutionID, patientUUID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgDeletePatientRelation)
+		return
+	}
+
+	err = u.PatientDB.DeletePatientRelation(ctx, userDetail.InstitutionID, patient.ID, relationID)
+	if errors.Is(err, constant.ErrorNoAffectedRow) {
+		err = commonerr.SetNewBadRequest("relation not found", fmt.Sprintf("patient %s has no relation %d", patientUUID, relationID))
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgDeletePatientRelation)
+		return
+	}
+	return
+}
+
+func (u *PatientUC) getPatientByUUID(ctx context.Context, institutionID int64, patientUUID string) (patient model.MstPatientInstitution, err error) {
+	patient, err = u.PatientDB.GetPatientByParams(ctx, model.MstPatientInstitution{
+		UUID:          patientUUID,
+		InstitutionID: institutionID,
+	})
+	if err != nil {
+		return
+	}
+	if patient.ID == 0 {
+		err = commonerr.SetNewBadRequest("patient not found", fmt.Sprintf("no patient with uuid %s in this institution", patientUUID))
+		return
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package patient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+)
+
+const (
+	WrapMsgGetPatientTimeline = WrapErrMsg + "GetPatientTimeline"
+)
+
+// GetPatientTimeline returns a page of everything recorded for the patient,
+// newest first. NextCursor is set when there are older entries.
+func (u *PatientUC) GetPatientTimeline(ctx context.Context, params model.GetPatientTimelineParams) (timeline model.PatientTimeline, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	for i, kind := range params.Kinds {
+		if !model.IsValidTimelineKind(kind) {
+			errMsg.Append(fmt.Sprintf("kind[%d]", i), fmt.Sprintf("unknown kind %q", kind))
+		}
+	}
+	if !params.FromTime.IsZero() && !params.ToTime.IsZero() && params.ToTime.Before(params.FromTime.Time) {
+		errMsg.Append("to_time", "to_time must not be before from_time")
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return
+	}
+
+	var cursor *model.TimelineCursor
+	if params.Cursor != "" {
+		decoded, errDecode := model.DecodeTimelineCursor(params.Cursor)
+		if errDecode != nil {
+			err = commonerr.SetNewBadRequest("invalid", "Invalid timeline cursor")
+			return
+		}
+		cursor = &decoded
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = model.DefaultTimelineLimit
+	}
+	if limit > model.MaxTimelineLimit {
+		limit = model.MaxTimelineLimit
+	}
+
+	patients, err := u.PatientDB.GetPatients(ctx, model.GetPatientParams{
+		PatientUUIDs:  []string{params.PatientUUID},
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientTimeline)
+		return
+	}
+	if len(patients) == 0 {
+		err = commonerr.SetNewBadRequest("patient not found", fmt.Sprintf("there is no patient with registered with uuid = %v", params.PatientUUID))
+		return
+	}
+
+	// one extra row tells whether another page follows
+	entries, err := u.PatientDB.ListPatientTimeline(ctx, userDetail.InstitutionID, patients[0].ID, params, cursor, limit+1)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetPatientTimeline)
+		return
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		timeline.NextCursor = model.NewTimelineCursor(entries[limit-1]).Encode()
+	}
+	timeline.Entries = entries
+
+	return
+}
@@ -0,0 +1,308 @@
+package pricelist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	pricelistrepo "github.com/faisalhardin/medilink/internal/entity/repo/pricelist"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+)
+
+const (
+	wrapMsgCreatePriceList        = "PriceListUC.CreatePriceList"
+	wrapMsgListPriceLists         = "PriceListUC.ListPriceLists"
+	wrapMsgGetPriceList           = "PriceListUC.GetPriceList"
+	wrapMsgUpdatePriceListItems   = "PriceListUC.UpdatePriceListItems"
+	wrapMsgDeletePriceList        = "PriceListUC.DeletePriceList"
+	wrapMsgGetProductPriceHistory = "PriceListUC.GetProductPriceHistory"
+	wrapMsgValidateItems          = "PriceListUC.validateItems"
+)
+
+type PriceListUC struct {
+	PriceListDB     pricelistrepo.PriceListDB
+	InstitutionRepo institutionrepo.InstitutionDB
+	Transaction     xormlib.DBTransactionInterface
+}
+
+func NewPriceListUC(u *PriceListUC) *PriceListUC {
+	return u
+}
+
+func (u *PriceListUC) CreatePriceList(ctx context.Context, req model.CreatePriceListRequest) (resp model.PriceListResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	effectiveFrom := time.Now()
+	if !req.EffectiveFrom.IsZero() {
+		effectiveFrom = req.EffectiveFrom.Time
+	}
+
+	items, err := u.validateItems(ctx, userDetail.InstitutionID, req.Items)
+	if err != nil {
+		return resp, err
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	latest, err := u.PriceListDB.GetLatestPriceList(txCtx, userDetail.InstitutionID, req.PatientCategory)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreatePriceList)
+	}
+	if latest != nil {
+		if !effectiveFrom.After(latest.EffectiveFrom) {
+			err = commonerr.SetNewBadRequest("price_list_effective_from",
+				fmt.Sprintf("effective_from must be after %s, when price list #%d takes effect",
+					latest.EffectiveFrom.Format(time.RFC3339), latest.ID))
+			return resp, err
+		}
+		if latest.EffectiveTo == nil || latest.EffectiveTo.After(effectiveFrom) {
+			if err = u.PriceListDB.SetPriceListEffectiveTo(txCtx, latest.ID, &effectiveFrom); err != nil {
+				return resp, errors.Wrap(err, wrapMsgCreatePriceList)
+			}
+		}
+	}
+
+	priceList := model.MstPriceList{
+		IDMstInstitution: userDetail.InstitutionID,
+		Name:             req.Name,
+		PatientCategory:  req.PatientCategory,
+		EffectiveFrom:    effectiveFrom,
+		Notes:            req.Notes,
+		CreatedBy:        userDetail.Email,
+	}
+	if err = u.PriceListDB.InsertPriceList(txCtx, &priceList); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreatePriceList)
+	}
+
+	for i := range items {
+		items[i].IDMstPriceList = priceList.ID
+	}
+	if err = u.PriceListDB.ReplacePriceListItems(txCtx, priceList.ID, items); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreatePriceList)
+	}
+
+	resp, err = u.buildResponse(txCtx, priceList)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreatePriceList)
+	}
+	return resp, nil
+}
+
+func (u *PriceListUC) ListPriceLists(ctx context.Context, params model.ListPriceListParams) ([]model.MstPriceList, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+
+	priceLists, err := u.PriceListDB.ListPriceLists(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListPriceLists)
+	}
+	return priceLists, nil
+}
+
+func (u *PriceListUC) GetPriceList(ctx context.Context, priceListID int64) (model.PriceListResponse, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.PriceListResponse{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	priceList, err := u.PriceListDB.GetPriceListByID(ctx, userDetail.InstitutionID, priceListID)
+	if err != nil {
+		return model.PriceListResponse{}, errors.Wrap(err, wrapMsgGetPriceList)
+	}
+	if priceList == nil {
+		return model.PriceListResponse{}, errPriceListNotFound()
+	}
+
+	resp, err := u.buildResponse(ctx, *priceList)
+	if err != nil {
+		return model.PriceListResponse{}, errors.Wrap(err, wrapMsgGetPriceList)
+	}
+	return resp, nil
+}
+
+func (u *PriceListUC) UpdatePriceListItems(ctx context.Context, priceListID int64, req model.UpdatePriceListItemsRequest) (resp model.PriceListResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	items, err := u.validateItems(ctx, userDetail.InstitutionID, req.Items)
+	if err != nil {
+		return resp, err
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	priceList, err := u.getPendingPriceList(txCtx, userDetail.InstitutionID, priceListID)
+	if err != nil {
+		return resp, err
+	}
+
+	for i := range items {
+		items[i].IDMstPriceList = priceList.ID
+	}
+	if err = u.PriceListDB.ReplacePriceListItems(txCtx, priceList.ID, items); err != nil {
+		return resp, errors.Wrap(err, wrapMsgUpdatePriceListItems)
+	}
+
+	resp, err = u.buildResponse(txCtx, *priceList)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgUpdatePriceListItems)
+	}
+	return resp, nil
+}
+
+func (u *PriceListUC) DeletePriceList(ctx context.Context, priceListID int64) (err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	priceList, err := u.getPendingPriceList(txCtx, userDetail.InstitutionID, priceListID)
+	if err != nil {
+		return err
+	}
+	if priceList.EffectiveTo != nil {
+		err = commonerr.SetNewBadRequest("price_list_superseded", "only the latest price list of a category can be deleted")
+		return err
+	}
+
+	if err = u.PriceListDB.DeletePriceList(txCtx, priceList.ID); err != nil {
+		return errors.Wrap(err, wrapMsgDeletePriceList)
+	}
+	if err = u.PriceListDB.ReopenPriceListEndingAt(txCtx, userDetail.InstitutionID, priceList.PatientCategory, priceList.EffectiveFrom); err != nil {
+		return errors.Wrap(err, wrapMsgDeletePriceList)
+	}
+	return nil
+}
+
+func (u *PriceListUC) GetProductPriceHistory(ctx context.Context, params model.ProductPriceHistoryParams) (resp model.ProductPriceHistoryResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	products, err := u.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              []int64{params.IDTrxInstitutionProduct},
+		IDMstInstitution: userDetail.InstitutionID,
+	})
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgGetProductPriceHistory)
+	}
+	if len(products) == 0 {
+		return resp, commonerr.SetNewError(http.StatusNotFound, "product_not_found", "product was not found in this institution")
+	}
+
+	history, err := u.PriceListDB.FindProductPriceHistory(ctx, userDetail.InstitutionID, params.IDTrxInstitutionProduct)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgGetProductPriceHistory)
+	}
+	entries, err := u.PriceListDB.FindProductPriceListEntries(ctx, userDetail.InstitutionID, params.IDTrxInstitutionProduct)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgGetProductPriceHistory)
+	}
+
+	return model.ProductPriceHistoryResponse{
+		IDTrxInstitutionProduct: products[0].ID,
+		Price:                   products[0].Price,
+		BasePriceChanges:        history,
+		PriceLists:              entries,
+	}, nil
+}
+
+// getPendingPriceList loads a list of the institution and rejects it once it
+// has taken effect, since visits may already have been priced from it.
+func (u *PriceListUC) getPendingPriceList(ctx context.Context, institutionID, priceListID int64) (*model.MstPriceList, error) {
+	priceList, err := u.PriceListDB.GetPriceListByID(ctx, institutionID, priceListID)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgGetPriceList)
+	}
+	if priceList == nil {
+		return nil, errPriceListNotFound()
+	}
+	if !priceList.EffectiveFrom.After(time.Now()) {
+		return nil, commonerr.SetNewBadRequest("price_list_effective", "price list is already effective; create a new price list instead")
+	}
+	return priceList, nil
+}
+
+// validateItems checks that every product belongs to the institution and is
+// listed once.
+func (u *PriceListUC) validateItems(ctx context.Context, institutionID int64, reqItems []model.PriceListItemRequest) ([]model.DtlPriceListItem, error) {
+	productIDs := make([]int64, 0, len(reqItems))
+	for _, item := range reqItems {
+		productIDs = append(productIDs, item.IDTrxInstitutionProduct)
+	}
+
+	products, err := u.InstitutionRepo.FindTrxInstitutionProductByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:              productIDs,
+		IDMstInstitution: institutionID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgValidateItems)
+	}
+	known := make(map[int64]struct{}, len(products))
+	for _, product := range products {
+		known[product.ID] = struct{}{}
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	seen := make(map[int64]struct{}, len(reqItems))
+	items := make([]model.DtlPriceListItem, 0, len(reqItems))
+	for i, item := range reqItems {
+		field := fmt.Sprintf("items[%d].product_id", i)
+		if _, ok := known[item.IDTrxInstitutionProduct]; !ok {
+			errMsg.Append(field, "product does not belong to this institution")
+			continue
+		}
+		if _, ok := seen[item.IDTrxInstitutionProduct]; ok {
+			errMsg.Append(field, "product is listed more than once")
+			continue
+		}
+		seen[item.IDTrxInstitutionProduct] = struct{}{}
+		items = append(items, model.DtlPriceListItem{
+			IDTrxInstitutionProduct: item.IDTrxInstitutionProduct,
+			Price:                   item.Price.Round(),
+		})
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return nil, errMsg
+	}
+	return items, nil
+}
+
+func (u *PriceListUC) buildResponse(ctx context.Context, priceList model.MstPriceList) (model.PriceListResponse, error) {
+	items, err := u.PriceListDB.GetPriceListItems(ctx, priceList.ID)
+	if err != nil {
+		return model.PriceListResponse{}, err
+	}
+	return model.PriceListResponse{
+		MstPriceList: priceList,
+		Items:        items,
+	}, nil
+}
+
+func errPriceListNotFound() error {
+	return commonerr.SetNewError(http.StatusNotFound, "price_list_not_found", "price list was not found in this institution")
+}
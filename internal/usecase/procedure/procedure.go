@@ -8,10 +8,12 @@ import (
 	"time"
 
 	"github.com/faisalhardin/medilink/internal/entity/model"
+	consentrepo "github.com/faisalhardin/medilink/internal/entity/repo/consent"
 	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
 	patientrepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
 	practitionerrepo "github.com/faisalhardin/medilink/internal/entity/repo/practitioner"
 	procedurerepo "github.com/faisalhardin/medilink/internal/entity/repo/procedure"
+	institutionuc "github.com/faisalhardin/medilink/internal/entity/usecase/institution"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
 	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
@@ -35,7 +37,11 @@ type ProcedureUC struct {
 	InstitutionRepo institutionrepo.InstitutionDB
 	PatientDB       patientrepo.PatientDB
 	PractitionerDB  practitionerrepo.PractitionerDB
-	Transaction     xormlib.DBTransactionInterface
+	InstitutionUC   institutionuc.InstitutionUC
+	// ConsentDB, when set, holds back procedures of a category whose consent
+	// template is required until a consent for it has been signed.
+	ConsentDB   consentrepo.ConsentDB
+	Transaction xormlib.DBTransactionInterface
 }
 
 func NewProcedureUC(u *ProcedureUC) *ProcedureUC {
@@ -123,6 +129,10 @@ func (u *ProcedureUC) Save(ctx context.Context, visitID int64, req model.SavePro
 		return resp, diffErr
 	}
 
+	if dbErr = u.validateConsents(ctx, userDetail.InstitutionID, visitID, req.Procedures, existingRows, errMsg); dbErr != nil {
+		return resp, errors.Wrap(dbErr, wrapMsgSave)
+	}
+
 	if len(errMsg.ErrorList) > 0 {
 		errMsg.SetUnprocessableEntity()
 		return resp, errMsg
@@ -138,17 +148,31 @@ func (u *ProcedureUC) Save(ctx context.Context, visitID int64, req model.SavePro
 	}, nil
 }
 
-func (u *ProcedureUC) Delete(ctx context.Context, visitID, procedureID int64) error {
+func (u *ProcedureUC) Delete(ctx context.Context, visitID, procedureID int64) (err error) {
 	userDetail, err := u.authorizeVisit(ctx, visitID)
 	if err != nil {
 		return err
 	}
-	found, dbErr := u.ProcedureDB.SoftDeleteByID(ctx, userDetail.InstitutionID, visitID, procedureID)
-	if dbErr != nil {
-		return errors.Wrap(dbErr, wrapMsgDelete)
+
+	session, err := u.Transaction.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, wrapMsgDelete)
+	}
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	found, err := u.ProcedureDB.SoftDeleteByID(txCtx, userDetail.InstitutionID, visitID, procedureID)
+	if err != nil {
+		return errors.Wrap(err, wrapMsgDelete)
 	}
 	if !found {
-		return commonerr.SetNewError(http.StatusNotFound, "procedure_not_found", "procedure row was not found for this visit")
+		err = commonerr.SetNewError(http.StatusNotFound, "procedure_not_found", "procedure row was not found for this visit")
+		return err
+	}
+
+	// a voided treatment procedure returns its materials to stock
+	if err = u.InstitutionUC.ConsumeTreatmentMaterials(txCtx, visitID); err != nil {
+		return errors.Wrap(err, wrapMsgDelete)
 	}
 	return nil
 }
@@ -398,6 +422,63 @@ func (u *ProcedureUC) resolveSnapshotNames(
 	return snap, nil
 }
 
+// validateConsents flags new procedures, and saved ones moved to another
+// category, whose category requires a consent that the visit does not have.
+// Procedures saved before their template became required are left alone.
+func (u *ProcedureUC) validateConsents(
+	ctx context.Context,
+	institutionID, visitID int64,
+	payload []model.SaveProcedureRow,
+	existingRows []model.TrxVisitProcedure,
+	errMsg *commonerr.ErrorMessage,
+) error {
+	if u.ConsentDB == nil {
+		return nil
+	}
+
+	required, err := u.ConsentDB.ListRequiredConsentCategories(ctx, institutionID)
+	if err != nil || len(required) == 0 {
+		return err
+	}
+	requiredSet := stringSliceToSet(required)
+
+	existingCategories := make(map[int64]string, len(existingRows))
+	for _, row := range existingRows {
+		existingCategories[row.ID] = row.Category.String
+	}
+
+	var consents []model.TrxConsent
+	for i, item := range payload {
+		if !item.Category.Valid {
+			continue
+		}
+		if _, ok := requiredSet[item.Category.String]; !ok {
+			continue
+		}
+		if category, ok := existingCategories[item.ID.Int64]; item.ID.Valid && ok && category == item.Category.String {
+			continue
+		}
+
+		if consents == nil {
+			consents, err = u.ConsentDB.ListVisitConsents(ctx, institutionID, visitID)
+			if err != nil {
+				return err
+			}
+		}
+		covered := false
+		for _, consent := range consents {
+			if consent.Covers(item.Category.String, item.ID.Int64) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			errMsg.Append(fmt.Sprintf("procedures[%d].category", i), "informed consent for this procedure category has not been signed")
+		}
+	}
+	return nil
+}
+
 // diffProcedureRows compares the payload against existing rows and produces three
 // disjoint slices: rows to insert, rows to update, and IDs to soft-delete.
 func diffProcedureRows(
@@ -438,8 +519,9 @@ func diffProcedureRows(
 	return
 }
 
-// persistAtomically runs soft-delete, bulk-insert, and bulk-update inside a single DB
-// transaction. The named err pointer is used by the deferred Finish to roll back on panic.
+// persistAtomically runs soft-delete, bulk-insert, bulk-update and the material
+// consumption of treatment procedures inside a single DB transaction. The named
+// err pointer is used by the deferred Finish to roll back on panic.
 func (u *ProcedureUC) persistAtomically(
 	ctx context.Context,
 	institutionID, visitID int64,
@@ -466,6 +548,11 @@ func (u *ProcedureUC) persistAtomically(
 		*errPtr = errors.Wrap(err, wrapMsgSave)
 		return *errPtr
 	}
+	// procedures with a treatment product consume (or, once removed, return) its materials
+	if err := u.InstitutionUC.ConsumeTreatmentMaterials(txCtx, visitID); err != nil {
+		*errPtr = errors.Wrap(err, wrapMsgSave)
+		return *errPtr
+	}
 	return nil
 }
 
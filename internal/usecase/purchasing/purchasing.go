@@ -0,0 +1,535 @@
+package purchasing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	purchasingrepo "github.com/faisalhardin/medilink/internal/entity/repo/purchasing"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/pkg/errors"
+)
+
+const (
+	wrapMsgCreateSupplier      = "PurchasingUC.CreateSupplier"
+	wrapMsgUpdateSupplier      = "PurchasingUC.UpdateSupplier"
+	wrapMsgListSuppliers       = "PurchasingUC.ListSuppliers"
+	wrapMsgCreatePurchaseOrder = "PurchasingUC.CreatePurchaseOrder"
+	wrapMsgUpdatePurchaseOrder = "PurchasingUC.UpdatePurchaseOrder"
+	wrapMsgGetPurchaseOrder    = "PurchasingUC.GetPurchaseOrder"
+	wrapMsgListPurchaseOrders  = "PurchasingUC.ListPurchaseOrders"
+	wrapMsgTransition          = "PurchasingUC.transition"
+	wrapMsgReceive             = "PurchasingUC.ReceivePurchaseOrder"
+)
+
+type PurchasingUC struct {
+	PurchasingDB    purchasingrepo.PurchasingDB
+	InstitutionRepo institutionrepo.InstitutionDB
+	Transaction     xormlib.DBTransactionInterface
+}
+
+func NewPurchasingUC(u *PurchasingUC) *PurchasingUC {
+	return u
+}
+
+func (u *PurchasingUC) CreateSupplier(ctx context.Context, req model.CreateSupplierRequest) (model.MstSupplier, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.MstSupplier{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	supplier := model.MstSupplier{
+		IDMstInstitution: userDetail.InstitutionID,
+		Name:             req.Name,
+		ContactPerson:    req.ContactPerson,
+		PhoneNumber:      req.PhoneNumber,
+		Email:            req.Email,
+		Address:          req.Address,
+		Notes:            req.Notes,
+	}
+	if err := u.PurchasingDB.InsertSupplier(ctx, &supplier); err != nil {
+		return model.MstSupplier{}, errors.Wrap(err, wrapMsgCreateSupplier)
+	}
+	return supplier, nil
+}
+
+func (u *PurchasingUC) UpdateSupplier(ctx context.Context, req model.UpdateSupplierRequest) error {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	updated, err := u.PurchasingDB.UpdateSupplier(ctx, userDetail.InstitutionID, req)
+	if err != nil {
+		return errors.Wrap(err, wrapMsgUpdateSupplier)
+	}
+	if !updated {
+		return errSupplierNotFound()
+	}
+	return nil
+}
+
+func (u *PurchasingUC) ListSuppliers(ctx context.Context, params model.ListSupplierParams) ([]model.MstSupplier, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+
+	suppliers, err := u.PurchasingDB.ListSuppliers(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListSuppliers)
+	}
+	return suppliers, nil
+}
+
+func (u *PurchasingUC) CreatePurchaseOrder(ctx context.Context, req model.CreatePurchaseOrderRequest) (resp model.PurchaseOrderResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if err = u.validateSupplier(ctx, userDetail.InstitutionID, req.IDMstSupplier); err != nil {
+		return resp, err
+	}
+
+	items, err := u.buildOrderItems(ctx, userDetail.InstitutionID, req.Items)
+	if err != nil {
+		return resp, err
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	order := model.TrxPurchaseOrder{
+		IDMstInstitution: userDetail.InstitutionID,
+		IDMstSupplier:    req.IDMstSupplier,
+		Status:           model.PurchaseOrderStatusDraft,
+		Notes:            req.Notes,
+		TotalAmount:      purchaseOrderTotal(items),
+		CreatedBy:        userDetail.Email,
+	}
+	if err = u.PurchasingDB.InsertPurchaseOrder(ctx, &order); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreatePurchaseOrder)
+	}
+
+	for i := range items {
+		items[i].IDTrxPurchaseOrder = order.ID
+	}
+	if err = u.PurchasingDB.InsertPurchaseOrderItems(ctx, items); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreatePurchaseOrder)
+	}
+
+	return model.PurchaseOrderResponse{
+		TrxPurchaseOrder: order,
+		Items:            items,
+	}, nil
+}
+
+func (u *PurchasingUC) UpdatePurchaseOrder(ctx context.Context, orderID int64, req model.UpdatePurchaseOrderRequest) (resp model.PurchaseOrderResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	if err = u.validateSupplier(ctx, userDetail.InstitutionID, req.IDMstSupplier); err != nil {
+		return resp, err
+	}
+
+	items, err := u.buildOrderItems(ctx, userDetail.InstitutionID, req.Items)
+	if err != nil {
+		return resp, err
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	order, err := u.PurchasingDB.LockPurchaseOrder(ctx, userDetail.InstitutionID, orderID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgUpdatePurchaseOrder)
+	}
+	if order == nil {
+		err = errPurchaseOrderNotFound()
+		return resp, err
+	}
+	if order.Status != model.PurchaseOrderStatusDraft {
+		err = commonerr.SetNewBadRequest("purchase_order_not_draft", "only draft purchase orders can be edited")
+		return resp, err
+	}
+
+	if err = u.PurchasingDB.SoftDeletePurchaseOrderItems(ctx, order.ID); err != nil {
+		return resp, errors.Wrap(err, wrapMsgUpdatePurchaseOrder)
+	}
+	for i := range items {
+		items[i].IDTrxPurchaseOrder = order.ID
+	}
+	if err = u.PurchasingDB.InsertPurchaseOrderItems(ctx, items); err != nil {
+		return resp, errors.Wrap(err, wrapMsgUpdatePurchaseOrder)
+	}
+
+	order.IDMstSupplier = req.IDMstSupplier
+	order.Notes = req.Notes
+	order.TotalAmount = purchaseOrderTotal(items)
+	if err = u.PurchasingDB.UpdatePurchaseOrder(ctx, order); err != nil {
+		return resp, errors.Wrap(err, wrapMsgUpdatePurchaseOrder)
+	}
+
+	return model.PurchaseOrderResponse{
+		TrxPurchaseOrder: *order,
+		Items:            items,
+	}, nil
+}
+
+func (u *PurchasingUC) GetPurchaseOrder(ctx context.Context, orderID int64) (model.PurchaseOrderResponse, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.PurchaseOrderResponse{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	order, err := u.PurchasingDB.GetPurchaseOrderByID(ctx, userDetail.InstitutionID, orderID)
+	if err != nil {
+		return model.PurchaseOrderResponse{}, errors.Wrap(err, wrapMsgGetPurchaseOrder)
+	}
+	if order == nil {
+		return model.PurchaseOrderResponse{}, errPurchaseOrderNotFound()
+	}
+
+	items, err := u.PurchasingDB.GetPurchaseOrderItems(ctx, order.ID)
+	if err != nil {
+		return model.PurchaseOrderResponse{}, errors.Wrap(err, wrapMsgGetPurchaseOrder)
+	}
+
+	return model.PurchaseOrderResponse{
+		TrxPurchaseOrder: order.TrxPurchaseOrder,
+		SupplierName:     order.SupplierName,
+		Items:            items,
+	}, nil
+}
+
+func (u *PurchasingUC) ListPurchaseOrders(ctx context.Context, params model.ListPurchaseOrderParams) ([]model.TrxPurchaseOrderJoinSupplier, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+
+	orders, err := u.PurchasingDB.ListPurchaseOrders(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListPurchaseOrders)
+	}
+	return orders, nil
+}
+
+func (u *PurchasingUC) OrderPurchaseOrder(ctx context.Context, orderID int64) (model.PurchaseOrderResponse, error) {
+	return u.transition(ctx, orderID, model.PurchaseOrderStatusOrdered)
+}
+
+func (u *PurchasingUC) CancelPurchaseOrder(ctx context.Context, orderID int64) (model.PurchaseOrderResponse, error) {
+	return u.transition(ctx, orderID, model.PurchaseOrderStatusCancelled)
+}
+
+func (u *PurchasingUC) ReceivePurchaseOrder(ctx context.Context, orderID int64, req model.ReceivePurchaseOrderRequest) (resp model.PurchaseOrderResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	order, err := u.PurchasingDB.LockPurchaseOrder(ctx, userDetail.InstitutionID, orderID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgReceive)
+	}
+	if order == nil {
+		err = errPurchaseOrderNotFound()
+		return resp, err
+	}
+	if order.Status != model.PurchaseOrderStatusOrdered && order.Status != model.PurchaseOrderStatusPartiallyReceived {
+		err = commonerr.SetNewBadRequest("purchase_order_not_receivable", fmt.Sprintf("purchase order in status %s cannot receive goods", order.Status))
+		return resp, err
+	}
+	if err = u.validateSupplier(ctx, userDetail.InstitutionID, order.IDMstSupplier); err != nil {
+		return resp, err
+	}
+	location, err := u.receivingLocation(ctx, userDetail.InstitutionID, req.IDMstStockLocation)
+	if err != nil {
+		return resp, err
+	}
+
+	items, err := u.PurchasingDB.GetPurchaseOrderItems(ctx, order.ID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgReceive)
+	}
+	itemByID := make(map[int64]*model.DtlPurchaseOrderItem, len(items))
+	for i := range items {
+		itemByID[items[i].ID] = &items[i]
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	for i, line := range req.Items {
+		item, ok := itemByID[line.IDDtlPurchaseOrderItem]
+		if !ok {
+			errMsg.Append(fmt.Sprintf("items[%d].item_id", i), "item does not belong to this purchase order")
+			continue
+		}
+		if line.Quantity > item.OutstandingQuantity() {
+			errMsg.Append(fmt.Sprintf("items[%d].quantity", i), fmt.Sprintf("only %d outstanding for %s", item.OutstandingQuantity(), item.Name))
+			continue
+		}
+		// Count the line against the outstanding quantity so the same item
+		// repeated in one request cannot over-receive.
+		item.ReceivedQuantity += line.Quantity
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return resp, err
+	}
+
+	movements := make([]model.TrxStockMovement, 0, len(req.Items))
+	for _, line := range req.Items {
+		item := itemByID[line.IDDtlPurchaseOrderItem]
+		unitCost := item.UnitCost
+		if line.UnitCost != nil {
+			unitCost = line.UnitCost.Round()
+		}
+
+		// lines are ordered in the purchase unit; stock and average cost are kept per base unit
+		baseQuantity, baseUnitCost := toBaseUnit(line.Quantity, unitCost, item.ConversionFactor)
+
+		if err = u.PurchasingDB.AddReceivedQuantity(ctx, item.ID, line.Quantity); err != nil {
+			return resp, errors.Wrap(err, wrapMsgReceive)
+		}
+		if err = u.InstitutionRepo.ReceiveDtlInstitutionProductStock(ctx, item.IDTrxInstitutionProduct, baseQuantity, baseUnitCost); err != nil {
+			return resp, errors.Wrap(err, wrapMsgReceive)
+		}
+
+		movements = append(movements, model.TrxStockMovement{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: item.IDTrxInstitutionProduct,
+			IDMstStockLocation:      location.ID,
+			MovementType:            model.StockMovementTypePurchaseReceipt,
+			Quantity:                baseQuantity,
+			UnitCost:                baseUnitCost,
+			ReferenceType:           model.StockMovementReferencePurchaseOrder,
+			ReferenceID:             order.ID,
+			Notes:                   req.Notes,
+			CreatedBy:               userDetail.Email,
+		})
+	}
+	if err = u.InstitutionRepo.InsertStockMovements(ctx, movements); err != nil {
+		return resp, errors.Wrap(err, wrapMsgReceive)
+	}
+
+	order.Status = purchaseOrderStatusAfterReceipt(items)
+	if order.Status == model.PurchaseOrderStatusReceived {
+		now := time.Now()
+		order.ReceivedAt = &now
+	}
+	if err = u.PurchasingDB.UpdatePurchaseOrder(ctx, order); err != nil {
+		return resp, errors.Wrap(err, wrapMsgReceive)
+	}
+
+	return model.PurchaseOrderResponse{
+		TrxPurchaseOrder: *order,
+		Items:            items,
+	}, nil
+}
+
+// transition moves an order to the target status when the state machine allows it.
+func (u *PurchasingUC) transition(ctx context.Context, orderID int64, to string) (resp model.PurchaseOrderResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	ctx = xormlib.SetDBSession(ctx, session)
+
+	order, err := u.PurchasingDB.LockPurchaseOrder(ctx, userDetail.InstitutionID, orderID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgTransition)
+	}
+	if order == nil {
+		err = errPurchaseOrderNotFound()
+		return resp, err
+	}
+	if !canTransitionPurchaseOrder(order.Status, to) {
+		err = commonerr.SetNewBadRequest("invalid_status_transition", fmt.Sprintf("cannot move purchase order from %s to %s", order.Status, to))
+		return resp, err
+	}
+
+	order.Status = to
+	if to == model.PurchaseOrderStatusOrdered {
+		now := time.Now()
+		order.OrderedAt = &now
+	}
+	if err = u.PurchasingDB.UpdatePurchaseOrder(ctx, order); err != nil {
+		return resp, errors.Wrap(err, wrapMsgTransition)
+	}
+
+	items, err := u.PurchasingDB.GetPurchaseOrderItems(ctx, order.ID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgTransition)
+	}
+
+	return model.PurchaseOrderResponse{
+		TrxPurchaseOrder: *order,
+		Items:            items,
+	}, nil
+}
+
+func (u *PurchasingUC) validateSupplier(ctx context.Context, institutionID, supplierID int64) error {
+	supplier, err := u.PurchasingDB.GetSupplierByID(ctx, institutionID, supplierID)
+	if err != nil {
+		return errors.Wrap(err, wrapMsgCreatePurchaseOrder)
+	}
+	if supplier == nil {
+		return errSupplierNotFound()
+	}
+	return nil
+}
+
+// receivingLocation returns the location goods are received at: the requested
+// one, or the main location when none is given.
+func (u *PurchasingUC) receivingLocation(ctx context.Context, institutionID, locationID int64) (model.MstStockLocation, error) {
+	if locationID == 0 {
+		location, err := u.InstitutionRepo.EnsureMainStockLocation(ctx, institutionID)
+		if err != nil {
+			return location, errors.Wrap(err, wrapMsgReceive)
+		}
+		return location, nil
+	}
+
+	locations, err := u.InstitutionRepo.FindStockLocations(ctx, institutionID)
+	if err != nil {
+		return model.MstStockLocation{}, errors.Wrap(err, wrapMsgReceive)
+	}
+	for _, location := range locations {
+		if location.ID == locationID {
+			return location, nil
+		}
+	}
+	return model.MstStockLocation{}, commonerr.SetNewBadRequest("location_not_found", "stock location was not found in this institution")
+}
+
+// buildOrderItems checks that every product belongs to the institution and is a
+// stocked item ordered in a configured unit, snapshotting the product name and
+// unit conversion onto the line.
+func (u *PurchasingUC) buildOrderItems(ctx context.Context, institutionID int64, lines []model.PurchaseOrderItemRequest) ([]model.DtlPurchaseOrderItem, error) {
+	productIDs := make([]int64, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.IDTrxInstitutionProduct)
+	}
+
+	products, err := u.InstitutionRepo.FindTrxInstitutionProductJoinStockByParams(ctx, model.FindTrxInstitutionProductParams{
+		IDs:                  productIDs,
+		IDMstInstitution:     institutionID,
+		CommonRequestPayload: model.CommonRequestPayload{Limit: len(productIDs)},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgCreatePurchaseOrder)
+	}
+	units, err := u.InstitutionRepo.FindProductUnitsByProductIDs(ctx, productIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgCreatePurchaseOrder)
+	}
+	conversions := model.NewProductUnitConversions(products, units)
+	productByID := make(map[int64]model.GetInstitutionProductResponse, len(products))
+	for _, product := range products {
+		productByID[product.ID] = product
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	items := make([]model.DtlPurchaseOrderItem, 0, len(lines))
+	for i, line := range lines {
+		product, ok := productByID[line.IDTrxInstitutionProduct]
+		if !ok {
+			errMsg.Append(fmt.Sprintf("items[%d].product_id", i), "product was not found in this institution")
+			continue
+		}
+		if !product.IsItem {
+			errMsg.Append(fmt.Sprintf("items[%d].product_id", i), fmt.Sprintf("%s is not a stocked item", product.Name))
+			continue
+		}
+		conversion := conversions[product.ID]
+		factor, ok := conversion.Factor(line.UnitType)
+		if !ok {
+			errMsg.Append(fmt.Sprintf("items[%d].unit_type", i), fmt.Sprintf("unit %s is not configured for %s", line.UnitType, product.Name))
+			continue
+		}
+		items = append(items, model.DtlPurchaseOrderItem{
+			IDTrxInstitutionProduct: product.ID,
+			Name:                    product.Name,
+			UnitType:                conversion.UnitType(line.UnitType),
+			ConversionFactor:        factor,
+			OrderedQuantity:         line.Quantity,
+			UnitCost:                line.UnitCost.Round(),
+		})
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		return nil, errMsg
+	}
+	return items, nil
+}
+
+// canTransitionPurchaseOrder reports whether a manual status change is allowed.
+// Receipt statuses are only reached through ReceivePurchaseOrder.
+func canTransitionPurchaseOrder(from, to string) bool {
+	switch to {
+	case model.PurchaseOrderStatusOrdered:
+		return from == model.PurchaseOrderStatusDraft
+	case model.PurchaseOrderStatusCancelled:
+		return from == model.PurchaseOrderStatusDraft || from == model.PurchaseOrderStatusOrdered
+	}
+	return false
+}
+
+// purchaseOrderStatusAfterReceipt derives the order status from its lines'
+// received quantities.
+func purchaseOrderStatusAfterReceipt(items []model.DtlPurchaseOrderItem) string {
+	for _, item := range items {
+		if item.OutstandingQuantity() > 0 {
+			return model.PurchaseOrderStatusPartiallyReceived
+		}
+	}
+	return model.PurchaseOrderStatusReceived
+}
+
+// toBaseUnit converts a received quantity and its cost per purchase unit into
+// base units and cost per base unit. The base cost is not rounded so that the
+// weighted average cost stays exact.
+func toBaseUnit(quantity int64, unitCost money.Money, conversionFactor int64) (int64, money.Money) {
+	if conversionFactor <= 0 {
+		conversionFactor = 1
+	}
+	return quantity * conversionFactor, unitCost.Div(conversionFactor)
+}
+
+func purchaseOrderTotal(items []model.DtlPurchaseOrderItem) money.Money {
+	total := money.Zero
+	for _, item := range items {
+		total = total.Add(item.UnitCost.MulInt(item.OrderedQuantity).Round())
+	}
+	return total
+}
+
+func errSupplierNotFound() error {
+	return commonerr.SetNewError(http.StatusNotFound, "supplier_not_found", "supplier was not found in this institution")
+}
+
+func errPurchaseOrderNotFound() error {
+	return commonerr.SetNewError(http.StatusNotFound, "purchase_order_not_found", "purchase order was not found in this institution")
+}
@@ -0,0 +1,65 @@
+package purchasing
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestCanTransitionPurchaseOrder(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{model.PurchaseOrderStatusDraft, model.PurchaseOrderStatusOrdered, true},
+		{model.PurchaseOrderStatusDraft, model.PurchaseOrderStatusCancelled, true},
+		{model.PurchaseOrderStatusOrdered, model.PurchaseOrderStatusCancelled, true},
+		{model.PurchaseOrderStatusOrdered, model.PurchaseOrderStatusOrdered, false},
+		{model.PurchaseOrderStatusPartiallyReceived, model.PurchaseOrderStatusCancelled, false},
+		{model.PurchaseOrderStatusReceived, model.PurchaseOrderStatusOrdered, false},
+		{model.PurchaseOrderStatusDraft, model.PurchaseOrderStatusReceived, false},
+	}
+	for _, c := range cases {
+		if got := canTransitionPurchaseOrder(c.from, c.to); got != c.want {
+			t.Fatalf("%s -> %s: got %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestPurchaseOrderStatusAfterReceipt(t *testing.T) {
+	t.Parallel()
+
+	partial := []model.DtlPurchaseOrderItem{
+		{OrderedQuantity: 10, ReceivedQuantity: 10},
+		{OrderedQuantity: 5, ReceivedQuantity: 2},
+	}
+	if got := purchaseOrderStatusAfterReceipt(partial); got != model.PurchaseOrderStatusPartiallyReceived {
+		t.Fatalf("expected partially_received, got %s", got)
+	}
+
+	complete := []model.DtlPurchaseOrderItem{
+		{OrderedQuantity: 10, ReceivedQuantity: 10},
+		{OrderedQuantity: 5, ReceivedQuantity: 5},
+	}
+	if got := purchaseOrderStatusAfterReceipt(complete); got != model.PurchaseOrderStatusReceived {
+		t.Fatalf("expected received, got %s", got)
+	}
+}
+
+func TestToBaseUnit(t *testing.T) {
+	t.Parallel()
+
+	// 3 boxes of 100 tablets at 50,000 per box
+	quantity, unitCost := toBaseUnit(3, money.New(50000), 100)
+	if quantity != 300 || !unitCost.Equal(money.New(500)) {
+		t.Fatalf("expected 300 @ 500, got %d @ %v", quantity, unitCost)
+	}
+
+	quantity, unitCost = toBaseUnit(4, money.New(1200), 0)
+	if quantity != 4 || !unitCost.Equal(money.New(1200)) {
+		t.Fatalf("expected missing factor to mean base unit, got %d @ %v", quantity, unitCost)
+	}
+}
@@ -18,6 +18,7 @@ const (
 	wrapMsgUpdateRecall           = "RecallUC.UpdateRecall"
 	wrapMsgGetNextRecallByPatient = "RecallUC.GetNextRecallByPatient"
 	wrapMsgListRecalls            = "RecallUC.ListRecalls"
+	wrapMsgRecallContacts         = "RecallUC.recallContacts"
 	defaultListLimit              = 50
 )
 
@@ -61,6 +62,12 @@ func (u *RecallUC) CreateRecall(ctx context.Context, req model.CreateRecallReque
 		return model.RecallResponse{}, errors.Wrap(err, wrapMsgCreateRecall)
 	}
 
+	contacts, err := u.recallContacts(ctx, userDetail.InstitutionID, []model.MstPatientInstitution{patient})
+	if err != nil {
+		return model.RecallResponse{}, errors.Wrap(err, wrapMsgCreateRecall)
+	}
+	contact := contacts[patient.ID]
+
 	return model.RecallResponse{
 		ID:                rec.ID,
 		PatientUUID:       patient.UUID,
@@ -70,6 +77,7 @@ func (u *RecallUC) CreateRecall(ctx context.Context, req model.CreateRecallReque
 		Notes:             rec.Notes,
 		IDTrxPatientVisit: rec.IDTrxPatientVisit,
 		CreateTime:        rec.CreateTime,
+		Contact:           &contact,
 	}, nil
 }
 
@@ -119,6 +127,12 @@ func (u *RecallUC) GetNextRecallByPatient(ctx context.Context, patientUUID strin
 		return model.NextRecallResponse{RecallResponse: model.RecallResponse{}, HasNext: false}, nil
 	}
 
+	contacts, err := u.recallContacts(ctx, userDetail.InstitutionID, []model.MstPatientInstitution{recallPatient(rec)})
+	if err != nil {
+		return model.NextRecallResponse{}, errors.Wrap(err, wrapMsgGetNextRecallByPatient)
+	}
+	contact := contacts[rec.TrxRecall.IDMstPatient]
+
 	return model.NextRecallResponse{
 		RecallResponse: model.RecallResponse{
 			ID:                rec.TrxRecall.ID,
@@ -129,6 +143,7 @@ func (u *RecallUC) GetNextRecallByPatient(ctx context.Context, patientUUID strin
 			Notes:             rec.TrxRecall.Notes,
 			IDTrxPatientVisit: rec.TrxRecall.IDTrxPatientVisit,
 			CreateTime:        rec.TrxRecall.CreateTime,
+			Contact:           &contact,
 		},
 		HasNext: false,
 	}, nil
@@ -154,8 +169,18 @@ func (u *RecallUC) ListRecalls(ctx context.Context, params model.GetRecallParams
 		return nil, errors.Wrap(err, wrapMsgListRecalls)
 	}
 
+	patients := make([]model.MstPatientInstitution, 0, len(list))
+	for _, r := range list {
+		patients = append(patients, recallPatient(r))
+	}
+	contacts, err := u.recallContacts(ctx, userDetail.InstitutionID, patients)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListRecalls)
+	}
+
 	result := make([]model.RecallResponse, 0, len(list))
 	for _, r := range list {
+		contact := contacts[r.TrxRecall.IDMstPatient]
 		result = append(result, model.RecallResponse{
 			ID:                r.TrxRecall.ID,
 			PatientUUID:       r.MstPatientInstitution.UUID,
@@ -165,7 +190,39 @@ func (u *RecallUC) ListRecalls(ctx context.Context, params model.GetRecallParams
 			Notes:             r.Notes,
 			IDTrxPatientVisit: r.IDTrxPatientVisit,
 			CreateTime:        r.TrxRecall.CreateTime,
+			Contact:           &contact,
 		})
 	}
 	return result, nil
 }
+
+// recallContacts finds who to reach about the recalls of each patient, keyed
+// by patient ID.
+func (u *RecallUC) recallContacts(ctx context.Context, institutionID int64, patients []model.MstPatientInstitution) (map[int64]model.RecallContact, error) {
+	patientIDs := make([]int64, 0, len(patients))
+	for _, patient := range patients {
+		patientIDs = append(patientIDs, patient.ID)
+	}
+	relations, err := u.PatientDB.ListPatientRelations(ctx, institutionID, patientIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgRecallContacts)
+	}
+
+	byPatient := make(map[int64][]model.PatientRelationResponse, len(patients))
+	for _, relation := range relations {
+		byPatient[relation.IDMstPatient] = append(byPatient[relation.IDMstPatient], relation)
+	}
+	contacts := make(map[int64]model.RecallContact, len(patients))
+	for _, patient := range patients {
+		contacts[patient.ID] = model.RecallContactOf(patient, byPatient[patient.ID])
+	}
+	return contacts, nil
+}
+
+// recallPatient is the patient of a recall as far as the recall queries
+// select it.
+func recallPatient(rec model.TrxRecallJoinPatient) model.MstPatientInstitution {
+	patient := rec.MstPatientInstitution
+	patient.ID = rec.TrxRecall.IDMstPatient
+	return patient
+}
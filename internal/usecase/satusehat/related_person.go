@@ -0,0 +1,76 @@
+package satusehat
+
+import (
+	"fmt"
+
+	"github.com/faisalhardin/medilink/internal/entity/constant"
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	ss "github.com/faisalhardin/medilink/internal/entity/model/satusehat"
+)
+
+// relatedPersonRelationships codes the relationship of a patient relation.
+var relatedPersonRelationships = map[string]ss.Coding{
+	model.PatientRelationshipParent: {
+		System:  "http://terminology.hl7.org/CodeSystem/v3-RoleCode",
+		Code:    "PRN",
+		Display: "parent",
+	},
+	model.PatientRelationshipSpouse: {
+		System:  "http://terminology.hl7.org/CodeSystem/v3-RoleCode",
+		Code:    "SPS",
+		Display: "spouse",
+	},
+	model.PatientRelationshipGuardian: {
+		System:  "http://terminology.hl7.org/CodeSystem/v3-RoleCode",
+		Code:    "GUARD",
+		Display: "guardian",
+	},
+	model.PatientRelationshipEmergencyContact: {
+		System:  "http://terminology.hl7.org/CodeSystem/v2-0131",
+		Code:    "C",
+		Display: "Emergency Contact",
+	},
+}
+
+// NewRelatedPerson maps a patient relation to a RelatedPerson of the Satu
+// Sehat patient patientID. Nothing submits relations yet; the mapping is
+// ready for when patients are synced to Satu Sehat.
+func NewRelatedPerson(patientID string, relation model.PatientRelationResponse) *ss.RelatedPerson {
+	person := &ss.RelatedPerson{
+		DomainResource: ss.DomainResource{
+			Resource: ss.Resource{
+				ResourceType: "RelatedPerson",
+			},
+		},
+		Active: boolPtr(true),
+		Patient: &ss.Reference{
+			Reference: fmt.Sprintf("Patient/%s", patientID),
+		},
+		Gender: relation.Sex,
+	}
+	if coding, ok := relatedPersonRelationships[relation.Relationship]; ok {
+		person.Relationship = []ss.CodeableConcept{{Coding: []ss.Coding{coding}}}
+	}
+	if relation.NIK != "" {
+		person.Identifier = []ss.Identifier{
+			{
+				Use:    "official",
+				System: "https://fhir.kemkes.go.id/id/nik",
+				Value:  relation.NIK,
+			},
+		}
+	}
+	if relation.Name != "" {
+		person.Name = []ss.HumanName{{Use: "official", Text: relation.Name}}
+	}
+	if relation.PhoneNumber != "" {
+		person.Telecom = []ss.ContactPoint{{System: "phone", Value: relation.PhoneNumber, Use: "mobile"}}
+	}
+	if relation.DateOfBirth.Valid {
+		person.BirthDate = relation.DateOfBirth.Time.Format(constant.DateFormatYYYYMMDDDashed)
+	}
+	if relation.Address != "" {
+		person.Address = []ss.Address{{Use: "home", Text: relation.Address}}
+	}
+	return person
+}
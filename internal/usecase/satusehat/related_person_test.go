@@ -0,0 +1,41 @@
+package satusehat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	"github.com/volatiletech/null/v8"
+)
+
+func TestNewRelatedPerson(t *testing.T) {
+	t.Parallel()
+
+	person := NewRelatedPerson("P02478375538", model.PatientRelationResponse{
+		Relationship: model.PatientRelationshipGuardian,
+		Name:         "Siti Aminah",
+		NIK:          "3171234567890001",
+		Sex:          "female",
+		DateOfBirth:  null.TimeFrom(time.Date(1980, 5, 17, 0, 0, 0, 0, time.UTC)),
+		PhoneNumber:  "081200000002",
+	})
+
+	if person.ResourceType != "RelatedPerson" || person.Patient.Reference != "Patient/P02478375538" {
+		t.Fatalf("NewRelatedPerson() = %+v, want a RelatedPerson of Patient/P02478375538", person)
+	}
+	if len(person.Relationship) != 1 || person.Relationship[0].Coding[0].Code != "GUARD" {
+		t.Fatalf("Relationship = %+v, want GUARD", person.Relationship)
+	}
+	if len(person.Identifier) != 1 || person.Identifier[0].Value != "3171234567890001" {
+		t.Fatalf("Identifier = %+v, want the NIK", person.Identifier)
+	}
+	if person.BirthDate != "1980-05-17" || person.Gender != "female" {
+		t.Fatalf("BirthDate, Gender = %q, %q, want 1980-05-17, female", person.BirthDate, person.Gender)
+	}
+	if len(person.Telecom) != 1 || person.Telecom[0].Value != "081200000002" {
+		t.Fatalf("Telecom = %+v, want the phone number", person.Telecom)
+	}
+	if person.Address != nil {
+		t.Fatalf("Address = %+v, want none", person.Address)
+	}
+}
@@ -0,0 +1,405 @@
+package stocktake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	stocktakerepo "github.com/faisalhardin/medilink/internal/entity/repo/stocktake"
+	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
+	xormlib "github.com/faisalhardin/medilink/internal/library/db/xorm"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/pkg/errors"
+)
+
+const (
+	wrapMsgCreateStockTake   = "StockTakeUC.CreateStockTake"
+	wrapMsgListStockTakes    = "StockTakeUC.ListStockTakes"
+	wrapMsgGetStockTake      = "StockTakeUC.GetStockTake"
+	wrapMsgSaveCounts        = "StockTakeUC.SaveCounts"
+	wrapMsgFinalizeStockTake = "StockTakeUC.FinalizeStockTake"
+	wrapMsgCancelStockTake   = "StockTakeUC.CancelStockTake"
+)
+
+type StockTakeUC struct {
+	StockTakeDB     stocktakerepo.StockTakeDB
+	InstitutionRepo institutionrepo.InstitutionDB
+	Transaction     xormlib.DBTransactionInterface
+}
+
+func NewStockTakeUC(u *StockTakeUC) *StockTakeUC {
+	return u
+}
+
+func (u *StockTakeUC) CreateStockTake(ctx context.Context, req model.CreateStockTakeRequest) (resp model.StockTakeResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	location, err := u.stockTakeLocation(txCtx, userDetail.InstitutionID, req.IDMstStockLocation)
+	if err != nil {
+		return resp, err
+	}
+
+	openSessions, err := u.StockTakeDB.ListStockTakes(txCtx, model.ListStockTakeParams{
+		Status:               model.StockTakeStatusOpen,
+		IDMstStockLocation:   location.ID,
+		IDMstInstitution:     userDetail.InstitutionID,
+		CommonRequestPayload: model.CommonRequestPayload{Limit: 1},
+	})
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreateStockTake)
+	}
+	if len(openSessions) > 0 {
+		err = commonerr.SetNewBadRequest("stock_take_already_open", fmt.Sprintf("stock-take #%d of %s is still open; finalize or cancel it first", openSessions[0].ID, location.Name))
+		return resp, err
+	}
+
+	stockTake := model.TrxStockTake{
+		IDMstInstitution:   userDetail.InstitutionID,
+		IDMstStockLocation: location.ID,
+		Status:             model.StockTakeStatusOpen,
+		Notes:              req.Notes,
+		CreatedBy:          userDetail.Email,
+	}
+	if err = u.StockTakeDB.InsertStockTake(txCtx, &stockTake); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreateStockTake)
+	}
+	if _, err = u.StockTakeDB.SnapshotStockTakeItems(txCtx, userDetail.InstitutionID, location.ID, stockTake.ID); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreateStockTake)
+	}
+
+	resp, err = u.buildResponse(txCtx, stockTake)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgCreateStockTake)
+	}
+	return resp, nil
+}
+
+func (u *StockTakeUC) ListStockTakes(ctx context.Context, params model.ListStockTakeParams) ([]model.TrxStockTake, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return nil, commonerr.SetNewUnauthorizedAPICall()
+	}
+	params.IDMstInstitution = userDetail.InstitutionID
+
+	stockTakes, err := u.StockTakeDB.ListStockTakes(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, wrapMsgListStockTakes)
+	}
+	return stockTakes, nil
+}
+
+func (u *StockTakeUC) GetStockTake(ctx context.Context, stockTakeID int64) (model.StockTakeResponse, error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return model.StockTakeResponse{}, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	stockTake, err := u.StockTakeDB.GetStockTakeByID(ctx, userDetail.InstitutionID, stockTakeID)
+	if err != nil {
+		return model.StockTakeResponse{}, errors.Wrap(err, wrapMsgGetStockTake)
+	}
+	if stockTake == nil {
+		return model.StockTakeResponse{}, errStockTakeNotFound()
+	}
+
+	resp, err := u.buildResponse(ctx, *stockTake)
+	if err != nil {
+		return model.StockTakeResponse{}, errors.Wrap(err, wrapMsgGetStockTake)
+	}
+	return resp, nil
+}
+
+func (u *StockTakeUC) SaveCounts(ctx context.Context, stockTakeID int64, req model.SaveStockTakeCountRequest) (resp model.StockTakeResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	stockTake, err := u.lockOpenStockTake(txCtx, userDetail.InstitutionID, stockTakeID)
+	if err != nil {
+		return resp, err
+	}
+
+	rows, err := u.StockTakeDB.GetStockTakeItems(txCtx, stockTake.ID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgSaveCounts)
+	}
+	itemIDs := make(map[int64]struct{}, len(rows))
+	for _, row := range rows {
+		itemIDs[row.ID] = struct{}{}
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	counts := make([]model.DtlStockTakeCount, 0, len(req.Items))
+	for i, item := range req.Items {
+		if _, ok := itemIDs[item.IDDtlStockTakeItem]; !ok {
+			errMsg.Append(fmt.Sprintf("items[%d].item_id", i), "item does not belong to this stock-take")
+			continue
+		}
+		counts = append(counts, model.DtlStockTakeCount{
+			IDDtlStockTakeItem: item.IDDtlStockTakeItem,
+			CountedBy:          userDetail.Email,
+			CountedQuantity:    item.CountedQuantity,
+		})
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return resp, err
+	}
+
+	if err = u.StockTakeDB.UpsertStockTakeCounts(txCtx, counts); err != nil {
+		return resp, errors.Wrap(err, wrapMsgSaveCounts)
+	}
+
+	resp, err = u.buildResponse(txCtx, *stockTake)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgSaveCounts)
+	}
+	return resp, nil
+}
+
+func (u *StockTakeUC) FinalizeStockTake(ctx context.Context, stockTakeID int64, req model.FinalizeStockTakeRequest) (resp model.StockTakeResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	stockTake, err := u.lockOpenStockTake(txCtx, userDetail.InstitutionID, stockTakeID)
+	if err != nil {
+		return resp, err
+	}
+
+	rows, err := u.StockTakeDB.GetStockTakeItems(txCtx, stockTake.ID)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgFinalizeStockTake)
+	}
+	rowByID := make(map[int64]model.StockTakeItemRow, len(rows))
+	for _, row := range rows {
+		rowByID[row.ID] = row
+	}
+
+	errMsg := commonerr.NewErrorMessage()
+	itemReasons := make(map[int64]string, len(req.ItemReasons))
+	for i, itemReason := range req.ItemReasons {
+		if _, ok := rowByID[itemReason.IDDtlStockTakeItem]; !ok {
+			errMsg.Append(fmt.Sprintf("item_reasons[%d].item_id", i), "item does not belong to this stock-take")
+			continue
+		}
+		itemReasons[itemReason.IDDtlStockTakeItem] = itemReason.Reason
+	}
+	if len(errMsg.ErrorList) > 0 {
+		errMsg.SetUnprocessableEntity()
+		err = errMsg
+		return resp, err
+	}
+
+	movements := []model.TrxStockMovement{}
+	for _, row := range rows {
+		variance := stockTakeVariance(row.SystemQuantity, row.CountedQuantity)
+		if variance == nil || *variance == 0 {
+			continue
+		}
+
+		reason, ok := itemReasons[row.ID]
+		if !ok {
+			reason = req.Reason
+		}
+
+		// Apply the delta rather than overwriting the quantity so sales made
+		// between the snapshot and finalization are kept. The movement moves
+		// the quantity of the counted location by the same delta.
+		if err = u.InstitutionRepo.AdjustDtlInstitutionProductStock(txCtx, row.IDTrxInstitutionProduct, *variance); err != nil {
+			return resp, errors.Wrap(err, wrapMsgFinalizeStockTake)
+		}
+		if err = u.StockTakeDB.UpdateStockTakeItemReason(txCtx, row.ID, reason); err != nil {
+			return resp, errors.Wrap(err, wrapMsgFinalizeStockTake)
+		}
+
+		movements = append(movements, model.TrxStockMovement{
+			IDMstInstitution:        userDetail.InstitutionID,
+			IDTrxInstitutionProduct: row.IDTrxInstitutionProduct,
+			IDMstStockLocation:      stockTake.IDMstStockLocation,
+			MovementType:            model.StockMovementTypeStockTake,
+			Quantity:                *variance,
+			UnitCost:                row.UnitCost,
+			ReferenceType:           model.StockMovementReferenceStockTake,
+			ReferenceID:             stockTake.ID,
+			Notes:                   reason,
+			CreatedBy:               userDetail.Email,
+		})
+	}
+	if err = u.InstitutionRepo.InsertStockMovements(txCtx, movements); err != nil {
+		return resp, errors.Wrap(err, wrapMsgFinalizeStockTake)
+	}
+
+	now := time.Now()
+	stockTake.Status = model.StockTakeStatusFinalized
+	stockTake.FinalizeReason = req.Reason
+	stockTake.FinalizedBy = userDetail.Email
+	stockTake.FinalizedAt = &now
+	if err = u.StockTakeDB.UpdateStockTake(txCtx, stockTake); err != nil {
+		return resp, errors.Wrap(err, wrapMsgFinalizeStockTake)
+	}
+
+	resp, err = u.buildResponse(txCtx, *stockTake)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgFinalizeStockTake)
+	}
+	return resp, nil
+}
+
+func (u *StockTakeUC) CancelStockTake(ctx context.Context, stockTakeID int64) (resp model.StockTakeResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		return resp, commonerr.SetNewUnauthorizedAPICall()
+	}
+
+	session, err := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	txCtx := xormlib.SetDBSession(ctx, session)
+
+	stockTake, err := u.lockOpenStockTake(txCtx, userDetail.InstitutionID, stockTakeID)
+	if err != nil {
+		return resp, err
+	}
+
+	stockTake.Status = model.StockTakeStatusCancelled
+	if err = u.StockTakeDB.UpdateStockTake(txCtx, stockTake); err != nil {
+		return resp, errors.Wrap(err, wrapMsgCancelStockTake)
+	}
+
+	resp, err = u.buildResponse(txCtx, *stockTake)
+	if err != nil {
+		return resp, errors.Wrap(err, wrapMsgCancelStockTake)
+	}
+	return resp, nil
+}
+
+// stockTakeLocation returns the location a session counts: the requested one,
+// or the main location when none is given.
+func (u *StockTakeUC) stockTakeLocation(ctx context.Context, institutionID, locationID int64) (model.MstStockLocation, error) {
+	if locationID == 0 {
+		location, err := u.InstitutionRepo.EnsureMainStockLocation(ctx, institutionID)
+		if err != nil {
+			return location, errors.Wrap(err, wrapMsgCreateStockTake)
+		}
+		return location, nil
+	}
+
+	locations, err := u.InstitutionRepo.FindStockLocations(ctx, institutionID)
+	if err != nil {
+		return model.MstStockLocation{}, errors.Wrap(err, wrapMsgCreateStockTake)
+	}
+	for _, location := range locations {
+		if location.ID == locationID {
+			return location, nil
+		}
+	}
+	return model.MstStockLocation{}, commonerr.SetNewBadRequest("location_not_found", "stock location was not found in this institution")
+}
+
+// lockOpenStockTake locks the session and rejects it unless it is still open.
+func (u *StockTakeUC) lockOpenStockTake(ctx context.Context, institutionID, stockTakeID int64) (*model.TrxStockTake, error) {
+	stockTake, err := u.StockTakeDB.LockStockTake(ctx, institutionID, stockTakeID)
+	if err != nil {
+		return nil, errors.Wrap(err, "StockTakeUC.lockOpenStockTake")
+	}
+	if stockTake == nil {
+		return nil, errStockTakeNotFound()
+	}
+	if stockTake.Status != model.StockTakeStatusOpen {
+		return nil, commonerr.SetNewBadRequest("stock_take_not_open", fmt.Sprintf("stock-take is already %s", stockTake.Status))
+	}
+	return stockTake, nil
+}
+
+func (u *StockTakeUC) buildResponse(ctx context.Context, stockTake model.TrxStockTake) (model.StockTakeResponse, error) {
+	rows, err := u.StockTakeDB.GetStockTakeItems(ctx, stockTake.ID)
+	if err != nil {
+		return model.StockTakeResponse{}, err
+	}
+	counts, err := u.StockTakeDB.GetStockTakeCounts(ctx, stockTake.ID)
+	if err != nil {
+		return model.StockTakeResponse{}, err
+	}
+
+	items, summary := assembleStockTakeItems(rows, counts)
+	return model.StockTakeResponse{
+		TrxStockTake: stockTake,
+		Summary:      summary,
+		Items:        items,
+	}, nil
+}
+
+// assembleStockTakeItems attaches counter entries and variances to each item
+// and totals them. Uncounted items carry a nil variance and are left out of
+// the variance figures.
+func assembleStockTakeItems(rows []model.StockTakeItemRow, counts []model.DtlStockTakeCount) ([]model.StockTakeItemResponse, model.StockTakeSummary) {
+	countsByItem := make(map[int64][]model.DtlStockTakeCount)
+	for _, count := range counts {
+		countsByItem[count.IDDtlStockTakeItem] = append(countsByItem[count.IDDtlStockTakeItem], count)
+	}
+
+	summary := model.StockTakeSummary{TotalItems: len(rows)}
+	items := make([]model.StockTakeItemResponse, 0, len(rows))
+	for _, row := range rows {
+		item := model.StockTakeItemResponse{
+			DtlStockTakeItem: row.DtlStockTakeItem,
+			CountedQuantity:  row.CountedQuantity,
+			Variance:         stockTakeVariance(row.SystemQuantity, row.CountedQuantity),
+			Counts:           countsByItem[row.ID],
+		}
+		if item.Counts == nil {
+			item.Counts = []model.DtlStockTakeCount{}
+		}
+
+		if item.Variance != nil {
+			summary.CountedItems++
+			item.VarianceValue = row.UnitCost.MulInt(*item.Variance).Round()
+			summary.VarianceValue = summary.VarianceValue.Add(item.VarianceValue)
+			switch {
+			case *item.Variance > 0:
+				summary.VarianceItems++
+				summary.SurplusQuantity += *item.Variance
+			case *item.Variance < 0:
+				summary.VarianceItems++
+				summary.ShortageQuantity -= *item.Variance
+			}
+		}
+		items = append(items, item)
+	}
+	return items, summary
+}
+
+// stockTakeVariance returns counted minus system quantity, or nil when the item
+// has not been counted.
+func stockTakeVariance(systemQuantity int64, countedQuantity *int64) *int64 {
+	if countedQuantity == nil {
+		return nil
+	}
+	variance := *countedQuantity - systemQuantity
+	return &variance
+}
+
+func errStockTakeNotFound() error {
+	return commonerr.SetNewError(http.StatusNotFound, "stock_take_not_found", "stock-take was not found in this institution")
+}
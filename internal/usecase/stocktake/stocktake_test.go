@@ -0,0 +1,179 @@
+package stocktake
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-xorm/xorm"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	institutionrepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	stocktakerepo "github.com/faisalhardin/medilink/internal/entity/repo/stocktake"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestAssembleStockTakeItems(t *testing.T) {
+	t.Parallel()
+
+	rows := []model.StockTakeItemRow{
+		{DtlStockTakeItem: model.DtlStockTakeItem{ID: 1, SystemQuantity: 10, UnitCost: money.New(500)}, CountedQuantity: int64Ptr(12), CounterTotal: 2},
+		{DtlStockTakeItem: model.DtlStockTakeItem{ID: 2, SystemQuantity: 8, UnitCost: money.New(1000)}, CountedQuantity: int64Ptr(5), CounterTotal: 1},
+		{DtlStockTakeItem: model.DtlStockTakeItem{ID: 3, SystemQuantity: 4, UnitCost: money.New(200)}, CountedQuantity: int64Ptr(4), CounterTotal: 1},
+		{DtlStockTakeItem: model.DtlStockTakeItem{ID: 4, SystemQuantity: 7, UnitCost: money.New(300)}},
+	}
+	counts := []model.DtlStockTakeCount{
+		{IDDtlStockTakeItem: 1, CountedBy: "a@clinic", CountedQuantity: 7},
+		{IDDtlStockTakeItem: 1, CountedBy: "b@clinic", CountedQuantity: 5},
+		{IDDtlStockTakeItem: 2, CountedBy: "a@clinic", CountedQuantity: 5},
+		{IDDtlStockTakeItem: 3, CountedBy: "b@clinic", CountedQuantity: 4},
+	}
+
+	items, summary := assembleStockTakeItems(rows, counts)
+
+	if len(items) != 4 {
+		t.Fatalf("expected 4 items, got %d", len(items))
+	}
+	if len(items[0].Counts) != 2 {
+		t.Fatalf("expected 2 counter entries on item 1, got %d", len(items[0].Counts))
+	}
+	if items[0].Variance == nil || *items[0].Variance != 2 {
+		t.Fatalf("expected variance 2 on item 1, got %v", items[0].Variance)
+	}
+	if items[3].Variance != nil {
+		t.Fatalf("expected nil variance on uncounted item, got %d", *items[3].Variance)
+	}
+	if items[3].Counts == nil {
+		t.Fatalf("expected empty counts slice on uncounted item")
+	}
+
+	if summary.TotalItems != 4 || summary.CountedItems != 3 || summary.VarianceItems != 2 {
+		t.Fatalf("unexpected item totals: %+v", summary)
+	}
+	if summary.SurplusQuantity != 2 || summary.ShortageQuantity != 3 {
+		t.Fatalf("unexpected quantities: surplus=%d shortage=%d", summary.SurplusQuantity, summary.ShortageQuantity)
+	}
+	// 2*500 - 3*1000
+	if !summary.VarianceValue.Equal(money.New(-2000)) {
+		t.Fatalf("expected variance value -2000, got %v", summary.VarianceValue)
+	}
+}
+
+// stockTakeDB keeps one session and its items in memory. Methods the tests do
+// not reach are left to the embedded interface.
+type stockTakeDB struct {
+	stocktakerepo.StockTakeDB
+
+	stockTake        model.TrxStockTake
+	items            []model.StockTakeItemRow
+	snapshotLocation int64
+}
+
+func (db *stockTakeDB) ListStockTakes(ctx context.Context, params model.ListStockTakeParams) ([]model.TrxStockTake, error) {
+	return nil, nil
+}
+
+func (db *stockTakeDB) InsertStockTake(ctx context.Context, stockTake *model.TrxStockTake) error {
+	stockTake.ID = 1
+	db.stockTake = *stockTake
+	return nil
+}
+
+func (db *stockTakeDB) SnapshotStockTakeItems(ctx context.Context, institutionID, locationID, stockTakeID int64) (int64, error) {
+	db.snapshotLocation = locationID
+	return int64(len(db.items)), nil
+}
+
+func (db *stockTakeDB) LockStockTake(ctx context.Context, institutionID, stockTakeID int64) (*model.TrxStockTake, error) {
+	stockTake := db.stockTake
+	return &stockTake, nil
+}
+
+func (db *stockTakeDB) UpdateStockTake(ctx context.Context, stockTake *model.TrxStockTake) error {
+	db.stockTake = *stockTake
+	return nil
+}
+
+func (db *stockTakeDB) GetStockTakeItems(ctx context.Context, stockTakeID int64) ([]model.StockTakeItemRow, error) {
+	return db.items, nil
+}
+
+func (db *stockTakeDB) GetStockTakeCounts(ctx context.Context, stockTakeID int64) ([]model.DtlStockTakeCount, error) {
+	return nil, nil
+}
+
+func (db *stockTakeDB) UpdateStockTakeItemReason(ctx context.Context, itemID int64, reason string) error {
+	return nil
+}
+
+// stockDB records the institution-wide adjustments and movements posted.
+type stockDB struct {
+	institutionrepo.InstitutionDB
+
+	adjusted  map[int64]int64
+	movements []model.TrxStockMovement
+}
+
+func (db *stockDB) EnsureMainStockLocation(ctx context.Context, institutionID int64) (model.MstStockLocation, error) {
+	return model.MstStockLocation{ID: 1, IDMstInstitution: institutionID, Name: model.MainStockLocationName, IsMain: true}, nil
+}
+
+func (db *stockDB) FindStockLocations(ctx context.Context, institutionID int64) ([]model.MstStockLocation, error) {
+	main, _ := db.EnsureMainStockLocation(ctx, institutionID)
+	return []model.MstStockLocation{main, {ID: 2, IDMstInstitution: institutionID, Name: "Pharmacy"}}, nil
+}
+
+func (db *stockDB) AdjustDtlInstitutionProductStock(ctx context.Context, productID, delta int64) error {
+	db.adjusted[productID] += delta
+	return nil
+}
+
+func (db *stockDB) InsertStockMovements(ctx context.Context, movements []model.TrxStockMovement) error {
+	db.movements = append(db.movements, movements...)
+	return nil
+}
+
+type noTransaction struct{}
+
+func (noTransaction) Begin(ctx context.Context) (*xorm.Session, error) { return nil, nil }
+
+func (noTransaction) Finish(session *xorm.Session, err *error) {}
+
+func TestStockTakeAtLocation(t *testing.T) {
+	t.Parallel()
+
+	ctx := auth.SetUserDetailToCtx(context.Background(), model.UserJWTPayload{InstitutionID: 7, Email: "clerk@clinic"})
+	db := &stockTakeDB{items: []model.StockTakeItemRow{{
+		DtlStockTakeItem: model.DtlStockTakeItem{ID: 11, IDTrxInstitutionProduct: 5, SystemQuantity: 10, UnitCost: money.New(2000)},
+		CountedQuantity:  int64Ptr(7),
+	}}}
+	stock := &stockDB{adjusted: map[int64]int64{}}
+	uc := &StockTakeUC{StockTakeDB: db, InstitutionRepo: stock, Transaction: noTransaction{}}
+
+	if _, err := uc.CreateStockTake(ctx, model.CreateStockTakeRequest{IDMstStockLocation: 3}); err == nil {
+		t.Fatalf("CreateStockTake() at an unknown location = nil error")
+	}
+
+	resp, err := uc.CreateStockTake(ctx, model.CreateStockTakeRequest{IDMstStockLocation: 2})
+	if err != nil {
+		t.Fatalf("CreateStockTake() = %v", err)
+	}
+	if resp.IDMstStockLocation != 2 || db.snapshotLocation != 2 {
+		t.Fatalf("session at location %d snapshotted location %d, want 2", resp.IDMstStockLocation, db.snapshotLocation)
+	}
+
+	if _, err = uc.FinalizeStockTake(ctx, resp.ID, model.FinalizeStockTakeRequest{Reason: "expired"}); err != nil {
+		t.Fatalf("FinalizeStockTake() = %v", err)
+	}
+	if stock.adjusted[5] != -3 {
+		t.Fatalf("product stock adjusted by %d, want -3", stock.adjusted[5])
+	}
+	if len(stock.movements) != 1 {
+		t.Fatalf("posted %d movements, want 1", len(stock.movements))
+	}
+	if movement := stock.movements[0]; movement.IDMstStockLocation != 2 || movement.Quantity != -3 {
+		t.Fatalf("movement of %d at location %d, want -3 at location 2", movement.Quantity, movement.IDMstStockLocation)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/faisalhardin/medilink/internal/entity/constant"
@@ -13,13 +14,17 @@ import (
 	institutionRepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
 	journeyDB "github.com/faisalhardin/medilink/internal/entity/repo/journey"
 	patientRepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
+	pricelistrepo "github.com/faisalhardin/medilink/internal/entity/repo/pricelist"
 	procedurerepo "github.com/faisalhardin/medilink/internal/entity/repo/procedure"
+	discountuc "github.com/faisalhardin/medilink/internal/entity/usecase/discount"
+	institutionuc "github.com/faisalhardin/medilink/internal/entity/usecase/institution"
+	patientuc "github.com/faisalhardin/medilink/internal/entity/usecase/patient"
 	"github.com/faisalhardin/medilink/internal/library/common/commonerr"
 	"github.com/faisalhardin/medilink/internal/library/db/xorm"
 	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/pkg/type/money"
 	customtime "github.com/faisalhardin/medilink/pkg/type/time"
 	"github.com/pkg/errors"
-	"github.com/shopspring/decimal"
 	"github.com/volatiletech/null/v8"
 	"golang.org/x/sync/errgroup"
 )
@@ -34,6 +39,8 @@ const (
 	WrapMsgGetVisitTouchpoint    = WrapErrMsgPrefix + "GetVisitTouchpoint"
 	WrapMsgInsertVisitProduct    = WrapErrMsgPrefix + "InsertVisitProduct"
 	WrapMsgReduceProductStock    = WrapErrMsgPrefix + "ReduceProductStock"
+	WrapMsgGetVisitInvoice       = WrapErrMsgPrefix + "GetVisitInvoice"
+	WrapMsgSelectVisitPayer      = WrapErrMsgPrefix + "SelectVisitPayer"
 )
 
 const (
@@ -48,6 +55,10 @@ type VisitUC struct {
 	AnamnesaDB      anamnesarepo.AnamnesaDB
 	DiagnosisDB     diagnosisrepo.DiagnosisDB
 	ProcedureDB     procedurerepo.ProcedureDB
+	PriceListDB     pricelistrepo.PriceListDB
+	InstitutionUC   institutionuc.InstitutionUC
+	DiscountUC      discountuc.DiscountUC
+	PatientUC       patientuc.PatientUC
 }
 
 func NewVisitUC(u *VisitUC) *VisitUC {
@@ -95,12 +106,28 @@ func (u *VisitUC) InsertNewVisit(ctx context.Context, req model.InsertNewVisitRe
 	patientID := mstPatient[0].ID
 	institutionID := userDetail.InstitutionID
 
+	// the visit keeps the category and payer it was opened with, so later
+	// changes to the patient do not reprice or resplit it
+	patientCategory := mstPatient[0].PatientCategory
+	if req.PatientCategory != "" {
+		patientCategory = req.PatientCategory
+	}
+
+	payer, err := u.newVisitPayer(ctx, mstPatient[0], req)
+	if err != nil {
+		return err
+	}
+
 	newTrxVisit := &model.TrxPatientVisit{
 		IDMstPatient:                patientID,
 		IDMstInstitution:            institutionID,
 		IDMstJourneyPoint:           journeyPoint.ID,
 		IDMstJourneyBoard:           journeyBoard.ID,
 		UpdateTimeMstJourneyPointID: time.Now().Unix(),
+		PatientCategory:             model.NormalisePatientCategory(patientCategory),
+	}
+	if payer.ID > 0 {
+		newTrxVisit.SelectPayer(payer)
 	}
 
 	err = u.PatientDB.RecordPatientVisit(ctx, newTrxVisit)
@@ -128,6 +155,44 @@ func (u *VisitUC) InsertNewVisit(ctx context.Context, req model.InsertNewVisitRe
 	return nil
 }
 
+// newVisitPayer picks the payer a new visit is billed to: the one asked for,
+// which must cover the patient today, or else the patient's default payer
+// when no category was asked for. A default payer that does not cover the
+// patient, or cannot be asked, is passed over and the patient pays. A zero
+// payer bills the patient.
+func (u *VisitUC) newVisitPayer(ctx context.Context, patient model.MstPatientInstitution, req model.InsertNewVisitRequest) (payer model.MstPatientPayer, err error) {
+	payerID := req.PayerID
+	if payerID == 0 {
+		if req.PatientCategory != "" {
+			return
+		}
+		payers, errList := u.PatientDB.ListPatientPayers(ctx, patient.InstitutionID, patient.ID)
+		if errList != nil {
+			return payer, errors.Wrap(errList, WrapMsgInsertNewVisit)
+		}
+		if len(payers) == 0 || !payers[0].IsDefault {
+			return
+		}
+		payerID = payers[0].ID
+	}
+
+	payer, err = u.PatientUC.CheckPayerEligibility(ctx, model.CheckPatientPayerEligibilityRequest{
+		PatientUUID: patient.UUID,
+		ID:          payerID,
+	})
+	if req.PayerID == 0 && (err != nil || payer.EligibilityStatus != model.EligibilityStatusEligible) {
+		return model.MstPatientPayer{}, nil
+	}
+	if err != nil {
+		return
+	}
+	if payer.EligibilityStatus != model.EligibilityStatusEligible {
+		err = commonerr.SetNewUnprocessableEntityError("payer_id", "the payer does not cover the patient: "+payer.EligibilityMessage)
+		return
+	}
+	return
+}
+
 func (u *VisitUC) GetPatientVisitDetail(ctx context.Context, req model.GetPatientVisitParams) (visitDetail model.GetPatientVisitDetailResponse, err error) {
 
 	userDetail, found := auth.GetUserDetailFromCtx(ctx)
@@ -179,6 +244,7 @@ func (u *VisitUC) GetPatientVisitDetail(ctx context.Context, req model.GetPatien
 			return errors.Wrap(err, WrapMsgGetPatientVisits)
 		}
 		visitDetail.Products = products
+		visitDetail.Totals = model.NewVisitTotals(products)
 		return nil
 	})
 
@@ -328,6 +394,7 @@ func (u *VisitUC) ListPatientVisitDetailed(ctx context.Context, req model.GetPat
 	for i, response := range visitsDetails {
 		response.DtlPatientVisit = mapVisitIDtoDtlVisit[response.ID]
 		response.Products = mapVisitIDtoProducts[response.ID]
+		response.Totals = model.NewVisitTotals(response.Products)
 		visitsDetails[i] = response
 	}
 
@@ -637,74 +704,141 @@ func (u *VisitUC) InsertVisitProduct(ctx context.Context, req model.InsertTrxVis
 		return
 	}
 
+	// Resolve the requested unit of each product to its base (stock) unit
+	conversions, err := u.getProductUnitConversions(ctx, productItems)
+	if err != nil {
+		return
+	}
+
+	// Price from the price list of the visit's patient category, when there is one
+	priceListIDs, err := u.applyPriceLists(ctx, userDetail.InstitutionID, dtlPatientVisit[0].DtlPatientVisit.IDTrxPatientVisit, conversions)
+	if err != nil {
+		return
+	}
+
+	// Tax and service charge follow the institution's current configuration
+	taxConfig, err := u.InstitutionRepo.GetInstitutionTaxConfig(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertVisitProduct)
+		return
+	}
+
 	// === TRANSACTION PROCESSING SECTION ===
 	// Begin database transaction to ensure data consistency
 	// All operations will be rolled back if any step fails
 	session, _ := u.Transaction.Begin(ctx)
 	defer u.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	// Items are sold from the stock location of the visit's service point
+	location, onHand, err := u.visitLocationStock(ctx, dtlPatientVisit[0].DtlPatientVisit.IDTrxPatientVisit, requestedTrxInstitutionProducts.IDs)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertVisitProduct)
+		return
+	}
+
+	priceChanges := make([]model.VisitLinePriceChange, 0, len(productItems))
 
 	// Process each product item in the request
 	for _, productItem := range productItems {
 
+		// Extract product details from the request mapping
+		quantity := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].Quantity
+		unitType := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].UnitType
+		discountRate := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].DiscountRate
+		discountPrice := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].DiscountPrice.Round()
+
+		conversion := conversions[productItem.ID]
+		factor, ok := conversion.Factor(unitType)
+		if !ok {
+			err = commonerr.SetNewBadRequest("invalid unit", fmt.Sprintf("unit %s is not configured for %s", unitType, productItem.Name))
+			return
+		}
+		pricePerUnit, _ := conversion.Price(unitType)
+		baseQuantity := int64(quantity) * factor
+
 		// Validate stock availability before processing
-		if productItem.Quantity < int64(mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].Quantity) {
+		if productItem.Quantity < baseQuantity {
 			err = commonerr.SetNewBadRequest("invalid", "purchase quantity exceeds stock")
 			return
 		}
-
-		// Extract product details from the request mapping
-		quantity := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].Quantity
-		discountRate := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].DiscountRate
-		discountPrice := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].DiscountPrice
+		if productItem.IsItem && onHand[productItem.ID] < baseQuantity {
+			err = commonerr.SetNewBadRequest("stock issue", fmt.Sprintf("product %s stock at %s is not enough", productItem.Name, location.Name))
+			return
+		}
 
 		// Calculate total price with discount logic
-		sumPrice := productItem.Price * float64(quantity)
-		if discountPrice > 0 {
-			// Apply fixed discount amount if specified
-			sumPrice = sumPrice - discountPrice
-		} else if discountRate > 0 {
-			// Apply percentage discount if specified
-			sumPrice = sumPrice * (1 - discountRate)
-		}
+		sumPrice := visitProductTotalPrice(pricePerUnit, quantity, discountPrice, discountRate)
 
 		// Validate adjusted price doesn't exceed calculated total
-		adjustedPrice := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].AdjustedPrice
-		if adjustedPrice > sumPrice {
+		adjustedPrice := mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].AdjustedPrice.Round()
+		if adjustedPrice.GreaterThan(sumPrice) {
 			err = commonerr.SetNewBadRequest("invalid price", fmt.Sprintf("adjusted price must not exceed total price %v", sumPrice))
 			return
 		}
 
-		// Insert the visit product record into the database
-		err = u.PatientDB.InsertTrxVisitProduct(ctx, &model.TrxVisitProduct{
+		visitProduct := model.TrxVisitProduct{
 			IDTrxInstitutionProduct: productItem.ID,
 			IDMstInstitution:        userDetail.InstitutionID,
 			IDTrxPatientVisit:       dtlPatientVisit[0].DtlPatientVisit.IDTrxPatientVisit,
 			IDDtlPatientVisit:       req.IDTrxPatientVisit,
 			Quantity:                quantity,
-			UnitType:                productItem.UnitType,
-			Price:                   productItem.Price,
+			UnitType:                conversion.UnitType(unitType),
+			Price:                   pricePerUnit,
 			DiscountRate:            discountRate,
 			DiscountPrice:           discountPrice,
 			TotalPrice:              sumPrice,
 			AdjustedPrice:           adjustedPrice,
-		})
+			UnitCost:                productItem.AvgCost.MulInt(factor),
+			ConversionFactor:        factor,
+			IDMstPriceList:          priceListIDs[productItem.ID],
+			IDMstStockLocation:      null.NewInt64(location.ID, productItem.IsItem),
+		}
+		visitProduct.ApplyTax(productItem.TaxCategory, taxConfig)
+
+		// Insert the visit product record into the database
+		err = u.PatientDB.InsertTrxVisitProduct(ctx, &visitProduct)
 		if err != nil {
 			err = errors.Wrap(err, WrapMsgInsertVisitProduct)
 			return
 		}
 
+		priceChanges = append(priceChanges, model.VisitLinePriceChange{
+			After:              visitProduct,
+			Reason:             mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].PriceChangeReason,
+			DiscountApprovalID: mapRequestedProductIDToTrxInstitutionProducts[productItem.ID].DiscountApprovalID,
+		})
+
 		// Reduce the product stock by the purchased quantity
 		err = u.ReduceProductStock(ctx, ProductStockReducerRequest{
 			ProductID: productItem.ID,
-			Quantity:  int64(quantity),
+			Quantity:  baseQuantity,
 		})
 		if err != nil {
 			err = errors.Wrap(err, WrapMsgInsertVisitProduct)
 			return
 		}
+		err = u.InstitutionRepo.AdjustLocationStock(ctx, location.ID, productItem.ID, -baseQuantity)
+		if err != nil {
+			err = errors.Wrap(err, WrapMsgInsertVisitProduct)
+			return
+		}
 
 	}
 
+	// Discounts beyond the user's limit need an approval; every one is audited
+	err = u.DiscountUC.AuthorizePriceChanges(ctx, priceChanges)
+	if err != nil {
+		return
+	}
+
+	// Treatments on the visit consume their bill of materials
+	err = u.InstitutionUC.ConsumeTreatmentMaterials(ctx, dtlPatientVisit[0].DtlPatientVisit.IDTrxPatientVisit)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgInsertVisitProduct)
+		return
+	}
+
 	return nil
 }
 
@@ -720,6 +854,22 @@ func (u *VisitUC) UpsertVisitProduct(ctx context.Context, req model.UpsertTrxVis
 	if err != nil {
 		return
 	}
+	productItems := make([]model.GetInstitutionProductResponse, 0, len(mappedProductInstitution))
+	for _, productItem := range mappedProductInstitution {
+		productItems = append(productItems, productItem)
+	}
+	conversions, err := u.getProductUnitConversions(ctx, productItems)
+	if err != nil {
+		return
+	}
+	priceListIDs, err := u.applyPriceLists(ctx, userDetail.InstitutionID, req.IDTrxPatientVisit, conversions)
+	if err != nil {
+		return
+	}
+	taxConfig, err := u.InstitutionRepo.GetInstitutionTaxConfig(ctx, userDetail.InstitutionID)
+	if err != nil {
+		return errors.Wrap(err, "usecase.UpsertVisitProduct")
+	}
 	// END:fetch institution product
 
 	// START: fetch existing product
@@ -732,47 +882,102 @@ func (u *VisitUC) UpsertVisitProduct(ctx context.Context, req model.UpsertTrxVis
 	}
 	// END: fetch existing product
 
+	session, _ := u.Transaction.Begin(ctx)
+	defer u.Transaction.Finish(session, &err)
+	ctx = xorm.SetDBSession(ctx, session)
+
+	// new items are sold from the stock location of the visit's service point; ordered
+	// lines return stock to, and draw more from, the location they were sold from
+	productIDs := make([]int64, 0, len(req.Products))
+	for _, requestedProduct := range req.Products {
+		productIDs = append(productIDs, requestedProduct.IDTrxInstitutionProduct)
+	}
+	location, onHand, err := u.visitLocationStock(ctx, req.IDTrxPatientVisit, productIDs)
+	if err != nil {
+		return errors.Wrap(err, "usecase.UpsertVisitProduct")
+	}
+
 	// create mapping product id to requested product id
 	// compare
 
+	priceChanges := make([]model.VisitLinePriceChange, 0, len(req.Products))
 	for _, requestedProduct := range req.Products {
 		productStock := mappedProductInstitution[requestedProduct.IDTrxInstitutionProduct]
 
+		conversion := conversions[requestedProduct.IDTrxInstitutionProduct]
+		factor, ok := conversion.Factor(requestedProduct.UnitType)
+		if !ok {
+			err = commonerr.SetNewBadRequest("invalid unit", fmt.Sprintf("unit %s is not configured for %s", requestedProduct.UnitType, productStock.Name))
+			return
+		}
+
 		orderedProduct, found := mappedProductVisit[requestedProduct.IDTrxInstitutionProduct]
 		// if not exist then buy anew
 		if !found {
-			err = u.orderProduct(ctx, model.TrxVisitProduct{
+			var newProduct model.TrxVisitProduct
+			newProduct, err = u.orderProduct(ctx, model.TrxVisitProduct{
 				IDTrxInstitutionProduct: requestedProduct.IDTrxInstitutionProduct,
 				IDMstInstitution:        userDetail.InstitutionID,
 				IDTrxPatientVisit:       req.IDTrxPatientVisit,
 				Name:                    productStock.Name,
 				IDDtlPatientVisit:       req.IDDtlPatientVisit,
-				UnitType:                productStock.UnitType,
+				UnitType:                conversion.UnitType(requestedProduct.UnitType),
 				Price:                   productStock.Price,
 				DiscountRate:            requestedProduct.DiscountRate,
 				DiscountPrice:           requestedProduct.DiscountPrice,
-				TotalPrice:              float64(requestedProduct.TotalPrice),
+				TotalPrice:              requestedProduct.TotalPrice,
 				AdjustedPrice:           requestedProduct.AdjustedPrice,
+				UnitCost:                productStock.AvgCost.MulInt(factor),
+				ConversionFactor:        factor,
+				IDMstPriceList:          priceListIDs[requestedProduct.IDTrxInstitutionProduct],
 			},
 				productStock,
+				location,
+				onHand[requestedProduct.IDTrxInstitutionProduct],
+				conversion,
+				taxConfig,
 				requestedProduct)
 			if err != nil {
 				return
 			}
+			priceChanges = append(priceChanges, model.VisitLinePriceChange{
+				After:              newProduct,
+				Reason:             requestedProduct.PriceChangeReason,
+				DiscountApprovalID: requestedProduct.DiscountApprovalID,
+			})
 			continue
 		}
 
-		// if requested quantity != existing quantity => reduce/increas from stock and add/substract to visit product
-		if requestedProduct.Quantity != orderedProduct.Quantity {
+		// if requested quantity, unit or discount != existing => reduce/increas from stock and add/substract to visit product
+		if requestedProduct.Quantity != orderedProduct.Quantity || factor != orderedProduct.ConversionFactor ||
+			isVisitProductPriceChanged(orderedProduct, requestedProduct) {
 
-			err = u.orderProduct(
+			orderedProduct.IDMstPriceList = priceListIDs[requestedProduct.IDTrxInstitutionProduct]
+			var lineLocation model.MstStockLocation
+			var lineOnHand int64
+			lineLocation, lineOnHand, err = u.lineStockLocation(ctx, orderedProduct, location, onHand)
+			if err != nil {
+				return errors.Wrap(err, "usecase.UpsertVisitProduct")
+			}
+			var updatedProduct model.TrxVisitProduct
+			updatedProduct, err = u.orderProduct(
 				ctx,
 				orderedProduct,
 				productStock,
+				lineLocation,
+				lineOnHand,
+				conversion,
+				taxConfig,
 				requestedProduct)
 			if err != nil {
 				return
 			}
+			priceChanges = append(priceChanges, model.VisitLinePriceChange{
+				Before:             orderedProduct,
+				After:              updatedProduct,
+				Reason:             requestedProduct.PriceChangeReason,
+				DiscountApprovalID: requestedProduct.DiscountApprovalID,
+			})
 
 		}
 		// if requested quantity == existing quantity => do nothing
@@ -783,27 +988,48 @@ func (u *VisitUC) UpsertVisitProduct(ctx context.Context, req model.UpsertTrxVis
 
 	// if exist product from mapping => add product quantity back to stock then delete its visit product record
 	for _, remainingProduct := range mappedProductVisit {
-		// productStock := mappedProductInstitution[remainingProduct.IDTrxInstitutionProduct]
-		err = u.voidOrder(ctx, remainingProduct)
+		var lineLocation model.MstStockLocation
+		lineLocation, _, err = u.lineStockLocation(ctx, remainingProduct, location, onHand)
+		if err != nil {
+			return errors.Wrap(err, "usecase.UpsertVisitProduct")
+		}
+		err = u.voidOrder(ctx, lineLocation, remainingProduct)
 		if err != nil {
 			return
 		}
 
 	}
 
+	// discounts beyond the user's limit need an approval; every one is audited
+	err = u.DiscountUC.AuthorizePriceChanges(ctx, priceChanges)
+	if err != nil {
+		return
+	}
+
+	// added, changed and voided treatments move their materials by the difference
+	err = u.InstitutionUC.ConsumeTreatmentMaterials(ctx, req.IDTrxPatientVisit)
+	if err != nil {
+		return errors.Wrap(err, "usecase.UpsertVisitProduct")
+	}
+
 	return nil
 }
 
 func (u *VisitUC) voidOrder(ctx context.Context,
+	location model.MstStockLocation,
 	existingProduct model.TrxVisitProduct,
 ) (err error) {
 	err = u.InstitutionRepo.RestockDtlInstitutionProductStock(ctx, &model.DtlInstitutionProductStock{
 		IDTrxInstitutionProduct: existingProduct.IDTrxInstitutionProduct,
-		Quantity:                int64(existingProduct.Quantity),
+		Quantity:                existingProduct.BaseQuantity(),
 	})
 	if err != nil {
 		return errors.Wrap(err, "usecase.voidOrder")
 	}
+	err = u.InstitutionRepo.AdjustLocationStock(ctx, location.ID, existingProduct.IDTrxInstitutionProduct, existingProduct.BaseQuantity())
+	if err != nil {
+		return errors.Wrap(err, "usecase.voidOrder")
+	}
 
 	err = u.PatientDB.DeleteTrxVisitProduct(ctx, &existingProduct)
 	if err != nil {
@@ -816,43 +1042,153 @@ func (u *VisitUC) voidOrder(ctx context.Context,
 func (u *VisitUC) orderProduct(ctx context.Context,
 	existingProduct model.TrxVisitProduct,
 	productStock model.GetInstitutionProductResponse,
-	productRequest model.PurchasedProduct) (err error) {
+	location model.MstStockLocation,
+	locationQuantity int64,
+	conversion model.ProductUnitConversion,
+	taxConfig model.MstInstitutionTaxConfig,
+	productRequest model.PurchasedProduct) (visitProduct model.TrxVisitProduct, err error) {
 
-	quantityDifference := existingProduct.Quantity - productRequest.Quantity
+	// quantities are compared in the base unit so a line may switch units
+	factor, _ := conversion.Factor(productRequest.UnitType)
+	pricePerUnit, _ := conversion.Price(productRequest.UnitType)
+	quantityDifference := existingProduct.BaseQuantity() - int64(productRequest.Quantity)*factor
 
 	existingStock := model.DtlInstitutionProductStock{
 		IDTrxInstitutionProduct: productStock.ID,
-		Quantity:                productStock.Quantity + int64(quantityDifference), // if less than zero return error below
+		Quantity:                productStock.Quantity + quantityDifference, // if less than zero return error below
 	}
 
 	// if existing quantity > requested quantity => stock replenished
 	// if existing qunatity < reuqested quantity => stock reduced
 	if existingStock.Quantity < 0 && productStock.IsItem {
-		return commonerr.SetNewBadRequest("stock issue", fmt.Sprintf("product %s stock is not enough", productStock.Name))
+		return visitProduct, commonerr.SetNewBadRequest("stock issue", fmt.Sprintf("product %s stock is not enough", productStock.Name))
+	}
+	if locationQuantity+quantityDifference < 0 && productStock.IsItem {
+		return visitProduct, commonerr.SetNewBadRequest("stock issue", fmt.Sprintf("product %s stock at %s is not enough", productStock.Name, location.Name))
 	}
 
 	if productStock.IsItem {
+		// lines sold before they kept their location stay at the visit's
+		if !existingProduct.IDMstStockLocation.Valid {
+			existingProduct.IDMstStockLocation = null.Int64From(location.ID)
+		}
 		err = u.InstitutionRepo.UpdateDtlInstitutionProductStock(ctx, &existingStock)
 		if err != nil {
-			return errors.Wrap(err, "orderNewProductForVisit")
+			return visitProduct, errors.Wrap(err, "orderNewProductForVisit")
+		}
+		err = u.InstitutionRepo.AdjustLocationStock(ctx, location.ID, productStock.ID, quantityDifference)
+		if err != nil {
+			return visitProduct, errors.Wrap(err, "orderNewProductForVisit")
 		}
 	}
 
+	if existingProduct.ConversionFactor != factor {
+		existingProduct.UnitCost = productStock.AvgCost.MulInt(factor)
+	}
 	existingProduct.Quantity = productRequest.Quantity
-	existingProduct.AdjustedPrice = productRequest.AdjustedPrice
-	existingProduct.DiscountPrice = productRequest.DiscountPrice
+	existingProduct.UnitType = conversion.UnitType(productRequest.UnitType)
+	existingProduct.ConversionFactor = factor
+	existingProduct.Price = pricePerUnit
+	existingProduct.AdjustedPrice = productRequest.AdjustedPrice.Round()
+	existingProduct.DiscountPrice = productRequest.DiscountPrice.Round()
 	existingProduct.DiscountRate = productRequest.DiscountRate
-	decimalPrice := decimal.NewFromFloat(productStock.Price)
-
-	existingProduct.TotalPrice = decimal.NewFromInt(int64(existingProduct.Quantity)).Mul(decimalPrice).InexactFloat64()
+	existingProduct.TotalPrice = visitProductTotalPrice(pricePerUnit, existingProduct.Quantity, existingProduct.DiscountPrice, existingProduct.DiscountRate)
+	existingProduct.ApplyTax(productStock.TaxCategory, taxConfig)
 	err = u.PatientDB.UpsertTrxVisitProduct(ctx, &existingProduct)
 	if err != nil {
-		return errors.Wrap(err, "orderNewProductForVisit")
+		return visitProduct, errors.Wrap(err, "orderNewProductForVisit")
 	}
 
+	return existingProduct, nil
+}
+
+// lineStockLocation returns the location an ordered line was sold from, where
+// its stock is returned to and further quantity is drawn from, with the
+// quantity of the line's product there. Lines sold before they kept their
+// location use the visit's location, with the quantity in onHand.
+func (u *VisitUC) lineStockLocation(ctx context.Context, line model.TrxVisitProduct, location model.MstStockLocation, onHand map[int64]int64) (model.MstStockLocation, int64, error) {
+	if !line.IDMstStockLocation.Valid || line.IDMstStockLocation.Int64 == location.ID {
+		return location, onHand[line.IDTrxInstitutionProduct], nil
+	}
+
+	lineLocation := model.MstStockLocation{
+		ID:               line.IDMstStockLocation.Int64,
+		IDMstInstitution: line.IDMstInstitution,
+		Name:             fmt.Sprintf("location %d", line.IDMstStockLocation.Int64),
+	}
+	locations, err := u.InstitutionRepo.FindStockLocations(ctx, line.IDMstInstitution)
+	if err != nil {
+		return lineLocation, 0, err
+	}
+	for _, candidate := range locations {
+		if candidate.ID == lineLocation.ID {
+			lineLocation = candidate
+		}
+	}
+
+	stocks, err := u.InstitutionRepo.FindLocationStocks(ctx, model.ListLocationStockParams{
+		IDMstInstitution:   line.IDMstInstitution,
+		IDMstStockLocation: lineLocation.ID,
+		ProductIDs:         []int64{line.IDTrxInstitutionProduct},
+	})
+	if err != nil {
+		return lineLocation, 0, err
+	}
+	var quantity int64
+	for _, stock := range stocks {
+		quantity += stock.Quantity
+	}
+	return lineLocation, quantity, nil
+}
+
+// visitLocationStock returns the stock location sales on the visit draw from and
+// the quantity there of each of the products; products without stock at the
+// location are absent.
+func (u *VisitUC) visitLocationStock(ctx context.Context, visitID int64, productIDs []int64) (location model.MstStockLocation, onHand map[int64]int64, err error) {
+	location, err = u.InstitutionUC.GetVisitStockLocation(ctx, visitID)
+	if err != nil {
+		return
+	}
+
+	stocks, err := u.InstitutionRepo.FindLocationStocks(ctx, model.ListLocationStockParams{
+		IDMstInstitution:   location.IDMstInstitution,
+		IDMstStockLocation: location.ID,
+		ProductIDs:         productIDs,
+	})
+	if err != nil {
+		return
+	}
+
+	onHand = make(map[int64]int64, len(stocks))
+	for _, stock := range stocks {
+		onHand[stock.IDTrxInstitutionProduct] = stock.Quantity
+	}
 	return
 }
 
+// isVisitProductPriceChanged reports whether the request changes the discount
+// or adjusted price of an ordered line.
+func isVisitProductPriceChanged(orderedProduct model.TrxVisitProduct, productRequest model.PurchasedProduct) bool {
+	return orderedProduct.DiscountRate != productRequest.DiscountRate ||
+		!orderedProduct.DiscountPrice.Equal(productRequest.DiscountPrice.Round()) ||
+		!orderedProduct.AdjustedPrice.Equal(productRequest.AdjustedPrice.Round())
+}
+
+// visitProductTotalPrice is the line total of quantity units at pricePerUnit.
+// A fixed discountPrice takes precedence over discountRate; the percentage
+// discount is rounded to the cent before it is subtracted.
+func visitProductTotalPrice(pricePerUnit money.Money, quantity int, discountPrice money.Money, discountRate float64) money.Money {
+	total := pricePerUnit.MulInt(int64(quantity))
+	if discountPrice.IsPositive() {
+		// Apply fixed discount amount if specified
+		total = total.Sub(discountPrice)
+	} else if discountRate > 0 {
+		// Apply percentage discount if specified
+		total = total.Sub(total.MulRate(discountRate).Round())
+	}
+	return total.Round()
+}
+
 func (u *VisitUC) getMappedInstitutionProducts(
 	ctx context.Context,
 	institutionID int64,
@@ -896,6 +1232,62 @@ func (u *VisitUC) getMappedInstitutionProducts(
 	return mappedProductItems, nil
 }
 
+// getProductUnitConversions loads the configured units of the given products
+// and returns their conversions keyed by product ID.
+func (u *VisitUC) getProductUnitConversions(
+	ctx context.Context,
+	productItems []model.GetInstitutionProductResponse,
+) (map[int64]model.ProductUnitConversion, error) {
+	productIDs := make([]int64, 0, len(productItems))
+	for _, item := range productItems {
+		productIDs = append(productIDs, item.ID)
+	}
+
+	units, err := u.InstitutionRepo.FindProductUnitsByProductIDs(ctx, productIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgInsertVisitProduct)
+	}
+
+	return model.NewProductUnitConversions(productItems, units), nil
+}
+
+// applyPriceLists reprices conversions from the price lists effective when the
+// visit was opened, preferring the visit's patient category over the general
+// list. It returns the list that priced each product; products on no list keep
+// their base price and are absent from the result.
+func (u *VisitUC) applyPriceLists(
+	ctx context.Context,
+	institutionID, visitID int64,
+	conversions map[int64]model.ProductUnitConversion,
+) (map[int64]null.Int64, error) {
+	priceListIDs := make(map[int64]null.Int64, len(conversions))
+
+	visit, err := u.PatientDB.GetPatientVisitsByID(ctx, visitID)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgInsertVisitProduct)
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != institutionID {
+		return priceListIDs, nil
+	}
+
+	productIDs := make([]int64, 0, len(conversions))
+	for productID := range conversions {
+		productIDs = append(productIDs, productID)
+	}
+
+	prices, err := u.PriceListDB.FindApplicablePrices(ctx, institutionID,
+		model.ApplicablePriceCategories(visit.PatientCategory), visit.CreateTime, productIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, WrapMsgInsertVisitProduct)
+	}
+
+	for productID, price := range model.SelectApplicablePrices(visit.PatientCategory, prices) {
+		conversions[productID] = conversions[productID].WithListPrice(price.Price)
+		priceListIDs[productID] = null.Int64From(price.IDMstPriceList)
+	}
+	return priceListIDs, nil
+}
+
 func (u *VisitUC) getMappedOrderedProduct(ctx context.Context, trxVisit model.TrxVisitProduct) (mappedProductVisitByProductID map[int64]model.TrxVisitProduct, err error) {
 	orderedProducts, err := u.PatientDB.GetTrxVisitProduct(ctx, model.GetVisitProductRequest{
 		VisitID:       trxVisit.ID,
@@ -966,6 +1358,122 @@ func (u *VisitUC) ListVisitProducts(ctx context.Context, params model.GetVisitPr
 	return u.PatientDB.GetTrxVisitProduct(ctx, params)
 }
 
+// GetVisitInvoice returns the bill of a visit: its lines with the tax treatment
+// each was charged with, totals broken down by tax category, and the split
+// between the visit's payer and the patient.
+func (u *VisitUC) GetVisitInvoice(ctx context.Context, visitID int64) (invoice model.VisitInvoiceResponse, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	visit, err := u.PatientDB.GetPatientVisitsByID(ctx, visitID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetVisitInvoice)
+		return
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != userDetail.InstitutionID {
+		err = commonerr.SetNewError(http.StatusNotFound, "visit_not_found", "visit was not found in this institution")
+		return
+	}
+
+	lines, err := u.PatientDB.GetTrxVisitProduct(ctx, model.GetVisitProductRequest{
+		VisitID:       visitID,
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetVisitInvoice)
+		return
+	}
+	if lines == nil {
+		lines = []model.TrxVisitProduct{}
+	}
+
+	taxConfig, err := u.InstitutionRepo.GetInstitutionTaxConfig(ctx, userDetail.InstitutionID)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgGetVisitInvoice)
+		return
+	}
+
+	totals := model.NewVisitTotals(lines)
+	return model.VisitInvoiceResponse{
+		IDTrxPatientVisit: visit.ID,
+		PatientCategory:   visit.PatientCategory,
+		TaxConfig:         taxConfig,
+		Lines:             lines,
+		Totals:            totals,
+		Split:             model.NewVisitInvoiceSplit(visit, totals.GrandTotal),
+	}, nil
+}
+
+// SelectVisitPayer bills a visit to one of its patient's payers, which must
+// cover the patient on the day the visit was opened, or with a zero payer to
+// the patient alone, priced with the patient's category. Lines are priced as
+// they are added, so the payer can only change before the visit has any.
+func (u *VisitUC) SelectVisitPayer(ctx context.Context, req model.SelectVisitPayerRequest) (visit model.TrxPatientVisit, err error) {
+	userDetail, found := auth.GetUserDetailFromCtx(ctx)
+	if !found {
+		err = commonerr.SetNewUnauthorizedAPICall()
+		return
+	}
+
+	visit, err = u.PatientDB.GetPatientVisitsByID(ctx, req.IDTrxPatientVisit)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSelectVisitPayer)
+		return
+	}
+	if visit.ID == 0 || visit.IDMstInstitution != userDetail.InstitutionID {
+		err = commonerr.SetNewError(http.StatusNotFound, "visit_not_found", "visit was not found in this institution")
+		return
+	}
+
+	lines, err := u.PatientDB.GetTrxVisitProduct(ctx, model.GetVisitProductRequest{
+		VisitID:       visit.ID,
+		InstitutionID: userDetail.InstitutionID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSelectVisitPayer)
+		return
+	}
+	if len(lines) > 0 {
+		err = commonerr.SetNewBadRequest("visit_has_lines", "the payer of a visit can only change before anything is billed to it")
+		return
+	}
+
+	patient, err := u.PatientDB.GetPatientByID(ctx, visit.IDMstPatient)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSelectVisitPayer)
+		return
+	}
+
+	if req.PayerID == 0 {
+		visit.ClearPayer(patient.PatientCategory)
+	} else {
+		payer, errCheck := u.PatientUC.CheckPayerEligibility(ctx, model.CheckPatientPayerEligibilityRequest{
+			PatientUUID: patient.UUID,
+			ID:          req.PayerID,
+			ServiceDate: customtime.Time{Time: visit.CreateTime},
+		})
+		if errCheck != nil {
+			err = errCheck
+			return
+		}
+		if payer.EligibilityStatus != model.EligibilityStatusEligible {
+			err = commonerr.SetNewUnprocessableEntityError("payer_id", "the payer does not cover the patient: "+payer.EligibilityMessage)
+			return
+		}
+		visit.SelectPayer(payer)
+	}
+
+	err = u.PatientDB.UpdateVisitPayer(ctx, visit)
+	if err != nil {
+		err = errors.Wrap(err, WrapMsgSelectVisitPayer)
+		return
+	}
+	return
+}
+
 func (u *VisitUC) ArchivePatientVisit(ctx context.Context, req model.ArchivePatientVisitRequest) (err error) {
 
 	_, err = u.ValidatePatientVisitExist(ctx, ValidatePatientVisitExistRequest{
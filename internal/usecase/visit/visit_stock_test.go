@@ -0,0 +1,373 @@
+package visit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-xorm/xorm"
+
+	"github.com/faisalhardin/medilink/internal/entity/model"
+	institutionRepo "github.com/faisalhardin/medilink/internal/entity/repo/institution"
+	patientRepo "github.com/faisalhardin/medilink/internal/entity/repo/patient"
+	pricelistrepo "github.com/faisalhardin/medilink/internal/entity/repo/pricelist"
+	discountuc "github.com/faisalhardin/medilink/internal/entity/usecase/discount"
+	"github.com/faisalhardin/medilink/internal/library/middlewares/auth"
+	"github.com/faisalhardin/medilink/internal/usecase/institution"
+	"github.com/faisalhardin/medilink/pkg/type/money"
+	"github.com/volatiletech/null/v8"
+)
+
+// stockDB keeps products, their stock and the stock per location in memory.
+// Methods the tests do not reach are left to the embedded interface.
+type stockDB struct {
+	institutionRepo.InstitutionDB
+
+	main           model.MstStockLocation
+	products       map[int64]model.TrxInstitutionProduct
+	stocks         map[int64]model.DtlInstitutionProductStock
+	locationStocks map[int64]map[int64]int64
+	movements      []model.TrxStockMovement
+}
+
+func newStockDB(institutionID int64) *stockDB {
+	return &stockDB{
+		main:           model.MstStockLocation{ID: 1, IDMstInstitution: institutionID, Name: "Main", IsMain: true},
+		products:       map[int64]model.TrxInstitutionProduct{},
+		stocks:         map[int64]model.DtlInstitutionProductStock{},
+		locationStocks: map[int64]map[int64]int64{},
+	}
+}
+
+func (db *stockDB) InsertInstitutionProduct(ctx context.Context, product *model.TrxInstitutionProduct) error {
+	product.ID = int64(len(db.products) + 1)
+	db.products[product.ID] = *product
+	return nil
+}
+
+func (db *stockDB) InsertInstitutionProductStock(ctx context.Context, stock *model.DtlInstitutionProductStock) error {
+	stock.ID = stock.IDTrxInstitutionProduct
+	db.stocks[stock.IDTrxInstitutionProduct] = *stock
+	return nil
+}
+
+func (db *stockDB) FindTrxInstitutionProductStockByParams(ctx context.Context, request model.DtlInstitutionProductStock) ([]model.DtlInstitutionProductStock, error) {
+	stock, ok := db.stocks[request.IDTrxInstitutionProduct]
+	if !ok {
+		return nil, nil
+	}
+	return []model.DtlInstitutionProductStock{stock}, nil
+}
+
+func (db *stockDB) UpdateDtlInstitutionProductStock(ctx context.Context, request *model.DtlInstitutionProductStock) error {
+	stock := db.stocks[request.IDTrxInstitutionProduct]
+	stock.Quantity = request.Quantity
+	db.stocks[request.IDTrxInstitutionProduct] = stock
+	return nil
+}
+
+func (db *stockDB) RestockDtlInstitutionProductStock(ctx context.Context, request *model.DtlInstitutionProductStock) error {
+	stock := db.stocks[request.IDTrxInstitutionProduct]
+	stock.Quantity += request.Quantity
+	db.stocks[request.IDTrxInstitutionProduct] = stock
+	return nil
+}
+
+func (db *stockDB) FindTrxInstitutionProductJoinStockByParams(ctx context.Context, params model.FindTrxInstitutionProductParams) ([]model.GetInstitutionProductResponse, error) {
+	products := []model.GetInstitutionProductResponse{}
+	for _, id := range params.IDs {
+		product, ok := db.products[id]
+		if !ok {
+			continue
+		}
+		stock := db.stocks[id]
+		products = append(products, model.GetInstitutionProductResponse{
+			ID:          product.ID,
+			Name:        product.Name,
+			Price:       product.Price,
+			IsItem:      product.IsItem,
+			TaxCategory: product.TaxCategory,
+			Quantity:    stock.Quantity,
+			UnitType:    stock.UnitType,
+			AvgCost:     stock.AvgCost,
+		})
+	}
+	return products, nil
+}
+
+// InsertStockMovements books movements without a location at the main one,
+// as the repository does.
+func (db *stockDB) InsertStockMovements(ctx context.Context, movements []model.TrxStockMovement) error {
+	for _, movement := range movements {
+		if movement.IDMstStockLocation == 0 {
+			movement.IDMstStockLocation = db.main.ID
+		}
+		db.movements = append(db.movements, movement)
+		if err := db.AdjustLocationStock(ctx, movement.IDMstStockLocation, movement.IDTrxInstitutionProduct, movement.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *stockDB) EnsureMainStockLocation(ctx context.Context, institutionID int64) (model.MstStockLocation, error) {
+	return db.main, nil
+}
+
+func (db *stockDB) FindStockLocations(ctx context.Context, institutionID int64) ([]model.MstStockLocation, error) {
+	return []model.MstStockLocation{db.main, {ID: 2, IDMstInstitution: institutionID, Name: "Pharmacy"}}, nil
+}
+
+func (db *stockDB) FindVisitStockLocation(ctx context.Context, institutionID, visitID int64) (model.MstStockLocation, bool, error) {
+	return model.MstStockLocation{}, false, nil
+}
+
+func (db *stockDB) FindLocationStocks(ctx context.Context, params model.ListLocationStockParams) ([]model.LocationStockRow, error) {
+	rows := []model.LocationStockRow{}
+	for _, productID := range params.ProductIDs {
+		quantity, ok := db.locationStocks[params.IDMstStockLocation][productID]
+		if !ok {
+			continue
+		}
+		rows = append(rows, model.LocationStockRow{
+			IDMstStockLocation:      params.IDMstStockLocation,
+			IDTrxInstitutionProduct: productID,
+			Quantity:                quantity,
+		})
+	}
+	return rows, nil
+}
+
+func (db *stockDB) AdjustLocationStock(ctx context.Context, locationID, productID, delta int64) error {
+	if db.locationStocks[locationID] == nil {
+		db.locationStocks[locationID] = map[int64]int64{}
+	}
+	db.locationStocks[locationID][productID] += delta
+	return nil
+}
+
+func (db *stockDB) FindProductUnitsByProductIDs(ctx context.Context, productIDs []int64) ([]model.DtlInstitutionProductUnit, error) {
+	return nil, nil
+}
+
+func (db *stockDB) GetInstitutionTaxConfig(ctx context.Context, institutionID int64) (model.MstInstitutionTaxConfig, error) {
+	return model.MstInstitutionTaxConfig{}, nil
+}
+
+func (db *stockDB) FindVisitTreatmentUsage(ctx context.Context, institutionID, visitID int64) ([]model.TreatmentUsage, error) {
+	return nil, nil
+}
+
+func (db *stockDB) FindTreatmentMaterialsByProductIDs(ctx context.Context, productIDs []int64) ([]model.TreatmentMaterialRow, error) {
+	return nil, nil
+}
+
+func (db *stockDB) FindVisitTreatmentMaterials(ctx context.Context, visitID int64) ([]model.TreatmentMaterialRow, error) {
+	return nil, nil
+}
+
+func (db *stockDB) SumStockMovementsByReference(ctx context.Context, institutionID int64, referenceType string, referenceID int64, movementTypes []string) ([]model.MaterialStockBalance, error) {
+	return nil, nil
+}
+
+type visitDB struct {
+	patientRepo.PatientDB
+
+	dtlVisit model.DtlPatientVisit
+	lines    []model.TrxVisitProduct
+}
+
+func (db *visitDB) GetDtlPatientVisit(ctx context.Context, params model.GetDtlPatientVisitParams) ([]model.DtlPatientVisitWithShortID, error) {
+	return []model.DtlPatientVisitWithShortID{{DtlPatientVisit: db.dtlVisit}}, nil
+}
+
+func (db *visitDB) GetPatientVisitsByID(ctx context.Context, visitID int64) (model.TrxPatientVisit, error) {
+	return model.TrxPatientVisit{}, nil
+}
+
+func (db *visitDB) InsertTrxVisitProduct(ctx context.Context, line *model.TrxVisitProduct) error {
+	line.ID = int64(len(db.lines) + 1)
+	db.lines = append(db.lines, *line)
+	return nil
+}
+
+func (db *visitDB) GetTrxVisitProduct(ctx context.Context, params model.GetVisitProductRequest) ([]model.TrxVisitProduct, error) {
+	return db.lines, nil
+}
+
+func (db *visitDB) UpsertTrxVisitProduct(ctx context.Context, line *model.TrxVisitProduct) error {
+	for i := range db.lines {
+		if db.lines[i].ID == line.ID {
+			db.lines[i] = *line
+		}
+	}
+	return nil
+}
+
+func (db *visitDB) DeleteTrxVisitProduct(ctx context.Context, line *model.TrxVisitProduct) error {
+	lines := db.lines[:0]
+	for _, existing := range db.lines {
+		if existing.ID != line.ID {
+			lines = append(lines, existing)
+		}
+	}
+	db.lines = lines
+	return nil
+}
+
+type priceListDB struct {
+	pricelistrepo.PriceListDB
+}
+
+func (priceListDB) InsertProductPriceHistory(ctx context.Context, history *model.TrxProductPriceHistory) error {
+	return nil
+}
+
+func (priceListDB) FindApplicablePrices(ctx context.Context, institutionID int64, patientCategories []string, at time.Time, productIDs []int64) ([]model.ApplicablePrice, error) {
+	return nil, nil
+}
+
+type discountUC struct {
+	discountuc.DiscountUC
+}
+
+func (discountUC) AuthorizePriceChanges(ctx context.Context, changes []model.VisitLinePriceChange) error {
+	return nil
+}
+
+type noTransaction struct{}
+
+func (noTransaction) Begin(ctx context.Context) (*xorm.Session, error) { return nil, nil }
+
+func (noTransaction) Finish(session *xorm.Session, err *error) {}
+
+func TestSellNewProduct(t *testing.T) {
+	t.Parallel()
+
+	const institutionID = 7
+	ctx := auth.SetUserDetailToCtx(context.Background(), model.UserJWTPayload{
+		InstitutionID: institutionID,
+		Email:         "clerk@clinic",
+	})
+
+	stock := newStockDB(institutionID)
+	institutionUC := &institution.InstitutionUC{
+		InstitutionRepo: stock,
+		PriceListDB:     priceListDB{},
+		Transaction:     noTransaction{},
+	}
+	visits := &visitDB{dtlVisit: model.DtlPatientVisit{ID: 31, IDTrxPatientVisit: 30}}
+	visitUC := &VisitUC{
+		PatientDB:       visits,
+		InstitutionRepo: stock,
+		PriceListDB:     priceListDB{},
+		Transaction:     noTransaction{},
+		InstitutionUC:   institutionUC,
+		DiscountUC:      discountUC{},
+	}
+
+	product, err := institutionUC.InserInstitutionProduct(ctx, model.InsertInstitutionProductRequest{
+		Name:     "Amoxicillin 500mg",
+		Price:    money.New(2500),
+		IsItem:   true,
+		Quantity: 10,
+		UnitType: "tablet",
+	})
+	if err != nil {
+		t.Fatalf("InserInstitutionProduct() = %v", err)
+	}
+	if len(stock.movements) != 1 || stock.movements[0].MovementType != model.StockMovementTypeCatalogImport {
+		t.Fatalf("expected one opening stock movement, got %+v", stock.movements)
+	}
+	if got := stock.locationStocks[stock.main.ID][product.ID]; got != 10 {
+		t.Fatalf("expected 10 at the main location after create, got %d", got)
+	}
+
+	err = visitUC.InsertVisitProduct(ctx, model.InsertTrxVisitProductRequest{
+		IDDtlPatientVisit: 31,
+		IDTrxPatientVisit: 30,
+		Products: []model.PurchasedProduct{
+			{IDTrxInstitutionProduct: product.ID, Quantity: 4, UnitType: "tablet"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("InsertVisitProduct() = %v", err)
+	}
+	if len(visits.lines) != 1 || visits.lines[0].Quantity != 4 {
+		t.Fatalf("expected a visit line of 4, got %+v", visits.lines)
+	}
+	if got := stock.stocks[product.ID].Quantity; got != 6 {
+		t.Fatalf("expected 6 in stock after the sale, got %d", got)
+	}
+	if got := stock.locationStocks[stock.main.ID][product.ID]; got != 6 {
+		t.Fatalf("expected 6 at the main location after the sale, got %d", got)
+	}
+}
+
+func TestReturnStockToSoldFromLocation(t *testing.T) {
+	t.Parallel()
+
+	const institutionID = 7
+	ctx := auth.SetUserDetailToCtx(context.Background(), model.UserJWTPayload{
+		InstitutionID: institutionID,
+		Email:         "clerk@clinic",
+	})
+
+	// both lines were sold from the pharmacy; the visit has since moved to a
+	// service point that sells from the main location
+	const pharmacyID = 2
+	stock := newStockDB(institutionID)
+	visits := &visitDB{dtlVisit: model.DtlPatientVisit{ID: 31, IDTrxPatientVisit: 30}}
+	for _, name := range []string{"Paracetamol 500mg", "Cetirizine 10mg"} {
+		product := model.TrxInstitutionProduct{Name: name, Price: money.New(1000), IsItem: true}
+		_ = stock.InsertInstitutionProduct(ctx, &product)
+		_ = stock.InsertInstitutionProductStock(ctx, &model.DtlInstitutionProductStock{IDTrxInstitutionProduct: product.ID, Quantity: 7, UnitType: "tablet"})
+		_ = visits.InsertTrxVisitProduct(ctx, &model.TrxVisitProduct{
+			IDTrxInstitutionProduct: product.ID,
+			IDMstInstitution:        institutionID,
+			IDTrxPatientVisit:       30,
+			Quantity:                3,
+			UnitType:                "tablet",
+			ConversionFactor:        1,
+			Price:                   product.Price,
+			IDMstStockLocation:      null.Int64From(pharmacyID),
+		})
+	}
+	stock.locationStocks[pharmacyID] = map[int64]int64{1: 7, 2: 7}
+
+	visitUC := &VisitUC{
+		PatientDB:       visits,
+		InstitutionRepo: stock,
+		PriceListDB:     priceListDB{},
+		Transaction:     noTransaction{},
+		InstitutionUC: &institution.InstitutionUC{
+			InstitutionRepo: stock,
+			Transaction:     noTransaction{},
+		},
+		DiscountUC: discountUC{},
+	}
+
+	// the first line is reduced to 1, the second is voided
+	err := visitUC.UpsertVisitProduct(ctx, model.UpsertTrxVisitProductRequest{
+		IDDtlPatientVisit: 31,
+		IDTrxPatientVisit: 30,
+		Products: []model.PurchasedProduct{
+			{IDTrxInstitutionProduct: 1, Quantity: 1, UnitType: "tablet"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("UpsertVisitProduct() = %v", err)
+	}
+
+	for productID, want := range map[int64]int64{1: 9, 2: 10} {
+		if got := stock.locationStocks[pharmacyID][productID]; got != want {
+			t.Fatalf("product %d: expected %d at the pharmacy, got %d", productID, want, got)
+		}
+		if got := stock.locationStocks[stock.main.ID][productID]; got != 0 {
+			t.Fatalf("product %d: expected nothing returned to the main location, got %d", productID, got)
+		}
+	}
+	if len(visits.lines) != 1 || visits.lines[0].Quantity != 1 {
+		t.Fatalf("expected the reduced line only, got %+v", visits.lines)
+	}
+}
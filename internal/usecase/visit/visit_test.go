@@ -0,0 +1,31 @@
+package visit
+
+import (
+	"testing"
+
+	"github.com/faisalhardin/medilink/pkg/type/money"
+)
+
+func TestVisitProductTotalPrice(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		price         money.Money
+		quantity      int
+		discountPrice money.Money
+		discountRate  float64
+		want          string
+	}{
+		{"no discount", money.NewFromFloat(33333.33), 3, money.Zero, 0, "99999.99"},
+		{"fixed discount wins over rate", money.New(15000), 2, money.New(5000), 0.5, "25000"},
+		{"rate discount rounded to the cent", money.NewFromFloat(33333.33), 3, money.Zero, 0.125, "87499.99"},
+		{"rate discount of a tenth", money.NewFromFloat(0.1), 3, money.Zero, 0.1, "0.27"},
+	}
+	for _, c := range cases {
+		got := visitProductTotalPrice(c.price, c.quantity, c.discountPrice, c.discountRate)
+		if got.String() != c.want {
+			t.Fatalf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
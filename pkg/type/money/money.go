@@ -0,0 +1,269 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	"github.com/shopspring/decimal"
+)
+
+// Scale is the number of decimal places amounts are rounded to.
+const Scale int32 = 2
+
+// Money is an exact decimal amount of Rupiah.
+//
+// Rounding rules:
+//   - arithmetic (Add, Sub, Mul, MulInt, MulRate, Div, DivRate) is exact and never
+//     rounds except Div and DivRate, which keep 16 decimal places;
+//   - amounts that are persisted or shown as a line total are rounded with Round,
+//     half away from zero, to Scale decimal places;
+//   - running averages such as average unit cost keep full precision.
+type Money struct {
+	decimal.Decimal
+}
+
+// Zero is the zero amount.
+var Zero = Money{Decimal: decimal.Zero}
+
+// New returns an amount of whole Rupiah.
+func New(value int64) Money {
+	return Money{Decimal: decimal.NewFromInt(value)}
+}
+
+// NewFromFloat returns the amount closest to value.
+func NewFromFloat(value float64) Money {
+	return Money{Decimal: decimal.NewFromFloat(value)}
+}
+
+// NewFromString parses a decimal amount such as "12500.50".
+func NewFromString(value string) (Money, error) {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return Zero, err
+	}
+	return Money{Decimal: d}, nil
+}
+
+// NewFromDecimal wraps d.
+func NewFromDecimal(d decimal.Decimal) Money {
+	return Money{Decimal: d}
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{Decimal: m.Decimal.Add(other.Decimal)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Decimal: m.Decimal.Sub(other.Decimal)}
+}
+
+func (m Money) Mul(other Money) Money {
+	return Money{Decimal: m.Decimal.Mul(other.Decimal)}
+}
+
+// MulInt multiplies the amount by a quantity.
+func (m Money) MulInt(quantity int64) Money {
+	return Money{Decimal: m.Decimal.Mul(decimal.NewFromInt(quantity))}
+}
+
+// MulRate multiplies the amount by a fractional rate, e.g. 0.1 for 10%.
+func (m Money) MulRate(rate float64) Money {
+	return Money{Decimal: m.Decimal.Mul(decimal.NewFromFloat(rate))}
+}
+
+// DivRate divides the amount by a fractional rate, e.g. 1.11 to take 11% PPN
+// out of a tax-inclusive price. Dividing by zero returns Zero.
+func (m Money) DivRate(rate float64) Money {
+	if rate == 0 {
+		return Zero
+	}
+	return Money{Decimal: m.Decimal.Div(decimal.NewFromFloat(rate))}
+}
+
+// Div divides the amount by a quantity. Dividing by zero returns Zero.
+func (m Money) Div(quantity int64) Money {
+	if quantity == 0 {
+		return Zero
+	}
+	return Money{Decimal: m.Decimal.Div(decimal.NewFromInt(quantity))}
+}
+
+// DivMoney returns the ratio m/other. Dividing by zero returns Zero.
+func (m Money) DivMoney(other Money) Money {
+	if other.IsZero() {
+		return Zero
+	}
+	return Money{Decimal: m.Decimal.Div(other.Decimal)}
+}
+
+func (m Money) Neg() Money {
+	return Money{Decimal: m.Decimal.Neg()}
+}
+
+// Round rounds the amount half away from zero to Scale decimal places.
+func (m Money) Round() Money {
+	return Money{Decimal: m.Decimal.Round(Scale)}
+}
+
+func (m Money) Cmp(other Money) int {
+	return m.Decimal.Cmp(other.Decimal)
+}
+
+func (m Money) Equal(other Money) bool {
+	return m.Decimal.Equal(other.Decimal)
+}
+
+func (m Money) GreaterThan(other Money) bool {
+	return m.Decimal.GreaterThan(other.Decimal)
+}
+
+func (m Money) LessThan(other Money) bool {
+	return m.Decimal.LessThan(other.Decimal)
+}
+
+// Float64 returns the nearest float64, for ratios and display only.
+func (m Money) Float64() float64 {
+	f, _ := m.Decimal.Float64()
+	return f
+}
+
+// Max returns the larger of a and b.
+func Max(a, b Money) Money {
+	if a.GreaterThan(b) {
+		return a
+	}
+	return b
+}
+
+// Min returns the smaller of a and b.
+func Min(a, b Money) Money {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
+
+// Sum adds up amounts.
+func Sum(amounts ...Money) Money {
+	total := Zero
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+// MarshalJSON writes the amount as a plain JSON number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		m.Decimal = decimal.Zero
+		return nil
+	}
+	b = bytes.Trim(b, `"`)
+	d, err := decimal.NewFromString(string(b))
+	if err != nil {
+		return fmt.Errorf("money: invalid amount %s", string(b))
+	}
+	m.Decimal = d
+	return nil
+}
+
+// FromDB implements xorm's core.Conversion for NUMERIC columns.
+func (m *Money) FromDB(b []byte) error {
+	if len(b) == 0 {
+		m.Decimal = decimal.Zero
+		return nil
+	}
+	d, err := decimal.NewFromString(string(b))
+	if err != nil {
+		return err
+	}
+	m.Decimal = d
+	return nil
+}
+
+// ToDB implements xorm's core.Conversion for NUMERIC columns.
+func (m *Money) ToDB() ([]byte, error) {
+	return []byte(m.Decimal.String()), nil
+}
+
+// Scan implements sql.Scanner so raw queries can scan into Money.
+func (m *Money) Scan(value interface{}) error {
+	if value == nil {
+		m.Decimal = decimal.Zero
+		return nil
+	}
+	return m.Decimal.Scan(value)
+}
+
+// Value implements driver.Valuer.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal.String(), nil
+}
+
+// MoneyConverter decodes query and form values into Money.
+var MoneyConverter = func(value string) reflect.Value {
+	if m, err := NewFromString(value); err == nil {
+		return reflect.ValueOf(m)
+	}
+	return reflect.Value{}
+}
+
+// NullMoney is a Money that may be absent.
+type NullMoney struct {
+	Money Money
+	Valid bool
+}
+
+// NullMoneyFrom returns a valid NullMoney holding m.
+func NullMoneyFrom(m Money) NullMoney {
+	return NullMoney{Money: m, Valid: true}
+}
+
+func (n NullMoney) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return n.Money.MarshalJSON()
+}
+
+func (n *NullMoney) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		n.Money, n.Valid = Zero, false
+		return nil
+	}
+	if err := n.Money.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Scan implements sql.Scanner. NullMoney deliberately does not implement
+// xorm's core.Conversion so that an invalid value is written as NULL rather
+// than an empty string.
+func (n *NullMoney) Scan(value interface{}) error {
+	if value == nil {
+		n.Money, n.Valid = Zero, false
+		return nil
+	}
+	if err := n.Money.Scan(value); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+func (n NullMoney) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Money.Value()
+}
@@ -0,0 +1,107 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoneyArithmeticIsExact(t *testing.T) {
+	t.Parallel()
+
+	// 0.1 + 0.2 drifts with float64
+	got := NewFromFloat(0.1).Add(NewFromFloat(0.2))
+	if !got.Equal(NewFromFloat(0.3)) {
+		t.Fatalf("expected 0.3, got %s", got)
+	}
+
+	// 3 x 33,333.33 with a 12.5% discount
+	price := NewFromFloat(33333.33)
+	total := price.MulInt(3)
+	discount := total.MulRate(0.125).Round()
+	if discount.String() != "12500" {
+		t.Fatalf("expected discount 12500, got %s", discount)
+	}
+	if net := total.Sub(discount); net.String() != "87499.99" {
+		t.Fatalf("expected 87499.99, got %s", net)
+	}
+
+	// 11% PPN taken out of a tax-inclusive 111,000
+	if base := New(111000).DivRate(1.11).Round(); base.String() != "100000" {
+		t.Fatalf("expected base 100000, got %s", base)
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in, want string
+	}{
+		{"10.005", "10.01"},
+		{"10.004", "10"},
+		{"-10.005", "-10.01"},
+		{"1500", "1500"},
+	}
+	for _, c := range cases {
+		m, err := NewFromString(c.in)
+		if err != nil {
+			t.Fatalf("parse %s: %v", c.in, err)
+		}
+		if got := m.Round().String(); got != c.want {
+			t.Fatalf("round %s: got %s, want %s", c.in, got, c.want)
+		}
+	}
+
+	if got := New(10).Div(3).Round().String(); got != "3.33" {
+		t.Fatalf("expected 3.33, got %s", got)
+	}
+	if got := New(10).Div(0); !got.IsZero() {
+		t.Fatalf("expected division by zero to return zero, got %s", got)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Parallel()
+
+	var payload struct {
+		Price  Money     `json:"price"`
+		Quoted Money     `json:"quoted"`
+		Unit   NullMoney `json:"unit"`
+		Absent NullMoney `json:"absent"`
+	}
+	err := json.Unmarshal([]byte(`{"price": 12500.5, "quoted": "99.99", "unit": 100, "absent": null}`), &payload)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if payload.Price.String() != "12500.5" || payload.Quoted.String() != "99.99" {
+		t.Fatalf("unexpected amounts %s, %s", payload.Price, payload.Quoted)
+	}
+	if !payload.Unit.Valid || payload.Absent.Valid {
+		t.Fatalf("unexpected validity %v, %v", payload.Unit.Valid, payload.Absent.Valid)
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"price":12500.5,"quoted":99.99,"unit":100,"absent":null}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+}
+
+func TestMoneyFromDB(t *testing.T) {
+	t.Parallel()
+
+	var m Money
+	if err := m.FromDB([]byte("15000.25")); err != nil {
+		t.Fatalf("FromDB: %v", err)
+	}
+	b, _ := m.ToDB()
+	if string(b) != "15000.25" {
+		t.Fatalf("expected round trip, got %s", b)
+	}
+	if err := m.FromDB([]byte("abc")); err == nil {
+		t.Fatalf("expected invalid numeric to fail")
+	}
+}
@@ -0,0 +1,121 @@
+-- Supplier master data, purchase orders and the stock movement ledger.
+-- Receiving a purchase order line writes a mdl_trx_stock_movement row and
+-- folds the received unit cost into mdl_dtl_institution_product_stock.avg_cost
+-- (weighted average). mdl_trx_visit_product.unit_cost snapshots that average
+-- at sale time so product statistics can report cost and margin.
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_supplier (
+    id                  BIGSERIAL       PRIMARY KEY,
+    id_mst_institution  BIGINT          NOT NULL,
+    name                VARCHAR(255)    NOT NULL,
+    contact_person      VARCHAR(255),
+    phone_number        VARCHAR(50),
+    email               VARCHAR(255),
+    address             TEXT,
+    notes               TEXT,
+    create_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_mst_supplier_institution_active
+    ON public.mdl_mst_supplier (id_mst_institution)
+    WHERE delete_time IS NULL;
+
+-- status: draft -> ordered -> partially_received -> received; draft/ordered -> cancelled
+CREATE TABLE IF NOT EXISTS public.mdl_trx_purchase_order (
+    id                  BIGSERIAL       PRIMARY KEY,
+    id_mst_institution  BIGINT          NOT NULL,
+    id_mst_supplier     BIGINT          NOT NULL,
+    status              VARCHAR(20)     NOT NULL DEFAULT 'draft'
+                        CHECK (status IN ('draft', 'ordered', 'partially_received', 'received', 'cancelled')),
+    notes               TEXT,
+    total_amount        NUMERIC         NOT NULL DEFAULT 0,
+    created_by          VARCHAR(255)    NOT NULL,
+    ordered_at          TIMESTAMPTZ,
+    received_at         TIMESTAMPTZ,
+    create_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_purchase_order_institution_status
+    ON public.mdl_trx_purchase_order (id_mst_institution, status)
+    WHERE delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_purchase_order_item (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_purchase_order       BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    name                        VARCHAR(255)    NOT NULL,
+    ordered_quantity            BIGINT          NOT NULL CHECK (ordered_quantity > 0),
+    received_quantity           BIGINT          NOT NULL DEFAULT 0 CHECK (received_quantity >= 0),
+    unit_cost                   NUMERIC         NOT NULL DEFAULT 0,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ,
+    CONSTRAINT chk_dtl_purchase_order_item_received CHECK (received_quantity <= ordered_quantity)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dtl_purchase_order_item_order_active
+    ON public.mdl_dtl_purchase_order_item (id_trx_purchase_order)
+    WHERE delete_time IS NULL;
+
+-- Append-only ledger of stock changes. quantity is signed (positive = stock in).
+CREATE TABLE IF NOT EXISTS public.mdl_trx_stock_movement (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    movement_type               VARCHAR(30)     NOT NULL,
+    quantity                    BIGINT          NOT NULL,
+    unit_cost                   NUMERIC         NOT NULL DEFAULT 0,
+    reference_type              VARCHAR(50),
+    reference_id                BIGINT,
+    notes                       TEXT,
+    created_by                  VARCHAR(255)    NOT NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_stock_movement_product_time
+    ON public.mdl_trx_stock_movement (id_mst_institution, id_trx_institution_product, create_time);
+
+CREATE INDEX IF NOT EXISTS idx_trx_stock_movement_reference
+    ON public.mdl_trx_stock_movement (reference_type, reference_id);
+
+ALTER TABLE public.mdl_dtl_institution_product_stock
+    ADD COLUMN IF NOT EXISTS avg_cost NUMERIC NOT NULL DEFAULT 0;
+
+ALTER TABLE public.mdl_trx_visit_product
+    ADD COLUMN IF NOT EXISTS unit_cost NUMERIC NOT NULL DEFAULT 0;
+
+-- Purchasing permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('purchasing.read', 'purchasing', 'read', 'View suppliers and purchase orders'),
+    ('purchasing.create', 'purchasing', 'create', 'Create suppliers and purchase orders'),
+    ('purchasing.update', 'purchasing', 'update', 'Update suppliers and purchase orders'),
+    ('purchasing.receive', 'purchasing', 'receive', 'Receive purchase order goods into stock')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'purchasing.read',
+    'purchasing.create',
+    'purchasing.update',
+    'purchasing.receive'
+)
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,119 @@
+-- Stock-take (stock opname) sessions. Opening a session snapshots the system
+-- quantity and average cost of every is_item product. Counters then record
+-- counted quantities per item; entries from several counters are summed, as each
+-- counts their own shelf or cabinet. Finalizing posts the variance
+-- (counted - snapshot) as stock_take_adjustment rows in mdl_trx_stock_movement
+-- and applies the same delta to mdl_dtl_institution_product_stock.
+
+-- status: open -> finalized; open -> cancelled
+CREATE TABLE IF NOT EXISTS public.mdl_trx_stock_take (
+    id                  BIGSERIAL       PRIMARY KEY,
+    id_mst_institution  BIGINT          NOT NULL,
+    status              VARCHAR(20)     NOT NULL DEFAULT 'open'
+                        CHECK (status IN ('open', 'finalized', 'cancelled')),
+    notes               TEXT,
+    finalize_reason     TEXT,
+    created_by          VARCHAR(255)    NOT NULL,
+    finalized_by        VARCHAR(255),
+    finalized_at        TIMESTAMPTZ,
+    create_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time         TIMESTAMPTZ
+);
+
+-- At most one open session per institution.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_trx_stock_take_institution_open
+    ON public.mdl_trx_stock_take (id_mst_institution)
+    WHERE status = 'open' AND delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_trx_stock_take_institution
+    ON public.mdl_trx_stock_take (id_mst_institution, create_time DESC)
+    WHERE delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_stock_take_item (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_stock_take           BIGINT          NOT NULL REFERENCES public.mdl_trx_stock_take (id),
+    id_trx_institution_product  BIGINT          NOT NULL,
+    name                        VARCHAR(255)    NOT NULL,
+    unit_type                   VARCHAR(50),
+    system_quantity             BIGINT          NOT NULL,
+    unit_cost                   NUMERIC         NOT NULL DEFAULT 0,
+    adjustment_reason           TEXT,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_dtl_stock_take_item_product UNIQUE (id_trx_stock_take, id_trx_institution_product)
+);
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_stock_take_count (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_dtl_stock_take_item  BIGINT          NOT NULL REFERENCES public.mdl_dtl_stock_take_item (id),
+    counted_by              VARCHAR(255)    NOT NULL,
+    counted_quantity        BIGINT          NOT NULL CHECK (counted_quantity >= 0),
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_dtl_stock_take_count_counter UNIQUE (id_dtl_stock_take_item, counted_by)
+);
+
+-- Stock-take permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('stock_take.read', 'stock_take', 'read', 'View stock-take sessions and variance reports'),
+    ('stock_take.create', 'stock_take', 'create', 'Open and cancel stock-take sessions'),
+    ('stock_take.count', 'stock_take', 'count', 'Enter counted quantities'),
+    ('stock_take.finalize', 'stock_take', 'finalize', 'Finalize stock-take and post adjustments')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'stock_take.read',
+    'stock_take.create',
+    'stock_take.count'
+)
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'stock_take.read',
+    'stock_take.count'
+)
+WHERE r.name = 'nurse'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
+
+-- stock_take.finalize is granted to administrators only; assign it to other
+-- roles explicitly through staff role management.
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code = 'stock_take.finalize'
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
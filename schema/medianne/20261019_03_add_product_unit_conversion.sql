@@ -0,0 +1,30 @@
+-- Per-product unit-of-measure conversions. The base unit of a product is the
+-- unit_type on its mdl_dtl_institution_product_stock row and stock quantities
+-- are always kept in that unit. Each row here declares another unit and how
+-- many base units it holds (1 box = 100 tablets -> conversion_factor 100).
+-- price optionally overrides the selling price of one such unit; when NULL
+-- the base price times conversion_factor is used.
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_institution_product_unit (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    unit_type                   VARCHAR(50)     NOT NULL,
+    conversion_factor           BIGINT          NOT NULL CHECK (conversion_factor > 0),
+    price                       NUMERIC,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_dtl_institution_product_unit_active
+    ON public.mdl_dtl_institution_product_unit (id_trx_institution_product, LOWER(unit_type))
+    WHERE delete_time IS NULL;
+
+-- Sold and purchased lines keep the unit they were entered in; quantity times
+-- conversion_factor gives the base-unit quantity moved in or out of stock.
+ALTER TABLE public.mdl_trx_visit_product
+    ADD COLUMN IF NOT EXISTS conversion_factor BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE public.mdl_dtl_purchase_order_item
+    ADD COLUMN IF NOT EXISTS unit_type VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS conversion_factor BIGINT NOT NULL DEFAULT 1;
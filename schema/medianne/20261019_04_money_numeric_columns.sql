@@ -0,0 +1,34 @@
+-- Money columns are read and written as exact decimals (pkg/type/money).
+-- Prices and totals are rounded half away from zero to 2 decimal places before
+-- they are stored, so they are fixed to NUMERIC(18,2). Costs that feed the
+-- weighted average (avg_cost and the unit_cost derived from it) keep full
+-- precision and stay unconstrained NUMERIC.
+-- Existing values are rounded the same way the application rounds them.
+
+ALTER TABLE public.mdl_trx_institution_product
+    ALTER COLUMN price TYPE NUMERIC(18,2) USING ROUND(price::numeric, 2);
+
+ALTER TABLE public.mdl_trx_visit_product
+    ALTER COLUMN price TYPE NUMERIC(18,2) USING ROUND(price::numeric, 2),
+    ALTER COLUMN discount_price TYPE NUMERIC(18,2) USING ROUND(discount_price::numeric, 2),
+    ALTER COLUMN total_price TYPE NUMERIC(18,2) USING ROUND(total_price::numeric, 2),
+    ALTER COLUMN adjusted_price TYPE NUMERIC(18,2) USING ROUND(adjusted_price::numeric, 2),
+    ALTER COLUMN unit_cost TYPE NUMERIC USING unit_cost::numeric;
+
+ALTER TABLE public.mdl_dtl_institution_product_unit
+    ALTER COLUMN price TYPE NUMERIC(18,2) USING ROUND(price::numeric, 2);
+
+ALTER TABLE public.mdl_dtl_institution_product_stock
+    ALTER COLUMN avg_cost TYPE NUMERIC USING avg_cost::numeric;
+
+ALTER TABLE public.mdl_trx_purchase_order
+    ALTER COLUMN total_amount TYPE NUMERIC(18,2) USING ROUND(total_amount::numeric, 2);
+
+ALTER TABLE public.mdl_dtl_purchase_order_item
+    ALTER COLUMN unit_cost TYPE NUMERIC(18,2) USING ROUND(unit_cost::numeric, 2);
+
+ALTER TABLE public.mdl_trx_stock_movement
+    ALTER COLUMN unit_cost TYPE NUMERIC USING unit_cost::numeric;
+
+ALTER TABLE public.mdl_dtl_stock_take_item
+    ALTER COLUMN unit_cost TYPE NUMERIC USING unit_cost::numeric;
@@ -0,0 +1,69 @@
+-- Effective-dated price lists per patient category.
+-- A patient carries a category (general, insurance, corporate, staff) which is
+-- copied onto each visit when it is opened. Visit lines are priced from the
+-- price list of the visit's category that is effective at the visit's
+-- create_time, falling back to the general list and then to the product's base
+-- price. Lists are never edited once effective: a new list for the same
+-- category closes the previous one, so past prices remain queryable.
+
+ALTER TABLE public.mdl_mst_patient_institution
+    ADD COLUMN IF NOT EXISTS patient_category VARCHAR(20) NOT NULL DEFAULT 'general';
+
+ALTER TABLE public.mdl_trx_patient_visit
+    ADD COLUMN IF NOT EXISTS patient_category VARCHAR(20) NOT NULL DEFAULT 'general';
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_price_list (
+    id                  BIGSERIAL       PRIMARY KEY,
+    id_mst_institution  BIGINT          NOT NULL,
+    name                VARCHAR(255)    NOT NULL,
+    patient_category    VARCHAR(20)     NOT NULL
+                        CHECK (patient_category IN ('general', 'insurance', 'corporate', 'staff')),
+    effective_from      TIMESTAMPTZ     NOT NULL,
+    effective_to        TIMESTAMPTZ,
+    notes               TEXT            NOT NULL DEFAULT '',
+    created_by          VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time         TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time         TIMESTAMPTZ,
+    CHECK (effective_to IS NULL OR effective_to > effective_from)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_price_list_category_effective_from
+    ON public.mdl_mst_price_list (id_mst_institution, patient_category, effective_from)
+    WHERE delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_price_list_item (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_price_list           BIGINT          NOT NULL REFERENCES public.mdl_mst_price_list (id),
+    id_trx_institution_product  BIGINT          NOT NULL,
+    price                       NUMERIC(18,2)   NOT NULL CHECK (price >= 0),
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_dtl_price_list_item_product
+    ON public.mdl_dtl_price_list_item (id_mst_price_list, id_trx_institution_product)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_dtl_price_list_item_product
+    ON public.mdl_dtl_price_list_item (id_trx_institution_product)
+    WHERE delete_time IS NULL;
+
+-- Every change of a product's base price is appended here.
+CREATE TABLE IF NOT EXISTS public.mdl_trx_product_price_history (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    old_price                   NUMERIC(18,2)   NOT NULL DEFAULT 0,
+    new_price                   NUMERIC(18,2)   NOT NULL,
+    changed_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_product_price_history_product
+    ON public.mdl_trx_product_price_history (id_trx_institution_product, create_time DESC);
+
+-- Visit lines remember the price list they were priced from (NULL = base price).
+ALTER TABLE public.mdl_trx_visit_product
+    ADD COLUMN IF NOT EXISTS id_mst_price_list BIGINT;
@@ -0,0 +1,25 @@
+-- Bill of materials of treatment products. Each row declares an item product
+-- consumed by one unit of the treatment, in the material's base (stock) unit.
+--
+-- Consumption is reconciled per visit: a treatment is counted as the larger of
+-- its base quantity on the visit cart and the number of procedures recorded
+-- with it, so billing a treatment and charting it as a procedure consume its
+-- materials once. The difference between what the visit should have consumed
+-- and what the ledger already holds for it is written to mdl_trx_stock_movement
+-- as treatment_consumption (negative) or treatment_consumption_void (positive)
+-- rows with reference_type = 'visit', so voids restore exactly what was taken.
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_treatment_material (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    id_material_product         BIGINT          NOT NULL,
+    quantity                    BIGINT          NOT NULL CHECK (quantity > 0),
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ,
+    CHECK (id_material_product <> id_trx_institution_product)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_dtl_treatment_material_active
+    ON public.mdl_dtl_treatment_material (id_trx_institution_product, id_material_product)
+    WHERE delete_time IS NULL;
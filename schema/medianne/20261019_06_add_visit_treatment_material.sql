@@ -0,0 +1,18 @@
+-- The bill of materials each treatment of a visit was consumed by. It is
+-- copied from mdl_dtl_treatment_material when the visit first consumes the
+-- treatment, and the visit keeps reconciling against the copy, so a later
+-- change to the treatment's materials applies to new visits only. A treatment
+-- that had no materials is recorded by one row with id_material_product = 0.
+--
+-- Visits consumed before keep following the current bill of materials until
+-- their next change, which records it.
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_visit_treatment_material (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_patient_visit        BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    id_material_product         BIGINT          NOT NULL DEFAULT 0,
+    quantity                    BIGINT          NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    UNIQUE (id_trx_patient_visit, id_trx_institution_product, id_material_product)
+);
@@ -0,0 +1,81 @@
+-- Tax (PPN) and service charge on visit billing.
+--
+-- Every institution product carries a tax category: 'exempt' (medical services
+-- and medicines, the default) or 'ppn' (non-medical goods). Institutions that
+-- are PKP (pengusaha kena pajak) configure their PPN rate and whether their
+-- list prices already include it; a service charge rate applies to every line.
+--
+-- Visit lines snapshot the tax treatment they were billed with, so changing the
+-- configuration or a product's category never rewrites an existing bill:
+--   tax_base        the line amount before tax (dasar pengenaan pajak)
+--   tax_amount      PPN on tax_base, zero for exempt lines or non-PKP institutions
+--   service_charge  service charge on tax_base, not itself taxed
+
+ALTER TABLE public.mdl_trx_institution_product
+    ADD COLUMN IF NOT EXISTS tax_category VARCHAR(20) NOT NULL DEFAULT 'exempt'
+        CHECK (tax_category IN ('exempt', 'ppn'));
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_institution_tax_config (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL UNIQUE,
+    is_pkp                  BOOLEAN         NOT NULL DEFAULT FALSE,
+    ppn_rate                NUMERIC(6, 4)   NOT NULL DEFAULT 0 CHECK (ppn_rate >= 0 AND ppn_rate < 1),
+    price_includes_tax      BOOLEAN         NOT NULL DEFAULT FALSE,
+    service_charge_rate     NUMERIC(6, 4)   NOT NULL DEFAULT 0 CHECK (service_charge_rate >= 0 AND service_charge_rate < 1),
+    updated_by              VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE public.mdl_trx_visit_product
+    ADD COLUMN IF NOT EXISTS tax_category   VARCHAR(20)     NOT NULL DEFAULT 'exempt',
+    ADD COLUMN IF NOT EXISTS tax_rate       NUMERIC(6, 4)   NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_inclusive  BOOLEAN         NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS tax_base       NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_amount     NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS service_charge NUMERIC(18, 2)  NOT NULL DEFAULT 0;
+
+-- lines billed before tax existed were untaxed: their whole charge is the base
+UPDATE public.mdl_trx_visit_product
+SET tax_base = CASE WHEN adjusted_price > 0 THEN adjusted_price ELSE total_price END
+WHERE tax_base = 0;
+
+-- Tax configuration permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('tax_config.read', 'tax_config', 'read', 'View the institution tax and service charge configuration'),
+    ('tax_config.update', 'tax_config', 'update', 'Change the institution tax and service charge configuration')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code = 'tax_config.read'
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
+
+-- tax_config.update is granted to administrators only
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code = 'tax_config.update'
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,130 @@
+-- Discount policies, supervisor approvals and the price override audit trail.
+--
+-- A discount policy caps the discount a role may give on a visit line without
+-- approval, as a fraction of the line's gross amount (price x quantity). The
+-- discount of a line is measured the same way whichever of discount_rate,
+-- discount_price or adjusted_price produced it. A user holding several roles
+-- gets the most generous cap; administrators are never capped. Institutions
+-- without any policy keep allowing every discount, but once one policy exists
+-- a role without a policy needs approval for any discount.
+--
+-- Discounts above the cap need an approved mdl_trx_discount_approval for the
+-- same visit and product whose discount_rate covers the line's discount. The
+-- requester cannot approve their own request.
+--
+-- Every manual change of a visit line's discount_rate, discount_price or
+-- adjusted_price writes one mdl_trx_price_override_audit row holding the old
+-- and new values, who changed them, why, and the approval that allowed it.
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_discount_policy (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL,
+    role_name               VARCHAR(100)    NOT NULL,
+    max_discount_rate       NUMERIC(5, 4)   NOT NULL CHECK (max_discount_rate >= 0 AND max_discount_rate <= 1),
+    updated_by              VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time             TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_discount_policy_active
+    ON public.mdl_mst_discount_policy (id_mst_institution, role_name)
+    WHERE delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_discount_approval (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_trx_patient_visit        BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    discount_rate               NUMERIC(5, 4)   NOT NULL CHECK (discount_rate > 0 AND discount_rate <= 1),
+    reason                      TEXT            NOT NULL,
+    status                      VARCHAR(20)     NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    requested_by                VARCHAR(255)    NOT NULL,
+    decided_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    decided_at                  TIMESTAMPTZ,
+    decision_note               TEXT            NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_discount_approval_status
+    ON public.mdl_trx_discount_approval (id_mst_institution, status, create_time DESC);
+
+CREATE INDEX IF NOT EXISTS idx_trx_discount_approval_visit
+    ON public.mdl_trx_discount_approval (id_trx_patient_visit, id_trx_institution_product);
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_price_override_audit (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_trx_patient_visit        BIGINT          NOT NULL,
+    id_trx_visit_product        BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    gross_amount                NUMERIC(18, 2)  NOT NULL,
+    old_discount_rate           NUMERIC(5, 4)   NOT NULL DEFAULT 0,
+    new_discount_rate           NUMERIC(5, 4)   NOT NULL DEFAULT 0,
+    old_discount_price          NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    new_discount_price          NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    old_adjusted_price          NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    new_adjusted_price          NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    effective_discount_rate     NUMERIC(5, 4)   NOT NULL DEFAULT 0,
+    reason                      TEXT            NOT NULL DEFAULT '',
+    id_trx_discount_approval    BIGINT,
+    changed_by                  VARCHAR(255)    NOT NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_price_override_audit_visit
+    ON public.mdl_trx_price_override_audit (id_trx_patient_visit, create_time);
+
+CREATE INDEX IF NOT EXISTS idx_trx_price_override_audit_institution
+    ON public.mdl_trx_price_override_audit (id_mst_institution, create_time DESC);
+
+-- Discount permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('discount.policy.read', 'discount', 'policy.read', 'View discount policies per role'),
+    ('discount.policy.update', 'discount', 'policy.update', 'Change discount policies per role'),
+    ('discount.approve', 'discount', 'approve', 'Approve or reject discount requests'),
+    ('discount.audit.read', 'discount', 'audit.read', 'View the price override audit trail')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'discount.policy.read',
+    'discount.audit.read'
+)
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
+
+-- changing policies and approving discounts is granted to administrators only;
+-- assign discount.approve to supervisors through staff role management.
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'discount.policy.update',
+    'discount.approve'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
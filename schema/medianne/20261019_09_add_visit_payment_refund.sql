@@ -0,0 +1,122 @@
+-- Visit payments, refunds and credit notes.
+--
+-- A visit is paid once its payments cover the invoice grand total (the sum of
+-- tax_base + tax_amount + service_charge of its lines). Payments never exceed
+-- what is still outstanding.
+--
+-- Refund and credit-note documents return selected quantities of the lines of
+-- a paid visit. A refund pays the patient back through refund_method; a credit
+-- note keeps the amount as credit for the patient. Each document line takes the
+-- returned share of the visit line:
+--   revenue         share of the line's total_price, subtracted from product statistics
+--   tax_amount      share of the line's PPN
+--   service_charge  share of the line's service charge
+--   amount          share of what the patient paid for the line (tax_base + tax + service)
+-- The last units returned of a line take whatever of the line is left, so the
+-- shares of a fully returned line add up to the line exactly.
+--
+-- When restock is requested, returned items go back to stock with a
+-- 'refund_restock' stock movement referencing the document; their cost is then
+-- subtracted from product statistics as well.
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_visit_payment (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL,
+    id_trx_patient_visit    BIGINT          NOT NULL,
+    payment_method          VARCHAR(30)     NOT NULL,
+    amount                  NUMERIC(18, 2)  NOT NULL CHECK (amount > 0),
+    reference               VARCHAR(255)    NOT NULL DEFAULT '',
+    received_by             VARCHAR(255)    NOT NULL,
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_visit_payment_visit
+    ON public.mdl_trx_visit_payment (id_trx_patient_visit);
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_visit_refund (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL,
+    id_trx_patient_visit    BIGINT          NOT NULL,
+    document_type           VARCHAR(20)     NOT NULL CHECK (document_type IN ('refund', 'credit_note')),
+    refund_method           VARCHAR(30)     NOT NULL DEFAULT '',
+    reason                  TEXT            NOT NULL,
+    restock                 BOOLEAN         NOT NULL DEFAULT FALSE,
+    total_amount            NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    tax_amount              NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    service_charge          NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    created_by              VARCHAR(255)    NOT NULL,
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_visit_refund_visit
+    ON public.mdl_trx_visit_refund (id_trx_patient_visit);
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_visit_refund_line (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_visit_refund         BIGINT          NOT NULL REFERENCES public.mdl_trx_visit_refund (id),
+    id_mst_institution          BIGINT          NOT NULL,
+    id_trx_patient_visit        BIGINT          NOT NULL,
+    id_trx_visit_product        BIGINT          NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    name                        VARCHAR(255)    NOT NULL DEFAULT '',
+    quantity                    INT             NOT NULL CHECK (quantity > 0),
+    unit_type                   VARCHAR(50)     NOT NULL DEFAULT '',
+    conversion_factor           BIGINT          NOT NULL DEFAULT 1,
+    unit_cost                   NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    revenue                     NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    tax_amount                  NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    service_charge              NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    amount                      NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    restocked                   BOOLEAN         NOT NULL DEFAULT FALSE,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dtl_visit_refund_line_refund
+    ON public.mdl_dtl_visit_refund_line (id_trx_visit_refund);
+
+CREATE INDEX IF NOT EXISTS idx_dtl_visit_refund_line_visit
+    ON public.mdl_dtl_visit_refund_line (id_trx_patient_visit, id_trx_visit_product);
+
+-- product statistics read refund lines by institution and time
+CREATE INDEX IF NOT EXISTS idx_dtl_visit_refund_line_institution_time
+    ON public.mdl_dtl_visit_refund_line (id_mst_institution, create_time);
+
+-- Billing permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('payment.create', 'payment', 'create', 'Record payments of visit invoices'),
+    ('refund.create', 'refund', 'create', 'Issue refunds and credit notes on paid visits')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code = 'payment.create'
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
+
+-- refunds pay money out, so only administrators get them by default
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code = 'refund.create'
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,120 @@
+-- Patient account ledger: deposits, visit charges, payments, returns and refunds.
+--
+-- Every row is one signed movement of a patient's account, seen from the
+-- patient: a positive balance is credit the patient can spend on later visits,
+-- a negative balance is owed.
+--   deposit   +  money paid in advance, e.g. an orthodontic down payment or installment
+--   charge    -  a visit invoice; charges follow the invoice, so a changed
+--                invoice posts the difference the next time the visit is billed
+--   payment   +  money paid against a visit invoice
+--   return    +  value of items returned by a refund or credit note
+--   refund    -  money paid back, by a refund document or from unused credit
+--
+-- Paying a visit from a deposit writes a visit payment with payment_method
+-- 'deposit' and no ledger payment: the deposit already credited the account
+-- and the visit charge spends it.
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_patient_ledger_entry (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL,
+    id_mst_patient          BIGINT          NOT NULL,
+    entry_type              VARCHAR(20)     NOT NULL
+        CHECK (entry_type IN ('deposit', 'charge', 'payment', 'return', 'refund')),
+    amount                  NUMERIC(18, 2)  NOT NULL,
+    id_trx_patient_visit    BIGINT,
+    reference_type          VARCHAR(30)     NOT NULL DEFAULT '',
+    reference_id            BIGINT          NOT NULL DEFAULT 0,
+    payment_method          VARCHAR(30)     NOT NULL DEFAULT '',
+    notes                   TEXT            NOT NULL DEFAULT '',
+    created_by              VARCHAR(255)    NOT NULL,
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_ledger_entry_patient
+    ON public.mdl_trx_patient_ledger_entry (id_mst_institution, id_mst_patient, create_time, id);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_ledger_entry_visit
+    ON public.mdl_trx_patient_ledger_entry (id_trx_patient_visit)
+    WHERE id_trx_patient_visit IS NOT NULL;
+
+-- backfill visits paid before the ledger existed: their invoice, payments and
+-- refund documents
+INSERT INTO public.mdl_trx_patient_ledger_entry
+    (id_mst_institution, id_mst_patient, entry_type, amount, id_trx_patient_visit,
+     reference_type, reference_id, created_by, create_time)
+SELECT v.id_mst_institution, v.id_mst_patient, 'charge', -inv.total, v.id,
+       'visit', v.id, 'system', pay.first_time
+FROM public.mdl_trx_patient_visit v
+JOIN (
+    SELECT id_trx_patient_visit, SUM(tax_base + tax_amount + service_charge) AS total
+    FROM public.mdl_trx_visit_product
+    WHERE delete_time IS NULL
+    GROUP BY id_trx_patient_visit
+) inv ON inv.id_trx_patient_visit = v.id
+JOIN (
+    SELECT id_trx_patient_visit, MIN(create_time) AS first_time
+    FROM public.mdl_trx_visit_payment
+    GROUP BY id_trx_patient_visit
+) pay ON pay.id_trx_patient_visit = v.id
+WHERE inv.total <> 0
+  AND NOT EXISTS (
+      SELECT 1 FROM public.mdl_trx_patient_ledger_entry l
+      WHERE l.entry_type = 'charge' AND l.id_trx_patient_visit = v.id
+  );
+
+INSERT INTO public.mdl_trx_patient_ledger_entry
+    (id_mst_institution, id_mst_patient, entry_type, amount, id_trx_patient_visit,
+     reference_type, reference_id, payment_method, notes, created_by, create_time)
+SELECT p.id_mst_institution, v.id_mst_patient, 'payment', p.amount, v.id,
+       'visit_payment', p.id, p.payment_method, p.reference, p.received_by, p.create_time
+FROM public.mdl_trx_visit_payment p
+JOIN public.mdl_trx_patient_visit v ON v.id = p.id_trx_patient_visit
+WHERE p.payment_method <> 'deposit'
+  AND NOT EXISTS (
+      SELECT 1 FROM public.mdl_trx_patient_ledger_entry l
+      WHERE l.reference_type = 'visit_payment' AND l.reference_id = p.id
+  );
+
+INSERT INTO public.mdl_trx_patient_ledger_entry
+    (id_mst_institution, id_mst_patient, entry_type, amount, id_trx_patient_visit,
+     reference_type, reference_id, payment_method, notes, created_by, create_time)
+SELECT r.id_mst_institution, v.id_mst_patient, e.entry_type, e.sign * r.total_amount, v.id,
+       'visit_refund', r.id, CASE WHEN e.entry_type = 'refund' THEN r.refund_method ELSE '' END,
+       r.reason, r.created_by, r.create_time
+FROM public.mdl_trx_visit_refund r
+JOIN public.mdl_trx_patient_visit v ON v.id = r.id_trx_patient_visit
+JOIN (VALUES ('return', 1), ('refund', -1)) AS e(entry_type, sign)
+    ON e.entry_type = 'return' OR r.document_type = 'refund'
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_trx_patient_ledger_entry l
+    WHERE l.reference_type = 'visit_refund' AND l.reference_id = r.id
+      AND l.entry_type = e.entry_type
+);
+
+-- Patient account permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('patient_account.read', 'patient_account', 'read', 'View patient account balances and statements'),
+    ('patient_account.deposit', 'patient_account', 'deposit', 'Record patient deposits')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'patient_account.read',
+    'patient_account.deposit'
+)
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
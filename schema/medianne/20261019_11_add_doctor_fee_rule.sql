@@ -0,0 +1,72 @@
+-- Doctor fee (commission) rules.
+--
+-- A rule pays the doctor of a procedure either a percentage (fee_rate, a
+-- fraction such as 0.3) of the procedure's revenue or a fixed fee_amount per
+-- procedure. doctor_id NULL applies to every doctor. A rule names at most one
+-- of a treatment product or a procedure category (the SNOMED code stored in
+-- mdl_trx_visit_procedure.category); naming neither makes it a default.
+--
+-- The most specific rule wins: product over category over default, and a
+-- doctor's own rule over the institution-wide rule of the same scope.
+--
+-- The doctor fee report counts procedures of paid visits created in the
+-- period. A procedure's revenue is the total_price of its product's lines in
+-- the visit less what was refunded of them, split evenly between the
+-- procedures sharing those lines.
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_doctor_fee_rule (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    doctor_id                   VARCHAR(50),
+    id_trx_institution_product  BIGINT,
+    procedure_category          VARCHAR(20),
+    fee_type                    VARCHAR(20)     NOT NULL CHECK (fee_type IN ('percentage', 'fixed')),
+    fee_rate                    NUMERIC(5, 4)   NOT NULL DEFAULT 0 CHECK (fee_rate >= 0 AND fee_rate <= 1),
+    fee_amount                  NUMERIC(18, 2)  NOT NULL DEFAULT 0 CHECK (fee_amount >= 0),
+    updated_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ,
+    CHECK (id_trx_institution_product IS NULL OR procedure_category IS NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_doctor_fee_rule_active
+    ON public.mdl_mst_doctor_fee_rule (
+        id_mst_institution,
+        COALESCE(doctor_id, ''),
+        COALESCE(id_trx_institution_product, 0),
+        COALESCE(procedure_category, '')
+    )
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_trx_visit_procedure_doctor_active
+    ON public.mdl_trx_visit_procedure (institution_id, doctor_id)
+    WHERE deleted_at IS NULL;
+
+-- Doctor fee permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('doctor_fee.read', 'doctor_fee', 'read', 'View doctor fee rules and the doctor fee report'),
+    ('doctor_fee.update', 'doctor_fee', 'update', 'Manage doctor fee rules')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'doctor_fee.read',
+    'doctor_fee.update'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,41 @@
+-- Daily product statistics rollups.
+--
+-- Product statistics by hour, day or week aggregate mdl_trx_visit_product and
+-- mdl_dtl_visit_refund_line live and are limited to 31 days. Monthly and
+-- yearly statistics read these daily rollups instead and may span any range.
+--
+-- A row sums one product's visit lines less its refund lines on one local day
+-- of an institution, the same way the live statistics do. Days are local to
+-- utc_offset_seconds; the rollups are kept for WIB, WITA and WIT
+-- (25200, 28800 and 32400 seconds).
+--
+-- The rollup job rebuilds every day that has visit lines changed or refund
+-- lines written since mdl_agg_rollup_state.refreshed_until, with some overlap,
+-- so rebuilding is idempotent. Its first run rebuilds the whole history.
+
+CREATE TABLE IF NOT EXISTS public.mdl_agg_product_daily_stats (
+    id_mst_institution          BIGINT          NOT NULL,
+    utc_offset_seconds          INT             NOT NULL,
+    stat_date                   DATE            NOT NULL,
+    id_trx_institution_product  BIGINT          NOT NULL,
+    name                        VARCHAR(255)    NOT NULL,
+    total_quantity              BIGINT          NOT NULL DEFAULT 0,
+    total_revenue               NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    total_cost                  NUMERIC         NOT NULL DEFAULT 0,
+    total_tax                   NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    total_service_charge        NUMERIC(18, 2)  NOT NULL DEFAULT 0,
+    refresh_time                TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id_mst_institution, utc_offset_seconds, stat_date, id_trx_institution_product)
+);
+
+CREATE TABLE IF NOT EXISTS public.mdl_agg_rollup_state (
+    name                        VARCHAR(100)    PRIMARY KEY,
+    refreshed_until             TIMESTAMPTZ     NOT NULL
+);
+
+-- finding the days to rebuild scans changed visit lines
+CREATE INDEX IF NOT EXISTS idx_trx_visit_product_update_time
+    ON public.mdl_trx_visit_product (update_time);
+
+CREATE INDEX IF NOT EXISTS idx_dtl_visit_refund_line_create_time
+    ON public.mdl_dtl_visit_refund_line (create_time);
@@ -0,0 +1,5 @@
+-- finding the days to rebuild also scans removed visit lines by when they were
+-- removed; most lines never are, so only those are indexed
+CREATE INDEX IF NOT EXISTS idx_trx_visit_product_delete_time
+    ON public.mdl_trx_visit_product (delete_time)
+    WHERE delete_time IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Product statistics count revenue as the net amount charged (the tax base of
+-- visit lines, less the tax base returned by refund lines) instead of the list
+-- total. Drop the rollups summed the old way; the rollup job rebuilds the
+-- whole history on its next run.
+DELETE FROM public.mdl_agg_product_daily_stats;
+
+DELETE FROM public.mdl_agg_rollup_state
+WHERE name = 'product_daily_stats';
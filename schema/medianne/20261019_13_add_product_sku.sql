@@ -0,0 +1,16 @@
+-- Product SKUs for catalog import and export.
+--
+-- A SKU is optional and unique per institution, ignoring case. Catalog imports
+-- upsert products by SKU, falling back to the product name for rows without
+-- one, so the same file can be imported into several branches.
+
+ALTER TABLE public.mdl_trx_institution_product
+    ADD COLUMN IF NOT EXISTS sku VARCHAR(64) NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mdl_trx_institution_product_sku
+    ON public.mdl_trx_institution_product (id_mst_institution, lower(sku))
+    WHERE sku IS NOT NULL AND delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mdl_trx_institution_product_name
+    ON public.mdl_trx_institution_product (id_mst_institution, lower(name))
+    WHERE delete_time IS NULL;
@@ -0,0 +1,132 @@
+-- Stock per location and transfers between locations.
+--
+-- mdl_dtl_institution_product_stock keeps the institution-wide quantity and
+-- average cost of a product; mdl_dtl_location_stock splits the quantity of item
+-- products across the institution's stock locations. Every institution has one
+-- main location (the warehouse); any other location may serve one service point.
+--
+-- Location quantities follow the stock ledger: every mdl_trx_stock_movement row
+-- moves the quantity of its location, and movements without a location of
+-- their own (resupply, catalog imports) book to the main location. Sales on a visit draw from the location of the visit's
+-- service point, falling back to the main location. A transfer moves quantity
+-- from one location to another and leaves the institution-wide stock unchanged.
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_stock_location (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL,
+    name                    VARCHAR(100)    NOT NULL,
+    is_main                 BOOLEAN         NOT NULL DEFAULT FALSE,
+    id_mst_service_point    BIGINT,
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time             TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_stock_location_main
+    ON public.mdl_mst_stock_location (id_mst_institution)
+    WHERE is_main AND delete_time IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_stock_location_service_point
+    ON public.mdl_mst_stock_location (id_mst_institution, id_mst_service_point)
+    WHERE id_mst_service_point IS NOT NULL AND delete_time IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_stock_location_name
+    ON public.mdl_mst_stock_location (id_mst_institution, lower(name))
+    WHERE delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_location_stock (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_stock_location       BIGINT          NOT NULL REFERENCES public.mdl_mst_stock_location (id),
+    id_trx_institution_product  BIGINT          NOT NULL,
+    quantity                    BIGINT          NOT NULL DEFAULT 0,
+    reorder_level               BIGINT          NOT NULL DEFAULT 0 CHECK (reorder_level >= 0),
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    UNIQUE (id_mst_stock_location, id_trx_institution_product)
+);
+
+ALTER TABLE public.mdl_trx_stock_movement
+    ADD COLUMN IF NOT EXISTS id_mst_stock_location BIGINT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_trx_stock_movement_location
+    ON public.mdl_trx_stock_movement (id_mst_stock_location, create_time)
+    WHERE id_mst_stock_location IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_stock_transfer (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    from_id_mst_stock_location  BIGINT          NOT NULL REFERENCES public.mdl_mst_stock_location (id),
+    to_id_mst_stock_location    BIGINT          NOT NULL REFERENCES public.mdl_mst_stock_location (id),
+    notes                       TEXT            NOT NULL DEFAULT '',
+    created_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    CHECK (from_id_mst_stock_location <> to_id_mst_stock_location)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_stock_transfer_institution
+    ON public.mdl_trx_stock_transfer (id_mst_institution, create_time DESC);
+
+CREATE TABLE IF NOT EXISTS public.mdl_dtl_stock_transfer_item (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_trx_stock_transfer       BIGINT          NOT NULL REFERENCES public.mdl_trx_stock_transfer (id),
+    id_trx_institution_product  BIGINT          NOT NULL,
+    quantity                    BIGINT          NOT NULL CHECK (quantity > 0),
+    UNIQUE (id_trx_stock_transfer, id_trx_institution_product)
+);
+
+-- Every institution with products starts with a main location holding all of
+-- its item stock.
+INSERT INTO public.mdl_mst_stock_location (id_mst_institution, name, is_main)
+SELECT DISTINCT mtip.id_mst_institution, 'Gudang Utama', TRUE
+FROM public.mdl_trx_institution_product mtip
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM public.mdl_mst_stock_location msl
+    WHERE msl.id_mst_institution = mtip.id_mst_institution
+      AND msl.is_main
+      AND msl.delete_time IS NULL
+);
+
+INSERT INTO public.mdl_dtl_location_stock (id_mst_stock_location, id_trx_institution_product, quantity)
+SELECT msl.id, mtip.id, mdips.quantity
+FROM public.mdl_trx_institution_product mtip
+JOIN public.mdl_dtl_institution_product_stock mdips
+  ON mdips.id_trx_institution_product = mtip.id
+ AND mdips.delete_time IS NULL
+JOIN public.mdl_mst_stock_location msl
+  ON msl.id_mst_institution = mtip.id_mst_institution
+ AND msl.is_main
+ AND msl.delete_time IS NULL
+WHERE mtip.is_item
+  AND mtip.delete_time IS NULL
+ON CONFLICT (id_mst_stock_location, id_trx_institution_product) DO NOTHING;
+
+-- Stock location permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('stock_location.read', 'stock_location', 'read', 'View stock locations, stock per location, transfers and the low-stock report'),
+    ('stock_location.update', 'stock_location', 'update', 'Manage stock locations and their reorder levels'),
+    ('stock_location.transfer', 'stock_location', 'transfer', 'Transfer stock between locations')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'stock_location.read',
+    'stock_location.update',
+    'stock_location.transfer'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
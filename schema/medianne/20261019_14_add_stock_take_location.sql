@@ -0,0 +1,22 @@
+-- Stock-take sessions count one stock location. The snapshot takes the
+-- quantity held at that location from mdl_dtl_location_stock, and finalizing
+-- posts the variance as movements of that location.
+ALTER TABLE public.mdl_trx_stock_take
+    ADD COLUMN IF NOT EXISTS id_mst_stock_location BIGINT;
+
+-- Sessions opened before counted the institution-wide stock, which was all
+-- booked at the main location.
+UPDATE public.mdl_trx_stock_take st
+SET id_mst_stock_location = msl.id
+FROM public.mdl_mst_stock_location msl
+WHERE msl.id_mst_institution = st.id_mst_institution
+  AND msl.is_main
+  AND msl.delete_time IS NULL
+  AND st.id_mst_stock_location IS NULL;
+
+-- At most one open session per location.
+DROP INDEX IF EXISTS public.uq_trx_stock_take_institution_open;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_trx_stock_take_location_open
+    ON public.mdl_trx_stock_take (id_mst_institution, id_mst_stock_location)
+    WHERE status = 'open' AND delete_time IS NULL;
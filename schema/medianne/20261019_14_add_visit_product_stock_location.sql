@@ -0,0 +1,6 @@
+-- Visit lines remember the stock location they were sold from, so a voided or
+-- reduced line returns its stock there even after the visit moved to another
+-- service point. NULL on lines sold before, which return to the visit's
+-- current location.
+ALTER TABLE public.mdl_trx_visit_product
+    ADD COLUMN IF NOT EXISTS id_mst_stock_location BIGINT;
@@ -0,0 +1,54 @@
+-- Patient merges: the audit trail of duplicate patients folded into a
+-- surviving patient. undo_data lists every row the merge re-pointed so the
+-- merge can be reversed.
+CREATE TABLE IF NOT EXISTS public.mdl_trx_patient_merge (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    survivor_id_mst_patient     BIGINT          NOT NULL,
+    merged_id_mst_patient       BIGINT          NOT NULL,
+    reason                      VARCHAR(500)    NOT NULL DEFAULT '',
+    undo_data                   JSONB           NOT NULL,
+    merged_by                   VARCHAR         NOT NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    undone_by                   VARCHAR         NULL,
+    undo_time                   TIMESTAMPTZ     NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_merge_institution
+    ON public.mdl_trx_patient_merge (id_mst_institution, create_time DESC);
+
+-- A patient can only be merged away once until that merge is undone.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_trx_patient_merge_merged_patient
+    ON public.mdl_trx_patient_merge (merged_id_mst_patient)
+    WHERE undo_time IS NULL;
+
+-- Duplicate detection looks patients up by date of birth within an institution.
+CREATE INDEX IF NOT EXISTS idx_mst_patient_institution_date_of_birth
+    ON public.mdl_mst_patient_institution (id_mst_institution, date_of_birth)
+    WHERE delete_time IS NULL;
+
+-- Patient merge permission (code must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('patient.merge', 'patient', 'merge', 'Merge duplicate patients and undo merges')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'patient.merge'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
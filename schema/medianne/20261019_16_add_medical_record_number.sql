@@ -0,0 +1,69 @@
+-- Medical record numbers (nomor rekam medis, No. RM).
+--
+-- Every patient gets a human-readable number, unique within the institution,
+-- built from the institution's format: a prefix, optionally the registration
+-- year, and a zero-padded sequence, e.g. RM-2026-000123. With the year in the
+-- format the sequence restarts every year; without it the sequence runs on.
+--
+-- mdl_trx_mrn_counter holds the last number issued per institution and period
+-- (the year, or 0 for a format without one). Registration takes the next number
+-- by updating the counter row in the same transaction as the patient insert,
+-- so concurrent registrations queue on the row and a rolled back registration
+-- gives its number back: the sequence has no gaps.
+--
+-- Existing patients are numbered by the backfill command in cmd/mrn.
+
+ALTER TABLE public.mdl_mst_patient_institution
+    ADD COLUMN IF NOT EXISTS medical_record_number VARCHAR(50) NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_patient_institution_mrn
+    ON public.mdl_mst_patient_institution (id_mst_institution, medical_record_number)
+    WHERE medical_record_number IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_mst_institution_mrn_config (
+    id                      BIGSERIAL       PRIMARY KEY,
+    id_mst_institution      BIGINT          NOT NULL UNIQUE,
+    prefix                  VARCHAR(10)     NOT NULL DEFAULT 'RM',
+    separator               VARCHAR(1)      NOT NULL DEFAULT '-',
+    include_year            BOOLEAN         NOT NULL DEFAULT TRUE,
+    sequence_digits         INT             NOT NULL DEFAULT 6 CHECK (sequence_digits BETWEEN 3 AND 10),
+    updated_by              VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_mrn_counter (
+    id_mst_institution      BIGINT          NOT NULL,
+    period                  INT             NOT NULL,
+    last_number             BIGINT          NOT NULL,
+    update_time             TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id_mst_institution, period)
+);
+
+-- Medical record number configuration permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('mrn_config.read', 'mrn_config', 'read', 'View the medical record number format'),
+    ('mrn_config.update', 'mrn_config', 'update', 'Change the medical record number format')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'mrn_config.read',
+    'mrn_config.update'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,33 @@
+-- Fuzzy patient search.
+--
+-- GET /v1/patient?q=... matches the term against the name with trigram word
+-- similarity, so "Muhamad" still finds "Muhammad Rizki", and against the NIK,
+-- phone number and medical record number by substring. pg_trgm lowercases
+-- trigrams itself, and its GIN indexes serve both the similarity operators and
+-- LIKE/ILIKE '%...%', so the search stays an index scan for institutions with
+-- 100k+ patients. Queries need three characters, the shortest pg_trgm takes
+-- trigrams from. Results rank by match, then by the patient's latest visit,
+-- among the 200 best matches.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_institution_name_trgm
+    ON public.mdl_mst_patient_institution USING gin (name gin_trgm_ops)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_institution_nik_trgm
+    ON public.mdl_mst_patient_institution USING gin (nik gin_trgm_ops)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_institution_phone_trgm
+    ON public.mdl_mst_patient_institution USING gin ((regexp_replace(phone_number, '\D', '', 'g')) gin_trgm_ops)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_institution_mrn_trgm
+    ON public.mdl_mst_patient_institution USING gin (medical_record_number gin_trgm_ops)
+    WHERE delete_time IS NULL;
+
+-- latest visit per patient, for ranking
+CREATE INDEX IF NOT EXISTS idx_trx_patient_visit_patient_create_time
+    ON public.mdl_trx_patient_visit (id_mst_patient, create_time DESC)
+    WHERE delete_time IS NULL;
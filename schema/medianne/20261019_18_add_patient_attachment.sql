@@ -0,0 +1,62 @@
+-- Patient attachments: X-rays, intraoral photos, referral letters, lab PDFs.
+--
+-- The file itself lives in the configured storage under storage_key (and the
+-- JPEG thumbnail of an image under thumbnail_key); this table holds what it
+-- is, whose it is and optionally the visit and tooth it belongs to. Deleting an
+-- attachment only hides it: the file is kept as part of the medical record.
+CREATE TABLE IF NOT EXISTS public.mdl_trx_patient_attachment (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_mst_patient              BIGINT          NOT NULL,
+    id_trx_patient_visit        BIGINT          NULL,
+    tooth_id                    VARCHAR(2)      NULL,
+    category                    VARCHAR(30)     NOT NULL,
+    description                 VARCHAR(500)    NOT NULL DEFAULT '',
+    file_name                   VARCHAR(255)    NOT NULL,
+    content_type                VARCHAR(100)    NOT NULL,
+    size_bytes                  BIGINT          NOT NULL,
+    checksum                    VARCHAR(64)     NOT NULL,
+    storage_key                 VARCHAR(500)    NOT NULL UNIQUE,
+    thumbnail_key               VARCHAR(500)    NULL,
+    uploaded_by                 VARCHAR(255)    NOT NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ     NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_attachment_patient
+    ON public.mdl_trx_patient_attachment (id_mst_institution, id_mst_patient, create_time DESC)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_attachment_visit
+    ON public.mdl_trx_patient_attachment (id_trx_patient_visit)
+    WHERE id_trx_patient_visit IS NOT NULL AND delete_time IS NULL;
+
+-- Patient attachment permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('attachment.read', 'attachment', 'read', 'View and download patient attachments'),
+    ('attachment.create', 'attachment', 'create', 'Upload patient attachments'),
+    ('attachment.delete', 'attachment', 'delete', 'Delete patient attachments')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'attachment.read',
+    'attachment.create',
+    'attachment.delete'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
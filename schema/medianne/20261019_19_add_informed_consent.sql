@@ -0,0 +1,83 @@
+-- Informed consent (persetujuan tindakan medis).
+--
+-- mdl_mst_consent_template holds an institution's consent text per procedure
+-- category (SNOMED CT code, as on mdl_trx_visit_procedure.category). Saving a
+-- template retires the active version and inserts the next one, so the partial
+-- unique index allows one active version per category.
+--
+-- mdl_trx_consent is a signed consent: the text rendered for the visit at
+-- signing time, the signature image of the patient or their guardian, the
+-- witness and when it was signed. Rows are never updated.
+--
+-- When a category's template is_required, saving a new procedure of that
+-- category on a visit needs a consent for the category on the visit, either for
+-- that procedure or for the visit as a whole.
+CREATE TABLE IF NOT EXISTS public.mdl_mst_consent_template (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    procedure_category          VARCHAR(20)     NOT NULL,
+    title                       VARCHAR(200)    NOT NULL,
+    body                        TEXT            NOT NULL,
+    is_required                 BOOLEAN         NOT NULL DEFAULT FALSE,
+    version                     INT             NOT NULL,
+    updated_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ     NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_consent_template_active_category
+    ON public.mdl_mst_consent_template (id_mst_institution, procedure_category)
+    WHERE delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_consent (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_trx_patient_visit        BIGINT          NOT NULL,
+    id_trx_visit_procedure      BIGINT          NULL,
+    id_mst_consent_template     BIGINT          NOT NULL REFERENCES public.mdl_mst_consent_template (id),
+    template_version            INT             NOT NULL,
+    procedure_category          VARCHAR(20)     NOT NULL,
+    title                       VARCHAR(200)    NOT NULL,
+    rendered_text               TEXT            NOT NULL,
+    signer_type                 VARCHAR(10)     NOT NULL CHECK (signer_type IN ('patient', 'guardian')),
+    signer_name                 VARCHAR(255)    NOT NULL,
+    signer_relationship         VARCHAR(50)     NULL,
+    signature_image             BYTEA           NOT NULL,
+    signature_content_type      VARCHAR(50)     NOT NULL,
+    witness_name                VARCHAR(255)    NOT NULL,
+    content_hash                VARCHAR(64)     NOT NULL,
+    signed_at                   TIMESTAMPTZ     NOT NULL,
+    recorded_by                 VARCHAR(255)    NOT NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_consent_visit
+    ON public.mdl_trx_consent (id_mst_institution, id_trx_patient_visit);
+
+-- Informed consent permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('consent_template.update', 'consent_template', 'update', 'Write and retire informed consent templates'),
+    ('consent.create', 'consent', 'create', 'Record signed informed consents')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'consent_template.update',
+    'consent.create'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,37 @@
+-- Family, guardian and emergency contact relations of patients.
+--
+-- A relation points either at another patient of the institution
+-- (id_mst_patient_related), whose record then supplies the person's details,
+-- or at a person who is not a patient, described by the name and contact
+-- columns. For a related patient those columns keep a copy of their details
+-- taken when the relation was saved, shown if the patient is later merged away.
+--
+-- The relation with is_recall_contact is who recalls of the patient go to;
+-- saving a new recall contact unmarks the previous one. Relations map to the
+-- FHIR RelatedPerson resource.
+CREATE TABLE IF NOT EXISTS public.mdl_mst_patient_relation (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_mst_patient              BIGINT          NOT NULL,
+    id_mst_patient_related      BIGINT          NULL,
+    relationship                VARCHAR(20)     NOT NULL CHECK (relationship IN ('parent', 'spouse', 'guardian', 'emergency_contact')),
+    name                        VARCHAR(255)    NOT NULL DEFAULT '',
+    nik                         VARCHAR(20)     NOT NULL DEFAULT '',
+    sex                         VARCHAR(10)     NOT NULL DEFAULT '',
+    phone_number                VARCHAR(30)     NOT NULL DEFAULT '',
+    address                     TEXT            NOT NULL DEFAULT '',
+    is_recall_contact           BOOLEAN         NOT NULL DEFAULT FALSE,
+    notes                       TEXT            NOT NULL DEFAULT '',
+    updated_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ     NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_relation_patient
+    ON public.mdl_mst_patient_relation (id_mst_institution, id_mst_patient)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_relation_related
+    ON public.mdl_mst_patient_relation (id_mst_patient_related)
+    WHERE id_mst_patient_related IS NOT NULL;
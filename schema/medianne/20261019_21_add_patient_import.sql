@@ -0,0 +1,72 @@
+-- Bulk patient imports from the spreadsheets of a clinic's previous system.
+--
+-- An upload is stored with its rows and column mapping as a queued import; the
+-- patient import job of the API claims it and registers the patients in
+-- batches, saving its progress and the problems of every line after each
+-- batch. An import left processing by a stopped server is claimed again once
+-- its progress is stale and carries on after the last saved batch.
+--
+-- The number a patient had in the previous system is kept as
+-- legacy_record_number, next to the medical record number issued on import,
+-- and so is the date of their last visit there.
+ALTER TABLE public.mdl_mst_patient_institution
+    ADD COLUMN IF NOT EXISTS legacy_record_number VARCHAR(50) NULL,
+    ADD COLUMN IF NOT EXISTS legacy_last_visit_date DATE NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mst_patient_institution_legacy_record_number
+    ON public.mdl_mst_patient_institution (id_mst_institution, legacy_record_number)
+    WHERE legacy_record_number IS NOT NULL AND delete_time IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_patient_import (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    file_name                   VARCHAR(255)    NOT NULL DEFAULT '',
+    status                      VARCHAR(20)     NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'processing', 'completed', 'failed')),
+    options                     JSONB           NOT NULL DEFAULT '{}',
+    rows                        JSONB           NOT NULL,
+    total_rows                  INT             NOT NULL DEFAULT 0,
+    processed_rows              INT             NOT NULL DEFAULT 0,
+    imported_rows               INT             NOT NULL DEFAULT 0,
+    duplicate_rows              INT             NOT NULL DEFAULT 0,
+    failed_rows                 INT             NOT NULL DEFAULT 0,
+    errors                      JSONB           NOT NULL DEFAULT '[]',
+    failure_reason              TEXT            NOT NULL DEFAULT '',
+    created_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    start_time                  TIMESTAMPTZ     NULL,
+    finish_time                 TIMESTAMPTZ     NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_import_institution
+    ON public.mdl_trx_patient_import (id_mst_institution, create_time DESC);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_import_pending
+    ON public.mdl_trx_patient_import (id)
+    WHERE status IN ('queued', 'processing');
+
+-- Patient import permission (code must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('patient.import', 'patient', 'import', 'Import patients from the spreadsheets of another system')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'patient.import'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,49 @@
+-- Requests of patients under the personal data protection law (UU PDP):
+-- exports of everything held about a patient and anonymisations. Every request
+-- is kept as the audit trail of who handled it and why.
+--
+-- Anonymising scrubs what identifies the patient and stamps anonymise_time;
+-- visits, clinical records and billing stay for their retention periods.
+ALTER TABLE public.mdl_mst_patient_institution
+    ADD COLUMN IF NOT EXISTS anonymise_time TIMESTAMPTZ NULL;
+
+CREATE TABLE IF NOT EXISTS public.mdl_trx_patient_data_request (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_mst_patient              BIGINT          NOT NULL,
+    request_type                VARCHAR(20)     NOT NULL CHECK (request_type IN ('export', 'anonymise')),
+    reason                      VARCHAR(500)    NOT NULL DEFAULT '',
+    requested_by                VARCHAR(255)    NOT NULL,
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trx_patient_data_request_patient
+    ON public.mdl_trx_patient_data_request (id_mst_institution, id_mst_patient, create_time DESC);
+
+-- Patient personal data permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('patient.data_export', 'patient', 'data_export', 'Export everything held about a patient'),
+    ('patient.anonymise', 'patient', 'anonymise', 'Anonymise a patient, keeping clinical and billing records')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'patient.data_export',
+    'patient.anonymise'
+)
+WHERE r.name = 'administrator'
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );
@@ -0,0 +1,85 @@
+-- Payers of a patient: BPJS Kesehatan membership, private insurance policies
+-- and corporate arrangements. A visit is opened against one of them, which
+-- picks the price list and splits the invoice between the payer and the
+-- patient. The coverage of the payer is copied onto the visit, so later
+-- changes to the payer do not reprice or resplit visits already opened.
+--
+-- Eligibility is checked against the payer (BPJS VClaim, the insurer) through
+-- the configured checker; the last result is kept on the payer.
+CREATE TABLE IF NOT EXISTS public.mdl_mst_patient_payer (
+    id                          BIGSERIAL       PRIMARY KEY,
+    id_mst_institution          BIGINT          NOT NULL,
+    id_mst_patient              BIGINT          NOT NULL,
+    payer_type                  VARCHAR(20)     NOT NULL CHECK (payer_type IN ('bpjs', 'insurance', 'corporate')),
+    member_number               VARCHAR(50)     NOT NULL,
+    bpjs_class                  VARCHAR(1)      NULL CHECK (bpjs_class IN ('1', '2', '3')),
+    fktp_code                   VARCHAR(20)     NOT NULL DEFAULT '',
+    fktp_name                   VARCHAR(255)    NOT NULL DEFAULT '',
+    insurer_name                VARCHAR(255)    NOT NULL DEFAULT '',
+    policy_number               VARCHAR(50)     NOT NULL DEFAULT '',
+    policy_holder               VARCHAR(255)    NOT NULL DEFAULT '',
+    coverage_rate               NUMERIC(5, 4)   NOT NULL DEFAULT 1 CHECK (coverage_rate >= 0 AND coverage_rate <= 1),
+    coverage_limit              NUMERIC(18, 2)  NULL CHECK (coverage_limit >= 0),
+    valid_from                  DATE            NULL,
+    valid_to                    DATE            NULL,
+    is_default                  BOOLEAN         NOT NULL DEFAULT FALSE,
+    eligibility_status          VARCHAR(20)     NOT NULL DEFAULT 'unchecked'
+                                CHECK (eligibility_status IN ('unchecked', 'eligible', 'ineligible')),
+    eligibility_message         VARCHAR(500)    NOT NULL DEFAULT '',
+    eligibility_check_time      TIMESTAMPTZ     NULL,
+    notes                       TEXT            NOT NULL DEFAULT '',
+    updated_by                  VARCHAR(255)    NOT NULL DEFAULT '',
+    create_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    update_time                 TIMESTAMPTZ     NOT NULL DEFAULT NOW(),
+    delete_time                 TIMESTAMPTZ     NULL,
+    CHECK (valid_to IS NULL OR valid_from IS NULL OR valid_to >= valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_payer_patient
+    ON public.mdl_mst_patient_payer (id_mst_institution, id_mst_patient)
+    WHERE delete_time IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mst_patient_payer_member_number
+    ON public.mdl_mst_patient_payer (id_mst_institution, payer_type, member_number)
+    WHERE delete_time IS NULL;
+
+-- The payer a visit was opened against, with its coverage at the time.
+ALTER TABLE public.mdl_trx_patient_visit
+    ADD COLUMN IF NOT EXISTS id_mst_patient_payer BIGINT NULL,
+    ADD COLUMN IF NOT EXISTS payer_type VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS payer_coverage_rate NUMERIC(5, 4) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS payer_coverage_limit NUMERIC(18, 2) NULL;
+
+-- BPJS visits are priced from their own list, the INA-CBG and capitation
+-- tariffs, falling back to the general list like the other categories.
+ALTER TABLE public.mdl_mst_price_list
+    DROP CONSTRAINT IF EXISTS mdl_mst_price_list_patient_category_check;
+ALTER TABLE public.mdl_mst_price_list
+    ADD CONSTRAINT mdl_mst_price_list_patient_category_check
+    CHECK (patient_category IN ('general', 'bpjs', 'insurance', 'corporate', 'staff'));
+
+-- Patient payer permissions (codes must match internal/entity/constant/permission/permission.go)
+INSERT INTO public.mdl_mst_permission (code, resource, action, description)
+SELECT v.code, v.resource, v.action, v.description
+FROM (VALUES
+    ('patient_payer.update', 'patient_payer', 'update', 'Manage the BPJS membership and insurance of patients and check their eligibility')
+) AS v(code, resource, action, description)
+WHERE NOT EXISTS (
+    SELECT 1 FROM public.mdl_mst_permission p WHERE p.code = v.code
+);
+
+INSERT INTO public.mdl_map_role_permission (id_mst_role, id_mst_permission)
+SELECT r.id, p.id
+FROM public.mdl_mst_role r
+JOIN public.mdl_mst_permission p ON p.code IN (
+    'patient_payer.update'
+)
+WHERE r.name IN ('administrator', 'clerk')
+  AND r.delete_time IS NULL
+  AND p.delete_time IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM public.mdl_map_role_permission mrp
+      WHERE mrp.id_mst_role = r.id
+        AND mrp.id_mst_permission = p.id
+  );